@@ -0,0 +1,245 @@
+//! `git blame` annotation for `codenav blame`.
+//!
+//! Each file is blamed once, whole, with `git blame --line-porcelain`, and a
+//! [`Blamer`] keeps the result so every symbol in the file is annotated from it.
+
+use crate::core::Node;
+use anyhow::{bail, Context, Result};
+use chrono::{DateTime, FixedOffset, TimeZone};
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::process::Command;
+
+/// The commit a line was last changed in
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Commit {
+    /// `None` for a line that is not committed yet
+    pub hash: Option<String>,
+    pub author: String,
+    /// Author date, in the author's time zone
+    pub date: DateTime<FixedOffset>,
+    /// First line of the commit message
+    pub summary: String,
+}
+
+impl Commit {
+    /// The hash abbreviated to 7 characters, or `uncommitted`
+    pub fn short_hash(&self) -> &str {
+        match &self.hash {
+            Some(hash) => &hash[..hash.len().min(7)],
+            None => "uncommitted",
+        }
+    }
+}
+
+/// Blame for a symbol's declaration, as returned by [`Blamer::annotate`]
+#[derive(Debug, Clone)]
+pub struct SymbolBlame<'a> {
+    pub node: &'a Node,
+    /// 1-based lines blamed, inclusive
+    pub start_line: usize,
+    pub end_line: usize,
+    /// The newest of the lines' commits
+    pub last_modified: Commit,
+    /// Lines edited in the work tree and not committed
+    pub uncommitted: usize,
+}
+
+/// Parse `git blame --line-porcelain` output into one commit per line of the file
+pub fn parse_porcelain(text: &str) -> Result<Vec<Commit>> {
+    let mut commits = Vec::new();
+    let mut hash: Option<&str> = None;
+    let (mut author, mut summary) = ("", "");
+    let (mut time, mut tz): (i64, &str) = (0, "+0000");
+
+    for line in text.lines() {
+        // The line's content ends its entry
+        if line.starts_with('\t') {
+            let Some(hash) = hash.take() else {
+                bail!("Malformed git blame output: content before a commit header");
+            };
+            let uncommitted = hash.bytes().all(|b| b == b'0');
+            commits.push(Commit {
+                hash: (!uncommitted).then(|| hash.to_string()),
+                author: author.to_string(),
+                date: date(time, tz)?,
+                summary: summary.to_string(),
+            });
+            continue;
+        }
+        let (key, value) = line.split_once(' ').unwrap_or((line, ""));
+        match key {
+            "author" => author = value,
+            "author-time" => {
+                time = value
+                    .parse::<i64>()
+                    .with_context(|| format!("Malformed author-time: {}", value))?
+            }
+            "author-tz" => tz = value,
+            "summary" => summary = value,
+            _ if hash.is_none() => hash = Some(key),
+            _ => {}
+        }
+    }
+    Ok(commits)
+}
+
+/// `seconds` since the epoch in a `+hhmm` time zone
+fn date(seconds: i64, tz: &str) -> Result<DateTime<FixedOffset>> {
+    let digits: i32 = tz
+        .get(1..)
+        .unwrap_or_default()
+        .parse::<i32>()
+        .with_context(|| format!("Malformed author-tz: {}", tz))?;
+    let offset =
+        (digits / 100 * 3600 + digits % 100 * 60) * if tz.starts_with('-') { -1 } else { 1 };
+    FixedOffset::east_opt(offset)
+        .and_then(|zone| zone.timestamp_opt(seconds, 0).single())
+        .with_context(|| format!("Malformed author date: {} {}", seconds, tz))
+}
+
+/// Blames files on demand, each at most once
+#[derive(Debug, Default)]
+pub struct Blamer {
+    /// Per file, its lines' commits or why git could not blame it
+    files: HashMap<PathBuf, std::result::Result<Vec<Commit>, String>>,
+}
+
+impl Blamer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// The commit of every line of `path`, running `git blame` the first time the
+    /// file is asked for
+    pub fn file(&mut self, path: &Path) -> std::result::Result<&[Commit], &str> {
+        let blame = self
+            .files
+            .entry(path.to_path_buf())
+            .or_insert_with(|| blame_file(path).map_err(|e| format!("{:#}", e)));
+        match blame {
+            Ok(commits) => Ok(commits),
+            Err(reason) => Err(reason),
+        }
+    }
+
+    /// Blame the lines of `node`'s declaration, doc comment included. Fails with the
+    /// reason when its file is outside a git work tree, not tracked, or shorter than
+    /// the range the index recorded.
+    pub fn annotate<'a>(&mut self, node: &'a Node) -> std::result::Result<SymbolBlame<'a>, String> {
+        let (start_line, end_line) = match node.span {
+            Some(span) => (span.start.line, span.end.line),
+            None => (node.line, node.end_line.max(node.line)),
+        };
+        let commits = self.file(&node.file_path)?;
+        let Some(lines) = commits.get(start_line.saturating_sub(1)..end_line) else {
+            return Err(format!(
+                "{} has changed since {} was indexed; re-index it",
+                node.file_path.display(),
+                node.name
+            ));
+        };
+        // Ties go to the first of the lines
+        let mut last_modified = &lines[0];
+        for commit in lines {
+            if commit.date > last_modified.date {
+                last_modified = commit;
+            }
+        }
+        Ok(SymbolBlame {
+            node,
+            start_line,
+            end_line,
+            last_modified: last_modified.clone(),
+            uncommitted: lines.iter().filter(|c| c.hash.is_none()).count(),
+        })
+    }
+}
+
+/// Run `git blame --line-porcelain` on `path` from its own directory, so files of
+/// several repositories can be blamed in one run
+fn blame_file(path: &Path) -> Result<Vec<Commit>> {
+    let (Some(directory), Some(name)) = (path.parent(), path.file_name()) else {
+        bail!("{} is not a file", path.display());
+    };
+    let directory = match directory.as_os_str().is_empty() {
+        true => Path::new("."),
+        false => directory,
+    };
+    let output = Command::new("git")
+        .arg("-C")
+        .arg(directory)
+        .args(["blame", "--line-porcelain", "--"])
+        .arg(name)
+        .output()
+        .context("Failed to run git")?;
+    if !output.status.success() {
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        match stderr.contains("not a git repository") {
+            true => bail!("{} is not in a git work tree", path.display()),
+            false => bail!(
+                "git blame {} failed: {}",
+                path.display(),
+                stderr.trim().trim_start_matches("fatal: ")
+            ),
+        }
+    }
+    parse_porcelain(&String::from_utf8_lossy(&output.stdout))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const PORCELAIN: &str = "\
+3f1c2e0a9b8d7c6e5f4a3b2c1d0e9f8a7b6c5d4e 1 1 2
+author Ada Lovelace
+author-mail <ada@example.com>
+author-time 1700000000
+author-tz +0100
+committer Ada Lovelace
+committer-mail <ada@example.com>
+committer-time 1700000000
+committer-tz +0100
+summary Add the calculator
+filename calc.go
+\tfunc Add(a, b int) int {
+0000000000000000000000000000000000000000 2 2 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1800000000
+author-tz -0230
+committer Not Committed Yet
+committer-mail <not.committed.yet>
+committer-time 1800000000
+committer-tz -0230
+summary Version of calc.go from calc.go
+previous 3f1c2e0a9b8d7c6e5f4a3b2c1d0e9f8a7b6c5d4e calc.go
+filename calc.go
+\t\treturn a + b + 0
+";
+
+    #[test]
+    fn test_parse_porcelain() {
+        let commits = parse_porcelain(PORCELAIN).unwrap();
+        assert_eq!(commits.len(), 2);
+
+        assert_eq!(commits[0].short_hash(), "3f1c2e0");
+        assert_eq!(commits[0].author, "Ada Lovelace");
+        assert_eq!(commits[0].summary, "Add the calculator");
+        assert_eq!(commits[0].date.to_rfc3339(), "2023-11-14T23:13:20+01:00");
+
+        // The all-zero hash marks a line edited in the work tree
+        assert_eq!(commits[1].hash, None);
+        assert_eq!(commits[1].short_hash(), "uncommitted");
+        assert_eq!(
+            commits[1].date.offset().local_minus_utc(),
+            -(2 * 3600 + 30 * 60)
+        );
+    }
+
+    #[test]
+    fn test_content_before_a_header_is_an_error() {
+        assert!(parse_porcelain("\tfunc Add() {}\n").is_err());
+    }
+}
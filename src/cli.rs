@@ -16,28 +16,78 @@ pub struct Cli {
     /// Quiet mode (errors only)
     #[arg(short, long, global = true)]
     pub quiet: bool,
+
+    /// Emit machine-readable JSON (same as --output json)
+    #[arg(long, global = true)]
+    pub json: bool,
+
+    /// Leave out possible calls inferred through function-typed parameters
+    #[arg(long, global = true)]
+    pub no_indirect: bool,
+
+    /// Leave out possible calls of implementations through interface methods
+    #[arg(long, global = true)]
+    pub no_dynamic: bool,
+
+    /// Go: hide unexported symbols, and show calls through unexported functions as calls
+    /// between the exported ones on either side
+    #[arg(long, global = true)]
+    pub exported_only: bool,
+
+    /// Go: keep only symbols built for GOOS/GOARCH, from an index made with --all-platforms
+    #[arg(long, global = true, value_name = "GOOS/GOARCH")]
+    pub platform: Option<String>,
+
+    /// Print the syntax errors and other diagnostics recorded when the index was built
+    #[arg(long, global = true)]
+    pub show_errors: bool,
 }
 
 #[derive(Subcommand)]
 pub enum Commands {
     /// Index a codebase to build a navigable code graph
+    #[command(args_conflicts_with_subcommands = true, subcommand_negates_reqs = true)]
     Index {
-        /// Directory to parse
-        directory: PathBuf,
+        #[command(subcommand)]
+        action: Option<IndexAction>,
+
+        /// Directory to parse; with --root, the directory the roots are relative to,
+        /// by default the current one
+        #[arg(required_unless_present = "root")]
+        directory: Option<PathBuf>,
+
+        /// Index this directory as one root of a multi-root index, e.g. a module of a
+        /// monorepo (can be specified multiple times). Without it, the modules a go.work
+        /// in the directory uses are the roots.
+        #[arg(long, value_name = "DIR")]
+        root: Vec<PathBuf>,
 
         /// Output file
         #[arg(short, long, default_value = "codenav.bin")]
         output: PathBuf,
 
-        /// Language: go, typescript, python (auto-detect if not specified)
+        /// Language: go, typescript, javascript, python (every one found if not specified)
         #[arg(short, long)]
         language: Option<String>,
 
-        /// Exclude files matching pattern (can be specified multiple times)
+        /// Exclude paths matching a gitignore-style pattern, relative to the directory
+        /// (can be specified multiple times)
         #[arg(short, long)]
         exclude: Vec<String>,
 
-        /// Include test files
+        /// Index files that .gitignore files exclude
+        #[arg(long)]
+        no_gitignore: bool,
+
+        /// Descend into directories whose name starts with a dot
+        #[arg(long)]
+        include_hidden: bool,
+
+        /// Go: index files marked `// Code generated ... DO NOT EDIT.`
+        #[arg(long, default_value_t = true, action = clap::ArgAction::Set)]
+        include_generated: bool,
+
+        /// Go: also index _test.go files, for coverage-map; tests stay out of deadcode
         #[arg(long)]
         include_tests: bool,
 
@@ -49,6 +99,44 @@ pub enum Commands {
         #[arg(long)]
         force: bool,
 
+        /// Re-parse every file instead of reusing the per-file cache in .code-navigator/
+        #[arg(long)]
+        no_cache: bool,
+
+        /// Go: also index packages under vendor/ directories
+        #[arg(long)]
+        include_vendor: bool,
+
+        /// Go: select files for this GOOS instead of the host's
+        #[arg(long, conflicts_with = "all_platforms")]
+        goos: Option<String>,
+
+        /// Go: select files for this GOARCH instead of the host's
+        #[arg(long, conflicts_with = "all_platforms")]
+        goarch: Option<String>,
+
+        /// Go: build tags that count as set, comma-separated
+        #[arg(long, value_delimiter = ',', conflicts_with = "all_platforms")]
+        tags: Vec<String>,
+
+        /// Go: satisfy go1.N build tags only up to this release, e.g. 1.21 (default: all)
+        #[arg(long, conflicts_with = "all_platforms")]
+        go_version: Option<String>,
+
+        /// Go: index files for every platform, tagging symbols with their build constraint
+        #[arg(long)]
+        all_platforms: bool,
+
+        /// Parse this many files at once (default: one per CPU core)
+        #[arg(short, long)]
+        jobs: Option<usize>,
+
+        /// Hold less in memory, for very large trees: Go files are merged in batches,
+        /// and references go to a .refs file beside the output that only the commands
+        /// using them load
+        #[arg(long)]
+        low_memory: bool,
+
         /// Enable comprehensive benchmarking and output detailed metrics
         #[arg(long)]
         benchmark: bool,
@@ -58,8 +146,60 @@ pub enum Commands {
         benchmark_json: Option<PathBuf>,
     },
 
+    /// Watch a Go directory and re-index files as they change
+    Watch {
+        /// Directory to watch
+        directory: PathBuf,
+
+        /// Watch this directory, relative to the one above, as one root of a multi-root
+        /// index, as for `index` (can be specified multiple times)
+        #[arg(long, value_name = "DIR")]
+        root: Vec<PathBuf>,
+
+        /// Also write the updated graph here after every batch of changes
+        #[arg(short, long)]
+        output: Option<PathBuf>,
+
+        /// Wait this long after the last change before re-indexing
+        #[arg(long, default_value = "200")]
+        debounce_ms: u64,
+    },
+
+    /// Serve definitions, references, symbols and call hierarchy over LSP on stdio (Go)
+    Lsp,
+
+    /// Serve the graph as a read-only HTTP JSON API
+    Serve {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Address to listen on
+        #[arg(long, default_value = "127.0.0.1:7878")]
+        addr: String,
+    },
+
+    /// Serve symbol search, call graphs, references and source as MCP tools on stdio (Go)
+    Mcp {
+        /// Directory to index on the first tool call
+        #[arg(default_value = ".")]
+        directory: PathBuf,
+
+        /// Index _test.go files too
+        #[arg(long)]
+        include_tests: bool,
+
+        /// Longest source snippet a tool returns, in bytes
+        #[arg(long, default_value = "4000")]
+        max_snippet_bytes: usize,
+    },
+
     /// Query nodes in the graph
     Query {
+        /// Filter terms, all of which must match: kind:, name: (name:~ for part of the
+        /// name), receiver:, exported:true|false, file:, package:
+        filter: Option<String>,
+
         /// Graph file
         #[arg(short, long, default_value = "codenav.bin")]
         graph: PathBuf,
@@ -72,15 +212,20 @@ pub enum Commands {
         #[arg(short, long)]
         count: bool,
 
-        /// Limit results
+        /// Limit results; JSON output becomes a page with the total count
         #[arg(long)]
         limit: Option<usize>,
 
+        /// Skip this many results; JSON output becomes a page with the total count
+        #[arg(long)]
+        offset: Option<usize>,
+
         /// Filter by name (supports wildcards)
         #[arg(long)]
         name: Option<String>,
 
-        /// Filter by type: function, method, handler
+        /// Filter by type: function, method, handler, benchmark, fuzz, struct, interface, type,
+        /// const, var, field
         #[arg(long)]
         r#type: Option<String>,
 
@@ -92,9 +237,135 @@ pub enum Commands {
         #[arg(long)]
         file: Option<String>,
 
+        /// Only symbols of this root of a multi-root index, e.g. svc/a
+        #[arg(long)]
+        root: Option<String>,
+
         /// Filter by tag
         #[arg(long)]
         tag: Option<String>,
+
+        /// Only functions launched as a goroutine somewhere (`go f()`)
+        #[arg(long)]
+        only_goroutines: bool,
+
+        /// Only functions some `defer` statement calls
+        #[arg(long)]
+        only_deferred: bool,
+
+        /// Add a column with each function's cyclomatic complexity
+        #[arg(long)]
+        with_complexity: bool,
+        /// Parse this Go file on its own instead of loading a graph; - reads stdin
+        #[arg(long, value_name = "FILE", conflicts_with = "graph")]
+        source: Option<PathBuf>,
+    },
+
+    /// Find symbols by approximate name
+    Search {
+        /// Search text, e.g. "calc add" or "prmsg"
+        query: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Only return these kinds: function, method, handler, middleware, benchmark, fuzz,
+        /// struct, interface, type, const, var, field (comma-separated)
+        #[arg(short, long, value_delimiter = ',')]
+        kind: Vec<String>,
+
+        /// Maximum number of results [default: 20]; given, JSON output becomes a page with
+        /// the total count
+        #[arg(long)]
+        limit: Option<usize>,
+
+        /// Skip this many results; JSON output becomes a page with the total count
+        #[arg(long)]
+        offset: Option<usize>,
+
+        /// Only return symbols matching these filter terms, as for `query`, e.g.
+        /// "kind:method exported:true"
+        #[arg(long)]
+        filter: Option<String>,
+
+        /// Only return symbols of this root of a multi-root index, e.g. svc/a
+        #[arg(long)]
+        root: Option<String>,
+
+        /// Print only the number of matches
+        #[arg(long)]
+        count: bool,
+
+        /// Output format: table, json
+        #[arg(short, long, default_value = "table")]
+        output: String,
+    },
+
+    /// Show a symbol's signature and doc comment
+    Doc {
+        /// Function, method, type or field, e.g. Add or (*Calculator).Add
+        symbol: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
+        output: String,
+    },
+
+    /// Print a symbol's source, from its doc comment through the closing brace
+    Source {
+        /// Function, method, type or field, e.g. Add or (*Calculator).Add
+        symbol: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Lines of context before and after the declaration
+        #[arg(short = 'C', long, default_value = "0")]
+        context: usize,
+
+        /// Prefix each line with its line number
+        #[arg(short = 'n', long)]
+        with_line_numbers: bool,
+
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
+        output: String,
+    },
+
+    /// Show who last changed symbols, from git blame over their declarations
+    Blame {
+        /// Functions, methods, types or fields, e.g. Add or (*Calculator).Add
+        #[arg(required = true)]
+        symbols: Vec<String>,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
+        output: String,
+    },
+
+    /// Show a file's declarations as a tree, with fields and methods under their type
+    Outline {
+        /// Source file, as indexed or relative to the indexed root; without an index, or
+        /// with - for stdin, a Go file parsed on its own
+        file: PathBuf,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
+        output: String,
     },
 
     /// Trace function dependencies (what does this call?)
@@ -111,7 +382,7 @@ pub enum Commands {
         #[arg(short, long, default_value = "1")]
         depth: usize,
 
-        /// Output format: tree, json, dot
+        /// Output format: tree, json, dot, mermaid
         #[arg(short, long, default_value = "tree")]
         output: String,
 
@@ -144,6 +415,375 @@ pub enum Commands {
         /// Show line numbers
         #[arg(long)]
         show_lines: bool,
+
+        /// Include indirect callers (callers of callers)
+        #[arg(long)]
+        transitive: bool,
+
+        /// Traversal depth for --transitive
+        #[arg(short, long, default_value = "5")]
+        depth: usize,
+
+        /// Maximum number of callers to list; JSON output becomes a page with the total count
+        #[arg(long)]
+        limit: Option<usize>,
+
+        /// Skip this many callers; JSON output becomes a page with the total count
+        #[arg(long)]
+        offset: Option<usize>,
+
+        /// Only callers in this root of a multi-root index, e.g. svc/a
+        #[arg(long)]
+        root: Option<String>,
+    },
+
+    /// Find what a function calls; with --transitive, everything it may end up calling
+    Callees {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Function or method name
+        function: String,
+
+        /// Include callees of callees
+        #[arg(long)]
+        transitive: bool,
+
+        /// Traversal depth for --transitive
+        #[arg(short, long, default_value = "5")]
+        depth: usize,
+
+        /// Leave out calls outside the index (external), or list calls into other
+        /// packages without following them (package); comma-separated
+        #[arg(long, value_delimiter = ',')]
+        prune: Vec<String>,
+
+        /// Also list every call found on the way, to draw the subgraph
+        #[arg(long)]
+        with_edges: bool,
+
+        /// Only callees defined in this root of a multi-root index, e.g. svc/a
+        #[arg(long)]
+        root: Option<String>,
+
+        /// Show count only
+        #[arg(short, long)]
+        count: bool,
+
+        /// Output format: tree, json, table
+        #[arg(short, long, default_value = "tree")]
+        output: String,
+    },
+
+    /// Find every reference to a symbol: calls, plus uses as a value such as
+    /// `f := PrintMessage`, `run(Add)` or `(*Calculator).Add`
+    References {
+        /// Function, method, constant, variable or field name
+        symbol: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Only these kinds: call, assignment, argument, method_value, value, write, closure
+        /// (comma-separated)
+        #[arg(short, long, value_delimiter = ',')]
+        kind: Vec<String>,
+
+        /// Maximum number of references to list; JSON output becomes a page with the total
+        /// count
+        #[arg(long)]
+        limit: Option<usize>,
+
+        /// Skip this many references; JSON output becomes a page with the total count
+        #[arg(long)]
+        offset: Option<usize>,
+
+        /// Only references in this root of a multi-root index, e.g. svc/a
+        #[arg(long)]
+        root: Option<String>,
+
+        /// Output format: tree, json, table
+        #[arg(short, long, default_value = "tree")]
+        output: String,
+    },
+
+    /// Show where a struct field is read, written and set in composite literals
+    FieldUsage {
+        /// Field, e.g. Calculator.name or name
+        field: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Only these kinds: read, write, init (comma-separated)
+        #[arg(short, long, value_delimiter = ',')]
+        kind: Vec<String>,
+
+        /// Output format: tree, json, table
+        #[arg(short, long, default_value = "tree")]
+        output: String,
+    },
+
+    /// List the tests that call a function, directly or through other functions
+    CoverageMap {
+        /// Function or method, e.g. PrintMessage or (*Calculator).Add
+        symbol: String,
+
+        /// Graph file (index with --include-tests)
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Most calls between a test and the function
+        #[arg(long, default_value = "10")]
+        max_depth: usize,
+
+        /// Output format: tree, json
+        #[arg(short, long, default_value = "tree")]
+        output: String,
+    },
+
+    /// List the benchmarks and fuzz targets that call a function, directly or through
+    /// other functions, including through their b.Run and f.Fuzz callbacks
+    Benchmarks {
+        /// Function or method, e.g. PrintMessage or (*Calculator).Add
+        symbol: String,
+
+        /// Graph file (index with --include-tests)
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Most calls between a benchmark and the function
+        #[arg(long, default_value = "10")]
+        max_depth: usize,
+
+        /// Output format: tree, json
+        #[arg(short, long, default_value = "tree")]
+        output: String,
+    },
+
+    /// Plan renaming a symbol: every edit it needs and any name it would collide with
+    Rename {
+        /// Symbol to rename, e.g. PrintMessage or (*Calculator).Add
+        old: String,
+
+        /// New identifier
+        new: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Report the edits without writing them (required for now)
+        #[arg(long)]
+        dry_run: bool,
+
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
+        output: String,
+    },
+
+    /// Find call cycles: mutually recursive functions and functions calling themselves
+    Cycles {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output format: text, json, sarif
+        #[arg(short, long, alias = "format", default_value = "text")]
+        output: String,
+    },
+
+    /// Show the package import graph, the packages importing one, or import cycles
+    Imports {
+        /// Only this package's imports, by import path
+        package: Option<String>,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Also list packages outside the module, the standard library included
+        #[arg(long)]
+        external: bool,
+
+        /// List the packages importing PACKAGE instead of those it imports
+        #[arg(long, requires = "package")]
+        reverse: bool,
+
+        /// Only report import cycles between the module's packages
+        #[arg(long, conflicts_with_all = ["package", "external"])]
+        cycles: bool,
+
+        /// Output format: text, json, dot
+        #[arg(short, long, default_value = "text")]
+        output: String,
+    },
+
+    /// Report functions and methods that no entry point reaches
+    Deadcode {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Only main, init, tests and --root are entry points; exported symbols of
+        /// library packages are not
+        #[arg(long)]
+        strict: bool,
+
+        /// Extra entry point (repeatable); `entrypoints` adds every entry point the
+        /// `entrypoints` command lists, exported functions included even with --strict
+        #[arg(long = "root", visible_alias = "roots")]
+        roots: Vec<String>,
+
+        /// Only report dead code in this root of a multi-root index, e.g. svc/a; every
+        /// root's entry points still count
+        #[arg(long)]
+        in_root: Option<String>,
+
+        /// Output format: text, json, sarif
+        #[arg(short, long, alias = "format", default_value = "text")]
+        output: String,
+    },
+
+    /// List entry points by package: every func main, every init function and the
+    /// exported functions and methods of library packages
+    Entrypoints {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Only main and init functions; leave out exported functions
+        #[arg(long)]
+        strict: bool,
+
+        /// Only entry points in this root of a multi-root index, e.g. svc/a
+        #[arg(long)]
+        root: Option<String>,
+
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
+        output: String,
+    },
+
+    /// Summarize an index: files, packages, symbols, calls by kind, unresolved calls,
+    /// diagnostics, build time and cache use
+    Stats {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output format: table, json
+        #[arg(short, long, default_value = "table")]
+        output: String,
+    },
+
+    /// Report fan-in, fan-out, reachable functions and length of every function and method
+    Metrics {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Column to sort by, largest first: fan-in, fan-out, reachable, lines, name
+        #[arg(long, default_value = "fan-in")]
+        sort: String,
+
+        /// Only the first N rows
+        #[arg(long)]
+        top: Option<usize>,
+
+        /// Only functions in this root of a multi-root index, e.g. svc/a
+        #[arg(long)]
+        root: Option<String>,
+
+        /// Output format: table, json
+        #[arg(short, long, default_value = "table")]
+        output: String,
+    },
+
+    /// List functions whose cyclomatic complexity exceeds a threshold; exits with status 2
+    /// when there are any, so it can gate CI
+    Complexity {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Highest complexity allowed
+        #[arg(long, default_value_t = 10)]
+        threshold: usize,
+
+        /// Parse this Go file on its own instead of loading a graph; - reads stdin
+        #[arg(long, value_name = "FILE", conflicts_with = "graph")]
+        source: Option<PathBuf>,
+
+        /// Output format: text, json, sarif
+        #[arg(short, long, alias = "format", default_value = "text")]
+        output: String,
+    },
+
+    /// List the types implementing an interface, or the interfaces a type implements
+    Implementations {
+        /// Interface, struct or named type, e.g. Logger or example.com/mymod/calc.Adder
+        r#type: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output format: tree, json
+        #[arg(short, long, default_value = "tree")]
+        output: String,
+    },
+
+    /// Find the methods implementing an interface method, or the interface methods a
+    /// method satisfies
+    MethodImplementations {
+        /// Receiver-qualified method, e.g. Logger.LogOperation or (*Calculator).Add
+        method: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output format: tree, json
+        #[arg(short, long, default_value = "tree")]
+        output: String,
+    },
+
+    /// Show the types a type embeds, as a tree, and the interfaces around it
+    Hierarchy {
+        /// Interface, struct or named type, e.g. Logger or example.com/mymod/calc.Adder
+        r#type: String,
+
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output format: tree, json
+        #[arg(short, long, default_value = "tree")]
+        output: String,
+    },
+
+    /// List the calls and references the resolver bound to no indexed symbol, by reason
+    Unresolved {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Only this reason: external_package, build_excluded, unknown_receiver,
+        /// ambiguous, builtin, undefined
+        #[arg(long)]
+        reason: Option<String>,
+
+        /// Example locations listed per name
+        #[arg(long, default_value = "3")]
+        examples: usize,
+
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
+        output: String,
     },
 
     /// Find call paths between two functions (default: shortest path)
@@ -153,22 +793,27 @@ pub enum Commands {
         graph: PathBuf,
 
         /// Starting function
-        #[arg(long)]
-        from: String,
+        #[arg(long, required_unless_present = "roots")]
+        from: Option<String>,
+
+        /// Start from a root set instead of --from: `entrypoints` finds paths from every
+        /// entry point that reaches the target
+        #[arg(long, value_name = "SET", conflicts_with = "from")]
+        roots: Option<String>,
 
         /// Target function
         #[arg(long)]
         to: String,
 
-        /// Find multiple paths (specify number, e.g., --limit 10)
+        /// Keep only this many of the shortest paths (implies --all)
         #[arg(short, long)]
         limit: Option<usize>,
 
-        /// Find all possible paths (warning: may be slow)
+        /// Find every path that visits no function twice (warning: may be slow)
         #[arg(long)]
         all: bool,
 
-        /// Maximum search depth
+        /// Maximum number of calls in a path
         #[arg(long, default_value = "10")]
         max_depth: usize,
 
@@ -209,7 +854,7 @@ pub enum Commands {
         #[arg(short, long)]
         output: PathBuf,
 
-        /// Format: graphml, dot, csv
+        /// Format: graphml, dot, mermaid, csv, json
         #[arg(short, long)]
         format: String,
 
@@ -220,6 +865,66 @@ pub enum Commands {
         /// Exclude test files
         #[arg(long)]
         exclude_tests: bool,
+
+        /// Group nodes by source file (dot only)
+        #[arg(long)]
+        cluster_by_file: bool,
+
+        /// Omit calls to functions outside the indexed code (dot, mermaid, json)
+        #[arg(long)]
+        no_external: bool,
+
+        /// Group nodes by file or package, merging the calls between groups into one
+        /// counted edge (dot, mermaid, json)
+        #[arg(long)]
+        group_by: Option<String>,
+
+        /// Draw each group as a single node
+        #[arg(long, requires = "group_by")]
+        collapse: bool,
+
+        /// Keep the calls within a collapsed group as a self-edge
+        #[arg(long, requires = "collapse")]
+        keep_internal: bool,
+
+        /// Only export functions reachable from this function
+        #[arg(long)]
+        root: Option<String>,
+
+        /// Traversal depth from --root
+        #[arg(short, long, default_value = "3")]
+        depth: usize,
+    },
+
+    /// Write a Markdown report of the symbols, call graph, entry points and dead code
+    Report {
+        /// Graph file
+        #[arg(short, long, default_value = "codenav.bin")]
+        graph: PathBuf,
+
+        /// Output file; the report goes to standard output when omitted
+        #[arg(long)]
+        out: Option<PathBuf>,
+
+        /// Only this package, by name or import path
+        #[arg(long)]
+        package: Option<String>,
+
+        /// Leave out the symbols tables
+        #[arg(long)]
+        no_symbols: bool,
+
+        /// Leave out the Mermaid call graph
+        #[arg(long)]
+        no_call_graph: bool,
+
+        /// Leave out the entry points
+        #[arg(long)]
+        no_entry_points: bool,
+
+        /// Leave out the dead code findings
+        #[arg(long)]
+        no_dead_code: bool,
     },
 
     /// Extract focused subgraph rooted at a node
@@ -241,32 +946,85 @@ pub enum Commands {
         output: PathBuf,
     },
 
-    /// Compare two graphs to detect changes
+    /// Compare two snapshots of a codebase: symbols and calls added, removed, changed
+    /// or renamed, by file. Exits with status 2 when anything changed.
     Diff {
-        /// Old graph file (baseline)
-        old_graph: PathBuf,
+        /// Old snapshot: a directory to index or a graph file; with --ref, the directory
+        /// to check out at both refs (default: current directory)
+        old: Option<PathBuf>,
+
+        /// New snapshot: a directory to index or a graph file
+        new: Option<PathBuf>,
 
-        /// New graph file (current)
-        new_graph: PathBuf,
+        /// Compare the directory at two git refs, e.g. `--ref main HEAD`, each checked
+        /// out to a temporary worktree
+        #[arg(long = "ref", num_args = 2, value_names = ["OLD", "NEW"])]
+        refs: Option<Vec<String>>,
 
-        /// Show added nodes
+        /// Language of indexed directories: go, typescript, javascript, python
+        #[arg(short, long, default_value = "go")]
+        language: String,
+
+        /// Show added symbols and calls
         #[arg(long)]
         show_added: bool,
 
-        /// Show removed nodes
+        /// Show removed symbols and calls
         #[arg(long)]
         show_removed: bool,
 
-        /// Show changed nodes
+        /// Show changed and renamed symbols
         #[arg(long)]
         show_changed: bool,
 
-        /// Warn if complexity increases by this threshold
+        /// Also report functions whose cyclomatic complexity changed by at least this much
         #[arg(long)]
         complexity_threshold: Option<usize>,
 
-        /// Output format: table, json
-        #[arg(short, long, default_value = "table")]
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
         output: String,
     },
 }
+
+#[derive(Subcommand)]
+pub enum IndexAction {
+    /// Print the format version, tool version, creation time and file count of a graph
+    /// file or per-file cache, without loading it
+    Inspect {
+        /// Graph file, e.g. codenav.bin, or a .code-navigator/index.cache
+        file: PathBuf,
+
+        /// Output format: text, json
+        #[arg(short, long, default_value = "text")]
+        output: String,
+    },
+}
+
+impl Commands {
+    /// Whether the command reads the graph's references, the uses of symbols other
+    /// than calls; the others can skip loading a reference table saved on its own
+    pub fn reads_references(&self) -> bool {
+        !matches!(
+            self,
+            Commands::Query { .. }
+                | Commands::Search { .. }
+                | Commands::Doc { .. }
+                | Commands::Source { .. }
+                | Commands::Blame { .. }
+                | Commands::Outline { .. }
+                | Commands::Trace { .. }
+                | Commands::Callers { .. }
+                | Commands::Callees { .. }
+                | Commands::Cycles { .. }
+                | Commands::Imports { .. }
+                | Commands::Stats { .. }
+                | Commands::Metrics { .. }
+                | Commands::Complexity { .. }
+                | Commands::Implementations { .. }
+                | Commands::MethodImplementations { .. }
+                | Commands::Hierarchy { .. }
+                | Commands::Path { .. }
+        )
+    }
+}
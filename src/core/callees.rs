@@ -0,0 +1,139 @@
+use super::{CodeGraph, EdgeType, Node, PathHop};
+use anyhow::{bail, Result};
+use std::collections::{HashSet, VecDeque};
+use std::path::Path;
+use std::str::FromStr;
+
+/// What [`CodeGraph::callees`] leaves out of the walk
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum Prune {
+    /// Calls into code outside the index, such as `fmt.Println`
+    External,
+    /// Functions in other packages are listed, but their calls are not followed
+    Package,
+}
+
+impl FromStr for Prune {
+    type Err = anyhow::Error;
+
+    fn from_str(s: &str) -> Result<Self> {
+        match s {
+            "external" => Ok(Prune::External),
+            "package" => Ok(Prune::Package),
+            _ => bail!("Unknown prune mode: {} (expected external or package)", s),
+        }
+    }
+}
+
+/// Limits for [`CodeGraph::callees`]
+#[derive(Debug, Clone)]
+pub struct CalleeOptions {
+    /// Most calls between the root and a callee; 1 lists only direct calls
+    pub max_depth: usize,
+    pub prune: Vec<Prune>,
+}
+
+impl Default for CalleeOptions {
+    fn default() -> Self {
+        Self {
+            max_depth: 1,
+            prune: Vec::new(),
+        }
+    }
+}
+
+/// A call found on the walk: `caller` makes `hop.call`, `depth` calls from the root
+#[derive(Debug, Clone, Copy)]
+pub struct CalleeCall<'a> {
+    pub caller: &'a Node,
+    pub hop: PathHop<'a>,
+    pub depth: usize,
+}
+
+/// Everything a function may end up calling, as found by [`CodeGraph::callees`]
+#[derive(Debug, Clone)]
+pub struct CalleeClosure<'a> {
+    pub root: &'a Node,
+    /// Each callee once, through the first call reaching it at its least depth; nearest
+    /// first, in the order the walk met them
+    pub reached: Vec<CalleeCall<'a>>,
+    /// Every call between the root and the functions reached, so the subgraph can be
+    /// drawn; calls back into functions already reached are included
+    pub calls: Vec<CalleeCall<'a>>,
+}
+
+impl CodeGraph {
+    /// The functions `symbol` calls, and with a `max_depth` above 1 the functions those
+    /// call, walked breadth-first so each is reported at the least depth it is reached.
+    /// Each function is expanded once, so cycles terminate; the root itself is never
+    /// reported. Calls outside the index are reported by the name written, e.g.
+    /// `fmt.Println`, unless pruned.
+    pub fn callees(&self, symbol: &str, options: &CalleeOptions) -> Result<CalleeClosure<'_>> {
+        let root = self.resolve_symbol(symbol)?;
+        let prune_external = options.prune.contains(&Prune::External);
+        let root_package = root.file_path.parent().unwrap_or(Path::new(""));
+        let stays = |node: &Node| {
+            !options.prune.contains(&Prune::Package)
+                || node.file_path.parent().unwrap_or(Path::new("")) == root_package
+        };
+
+        let mut reached = Vec::new();
+        let mut calls = Vec::new();
+        let mut seen_nodes: HashSet<&str> = HashSet::from([root.id.as_str()]);
+        let mut seen_external: HashSet<String> = HashSet::new();
+        let mut queue = VecDeque::from([(root, 0)]);
+        while let Some((caller, depth)) = queue.pop_front() {
+            if depth >= options.max_depth {
+                continue;
+            }
+            for edge in self.get_outgoing_edges(&caller.id) {
+                // A call through a parameter goes nowhere itself; its indirect edges
+                // carry the candidates
+                if edge.edge_type != EdgeType::Calls || edge.calls_through_value() {
+                    continue;
+                }
+                let targets = self.edge_targets(edge);
+                if targets.is_empty() {
+                    if prune_external {
+                        continue;
+                    }
+                    let call = CalleeCall {
+                        caller,
+                        hop: PathHop {
+                            call: edge,
+                            callee: None,
+                        },
+                        depth: depth + 1,
+                    };
+                    if seen_external.insert(call.hop.callee_name()) {
+                        reached.push(call);
+                    }
+                    calls.push(call);
+                    continue;
+                }
+                for callee in targets {
+                    let call = CalleeCall {
+                        caller,
+                        hop: PathHop {
+                            call: edge,
+                            callee: Some(callee),
+                        },
+                        depth: depth + 1,
+                    };
+                    calls.push(call);
+                    if seen_nodes.insert(callee.id.as_str()) {
+                        reached.push(call);
+                        if stays(callee) {
+                            queue.push_back((callee, depth + 1));
+                        }
+                    }
+                }
+            }
+        }
+        Ok(CalleeClosure {
+            root,
+            reached,
+            calls,
+        })
+    }
+}
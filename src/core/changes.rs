@@ -0,0 +1,345 @@
+use super::{CodeGraph, Edge, EdgeType, Node, NodeType};
+use std::collections::hash_map::DefaultHasher;
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::fs;
+use std::hash::{Hash, Hasher};
+use std::path::{Path, PathBuf};
+
+/// A symbol as it was in the old snapshot and as it is in the new one
+#[derive(Debug, Clone)]
+pub struct SymbolChange<'a> {
+    pub old: &'a Node,
+    pub new: &'a Node,
+}
+
+/// A call made in one snapshot but not the other
+#[derive(Debug, Clone)]
+pub struct CallChange<'a> {
+    pub caller: &'a Node,
+    /// Name of the callee as resolved, e.g. `(*Calculator).Add`, or as written for
+    /// calls into unindexed code, e.g. `fmt.Println`
+    pub callee: String,
+    pub edge: &'a Edge,
+}
+
+/// What changed in one file, named relative to the indexed directory
+#[derive(Debug, Clone, Default)]
+pub struct FileChanges<'a> {
+    pub file: String,
+    pub added: Vec<&'a Node>,
+    pub removed: Vec<&'a Node>,
+    /// Symbols kept under the same name whose declaration changed
+    pub changed: Vec<SymbolChange<'a>>,
+    /// Symbols found under a new name with an unchanged body, listed in the new file
+    pub renamed: Vec<SymbolChange<'a>>,
+    pub added_calls: Vec<CallChange<'a>>,
+    pub removed_calls: Vec<CallChange<'a>>,
+    /// Functions whose cyclomatic complexity moved by at least the requested threshold
+    pub complexity: Vec<SymbolChange<'a>>,
+}
+
+impl FileChanges<'_> {
+    pub fn is_empty(&self) -> bool {
+        self.added.is_empty()
+            && self.removed.is_empty()
+            && self.changed.is_empty()
+            && self.renamed.is_empty()
+            && self.added_calls.is_empty()
+            && self.removed_calls.is_empty()
+            && self.complexity.is_empty()
+    }
+}
+
+/// Differences between two snapshots of a codebase, as found by
+/// [`CodeGraph::changes`]
+#[derive(Debug, Clone, Default)]
+pub struct GraphChanges<'a> {
+    /// Files with at least one change, in path order
+    pub files: Vec<FileChanges<'a>>,
+}
+
+impl GraphChanges<'_> {
+    pub fn is_empty(&self) -> bool {
+        self.files.is_empty()
+    }
+}
+
+/// A symbol's file, relative to its graph's root, and its name
+type Key = (String, String);
+
+impl CodeGraph {
+    /// Compare this graph, the old snapshot, with `new`. Symbols are matched by their
+    /// stable ID, then by file and name, so a symbol that merely moved is unchanged and
+    /// one whose signature changed is reported as changed. A removed symbol whose body
+    /// reappears under another name is reported as a rename when no other symbol shares
+    /// that body; source files are read to compare bodies, so both snapshots must still
+    /// be on disk. Complexity changes are only reported with a `complexity_threshold`.
+    pub fn changes<'a>(
+        &'a self,
+        new: &'a CodeGraph,
+        complexity_threshold: Option<usize>,
+    ) -> GraphChanges<'a> {
+        let mut files: BTreeMap<String, FileChanges<'a>> = BTreeMap::new();
+
+        // A symbol keeps its ID while its signature does, wherever it moved in the
+        // package; the rest are paired by file and name
+        let by_id: HashMap<&str, &'a Node> = new
+            .nodes
+            .iter()
+            .map(|node| (node.id.as_str(), node))
+            .collect();
+        let mut matched: Vec<(Key, SymbolChange<'a>)> = Vec::new();
+        let mut kept_new = HashSet::new();
+        for old in self.nodes.iter().filter(|n| n.node_type != NodeType::Field) {
+            if let Some(&new_node) = by_id.get(old.id.as_str()) {
+                kept_new.insert(new_node.id.as_str());
+                matched.push((key(new, new_node), SymbolChange { old, new: new_node }));
+            }
+        }
+        let kept_old: HashSet<&str> = matched.iter().map(|(_, c)| c.old.id.as_str()).collect();
+        let old_symbols = symbols(self, &kept_old);
+        let new_symbols = symbols(new, &kept_new);
+        let mut removed: Vec<(&Key, &'a Node)> = Vec::new();
+        let mut added: Vec<(&Key, &'a Node)> = Vec::new();
+        for (key, old_nodes) in &old_symbols {
+            let new_nodes = new_symbols.get(key).map(Vec::as_slice).unwrap_or_default();
+            for (i, &old) in old_nodes.iter().enumerate() {
+                match new_nodes.get(i) {
+                    Some(&new) => matched.push((key.clone(), SymbolChange { old, new })),
+                    None => removed.push((key, old)),
+                }
+            }
+        }
+        for (key, new_nodes) in &new_symbols {
+            let kept = old_symbols.get(key).map_or(0, Vec::len);
+            added.extend(new_nodes.iter().skip(kept).map(|&node| (key, node)));
+        }
+        // Calls are matched by ID, so old IDs are translated to what they became
+        let mut renames: HashMap<&str, &str> = matched
+            .iter()
+            .map(|(_, change)| (change.old.id.as_str(), change.new.id.as_str()))
+            .collect();
+
+        // Renames: a body seen exactly once among removed and once among added symbols
+        let mut sources = Sources::default();
+        let old_bodies: Vec<_> = removed.iter().map(|(_, node)| sources.body(node)).collect();
+        let new_bodies: Vec<_> = added.iter().map(|(_, node)| sources.body(node)).collect();
+        let unique = |bodies: &[Option<Body>]| -> HashMap<Body, usize> {
+            let mut seen: HashMap<Body, Option<usize>> = HashMap::new();
+            for (i, body) in bodies.iter().enumerate() {
+                if let Some(body) = body {
+                    seen.entry(body.clone())
+                        .and_modify(|only| *only = None)
+                        .or_insert(Some(i));
+                }
+            }
+            seen.into_iter()
+                .filter_map(|(body, only)| Some((body, only?)))
+                .collect()
+        };
+        let new_unique = unique(&new_bodies);
+        let mut renamed: Vec<SymbolChange<'a>> = Vec::new();
+        let mut renamed_old = HashSet::new();
+        let mut renamed_new = HashSet::new();
+        for (body, i) in unique(&old_bodies) {
+            if let Some(&j) = new_unique.get(&body) {
+                let ((_, old), (new_key, new)) = (removed[i], added[j]);
+                renames.insert(old.id.as_str(), new.id.as_str());
+                renamed_old.insert(i);
+                renamed_new.insert(j);
+                let change = SymbolChange { old, new };
+                entry(&mut files, &new_key.0).renamed.push(change.clone());
+                renamed.push(change);
+            }
+        }
+
+        for (i, (key, node)) in removed.iter().enumerate() {
+            if !renamed_old.contains(&i) {
+                entry(&mut files, &key.0).removed.push(node);
+            }
+        }
+        for (i, (key, node)) in added.iter().enumerate() {
+            if !renamed_new.contains(&i) {
+                entry(&mut files, &key.0).added.push(node);
+            }
+        }
+        // Reformatting alone is no change
+        let declaration = |node: &Node| node.declaration().split_whitespace().collect::<String>();
+        for (key, change) in &matched {
+            if declaration(change.old) != declaration(change.new) {
+                entry(&mut files, &key.0).changed.push(change.clone());
+            }
+        }
+        if let Some(threshold) = complexity_threshold {
+            for change in matched.into_iter().map(|(_, change)| change).chain(renamed) {
+                if let (Some(before), Some(after)) =
+                    (change.old.complexity(), change.new.complexity())
+                {
+                    if before.abs_diff(after) >= threshold.max(1) {
+                        let name = relative(new, &change.new.file_path);
+                        entry(&mut files, &name).complexity.push(change);
+                    }
+                }
+            }
+        }
+
+        // Calls, with old IDs translated so a renamed symbol keeps its calls
+        let old_calls = calls(self, &renames);
+        let new_calls = calls(new, &HashMap::new());
+        for (key, change) in &old_calls {
+            if !new_calls.contains_key(key) {
+                entry(&mut files, &relative(self, &change.caller.file_path))
+                    .removed_calls
+                    .push(change.clone());
+            }
+        }
+        for (key, change) in &new_calls {
+            if !old_calls.contains_key(key) {
+                entry(&mut files, &relative(new, &change.caller.file_path))
+                    .added_calls
+                    .push(change.clone());
+            }
+        }
+
+        let mut files: Vec<FileChanges<'a>> = files
+            .into_values()
+            .filter(|changes| !changes.is_empty())
+            .collect();
+        for changes in &mut files {
+            changes.added.sort_by_key(|node| node.line);
+            changes.removed.sort_by_key(|node| node.line);
+            changes.changed.sort_by_key(|change| change.new.line);
+            changes.renamed.sort_by_key(|change| change.new.line);
+            changes.complexity.sort_by_key(|change| change.new.line);
+            for calls in [&mut changes.added_calls, &mut changes.removed_calls] {
+                calls.sort_by(|a, b| {
+                    (a.caller.line, a.edge.line, &a.callee).cmp(&(
+                        b.caller.line,
+                        b.edge.line,
+                        &b.callee,
+                    ))
+                });
+            }
+        }
+        GraphChanges { files }
+    }
+}
+
+fn entry<'m, 'a>(
+    files: &'m mut BTreeMap<String, FileChanges<'a>>,
+    file: &str,
+) -> &'m mut FileChanges<'a> {
+    files
+        .entry(file.to_string())
+        .or_insert_with(|| FileChanges {
+            file: file.to_string(),
+            ..Default::default()
+        })
+}
+
+/// Every symbol by file and name but those already `matched`, in source order within a
+/// key; struct fields are covered by their struct's declaration
+fn symbols<'a>(graph: &'a CodeGraph, matched: &HashSet<&str>) -> BTreeMap<Key, Vec<&'a Node>> {
+    let mut symbols: BTreeMap<Key, Vec<&Node>> = BTreeMap::new();
+    for node in graph
+        .nodes
+        .iter()
+        .filter(|n| n.node_type != NodeType::Field && !matched.contains(n.id.as_str()))
+    {
+        symbols.entry(key(graph, node)).or_default().push(node);
+    }
+    for nodes in symbols.values_mut() {
+        nodes.sort_by_key(|node| node.line);
+    }
+    symbols
+}
+
+/// Each distinct call by caller and callee, with the first edge making it. Calls the
+/// index resolved are keyed by the callee's ID, the rest by the name written.
+fn calls<'a>(
+    graph: &'a CodeGraph,
+    renames: &HashMap<&str, &str>,
+) -> BTreeMap<(String, String), CallChange<'a>> {
+    let renamed = |id: &str| renames.get(id).copied().unwrap_or(id).to_string();
+    let mut calls = BTreeMap::new();
+    for edge in graph
+        .edges
+        .iter()
+        .filter(|edge| edge.edge_type == EdgeType::Calls)
+    {
+        let Some(caller) = graph.get_node_by_id(&edge.from) else {
+            continue;
+        };
+        let caller_key = renamed(&caller.id);
+        let targets = graph.edge_targets(edge);
+        let callees: Vec<(String, String)> = if targets.is_empty() {
+            vec![(edge.to.clone(), edge.to.clone())]
+        } else {
+            targets
+                .into_iter()
+                .map(|target| (renamed(&target.id), target.name.clone()))
+                .collect()
+        };
+        for (callee_key, callee) in callees {
+            calls
+                .entry((caller_key.clone(), callee_key))
+                .or_insert_with(|| CallChange {
+                    caller,
+                    callee,
+                    edge,
+                });
+        }
+    }
+    calls
+}
+
+fn key(graph: &CodeGraph, node: &Node) -> Key {
+    (relative(graph, &node.file_path), node.name.clone())
+}
+
+fn relative(graph: &CodeGraph, path: &Path) -> String {
+    path.strip_prefix(&graph.metadata.root_path)
+        .unwrap_or(path)
+        .display()
+        .to_string()
+}
+
+/// Functions and types are renamed with their body intact; constants, variables and
+/// fields have too little after their name to tell apart
+fn can_be_renamed(node: &Node) -> bool {
+    !matches!(
+        node.node_type,
+        NodeType::Const | NodeType::Var | NodeType::Field
+    )
+}
+
+/// A declaration's kind and the hash of its body
+type Body = (NodeType, u64);
+
+#[derive(Default)]
+struct Sources {
+    files: HashMap<PathBuf, Option<String>>,
+}
+
+impl Sources {
+    /// The declaration's text after its name hashed with whitespace collapsed, so the
+    /// same body hashes alike under any name or indentation; `None` for symbols that
+    /// can't be renamed, when the source can't be read or the index has no spans
+    fn body(&mut self, node: &Node) -> Option<Body> {
+        if !can_be_renamed(node) {
+            return None;
+        }
+        let (name, span) = (node.name_span?, node.span?);
+        let source = self
+            .files
+            .entry(node.file_path.clone())
+            .or_insert_with(|| fs::read_to_string(&node.file_path).ok())
+            .as_ref()?;
+        let body = source.get(name.end.offset..span.end.offset)?;
+        let mut hasher = DefaultHasher::new();
+        for word in body.split_whitespace() {
+            word.hash(&mut hasher);
+        }
+        Some((node.node_type.clone(), hasher.finish()))
+    }
+}
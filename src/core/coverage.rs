@@ -0,0 +1,207 @@
+use super::{CallPath, CodeGraph, Edge, Node, NodeType, PathHop};
+use anyhow::Result;
+use std::collections::{HashMap, VecDeque};
+use std::path::Path;
+
+/// A test function that reaches a symbol through calls
+#[derive(Debug, Clone)]
+pub struct TestCoverage<'a> {
+    /// The test, or the benchmark or fuzz target for
+    /// [`benchmarks_reaching`](CodeGraph::benchmarks_reaching)
+    pub test: &'a Node,
+    /// The function literal in the test the path leaves from, e.g. the `b.Run` or
+    /// `f.Fuzz` callback `BenchmarkParse.func1`
+    pub closure: Option<&'a Node>,
+    /// The shortest chain of calls from the test to the symbol; one hop for a direct call
+    pub path: CallPath<'a>,
+}
+
+impl TestCoverage<'_> {
+    pub fn is_direct(&self) -> bool {
+        self.path.hops.len() == 1
+    }
+
+    /// Names along the path, test first and then the closure the calls leave from, if any
+    pub fn names(&self) -> Vec<String> {
+        let mut names = self.path.names();
+        if let Some(closure) = self.closure {
+            names.insert(1, closure.name.clone());
+        }
+        names
+    }
+}
+
+/// How a caller reaches the target
+#[derive(Clone, Copy)]
+enum Reach<'a> {
+    /// Through a call, the first hop toward the target
+    Call(PathHop<'a>),
+    /// Through calls made by one of its function literals
+    Closure(&'a Node),
+}
+
+impl CodeGraph {
+    /// Tests that call `symbol` directly or through other functions, within `max_depth`
+    /// calls, nearest first and then in source order. Tests are the `Test`, `Benchmark`,
+    /// `Fuzz` and `Example` functions of `_test.go` files, which are only in the index
+    /// when it was built with tests included. Calls made by a function literal count as
+    /// calls of the function around it, so a subtest passed to `t.Run` reaches for its
+    /// test.
+    pub fn tests_reaching(&self, symbol: &str, max_depth: usize) -> Result<Vec<TestCoverage<'_>>> {
+        self.reached_by(symbol, max_depth, is_test_function)
+    }
+
+    /// Benchmarks and fuzz targets reaching `symbol`, as [`tests_reaching`](Self::tests_reaching)
+    /// finds tests; the callees of a `b.Run` or `f.Fuzz` callback are the benchmark's
+    pub fn benchmarks_reaching(
+        &self,
+        symbol: &str,
+        max_depth: usize,
+    ) -> Result<Vec<TestCoverage<'_>>> {
+        self.reached_by(symbol, max_depth, |node| {
+            matches!(node.node_type, NodeType::Benchmark | NodeType::Fuzz) && is_test_function(node)
+        })
+    }
+
+    fn reached_by(
+        &self,
+        symbol: &str,
+        max_depth: usize,
+        is_test: impl Fn(&Node) -> bool,
+    ) -> Result<Vec<TestCoverage<'_>>> {
+        let target = self.resolve_symbol(symbol)?;
+
+        let mut callers: HashMap<&str, Vec<&Edge>> = HashMap::new();
+        for edge in &self.edges {
+            for callee in self.edge_targets(edge) {
+                callers.entry(callee.id.as_str()).or_default().push(edge);
+            }
+        }
+        let mut functions: HashMap<&Path, Vec<&Node>> = HashMap::new();
+        for node in &self.nodes {
+            if is_function(node) && !node.metadata.contains_key("enclosing") {
+                functions
+                    .entry(node.file_path.as_path())
+                    .or_default()
+                    .push(node);
+            }
+        }
+        // The declared function a literal is written in, however deeply nested
+        let enclosing = |closure: &Node| -> Option<&Node> {
+            closure.metadata.get("enclosing")?;
+            functions
+                .get(closure.file_path.as_path())?
+                .iter()
+                .copied()
+                .find(|function| (function.line..=function.end_line).contains(&closure.line))
+        };
+
+        // Walk callers breadth-first; each one remembers how it reaches the target
+        let mut toward: HashMap<&str, Reach> = HashMap::new();
+        let mut queue = VecDeque::from([(target, 0)]);
+        while let Some((node, depth)) = queue.pop_front() {
+            if depth >= max_depth {
+                continue;
+            }
+            for &call in callers.get(node.id.as_str()).into_iter().flatten() {
+                let Some(caller) = self.get_node_by_id(&call.from) else {
+                    continue;
+                };
+                if caller.id == target.id || toward.contains_key(caller.id.as_str()) {
+                    continue;
+                }
+                toward.insert(
+                    caller.id.as_str(),
+                    Reach::Call(PathHop {
+                        call,
+                        callee: Some(node),
+                    }),
+                );
+                queue.push_back((caller, depth + 1));
+
+                // The function around a literal reaches as far, with no call between them
+                if let Some(function) = enclosing(caller) {
+                    if function.id != target.id && !toward.contains_key(function.id.as_str()) {
+                        toward.insert(function.id.as_str(), Reach::Closure(caller));
+                        queue.push_back((function, depth + 1));
+                    }
+                }
+            }
+        }
+
+        let mut tests: Vec<TestCoverage> = self
+            .nodes
+            .iter()
+            .filter(|node| is_test(node))
+            .filter_map(|test| {
+                let mut closure = None;
+                let mut hops = Vec::new();
+                let mut current = test;
+                while let Some(&reach) = toward.get(current.id.as_str()) {
+                    match reach {
+                        Reach::Call(hop) => {
+                            hops.push(hop);
+                            current = hop.callee?;
+                        }
+                        Reach::Closure(literal) => {
+                            if hops.is_empty() {
+                                closure = Some(literal);
+                            }
+                            current = literal;
+                        }
+                    }
+                }
+                (!hops.is_empty()).then(|| TestCoverage {
+                    test,
+                    closure,
+                    path: CallPath { start: test, hops },
+                })
+            })
+            .collect();
+        tests.sort_by(|a, b| {
+            (a.path.hops.len(), &a.test.file_path, a.test.line).cmp(&(
+                b.path.hops.len(),
+                &b.test.file_path,
+                b.test.line,
+            ))
+        });
+        Ok(tests)
+    }
+
+    /// Whether any `_test.go` file was indexed
+    pub fn has_tests(&self) -> bool {
+        self.nodes
+            .iter()
+            .any(|node| node.metadata.contains_key("test"))
+    }
+}
+
+/// A function `go test` runs: `TestX`, `BenchmarkX`, `FuzzX` or `ExampleX` in a test
+/// file, where X doesn't start with a lowercase letter (`Testing` is a helper)
+fn is_test_function(node: &Node) -> bool {
+    matches!(
+        node.node_type,
+        NodeType::Function | NodeType::Benchmark | NodeType::Fuzz
+    ) && node.metadata.contains_key("test")
+        && !node.metadata.contains_key("enclosing")
+        && ["Test", "Benchmark", "Fuzz", "Example"]
+            .iter()
+            .any(|prefix| {
+                node.name
+                    .strip_prefix(prefix)
+                    .is_some_and(|rest| !rest.starts_with(char::is_lowercase))
+            })
+}
+
+/// Declarations with a body a function literal can be written in
+fn is_function(node: &Node) -> bool {
+    matches!(
+        node.node_type,
+        NodeType::Function
+            | NodeType::Method
+            | NodeType::HttpHandler
+            | NodeType::Middleware
+            | NodeType::Benchmark
+            | NodeType::Fuzz
+    )
+}
@@ -0,0 +1,194 @@
+use super::{CodeGraph, Edge, Node};
+use std::collections::{HashMap, HashSet};
+
+/// Functions that call each other in a loop: a strongly connected component of the
+/// call graph with more than one member, or a single function calling itself
+#[derive(Debug, Clone)]
+pub struct Cycle<'a> {
+    /// Members in the order a depth-first walk over their calls reaches them,
+    /// starting from the first member in source order
+    pub nodes: Vec<&'a Node>,
+    /// Every call from one member to another, by caller in `nodes` order, then position
+    pub calls: Vec<&'a Edge>,
+    /// A single function calling itself
+    pub self_recursive: bool,
+}
+
+impl CodeGraph {
+    /// Call cycles found with Tarjan's algorithm, sorted by the position of their first member
+    pub fn cycles(&self) -> Vec<Cycle<'_>> {
+        let positions: HashMap<&str, usize> = self
+            .nodes
+            .iter()
+            .enumerate()
+            .map(|(idx, node)| (node.id.as_str(), idx))
+            .collect();
+
+        // Calls out of each node, by the index of the node they resolve to
+        let calls: Vec<Vec<(usize, &Edge)>> = self
+            .nodes
+            .iter()
+            .map(|node| {
+                let mut edges = self.get_outgoing_edges(&node.id);
+                edges.sort_by_key(|edge| (edge.line, edge.column));
+                edges
+                    .into_iter()
+                    .flat_map(|edge| {
+                        self.edge_targets(edge)
+                            .into_iter()
+                            .filter_map(|target| positions.get(target.id.as_str()))
+                            .map(move |&target| (target, edge))
+                    })
+                    .collect()
+            })
+            .collect();
+        let successors: Vec<Vec<usize>> = calls
+            .iter()
+            .map(|edges| edges.iter().map(|&(target, _)| target).collect())
+            .collect();
+
+        let mut cycles: Vec<Cycle> = strongly_connected(&successors)
+            .into_iter()
+            .filter(|component| {
+                component.len() > 1 || successors[component[0]].contains(&component[0])
+            })
+            .map(|component| {
+                let members: HashSet<usize> = component.iter().copied().collect();
+                let start = component
+                    .iter()
+                    .copied()
+                    .min_by(|&a, &b| {
+                        let (a, b) = (&self.nodes[a], &self.nodes[b]);
+                        (&a.file_path, a.line).cmp(&(&b.file_path, b.line))
+                    })
+                    .unwrap_or(component[0]);
+
+                // Walk the component's own calls depth-first, in call order
+                let mut order = Vec::new();
+                let mut seen = HashSet::new();
+                let mut pending = vec![start];
+                while let Some(idx) = pending.pop() {
+                    if !seen.insert(idx) {
+                        continue;
+                    }
+                    order.push(idx);
+                    pending.extend(
+                        successors[idx]
+                            .iter()
+                            .rev()
+                            .filter(|target| members.contains(target) && !seen.contains(target)),
+                    );
+                }
+
+                let mut cycle_calls: Vec<&Edge> = Vec::new();
+                for &idx in &order {
+                    for &(target, edge) in &calls[idx] {
+                        if members.contains(&target)
+                            && !cycle_calls.iter().any(|e| std::ptr::eq(*e, edge))
+                        {
+                            cycle_calls.push(edge);
+                        }
+                    }
+                }
+
+                Cycle {
+                    self_recursive: component.len() == 1,
+                    nodes: order.iter().map(|&idx| &self.nodes[idx]).collect(),
+                    calls: cycle_calls,
+                }
+            })
+            .collect();
+
+        cycles.sort_by(|a, b| {
+            let (a, b) = (a.nodes[0], b.nodes[0]);
+            (&a.file_path, a.line).cmp(&(&b.file_path, b.line))
+        });
+        cycles
+    }
+}
+
+/// Tarjan's strongly connected components, iteratively so deep call chains can't
+/// overflow the stack. Every node ends up in exactly one component.
+pub(super) fn strongly_connected(successors: &[Vec<usize>]) -> Vec<Vec<usize>> {
+    const UNVISITED: usize = usize::MAX;
+
+    let count = successors.len();
+    let mut index = vec![UNVISITED; count];
+    let mut low = vec![0; count];
+    let mut on_stack = vec![false; count];
+    let mut stack = Vec::new();
+    let mut components = Vec::new();
+    let mut next_index = 0;
+
+    for root in 0..count {
+        if index[root] != UNVISITED {
+            continue;
+        }
+        index[root] = next_index;
+        low[root] = next_index;
+        next_index += 1;
+        stack.push(root);
+        on_stack[root] = true;
+
+        // Each frame is a node and the position of the next successor to visit
+        let mut frames = vec![(root, 0)];
+        while let Some((node, position)) = frames.last().copied() {
+            if let Some(&successor) = successors[node].get(position) {
+                if let Some(frame) = frames.last_mut() {
+                    frame.1 += 1;
+                }
+                if index[successor] == UNVISITED {
+                    index[successor] = next_index;
+                    low[successor] = next_index;
+                    next_index += 1;
+                    stack.push(successor);
+                    on_stack[successor] = true;
+                    frames.push((successor, 0));
+                } else if on_stack[successor] {
+                    low[node] = low[node].min(index[successor]);
+                }
+                continue;
+            }
+
+            frames.pop();
+            if let Some(&(parent, _)) = frames.last() {
+                low[parent] = low[parent].min(low[node]);
+            }
+            if low[node] == index[node] {
+                let mut component = Vec::new();
+                while let Some(member) = stack.pop() {
+                    on_stack[member] = false;
+                    component.push(member);
+                    if member == node {
+                        break;
+                    }
+                }
+                components.push(component);
+            }
+        }
+    }
+    components
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn sorted(mut components: Vec<Vec<usize>>) -> Vec<Vec<usize>> {
+        for component in &mut components {
+            component.sort();
+        }
+        components.sort();
+        components
+    }
+
+    #[test]
+    fn test_strongly_connected_components() {
+        // 0 -> 1 -> 2 -> 0, 2 -> 3, 3 -> 3, 4 alone
+        let successors = vec![vec![1], vec![2], vec![0, 3], vec![3], vec![]];
+        assert_eq!(
+            sorted(strongly_connected(&successors)),
+            vec![vec![0, 1, 2], vec![3], vec![4]]
+        );
+    }
+}
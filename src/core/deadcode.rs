@@ -0,0 +1,134 @@
+use super::entrypoints::{EntryKind, ENTRY_POINTS};
+use super::{CodeGraph, Edge, Node, NodeType};
+use std::collections::{HashMap, HashSet, VecDeque};
+
+/// Settings for [`CodeGraph::dead_code`]
+#[derive(Debug, Clone, Default)]
+pub struct DeadCodeOptions {
+    /// Exported functions and methods of library packages are not entry points;
+    /// only main, init and `roots` are
+    pub strict: bool,
+    /// Extra entry points, as symbol names; [`ENTRY_POINTS`] adds every entry point,
+    /// exported functions included even when `strict`
+    pub roots: Vec<String>,
+}
+
+#[derive(Debug, Clone)]
+pub struct DeadCodeReport<'a> {
+    /// Entry points reachability starts from, in source order
+    pub roots: Vec<&'a Node>,
+    /// Functions and methods no entry point reaches, in source order
+    pub dead: Vec<&'a Node>,
+}
+
+impl CodeGraph {
+    /// Functions and methods that can't be reached from an entry point. Reachability
+    /// follows calls and references such as `run(handler)`. Calling an interface method
+    /// reaches the method of every indexed type implementing the interface, and a call
+    /// on a value of unknown type reaches every method of that name.
+    pub fn dead_code(&self, options: &DeadCodeOptions) -> anyhow::Result<DeadCodeReport<'_>> {
+        let mut roots: Vec<&Node> = self
+            .nodes
+            .iter()
+            .filter(|node| EntryKind::of(node, options.strict).is_some())
+            .collect();
+        for symbol in &options.roots {
+            let nodes = match symbol.as_str() {
+                ENTRY_POINTS => self
+                    .entry_points(false)
+                    .into_iter()
+                    .flat_map(|package| package.entry_points)
+                    .map(|entry| entry.node)
+                    .collect(),
+                _ => vec![self.resolve_symbol(symbol)?],
+            };
+            for node in nodes {
+                if !roots.iter().any(|root| root.id == node.id) {
+                    roots.push(node);
+                }
+            }
+        }
+        roots.sort_by(|a, b| (&a.file_path, a.line).cmp(&(&b.file_path, b.line)));
+
+        let mut references: HashMap<&str, Vec<&Edge>> = HashMap::new();
+        for reference in &self.references {
+            references
+                .entry(reference.from.as_str())
+                .or_default()
+                .push(reference);
+        }
+
+        let mut live: HashSet<&str> = HashSet::new();
+        let mut pending: VecDeque<&Node> = roots.iter().copied().collect();
+        while let Some(node) = pending.pop_front() {
+            if !live.insert(node.id.as_str()) {
+                continue;
+            }
+
+            for edge in self.get_outgoing_edges(&node.id) {
+                let targets = self.edge_targets(edge);
+                if targets.is_empty() && is_untyped_method_call(edge) {
+                    pending.extend(self.methods_named(&edge.to));
+                }
+                pending.extend(targets);
+            }
+            for reference in references.get(node.id.as_str()).into_iter().flatten() {
+                pending.extend(
+                    reference
+                        .metadata
+                        .get("target_id")
+                        .and_then(|id| self.get_node_by_id(id)),
+                );
+            }
+            if node.metadata.contains_key("abstract") {
+                pending.extend(self.implementing_methods(node));
+            }
+        }
+
+        let mut dead: Vec<&Node> = self
+            .nodes
+            .iter()
+            .filter(|node| matches!(node.node_type, NodeType::Function | NodeType::Method))
+            .filter(|node| !node.metadata.contains_key("abstract"))
+            .filter(|node| !node.metadata.contains_key("test"))
+            .filter(|node| !live.contains(node.id.as_str()))
+            .collect();
+        dead.sort_by(|a, b| (&a.file_path, a.line).cmp(&(&b.file_path, b.line)));
+
+        Ok(DeadCodeReport { roots, dead })
+    }
+
+    /// Concrete methods a call to the interface method `abstract_method` may dispatch to
+    fn implementing_methods<'a>(&'a self, abstract_method: &'a Node) -> Vec<&'a Node> {
+        let Some(interface_name) = abstract_method.metadata.get("receiver_type") else {
+            return Vec::new();
+        };
+        let package_dir = abstract_method.file_path.parent();
+        let Some(interface) = self.nodes.iter().find(|node| {
+            node.node_type == NodeType::Interface
+                && &node.name == interface_name
+                && node.file_path.parent() == package_dir
+        }) else {
+            return Vec::new();
+        };
+
+        self.implementations(interface)
+            .into_iter()
+            .flat_map(|implementation| implementation.methods)
+            .filter(|satisfied| satisfied.requirement.id == abstract_method.id)
+            .map(|satisfied| satisfied.method)
+            .collect()
+    }
+
+    fn methods_named(&self, method: &str) -> Vec<&Node> {
+        self.get_nodes_by_type(&NodeType::Method)
+            .into_iter()
+            .filter(|node| node.metadata.get("method").is_some_and(|m| m == method))
+            .collect()
+    }
+}
+
+/// A method call on a value whose type the parser couldn't determine
+fn is_untyped_method_call(edge: &Edge) -> bool {
+    edge.metadata.contains_key("qualifier") && !edge.metadata.contains_key("import_path")
+}
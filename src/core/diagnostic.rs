@@ -0,0 +1,89 @@
+use serde::{Deserialize, Serialize};
+use std::fmt;
+use std::path::PathBuf;
+
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, Hash)]
+#[serde(rename_all = "snake_case")]
+pub enum Severity {
+    Error,
+    Warning,
+}
+
+/// A problem found while indexing, reported alongside the graph instead of aborting
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+pub struct Diagnostic {
+    pub severity: Severity,
+    pub code: String,
+    pub message: String,
+    pub file_path: PathBuf,
+    pub line: usize,
+    /// 1-based byte column; 0 when the problem is with a whole declaration or file
+    #[serde(default)]
+    pub column: usize,
+}
+
+impl Diagnostic {
+    pub fn new(
+        severity: Severity,
+        code: &str,
+        message: String,
+        file_path: PathBuf,
+        line: usize,
+    ) -> Self {
+        Self {
+            severity,
+            code: code.to_string(),
+            message,
+            file_path,
+            line,
+            column: 0,
+        }
+    }
+
+    /// The same diagnostic pinned to a column of its line
+    pub fn at_column(mut self, column: usize) -> Self {
+        self.column = column;
+        self
+    }
+
+    pub fn warning(code: &str, message: String, file_path: PathBuf, line: usize) -> Self {
+        Self::new(Severity::Warning, code, message, file_path, line)
+    }
+
+    pub fn error(code: &str, message: String, file_path: PathBuf, line: usize) -> Self {
+        Self::new(Severity::Error, code, message, file_path, line)
+    }
+}
+
+impl fmt::Display for Diagnostic {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let severity = match self.severity {
+            Severity::Error => "error",
+            Severity::Warning => "warning",
+        };
+        write!(f, "{}:{}", self.file_path.display(), self.line)?;
+        if self.column > 0 {
+            write!(f, ":{}", self.column)?;
+        }
+        write!(f, ": {}: {} [{}]", severity, self.message, self.code)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_display_includes_known_column() {
+        let diagnostic =
+            Diagnostic::error("syntax-error", "missing `}`".to_string(), "a.go".into(), 3);
+        assert_eq!(
+            diagnostic.to_string(),
+            "a.go:3: error: missing `}` [syntax-error]"
+        );
+        assert_eq!(
+            diagnostic.at_column(7).to_string(),
+            "a.go:3:7: error: missing `}` [syntax-error]"
+        );
+    }
+}
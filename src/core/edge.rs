@@ -1,6 +1,7 @@
 use serde::{Deserialize, Serialize};
-use std::collections::HashMap;
+use std::collections::BTreeMap;
 use std::path::PathBuf;
+use std::str::FromStr;
 
 #[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, Hash)]
 #[serde(rename_all = "snake_case")]
@@ -8,6 +9,102 @@ pub enum EdgeType {
     Calls,
     Imports,
     Implements,
+    /// A use of a function or method other than calling it, kept in `CodeGraph::references`
+    References,
+}
+
+/// How a symbol is used at a reference site
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ReferenceKind {
+    /// Called, directly or through a local bound to it (`op := Add` then `op()`)
+    Call,
+    /// Assigned to a variable, e.g. `f := PrintMessage`
+    Assignment,
+    /// Passed to a function, e.g. `apply(Add, 1, 2)`
+    Argument,
+    /// A method value or method expression, e.g. `c.Add` or `(*Calculator).Add`
+    MethodValue,
+    /// Any other use as a value, such as being returned or stored in a literal
+    Value,
+    /// A struct field being set, e.g. `c.name = n` or `Calculator{name: n}`
+    Write,
+    /// A function literal, from the function it is written in, e.g. `defer func() { ... }()`
+    Closure,
+}
+
+impl ReferenceKind {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ReferenceKind::Call => "call",
+            ReferenceKind::Assignment => "assignment",
+            ReferenceKind::Argument => "argument",
+            ReferenceKind::MethodValue => "method_value",
+            ReferenceKind::Value => "value",
+            ReferenceKind::Write => "write",
+            ReferenceKind::Closure => "closure",
+        }
+    }
+}
+
+impl FromStr for ReferenceKind {
+    type Err = anyhow::Error;
+
+    /// Parse a kind as written on the command line; `method-value` is accepted too
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "call" => Ok(ReferenceKind::Call),
+            "assignment" => Ok(ReferenceKind::Assignment),
+            "argument" => Ok(ReferenceKind::Argument),
+            "method_value" | "method-value" => Ok(ReferenceKind::MethodValue),
+            "value" => Ok(ReferenceKind::Value),
+            "write" => Ok(ReferenceKind::Write),
+            "closure" => Ok(ReferenceKind::Closure),
+            _ => anyhow::bail!("Unknown reference kind: {}", s),
+        }
+    }
+}
+
+/// How a call runs relative to its caller
+#[derive(
+    Debug, Clone, Copy, Default, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize,
+)]
+#[serde(rename_all = "snake_case")]
+pub enum EdgeKind {
+    /// An ordinary call that returns before the caller continues
+    #[default]
+    Normal,
+    /// Launched as a goroutine, e.g. `go worker(jobs)`
+    Go,
+    /// Deferred until the caller returns, e.g. `defer c.LogOperation(op, result)`
+    Defer,
+}
+
+impl EdgeKind {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            EdgeKind::Normal => "normal",
+            EdgeKind::Go => "go",
+            EdgeKind::Defer => "defer",
+        }
+    }
+
+    pub fn is_normal(&self) -> bool {
+        *self == EdgeKind::Normal
+    }
+}
+
+impl FromStr for EdgeKind {
+    type Err = anyhow::Error;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "normal" => Ok(EdgeKind::Normal),
+            "go" => Ok(EdgeKind::Go),
+            "defer" => Ok(EdgeKind::Defer),
+            _ => anyhow::bail!("Unknown call kind: {}", s),
+        }
+    }
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -19,8 +116,11 @@ pub struct Edge {
     pub call_site: String,
     pub file_path: PathBuf,
     pub line: usize,
+    /// 1-based column of the call expression; 0 when unknown
+    #[serde(default)]
+    pub column: usize,
     #[serde(default)]
-    pub metadata: HashMap<String, String>,
+    pub metadata: BTreeMap<String, String>,
 }
 
 impl Edge {
@@ -39,7 +139,56 @@ impl Edge {
             call_site,
             file_path,
             line,
-            metadata: HashMap::new(),
+            column: 0,
+            metadata: BTreeMap::new(),
         }
     }
+
+    /// A possible call through a function-typed parameter rather than a call written
+    /// in the source, e.g. from `apply` to `Add` when `apply(Add, 1, 2)` calls `f(a, b)`
+    pub fn is_indirect(&self) -> bool {
+        self.metadata.contains_key("indirect")
+    }
+
+    /// A call through a parameter, e.g. `f(a, b)`, or through a local whose function
+    /// isn't known where the call is made; it resolves to nothing itself, and the
+    /// indirect edges beside it carry the functions it may call
+    pub fn calls_through_value(&self) -> bool {
+        self.metadata.contains_key("parameter")
+            || (self.metadata.contains_key("local") && !self.metadata.contains_key("via"))
+    }
+
+    /// A possible call of an implementation through an interface method rather than a
+    /// call written in the source, e.g. from `report` to `Square.Area` for `s.Area()`
+    /// on a `Shape`
+    pub fn is_dynamic(&self) -> bool {
+        self.metadata.contains_key("dynamic")
+    }
+
+    /// Embedded fields a call of a promoted method goes through, outermost first, as in
+    /// `["*Base", "Logger"]`; empty when the method is declared on the receiver's type
+    pub fn promoted_via(&self) -> Vec<String> {
+        self.metadata
+            .get("promoted_via")
+            .map(|via| via.split(',').map(str::to_string).collect())
+            .unwrap_or_default()
+    }
+
+    /// Unexported functions a call goes through after
+    /// [`hide_unexported`](super::CodeGraph::hide_unexported), in call order; empty for
+    /// a call written in the source
+    pub fn hidden_via(&self) -> Vec<String> {
+        self.metadata
+            .get(super::HIDDEN_VIA)
+            .map(|via| via.split(',').map(str::to_string).collect())
+            .unwrap_or_default()
+    }
+
+    /// Whether the call is an ordinary one, a `go` statement or a `defer`
+    pub fn kind(&self) -> EdgeKind {
+        self.metadata
+            .get("kind")
+            .and_then(|kind| kind.parse().ok())
+            .unwrap_or_default()
+    }
 }
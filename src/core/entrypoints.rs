@@ -0,0 +1,103 @@
+use super::{CodeGraph, Node, NodeType};
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::path::Path;
+
+/// Root set name `deadcode --root` and `path --roots` accept for every entry point
+pub const ENTRY_POINTS: &str = "entrypoints";
+
+/// Why a function is an entry point
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum EntryKind {
+    /// `func main` of a main package
+    Main,
+    /// An `init` function, run when its package is loaded
+    Init,
+    /// An exported function or method of a library package, callable by importers
+    Exported,
+}
+
+impl EntryKind {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            EntryKind::Main => "main",
+            EntryKind::Init => "init",
+            EntryKind::Exported => "exported",
+        }
+    }
+
+    /// main and init, and unless `strict`, exported functions and methods of packages
+    /// other than main, since importers may call them. Code in test files is never an
+    /// entry point, so functions only tests call are still reported, and neither is a
+    /// function literal, which is live when the function containing it is.
+    pub fn of(node: &Node, strict: bool) -> Option<Self> {
+        if !matches!(node.node_type, NodeType::Function | NodeType::Method)
+            || node.metadata.contains_key("abstract")
+            || node.metadata.contains_key("test")
+            || node.metadata.contains_key("enclosing")
+        {
+            return None;
+        }
+        if node.node_type == NodeType::Function {
+            if node.name == "init" {
+                return Some(EntryKind::Init);
+            }
+            if node.name == "main" && node.package == "main" {
+                return Some(EntryKind::Main);
+            }
+        }
+        (!strict && node.package != "main" && node.is_exported()).then_some(EntryKind::Exported)
+    }
+}
+
+#[derive(Debug, Clone)]
+pub struct EntryPoint<'a> {
+    pub node: &'a Node,
+    pub kind: EntryKind,
+}
+
+/// The entry points of one package, i.e. one directory
+#[derive(Debug, Clone)]
+pub struct PackageEntryPoints<'a> {
+    /// Import path when indexed from a module, the package name otherwise
+    pub package: String,
+    pub dir: &'a Path,
+    /// In declaration order: by file, then line
+    pub entry_points: Vec<EntryPoint<'a>>,
+}
+
+impl CodeGraph {
+    /// Every entry point grouped by package, packages in directory order; see
+    /// [`EntryKind::of`] for what counts and what `strict` leaves out
+    pub fn entry_points(&self, strict: bool) -> Vec<PackageEntryPoints<'_>> {
+        let mut packages: BTreeMap<&Path, PackageEntryPoints> = BTreeMap::new();
+        for node in &self.nodes {
+            let Some(kind) = EntryKind::of(node, strict) else {
+                continue;
+            };
+            let dir = node.file_path.parent().unwrap_or(Path::new(""));
+            packages
+                .entry(dir)
+                .or_insert_with(|| PackageEntryPoints {
+                    package: node
+                        .metadata
+                        .get("import_path")
+                        .cloned()
+                        .unwrap_or_else(|| node.package.clone()),
+                    dir,
+                    entry_points: Vec::new(),
+                })
+                .entry_points
+                .push(EntryPoint { node, kind });
+        }
+
+        let mut packages: Vec<PackageEntryPoints> = packages.into_values().collect();
+        for package in &mut packages {
+            package.entry_points.sort_by(|a, b| {
+                (&a.node.file_path, a.node.line).cmp(&(&b.node.file_path, b.node.line))
+            });
+        }
+        packages
+    }
+}
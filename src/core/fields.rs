@@ -0,0 +1,90 @@
+use super::{CodeGraph, Edge, Node, NodeType};
+use anyhow::{bail, Result};
+use serde::{Deserialize, Serialize};
+use std::str::FromStr;
+
+/// How a struct field is accessed
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum FieldAccessKind {
+    /// The value is used, e.g. `fmt.Sprintf("%s", c.name)`
+    Read,
+    /// Assigned through a selector, e.g. `c.name = name` or `c.count++`
+    Write,
+    /// Set by a composite literal, e.g. `&Calculator{name: name}`
+    Init,
+}
+
+impl FieldAccessKind {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            FieldAccessKind::Read => "read",
+            FieldAccessKind::Write => "write",
+            FieldAccessKind::Init => "init",
+        }
+    }
+}
+
+impl FromStr for FieldAccessKind {
+    type Err = anyhow::Error;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "read" => Ok(FieldAccessKind::Read),
+            "write" => Ok(FieldAccessKind::Write),
+            "init" => Ok(FieldAccessKind::Init),
+            _ => bail!("Unknown field access kind: {}", s),
+        }
+    }
+}
+
+/// One access to a struct field
+#[derive(Debug, Clone)]
+pub struct FieldAccess<'a> {
+    pub field: &'a Node,
+    pub kind: FieldAccessKind,
+    /// The function or method the access is in
+    pub function: Option<&'a Node>,
+    /// The reference edge, carrying the position and source line
+    pub reference: &'a Edge,
+}
+
+impl CodeGraph {
+    /// Every read, write and literal initialization of the struct field `symbol`
+    /// (`Calculator.name` or `name`), sorted by position. Only selectors on values
+    /// whose type the parser knows, such as receivers, typed parameters and
+    /// `c := &Calculator{}`, can be attributed to a field.
+    pub fn field_accesses(&self, symbol: &str) -> Result<Vec<FieldAccess<'_>>> {
+        let fields: Vec<&Node> = self
+            .find_nodes_by_symbol(symbol)
+            .into_iter()
+            .filter(|node| node.node_type == NodeType::Field)
+            .collect();
+        let field = match fields.as_slice() {
+            [] => bail!("Field not found: {}", symbol),
+            [field] => *field,
+            _ => self.resolve_symbol(symbol)?,
+        };
+
+        let mut accesses: Vec<FieldAccess> = self
+            .references
+            .iter()
+            .filter(|reference| reference.metadata.get("target_id") == Some(&field.id))
+            .map(|reference| FieldAccess {
+                field,
+                kind: reference
+                    .metadata
+                    .get("access")
+                    .and_then(|access| access.parse().ok())
+                    .unwrap_or(FieldAccessKind::Read),
+                function: self.get_node_by_id(&reference.from),
+                reference,
+            })
+            .collect();
+        accesses.sort_by(|a, b| {
+            let (a, b) = (a.reference, b.reference);
+            (&a.file_path, a.line, a.column).cmp(&(&b.file_path, b.line, b.column))
+        });
+        Ok(accesses)
+    }
+}
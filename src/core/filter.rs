@@ -0,0 +1,139 @@
+use super::{root_label, Node, NodeType};
+use anyhow::{bail, Context};
+use std::path::Path;
+use std::str::FromStr;
+
+/// Symbols matching every condition of a query such as
+/// `kind:method receiver:Calculator exported:true file:calculator.go name:~Add`, or
+/// `root:svc/a` in an index of several roots.
+/// The empty query matches everything.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct SymbolFilter {
+    conditions: Vec<Condition>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum Condition {
+    /// Any of these kinds; `kind:method,function`
+    Kind(Vec<NodeType>),
+    /// The whole name or, for methods and fields, the bare one
+    Name(String),
+    /// `name:~Add`: part of the name
+    NameContains(String),
+    /// The receiver's type, `*` included only if given
+    Receiver(String),
+    Exported(bool),
+    /// Part of the file path
+    File(String),
+    /// Package name or import path
+    Package(String),
+    /// The root of a multi-root index, as [`root_label`] names it
+    Root(String),
+}
+
+const KEYS: &str = "kind, name, receiver, exported, file, package, root";
+
+impl FromStr for SymbolFilter {
+    type Err = anyhow::Error;
+
+    /// Parse whitespace-separated `key:value` terms
+    fn from_str(query: &str) -> Result<Self, Self::Err> {
+        let mut conditions = Vec::new();
+        for term in query.split_whitespace() {
+            let Some((key, value)) = term.split_once(':') else {
+                bail!(
+                    "Invalid filter term {:?}: expected key:value with key one of {}",
+                    term,
+                    KEYS
+                );
+            };
+            if value.is_empty() {
+                bail!("Invalid filter term {:?}: {} needs a value", term, key);
+            }
+            conditions.push(match key {
+                "kind" => Condition::Kind(
+                    value
+                        .split(',')
+                        .map(str::parse::<NodeType>)
+                        .collect::<anyhow::Result<_>>()
+                        .with_context(|| format!("Invalid filter term {:?}", term))?,
+                ),
+                "name" => match value.strip_prefix('~') {
+                    Some(part) => Condition::NameContains(part.to_string()),
+                    None => Condition::Name(value.to_string()),
+                },
+                "receiver" => Condition::Receiver(value.to_string()),
+                "exported" => Condition::Exported(match value {
+                    "true" | "yes" => true,
+                    "false" | "no" => false,
+                    _ => bail!("Invalid filter term {:?}: exported is true or false", term),
+                }),
+                "file" => Condition::File(value.to_string()),
+                "package" => Condition::Package(value.to_string()),
+                "root" => Condition::Root(root_label(Path::new(""), Path::new(value))),
+                _ => bail!(
+                    "Unknown filter key {:?} in {:?}; expected one of {}",
+                    key,
+                    term,
+                    KEYS
+                ),
+            });
+        }
+        Ok(Self { conditions })
+    }
+}
+
+impl SymbolFilter {
+    pub fn is_empty(&self) -> bool {
+        self.conditions.is_empty()
+    }
+
+    /// Also require symbols to lie in `root`, as `--root` does
+    pub fn with_root(mut self, root: &str) -> Self {
+        self.conditions
+            .push(Condition::Root(root_label(Path::new(""), Path::new(root))));
+        self
+    }
+
+    pub fn matches(&self, node: &Node) -> bool {
+        self.conditions.iter().all(|condition| match condition {
+            Condition::Kind(kinds) => kinds.contains(&node.node_type),
+            Condition::Name(name) => node.name == *name || node.identifier() == name,
+            Condition::NameContains(part) => node.name.contains(part.as_str()),
+            Condition::Receiver(receiver) => {
+                let wanted = match receiver.starts_with('*') {
+                    true => node.metadata.get("receiver"),
+                    false => node.metadata.get("receiver_type"),
+                };
+                wanted == Some(receiver)
+            }
+            Condition::Exported(exported) => node.is_exported() == *exported,
+            Condition::File(part) => node.file_path.to_string_lossy().contains(part.as_str()),
+            Condition::Package(package) => {
+                node.package == *package || node.metadata.get("import_path") == Some(package)
+            }
+            Condition::Root(root) => node.root() == Some(root.as_str()),
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_errors_name_the_problem() {
+        let err = "kind:method colour:red"
+            .parse::<SymbolFilter>()
+            .unwrap_err();
+        assert_eq!(
+            err.to_string(),
+            "Unknown filter key \"colour\" in \"colour:red\"; expected one of kind, name, receiver, exported, file, package, root"
+        );
+        assert!("Add".parse::<SymbolFilter>().is_err());
+        assert!("name:".parse::<SymbolFilter>().is_err());
+        assert!("exported:maybe".parse::<SymbolFilter>().is_err());
+        assert!("kind:method,widget".parse::<SymbolFilter>().is_err());
+        assert!("".parse::<SymbolFilter>().unwrap().is_empty());
+    }
+}
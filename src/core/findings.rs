@@ -0,0 +1,178 @@
+use super::{Cycle, DeadCodeReport, Node, Span};
+use std::path::PathBuf;
+
+/// How serious a finding is, named as in SARIF
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum Level {
+    Error,
+    Warning,
+    Note,
+}
+
+impl Level {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Level::Error => "error",
+            Level::Warning => "warning",
+            Level::Note => "note",
+        }
+    }
+}
+
+/// A check whose results are reported as findings
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub struct Rule {
+    /// Stable identifier, e.g. `dead-code`
+    pub id: &'static str,
+    /// Human-readable name in UpperCamelCase, e.g. `DeadCode`
+    pub name: &'static str,
+    /// One sentence on what the rule reports
+    pub description: &'static str,
+    pub level: Level,
+}
+
+/// Functions and methods no entry point reaches, as found by `deadcode`
+pub const DEAD_CODE: Rule = Rule {
+    id: "dead-code",
+    name: "DeadCode",
+    description: "Function or method that no entry point reaches.",
+    level: Level::Warning,
+};
+
+/// Functions calling each other in a loop, as found by `cycles`
+pub const CALL_CYCLE: Rule = Rule {
+    id: "call-cycle",
+    name: "CallCycle",
+    description: "Functions that call each other in a loop, or a function calling itself.",
+    level: Level::Note,
+};
+
+/// Functions above a cyclomatic complexity threshold, as found by `complexity`
+pub const HIGH_COMPLEXITY: Rule = Rule {
+    id: "high-complexity",
+    name: "HighComplexity",
+    description: "Function whose cyclomatic complexity exceeds the threshold.",
+    level: Level::Warning,
+};
+
+/// Where in a file a finding points; lines and columns are 1-based and an end of 0
+/// means the region is just the start position
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct FindingLocation {
+    pub file_path: PathBuf,
+    pub line: usize,
+    pub column: usize,
+    pub end_line: usize,
+    pub end_column: usize,
+    /// What is at this location, for locations related to a finding
+    pub message: Option<String>,
+}
+
+impl FindingLocation {
+    /// The name of `node` where it is declared, or its first line for graphs indexed
+    /// before name spans were recorded
+    pub fn of(node: &Node) -> Self {
+        let (line, column, end_line, end_column) = match node.name_span {
+            Some(Span { start, end }) => (start.line, start.column, end.line, end.column),
+            None => (node.line, node.column, 0, 0),
+        };
+        Self {
+            file_path: node.file_path.clone(),
+            line,
+            column,
+            end_line,
+            end_column,
+            message: None,
+        }
+    }
+
+    pub fn with_message(mut self, message: String) -> Self {
+        self.message = Some(message);
+        self
+    }
+}
+
+/// One result of an analysis, in a form every output format can render
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Finding {
+    pub rule: Rule,
+    pub level: Level,
+    pub message: String,
+    pub location: FindingLocation,
+    /// Other places the finding involves, such as the rest of a cycle's members
+    pub related: Vec<FindingLocation>,
+}
+
+impl Finding {
+    pub fn new(rule: Rule, message: String, location: FindingLocation) -> Self {
+        Self {
+            rule,
+            level: rule.level,
+            message,
+            location,
+            related: Vec::new(),
+        }
+    }
+}
+
+impl DeadCodeReport<'_> {
+    /// One finding per unreachable function, in source order
+    pub fn findings(&self) -> Vec<Finding> {
+        self.dead
+            .iter()
+            .map(|node| {
+                Finding::new(
+                    DEAD_CODE,
+                    format!(
+                        "{} is not reachable from any of the {} entry points",
+                        node.name,
+                        self.roots.len()
+                    ),
+                    FindingLocation::of(node),
+                )
+            })
+            .collect()
+    }
+}
+
+impl Cycle<'_> {
+    /// A finding at the cycle's first member, related to every member
+    pub fn finding(&self) -> Finding {
+        let first = self.nodes[0];
+        let message = if self.self_recursive {
+            format!("{} calls itself", first.name)
+        } else {
+            let names: Vec<&str> = self.nodes.iter().map(|node| node.name.as_str()).collect();
+            format!("Call cycle: {} → {}", names.join(" → "), first.name)
+        };
+        let mut finding = Finding::new(CALL_CYCLE, message, FindingLocation::of(first));
+        if !self.self_recursive {
+            finding.related = self
+                .nodes
+                .iter()
+                .map(|node| FindingLocation::of(node).with_message(node.name.clone()))
+                .collect();
+        }
+        finding
+    }
+}
+
+/// One finding per function in `complex`, as returned by
+/// [`CodeGraph::complex_functions`](super::CodeGraph::complex_functions)
+pub fn complexity_findings(complex: &[&Node], threshold: usize) -> Vec<Finding> {
+    complex
+        .iter()
+        .map(|node| {
+            Finding::new(
+                HIGH_COMPLEXITY,
+                format!(
+                    "{} has a cyclomatic complexity of {}, above the threshold of {}",
+                    node.name,
+                    node.complexity().unwrap_or_default(),
+                    threshold
+                ),
+                FindingLocation::of(node),
+            )
+        })
+        .collect()
+}
@@ -1,11 +1,15 @@
-use super::edge::Edge;
+use super::diagnostic::Diagnostic;
+use super::edge::{Edge, EdgeKind, EdgeType, ReferenceKind};
+use super::imports::{Import, ModuleBinding};
 use super::node::{Node, NodeType};
+use crate::serializer::file_cache::CacheStats;
 use crate::serializer::index_cache::SerializedIndices;
+use anyhow::{bail, Result};
 use serde::{Deserialize, Serialize};
 use std::collections::hash_map::DefaultHasher;
-use std::collections::{HashMap, HashSet};
+use std::collections::{BTreeMap, HashMap, HashSet};
 use std::hash::{Hash, Hasher};
-use std::path::PathBuf;
+use std::path::{Path, PathBuf};
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct GraphMetadata {
@@ -16,8 +20,50 @@ pub struct GraphMetadata {
     pub root_path: String,
     pub stats: GraphStats,
     #[serde(default)]
-    pub file_metadata: HashMap<String, FileMetadata>,
+    pub file_metadata: BTreeMap<String, FileMetadata>,
     pub git_commit_hash: Option<String>,
+    #[serde(default)]
+    pub diagnostics: Vec<Diagnostic>,
+    /// Symbols declared only in files the Go build constraints left out, by file, named
+    /// as calls resolve to them: `Name`, or `Type.Method` for methods
+    #[serde(default)]
+    pub build_excluded: BTreeMap<String, Vec<String>>,
+    /// Content hash of each Go file when its symbols were read, by path, so
+    /// [`source`](CodeGraph::source) can refuse a file edited since
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub file_hashes: BTreeMap<String, String>,
+    /// `references` was written to a file of its own beside the graph, by
+    /// `index --low-memory`, and is loaded only by the commands that read it
+    #[serde(default)]
+    pub references_spilled: bool,
+    /// The roots of an index spanning several, such as the modules of a Go workspace,
+    /// relative to `root_path`, e.g. `svc/a`; empty for an index of `root_path` alone
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub roots: Vec<String>,
+    /// The Go build settings of the `index` run that wrote the graph; an incremental
+    /// update with different ones indexes everything again
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub build: Option<BuildSettings>,
+}
+
+/// What decided which Go files went into an index
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BuildSettings {
+    pub goos: String,
+    pub goarch: String,
+    #[serde(default)]
+    pub tags: Vec<String>,
+    /// Minor version of the Go release `go1.N` tags were checked against
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub go_version: Option<u32>,
+    #[serde(default)]
+    pub cgo: bool,
+    #[serde(default)]
+    pub all_platforms: bool,
+    #[serde(default)]
+    pub include_vendor: bool,
+    #[serde(default)]
+    pub include_tests: bool,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -25,6 +71,12 @@ pub struct GraphStats {
     pub total_nodes: usize,
     pub total_edges: usize,
     pub files_parsed: usize,
+    /// How long the `index` run that wrote the graph took, in milliseconds
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub index_duration_ms: Option<u64>,
+    /// Per-file cache use of that run, when it had the cache enabled
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub cache: Option<CacheStats>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -39,6 +91,17 @@ pub struct CodeGraph {
     pub metadata: GraphMetadata,
     pub nodes: Vec<Node>,
     pub edges: Vec<Edge>,
+    /// Uses of functions and methods other than calls, e.g. `f := PrintMessage`.
+    /// Kept apart from `edges` so call-graph queries only follow calls.
+    #[serde(default)]
+    pub references: Vec<Edge>,
+    /// Import specs of every parsed file, for the package import graph
+    #[serde(default)]
+    pub imports: Vec<Import>,
+    /// ES module imports and exports of every parsed TypeScript or JavaScript file, for
+    /// resolving calls across files
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub modules: Vec<ModuleBinding>,
 
     // Indexes for fast querying (not serialized)
     #[serde(skip, default)]
@@ -72,12 +135,23 @@ impl CodeGraph {
                     total_nodes: 0,
                     total_edges: 0,
                     files_parsed: 0,
+                    index_duration_ms: None,
+                    cache: None,
                 },
-                file_metadata: HashMap::new(),
+                file_metadata: BTreeMap::new(),
                 git_commit_hash: None,
+                diagnostics: Vec::new(),
+                build_excluded: BTreeMap::new(),
+                file_hashes: BTreeMap::new(),
+                references_spilled: false,
+                roots: Vec::new(),
+                build: None,
             },
             nodes: Vec::new(),
             edges: Vec::new(),
+            references: Vec::new(),
+            imports: Vec::new(),
+            modules: Vec::new(),
             node_by_id: HashMap::new(),
             outgoing: HashMap::new(),
             incoming: HashMap::new(),
@@ -107,12 +181,23 @@ impl CodeGraph {
                     total_nodes: 0,
                     total_edges: 0,
                     files_parsed: 0,
+                    index_duration_ms: None,
+                    cache: None,
                 },
-                file_metadata: HashMap::new(),
+                file_metadata: BTreeMap::new(),
                 git_commit_hash: None,
+                diagnostics: Vec::new(),
+                build_excluded: BTreeMap::new(),
+                file_hashes: BTreeMap::new(),
+                references_spilled: false,
+                roots: Vec::new(),
+                build: None,
             },
             nodes: Vec::with_capacity(estimated_nodes),
             edges: Vec::with_capacity(estimated_edges),
+            references: Vec::new(),
+            imports: Vec::new(),
+            modules: Vec::new(),
             node_by_id: HashMap::with_capacity(estimated_nodes),
             outgoing: HashMap::with_capacity(estimated_edges / 2),
             incoming: HashMap::with_capacity(estimated_edges / 2),
@@ -148,6 +233,10 @@ impl CodeGraph {
         self.metadata.stats.total_edges = self.edges.len();
     }
 
+    pub fn add_reference(&mut self, reference: Edge) {
+        self.references.push(reference);
+    }
+
     /// Ensure indices are up-to-date (Phase 1 optimization: lazy rebuilding)
     pub fn ensure_indices(&mut self) {
         if self.indices_dirty {
@@ -214,9 +303,17 @@ impl CodeGraph {
             self.edges.push(edge);
         }
 
+        self.references.extend(other.references);
+        self.imports.extend(other.imports);
+        self.modules.extend(other.modules);
         self.metadata
             .file_metadata
             .extend(other.metadata.file_metadata);
+        self.metadata.diagnostics.extend(other.metadata.diagnostics);
+        self.metadata
+            .build_excluded
+            .extend(other.metadata.build_excluded);
+        self.metadata.file_hashes.extend(other.metadata.file_hashes);
     }
 
     pub fn get_node_by_id(&self, id: &str) -> Option<&Node> {
@@ -235,6 +332,98 @@ impl CodeGraph {
             .unwrap_or_default()
     }
 
+    /// Look up nodes by symbol name, accepting receiver-qualified method names
+    /// (`(*Calculator).Add`, `Calculator.Add`) as well as bare method names (`Add`)
+    /// when no function of that exact name exists. Names may be prefixed with a Go
+    /// import path (`example.com/mymod/calc.Add`) to pick one package's definition, and
+    /// by the root as well (`svc/a:example.com/shared/calc.Add`) where two roots of the
+    /// index declare the package. Struct fields are found the same way, as `Calculator.name` or `name`. A node's ID
+    /// names it alone.
+    pub fn find_nodes_by_symbol(&self, symbol: &str) -> Vec<&Node> {
+        if let Some(node) = self.get_node_by_id(symbol) {
+            return vec![node];
+        }
+        let exact = self.get_nodes_by_name(symbol);
+        if !exact.is_empty() {
+            return exact;
+        }
+
+        let qualified: Vec<&Node> = self
+            .nodes
+            .iter()
+            .filter(|node| {
+                // For a package two roots declare, the import path alone names both
+                let qualifiers = [node.qualifier(), node.metadata.get("import_path").cloned()];
+                qualifiers.iter().flatten().any(|qualifier| {
+                    symbol
+                        .strip_prefix(qualifier.as_str())
+                        .and_then(|rest| rest.strip_prefix('.'))
+                        .is_some_and(|rest| node.name == rest || member_matches(node, rest))
+                })
+            })
+            .collect();
+        if !qualified.is_empty() {
+            return qualified;
+        }
+
+        self.get_nodes_by_type(&NodeType::Method)
+            .into_iter()
+            .chain(self.get_nodes_by_type(&NodeType::Field))
+            .filter(|node| member_matches(node, symbol))
+            .collect()
+    }
+
+    /// The single node `symbol` names, for commands that start from one function.
+    /// Fails when nothing matches or when several packages define the symbol.
+    pub fn resolve_symbol(&self, symbol: &str) -> Result<&Node> {
+        let mut nodes = self.find_nodes_by_symbol(symbol);
+        match nodes.len() {
+            0 => bail!("Function not found: {}", symbol),
+            1 => Ok(nodes[0]),
+            _ => {
+                nodes.sort_by(|a, b| (&a.file_path, a.line).cmp(&(&b.file_path, b.line)));
+                let candidates: Vec<String> = nodes
+                    .iter()
+                    .map(|node| {
+                        format!(
+                            "{} ({}:{})",
+                            node.qualified_name(),
+                            node.file_path.display(),
+                            node.line
+                        )
+                    })
+                    .collect();
+                bail!(
+                    "Ambiguous symbol {}; qualify it with one of:\n  {}",
+                    symbol,
+                    candidates.join("\n  ")
+                )
+            }
+        }
+    }
+
+    /// Definitions an edge points at: the node it was resolved to during indexing,
+    /// or every node in the caller's language sharing the target name when the call
+    /// could not be resolved.
+    /// Unresolved calls into an imported package point outside the index, and a call
+    /// through a parameter points nowhere; its indirect edges carry the candidates.
+    pub fn edge_targets(&self, edge: &Edge) -> Vec<&Node> {
+        if let Some(target) = edge
+            .metadata
+            .get("target_id")
+            .and_then(|id| self.get_node_by_id(id))
+        {
+            return vec![target];
+        }
+        if is_external_call(edge) || edge.calls_through_value() {
+            return Vec::new();
+        }
+        // Calls are never bound across languages
+        let mut targets = self.get_nodes_by_name(&edge.to);
+        targets.retain(|node| same_language(&node.file_path, &edge.file_path));
+        targets
+    }
+
     pub fn get_nodes_by_type(&self, node_type: &NodeType) -> Vec<&Node> {
         self.by_type
             .get(node_type)
@@ -297,26 +486,23 @@ impl CodeGraph {
             results.push(TraceResult {
                 from_id: edge.from.clone(),
                 to_name: edge.to.clone(),
+                to_id: edge.metadata.get("target_id").cloned(),
                 edge_type: edge.edge_type.clone(),
                 call_site: edge.call_site.clone(),
                 file_path: edge.file_path.clone(),
                 line: edge.line,
+                column: edge.column,
                 depth,
+                indirect: edge.is_indirect(),
+                dynamic: edge.is_dynamic(),
+                call_kind: edge.kind(),
+                promoted_via: edge.promoted_via(),
+                hidden_via: edge.hidden_via(),
             });
 
             // Try to find the target node and recurse
-            if let Some(target_nodes) = self.by_name.get(&edge.to) {
-                for &target_idx in target_nodes {
-                    if let Some(target_node) = self.nodes.get(target_idx) {
-                        self.trace_recursive(
-                            &target_node.id,
-                            depth + 1,
-                            max_depth,
-                            visited,
-                            results,
-                        );
-                    }
-                }
+            for target_node in self.edge_targets(edge) {
+                self.trace_recursive(&target_node.id, depth + 1, max_depth, visited, results);
             }
         }
     }
@@ -334,6 +520,134 @@ impl CodeGraph {
             .unwrap_or_default()
     }
 
+    /// Every call site of `symbol` with the enclosing function of the call
+    pub fn callers(&self, symbol: &str) -> Vec<CallSite> {
+        self.transitive_callers(symbol, 1)
+    }
+
+    /// Call sites that eventually reach `symbol`, walking callers breadth-first up to
+    /// `max_depth` levels (1 = direct callers). Each enclosing function is expanded once,
+    /// so recursive and mutually recursive calls terminate.
+    pub fn transitive_callers(&self, symbol: &str, max_depth: usize) -> Vec<CallSite> {
+        let mut results = Vec::new();
+
+        // Unknown symbols (e.g. fmt.Println) are matched by name only
+        let targets = self.find_nodes_by_symbol(symbol);
+        let mut expanded: HashSet<String> = targets.iter().map(|n| n.id.clone()).collect();
+        let mut frontier: Vec<(String, Option<String>)> = if targets.is_empty() {
+            vec![(symbol.to_string(), None)]
+        } else {
+            targets
+                .iter()
+                .map(|n| (n.name.clone(), Some(n.id.clone())))
+                .collect()
+        };
+
+        for depth in 1..=max_depth {
+            let mut next = Vec::new();
+            let mut level = Vec::new();
+
+            for (name, id) in &frontier {
+                for edge in self.find_callers(name) {
+                    // Skip calls resolved to a different definition of the same name
+                    if let (Some(id), Some(target_id)) = (id, edge.metadata.get("target_id")) {
+                        if id != target_id {
+                            continue;
+                        }
+                    }
+                    if id.is_some() && is_external_call(edge) {
+                        continue;
+                    }
+
+                    let caller = self.get_node_by_id(&edge.from);
+                    level.push(CallSite {
+                        caller: caller
+                            .map(|n| n.name.clone())
+                            .unwrap_or_else(|| edge.from.clone()),
+                        caller_id: edge.from.clone(),
+                        callee: edge.to.clone(),
+                        callee_id: edge.metadata.get("target_id").cloned(),
+                        call_site: edge.call_site.clone(),
+                        file_path: edge.file_path.clone(),
+                        line: edge.line,
+                        column: edge.column,
+                        depth,
+                        kind: ReferenceKind::Call,
+                        indirect: edge.is_indirect(),
+                        dynamic: edge.is_dynamic(),
+                        call_kind: edge.kind(),
+                    });
+
+                    if let Some(caller) = caller {
+                        if expanded.insert(caller.id.clone()) {
+                            next.push((caller.name.clone(), Some(caller.id.clone())));
+                        }
+                    }
+                }
+            }
+
+            level.sort_by(|a, b| {
+                (&a.file_path, a.line, a.column).cmp(&(&b.file_path, b.line, b.column))
+            });
+            results.extend(level);
+
+            if next.is_empty() {
+                break;
+            }
+            frontier = next;
+        }
+
+        results
+    }
+
+    /// Every use of `symbol`: its call sites plus the places it is taken as a value,
+    /// such as `f := PrintMessage`, `apply(Add)` or `(*Calculator).Add`, sorted by position
+    pub fn references(&self, symbol: &str) -> Vec<CallSite> {
+        let mut sites = self.callers(symbol);
+
+        let targets = self.find_nodes_by_symbol(symbol);
+        let ids: HashSet<&str> = targets.iter().map(|n| n.id.as_str()).collect();
+        for reference in &self.references {
+            let target_id = reference.metadata.get("target_id");
+            let matches = match target_id {
+                Some(target_id) => ids.contains(target_id.as_str()),
+                // Like callers, unknown symbols such as strings.ToUpper match by name
+                None => targets.is_empty() && reference.to == symbol,
+            };
+            if !matches || reference.edge_type != EdgeType::References {
+                continue;
+            }
+
+            sites.push(CallSite {
+                caller: self
+                    .get_node_by_id(&reference.from)
+                    .map(|n| n.name.clone())
+                    .unwrap_or_else(|| reference.from.clone()),
+                caller_id: reference.from.clone(),
+                callee: reference.to.clone(),
+                callee_id: target_id.cloned(),
+                call_site: reference.call_site.clone(),
+                file_path: reference.file_path.clone(),
+                line: reference.line,
+                column: reference.column,
+                depth: 1,
+                kind: reference
+                    .metadata
+                    .get("reference")
+                    .and_then(|kind| kind.parse().ok())
+                    .unwrap_or(ReferenceKind::Value),
+                indirect: false,
+                dynamic: false,
+                call_kind: EdgeKind::Normal,
+            });
+        }
+
+        sites.sort_by(|a, b| {
+            (&a.file_path, a.line, a.column).cmp(&(&b.file_path, b.line, b.column))
+        });
+        sites
+    }
+
     /// Find all paths from one node to another
     pub fn find_paths(&self, from_id: &str, to_name: &str, max_depth: usize) -> Vec<Vec<String>> {
         self.find_paths_limited(from_id, to_name, max_depth, usize::MAX)
@@ -550,10 +864,14 @@ impl CodeGraph {
             )
             .len();
 
+        let cyclomatic = self
+            .get_node_by_id(node_id)
+            .and_then(Node::complexity)
+            .unwrap_or(fan_out + 1); // Simplified for languages that don't measure it
         ComplexityMetrics {
             fan_in,
             fan_out,
-            cyclomatic: fan_out + 1, // Simplified
+            cyclomatic,
         }
     }
 
@@ -583,34 +901,38 @@ impl CodeGraph {
     pub fn extract_subgraph(&self, from_name: &str, max_depth: usize) -> CodeGraph {
         let mut extracted_nodes = Vec::new();
         let mut extracted_edges = Vec::new();
-        let mut visited = HashSet::new();
-        let mut node_ids_to_include = HashSet::new();
+        // node id -> fewest calls from a starting node
+        let mut depths = HashMap::new();
 
         // Find starting nodes by name
         if let Some(start_nodes) = self.by_name.get(from_name) {
             for &node_idx in start_nodes {
                 if let Some(start_node) = self.nodes.get(node_idx) {
-                    self.extract_recursive(
-                        &start_node.id,
-                        0,
-                        max_depth,
-                        &mut visited,
-                        &mut node_ids_to_include,
-                    );
+                    self.extract_recursive(&start_node.id, 0, max_depth, &mut depths);
                 }
             }
         }
 
         // Collect nodes that should be included
         for node in &self.nodes {
-            if node_ids_to_include.contains(&node.id) {
+            if depths.contains_key(&node.id) {
                 extracted_nodes.push(node.clone());
             }
         }
 
-        // Collect edges where from is in the subgraph
+        // Collect edges leaving the subgraph's nodes, dropping those whose targets were
+        // cut off by the depth limit. A call to an external function is one call further,
+        // so it is kept only from within the limit, as `trace` and `callers` cut them.
         for edge in &self.edges {
-            if node_ids_to_include.contains(&edge.from) {
+            let Some(&depth) = depths.get(&edge.from) else {
+                continue;
+            };
+            let targets = self.edge_targets(edge);
+            let keep = match targets.is_empty() {
+                true => depth < max_depth,
+                false => targets.iter().any(|t| depths.contains_key(&t.id)),
+            };
+            if keep {
                 extracted_edges.push(edge.clone());
             }
         }
@@ -626,12 +948,23 @@ impl CodeGraph {
                     total_nodes: extracted_nodes.len(),
                     total_edges: extracted_edges.len(),
                     files_parsed: 0,
+                    index_duration_ms: None,
+                    cache: None,
                 },
-                file_metadata: HashMap::new(),
+                file_metadata: BTreeMap::new(),
                 git_commit_hash: None,
+                diagnostics: Vec::new(),
+                build_excluded: BTreeMap::new(),
+                file_hashes: BTreeMap::new(),
+                references_spilled: false,
+                roots: Vec::new(),
+                build: None,
             },
             nodes: extracted_nodes,
             edges: extracted_edges,
+            references: Vec::new(),
+            imports: Vec::new(),
+            modules: Vec::new(),
             node_by_id: Default::default(),
             outgoing: Default::default(),
             incoming: Default::default(),
@@ -704,12 +1037,23 @@ impl CodeGraph {
                     total_nodes: filtered_nodes.len(),
                     total_edges: filtered_edges.len(),
                     files_parsed: 0,
+                    index_duration_ms: None,
+                    cache: None,
                 },
-                file_metadata: HashMap::new(),
+                file_metadata: BTreeMap::new(),
                 git_commit_hash: None,
+                diagnostics: Vec::new(),
+                build_excluded: BTreeMap::new(),
+                file_hashes: BTreeMap::new(),
+                references_spilled: false,
+                roots: Vec::new(),
+                build: None,
             },
             nodes: filtered_nodes,
             edges: filtered_edges,
+            references: Vec::new(),
+            imports: Vec::new(),
+            modules: Vec::new(),
             node_by_id: Default::default(),
             outgoing: Default::default(),
             incoming: Default::default(),
@@ -727,31 +1071,19 @@ impl CodeGraph {
         node_id: &str,
         depth: usize,
         max_depth: usize,
-        visited: &mut HashSet<String>,
-        node_ids_to_include: &mut HashSet<String>,
+        depths: &mut HashMap<String, usize>,
     ) {
-        if depth > max_depth || visited.contains(node_id) {
+        // Revisit a node reached in fewer calls, so its callees get their own depth right
+        if depth > max_depth || depths.get(node_id).is_some_and(|&seen| seen <= depth) {
             return;
         }
 
-        visited.insert(node_id.to_string());
-        node_ids_to_include.insert(node_id.to_string());
+        depths.insert(node_id.to_string(), depth);
 
         // Traverse outgoing edges
         for edge in self.get_outgoing_edges(node_id) {
-            // Try to find target nodes by name
-            if let Some(target_nodes) = self.by_name.get(&edge.to) {
-                for &target_idx in target_nodes {
-                    if let Some(target_node) = self.nodes.get(target_idx) {
-                        self.extract_recursive(
-                            &target_node.id,
-                            depth + 1,
-                            max_depth,
-                            visited,
-                            node_ids_to_include,
-                        );
-                    }
-                }
+            for target_node in self.edge_targets(edge) {
+                self.extract_recursive(&target_node.id, depth + 1, max_depth, depths);
             }
         }
     }
@@ -773,11 +1105,53 @@ impl CodeGraph {
 
         // Remove edges where from node is being removed
         self.edges.retain(|e| !nodes_to_remove.contains(&e.from));
+        self.references
+            .retain(|r| !nodes_to_remove.contains(&r.from));
+        self.imports
+            .retain(|i| i.file_path.to_string_lossy() != file_path_normalized);
+        self.modules
+            .retain(|b| b.file_path.to_string_lossy() != file_path_normalized);
+        self.metadata.build_excluded.remove(file_path);
+        self.metadata.file_hashes.remove(file_path);
+        self.metadata
+            .diagnostics
+            .retain(|d| d.file_path != Path::new(file_path));
 
         // Rebuild indexes after removal
         self.build_indexes();
     }
 
+    /// Functions and methods some call launches as a goroutine (`EdgeKind::Go`) or
+    /// defers (`EdgeKind::Defer`), in source order
+    pub fn called_as(&self, kind: EdgeKind) -> Vec<&Node> {
+        let mut seen = HashSet::new();
+        let mut nodes: Vec<&Node> = self
+            .edges
+            .iter()
+            .filter(|edge| edge.edge_type == EdgeType::Calls && edge.kind() == kind)
+            .flat_map(|edge| self.edge_targets(edge))
+            .filter(|node| seen.insert(node.id.as_str()))
+            .collect();
+        nodes.sort_by(|a, b| (&a.file_path, a.line).cmp(&(&b.file_path, b.line)));
+        nodes
+    }
+
+    /// Drop the possible calls inferred through function-typed parameters, keeping only
+    /// calls written in the source
+    pub fn remove_indirect_calls(&mut self) {
+        self.edges.retain(|edge| !edge.is_indirect());
+        self.metadata.stats.total_edges = self.edges.len();
+        self.build_indexes();
+    }
+
+    /// Drop the calls of implementations inferred through interface methods, leaving
+    /// each such call pointing at the interface method only
+    pub fn remove_dynamic_calls(&mut self) {
+        self.edges.retain(|edge| !edge.is_dynamic());
+        self.metadata.stats.total_edges = self.edges.len();
+        self.build_indexes();
+    }
+
     /// Track which nodes came from which file (for incremental updates)
     pub fn track_file_metadata(&mut self, file_path: &PathBuf, last_modified: String) {
         let file_path_str = file_path.to_string_lossy().to_string();
@@ -800,82 +1174,6 @@ impl CodeGraph {
         );
     }
 
-    /// Compare this graph with another and return differences
-    pub fn diff(&self, other: &CodeGraph) -> GraphDiff {
-        let mut added_nodes = Vec::new();
-        let mut removed_nodes = Vec::new();
-        let mut changed_nodes = Vec::new();
-        let mut complexity_changes = Vec::new();
-
-        // Build sets of node IDs for quick lookup
-        let old_ids: HashSet<_> = self.nodes.iter().map(|n| n.id.clone()).collect();
-        let new_ids: HashSet<_> = other.nodes.iter().map(|n| n.id.clone()).collect();
-
-        // Find added nodes (in new but not in old)
-        for node_id in &new_ids {
-            if !old_ids.contains(node_id) {
-                added_nodes.push(node_id.clone());
-            }
-        }
-
-        // Find removed nodes (in old but not in new)
-        for node_id in &old_ids {
-            if !new_ids.contains(node_id) {
-                removed_nodes.push(node_id.clone());
-            }
-        }
-
-        // Find changed nodes (present in both but with different signatures)
-        for old_node in &self.nodes {
-            if let Some(new_node) = other.get_node_by_id(&old_node.id) {
-                if old_node.signature != new_node.signature || old_node.line != new_node.line {
-                    changed_nodes.push(NodeChange {
-                        node_id: old_node.id.clone(),
-                        node_name: old_node.name.clone(),
-                        old_signature: old_node.signature.clone(),
-                        new_signature: new_node.signature.clone(),
-                        old_line: old_node.line,
-                        new_line: new_node.line,
-                    });
-                }
-
-                // Check complexity changes
-                let old_fan_in = self.incoming.get(&old_node.id).map_or(0, |v| v.len());
-                let old_fan_out = self.outgoing.get(&old_node.id).map_or(0, |v| v.len());
-                let new_fan_in = other.incoming.get(&old_node.id).map_or(0, |v| v.len());
-                let new_fan_out = other.outgoing.get(&old_node.id).map_or(0, |v| v.len());
-
-                let old_total = (old_fan_in + old_fan_out) as i32;
-                let new_total = (new_fan_in + new_fan_out) as i32;
-                let change = new_total - old_total;
-
-                if change != 0 {
-                    complexity_changes.push(ComplexityChange {
-                        node_id: old_node.id.clone(),
-                        node_name: old_node.name.clone(),
-                        old_fan_in,
-                        new_fan_in,
-                        old_fan_out,
-                        new_fan_out,
-                        change,
-                    });
-                }
-            }
-        }
-
-        let added_edges_count = other.edges.len().saturating_sub(self.edges.len());
-        let removed_edges_count = self.edges.len().saturating_sub(other.edges.len());
-
-        GraphDiff {
-            added_nodes,
-            removed_nodes,
-            changed_nodes,
-            added_edges_count,
-            removed_edges_count,
-            complexity_changes,
-        }
-    }
-
     /// Compute a hash of the graph structure for cache validation
     /// Uses fast hashing to detect if graph has changed
     pub fn compute_hash(&self) -> String {
@@ -937,11 +1235,56 @@ impl CodeGraph {
 pub struct TraceResult {
     pub from_id: String,
     pub to_name: String,
+    /// Node the call was resolved to, when known
+    pub to_id: Option<String>,
     pub edge_type: super::EdgeType,
     pub call_site: String,
     pub file_path: std::path::PathBuf,
     pub line: usize,
+    pub column: usize,
     pub depth: usize,
+    /// A possible call through a function-typed parameter
+    #[serde(default)]
+    pub indirect: bool,
+    /// A possible call of an implementation through an interface method
+    #[serde(default)]
+    pub dynamic: bool,
+    /// Whether the call is launched with `go` or deferred
+    #[serde(default)]
+    pub call_kind: EdgeKind,
+    /// Embedded fields the called method is promoted through
+    #[serde(default)]
+    pub promoted_via: Vec<String>,
+    /// Unexported functions the call goes through with `--exported-only`
+    #[serde(default)]
+    pub hidden_via: Vec<String>,
+}
+
+/// A call of some function, located in its enclosing caller
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CallSite {
+    pub caller: String,
+    pub caller_id: String,
+    pub callee: String,
+    /// Node the call was resolved to, when known
+    pub callee_id: Option<String>,
+    pub call_site: String,
+    pub file_path: std::path::PathBuf,
+    pub line: usize,
+    pub column: usize,
+    /// 1 for direct callers, n for callers n calls away from the target
+    pub depth: usize,
+    /// `Call` for call sites; other kinds come from [`CodeGraph::references`]
+    pub kind: ReferenceKind,
+    /// A possible call through a function-typed parameter
+    #[serde(default)]
+    pub indirect: bool,
+    /// A possible call of an implementation through an interface method
+    #[serde(default)]
+    pub dynamic: bool,
+    /// Whether the call is launched with `go` or deferred
+    #[serde(default)]
+    pub call_kind: EdgeKind,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -957,33 +1300,30 @@ pub struct HotspotResult {
     pub call_count: usize,
 }
 
-#[derive(Debug, Clone, Serialize, Deserialize)]
-pub struct GraphDiff {
-    pub added_nodes: Vec<String>,   // Node IDs
-    pub removed_nodes: Vec<String>, // Node IDs
-    pub changed_nodes: Vec<NodeChange>,
-    pub added_edges_count: usize,
-    pub removed_edges_count: usize,
-    pub complexity_changes: Vec<ComplexityChange>,
+/// Whether the files at `a` and `b` are in the same language
+fn same_language(a: &Path, b: &Path) -> bool {
+    crate::parser::language_of(a) == crate::parser::language_of(b)
 }
 
-#[derive(Debug, Clone, Serialize, Deserialize)]
-pub struct NodeChange {
-    pub node_id: String,
-    pub node_name: String,
-    pub old_signature: String,
-    pub new_signature: String,
-    pub old_line: usize,
-    pub new_line: usize,
+/// An unresolved call through an import, i.e. into a package that isn't indexed
+fn is_external_call(edge: &Edge) -> bool {
+    edge.metadata.contains_key("import_path") && !edge.metadata.contains_key("target_id")
 }
 
-#[derive(Debug, Clone, Serialize, Deserialize)]
-pub struct ComplexityChange {
-    pub node_id: String,
-    pub node_name: String,
-    pub old_fan_in: usize,
-    pub new_fan_in: usize,
-    pub old_fan_out: usize,
-    pub new_fan_out: usize,
-    pub change: i32, // positive = increased, negative = decreased
+/// Whether `symbol` names this method as `Type.Method`, `(*Type).Method` or `Method`,
+/// or this struct field as `Struct.field` or `field`
+fn member_matches(node: &Node, symbol: &str) -> bool {
+    let (receiver, method) = match node.node_type {
+        NodeType::Field => ("struct", "field"),
+        _ => ("receiver_type", "method"),
+    };
+    let method = node.metadata.get(method).map(String::as_str);
+    let receiver = node.metadata.get(receiver).map(String::as_str);
+    match (receiver, method) {
+        (Some(receiver), Some(method)) => {
+            let unqualified = symbol.replace("(*", "").replace(')', "");
+            method == symbol || format!("{}.{}", receiver, method) == unqualified
+        }
+        _ => false,
+    }
 }
@@ -0,0 +1,166 @@
+use super::{CodeGraph, EdgeType, Node};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashMap};
+use std::path::Path;
+use std::str::FromStr;
+
+/// What [`CodeGraph::group_calls`] gathers symbols by
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum GroupBy {
+    /// The source file, relative to the indexed root
+    File,
+    /// The import path, or the package name where there is none
+    Package,
+}
+
+impl GroupBy {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            GroupBy::File => "file",
+            GroupBy::Package => "package",
+        }
+    }
+}
+
+impl FromStr for GroupBy {
+    type Err = anyhow::Error;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "file" => Ok(GroupBy::File),
+            "package" => Ok(GroupBy::Package),
+            _ => anyhow::bail!("Unknown grouping: {}. Use: file, package", s),
+        }
+    }
+}
+
+/// The symbols of one file or package, in source order
+#[derive(Debug, Clone)]
+pub struct SymbolGroup<'a> {
+    pub name: String,
+    pub nodes: Vec<&'a Node>,
+}
+
+/// Where the calls of a [`GroupCall`] go
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Hash)]
+pub enum GroupTarget {
+    /// A group, by its index in [`GroupedCalls::groups`]
+    Group(usize),
+    /// A function outside the index, qualified as written, e.g. `fmt.Println`
+    External(String),
+}
+
+/// The calls from one group to one target, merged
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GroupCall {
+    /// Index in [`GroupedCalls::groups`]
+    pub from: usize,
+    pub to: GroupTarget,
+    /// Call sites merged, each call of each resolved target counted once
+    pub calls: usize,
+}
+
+impl GroupCall {
+    /// Between two symbols of the same group
+    pub fn is_internal(&self) -> bool {
+        self.to == GroupTarget::Group(self.from)
+    }
+}
+
+/// The call graph with symbols gathered into groups, as returned by
+/// [`CodeGraph::group_calls`]
+#[derive(Debug, Clone)]
+pub struct GroupedCalls<'a> {
+    pub by: GroupBy,
+    /// By name
+    pub groups: Vec<SymbolGroup<'a>>,
+    /// By source group, then target
+    pub calls: Vec<GroupCall>,
+}
+
+impl<'a> GroupedCalls<'a> {
+    /// The index of each node's group, by node ID
+    pub fn membership(&self) -> HashMap<&'a str, usize> {
+        self.groups
+            .iter()
+            .enumerate()
+            .flat_map(|(i, group)| group.nodes.iter().map(move |node| (node.id.as_str(), i)))
+            .collect()
+    }
+}
+
+impl CodeGraph {
+    /// Every symbol gathered by file or package, with the calls between groups merged
+    /// and counted. Calls inside a group are kept as [`internal`](GroupCall::is_internal)
+    /// calls; possible calls through parameters are left out, like the calls they stand
+    /// for.
+    pub fn group_calls(&self, by: GroupBy) -> GroupedCalls<'_> {
+        let mut by_name: BTreeMap<String, Vec<&Node>> = BTreeMap::new();
+        for node in &self.nodes {
+            by_name
+                .entry(self.group_name(node, by))
+                .or_default()
+                .push(node);
+        }
+        let groups: Vec<SymbolGroup> = by_name
+            .into_iter()
+            .map(|(name, mut nodes)| {
+                nodes.sort_by(|a, b| {
+                    (&a.file_path, a.line, &a.name).cmp(&(&b.file_path, b.line, &b.name))
+                });
+                SymbolGroup { name, nodes }
+            })
+            .collect();
+        let mut grouped = GroupedCalls {
+            by,
+            groups,
+            calls: Vec::new(),
+        };
+        let index = grouped.membership();
+
+        let mut counts: BTreeMap<(usize, GroupTarget), usize> = BTreeMap::new();
+        for edge in &self.edges {
+            if edge.edge_type != EdgeType::Calls || edge.is_indirect() {
+                continue;
+            }
+            let Some(&from) = index.get(edge.from.as_str()) else {
+                continue;
+            };
+            let targets = self.edge_targets(edge);
+            if targets.is_empty() {
+                let name = match edge.metadata.get("qualifier") {
+                    Some(qualifier) => format!("{}.{}", qualifier, edge.to),
+                    None => edge.to.clone(),
+                };
+                *counts
+                    .entry((from, GroupTarget::External(name)))
+                    .or_default() += 1;
+            }
+            for target in targets {
+                if let Some(&to) = index.get(target.id.as_str()) {
+                    *counts.entry((from, GroupTarget::Group(to))).or_default() += 1;
+                }
+            }
+        }
+
+        grouped.calls = counts
+            .into_iter()
+            .map(|((from, to), calls)| GroupCall { from, to, calls })
+            .collect();
+        grouped
+    }
+
+    /// The name of the group `node` falls in
+    fn group_name(&self, node: &Node, by: GroupBy) -> String {
+        match by {
+            GroupBy::File => node
+                .file_path
+                .strip_prefix(Path::new(&self.metadata.root_path))
+                .unwrap_or(&node.file_path)
+                .display()
+                .to_string(),
+            GroupBy::Package => node.qualifier().unwrap_or_else(|| node.package.clone()),
+        }
+    }
+}
@@ -0,0 +1,100 @@
+use super::interfaces::{embeds, TypeIndex};
+use super::{CodeGraph, Implementation, Node, NodeType};
+
+/// A type and, below it, the types it embeds, as returned by [`CodeGraph::hierarchy`]
+#[derive(Debug, Clone)]
+pub struct HierarchyEntry<'a> {
+    /// The type as its embedder writes it, without the `*`, e.g. `Calculator` or
+    /// `sync.Mutex`; at the root, the type's own name
+    pub name: String,
+    /// The type's declaration; `None` for a type outside the index, which is a leaf
+    pub node: Option<&'a Node>,
+    /// Embedded through a pointer, as in `*Calculator`
+    pub pointer: bool,
+    /// The type already appears on the way here from the root, so its embeds are not
+    /// repeated below it
+    pub cycle: bool,
+    /// Embedded types in declaration order: struct fields, or an interface's embedded
+    /// interfaces
+    pub embeds: Vec<HierarchyEntry<'a>>,
+}
+
+impl HierarchyEntry<'_> {
+    /// Embedded from outside the index, e.g. `sync.Mutex`
+    pub fn is_external(&self) -> bool {
+        self.node.is_none()
+    }
+}
+
+/// What a type is built from and how it relates to interfaces
+#[derive(Debug, Clone)]
+pub struct TypeHierarchy<'a> {
+    pub root: HierarchyEntry<'a>,
+    /// For an interface, the indexed types implementing it; otherwise the indexed
+    /// interfaces the type satisfies, as [`CodeGraph::implementations`] lists them
+    pub implementations: Vec<Implementation<'a>>,
+}
+
+impl CodeGraph {
+    /// The types `type_node` embeds, recursively, with the interfaces it satisfies or,
+    /// for an interface, the types implementing it. Embedding that leads back to a type
+    /// on the current branch, possible through pointers, is marked `cycle` and not
+    /// followed again.
+    pub fn hierarchy<'a>(&'a self, type_node: &'a Node) -> TypeHierarchy<'a> {
+        let index = TypeIndex::build(&self.nodes);
+        let mut branch = Vec::new();
+        let root = embedding(
+            &index,
+            type_node.name.clone(),
+            type_node,
+            false,
+            &mut branch,
+        );
+        let implementations = match type_node.node_type {
+            NodeType::Interface => index.implementers_of(type_node),
+            _ => index.interfaces_of(type_node),
+        };
+        TypeHierarchy {
+            root,
+            implementations,
+        }
+    }
+}
+
+/// `node` with its embeds below it; `branch` holds the IDs of the types above it
+fn embedding<'a>(
+    index: &TypeIndex<'a>,
+    name: String,
+    node: &'a Node,
+    pointer: bool,
+    branch: &mut Vec<&'a str>,
+) -> HierarchyEntry<'a> {
+    let mut entry = HierarchyEntry {
+        name,
+        node: Some(node),
+        pointer,
+        cycle: branch.contains(&node.id.as_str()),
+        embeds: Vec::new(),
+    };
+    if entry.cycle {
+        return entry;
+    }
+
+    branch.push(&node.id);
+    for embedded in embeds(node) {
+        let pointer = embedded.starts_with('*');
+        let name = embedded.trim_start_matches('*').to_string();
+        entry.embeds.push(match index.lookup(node, embedded) {
+            Some(field_type) => embedding(index, name, field_type, pointer, branch),
+            None => HierarchyEntry {
+                name,
+                node: None,
+                pointer,
+                cycle: false,
+                embeds: Vec::new(),
+            },
+        });
+    }
+    branch.pop();
+    entry
+}
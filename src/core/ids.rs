@@ -0,0 +1,135 @@
+use super::{CodeGraph, Node, NodeType};
+use crate::serializer::file_cache::content_hash;
+use std::collections::HashMap;
+use std::path::Path;
+
+impl CodeGraph {
+    /// Give every Go symbol its [`stable_id`] and point edges, references and file
+    /// metadata at the new IDs. Symbols that would share one, such as the `init`
+    /// functions of a package or one function declared per platform, get `~2`, `~3`...
+    /// after the first, in file and line order. Assigning again changes nothing.
+    pub fn assign_stable_ids(&mut self) {
+        self.assign_stable_ids_where(|_| true);
+    }
+
+    /// [`assign_stable_ids`](Self::assign_stable_ids) for the package in `dir` alone, after
+    /// adding one of its files. IDs never collide across packages, so the rest keep theirs.
+    pub fn assign_package_stable_ids(&mut self, dir: &Path) {
+        self.assign_stable_ids_where(|node| node.file_path.parent() == Some(dir));
+    }
+
+    fn assign_stable_ids_where(&mut self, in_scope: impl Fn(&Node) -> bool) {
+        let root = Path::new(&self.metadata.root_path).to_path_buf();
+        let mut order: Vec<usize> = (0..self.nodes.len())
+            .filter(|&i| is_go(&self.nodes[i]) && in_scope(&self.nodes[i]))
+            .collect();
+        let nodes = &self.nodes;
+        order.sort_by(|&a, &b| {
+            let (a, b) = (&nodes[a], &nodes[b]);
+            (&a.file_path, a.line, a.column).cmp(&(&b.file_path, b.line, b.column))
+        });
+
+        let mut taken: HashMap<String, usize> = HashMap::new();
+        let mut renamed: HashMap<String, String> = HashMap::new();
+        for i in order {
+            let base = stable_id(&self.nodes[i], &root);
+            let count = taken.entry(base.clone()).or_default();
+            *count += 1;
+            let id = match *count {
+                1 => base,
+                n => format!("{}~{}", base, n),
+            };
+            if id != self.nodes[i].id {
+                renamed.insert(std::mem::replace(&mut self.nodes[i].id, id.clone()), id);
+            }
+        }
+        if renamed.is_empty() {
+            return;
+        }
+
+        let rename = |id: &mut String| {
+            if let Some(new) = renamed.get(id.as_str()) {
+                *id = new.clone();
+            }
+        };
+        for edge in self.edges.iter_mut().chain(self.references.iter_mut()) {
+            rename(&mut edge.from);
+            for key in ["target_id", "parameter_of"] {
+                if let Some(id) = edge.metadata.get_mut(key) {
+                    rename(id);
+                }
+            }
+        }
+        for file in self.metadata.file_metadata.values_mut() {
+            file.node_ids.iter_mut().for_each(rename);
+        }
+        self.build_indexes();
+    }
+
+    /// The symbol with this ID, stable or not
+    pub fn resolve(&self, id: &str) -> Option<&Node> {
+        self.get_node_by_id(id)
+    }
+
+    /// Every symbol `name` may mean, as accepted by commands, in file and line order
+    pub fn lookup(&self, name: &str) -> Vec<&Node> {
+        let mut nodes = self.find_nodes_by_symbol(name);
+        nodes.sort_by(|a, b| (&a.file_path, a.line).cmp(&(&b.file_path, b.line)));
+        nodes
+    }
+}
+
+/// `pkgpath.Name#hash`, e.g. `example.com/mymod/calc.(*Calculator).Add#1f2e3d4c`: the
+/// package's [`Node::qualifier`], or without a `go.mod` its directory under `root` (the
+/// package name at the root), then the symbol's name and a hash of its signature. The
+/// signature is the parameter and result types of functions and methods and the
+/// declaration of anything else, whitespace ignored, so the ID survives reformatting
+/// and moving within the package but not a change of signature.
+pub fn stable_id(node: &Node, root: &Path) -> String {
+    let package = match node.qualifier() {
+        Some(qualifier) => qualifier,
+        None => {
+            let dir = node.file_path.parent().unwrap_or(Path::new(""));
+            let relative = dir.strip_prefix(root).unwrap_or(dir);
+            match relative.as_os_str().is_empty() {
+                true => node.package.clone(),
+                false => relative
+                    .components()
+                    .map(|part| part.as_os_str().to_string_lossy())
+                    .collect::<Vec<_>>()
+                    .join("/"),
+            }
+        }
+    };
+    let hash = content_hash(signature(node).as_bytes());
+    format!("{}.{}#{}", package, node.name, &hash[..8])
+}
+
+fn signature(node: &Node) -> String {
+    let compact = |text: &str| text.split_whitespace().collect::<String>();
+    match node.node_type {
+        NodeType::Function | NodeType::Method | NodeType::Benchmark | NodeType::Fuzz => {
+            let params: Vec<String> = node
+                .parameters
+                .iter()
+                .map(|param| compact(&param.param_type))
+                .collect();
+            let results: Vec<String> = node.returns.iter().map(|r| compact(r)).collect();
+            format!(
+                "{}({})({})",
+                node.node_type.as_str(),
+                params.join(","),
+                results.join(",")
+            )
+        }
+        _ => format!(
+            "{} {}",
+            node.node_type.as_str(),
+            compact(node.declaration())
+        ),
+    }
+}
+
+fn is_go(node: &Node) -> bool {
+    node.file_path.extension().is_some_and(|ext| ext == "go")
+}
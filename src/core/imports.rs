@@ -0,0 +1,250 @@
+use super::cycles::strongly_connected;
+use super::CodeGraph;
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, BTreeSet, HashSet};
+use std::path::{Path, PathBuf};
+
+/// One import spec in a source file, e.g. `import calc "example.com/mymod/calc"`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Import {
+    /// Import path as written, e.g. `example.com/mymod/calc`
+    pub path: String,
+    /// Name the spec gives the package: an identifier, `.` or `_`
+    pub alias: Option<String>,
+    pub file_path: PathBuf,
+    pub line: usize,
+    pub column: usize,
+    /// Import path of the importing package's directory, when its module is known
+    #[serde(default)]
+    pub importer: Option<String>,
+    /// Package clause of the importing file, e.g. `calc` or `calc_test`
+    #[serde(default)]
+    pub package: String,
+    /// Whether `path` lies inside the importing package's module
+    #[serde(default)]
+    pub internal: bool,
+}
+
+/// How an import spec names the package it imports
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ImportKind {
+    /// `import "fmt"`
+    Normal,
+    /// `import f "fmt"`
+    Alias,
+    /// `import . "fmt"`
+    Dot,
+    /// `import _ "net/http/pprof"`, for the package's side effects only
+    Blank,
+}
+
+impl ImportKind {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ImportKind::Normal => "normal",
+            ImportKind::Alias => "alias",
+            ImportKind::Dot => "dot",
+            ImportKind::Blank => "blank",
+        }
+    }
+}
+
+impl Import {
+    pub fn kind(&self) -> ImportKind {
+        match self.alias.as_deref() {
+            None => ImportKind::Normal,
+            Some(".") => ImportKind::Dot,
+            Some("_") => ImportKind::Blank,
+            Some(_) => ImportKind::Alias,
+        }
+    }
+}
+
+/// What an ES module statement binds across files
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum BindingKind {
+    /// `import { add as plus } from './calculator'`
+    Import,
+    /// `export function add`, `export default add` or `export { add as sum }`
+    Export,
+    /// `export { add } from './calculator'` or `export * from './calculator'`
+    ReExport,
+}
+
+/// One name an `import` or `export` statement binds, e.g. `plus` in
+/// `import { add as plus } from './calculator'`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ModuleBinding {
+    pub kind: BindingKind,
+    /// Module specifier as written, e.g. `./calculator`; `None` for an export of a
+    /// declaration in the same file
+    pub source: Option<String>,
+    /// The name bound: an export of `source`, `default` for its default export or `*`
+    /// for the whole module; for an export of a local declaration, its name
+    pub name: String,
+    /// The name it is bound to: the local name of an import, the exported name of an
+    /// export, `*` for `export * from`
+    pub alias: String,
+    pub file_path: PathBuf,
+    pub line: usize,
+    pub column: usize,
+}
+
+/// Packages importing each other in a loop, which `go build` rejects
+#[derive(Debug, Clone)]
+pub struct ImportCycle<'a> {
+    /// Members in the order a depth-first walk over their imports reaches them,
+    /// starting from the first by name
+    pub packages: Vec<String>,
+    /// Every import from one member to another, by importer in `packages` order
+    pub imports: Vec<&'a Import>,
+}
+
+impl CodeGraph {
+    /// The package `import` is written in: its import path, or without a module the
+    /// directory relative to the indexed root. An external test package is named like
+    /// `go list` names it, with `_test` appended, since it is a package of its own.
+    pub fn importing_package(&self, import: &Import) -> String {
+        let package = match &import.importer {
+            Some(importer) => importer.clone(),
+            None => {
+                let dir = import.file_path.parent().unwrap_or(Path::new(""));
+                match dir.strip_prefix(&self.metadata.root_path) {
+                    Ok(relative) if relative.as_os_str().is_empty() => ".".to_string(),
+                    Ok(relative) => relative.display().to_string(),
+                    Err(_) => dir.display().to_string(),
+                }
+            }
+        };
+        if is_external_test(import) {
+            format!("{}_test", package)
+        } else {
+            package
+        }
+    }
+
+    /// Import specs sorted by importing package, imported path and position. Imports of
+    /// packages outside the module are left out unless `include_external` is set, and so
+    /// are external test packages importing the package they test.
+    pub fn package_imports(&self, include_external: bool) -> Vec<&Import> {
+        let mut imports: Vec<(String, &Import)> = self
+            .imports
+            .iter()
+            .filter(|import| include_external || import.internal)
+            .map(|import| (self.importing_package(import), import))
+            .filter(|(package, import)| {
+                !(is_external_test(import)
+                    && package.strip_suffix("_test") == Some(import.path.as_str()))
+            })
+            .collect();
+        imports.sort_by(|(a_package, a), (b_package, b)| {
+            (a_package, &a.path, &a.file_path, a.line).cmp(&(
+                b_package,
+                &b.path,
+                &b.file_path,
+                b.line,
+            ))
+        });
+        imports.into_iter().map(|(_, import)| import).collect()
+    }
+
+    /// Import specs of `path`, from every package importing it, in the same order
+    pub fn importers(&self, path: &str) -> Vec<&Import> {
+        self.package_imports(true)
+            .into_iter()
+            .filter(|import| import.path == path)
+            .collect()
+    }
+
+    /// Import cycles among the module's packages, found with Tarjan's algorithm and
+    /// sorted by their first member. The index is usually of a tree that doesn't build.
+    pub fn import_cycles(&self) -> Vec<ImportCycle<'_>> {
+        let imports = self.package_imports(false);
+        let mut edges: BTreeMap<String, BTreeMap<String, Vec<&Import>>> = BTreeMap::new();
+        for &import in &imports {
+            edges
+                .entry(self.importing_package(import))
+                .or_default()
+                .entry(import.path.clone())
+                .or_default()
+                .push(import);
+        }
+
+        let packages: Vec<&String> = edges
+            .keys()
+            .chain(edges.values().flat_map(|targets| targets.keys()))
+            .collect::<BTreeSet<_>>()
+            .into_iter()
+            .collect();
+        let position = |package: &str| packages.iter().position(|p| p.as_str() == package);
+        let successors: Vec<Vec<usize>> = packages
+            .iter()
+            .map(|package| {
+                edges
+                    .get(package.as_str())
+                    .into_iter()
+                    .flat_map(|targets| targets.keys())
+                    .filter_map(|target| position(target))
+                    .collect()
+            })
+            .collect();
+
+        let mut cycles: Vec<ImportCycle> = strongly_connected(&successors)
+            .into_iter()
+            .filter(|component| {
+                component.len() > 1 || successors[component[0]].contains(&component[0])
+            })
+            .map(|component| {
+                let members: HashSet<usize> = component.iter().copied().collect();
+                let start = component.iter().copied().min().unwrap_or(component[0]);
+
+                // Walk the component's own imports depth-first, by name
+                let mut order = Vec::new();
+                let mut seen = HashSet::new();
+                let mut pending = vec![start];
+                while let Some(idx) = pending.pop() {
+                    if !seen.insert(idx) {
+                        continue;
+                    }
+                    order.push(idx);
+                    pending.extend(
+                        successors[idx]
+                            .iter()
+                            .rev()
+                            .filter(|target| members.contains(target) && !seen.contains(target)),
+                    );
+                }
+
+                let mut cycle_imports = Vec::new();
+                for &idx in &order {
+                    for &target in &successors[idx] {
+                        if members.contains(&target) {
+                            cycle_imports.extend(&edges[packages[idx]][packages[target]]);
+                        }
+                    }
+                }
+
+                ImportCycle {
+                    packages: order.iter().map(|&idx| packages[idx].clone()).collect(),
+                    imports: cycle_imports,
+                }
+            })
+            .collect();
+
+        cycles.sort_by(|a, b| a.packages[0].cmp(&b.packages[0]));
+        cycles
+    }
+}
+
+/// Whether `import` is written in an external test package, `package foo_test` in a
+/// `_test.go` file
+fn is_external_test(import: &Import) -> bool {
+    import.package.ends_with("_test")
+        && import
+            .file_path
+            .file_name()
+            .and_then(|name| name.to_str())
+            .is_some_and(|name| name.ends_with("_test.go"))
+}
@@ -0,0 +1,471 @@
+use super::{CodeGraph, Node, NodeType};
+use std::collections::{BTreeMap, HashMap, HashSet, VecDeque};
+use std::path::Path;
+
+/// A method in a type's method set, possibly promoted from an embedded field
+#[derive(Debug, Clone)]
+pub struct MethodSetEntry<'a> {
+    pub method: &'a Node,
+    /// Embedded fields the method is promoted through, outermost first, e.g.
+    /// `["*Base", "Logger"]`; empty for methods declared on the type itself
+    pub via: Vec<String>,
+}
+
+/// A type whose method set covers every method of an interface
+#[derive(Debug, Clone)]
+pub struct Implementation<'a> {
+    pub interface: &'a Node,
+    pub implementer: &'a Node,
+    /// Only `*T` implements the interface because some methods have pointer receivers
+    pub pointer: bool,
+    /// The interface's methods with the method satisfying each one, sorted by name
+    pub methods: Vec<SatisfiedMethod<'a>>,
+}
+
+/// What a selector such as `c.Warn` denotes in its operand's method set
+#[derive(Debug, Clone)]
+pub enum Selection<'a> {
+    Method(MethodSetEntry<'a>),
+    /// The name is promoted from two or more embedded fields at the same depth, which
+    /// Go rejects as an ambiguous selector
+    Ambiguous(Vec<MethodSetEntry<'a>>),
+}
+
+/// Method sets of every indexed type, built once to look up many selectors
+pub struct MethodSets<'a> {
+    index: TypeIndex<'a>,
+}
+
+impl<'a> MethodSets<'a> {
+    pub fn new(nodes: &'a [Node]) -> Self {
+        Self {
+            index: TypeIndex::build(nodes),
+        }
+    }
+
+    /// What `name` selects on an addressable value of the type `type_name` declared in
+    /// `package_dir`; `None` when the type isn't indexed or has no such method
+    pub fn select(&self, package_dir: &Path, type_name: &str, name: &str) -> Option<Selection<'a>> {
+        let &type_node = self.index.by_package.get(&(package_dir, type_name))?;
+        self.index.selections(type_node, true).remove(name)
+    }
+
+    /// The methods a call of the interface method `method` may dispatch to: the one
+    /// satisfying it on each indexed type implementing the interface, in the order the
+    /// types are declared. Empty when `method` isn't declared by an interface.
+    pub fn dispatch_targets(&self, method: &'a Node) -> Vec<&'a Node> {
+        let interface = method.metadata.get("receiver_type").and_then(|name| {
+            let package_dir = method.file_path.parent().unwrap_or(Path::new(""));
+            self.index.by_package.get(&(package_dir, name.as_str()))
+        });
+        let Some(&interface) = interface.filter(|node| node.node_type == NodeType::Interface)
+        else {
+            return Vec::new();
+        };
+
+        let mut targets: Vec<&'a Node> = Vec::new();
+        for implementation in self.index.implementers_of(interface) {
+            for satisfied in implementation.methods {
+                // Embedding types can share a promoted method
+                if satisfied.requirement.id == method.id
+                    && !targets
+                        .iter()
+                        .any(|target| target.id == satisfied.method.id)
+                {
+                    targets.push(satisfied.method);
+                }
+            }
+        }
+        targets
+    }
+}
+
+/// A concrete method and the interface method it satisfies, as returned by
+/// [`CodeGraph::method_implementations`]
+#[derive(Debug, Clone)]
+pub struct MethodImplementation<'a> {
+    /// The method as the interface declares it, e.g. `Logger.LogOperation`
+    pub requirement: &'a Node,
+    /// The method whose body runs
+    pub method: &'a Node,
+    /// The interface whose method set holds the requirement, possibly through an
+    /// embedded interface
+    pub interface: &'a Node,
+    /// The type implementing it, which may have the method promoted from a field
+    pub implementer: &'a Node,
+    /// Only `*T` implements the interface because some methods have pointer receivers
+    pub pointer: bool,
+    /// Embedded fields the method is promoted through, outermost first
+    pub via: Vec<String>,
+}
+
+#[derive(Debug, Clone)]
+pub struct SatisfiedMethod<'a> {
+    /// The method the interface declares, e.g. `Logger.LogOperation`
+    pub requirement: &'a Node,
+    pub method: &'a Node,
+    pub via: Vec<String>,
+}
+
+impl CodeGraph {
+    /// The struct, interface or named type `symbol` names
+    pub fn resolve_type(&self, symbol: &str) -> anyhow::Result<&Node> {
+        let types: Vec<&Node> = self
+            .find_nodes_by_symbol(symbol)
+            .into_iter()
+            .filter(|node| node.is_type())
+            .collect();
+        match types.as_slice() {
+            [] => anyhow::bail!("Type not found: {}", symbol),
+            [node] => Ok(node),
+            _ => self.resolve_symbol(symbol),
+        }
+    }
+
+    /// The indexed types implementing `type_node` when it is an interface, otherwise
+    /// the indexed interfaces it implements. Interfaces with no methods are left out.
+    pub fn implementations<'a>(&'a self, type_node: &'a Node) -> Vec<Implementation<'a>> {
+        let index = TypeIndex::build(&self.nodes);
+        if type_node.node_type == NodeType::Interface {
+            index.implementers_of(type_node)
+        } else {
+            index.interfaces_of(type_node)
+        }
+    }
+
+    /// The method `symbol` names, declared on a type or by an interface
+    pub fn resolve_method(&self, symbol: &str) -> anyhow::Result<&Node> {
+        let methods: Vec<&Node> = self
+            .find_nodes_by_symbol(symbol)
+            .into_iter()
+            .filter(|node| node.metadata.contains_key("receiver_type"))
+            .collect();
+        match methods.as_slice() {
+            [] => anyhow::bail!("Method not found: {}", symbol),
+            [node] => Ok(node),
+            _ => self.resolve_symbol(symbol),
+        }
+    }
+
+    /// For an interface method, the concrete methods implementing it on each indexed
+    /// type implementing the interface, in the order the types are declared. For a
+    /// concrete method, the interface methods it satisfies on its type and on every
+    /// type it is promoted to, by interface and then type. A type whose value method
+    /// set falls short is matched as `*T` and marked `pointer`.
+    pub fn method_implementations<'a>(&'a self, method: &'a Node) -> Vec<MethodImplementation<'a>> {
+        let index = TypeIndex::build(&self.nodes);
+        let Some(owner) = method
+            .metadata
+            .get("receiver_type")
+            .and_then(|receiver_type| index.lookup(method, receiver_type))
+        else {
+            return Vec::new();
+        };
+
+        let implementations = match owner.node_type {
+            NodeType::Interface => index.implementers_of(owner),
+            _ => {
+                let mut implementations: Vec<Implementation<'a>> = index
+                    .types
+                    .iter()
+                    .filter(|node| node.node_type != NodeType::Interface)
+                    .flat_map(|&node| index.interfaces_of(node))
+                    .collect();
+                // Stable, so each interface's implementers stay in declaration order
+                implementations.sort_by(|a, b| {
+                    (&a.interface.file_path, a.interface.line)
+                        .cmp(&(&b.interface.file_path, b.interface.line))
+                });
+                implementations
+            }
+        };
+        let mut matches = Vec::new();
+        for implementation in implementations {
+            for satisfied in implementation.methods {
+                let linked = match owner.node_type {
+                    NodeType::Interface => &satisfied.requirement.id,
+                    _ => &satisfied.method.id,
+                };
+                if *linked == method.id {
+                    matches.push(MethodImplementation {
+                        requirement: satisfied.requirement,
+                        method: satisfied.method,
+                        interface: implementation.interface,
+                        implementer: implementation.implementer,
+                        pointer: implementation.pointer,
+                        via: satisfied.via,
+                    });
+                }
+            }
+        }
+        matches
+    }
+
+    /// The methods callable on a value of type `type_node` (or `*type_node` when
+    /// `pointer` is set), keyed by method name. Promoted methods follow Go's rules:
+    /// shallower embeddings win and a name promoted from two fields at the same
+    /// depth is left out as ambiguous.
+    pub fn method_set<'a>(
+        &'a self,
+        type_node: &'a Node,
+        pointer: bool,
+    ) -> BTreeMap<String, MethodSetEntry<'a>> {
+        TypeIndex::build(&self.nodes).method_set(type_node, pointer)
+    }
+}
+
+/// Types and methods grouped for method-set lookups, built once per query
+pub(super) struct TypeIndex<'a> {
+    types: Vec<&'a Node>,
+    by_package: HashMap<(&'a Path, &'a str), &'a Node>,
+    by_import_path: HashMap<(&'a str, &'a str), &'a Node>,
+    methods: HashMap<(&'a Path, &'a str), Vec<&'a Node>>,
+}
+
+impl<'a> TypeIndex<'a> {
+    pub(super) fn build(nodes: &'a [Node]) -> Self {
+        let mut index = Self {
+            types: Vec::new(),
+            by_package: HashMap::new(),
+            by_import_path: HashMap::new(),
+            methods: HashMap::new(),
+        };
+        for node in nodes {
+            let package_dir = node.file_path.parent().unwrap_or(Path::new(""));
+            if node.is_type() {
+                index.types.push(node);
+                index
+                    .by_package
+                    .insert((package_dir, node.name.as_str()), node);
+                if let Some(import_path) = node.metadata.get("import_path") {
+                    index
+                        .by_import_path
+                        .insert((import_path.as_str(), node.name.as_str()), node);
+                }
+            } else if let Some(receiver_type) = node.metadata.get("receiver_type") {
+                index
+                    .methods
+                    .entry((package_dir, receiver_type.as_str()))
+                    .or_default()
+                    .push(node);
+            }
+        }
+        index
+            .types
+            .sort_by(|a, b| (&a.file_path, a.line).cmp(&(&b.file_path, b.line)));
+        index
+    }
+
+    /// The type an `embeds` entry of `owner` refers to, if it is indexed
+    pub(super) fn lookup(&self, owner: &'a Node, reference: &str) -> Option<&'a Node> {
+        let reference = reference.trim_start_matches('*');
+        match reference.rsplit_once('.') {
+            Some((import_path, name)) => self.by_import_path.get(&(import_path, name)).copied(),
+            None => {
+                let package_dir = owner.file_path.parent().unwrap_or(Path::new(""));
+                self.by_package.get(&(package_dir, reference)).copied()
+            }
+        }
+    }
+
+    /// Methods declared directly on `type_node`, abstract ones for an interface
+    fn declared(&self, type_node: &'a Node) -> &[&'a Node] {
+        let package_dir = type_node.file_path.parent().unwrap_or(Path::new(""));
+        self.methods
+            .get(&(package_dir, type_node.name.as_str()))
+            .map(Vec::as_slice)
+            .unwrap_or_default()
+    }
+
+    /// Every method an interface requires, including those of embedded interfaces.
+    /// `None` when that can't be known: an embedded interface isn't indexed, or the
+    /// interface is a type constraint.
+    fn interface_methods(&self, interface: &'a Node) -> Option<BTreeMap<String, &'a Node>> {
+        let mut methods = BTreeMap::new();
+        let mut seen = HashSet::new();
+        let mut pending = vec![interface];
+        while let Some(current) = pending.pop() {
+            if !seen.insert(current.id.as_str()) {
+                continue;
+            }
+            if current.node_type != NodeType::Interface
+                || current.metadata.contains_key("constraint")
+            {
+                return None;
+            }
+            for &method in self.declared(current) {
+                if let Some(name) = method.metadata.get("method") {
+                    methods.entry(name.clone()).or_insert(method);
+                }
+            }
+            for embedded in embeds(current) {
+                pending.push(self.lookup(current, embedded)?);
+            }
+        }
+        Some(methods)
+    }
+
+    fn method_set(
+        &self,
+        type_node: &'a Node,
+        pointer: bool,
+    ) -> BTreeMap<String, MethodSetEntry<'a>> {
+        self.selections(type_node, pointer)
+            .into_iter()
+            .filter_map(|(name, selection)| match selection {
+                Selection::Method(entry) => Some((name, entry)),
+                Selection::Ambiguous(_) => None,
+            })
+            .collect()
+    }
+
+    /// Every method name selectable on the type, breadth-first over embedded fields one
+    /// depth at a time
+    fn selections(&self, type_node: &'a Node, pointer: bool) -> BTreeMap<String, Selection<'a>> {
+        let mut set: BTreeMap<String, Selection<'a>> = BTreeMap::new();
+        // Names seen at a shallower depth, including ambiguous ones, which block deeper promotions
+        let mut shadowed: HashSet<String> = HashSet::new();
+        let mut visited = HashSet::new();
+        let mut level: VecDeque<(&'a Node, bool, Vec<String>)> =
+            VecDeque::from([(type_node, pointer, Vec::new())]);
+
+        while !level.is_empty() {
+            let mut found: HashMap<String, Vec<MethodSetEntry<'a>>> = HashMap::new();
+            let mut next = VecDeque::new();
+
+            for (current, addressable, via) in level {
+                if !visited.insert(current.id.as_str()) {
+                    continue;
+                }
+                if current.node_type == NodeType::Interface {
+                    for (name, method) in self.interface_methods(current).unwrap_or_default() {
+                        found.entry(name).or_default().push(MethodSetEntry {
+                            method,
+                            via: via.clone(),
+                        });
+                    }
+                    continue;
+                }
+
+                for &method in self.declared(current) {
+                    let pointer_receiver = method
+                        .metadata
+                        .get("receiver")
+                        .is_some_and(|r| r.starts_with('*'));
+                    if pointer_receiver && !addressable {
+                        continue;
+                    }
+                    if let Some(name) = method.metadata.get("method") {
+                        found.entry(name.clone()).or_default().push(MethodSetEntry {
+                            method,
+                            via: via.clone(),
+                        });
+                    }
+                }
+                for embedded in embeds(current) {
+                    if let Some(field_type) = self.lookup(current, embedded) {
+                        let mut path = via.clone();
+                        path.push(embedded.to_string());
+                        // Promoted through *T, every method of T is callable
+                        next.push_back((
+                            field_type,
+                            addressable || embedded.starts_with('*'),
+                            path,
+                        ));
+                    }
+                }
+            }
+
+            for (name, mut entries) in found {
+                if !shadowed.insert(name.clone()) {
+                    continue;
+                }
+                let selection = match entries.len() {
+                    1 => Selection::Method(entries.remove(0)),
+                    _ => Selection::Ambiguous(entries),
+                };
+                set.insert(name, selection);
+            }
+            level = next;
+        }
+        set
+    }
+
+    /// How `implementer` satisfies `interface`, trying the value method set before `*T`
+    fn satisfies(&self, implementer: &'a Node, interface: &'a Node) -> Option<Implementation<'a>> {
+        let required = self.interface_methods(interface)?;
+        // Every type satisfies an interface with no methods; that isn't worth reporting
+        if required.is_empty() {
+            return None;
+        }
+
+        [false, true].into_iter().find_map(|pointer| {
+            let set = self.method_set(implementer, pointer);
+            let methods = required
+                .iter()
+                .map(|(name, &requirement)| {
+                    let entry = set.get(name)?;
+                    let visible = is_exported(name) || same_package(entry.method, requirement);
+                    (visible && same_signature(entry.method, requirement)).then(|| {
+                        SatisfiedMethod {
+                            requirement,
+                            method: entry.method,
+                            via: entry.via.clone(),
+                        }
+                    })
+                })
+                .collect::<Option<Vec<_>>>()?;
+            Some(Implementation {
+                interface,
+                implementer,
+                pointer,
+                methods,
+            })
+        })
+    }
+
+    pub(super) fn implementers_of(&self, interface: &'a Node) -> Vec<Implementation<'a>> {
+        self.types
+            .iter()
+            .filter(|node| node.node_type != NodeType::Interface)
+            .filter_map(|&node| self.satisfies(node, interface))
+            .collect()
+    }
+
+    pub(super) fn interfaces_of(&self, implementer: &'a Node) -> Vec<Implementation<'a>> {
+        self.types
+            .iter()
+            .filter(|node| node.node_type == NodeType::Interface)
+            .filter_map(|&interface| self.satisfies(implementer, interface))
+            .collect()
+    }
+}
+
+pub(super) fn embeds(node: &Node) -> impl Iterator<Item = &str> {
+    node.metadata
+        .get("embeds")
+        .into_iter()
+        .flat_map(|embeds| embeds.split(','))
+}
+
+fn is_exported(name: &str) -> bool {
+    name.chars().next().is_some_and(char::is_uppercase)
+}
+
+/// Unexported interface methods can only be implemented inside the interface's package
+fn same_package(a: &Node, b: &Node) -> bool {
+    a.file_path.parent() == b.file_path.parent()
+}
+
+/// Parameter and result types match, ignoring parameter names and spacing
+fn same_signature(a: &Node, b: &Node) -> bool {
+    let params = |node: &Node| normalize(node.parameters.iter().map(|p| p.param_type.as_str()));
+    let results = |node: &Node| normalize(node.returns.iter().map(String::as_str));
+    params(a) == params(b) && results(a) == results(b)
+}
+
+fn normalize<'a>(types: impl Iterator<Item = &'a str>) -> Vec<String> {
+    types
+        .map(|t| t.split_whitespace().collect::<Vec<_>>().join(" "))
+        .collect()
+}
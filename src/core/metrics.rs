@@ -0,0 +1,164 @@
+use super::{CodeGraph, EdgeType, Node, NodeType};
+use anyhow::bail;
+use std::collections::{HashMap, HashSet};
+use std::str::FromStr;
+
+/// Call metrics of one function or method
+#[derive(Debug, Clone)]
+pub struct FunctionMetrics<'a> {
+    pub node: &'a Node,
+    /// Distinct functions calling it by name; calls made through a function value, such
+    /// as a local holding it or a parameter it is passed for, or through an interface
+    /// method don't count
+    pub fan_in: usize,
+    /// Distinct functions it calls directly, unindexed ones such as `fmt.Println` included
+    pub fan_out: usize,
+    /// Distinct indexed functions reachable through its calls, itself excluded
+    pub reachable: usize,
+    /// Non-blank lines of its body, between the braces
+    pub lines: usize,
+}
+
+/// Column `metrics` sorts by, largest first; `Name` sorts alphabetically
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum MetricsSort {
+    FanIn,
+    FanOut,
+    Reachable,
+    Lines,
+    Name,
+}
+
+impl FromStr for MetricsSort {
+    type Err = anyhow::Error;
+
+    /// Parse a column as written on the command line, e.g. `--sort fan-in`
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "fan-in" | "fan_in" => Ok(MetricsSort::FanIn),
+            "fan-out" | "fan_out" => Ok(MetricsSort::FanOut),
+            "reachable" => Ok(MetricsSort::Reachable),
+            "lines" | "loc" => Ok(MetricsSort::Lines),
+            "name" => Ok(MetricsSort::Name),
+            _ => bail!(
+                "Unknown sort column: {} (expected fan-in, fan-out, reachable, lines or name)",
+                s
+            ),
+        }
+    }
+}
+
+impl CodeGraph {
+    /// Metrics for every function and method, function literals aside, sorted by `sort`.
+    /// Ties keep source order.
+    pub fn function_metrics(&self, sort: MetricsSort) -> Vec<FunctionMetrics<'_>> {
+        let functions: Vec<(usize, &Node)> = self
+            .nodes
+            .iter()
+            .enumerate()
+            .filter(|(_, node)| {
+                matches!(
+                    node.node_type,
+                    NodeType::Function
+                        | NodeType::Method
+                        | NodeType::HttpHandler
+                        | NodeType::Middleware
+                        | NodeType::Benchmark
+                        | NodeType::Fuzz
+                ) && !node.metadata.contains_key("enclosing")
+            })
+            .collect();
+        let positions: HashMap<&str, usize> = self
+            .nodes
+            .iter()
+            .enumerate()
+            .map(|(idx, node)| (node.id.as_str(), idx))
+            .collect();
+
+        // Resolved callees of every node, and distinct callers naming every node
+        let mut callees: Vec<HashSet<usize>> = vec![HashSet::new(); self.nodes.len()];
+        let mut callers: Vec<HashSet<usize>> = vec![HashSet::new(); self.nodes.len()];
+        let mut fan_out: Vec<HashSet<&str>> = vec![HashSet::new(); self.nodes.len()];
+        for edge in self
+            .edges
+            .iter()
+            .filter(|edge| edge.edge_type == EdgeType::Calls)
+        {
+            let Some(&from) = positions.get(edge.from.as_str()) else {
+                continue;
+            };
+            let targets = self.edge_targets(edge);
+            if targets.is_empty() {
+                fan_out[from].insert(edge.to.as_str());
+            }
+            for target in targets {
+                if let Some(&to) = positions.get(target.id.as_str()) {
+                    fan_out[from].insert(target.id.as_str());
+                    callees[from].insert(to);
+                    // `via` names the local, parameter or interface method called
+                    if !edge.metadata.contains_key("via") {
+                        callers[to].insert(from);
+                    }
+                }
+            }
+        }
+
+        let mut metrics: Vec<FunctionMetrics> = functions
+            .into_iter()
+            .map(|(idx, node)| FunctionMetrics {
+                node,
+                fan_in: callers[idx].len(),
+                fan_out: fan_out[idx].len(),
+                reachable: reachable_from(idx, &callees),
+                lines: node
+                    .body_lines()
+                    .unwrap_or(node.end_line.saturating_sub(node.line) + 1),
+            })
+            .collect();
+
+        metrics.sort_by(|a, b| {
+            let order = match sort {
+                MetricsSort::FanIn => b.fan_in.cmp(&a.fan_in),
+                MetricsSort::FanOut => b.fan_out.cmp(&a.fan_out),
+                MetricsSort::Reachable => b.reachable.cmp(&a.reachable),
+                MetricsSort::Lines => b.lines.cmp(&a.lines),
+                MetricsSort::Name => a.node.name.cmp(&b.node.name),
+            };
+            order.then_with(|| {
+                (&a.node.file_path, a.node.line).cmp(&(&b.node.file_path, b.node.line))
+            })
+        });
+        metrics
+    }
+
+    /// Functions, methods and function literals whose cyclomatic complexity exceeds
+    /// `threshold`, most complex first, ties in source order
+    pub fn complex_functions(&self, threshold: usize) -> Vec<&Node> {
+        let mut nodes: Vec<&Node> = self
+            .nodes
+            .iter()
+            .filter(|node| node.complexity().is_some_and(|c| c > threshold))
+            .collect();
+        nodes.sort_by(|a, b| {
+            b.complexity()
+                .cmp(&a.complexity())
+                .then_with(|| (&a.file_path, a.line).cmp(&(&b.file_path, b.line)))
+        });
+        nodes
+    }
+}
+
+/// How many nodes a walk over `callees` reaches from `start`, `start` itself excluded
+/// even when it is recursive
+fn reachable_from(start: usize, callees: &[HashSet<usize>]) -> usize {
+    let mut seen = HashSet::from([start]);
+    let mut pending = vec![start];
+    while let Some(idx) = pending.pop() {
+        for &callee in &callees[idx] {
+            if seen.insert(callee) {
+                pending.push(callee);
+            }
+        }
+    }
+    seen.len() - 1
+}
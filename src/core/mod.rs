@@ -1,9 +1,64 @@
+pub mod callees;
+pub mod changes;
+pub mod coverage;
+pub mod cycles;
+pub mod deadcode;
+pub mod diagnostic;
 pub mod edge;
+pub mod entrypoints;
+pub mod fields;
+pub mod filter;
+pub mod findings;
 pub mod graph;
+pub mod grouping;
+pub mod hierarchy;
+pub mod ids;
+pub mod imports;
+pub mod interfaces;
+pub mod metrics;
 pub mod node;
+pub mod outline;
+pub mod page;
+pub mod paths;
+pub mod rename;
+pub mod roots;
+pub mod search;
+pub mod source;
+pub mod stats;
+pub mod unresolved;
+pub mod visibility;
 
-pub use edge::{Edge, EdgeType};
+pub use callees::{CalleeCall, CalleeClosure, CalleeOptions, Prune};
+pub use changes::{CallChange, FileChanges, GraphChanges, SymbolChange};
+pub use coverage::TestCoverage;
+pub use cycles::Cycle;
+pub use deadcode::{DeadCodeOptions, DeadCodeReport};
+pub use diagnostic::{Diagnostic, Severity};
+pub use edge::{Edge, EdgeKind, EdgeType, ReferenceKind};
+pub use entrypoints::{EntryKind, EntryPoint, PackageEntryPoints, ENTRY_POINTS};
+pub use fields::{FieldAccess, FieldAccessKind};
+pub use filter::SymbolFilter;
+pub use findings::{Finding, FindingLocation, Level, Rule};
 pub use graph::{
-    CodeGraph, ComplexityMetrics, GraphMetadata, GraphStats, HotspotResult, TraceResult,
+    BuildSettings, CallSite, CodeGraph, ComplexityMetrics, GraphMetadata, GraphStats,
+    HotspotResult, TraceResult,
 };
-pub use node::{Node, NodeType, Parameter};
+pub use grouping::{GroupBy, GroupCall, GroupTarget, GroupedCalls, SymbolGroup};
+pub use hierarchy::{HierarchyEntry, TypeHierarchy};
+pub use ids::stable_id;
+pub use imports::{BindingKind, Import, ImportCycle, ImportKind, ModuleBinding};
+pub use interfaces::{
+    Implementation, MethodImplementation, MethodSetEntry, MethodSets, SatisfiedMethod, Selection,
+};
+pub use metrics::{FunctionMetrics, MetricsSort};
+pub use node::{Node, NodeType, Parameter, Position, Span};
+pub use outline::OutlineEntry;
+pub use page::{Page, PageRequest};
+pub use paths::{CallPath, PathHop, PathOptions};
+pub use rename::{RenameConflict, RenameEdit, RenamePlan};
+pub use roots::{root_label, SHARED_IMPORT_PATH};
+pub use search::{MatchKind, SearchMatch, SearchOptions};
+pub use source::SourceSnippet;
+pub use stats::{CallCounts, IndexStats};
+pub use unresolved::{UnresolvedBucket, UnresolvedName, UnresolvedReason};
+pub use visibility::HIDDEN_VIA;
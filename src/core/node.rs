@@ -1,6 +1,8 @@
+use super::roots::SHARED_IMPORT_PATH;
 use serde::{Deserialize, Serialize};
-use std::collections::HashMap;
+use std::collections::BTreeMap;
 use std::path::PathBuf;
+use std::str::FromStr;
 
 #[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, Hash)]
 #[serde(rename_all = "snake_case")]
@@ -9,6 +11,67 @@ pub enum NodeType {
     Method,
     HttpHandler,
     Middleware,
+    /// A Go benchmark: `BenchmarkX(b *testing.B)` in a `_test.go` file
+    Benchmark,
+    /// A Go fuzz target: `FuzzX(f *testing.F)` in a `_test.go` file
+    Fuzz,
+    Struct,
+    /// A class, in languages that have them
+    Class,
+    Interface,
+    /// Any other named type, e.g. `type Celsius float64`
+    Type,
+    /// A package-level constant
+    Const,
+    /// A package-level variable
+    Var,
+    /// A named struct field, e.g. `Calculator.name`
+    Field,
+}
+
+impl NodeType {
+    /// The kind as it appears in JSON, e.g. `http_handler`
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            NodeType::Function => "function",
+            NodeType::Method => "method",
+            NodeType::HttpHandler => "http_handler",
+            NodeType::Middleware => "middleware",
+            NodeType::Benchmark => "benchmark",
+            NodeType::Fuzz => "fuzz",
+            NodeType::Struct => "struct",
+            NodeType::Class => "class",
+            NodeType::Interface => "interface",
+            NodeType::Type => "type",
+            NodeType::Const => "const",
+            NodeType::Var => "var",
+            NodeType::Field => "field",
+        }
+    }
+}
+
+impl FromStr for NodeType {
+    type Err = anyhow::Error;
+
+    /// Parse a kind as written on the command line, e.g. `--type handler`
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "function" => Ok(NodeType::Function),
+            "method" => Ok(NodeType::Method),
+            "handler" | "http_handler" => Ok(NodeType::HttpHandler),
+            "middleware" => Ok(NodeType::Middleware),
+            "benchmark" => Ok(NodeType::Benchmark),
+            "fuzz" => Ok(NodeType::Fuzz),
+            "struct" => Ok(NodeType::Struct),
+            "class" => Ok(NodeType::Class),
+            "interface" => Ok(NodeType::Interface),
+            "type" => Ok(NodeType::Type),
+            "const" => Ok(NodeType::Const),
+            "var" | "variable" => Ok(NodeType::Var),
+            "field" => Ok(NodeType::Field),
+            _ => anyhow::bail!("Unknown node type: {}", s),
+        }
+    }
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -17,6 +80,26 @@ pub struct Parameter {
     pub param_type: String,
 }
 
+/// A point in a source file: 1-based line, 1-based byte column and 0-based byte offset
+#[derive(
+    Debug, Clone, Copy, Default, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize,
+)]
+pub struct Position {
+    pub line: usize,
+    pub column: usize,
+    pub offset: usize,
+}
+
+/// A stretch of a source file; `end` is just past its last byte, so
+/// `&source[start.offset..end.offset]` is the text it covers
+#[derive(
+    Debug, Clone, Copy, Default, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize,
+)]
+pub struct Span {
+    pub start: Position,
+    pub end: Position,
+}
+
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct Node {
     pub id: String,
@@ -25,6 +108,9 @@ pub struct Node {
     pub node_type: NodeType,
     pub file_path: PathBuf,
     pub line: usize,
+    /// 1-based column of the symbol's name; 0 when unknown
+    #[serde(default)]
+    pub column: usize,
     pub end_line: usize,
     pub package: String,
     pub signature: String,
@@ -37,7 +123,13 @@ pub struct Node {
     #[serde(default)]
     pub tags: Vec<String>,
     #[serde(default)]
-    pub metadata: HashMap<String, String>,
+    pub metadata: BTreeMap<String, String>,
+    /// Where the name is written; `None` in graphs indexed before spans were recorded
+    #[serde(default)]
+    pub name_span: Option<Span>,
+    /// The whole declaration, from its doc comment through its last byte
+    #[serde(default)]
+    pub span: Option<Span>,
 }
 
 impl Node {
@@ -58,6 +150,7 @@ impl Node {
             node_type,
             file_path,
             line,
+            column: 0,
             end_line,
             package,
             signature,
@@ -65,7 +158,96 @@ impl Node {
             returns: Vec::new(),
             documentation: None,
             tags: Vec::new(),
-            metadata: HashMap::new(),
+            metadata: BTreeMap::new(),
+            name_span: None,
+            span: None,
+        }
+    }
+
+    /// Whether this is a named type declaration rather than a function
+    pub fn is_type(&self) -> bool {
+        matches!(
+            self.node_type,
+            NodeType::Struct | NodeType::Class | NodeType::Interface | NodeType::Type
+        )
+    }
+
+    /// The identifier as written in source: `Add` for `(*Calculator).Add`, `name` for
+    /// the field `Calculator.name`
+    pub fn identifier(&self) -> &str {
+        self.metadata
+            .get("method")
+            .or_else(|| self.metadata.get("field"))
+            .unwrap_or(&self.name)
+    }
+
+    /// Whether other packages can use it: by Go's rule, an identifier starting with an
+    /// upper-case letter, declared on an exported type for methods and fields. Function
+    /// literals have no name and are never exported.
+    pub fn is_exported(&self) -> bool {
+        let upper = |name: &str| name.chars().next().is_some_and(char::is_uppercase);
+        let owner = self
+            .metadata
+            .get("receiver_type")
+            .or_else(|| self.metadata.get("struct"));
+        !self.metadata.contains_key("enclosing")
+            && upper(self.identifier())
+            && owner.is_none_or(|owner| upper(owner))
+    }
+
+    /// The declaration's first line without its body: `func Add(a, b int) int` for both
+    /// `func Add(a, b int) int {` and a one-line `func Add(a, b int) int { return a + b }`
+    pub fn declaration(&self) -> &str {
+        let signature = self.signature.trim_end();
+        if let Some(head) = signature.strip_suffix('{') {
+            return head.trim_end();
+        }
+        if signature.ends_with('}') {
+            let mut depth = 0i32;
+            for (i, c) in signature.char_indices() {
+                match c {
+                    '(' | '[' => depth += 1,
+                    ')' | ']' => depth -= 1,
+                    '{' if depth == 0 => return signature[..i].trim_end(),
+                    _ => {}
+                }
+            }
+        }
+        signature
+    }
+
+    /// Cyclomatic complexity of a function or method body, when the parser measured it
+    pub fn complexity(&self) -> Option<usize> {
+        self.metadata.get("complexity")?.parse().ok()
+    }
+
+    /// Non-blank lines of a function or method body between its braces, when the parser
+    /// counted them
+    pub fn body_lines(&self) -> Option<usize> {
+        self.metadata.get("body_lines")?.parse().ok()
+    }
+
+    /// The root of a multi-root index the symbol lies in, e.g. `svc/a`
+    pub fn root(&self) -> Option<&str> {
+        self.metadata.get("root").map(String::as_str)
+    }
+
+    /// The package's import path when known, prefixed with the root when another root of
+    /// the index declares the same import path, e.g. `svc/a:example.com/shared/calc`
+    pub fn qualifier(&self) -> Option<String> {
+        let import_path = self.metadata.get("import_path")?;
+        match (self.root(), self.metadata.contains_key(SHARED_IMPORT_PATH)) {
+            (Some(root), true) => Some(format!("{}:{}", root, import_path)),
+            _ => Some(import_path.clone()),
+        }
+    }
+
+    /// Name prefixed with the package's [`qualifier`](Self::qualifier) when known, e.g.
+    /// `example.com/mymod/calc.Add`
+    pub fn qualified_name(&self) -> String {
+        match self.qualifier() {
+            Some(qualifier) => format!("{}.{}", qualifier, self.name),
+            None => self.name.clone(),
         }
     }
 }
@@ -0,0 +1,64 @@
+use super::{CodeGraph, Node, NodeType};
+use std::collections::{HashMap, HashSet};
+use std::path::Path;
+
+/// A declaration in a file outline with the members declared for it
+#[derive(Debug, Clone)]
+pub struct OutlineEntry<'a> {
+    pub node: &'a Node,
+    /// Fields and methods of a type, in source order; empty for anything else
+    pub children: Vec<OutlineEntry<'a>>,
+}
+
+impl CodeGraph {
+    /// The declarations of the file at `path` as a tree, each level in source order.
+    /// Struct fields, interface methods and methods with a receiver are nested under
+    /// their type wherever in the file they are declared; methods on a type declared
+    /// in another file stay at the top level. Function literals are left out.
+    pub fn outline(&self, path: &Path) -> Vec<OutlineEntry<'_>> {
+        let mut nodes: Vec<&Node> = self
+            .nodes
+            .iter()
+            .filter(|node| node.file_path == path && !node.metadata.contains_key("enclosing"))
+            .collect();
+        nodes.sort_by_key(|node| (node.line, node.column));
+
+        let types: HashSet<&str> = nodes
+            .iter()
+            .filter(|node| node.is_type())
+            .map(|node| node.name.as_str())
+            .collect();
+
+        let mut members: HashMap<&str, Vec<OutlineEntry>> = HashMap::new();
+        for &node in &nodes {
+            if let Some(owner) = owner(node, &types) {
+                members.entry(owner).or_default().push(OutlineEntry {
+                    node,
+                    children: Vec::new(),
+                });
+            }
+        }
+
+        nodes
+            .iter()
+            .filter(|node| owner(node, &types).is_none())
+            .map(|&node| OutlineEntry {
+                node,
+                children: match node.is_type() {
+                    true => members.remove(node.name.as_str()).unwrap_or_default(),
+                    false => Vec::new(),
+                },
+            })
+            .collect()
+    }
+}
+
+/// The type in `types` that `node` is a field or method of
+fn owner<'a>(node: &'a Node, types: &HashSet<&str>) -> Option<&'a str> {
+    let owner = match node.node_type {
+        NodeType::Field => node.metadata.get("struct"),
+        NodeType::Method => node.metadata.get("receiver_type"),
+        _ => None,
+    }?;
+    types.contains(owner.as_str()).then_some(owner.as_str())
+}
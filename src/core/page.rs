@@ -0,0 +1,156 @@
+use super::{CallSite, CodeGraph, ReferenceKind, SearchMatch, SearchOptions, SymbolFilter};
+
+/// Which slice of a result list to return: at most `limit` results after skipping
+/// `offset`
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct PageRequest {
+    pub offset: usize,
+    /// Every result from `offset` on when `None`
+    pub limit: Option<usize>,
+}
+
+/// One page of a result list and where it sits in the whole
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Page<T> {
+    pub items: Vec<T>,
+    /// Position of the first item in the whole list
+    pub offset: usize,
+    /// Results in the whole list
+    pub total: usize,
+}
+
+impl PageRequest {
+    pub fn new(offset: usize, limit: Option<usize>) -> Self {
+        Self { offset, limit }
+    }
+
+    /// Cut the page out of `items`, which must come in the same order for every page
+    pub fn apply<T>(&self, items: Vec<T>) -> Page<T> {
+        let mut items = items;
+        let total = items.len();
+        let offset = self.offset.min(total);
+        let end = self
+            .limit
+            .map_or(total, |limit| offset.saturating_add(limit).min(total));
+        items.truncate(end);
+        items.drain(..offset);
+        Page {
+            items,
+            offset: self.offset,
+            total,
+        }
+    }
+}
+
+impl<T> Page<T> {
+    /// Whether results follow this page
+    pub fn has_more(&self) -> bool {
+        self.offset + self.items.len() < self.total
+    }
+
+    /// The offset of the next page, if there is one
+    pub fn next_offset(&self) -> Option<usize> {
+        self.has_more().then(|| self.offset + self.items.len())
+    }
+
+    pub fn map<U>(self, f: impl FnMut(T) -> U) -> Page<U> {
+        Page {
+            items: self.items.into_iter().map(f).collect(),
+            offset: self.offset,
+            total: self.total,
+        }
+    }
+}
+
+impl CodeGraph {
+    /// A page of [`references`](Self::references), only those of `kinds` unless it is
+    /// empty, and only those made in a function `from` matches
+    pub fn references_page(
+        &self,
+        symbol: &str,
+        kinds: &[ReferenceKind],
+        from: &SymbolFilter,
+        page: &PageRequest,
+    ) -> Page<CallSite> {
+        let mut sites = self.references(symbol);
+        sites.retain(|site| kinds.is_empty() || kinds.contains(&site.kind));
+        page.apply(in_listing_order(self.sites_from(sites, from)))
+    }
+
+    /// A page of [`transitive_callers`](Self::transitive_callers), only those made in a
+    /// function `from` matches
+    pub fn callers_page(
+        &self,
+        symbol: &str,
+        max_depth: usize,
+        from: &SymbolFilter,
+        page: &PageRequest,
+    ) -> Page<CallSite> {
+        let sites = self.transitive_callers(symbol, max_depth);
+        page.apply(in_listing_order(self.sites_from(sites, from)))
+    }
+
+    /// A page of [`search`](Self::search) results in ranked order; the page's limit
+    /// replaces `options.limit`
+    pub fn search_page(
+        &self,
+        query: &str,
+        options: &SearchOptions,
+        page: &PageRequest,
+    ) -> Page<SearchMatch<'_>> {
+        let options = SearchOptions {
+            limit: None,
+            ..options.clone()
+        };
+        page.apply(self.search(query, &options))
+    }
+
+    /// Filtered before paging, so the total counts only the sites kept
+    fn sites_from(&self, mut sites: Vec<CallSite>, from: &SymbolFilter) -> Vec<CallSite> {
+        if !from.is_empty() {
+            sites.retain(|site| {
+                self.get_node_by_id(&site.caller_id)
+                    .is_some_and(|caller| from.matches(caller))
+            });
+        }
+        sites
+    }
+}
+
+/// Call sites in the order `--json` lists them, nearest first, then by file, line and
+/// column, so every page is cut from the same list
+fn in_listing_order(mut sites: Vec<CallSite>) -> Vec<CallSite> {
+    sites.sort_by(|a, b| {
+        (a.depth, a.file_path.to_string_lossy(), a.line, a.column).cmp(&(
+            b.depth,
+            b.file_path.to_string_lossy(),
+            b.line,
+            b.column,
+        ))
+    });
+    sites
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_pages_cover_the_list_once() {
+        let items: Vec<usize> = (0..7).collect();
+        let first = PageRequest::new(0, Some(3)).apply(items.clone());
+        assert_eq!((first.items.clone(), first.total), (vec![0, 1, 2], 7));
+        assert_eq!(first.next_offset(), Some(3));
+
+        let last = PageRequest::new(6, Some(3)).apply(items.clone());
+        assert_eq!(last.items, vec![6]);
+        assert!(!last.has_more());
+
+        let past = PageRequest::new(10, Some(3)).apply(items.clone());
+        assert!(past.items.is_empty());
+        assert_eq!((past.offset, past.total, past.has_more()), (10, 7, false));
+
+        let rest = PageRequest::new(2, None).apply(items);
+        assert_eq!(rest.items, vec![2, 3, 4, 5, 6]);
+    }
+}
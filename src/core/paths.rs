@@ -0,0 +1,295 @@
+use super::{CodeGraph, Edge, Node};
+use anyhow::Result;
+use std::collections::{HashMap, HashSet, VecDeque};
+use std::ops::RangeInclusive;
+
+/// Limits for [`CodeGraph::call_paths`]
+#[derive(Debug, Clone)]
+pub struct PathOptions {
+    /// Most calls a path may contain
+    pub max_depth: usize,
+    /// Enumerate every simple path instead of stopping at the shortest
+    pub all: bool,
+    /// Keep only this many of the shortest paths when enumerating
+    pub limit: Option<usize>,
+}
+
+impl Default for PathOptions {
+    fn default() -> Self {
+        Self {
+            max_depth: 10,
+            all: false,
+            limit: None,
+        }
+    }
+}
+
+/// A chain of calls from one function to another
+#[derive(Debug, Clone)]
+pub struct CallPath<'a> {
+    pub start: &'a Node,
+    /// One hop per call, in order; the last one reaches the target
+    pub hops: Vec<PathHop<'a>>,
+}
+
+#[derive(Debug, Clone, Copy)]
+pub struct PathHop<'a> {
+    /// The call expression and its position
+    pub call: &'a Edge,
+    /// The definition called; `None` when the target is outside the index, e.g. `fmt.Println`
+    pub callee: Option<&'a Node>,
+}
+
+impl CallPath<'_> {
+    /// Names along the path, starting function first, e.g. `["main", "Greet", "PrintMessage"]`
+    pub fn names(&self) -> Vec<String> {
+        std::iter::once(self.start.name.clone())
+            .chain(self.hops.iter().map(PathHop::callee_name))
+            .collect()
+    }
+}
+
+impl PathHop<'_> {
+    pub fn callee_name(&self) -> String {
+        match (self.callee, self.call.metadata.get("qualifier")) {
+            (Some(callee), _) => callee.name.clone(),
+            (None, Some(qualifier)) => format!("{}.{}", qualifier, self.call.to),
+            (None, None) => self.call.to.clone(),
+        }
+    }
+}
+
+/// What a path has to end at
+enum Target<'a> {
+    Node(&'a Node),
+    /// A symbol outside the index, matched by the name calls use for it
+    External(&'a str),
+}
+
+impl CodeGraph {
+    /// How `from` reaches `to` through calls: the shortest path found breadth-first, or with
+    /// `all` every path that visits no function twice, shortest first, and with `limit` only
+    /// that many of the shortest, ties in source order of the calls. Both symbols accept
+    /// qualified forms such as `(*Calculator).Add`; a name several definitions share is an
+    /// error listing them. A target that isn't indexed, like `Println`, is matched by name.
+    pub fn call_paths<'a>(
+        &'a self,
+        from: &str,
+        to: &'a str,
+        options: &PathOptions,
+    ) -> Result<Vec<CallPath<'a>>> {
+        let start = self.resolve_symbol(from)?;
+        let target = self.path_target(to)?;
+        Ok(self.paths_from(start, &target, options))
+    }
+
+    /// Paths to `to` from every entry point that reaches it, as listed by
+    /// [`entry_points`](Self::entry_points): with `all` every path, otherwise each entry
+    /// point's shortest. Shortest first, ties in entry point order; `limit` caps the total.
+    pub fn entry_point_paths<'a>(
+        &'a self,
+        to: &'a str,
+        options: &PathOptions,
+    ) -> Result<Vec<CallPath<'a>>> {
+        let target = self.path_target(to)?;
+        let mut paths: Vec<CallPath> = self
+            .entry_points(false)
+            .into_iter()
+            .flat_map(|package| package.entry_points)
+            .flat_map(|entry| self.paths_from(entry.node, &target, options))
+            .collect();
+        paths.sort_by_key(|path| path.hops.len());
+        if let Some(limit) = options.limit {
+            paths.truncate(limit);
+        }
+        Ok(paths)
+    }
+
+    fn path_target<'a>(&'a self, to: &'a str) -> Result<Target<'a>> {
+        Ok(match self.find_nodes_by_symbol(to).len() {
+            0 => Target::External(to),
+            _ => Target::Node(self.resolve_symbol(to)?),
+        })
+    }
+
+    fn paths_from<'a>(
+        &'a self,
+        start: &'a Node,
+        target: &Target,
+        options: &PathOptions,
+    ) -> Vec<CallPath<'a>> {
+        if !options.all {
+            return self
+                .shortest_path(start, target, options.max_depth)
+                .into_iter()
+                .collect();
+        }
+
+        let mut paths = Vec::new();
+        let mut on_path = HashSet::from([start.id.as_str()]);
+        let mut hops = Vec::new();
+        match options.limit {
+            // One length at a time, so the paths kept are the shortest rather than the
+            // first the walk happens to find
+            Some(limit) => {
+                for length in 1..=options.max_depth {
+                    self.enumerate_paths(
+                        start,
+                        target,
+                        &(length..=length),
+                        limit,
+                        &mut on_path,
+                        &mut hops,
+                        &mut paths,
+                    );
+                    if paths.len() >= limit {
+                        break;
+                    }
+                }
+            }
+            None => {
+                self.enumerate_paths(
+                    start,
+                    target,
+                    &(1..=options.max_depth),
+                    usize::MAX,
+                    &mut on_path,
+                    &mut hops,
+                    &mut paths,
+                );
+                paths.sort_by_key(|hops| hops.len());
+            }
+        }
+        paths
+            .into_iter()
+            .map(|hops| CallPath { start, hops })
+            .collect()
+    }
+
+    /// Calls out of `node` in source order, each with the definitions it may reach
+    fn calls_from<'a>(&'a self, node: &Node) -> Vec<(&'a Edge, Vec<&'a Node>)> {
+        let mut edges = self.get_outgoing_edges(&node.id);
+        edges.sort_by_key(|edge| (edge.line, edge.column));
+        edges
+            .into_iter()
+            .map(|edge| (edge, self.edge_targets(edge)))
+            .collect()
+    }
+
+    fn shortest_path<'a>(
+        &'a self,
+        start: &'a Node,
+        target: &Target,
+        max_depth: usize,
+    ) -> Option<CallPath<'a>> {
+        // node id -> the hop that first reached it
+        let mut reached: HashMap<&str, PathHop<'a>> = HashMap::new();
+        let mut visited = HashSet::from([start.id.as_str()]);
+        let mut queue = VecDeque::from([(start, 0)]);
+
+        while let Some((node, depth)) = queue.pop_front() {
+            if depth >= max_depth {
+                continue;
+            }
+            for (call, callees) in self.calls_from(node) {
+                let last = match target.reached_by(call, &callees) {
+                    Some(last) => last,
+                    None => {
+                        for callee in callees {
+                            if visited.insert(callee.id.as_str()) {
+                                reached.insert(
+                                    callee.id.as_str(),
+                                    PathHop {
+                                        call,
+                                        callee: Some(callee),
+                                    },
+                                );
+                                queue.push_back((callee, depth + 1));
+                            }
+                        }
+                        continue;
+                    }
+                };
+
+                // Walk back from the caller to the start
+                let mut hops = vec![last];
+                let mut current = node.id.as_str();
+                while let Some(hop) = reached.get(current) {
+                    hops.push(*hop);
+                    current = hop.call.from.as_str();
+                }
+                hops.reverse();
+                return Some(CallPath { start, hops });
+            }
+        }
+        None
+    }
+
+    /// Depth-first, the simple paths whose number of calls is within `lengths`
+    #[allow(clippy::too_many_arguments)]
+    fn enumerate_paths<'a>(
+        &'a self,
+        node: &'a Node,
+        target: &Target,
+        lengths: &RangeInclusive<usize>,
+        limit: usize,
+        on_path: &mut HashSet<&'a str>,
+        hops: &mut Vec<PathHop<'a>>,
+        paths: &mut Vec<Vec<PathHop<'a>>>,
+    ) {
+        let length = hops.len() + 1;
+        if length > *lengths.end() {
+            return;
+        }
+        for (call, callees) in self.calls_from(node) {
+            if paths.len() >= limit {
+                return;
+            }
+            if let Some(last) = target.reached_by(call, &callees) {
+                if lengths.contains(&length) {
+                    let mut path = hops.clone();
+                    path.push(last);
+                    paths.push(path);
+                }
+                continue;
+            }
+            for callee in callees {
+                if !on_path.insert(callee.id.as_str()) {
+                    continue;
+                }
+                hops.push(PathHop {
+                    call,
+                    callee: Some(callee),
+                });
+                self.enumerate_paths(callee, target, lengths, limit, on_path, hops, paths);
+                hops.pop();
+                on_path.remove(callee.id.as_str());
+            }
+        }
+    }
+}
+
+impl Target<'_> {
+    /// The final hop when `call` ends the path
+    fn reached_by<'a>(&self, call: &'a Edge, callees: &[&'a Node]) -> Option<PathHop<'a>> {
+        match self {
+            Target::Node(target) => {
+                callees
+                    .iter()
+                    .find(|callee| callee.id == target.id)
+                    .map(|&callee| PathHop {
+                        call,
+                        callee: Some(callee),
+                    })
+            }
+            Target::External(name) => {
+                let qualified = call
+                    .metadata
+                    .get("qualifier")
+                    .map(|qualifier| format!("{}.{}", qualifier, call.to));
+                (callees.is_empty() && (call.to == *name || qualified.as_deref() == Some(*name)))
+                    .then_some(PathHop { call, callee: None })
+            }
+        }
+    }
+}
@@ -0,0 +1,204 @@
+use super::{CodeGraph, Edge, EdgeType, Node, NodeType};
+use anyhow::{bail, Result};
+use std::collections::HashMap;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// Replace `old_text` with `new_text` at a 1-based line and column
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct RenameEdit {
+    pub file_path: PathBuf,
+    pub line: usize,
+    /// 1-based column of the first character of `old_text`
+    pub column: usize,
+    pub old_text: String,
+    pub new_text: String,
+}
+
+/// An existing symbol the new name would collide with
+#[derive(Debug, Clone)]
+pub struct RenameConflict<'a> {
+    pub existing: &'a Node,
+    /// Where the two names clash, e.g. `package main` or `type Calculator`
+    pub scope: String,
+}
+
+/// Everything a rename would touch, computed without writing anything
+#[derive(Debug, Clone)]
+pub struct RenamePlan<'a> {
+    pub symbol: &'a Node,
+    pub new_name: String,
+    /// The definition first, then every call and reference, sorted by file and position
+    pub edits: Vec<RenameEdit>,
+    pub conflicts: Vec<RenameConflict<'a>>,
+}
+
+impl RenamePlan<'_> {
+    /// Edits grouped by file, in file order
+    pub fn files(&self) -> Vec<(&Path, Vec<&RenameEdit>)> {
+        let mut files: Vec<(&Path, Vec<&RenameEdit>)> = Vec::new();
+        for edit in &self.edits {
+            match files.last_mut() {
+                Some((path, edits)) if *path == edit.file_path.as_path() => edits.push(edit),
+                _ => files.push((edit.file_path.as_path(), vec![edit])),
+            }
+        }
+        files
+    }
+}
+
+impl CodeGraph {
+    /// Plan renaming `symbol` to `new_name`: its definition plus every call and reference
+    /// the index resolved to it, and the symbols already using `new_name` in the same
+    /// scope. Source files are read to locate each name exactly; sites whose text no
+    /// longer matches the index, such as calls through a local (`op := Add; op()`),
+    /// are left out.
+    pub fn plan_rename(&self, symbol: &str, new_name: &str) -> Result<RenamePlan<'_>> {
+        if !is_identifier(new_name) {
+            bail!("Invalid name: {}", new_name);
+        }
+        let node = self.resolve_symbol(symbol)?;
+        // Type names appear in signatures and declarations the index doesn't record
+        if node.is_type() {
+            bail!(
+                "Renaming types is not supported: {} is used in places the index doesn't track",
+                node.name
+            );
+        }
+        // Function literals are named after their position, not in source
+        if let Some(enclosing) = node.metadata.get("enclosing") {
+            bail!(
+                "{} is a function literal in {} and has no name to rename",
+                node.name,
+                enclosing
+            );
+        }
+        let old_name = node.identifier();
+
+        let mut sources = SourceCache::default();
+        let mut edits = Vec::new();
+        let mut push = |path: &Path, line: usize, column: usize, exact: bool| {
+            let column = match exact {
+                true => Some(column),
+                false => sources.find(path, line, column, old_name),
+            };
+            if let Some(column) = column {
+                edits.push(RenameEdit {
+                    file_path: path.to_path_buf(),
+                    line,
+                    column,
+                    old_text: old_name.to_string(),
+                    new_text: new_name.to_string(),
+                });
+            }
+        };
+
+        push(&node.file_path, node.line, node.column, node.column > 0);
+        for edge in self.edges.iter().chain(&self.references) {
+            if !self.refers_to(edge, node) || edge.metadata.contains_key("via") {
+                continue;
+            }
+            // Bare identifiers start at the name; selectors and calls start at their
+            // operand, and `pkg.Name` skips the package so `add.add()` finds the second
+            let qualifier = edge.metadata.get("qualifier").map_or(0, String::len);
+            let exact = edge.edge_type == EdgeType::References
+                && qualifier == 0
+                && !edge.metadata.contains_key("receiver_type");
+            push(&edge.file_path, edge.line, edge.column + qualifier, exact);
+        }
+        edits.sort_by(|a, b| {
+            (&a.file_path, a.line, a.column).cmp(&(&b.file_path, b.line, b.column))
+        });
+        edits.dedup();
+
+        Ok(RenamePlan {
+            symbol: node,
+            new_name: new_name.to_string(),
+            edits,
+            conflicts: self.rename_conflicts(node, new_name),
+        })
+    }
+
+    fn refers_to(&self, edge: &Edge, node: &Node) -> bool {
+        match edge.edge_type {
+            EdgeType::References => edge.metadata.get("target_id") == Some(&node.id),
+            _ => self
+                .edge_targets(edge)
+                .iter()
+                .any(|target| target.id == node.id),
+        }
+    }
+
+    /// Symbols named `new_name` in the scope `node` is declared in: the package for
+    /// top-level declarations, the type for methods and fields
+    fn rename_conflicts<'a>(&'a self, node: &Node, new_name: &str) -> Vec<RenameConflict<'a>> {
+        let package_dir = node.file_path.parent();
+        let owner = member_owner(node);
+        let mut conflicts: Vec<RenameConflict> = self
+            .nodes
+            .iter()
+            .filter(|other| other.id != node.id && other.file_path.parent() == package_dir)
+            .filter(|other| member_owner(other) == owner && other.identifier() == new_name)
+            .map(|existing| RenameConflict {
+                existing,
+                scope: match owner {
+                    Some(owner) => format!("type {}", owner),
+                    None => format!("package {}", node.package),
+                },
+            })
+            .collect();
+        conflicts.sort_by(|a, b| {
+            (&a.existing.file_path, a.existing.line).cmp(&(&b.existing.file_path, b.existing.line))
+        });
+        conflicts
+    }
+}
+
+/// The type a method or field belongs to; `None` for package-level symbols
+fn member_owner(node: &Node) -> Option<&str> {
+    match node.node_type {
+        NodeType::Field => node.metadata.get("struct"),
+        _ => node.metadata.get("receiver_type"),
+    }
+    .map(String::as_str)
+}
+
+fn is_identifier(name: &str) -> bool {
+    let mut chars = name.chars();
+    chars.next().is_some_and(|c| c.is_alphabetic() || c == '_')
+        && chars.all(|c| c.is_alphanumeric() || c == '_')
+}
+
+/// Source lines of the files a plan touches, read once each
+#[derive(Default)]
+struct SourceCache {
+    files: HashMap<PathBuf, Option<Vec<String>>>,
+}
+
+impl SourceCache {
+    /// 1-based column of the first whole-word `name` at or after `column` on `line`
+    fn find(&mut self, path: &Path, line: usize, column: usize, name: &str) -> Option<usize> {
+        let lines = self
+            .files
+            .entry(path.to_path_buf())
+            .or_insert_with(|| {
+                fs::read_to_string(path)
+                    .ok()
+                    .map(|source| source.lines().map(str::to_string).collect())
+            })
+            .as_ref()?;
+        let text = lines.get(line.checked_sub(1)?)?;
+        let start = column.saturating_sub(1).min(text.len());
+
+        let is_word = |c: char| c.is_alphanumeric() || c == '_';
+        text.get(start..)?
+            .match_indices(name)
+            .map(|(offset, _)| start + offset)
+            .find(|&at| {
+                let before = text[..at].chars().next_back();
+                let after = text[at + name.len()..].chars().next();
+                !before.is_some_and(is_word) && !after.is_some_and(is_word)
+            })
+            .map(|at| at + 1)
+    }
+}
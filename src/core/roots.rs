@@ -0,0 +1,153 @@
+//! Indexes spanning several roots, such as the modules of a Go workspace.
+//!
+//! Each root is parsed into a graph of its own, with its own per-file cache, and
+//! [`CodeGraph::merge_roots`] combines them. Calls from one root into another are left
+//! for the language's resolver to bind over the combined graph.
+
+use super::{CodeGraph, Node};
+use crate::serializer::file_cache::CacheStats;
+use std::collections::{BTreeSet, HashMap};
+use std::path::{Component, Path, PathBuf};
+
+/// Metadata key marking a symbol whose package import path another root of the index
+/// declares too; its [`Node::qualifier`] and ID then name the root
+pub const SHARED_IMPORT_PATH: &str = "shared_import_path";
+
+/// How `root` is named in an index of `dir`: relative to `dir` where it lies inside,
+/// without `./` or a trailing `/`, e.g. `svc/a`; `.` for `dir` itself
+pub fn root_label(dir: &Path, root: &Path) -> String {
+    let relative = root.strip_prefix(dir).unwrap_or(root);
+    let parts: Vec<String> = relative
+        .components()
+        .filter(|part| !matches!(part, Component::CurDir))
+        .map(|part| part.as_os_str().to_string_lossy().to_string())
+        .collect();
+    match parts.is_empty() {
+        true => ".".to_string(),
+        false => parts.join("/"),
+    }
+}
+
+impl CodeGraph {
+    /// Merge the graphs of `roots`, each named by its [`root_label`], into this one.
+    /// Every symbol records its root under the `root` metadata key, and symbols of an
+    /// import path more than one root declares are marked [`SHARED_IMPORT_PATH`] and
+    /// given IDs naming their root before merging, so they can't collide. Imports of a
+    /// package in any of the roots count as internal. File and cache counts are summed.
+    pub fn merge_roots(&mut self, roots: Vec<(String, CodeGraph)>) {
+        let mut declared_in: HashMap<String, BTreeSet<String>> = HashMap::new();
+        for (label, graph) in &roots {
+            for import_path in graph
+                .nodes
+                .iter()
+                .filter_map(|node| node.metadata.get("import_path"))
+            {
+                declared_in
+                    .entry(import_path.clone())
+                    .or_default()
+                    .insert(label.clone());
+            }
+        }
+
+        let (mut files_parsed, mut cache) = (0, None::<CacheStats>);
+        for (label, mut graph) in roots {
+            for node in &mut graph.nodes {
+                mark_root(node, &label, &declared_in);
+            }
+            graph.assign_stable_ids();
+
+            files_parsed += graph.metadata.stats.files_parsed;
+            if let Some(stats) = graph.metadata.stats.cache {
+                let total = cache.get_or_insert_with(CacheStats::default);
+                total.hits += stats.hits;
+                total.misses += stats.misses;
+            }
+            self.metadata.roots.push(label);
+            self.merge(graph);
+        }
+
+        for import in &mut self.imports {
+            import.internal |= declared_in.contains_key(&import.path);
+        }
+        self.metadata.stats.files_parsed = files_parsed;
+        self.metadata.stats.total_nodes = self.nodes.len();
+        self.metadata.stats.total_edges = self.edges.len();
+        self.metadata.stats.cache = cache;
+        self.build_indexes();
+    }
+
+    /// Record the root of every symbol again, after files were re-parsed into a graph
+    /// made by [`merge_roots`](Self::merge_roots), and re-assign the IDs of any symbol
+    /// whose package turns out to be shared. Does nothing for a single-root index.
+    pub fn assign_roots(&mut self) {
+        if self.metadata.roots.is_empty() {
+            return;
+        }
+        let base = PathBuf::from(&self.metadata.root_path);
+        let mut dirs: Vec<(PathBuf, String)> = self
+            .metadata
+            .roots
+            .iter()
+            .map(|label| (base.join(label), label.clone()))
+            .collect();
+        // Nested roots claim their files before the roots around them
+        dirs.sort_by_key(|(dir, _)| std::cmp::Reverse(dir.components().count()));
+
+        let mut labels = Vec::with_capacity(self.nodes.len());
+        let mut declared_in: HashMap<String, BTreeSet<String>> = HashMap::new();
+        for node in &self.nodes {
+            let label = dirs
+                .iter()
+                .find(|(dir, _)| node.file_path.starts_with(dir))
+                .map(|(_, label)| label.clone());
+            if let (Some(label), Some(import_path)) = (&label, node.metadata.get("import_path")) {
+                declared_in
+                    .entry(import_path.clone())
+                    .or_default()
+                    .insert(label.clone());
+            }
+            labels.push(label);
+        }
+        for (node, label) in self.nodes.iter_mut().zip(labels) {
+            match label {
+                Some(label) => mark_root(node, &label, &declared_in),
+                None => {
+                    node.metadata.remove("root");
+                    node.metadata.remove(SHARED_IMPORT_PATH);
+                }
+            }
+        }
+        self.assign_stable_ids();
+    }
+}
+
+/// Record `label` as `node`'s root, marking it when another root declares its package
+fn mark_root(node: &mut Node, label: &str, declared_in: &HashMap<String, BTreeSet<String>>) {
+    node.metadata.insert("root".to_string(), label.to_string());
+    let shared = node
+        .metadata
+        .get("import_path")
+        .and_then(|import_path| declared_in.get(import_path))
+        .is_some_and(|labels| labels.len() > 1);
+    match shared {
+        true => node
+            .metadata
+            .insert(SHARED_IMPORT_PATH.to_string(), "true".to_string()),
+        false => node.metadata.remove(SHARED_IMPORT_PATH),
+    };
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_root_label() {
+        let dir = Path::new("monorepo");
+        assert_eq!(root_label(dir, Path::new("monorepo/svc/a")), "svc/a");
+        assert_eq!(root_label(dir, Path::new("monorepo/./svc/b/")), "svc/b");
+        assert_eq!(root_label(dir, Path::new("monorepo")), ".");
+        assert_eq!(root_label(Path::new("."), Path::new("./svc/a")), "svc/a");
+        assert_eq!(root_label(dir, Path::new("../shared")), "../shared");
+    }
+}
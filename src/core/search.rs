@@ -0,0 +1,138 @@
+use super::{CodeGraph, Node, NodeType, SymbolFilter};
+use serde::{Deserialize, Serialize};
+
+/// Filters for [`CodeGraph::search`]
+#[derive(Debug, Clone, Default)]
+pub struct SearchOptions {
+    /// Only return symbols of these kinds; empty means every kind
+    pub kinds: Vec<NodeType>,
+    /// Maximum number of results
+    pub limit: Option<usize>,
+    /// Only return symbols matching this filter
+    pub filter: SymbolFilter,
+}
+
+/// How a symbol matched the query, best first
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum MatchKind {
+    Exact,
+    Prefix,
+    Substring,
+    /// Every query character appears in order, e.g. `prmsg` in `PrintMessage`
+    Fuzzy,
+}
+
+#[derive(Debug, Clone)]
+pub struct SearchMatch<'a> {
+    pub node: &'a Node,
+    pub match_kind: MatchKind,
+    /// Relevance from 1000 (exact) down to 250. Each match kind has its own band,
+    /// and within a band shorter names and tighter matches score higher.
+    pub score: u32,
+}
+
+impl CodeGraph {
+    /// Case-insensitive symbol search. Results are ranked exact > prefix > substring >
+    /// fuzzy, then by score, with ties broken by name. Methods also match on
+    /// `Type.Method` and their bare name, so `calc add` finds `(*Calculator).Add`;
+    /// fields match on their bare name too.
+    pub fn search(&self, query: &str, options: &SearchOptions) -> Vec<SearchMatch<'_>> {
+        let query = query.trim().to_lowercase();
+        // Words are matched as one run of characters; symbol names contain no spaces
+        let compact: String = query.split_whitespace().collect();
+        if compact.is_empty() {
+            return Vec::new();
+        }
+
+        let mut matches: Vec<SearchMatch> = self
+            .nodes
+            .iter()
+            .filter(|node| options.kinds.is_empty() || options.kinds.contains(&node.node_type))
+            .filter(|node| options.filter.matches(node))
+            .filter_map(|node| {
+                search_names(node)
+                    .iter()
+                    .filter_map(|name| match_name(&query, &compact, name))
+                    .min_by(|a, b| a.0.cmp(&b.0).then(b.1.cmp(&a.1)))
+                    .map(|(match_kind, score)| SearchMatch {
+                        node,
+                        match_kind,
+                        score,
+                    })
+            })
+            .collect();
+
+        matches.sort_by(|a, b| {
+            a.match_kind
+                .cmp(&b.match_kind)
+                .then(b.score.cmp(&a.score))
+                .then_with(|| a.node.name.cmp(&b.node.name))
+                .then_with(|| {
+                    (&a.node.file_path, a.node.line).cmp(&(&b.node.file_path, b.node.line))
+                })
+        });
+        if let Some(limit) = options.limit {
+            matches.truncate(limit);
+        }
+        matches
+    }
+}
+
+/// Lowercased names a node can be found by
+fn search_names(node: &Node) -> Vec<String> {
+    let mut names = vec![node.name.to_lowercase()];
+    if let Some(method) = node.metadata.get("method") {
+        if let Some(receiver_type) = node.metadata.get("receiver_type") {
+            names.push(format!("{}.{}", receiver_type, method).to_lowercase());
+        }
+        names.push(method.to_lowercase());
+    }
+    // Fields are already named `Struct.field`
+    if let Some(field) = node.metadata.get("field") {
+        names.push(field.to_lowercase());
+    }
+    names
+}
+
+fn match_name(query: &str, compact: &str, name: &str) -> Option<(MatchKind, u32)> {
+    let query_len = query.chars().count();
+    let extra = name.chars().count().saturating_sub(query_len);
+
+    if name == query {
+        return Some((MatchKind::Exact, 1000));
+    }
+    if name.starts_with(query) {
+        return Some((MatchKind::Prefix, 999 - penalty(extra)));
+    }
+    if let Some(pos) = name.find(query) {
+        let offset = name[..pos].chars().count();
+        return Some((MatchKind::Substring, 749 - penalty(extra + offset)));
+    }
+
+    let (start, end) = subsequence_span(compact, name)?;
+    let gaps = end - start + 1 - compact.chars().count();
+    Some((MatchKind::Fuzzy, 499 - penalty(gaps + start)))
+}
+
+/// Keep penalties inside a match kind's band of 250
+fn penalty(n: usize) -> u32 {
+    n.min(249) as u32
+}
+
+/// Char positions of the first and last query character when `query` is matched
+/// greedily as a subsequence of `name`
+fn subsequence_span(query: &str, name: &str) -> Option<(usize, usize)> {
+    let mut wanted = query.chars().peekable();
+    let mut start = None;
+    for (i, c) in name.chars().enumerate() {
+        if wanted.peek() == Some(&c) {
+            wanted.next();
+            start.get_or_insert(i);
+            if wanted.peek().is_none() {
+                return start.map(|start| (start, i));
+            }
+        }
+    }
+    None
+}
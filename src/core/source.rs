@@ -0,0 +1,98 @@
+use super::{CodeGraph, Node, Span};
+use crate::serializer::file_cache::content_hash;
+use anyhow::{bail, Context, Result};
+use std::fs;
+
+/// A symbol's declaration read back from its file, as returned by [`CodeGraph::source`]
+#[derive(Debug, Clone)]
+pub struct SourceSnippet<'a> {
+    pub node: &'a Node,
+    /// The file's bytes in `range`: the declaration from its doc comment through the
+    /// closing brace
+    pub text: String,
+    pub range: Span,
+    /// Context before `text`, from the start of its first line up to `range.start`
+    pub before: String,
+    /// Context after `text`, from `range.end` to the end of its last line
+    pub after: String,
+    /// 1-based line `before` starts on; with no context, the line `text` starts on
+    pub start_line: usize,
+}
+
+impl SourceSnippet<'_> {
+    /// `before`, `text` and `after` as one string, starting on `start_line`
+    pub fn with_context(&self) -> String {
+        format!("{}{}{}", self.before, self.text, self.after)
+    }
+}
+
+impl CodeGraph {
+    /// The source of `symbol`, cut from its file at the range recorded when it was
+    /// indexed, plus `context` whole lines on either side. Ambiguous names fail with
+    /// the candidates, as in [`resolve_symbol`](Self::resolve_symbol); so does a file
+    /// edited since indexing, since the range would no longer cover the declaration.
+    pub fn source(&self, symbol: &str, context: usize) -> Result<SourceSnippet<'_>> {
+        let node = self.resolve_symbol(symbol)?;
+        let Some(range) = node.span else {
+            bail!(
+                "The index has no source range for {}; re-index to record it",
+                node.name
+            );
+        };
+        let source = fs::read_to_string(&node.file_path)
+            .with_context(|| format!("Failed to read {}", node.file_path.display()))?;
+        let edited = self
+            .metadata
+            .file_hashes
+            .get(node.file_path.to_string_lossy().as_ref())
+            .is_some_and(|hash| *hash != content_hash(source.as_bytes()));
+
+        // Without a hash, from an older index, the recorded name must still be where
+        // the index put it
+        let name = node
+            .name_span
+            .and_then(|name| source.get(name.start.offset..name.end.offset));
+        let moved = match name {
+            Some(name) => !node.metadata.contains_key("enclosing") && !node.name.ends_with(name),
+            None => node.name_span.is_some(),
+        };
+        let text = source.get(range.start.offset..range.end.offset);
+        let (Some(text), false) = (text, edited || moved) else {
+            bail!(
+                "{} has changed since {} was indexed; re-index it",
+                node.file_path.display(),
+                node.name
+            );
+        };
+
+        let line_start = |offset: usize| source[..offset].rfind('\n').map_or(0, |i| i + 1);
+        let line_end = |offset: usize| {
+            source[offset..]
+                .find('\n')
+                .map_or(source.len(), |i| offset + i)
+        };
+        let (mut begin, mut end) = (range.start.offset, range.end.offset);
+        if context > 0 {
+            begin = line_start(begin);
+            end = line_end(end);
+            for _ in 1..=context {
+                if begin > 0 {
+                    begin = line_start(begin - 1);
+                }
+                if end + 1 < source.len() {
+                    end = line_end(end + 1);
+                }
+            }
+        }
+        let before = source[begin..range.start.offset].to_string();
+
+        Ok(SourceSnippet {
+            node,
+            text: text.to_string(),
+            range,
+            start_line: range.start.line - before.matches('\n').count(),
+            before,
+            after: source[range.end.offset..end].to_string(),
+        })
+    }
+}
@@ -0,0 +1,127 @@
+use super::{CodeGraph, EdgeKind, EdgeType, NodeType};
+use crate::serializer::file_cache::CacheStats;
+use std::collections::{BTreeMap, HashSet};
+use std::path::Path;
+
+/// Call edges by how they were found and how they run
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct CallCounts {
+    /// `direct + indirect + dynamic`
+    pub total: usize,
+    /// Calls as written, resolved or not
+    pub direct: usize,
+    /// A function value or parameter called, one edge per function it may hold
+    pub indirect: usize,
+    /// An interface method called, one edge per implementation
+    pub dynamic: usize,
+    /// Of all of them, calls launched with `go`
+    pub go: usize,
+    /// Of all of them, deferred calls
+    pub defer: usize,
+}
+
+/// What an index holds, as returned by [`CodeGraph::index_stats`]
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct IndexStats {
+    /// Files the graph holds symbols from, the whole index after an incremental update
+    /// too; `GraphStats::files_parsed` is the number that run re-parsed
+    pub files: usize,
+    /// Distinct package declarations by directory, so `foo_test` counts apart from `foo`
+    pub packages: usize,
+    /// Functions, HTTP handlers, middleware, benchmarks and fuzz targets, function literals
+    /// aside
+    pub functions: usize,
+    /// Methods, interface methods included
+    pub methods: usize,
+    /// Structs, classes, interfaces and other named types
+    pub types: usize,
+    pub calls: CallCounts,
+    /// Direct calls bound to no indexed symbol, e.g. `fmt.Println`; calls of a parameter
+    /// are left out, as their candidates are the indirect calls
+    pub unresolved_calls: usize,
+    /// Diagnostics recorded while indexing, by code
+    pub diagnostics: BTreeMap<String, usize>,
+    pub index_duration_ms: Option<u64>,
+    pub cache: Option<CacheStats>,
+}
+
+impl IndexStats {
+    /// Share of files served from the cache, from 0 to 1
+    pub fn cache_hit_rate(&self) -> Option<f64> {
+        self.cache
+            .filter(|cache| cache.hits + cache.misses > 0)
+            .map(|cache| cache.hits as f64 / (cache.hits + cache.misses) as f64)
+    }
+}
+
+impl CodeGraph {
+    /// Source files the graph holds symbols from, whichever run parsed them
+    pub fn indexed_files(&self) -> usize {
+        let files: HashSet<&Path> = self.nodes.iter().map(|n| n.file_path.as_path()).collect();
+        files.len()
+    }
+
+    /// Counts of what the graph holds, with the build time and cache use its `index` run
+    /// recorded
+    pub fn index_stats(&self) -> IndexStats {
+        let mut stats = IndexStats {
+            files: self.indexed_files(),
+            index_duration_ms: self.metadata.stats.index_duration_ms,
+            cache: self.metadata.stats.cache,
+            ..Default::default()
+        };
+
+        let mut packages: HashSet<(&Path, &str)> = HashSet::new();
+        for node in &self.nodes {
+            packages.insert((
+                node.file_path.parent().unwrap_or(Path::new("")),
+                node.package.as_str(),
+            ));
+            match node.node_type {
+                NodeType::Function
+                | NodeType::HttpHandler
+                | NodeType::Middleware
+                | NodeType::Benchmark
+                | NodeType::Fuzz
+                    if !node.metadata.contains_key("enclosing") =>
+                {
+                    stats.functions += 1
+                }
+                NodeType::Method => stats.methods += 1,
+                NodeType::Struct | NodeType::Class | NodeType::Interface | NodeType::Type => {
+                    stats.types += 1
+                }
+                _ => {}
+            }
+        }
+        stats.packages = packages.len();
+
+        for edge in self.edges.iter().filter(|e| e.edge_type == EdgeType::Calls) {
+            let calls = &mut stats.calls;
+            calls.total += 1;
+            if edge.is_indirect() {
+                calls.indirect += 1;
+            } else if edge.is_dynamic() {
+                calls.dynamic += 1;
+            } else {
+                calls.direct += 1;
+                if !edge.calls_through_value() && self.edge_targets(edge).is_empty() {
+                    stats.unresolved_calls += 1;
+                }
+            }
+            match edge.kind() {
+                EdgeKind::Go => calls.go += 1,
+                EdgeKind::Defer => calls.defer += 1,
+                EdgeKind::Normal => {}
+            }
+        }
+
+        for diagnostic in &self.metadata.diagnostics {
+            *stats
+                .diagnostics
+                .entry(diagnostic.code.clone())
+                .or_default() += 1;
+        }
+        stats
+    }
+}
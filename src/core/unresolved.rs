@@ -0,0 +1,262 @@
+use super::{CodeGraph, Edge, EdgeType};
+use serde::{Deserialize, Serialize};
+use std::cmp::Reverse;
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::Path;
+use std::str::FromStr;
+
+/// Why a call or reference is bound to no indexed symbol
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum UnresolvedReason {
+    /// Through an import of a package outside the index, e.g. `fmt.Println`, or a method
+    /// of a type from one
+    ExternalPackage,
+    /// Declared only in files the build constraints leave out
+    BuildExcluded,
+    /// A method or function field of a value whose type isn't known, as with
+    /// reflection-style calls such as `m.Call(args)`
+    UnknownReceiver,
+    /// Declared more than once in the package, or promoted from two embedded fields
+    Ambiguous,
+    /// A predeclared function such as `len`, or a conversion to a predeclared type
+    /// such as `int(x)`
+    Builtin,
+    /// Nothing indexed declares the name
+    Undefined,
+}
+
+impl UnresolvedReason {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            UnresolvedReason::ExternalPackage => "external_package",
+            UnresolvedReason::BuildExcluded => "build_excluded",
+            UnresolvedReason::UnknownReceiver => "unknown_receiver",
+            UnresolvedReason::Ambiguous => "ambiguous",
+            UnresolvedReason::Builtin => "builtin",
+            UnresolvedReason::Undefined => "undefined",
+        }
+    }
+
+    /// How the reason reads in a report heading
+    pub fn label(&self) -> &'static str {
+        match self {
+            UnresolvedReason::ExternalPackage => "external package",
+            UnresolvedReason::BuildExcluded => "build-excluded",
+            UnresolvedReason::UnknownReceiver => "unknown receiver",
+            UnresolvedReason::Ambiguous => "ambiguous",
+            UnresolvedReason::Builtin => "builtin",
+            UnresolvedReason::Undefined => "undefined",
+        }
+    }
+}
+
+impl FromStr for UnresolvedReason {
+    type Err = anyhow::Error;
+
+    /// Parse a reason as written on the command line; dashes may stand for underscores
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.replace('-', "_").as_str() {
+            "external_package" => Ok(UnresolvedReason::ExternalPackage),
+            "build_excluded" => Ok(UnresolvedReason::BuildExcluded),
+            "unknown_receiver" => Ok(UnresolvedReason::UnknownReceiver),
+            "ambiguous" => Ok(UnresolvedReason::Ambiguous),
+            "builtin" => Ok(UnresolvedReason::Builtin),
+            "undefined" => Ok(UnresolvedReason::Undefined),
+            _ => anyhow::bail!("Unknown unresolved reason: {}", s),
+        }
+    }
+}
+
+/// Every unresolved use of one name, e.g. all calls of `fmt.Println`
+#[derive(Debug, Clone)]
+pub struct UnresolvedName<'a> {
+    /// The name as written, qualified: `fmt.Println`, `Calculator.Reset`, `len`
+    pub name: String,
+    /// The calls and references, by file and position
+    pub uses: Vec<&'a Edge>,
+}
+
+/// The unresolved names sharing a reason, as returned by [`CodeGraph::unresolved`]
+#[derive(Debug, Clone)]
+pub struct UnresolvedBucket<'a> {
+    pub reason: UnresolvedReason,
+    /// Most used first, then by name
+    pub names: Vec<UnresolvedName<'a>>,
+}
+
+impl UnresolvedBucket<'_> {
+    /// Uses across every name in the bucket
+    pub fn count(&self) -> usize {
+        self.names.iter().map(|name| name.uses.len()).sum()
+    }
+}
+
+impl CodeGraph {
+    /// The calls and references bound to no indexed symbol, grouped by why, in
+    /// [`UnresolvedReason`] order. Possible calls through parameters and interfaces
+    /// are left out, like the parameter calls they stand for; the calls counted as
+    /// unresolved by [`index_stats`](Self::index_stats) are the ones listed here.
+    pub fn unresolved(&self) -> Vec<UnresolvedBucket<'_>> {
+        let indexed_packages: HashSet<&str> = self
+            .nodes
+            .iter()
+            .filter_map(|node| node.metadata.get("import_path"))
+            .map(String::as_str)
+            .collect();
+        // How many symbols each package declares under a name, to tell ambiguity apart
+        let mut declared: HashMap<(&Path, String), usize> = HashMap::new();
+        for node in &self.nodes {
+            let key = match (
+                node.metadata.get("receiver_type"),
+                node.metadata.get("method"),
+            ) {
+                (Some(receiver_type), Some(method)) => format!("{}.{}", receiver_type, method),
+                _ => node.name.clone(),
+            };
+            *declared
+                .entry((node.file_path.parent().unwrap_or(Path::new("")), key))
+                .or_default() += 1;
+        }
+        let excluded: HashSet<(&Path, &str)> = self
+            .metadata
+            .build_excluded
+            .iter()
+            .flat_map(|(file, symbols)| {
+                let package_dir = Path::new(file).parent().unwrap_or(Path::new(""));
+                symbols.iter().map(move |name| (package_dir, name.as_str()))
+            })
+            .collect();
+        let ambiguous_sites: HashSet<(&Path, usize)> = self
+            .metadata
+            .diagnostics
+            .iter()
+            .filter(|d| d.code == "ambiguous-selector")
+            .map(|d| (d.file_path.as_path(), d.line))
+            .collect();
+
+        let mut buckets: BTreeMap<UnresolvedReason, BTreeMap<String, Vec<&Edge>>> = BTreeMap::new();
+        let calls = self
+            .edges
+            .iter()
+            .filter(|edge| edge.edge_type == EdgeType::Calls);
+        for edge in calls.chain(&self.references) {
+            if edge.is_indirect()
+                || edge.is_dynamic()
+                || edge.calls_through_value()
+                || !self.edge_targets(edge).is_empty()
+            {
+                continue;
+            }
+
+            let package_dir = edge.file_path.parent().unwrap_or(Path::new(""));
+            let qualifier = edge.metadata.get("qualifier");
+            let import_path = edge.metadata.get("import_path");
+            let selector = edge
+                .metadata
+                .get("receiver_type")
+                .zip(edge.metadata.get("method"));
+            let (name, key) = match (qualifier, selector) {
+                (Some(qualifier), _) => (format!("{}.{}", qualifier, edge.to), edge.to.clone()),
+                (None, Some((receiver_type, method))) => {
+                    let key = format!("{}.{}", receiver_type, method);
+                    (key.clone(), key)
+                }
+                (None, None) => (edge.to.clone(), edge.to.clone()),
+            };
+            let is_go = edge.file_path.extension().is_some_and(|ext| ext == "go");
+
+            let reason = if let Some(import_path) = import_path {
+                match indexed_packages.contains(import_path.as_str()) {
+                    true => UnresolvedReason::Undefined,
+                    false => UnresolvedReason::ExternalPackage,
+                }
+            } else if excluded.contains(&(package_dir, key.as_str())) {
+                UnresolvedReason::BuildExcluded
+            } else if ambiguous_sites.contains(&(edge.file_path.as_path(), edge.line))
+                || declared
+                    .get(&(package_dir, key.clone()))
+                    .is_some_and(|&n| n > 1)
+            {
+                UnresolvedReason::Ambiguous
+            } else if selector.is_some_and(|(receiver_type, _)| receiver_type.contains('.')) {
+                // A local of type bytes.Buffer, say
+                UnresolvedReason::ExternalPackage
+            } else if qualifier.is_some() || (selector.is_none() && edge.to.contains('.')) {
+                UnresolvedReason::UnknownReceiver
+            } else if is_go && selector.is_none() && GO_BUILTINS.contains(&edge.to.as_str()) {
+                UnresolvedReason::Builtin
+            } else {
+                UnresolvedReason::Undefined
+            };
+            buckets
+                .entry(reason)
+                .or_default()
+                .entry(name)
+                .or_default()
+                .push(edge);
+        }
+
+        buckets
+            .into_iter()
+            .map(|(reason, names)| {
+                let mut names: Vec<UnresolvedName> = names
+                    .into_iter()
+                    .map(|(name, mut uses)| {
+                        uses.sort_by(|a, b| {
+                            (&a.file_path, a.line, a.column).cmp(&(&b.file_path, b.line, b.column))
+                        });
+                        UnresolvedName { name, uses }
+                    })
+                    .collect();
+                // Stable for equal counts, so ties stay by name
+                names.sort_by_key(|name| Reverse(name.uses.len()));
+                UnresolvedBucket { reason, names }
+            })
+            .collect()
+    }
+}
+
+/// Go's predeclared functions and the predeclared types a call converts to, which no
+/// package declares
+const GO_BUILTINS: &[&str] = &[
+    "append",
+    "cap",
+    "clear",
+    "close",
+    "complex",
+    "copy",
+    "delete",
+    "imag",
+    "len",
+    "make",
+    "max",
+    "min",
+    "new",
+    "panic",
+    "print",
+    "println",
+    "real",
+    "recover",
+    "any",
+    "bool",
+    "byte",
+    "complex64",
+    "complex128",
+    "error",
+    "float32",
+    "float64",
+    "int",
+    "int8",
+    "int16",
+    "int32",
+    "int64",
+    "rune",
+    "string",
+    "uint",
+    "uint8",
+    "uint16",
+    "uint32",
+    "uint64",
+    "uintptr",
+];
@@ -0,0 +1,127 @@
+use super::{CodeGraph, Edge, EdgeType, Node};
+use std::collections::{HashSet, VecDeque};
+
+/// Metadata key of a call that stands for a chain of calls through unexported
+/// functions, naming them in call order, comma-separated
+pub const HIDDEN_VIA: &str = "hidden_via";
+
+impl CodeGraph {
+    /// Keep only what other packages can use: unexported Go symbols are dropped, and
+    /// each call into an unexported function is replaced by calls to the exported
+    /// functions, and code outside the index, it goes on to reach. Such a call is made
+    /// at the original call site and names the functions it passed through in
+    /// [`HIDDEN_VIA`], so everything reachable before stays reachable. Symbols of other
+    /// languages are kept.
+    pub fn hide_unexported(&mut self) {
+        let hidden: HashSet<String> = self
+            .nodes
+            .iter()
+            .filter(|node| is_hidden(node))
+            .map(|node| node.id.clone())
+            .collect();
+        if hidden.is_empty() {
+            return;
+        }
+
+        let mut edges = Vec::new();
+        for edge in self
+            .edges
+            .iter()
+            .filter(|edge| !hidden.contains(&edge.from))
+        {
+            let targets = match edge.edge_type {
+                EdgeType::Calls => self.edge_targets(edge),
+                _ => Vec::new(),
+            };
+            if targets.iter().all(|target| !hidden.contains(&target.id)) {
+                edges.push(edge.clone());
+                continue;
+            }
+            for target in targets {
+                match hidden.contains(&target.id) {
+                    true => edges.extend(self.calls_through(edge, target, &hidden)),
+                    false => edges.push(through(edge, edge, Some(target), &[])),
+                }
+            }
+        }
+        self.edges = edges;
+        self.nodes.retain(|node| !hidden.contains(&node.id));
+        self.references.retain(|reference| {
+            !hidden.contains(&reference.from)
+                && !reference
+                    .metadata
+                    .get("target_id")
+                    .is_some_and(|id| hidden.contains(id))
+        });
+        self.metadata.stats.total_nodes = self.nodes.len();
+        self.metadata.stats.total_edges = self.edges.len();
+        self.build_indexes();
+    }
+
+    /// Calls standing for `call` of the hidden `start`: one to each visible function or
+    /// external callee reached through hidden functions only, the nearest way
+    fn calls_through(&self, call: &Edge, start: &Node, hidden: &HashSet<String>) -> Vec<Edge> {
+        let mut calls = Vec::new();
+        let mut reached: HashSet<String> = HashSet::new();
+        let mut seen: HashSet<&str> = HashSet::from([start.id.as_str()]);
+        let mut queue = VecDeque::from([(start, vec![start.name.clone()])]);
+        while let Some((node, via)) = queue.pop_front() {
+            for next in self.get_outgoing_edges(&node.id) {
+                if next.edge_type != EdgeType::Calls || next.calls_through_value() {
+                    continue;
+                }
+                let targets = self.edge_targets(next);
+                if targets.is_empty() {
+                    let callee = match next.metadata.get("import_path") {
+                        Some(path) => format!("{}.{}", path, next.to),
+                        None => next.to.clone(),
+                    };
+                    if reached.insert(callee) {
+                        calls.push(through(call, next, None, &via));
+                    }
+                    continue;
+                }
+                for target in targets {
+                    if !hidden.contains(&target.id) {
+                        if reached.insert(target.id.clone()) {
+                            calls.push(through(call, next, Some(target), &via));
+                        }
+                    } else if seen.insert(target.id.as_str()) {
+                        let mut via = via.clone();
+                        via.push(target.name.clone());
+                        queue.push_back((target, via));
+                    }
+                }
+            }
+        }
+        calls
+    }
+}
+
+/// Dropped by [`CodeGraph::hide_unexported`]. Go is the only language indexed with a
+/// rule for it.
+fn is_hidden(node: &Node) -> bool {
+    node.file_path.extension().is_some_and(|ext| ext == "go") && !node.is_exported()
+}
+
+/// `last`, the call reaching `target`, moved to where `call` is made
+fn through(call: &Edge, last: &Edge, target: Option<&Node>, via: &[String]) -> Edge {
+    let mut edge = last.clone();
+    edge.from = call.from.clone();
+    edge.call_site = call.call_site.clone();
+    edge.file_path = call.file_path.clone();
+    edge.line = call.line;
+    edge.column = call.column;
+    if let Some(target) = target {
+        edge.metadata
+            .insert("target_id".to_string(), target.id.clone());
+    }
+    match call.metadata.get("kind") {
+        Some(kind) => edge.metadata.insert("kind".to_string(), kind.clone()),
+        None => edge.metadata.remove("kind"),
+    };
+    if !via.is_empty() {
+        edge.metadata.insert(HIDDEN_VIA.to_string(), via.join(","));
+    }
+    edge
+}
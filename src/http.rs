@@ -0,0 +1,361 @@
+//! A read-only HTTP JSON API over a loaded graph, for `codenav serve`.
+//!
+//! [`route`] maps one GET request to a [`Response`] whose body is one of the
+//! [`schema`](crate::schema) structures `--json` prints, so a client can share types
+//! with the CLI; errors are RFC 7807 problem objects. [`serve`] answers connections one
+//! at a time until its shutdown flag is raised, finishing the request in flight.
+
+use crate::core::{CodeGraph, Node, NodeType, Page, PageRequest, SearchOptions, SymbolFilter};
+use crate::schema::{self, SearchResult, Symbol};
+use anyhow::Result;
+use serde::Serialize;
+use serde_json::{json, Value};
+use std::io::{BufRead, BufReader, ErrorKind, Write};
+use std::net::{TcpListener, TcpStream};
+use std::path::Path;
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::time::Duration;
+
+/// How often the accept loop checks for shutdown while idle
+const POLL_INTERVAL: Duration = Duration::from_millis(50);
+/// Longest a client may take to send its request
+const READ_TIMEOUT: Duration = Duration::from_secs(5);
+/// Larger request heads are refused rather than buffered
+const MAX_HEAD_BYTES: usize = 16 * 1024;
+
+#[derive(Debug, Clone, PartialEq)]
+pub struct Response {
+    pub status: u16,
+    pub body: Value,
+}
+
+impl Response {
+    fn ok<T: Serialize>(body: &T) -> Self {
+        Self {
+            status: 200,
+            body: serde_json::to_value(body).unwrap_or(Value::Null),
+        }
+    }
+
+    /// A problem object: `{ type, title, status, detail }`
+    pub fn problem(status: u16, detail: impl Into<String>) -> Self {
+        Self {
+            status,
+            body: json!({
+                "type": "about:blank",
+                "title": reason(status),
+                "status": status,
+                "detail": detail.into(),
+            }),
+        }
+    }
+
+    fn content_type(&self) -> &'static str {
+        match self.status {
+            200..=299 => "application/json",
+            _ => "application/problem+json",
+        }
+    }
+}
+
+fn reason(status: u16) -> &'static str {
+    match status {
+        200 => "OK",
+        400 => "Bad Request",
+        404 => "Not Found",
+        405 => "Method Not Allowed",
+        431 => "Request Header Fields Too Large",
+        _ => "Internal Server Error",
+    }
+}
+
+/// Answer requests on `listener` until `shutdown` is set
+pub fn serve(graph: &CodeGraph, listener: &TcpListener, shutdown: &AtomicBool) -> Result<()> {
+    listener.set_nonblocking(true)?;
+    while !shutdown.load(Ordering::Relaxed) {
+        match listener.accept() {
+            Ok((stream, _)) => {
+                // A client that hangs up early only loses its own response
+                if let Err(err) = handle(graph, stream) {
+                    eprintln!("codenav serve: {:#}", err);
+                }
+            }
+            Err(err) if err.kind() == ErrorKind::WouldBlock => std::thread::sleep(POLL_INTERVAL),
+            Err(err) if err.kind() == ErrorKind::Interrupted => {}
+            Err(err) => return Err(err.into()),
+        }
+    }
+    Ok(())
+}
+
+fn handle(graph: &CodeGraph, stream: TcpStream) -> Result<()> {
+    stream.set_nonblocking(false)?;
+    stream.set_read_timeout(Some(READ_TIMEOUT))?;
+    let mut reader = BufReader::new(&stream);
+
+    let mut request_line = String::new();
+    reader.read_line(&mut request_line)?;
+    // Headers are read to the blank line and ignored; GET requests carry no body
+    let mut head_bytes = request_line.len();
+    loop {
+        let mut header = String::new();
+        let read = reader.read_line(&mut header)?;
+        head_bytes += read;
+        if read == 0 || header.trim_end().is_empty() || head_bytes > MAX_HEAD_BYTES {
+            break;
+        }
+    }
+
+    let response = match request_line.split_whitespace().collect::<Vec<_>>()[..] {
+        _ if head_bytes > MAX_HEAD_BYTES => Response::problem(431, "Request head too large"),
+        [method, target, _version] => route(graph, method, target),
+        _ => Response::problem(400, "Malformed request line"),
+    };
+
+    let body = serde_json::to_string(&response.body)?;
+    let mut stream = &stream;
+    write!(
+        stream,
+        "HTTP/1.1 {} {}\r\nContent-Type: {}\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+        response.status,
+        reason(response.status),
+        response.content_type(),
+        body.len(),
+        body
+    )?;
+    stream.flush()?;
+    Ok(())
+}
+
+/// Answer `method` on `target`, a path with an optional query string
+pub fn route(graph: &CodeGraph, method: &str, target: &str) -> Response {
+    if method != "GET" {
+        return Response::problem(405, format!("{} is not supported; use GET", method));
+    }
+    let (path, query) = target.split_once('?').unwrap_or((target, ""));
+    let query = Query::parse(query);
+
+    match path {
+        "/healthz" => Response::ok(&json!({ "status": "ok" })),
+        "/symbols" => symbols(graph, &query),
+        "/callgraph" => callgraph(graph, &query),
+        "/file" => file(graph, &query),
+        _ => match path.strip_prefix("/symbol/") {
+            Some(rest) => symbol(graph, rest, &query),
+            None => Response::problem(404, format!("No route for {}", path)),
+        },
+    }
+}
+
+/// `GET /symbols?q=...&kind=...&limit=...&offset=...`: ranked matches, as `search --json`
+fn symbols(graph: &CodeGraph, query: &Query) -> Response {
+    let Some(q) = query.get("q").filter(|q| !q.trim().is_empty()) else {
+        return Response::problem(400, "Missing query parameter q");
+    };
+    let kinds = match query.get("kind").map(str::parse::<NodeType>).transpose() {
+        Ok(kind) => kind.into_iter().collect(),
+        Err(err) => return Response::problem(400, err.to_string()),
+    };
+    let request = match query.page(Some(20)) {
+        Ok(request) => request,
+        Err(problem) => return problem,
+    };
+    let options = SearchOptions {
+        kinds,
+        ..Default::default()
+    };
+    let page = graph.search_page(q, &options, &request);
+    let results: Vec<SearchResult> = page.items.iter().map(SearchResult::from).collect();
+    paged(query, &page, results)
+}
+
+/// `GET /callgraph?root=...&depth=...`: calls reachable from `root`, as `trace --json`
+fn callgraph(graph: &CodeGraph, query: &Query) -> Response {
+    let Some(root) = query.get("root") else {
+        return Response::problem(400, "Missing query parameter root");
+    };
+    let node = match lookup(graph, root) {
+        Ok(node) => node,
+        Err(problem) => return problem,
+    };
+    let depth = match query.usize("depth", 3) {
+        Ok(depth) => depth,
+        Err(problem) => return problem,
+    };
+    Response::ok(&schema::call_edges(
+        &graph.trace_dependencies(&node.id, depth),
+    ))
+}
+
+/// `GET /file?path=...`: symbols the file defines in source order, as `query --json`;
+/// the path is as indexed or relative to the indexed root
+fn file(graph: &CodeGraph, query: &Query) -> Response {
+    let Some(path) = query.get("path") else {
+        return Response::problem(400, "Missing query parameter path");
+    };
+    let under_root = Path::new(&graph.metadata.root_path).join(path);
+    let nodes: Vec<&Node> = graph
+        .nodes
+        .iter()
+        .filter(|node| node.file_path == Path::new(path) || node.file_path == under_root)
+        .collect();
+    if nodes.is_empty() {
+        return Response::problem(404, format!("No symbols indexed for file {}", path));
+    }
+    Response::ok(&schema::symbols(nodes))
+}
+
+/// `GET /symbol/{id}`, `/symbol/{id}/references` and `/symbol/{id}/callers?depth=...`;
+/// both lists take `limit` and `offset`. IDs contain the package path and a `#`, so
+/// send them escaped; a `/` inside one may also be sent as is.
+fn symbol(graph: &CodeGraph, rest: &str, query: &Query) -> Response {
+    let (id, action) = match (
+        rest.strip_suffix("/references"),
+        rest.strip_suffix("/callers"),
+    ) {
+        (Some(id), _) => (id, "references"),
+        (_, Some(id)) => (id, "callers"),
+        _ => (rest, ""),
+    };
+    let node = match lookup(graph, &percent_decode(id)) {
+        Ok(node) => node,
+        Err(problem) => return problem,
+    };
+    if action.is_empty() {
+        return Response::ok(&schema::Versioned::new(Symbol::from(node)));
+    }
+    let request = match query.page(None) {
+        Ok(request) => request,
+        Err(problem) => return problem,
+    };
+    let page = match action {
+        // As `references --json`
+        "references" => graph.references_page(&node.id, &[], &SymbolFilter::default(), &request),
+        // As `callers --json`, transitively when depth is above 1
+        _ => match query.usize("depth", 1) {
+            Ok(depth) => graph.callers_page(&node.id, depth, &SymbolFilter::default(), &request),
+            Err(problem) => return problem,
+        },
+    };
+    paged(query, &page, schema::references(&page.items))
+}
+
+/// `results` as the bare list, or as a page when the request gave a limit or an offset
+fn paged<T, U: Serialize>(query: &Query, page: &Page<T>, results: Vec<U>) -> Response {
+    let limit = query.get("limit").and_then(|limit| limit.parse().ok());
+    let offset = query.get("offset").and_then(|offset| offset.parse().ok());
+    Response::ok(&schema::Listing::new(page, limit, offset, results))
+}
+
+/// The node with ID `key`, or failing that the one symbol named `key`
+fn lookup<'a>(graph: &'a CodeGraph, key: &str) -> Result<&'a Node, Response> {
+    if let Some(node) = graph.get_node_by_id(key) {
+        return Ok(node);
+    }
+    match graph.find_nodes_by_symbol(key).len() {
+        0 => Err(Response::problem(404, format!("Unknown symbol: {}", key))),
+        // Several definitions: the error lists them to qualify with
+        _ => graph
+            .resolve_symbol(key)
+            .map_err(|err| Response::problem(400, err.to_string())),
+    }
+}
+
+/// Decoded query string parameters, in order
+struct Query(Vec<(String, String)>);
+
+impl Query {
+    fn parse(query: &str) -> Self {
+        Self(
+            query
+                .split('&')
+                .filter(|pair| !pair.is_empty())
+                .map(|pair| {
+                    let (name, value) = pair.split_once('=').unwrap_or((pair, ""));
+                    (
+                        percent_decode(&name.replace('+', " ")),
+                        percent_decode(&value.replace('+', " ")),
+                    )
+                })
+                .collect(),
+        )
+    }
+
+    fn get(&self, name: &str) -> Option<&str> {
+        self.0
+            .iter()
+            .find(|(key, _)| key == name)
+            .map(|(_, value)| value.as_str())
+    }
+
+    /// A positive integer parameter, `default` when absent
+    fn usize(&self, name: &str, default: usize) -> Result<usize, Response> {
+        match self.get(name) {
+            None => Ok(default),
+            Some(value) => value.parse().ok().filter(|&n| n > 0).ok_or_else(|| {
+                Response::problem(
+                    400,
+                    format!("{} must be a positive integer, got {:?}", name, value),
+                )
+            }),
+        }
+    }
+
+    /// The page `limit` and `offset` ask for; `offset` may be 0
+    fn page(&self, default_limit: Option<usize>) -> Result<PageRequest, Response> {
+        let limit = match self.get("limit") {
+            None => default_limit,
+            Some(_) => Some(self.usize("limit", 0)?),
+        };
+        let offset = match self.get("offset") {
+            None => 0,
+            Some(value) => value.parse().map_err(|_| {
+                Response::problem(
+                    400,
+                    format!("offset must be a non-negative integer, got {:?}", value),
+                )
+            })?,
+        };
+        Ok(PageRequest::new(offset, limit))
+    }
+}
+
+/// `%XX` escapes decoded; malformed escapes are kept as written
+fn percent_decode(text: &str) -> String {
+    let bytes = text.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        let escaped = (bytes[i] == b'%')
+            .then(|| text.get(i + 1..i + 3))
+            .flatten()
+            .and_then(|hex| u8::from_str_radix(hex, 16).ok());
+        match escaped {
+            Some(byte) => {
+                decoded.push(byte);
+                i += 3;
+            }
+            None => {
+                decoded.push(bytes[i]);
+                i += 1;
+            }
+        }
+    }
+    String::from_utf8_lossy(&decoded).into_owned()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_query_decoding() {
+        let query = Query::parse("q=Calc+Add&root=%28%2ACalculator%29.Add&depth=2&flag");
+        assert_eq!(query.get("q"), Some("Calc Add"));
+        assert_eq!(query.get("root"), Some("(*Calculator).Add"));
+        assert_eq!(query.usize("depth", 1).ok(), Some(2));
+        assert_eq!(query.get("flag"), Some(""));
+        assert_eq!(query.usize("missing", 7).ok(), Some(7));
+        assert_eq!(query.usize("q", 1).unwrap_err().status, 400);
+    }
+}
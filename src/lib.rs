@@ -1,7 +1,13 @@
 pub mod benchmark;
+pub mod blame;
 pub mod core;
+pub mod http;
+pub mod lsp;
+pub mod mcp;
 pub mod parser;
+pub mod schema;
 pub mod serializer;
+pub mod watch;
 
 #[cfg(test)]
 mod tests {
@@ -273,6 +279,27 @@ mod tests {
         assert_eq!(callers.len(), 0);
     }
 
+    #[test]
+    fn test_transitive_callers() {
+        let graph = create_test_graph_with_calls();
+
+        // Direct callers only
+        let callers = graph.callers("funcD");
+        assert_eq!(callers.len(), 1);
+        assert_eq!(callers[0].caller, "funcC");
+        assert_eq!(callers[0].line, 22);
+        assert_eq!(callers[0].depth, 1);
+
+        // C calls D, B calls C, A calls B
+        let callers = graph.transitive_callers("funcD", 5);
+        let names: Vec<_> = callers.iter().map(|c| c.caller.as_str()).collect();
+        assert_eq!(names, vec!["funcC", "funcB", "funcA"]);
+        assert_eq!(callers[2].depth, 3);
+
+        // Depth limits how far up the chain we go
+        assert_eq!(graph.transitive_callers("funcD", 2).len(), 2);
+    }
+
     #[test]
     fn test_trace_dependencies() {
         let graph = create_test_graph_with_calls();
@@ -610,6 +637,10 @@ mod tests {
         // Should find B and C, but not loop infinitely
         assert!(trace.len() >= 2);
         assert!(trace.len() <= 3); // Won't revisit A
+
+        // Reverse traversal terminates too, reporting each call site once
+        let callers = graph.transitive_callers("funcA", 10);
+        assert_eq!(callers.len(), 3);
     }
 
     #[test]
@@ -665,4 +696,62 @@ mod tests {
         let helpers = graph.get_nodes_by_name("helper");
         assert_eq!(helpers.len(), 2);
     }
+
+    #[test]
+    fn test_search_ranking() {
+        use crate::core::{MatchKind, SearchOptions};
+
+        let mut graph = CodeGraph::new("test".to_string(), "go".to_string());
+        let names = [
+            ("PackageReaderSet", NodeType::Function),
+            ("Reparse", NodeType::Function),
+            ("ParseB", NodeType::Method),
+            ("ParseA", NodeType::Function),
+            ("Parse", NodeType::Function),
+            ("Unrelated", NodeType::Function),
+        ];
+        for (i, (name, node_type)) in names.into_iter().enumerate() {
+            graph.add_node(Node::new(
+                format!("test.go:{}:{}", name, i + 1),
+                name.to_string(),
+                node_type,
+                PathBuf::from("test.go"),
+                i + 1,
+                i + 1,
+                "main".to_string(),
+                format!("func {}() {{}}", name),
+            ));
+        }
+
+        let results = graph.search("PARSE", &SearchOptions::default());
+        let ranked: Vec<(&str, MatchKind)> = results
+            .iter()
+            .map(|m| (m.node.name.as_str(), m.match_kind))
+            .collect();
+        assert_eq!(
+            ranked,
+            vec![
+                ("Parse", MatchKind::Exact),
+                ("ParseA", MatchKind::Prefix),
+                ("ParseB", MatchKind::Prefix),
+                ("Reparse", MatchKind::Substring),
+                ("PackageReaderSet", MatchKind::Fuzzy),
+            ]
+        );
+        assert!(results.windows(2).all(|w| w[0].score >= w[1].score));
+
+        let options = SearchOptions {
+            kinds: vec![NodeType::Function],
+            limit: Some(2),
+            ..Default::default()
+        };
+        let names: Vec<&str> = graph
+            .search("parse", &options)
+            .iter()
+            .map(|m| m.node.name.as_str())
+            .collect();
+        assert_eq!(names, vec!["Parse", "ParseA"]);
+
+        assert!(graph.search("  ", &SearchOptions::default()).is_empty());
+    }
 }
@@ -0,0 +1,782 @@
+//! A Language Server Protocol endpoint for `codenav lsp`.
+//!
+//! [`serve`] reads JSON-RPC messages framed with `Content-Length` headers, indexes the
+//! workspace root on `initialize`, and answers definition, references, document symbol
+//! and call hierarchy requests from the graph. Edits re-index the affected file with
+//! [`update_go_source`].
+//!
+//! The index stores 1-based lines and 1-based byte columns; LSP positions are 0-based
+//! and count UTF-16 code units, so every position crosses [`utf16_column`] or
+//! [`byte_column`] against the text of its line.
+
+use crate::core::{CodeGraph, Edge, EdgeType, Node, NodeType, Span};
+use crate::parser::GoParser;
+use crate::watch::update_go_source;
+use anyhow::{bail, Context, Result};
+use serde_json::{json, Value};
+use std::collections::{BTreeMap, HashMap};
+use std::fs;
+use std::io::{BufRead, Write};
+use std::path::{Path, PathBuf};
+
+/// JSON-RPC error codes used in responses
+const INVALID_PARAMS: i64 = -32602;
+const METHOD_NOT_FOUND: i64 = -32601;
+const INTERNAL_ERROR: i64 = -32603;
+const SERVER_NOT_INITIALIZED: i64 = -32002;
+
+/// Answer LSP requests from `input` on `output` until the client sends `exit` or
+/// closes the stream
+pub fn serve(mut input: impl BufRead, mut output: impl Write) -> Result<()> {
+    let mut server = Server::default();
+    while let Some(message) = read_message(&mut input)? {
+        // Anything without a method is a response to a request the server never sends
+        let Some(method) = message["method"].as_str() else {
+            continue;
+        };
+        if method == "exit" {
+            break;
+        }
+        let params = message.get("params").cloned().unwrap_or(Value::Null);
+        let Some(id) = message.get("id").cloned() else {
+            // Notifications get no response; failures only reach the log
+            if let Err(err) = server.notify(method, &params) {
+                eprintln!("codenav lsp: {}: {:#}", method, err);
+            }
+            continue;
+        };
+
+        let response = match server.request(method, &params) {
+            Ok(result) => json!({ "jsonrpc": "2.0", "id": id, "result": result }),
+            Err(RequestError { code, message }) => json!({
+                "jsonrpc": "2.0",
+                "id": id,
+                "error": { "code": code, "message": message },
+            }),
+        };
+        write_message(&mut output, &response)?;
+    }
+    Ok(())
+}
+
+/// Read one `Content-Length` framed message; `None` at the end of the stream
+pub fn read_message(input: &mut impl BufRead) -> Result<Option<Value>> {
+    let mut length = None;
+    loop {
+        let mut header = String::new();
+        if input.read_line(&mut header)? == 0 {
+            return Ok(None);
+        }
+        let header = header.trim_end();
+        if header.is_empty() {
+            if length.is_some() {
+                break;
+            }
+            continue;
+        }
+        if let Some((name, value)) = header.split_once(':') {
+            if name.eq_ignore_ascii_case("Content-Length") {
+                length = Some(
+                    value
+                        .trim()
+                        .parse::<usize>()
+                        .context("Invalid Content-Length")?,
+                );
+            }
+        }
+    }
+
+    let mut body = vec![0; length.unwrap_or_default()];
+    input.read_exact(&mut body)?;
+    Ok(Some(
+        serde_json::from_slice(&body).context("Invalid JSON-RPC message")?,
+    ))
+}
+
+/// Write one message with its `Content-Length` header
+pub fn write_message(output: &mut impl Write, message: &Value) -> Result<()> {
+    let body = serde_json::to_string(message)?;
+    write!(output, "Content-Length: {}\r\n\r\n{}", body.len(), body)?;
+    output.flush()?;
+    Ok(())
+}
+
+struct RequestError {
+    code: i64,
+    message: String,
+}
+
+impl From<anyhow::Error> for RequestError {
+    fn from(err: anyhow::Error) -> Self {
+        Self {
+            code: INTERNAL_ERROR,
+            message: format!("{:#}", err),
+        }
+    }
+}
+
+fn invalid_params(message: &str) -> RequestError {
+    RequestError {
+        code: INVALID_PARAMS,
+        message: message.to_string(),
+    }
+}
+
+/// The graph once `initialize` has indexed the workspace
+struct Workspace {
+    graph: CodeGraph,
+    parser: GoParser,
+}
+
+#[derive(Default)]
+struct Server {
+    workspace: Option<Workspace>,
+    /// Text of the documents the client has open, which may differ from disk
+    open: HashMap<PathBuf, String>,
+}
+
+impl Server {
+    fn request(&mut self, method: &str, params: &Value) -> Result<Value, RequestError> {
+        if method == "initialize" {
+            return Ok(self.initialize(params)?);
+        }
+        if method == "shutdown" {
+            return Ok(Value::Null);
+        }
+        let Some(workspace) = &self.workspace else {
+            return Err(RequestError {
+                code: SERVER_NOT_INITIALIZED,
+                message: "Server not initialized".to_string(),
+            });
+        };
+        let graph = &workspace.graph;
+        let mut sources = Sources::new(&self.open);
+
+        match method {
+            "textDocument/definition" => {
+                let (path, line, column) = position_param(params, &mut sources)?;
+                let definitions = definitions_at(graph, &mut sources, &path, line, column);
+                Ok(Value::Array(
+                    definitions
+                        .into_iter()
+                        .map(|node| location(&mut sources, node))
+                        .collect(),
+                ))
+            }
+            "textDocument/references" => {
+                let (path, line, column) = position_param(params, &mut sources)?;
+                let include_declaration = params["context"]["includeDeclaration"]
+                    .as_bool()
+                    .unwrap_or(false);
+                let mut locations = Vec::new();
+                for node in definitions_at(graph, &mut sources, &path, line, column) {
+                    if include_declaration {
+                        locations.push(location(&mut sources, node));
+                    }
+                    for site in graph.references(&node.name) {
+                        if site.callee_id.as_ref() != Some(&node.id) {
+                            continue;
+                        }
+                        locations.push(json!({
+                            "uri": path_to_uri(&site.file_path),
+                            "range": name_range(
+                                &mut sources,
+                                &site.file_path,
+                                site.line,
+                                site.column,
+                                node.identifier(),
+                            ),
+                        }));
+                    }
+                }
+                Ok(Value::Array(locations))
+            }
+            "textDocument/documentSymbol" => {
+                let path = document_param(params)
+                    .ok_or_else(|| invalid_params("Missing textDocument.uri"))?;
+                Ok(document_symbols(graph, &mut sources, &path))
+            }
+            "textDocument/prepareCallHierarchy" => {
+                let (path, line, column) = position_param(params, &mut sources)?;
+                let items: Vec<Value> = definitions_at(graph, &mut sources, &path, line, column)
+                    .into_iter()
+                    .filter(|node| {
+                        matches!(
+                            node.node_type,
+                            NodeType::Function
+                                | NodeType::Method
+                                | NodeType::Benchmark
+                                | NodeType::Fuzz
+                        )
+                    })
+                    .map(|node| call_hierarchy_item(&mut sources, node))
+                    .collect();
+                Ok(match items.is_empty() {
+                    true => Value::Null,
+                    false => Value::Array(items),
+                })
+            }
+            "callHierarchy/incomingCalls" => {
+                let node = hierarchy_param(graph, params)?;
+                // Call sites grouped by caller, callers in source order
+                let mut by_caller: BTreeMap<(PathBuf, usize, String), Vec<Value>> = BTreeMap::new();
+                for site in graph.callers(&node.name) {
+                    if site.callee_id.as_ref() != Some(&node.id) {
+                        continue;
+                    }
+                    let Some(caller) = graph.get_node_by_id(&site.caller_id) else {
+                        continue;
+                    };
+                    let range = name_range(
+                        &mut sources,
+                        &site.file_path,
+                        site.line,
+                        site.column,
+                        node.identifier(),
+                    );
+                    by_caller
+                        .entry((caller.file_path.clone(), caller.line, caller.id.clone()))
+                        .or_default()
+                        .push(range);
+                }
+                Ok(Value::Array(
+                    by_caller
+                        .into_iter()
+                        .filter_map(|((_, _, id), ranges)| {
+                            let caller = graph.get_node_by_id(&id)?;
+                            Some(json!({
+                                "from": call_hierarchy_item(&mut sources, caller),
+                                "fromRanges": ranges,
+                            }))
+                        })
+                        .collect(),
+                ))
+            }
+            "callHierarchy/outgoingCalls" => {
+                let node = hierarchy_param(graph, params)?;
+                let mut by_callee: BTreeMap<(PathBuf, usize, String), Vec<Value>> = BTreeMap::new();
+                let mut edges = graph.get_outgoing_edges(&node.id);
+                edges.sort_by_key(|edge| (edge.line, edge.column));
+                for edge in edges {
+                    for callee in graph.edge_targets(edge) {
+                        let range = name_range(
+                            &mut sources,
+                            &edge.file_path,
+                            edge.line,
+                            edge.column,
+                            callee.identifier(),
+                        );
+                        by_callee
+                            .entry((callee.file_path.clone(), callee.line, callee.id.clone()))
+                            .or_default()
+                            .push(range);
+                    }
+                }
+                Ok(Value::Array(
+                    by_callee
+                        .into_iter()
+                        .filter_map(|((_, _, id), ranges)| {
+                            let callee = graph.get_node_by_id(&id)?;
+                            Some(json!({
+                                "to": call_hierarchy_item(&mut sources, callee),
+                                "fromRanges": ranges,
+                            }))
+                        })
+                        .collect(),
+                ))
+            }
+            _ => Err(RequestError {
+                code: METHOD_NOT_FOUND,
+                message: format!("Unsupported method: {}", method),
+            }),
+        }
+    }
+
+    /// Index the workspace root and advertise what the server answers
+    fn initialize(&mut self, params: &Value) -> Result<Value> {
+        let root = params["rootUri"]
+            .as_str()
+            .or_else(|| params["workspaceFolders"][0]["uri"].as_str())
+            .map(uri_to_path)
+            .or_else(|| params["rootPath"].as_str().map(PathBuf::from))
+            .context("initialize needs a rootUri or rootPath to index")?;
+        let root = root.canonicalize().unwrap_or(root);
+
+        let mut graph = CodeGraph::new(root.to_string_lossy().to_string(), "go".to_string());
+        let mut parser = GoParser::new()?.with_tests(true);
+        parser
+            .parse_directory(&root, &mut graph)
+            .with_context(|| format!("Failed to index {}", root.display()))?;
+        self.workspace = Some(Workspace { graph, parser });
+
+        Ok(json!({
+            "capabilities": {
+                // Full text on every change
+                "textDocumentSync": { "openClose": true, "change": 1, "save": true },
+                "definitionProvider": true,
+                "referencesProvider": true,
+                "documentSymbolProvider": true,
+                "callHierarchyProvider": true,
+            },
+            "serverInfo": { "name": "codenav", "version": env!("CARGO_PKG_VERSION") },
+        }))
+    }
+
+    fn notify(&mut self, method: &str, params: &Value) -> Result<()> {
+        match method {
+            "textDocument/didOpen" => {
+                let path = document_param(params).context("Missing textDocument.uri")?;
+                let text = params["textDocument"]["text"].as_str().unwrap_or_default();
+                self.open.insert(path, text.to_string());
+            }
+            "textDocument/didChange" => {
+                let path = document_param(params).context("Missing textDocument.uri")?;
+                // Only full-text sync is advertised, so the last change holds the document
+                let Some(text) = params["contentChanges"]
+                    .as_array()
+                    .and_then(|changes| changes.last())
+                    .and_then(|change| change["text"].as_str())
+                else {
+                    bail!("didChange without the document's text");
+                };
+                self.open.insert(path.clone(), text.to_string());
+                self.reindex(&path)?;
+            }
+            "textDocument/didSave" => {
+                let path = document_param(params).context("Missing textDocument.uri")?;
+                if let Some(text) = params["text"].as_str() {
+                    self.open.insert(path.clone(), text.to_string());
+                } else if let Ok(text) = fs::read_to_string(&path) {
+                    if let Some(open) = self.open.get_mut(&path) {
+                        *open = text;
+                    }
+                }
+                self.reindex(&path)?;
+            }
+            "textDocument/didClose" => {
+                let path = document_param(params).context("Missing textDocument.uri")?;
+                self.open.remove(&path);
+            }
+            _ => {}
+        }
+        Ok(())
+    }
+
+    /// Re-index a Go file from its open buffer, or from disk once it is closed
+    fn reindex(&mut self, path: &Path) -> Result<()> {
+        let Some(workspace) = &mut self.workspace else {
+            return Ok(());
+        };
+        if path.extension().and_then(|s| s.to_str()) != Some("go")
+            || !path.starts_with(&workspace.graph.metadata.root_path)
+        {
+            return Ok(());
+        }
+        let source = match self.open.get(path) {
+            Some(text) => Some(text.clone()),
+            None => fs::read_to_string(path).ok(),
+        };
+        update_go_source(
+            &mut workspace.graph,
+            &mut workspace.parser,
+            path,
+            source.as_deref(),
+        )?;
+        Ok(())
+    }
+}
+
+/// Lines of open documents and, failing that, of files on disk, split once each
+struct Sources<'a> {
+    open: &'a HashMap<PathBuf, String>,
+    lines: HashMap<PathBuf, Option<Vec<String>>>,
+}
+
+impl<'a> Sources<'a> {
+    fn new(open: &'a HashMap<PathBuf, String>) -> Self {
+        Self {
+            open,
+            lines: HashMap::new(),
+        }
+    }
+
+    /// Text of 1-based `line` of `path`, without its line ending
+    fn line(&mut self, path: &Path, line: usize) -> Option<&str> {
+        let open = self.open;
+        let lines = self
+            .lines
+            .entry(path.to_path_buf())
+            .or_insert_with(|| {
+                let text = match open.get(path) {
+                    Some(text) => Some(text.clone()),
+                    None => fs::read_to_string(path).ok(),
+                };
+                text.map(|text| text.lines().map(str::to_string).collect())
+            })
+            .as_ref()?;
+        lines.get(line.checked_sub(1)?).map(String::as_str)
+    }
+
+    /// LSP position of a 1-based line and 1-based byte column
+    fn position(&mut self, path: &Path, line: usize, column: usize) -> Value {
+        let character = match self.line(path, line) {
+            Some(text) => utf16_column(text, column.saturating_sub(1)),
+            None => column.saturating_sub(1),
+        };
+        json!({ "line": line.saturating_sub(1), "character": character })
+    }
+
+    /// LSP position just past the end of 1-based `line`
+    fn line_end(&mut self, path: &Path, line: usize) -> Value {
+        let character = self
+            .line(path, line)
+            .map_or(0, |text| text.encode_utf16().count());
+        json!({ "line": line.saturating_sub(1), "character": character })
+    }
+}
+
+/// UTF-16 code units before byte offset `byte` of `line`, as LSP counts characters
+pub fn utf16_column(line: &str, byte: usize) -> usize {
+    let mut byte = byte.min(line.len());
+    while !line.is_char_boundary(byte) {
+        byte -= 1;
+    }
+    line[..byte].encode_utf16().count()
+}
+
+/// Byte offset in `line` of the character `utf16` UTF-16 code units in; the end of the
+/// line when it is shorter
+pub fn byte_column(line: &str, utf16: usize) -> usize {
+    let mut units = 0;
+    for (offset, c) in line.char_indices() {
+        if units >= utf16 {
+            return offset;
+        }
+        units += c.len_utf16();
+    }
+    line.len()
+}
+
+/// `file:///a/b%20c.go` for `/a/b c.go`
+pub fn path_to_uri(path: &Path) -> String {
+    let mut uri = String::from("file://");
+    for byte in path.to_string_lossy().bytes() {
+        match byte {
+            b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'-' | b'.' | b'_' | b'~' | b'/' => {
+                uri.push(byte as char)
+            }
+            _ => uri.push_str(&format!("%{:02X}", byte)),
+        }
+    }
+    uri
+}
+
+/// The path a `file://` URI names, with percent escapes decoded
+pub fn uri_to_path(uri: &str) -> PathBuf {
+    let encoded = uri.strip_prefix("file://").unwrap_or(uri);
+    let bytes = encoded.as_bytes();
+    let mut decoded = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        let escaped = (bytes[i] == b'%')
+            .then(|| encoded.get(i + 1..i + 3))
+            .flatten()
+            .and_then(|hex| u8::from_str_radix(hex, 16).ok());
+        match escaped {
+            Some(byte) => {
+                decoded.push(byte);
+                i += 3;
+            }
+            None => {
+                decoded.push(bytes[i]);
+                i += 1;
+            }
+        }
+    }
+    PathBuf::from(String::from_utf8_lossy(&decoded).into_owned())
+}
+
+/// The file a request's `textDocument.uri` names, resolved like the workspace root
+fn document_param(params: &Value) -> Option<PathBuf> {
+    let path = uri_to_path(params["textDocument"]["uri"].as_str()?);
+    Some(path.canonicalize().unwrap_or(path))
+}
+
+/// The document and the 1-based line and byte column a request points at
+fn position_param(
+    params: &Value,
+    sources: &mut Sources,
+) -> Result<(PathBuf, usize, usize), RequestError> {
+    let path = document_param(params).ok_or_else(|| invalid_params("Missing textDocument.uri"))?;
+    let (Some(line), Some(character)) = (
+        params["position"]["line"].as_u64(),
+        params["position"]["character"].as_u64(),
+    ) else {
+        return Err(invalid_params("Missing position"));
+    };
+    let line = line as usize + 1;
+    let column = match sources.line(&path, line) {
+        Some(text) => byte_column(text, character as usize) + 1,
+        None => character as usize + 1,
+    };
+    Ok((path, line, column))
+}
+
+fn hierarchy_param<'a>(graph: &'a CodeGraph, params: &Value) -> Result<&'a Node, RequestError> {
+    params["item"]["data"]["id"]
+        .as_str()
+        .and_then(|id| graph.get_node_by_id(id))
+        .ok_or_else(|| invalid_params("Unknown call hierarchy item"))
+}
+
+/// The definitions the identifier at a position names: the symbol declared there, the
+/// target of a call or reference on it, or failing both every symbol of that name
+fn definitions_at<'a>(
+    graph: &'a CodeGraph,
+    sources: &mut Sources,
+    path: &Path,
+    line: usize,
+    column: usize,
+) -> Vec<&'a Node> {
+    let Some(text) = sources.line(path, line) else {
+        return Vec::new();
+    };
+    let Some((start, word)) = word_at(text, column.saturating_sub(1)) else {
+        return Vec::new();
+    };
+
+    if let Some(node) = graph.nodes.iter().find(|node| {
+        node.file_path == path
+            && node.line == line
+            && node.column == start + 1
+            && node.identifier() == word
+    }) {
+        return vec![node];
+    }
+
+    let uses = graph
+        .edges
+        .iter()
+        .chain(&graph.references)
+        .filter(|edge| edge.file_path == path && edge.line == line && edge.column <= start + 1);
+    // Calls and selectors start at their operand, so `c.Add` is found from `c`
+    for edge in uses {
+        let targets = use_targets(graph, edge);
+        if targets.iter().any(|target| target.identifier() == word) {
+            return targets;
+        }
+    }
+
+    graph
+        .find_nodes_by_symbol(word)
+        .into_iter()
+        .filter(|node| node.identifier() == word && !node.metadata.contains_key("enclosing"))
+        .collect()
+}
+
+fn use_targets<'a>(graph: &'a CodeGraph, edge: &Edge) -> Vec<&'a Node> {
+    match edge.edge_type {
+        EdgeType::References => edge
+            .metadata
+            .get("target_id")
+            .and_then(|id| graph.get_node_by_id(id))
+            .into_iter()
+            .collect(),
+        _ => graph.edge_targets(edge),
+    }
+}
+
+fn is_word(c: char) -> bool {
+    c.is_alphanumeric() || c == '_'
+}
+
+/// The identifier covering byte offset `at`, with the offset it starts at
+fn word_at(line: &str, at: usize) -> Option<(usize, &str)> {
+    let start = line[..at.min(line.len())]
+        .char_indices()
+        .rev()
+        .take_while(|&(_, c)| is_word(c))
+        .last()
+        .map_or(at.min(line.len()), |(offset, _)| offset);
+    let end = line[start..]
+        .char_indices()
+        .find(|&(_, c)| !is_word(c))
+        .map_or(line.len(), |(offset, _)| start + offset);
+    (end > start).then_some((start, &line[start..end]))
+}
+
+/// The range of the identifier `name` at or after a 1-based byte column, so `Add` isn't
+/// found inside `AddAll`, or an empty range at the column when the text no longer has it
+fn name_range(sources: &mut Sources, path: &Path, line: usize, column: usize, name: &str) -> Value {
+    let start = sources.line(path, line).and_then(|text| {
+        let from = column.saturating_sub(1).min(text.len());
+        text.get(from..)?
+            .match_indices(name)
+            .map(|(offset, _)| from + offset)
+            .find(|&at| {
+                !text[..at].chars().next_back().is_some_and(is_word)
+                    && !text[at + name.len()..].chars().next().is_some_and(is_word)
+            })
+            .map(|at| at + 1)
+    });
+    match start {
+        Some(start) => json!({
+            "start": sources.position(path, line, start),
+            "end": sources.position(path, line, start + name.len()),
+        }),
+        None => {
+            let position = sources.position(path, line, column);
+            json!({ "start": position, "end": position })
+        }
+    }
+}
+
+fn location(sources: &mut Sources, node: &Node) -> Value {
+    json!({
+        "uri": path_to_uri(&node.file_path),
+        "range": selection_range(sources, node),
+    })
+}
+
+/// LSP range of a span recorded by the parser
+fn span_range(sources: &mut Sources, path: &Path, span: &Span) -> Value {
+    json!({
+        "start": sources.position(path, span.start.line, span.start.column),
+        "end": sources.position(path, span.end.line, span.end.column),
+    })
+}
+
+/// The symbol's name in its declaration
+fn selection_range(sources: &mut Sources, node: &Node) -> Value {
+    match &node.name_span {
+        Some(span) => span_range(sources, &node.file_path, span),
+        None => name_range(
+            sources,
+            &node.file_path,
+            node.line,
+            node.column,
+            node.identifier(),
+        ),
+    }
+}
+
+/// The whole declaration including its doc comment; without a recorded span, from the
+/// start of its first line to the end of its last
+fn full_range(sources: &mut Sources, node: &Node) -> Value {
+    match &node.span {
+        Some(span) => span_range(sources, &node.file_path, span),
+        None => json!({
+            "start": { "line": node.line.saturating_sub(1), "character": 0 },
+            "end": sources.line_end(&node.file_path, node.end_line.max(node.line)),
+        }),
+    }
+}
+
+fn symbol_kind(node: &Node) -> u32 {
+    match node.node_type {
+        NodeType::Function
+        | NodeType::HttpHandler
+        | NodeType::Middleware
+        | NodeType::Benchmark
+        | NodeType::Fuzz => 12,
+        NodeType::Method => 6,
+        NodeType::Struct => 23,
+        NodeType::Class => 5,
+        NodeType::Interface => 11,
+        NodeType::Type => 5,
+        NodeType::Const => 14,
+        NodeType::Var => 13,
+        NodeType::Field => 8,
+    }
+}
+
+/// Top-level symbols of a file in source order, with fields and methods nested under
+/// their type as [`CodeGraph::outline`] arranges them
+fn document_symbols(graph: &CodeGraph, sources: &mut Sources, path: &Path) -> Value {
+    let outline = graph.outline(path);
+    Value::Array(
+        outline
+            .iter()
+            .map(|entry| {
+                let children = entry
+                    .children
+                    .iter()
+                    .map(|member| {
+                        document_symbol(sources, member.node, member.node.identifier(), Vec::new())
+                    })
+                    .collect();
+                document_symbol(sources, entry.node, &entry.node.name, children)
+            })
+            .collect(),
+    )
+}
+
+fn document_symbol(sources: &mut Sources, node: &Node, name: &str, children: Vec<Value>) -> Value {
+    json!({
+        "name": name,
+        "detail": node.signature,
+        "kind": symbol_kind(node),
+        "range": full_range(sources, node),
+        "selectionRange": selection_range(sources, node),
+        "children": children,
+    })
+}
+
+fn call_hierarchy_item(sources: &mut Sources, node: &Node) -> Value {
+    json!({
+        "name": node.name,
+        "kind": symbol_kind(node),
+        "detail": node.package,
+        "uri": path_to_uri(&node.file_path),
+        "range": full_range(sources, node),
+        "selectionRange": selection_range(sources, node),
+        "data": { "id": node.id },
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::io::Cursor;
+
+    #[test]
+    fn test_columns_count_utf16_units() {
+        // é is two bytes and one unit; 🙂 is four bytes and two units
+        let line = "\ts := \"é🙂\" + Add(1, 2)";
+        let add = line.find("Add").unwrap();
+        assert_eq!(add, 17);
+        assert_eq!(utf16_column(line, add), 14);
+        assert_eq!(byte_column(line, 14), add);
+
+        // Offsets inside a character snap to its start; past the end clamps
+        assert_eq!(utf16_column(line, 10), utf16_column(line, 9));
+        assert_eq!(byte_column("ab", 10), 2);
+    }
+
+    #[test]
+    fn test_uris_round_trip() {
+        let path = Path::new("/work/my project/ünï.go");
+        let uri = path_to_uri(path);
+        assert_eq!(uri, "file:///work/my%20project/%C3%BCn%C3%AF.go");
+        assert_eq!(uri_to_path(&uri), path);
+    }
+
+    #[test]
+    fn test_word_at_finds_the_identifier() {
+        let line = "\tresult := c.Add(a, b)";
+        let at = line.find("Add").unwrap();
+        assert_eq!(word_at(line, at + 1), Some((at, "Add")));
+        assert_eq!(word_at(line, line.find('(').unwrap()), None);
+    }
+
+    #[test]
+    fn test_messages_are_framed() {
+        let mut buf = Vec::new();
+        write_message(&mut buf, &json!({ "id": 1 })).unwrap();
+        assert_eq!(buf, b"Content-Length: 8\r\n\r\n{\"id\":1}");
+
+        let mut input = Cursor::new(buf);
+        assert_eq!(read_message(&mut input).unwrap(), Some(json!({ "id": 1 })));
+        assert_eq!(read_message(&mut input).unwrap(), None);
+    }
+}
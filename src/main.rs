@@ -1,20 +1,40 @@
-use anyhow::Result;
+use anyhow::{Context, Result};
 use clap::Parser;
 use code_navigator::benchmark::{BenchmarkMetrics, BenchmarkTimer};
-use code_navigator::core::{CodeGraph, NodeType};
-use code_navigator::parser::{GoParser, Language, PythonParser, TypeScriptParser};
-use code_navigator::serializer::{csv, dot, fast_compressed, graphml, json, jsonl};
+use code_navigator::blame::Blamer;
+use code_navigator::core::{
+    findings, root_label, BuildSettings, CallSite, CalleeOptions, CodeGraph, DeadCodeOptions,
+    EdgeKind, FieldAccess, FieldAccessKind, Finding, GroupBy, Import, ImportKind, MetricsSort,
+    NodeType, Page, PageRequest, PathOptions, ReferenceKind, SearchOptions, SymbolFilter,
+    UnresolvedReason, ENTRY_POINTS,
+};
+use code_navigator::parser::{
+    self, go_module, BuildContext, Discovery, GoParser, Language, LanguageParser, PythonParser,
+    TypeScriptParser,
+};
+use code_navigator::serializer::file_cache::FileCache;
+use code_navigator::serializer::format::{self, IndexKind};
+use code_navigator::serializer::{
+    csv, dot, fast_compressed, graphml, json, jsonl, markdown, mermaid, sarif,
+};
+use code_navigator::{schema, watch};
 use colored::Colorize;
 
 mod cli;
-use cli::{Cli, Commands};
-use std::collections::HashSet;
+use cli::{Cli, Commands, IndexAction};
+use std::collections::{BTreeMap, HashSet};
+use std::io::Read;
 use std::path::{Path, PathBuf};
 use std::process::Command;
 
 /// Load graph from file, auto-detecting format from extension
-/// Phase 3 optimization: Try to load cached indices first
 fn load_graph(path: &Path) -> Result<CodeGraph> {
+    load_graph_with(path, true)
+}
+
+/// Load graph from file, leaving out a reference table `index --low-memory` saved on
+/// its own unless `references` is set
+fn load_graph_with(path: &Path, references: bool) -> Result<CodeGraph> {
     use code_navigator::serializer::index_cache::SerializedIndices;
 
     let extension = path.extension().and_then(|s| s.to_str()).unwrap_or("bin");
@@ -25,6 +45,9 @@ fn load_graph(path: &Path) -> Result<CodeGraph> {
         "jsonl" => jsonl::load_from_jsonl(&path.to_string_lossy())?, // Legacy JSONL support
         _ => fast_compressed::load_from_file(&path.to_string_lossy())?, // Default: optimized binary (with JSON fallback)
     };
+    if references && graph.metadata.references_spilled {
+        graph.references = fast_compressed::load_references(path)?;
+    }
 
     // Phase 3: Try to load cached indices
     let idx_path = path.with_extension("idx");
@@ -50,8 +73,89 @@ fn load_graph(path: &Path) -> Result<CodeGraph> {
     Ok(graph)
 }
 
+/// Load a graph for querying, honouring the global `--platform`, `--no-indirect`,
+/// `--no-dynamic` and `--show-errors`
+fn open_graph(cli: &Cli, path: &Path) -> Result<CodeGraph> {
+    prepare_graph(cli, load_graph_with(path, cli.command.reads_references())?)
+}
+
+/// Index one source file on its own, or Go source on stdin for `-`, as [`open_graph`] would load an index
+fn open_standalone(cli: &Cli, file: &Path) -> Result<CodeGraph> {
+    let (path, source) = match file.to_str() {
+        Some("-") => {
+            let mut source = String::new();
+            std::io::stdin()
+                .read_to_string(&mut source)
+                .context("Failed to read stdin")?;
+            (PathBuf::from(parser::STDIN_PATH), source)
+        }
+        _ => (
+            file.to_path_buf(),
+            std::fs::read_to_string(file)
+                .with_context(|| format!("Failed to read {}", file.display()))?,
+        ),
+    };
+    // Stdin has no extension to go by and is read as Go
+    let mut parser: Box<dyn LanguageParser> = match path.extension() {
+        None => Box::new(GoParser::new()?),
+        Some(_) => parser::for_path(&path)?,
+    };
+    prepare_graph(cli, parser.index_standalone(&path, &source))
+}
+
+/// Apply the global flags that narrow what a loaded graph shows
+fn prepare_graph(cli: &Cli, mut graph: CodeGraph) -> Result<CodeGraph> {
+    if let Some(platform) = &cli.platform {
+        GoParser::select_platform(&mut graph, &platform.parse()?);
+    }
+    // On stderr, so the command's own output stays parseable
+    if cli.show_errors {
+        if cli.json {
+            eprintln!("{}", serde_json::to_string(&graph.metadata.diagnostics)?);
+        } else {
+            for diagnostic in &graph.metadata.diagnostics {
+                eprintln!("{} {}", "⚠".yellow(), diagnostic);
+            }
+        }
+    }
+    if cli.no_indirect {
+        graph.remove_indirect_calls();
+    }
+    if cli.no_dynamic {
+        graph.remove_dynamic_calls();
+    }
+    if cli.exported_only {
+        graph.hide_unexported();
+    }
+    Ok(graph)
+}
+
+/// ` (go)`, ` (defer)`, ` (indirect)` and ` (dynamic)` markers for a call in tree output
+fn call_tags(kind: EdgeKind, indirect: bool, dynamic: bool) -> String {
+    let mut tags = String::new();
+    if !kind.is_normal() {
+        tags.push_str(&format!(" ({})", kind.as_str()));
+    }
+    if indirect {
+        tags.push_str(" (indirect)");
+    }
+    if dynamic {
+        tags.push_str(" (dynamic)");
+    }
+    tags
+}
+
+/// ` (through helper → format)` for a call standing for calls through unexported
+/// functions hidden by `--exported-only`
+fn hidden_tag(hidden_via: &[String]) -> String {
+    match hidden_via.is_empty() {
+        true => String::new(),
+        false => format!(" (through {})", hidden_via.join(" → ")),
+    }
+}
+
 /// Detect changed files using git
-fn detect_changed_files_git(directory: &Path, file_extension: &str) -> Result<Vec<PathBuf>> {
+fn detect_changed_files_git(directory: &Path, extensions: &[&str]) -> Result<Vec<PathBuf>> {
     // Get files changed compared to HEAD (includes both staged and unstaged)
     let output = Command::new("git")
         .arg("-C")
@@ -70,7 +174,7 @@ fn detect_changed_files_git(directory: &Path, file_extension: &str) -> Result<Ve
 
     for line in stdout.lines() {
         let path = directory.join(line);
-        if path.extension().and_then(|s| s.to_str()) == Some(file_extension) && path.exists() {
+        if has_extension(&path, extensions) && path.exists() {
             changed_files.push(path);
         }
     }
@@ -88,10 +192,7 @@ fn detect_changed_files_git(directory: &Path, file_extension: &str) -> Result<Ve
         let stdout = String::from_utf8_lossy(&output.stdout);
         for line in stdout.lines() {
             let path = directory.join(line);
-            if path.extension().and_then(|s| s.to_str()) == Some(file_extension)
-                && path.exists()
-                && !changed_files.contains(&path)
-            {
+            if has_extension(&path, extensions) && path.exists() && !changed_files.contains(&path) {
                 changed_files.push(path);
             }
         }
@@ -128,15 +229,22 @@ fn count_lines_of_code(path: &Path) -> Result<usize> {
     Ok(count)
 }
 
-/// Count total lines of code in all files with given extension
-fn count_total_loc(directory: &Path, file_ext: &str) -> Result<usize> {
+/// Whether `path` ends in one of `extensions`
+fn has_extension(path: &Path, extensions: &[&str]) -> bool {
+    path.extension()
+        .and_then(|s| s.to_str())
+        .is_some_and(|ext| extensions.contains(&ext))
+}
+
+/// Count total lines of code in all files with the given extensions
+fn count_total_loc(directory: &Path, extensions: &[&str]) -> Result<usize> {
     use walkdir::WalkDir;
 
     let mut total = 0;
     for entry in WalkDir::new(directory)
         .into_iter()
         .filter_map(|e| e.ok())
-        .filter(|e| e.path().extension().and_then(|s| s.to_str()) == Some(file_ext))
+        .filter(|e| has_extension(e.path(), extensions))
     {
         if let Ok(loc) = count_lines_of_code(entry.path()) {
             total += loc;
@@ -149,7 +257,7 @@ fn count_total_loc(directory: &Path, file_ext: &str) -> Result<usize> {
 fn detect_changed_files_timestamp(
     directory: &Path,
     existing_graph: &CodeGraph,
-    file_extension: &str,
+    extensions: &[&str],
 ) -> Result<Vec<PathBuf>> {
     use std::fs;
     use walkdir::WalkDir;
@@ -159,7 +267,7 @@ fn detect_changed_files_timestamp(
     for entry in WalkDir::new(directory)
         .into_iter()
         .filter_map(|e| e.ok())
-        .filter(|e| e.path().extension().and_then(|s| s.to_str()) == Some(file_extension))
+        .filter(|e| has_extension(e.path(), extensions))
     {
         let path = entry.path();
         let path_str = path.to_string_lossy().to_string();
@@ -203,31 +311,379 @@ fn detect_deleted_files(directory: &Path, existing_graph: &CodeGraph) -> Vec<Str
     deleted_files
 }
 
+/// The roots an index or watch of `directory` spans, each with its [`root_label`]: the
+/// `--root` directories, relative to `directory`, or else the modules a go.work in
+/// `directory` uses. Empty when `directory` is indexed as a single root.
+fn index_roots(directory: &Path, roots: &[PathBuf]) -> Result<Vec<(String, PathBuf)>> {
+    let dirs = match roots.is_empty() {
+        true => go_module::workspace_roots(directory).unwrap_or_default(),
+        false => roots
+            .iter()
+            .map(|root| go_module::join_relative(directory, root))
+            .collect(),
+    };
+    let mut labelled: Vec<(String, PathBuf)> = Vec::new();
+    for dir in dirs {
+        if !dir.is_dir() {
+            anyhow::bail!("Root not found: {}", dir.display());
+        }
+        let label = root_label(directory, &dir);
+        if !labelled.iter().any(|(existing, _)| *existing == label) {
+            labelled.push((label, dir));
+        }
+    }
+    Ok(labelled)
+}
+
+/// Fail on a `--root` filter naming none of the graph's roots, listing the ones it has
+fn check_root(graph: &CodeGraph, root: Option<&str>) -> Result<()> {
+    let Some(root) = root else {
+        return Ok(());
+    };
+    let label = root_label(Path::new(""), Path::new(root));
+    if graph.metadata.roots.contains(&label) {
+        return Ok(());
+    }
+    match graph.metadata.roots.is_empty() {
+        true => anyhow::bail!(
+            "The index of {} has no roots to filter by; index it with --root",
+            graph.metadata.root_path
+        ),
+        false => anyhow::bail!(
+            "Unknown root {}; the index has {}",
+            root,
+            graph.metadata.roots.join(", ")
+        ),
+    }
+}
+
+/// The filter a `--root` flag stands for, once the index is known to have that root
+fn root_filter(graph: &CodeGraph, root: Option<&str>) -> Result<SymbolFilter> {
+    check_root(graph, root)?;
+    Ok(match root {
+        Some(root) => SymbolFilter::default().with_root(root),
+        None => SymbolFilter::default(),
+    })
+}
+
+/// `--json` overrides a command's `--output` format
+fn output_format<'a>(cli: &Cli, output: &'a str) -> &'a str {
+    if cli.json {
+        "json"
+    } else {
+        output
+    }
+}
+
+/// Print `results` as JSON: the bare list, or with `--limit` or `--offset` the page with
+/// its position in the whole list
+fn print_page_json<T, U: serde::Serialize>(
+    page: &Page<T>,
+    limit: Option<usize>,
+    offset: Option<usize>,
+    results: Vec<U>,
+) -> Result<()> {
+    schema::print_json(&schema::Listing::new(page, limit, offset, results))
+}
+
+/// The closing line of a text listing: the number found or, for a page, which part of
+/// the whole it shows and how to ask for the next
+fn print_page_summary<T>(page: &Page<T>, paged: bool, noun: &str) {
+    if !paged {
+        println!("{} {} {} found", "→".blue(), page.total, noun);
+        return;
+    }
+    let shown = match page.items.len() {
+        0 => "none".to_string(),
+        n => format!("{}–{}", page.offset + 1, page.offset + n),
+    };
+    println!(
+        "{} showing {} of {} {}",
+        "→".blue(),
+        shown,
+        page.total,
+        noun
+    );
+    if let Some(next) = page.next_offset() {
+        println!("  {}", format!("next page: --offset {}", next).dimmed());
+    }
+}
+
+/// Draw outline entries under `prefix` with box-drawing branches, each followed by
+/// the lines its declaration spans
+fn print_outline(entries: &[code_navigator::core::OutlineEntry], prefix: &str) {
+    for (i, entry) in entries.iter().enumerate() {
+        let last = i + 1 == entries.len();
+        let node = entry.node;
+        let lines = match node.end_line > node.line {
+            true => format!("{}-{}", node.line, node.end_line),
+            false => node.line.to_string(),
+        };
+        println!(
+            "{}{} {}  {}",
+            prefix,
+            if last { "└──" } else { "├──" },
+            node.declaration(),
+            lines.dimmed()
+        );
+        let nested = format!("{}{}", prefix, if last { "    " } else { "│   " });
+        print_outline(&entry.children, &nested);
+    }
+}
+
+/// Draw the types `entry` embeds under `prefix`, each with where it is declared
+fn print_hierarchy(entry: &code_navigator::core::HierarchyEntry, prefix: &str) {
+    for (i, embedded) in entry.embeds.iter().enumerate() {
+        let last = i + 1 == entry.embeds.len();
+        let name = match embedded.pointer {
+            true => format!("*{}", embedded.name),
+            false => embedded.name.clone(),
+        };
+        let note = match embedded.node {
+            None => "[external]".to_string(),
+            Some(node) if embedded.cycle => {
+                format!("({}:{}) [cycle]", node.file_path.display(), node.line)
+            }
+            Some(node) => format!("({}:{})", node.file_path.display(), node.line),
+        };
+        println!(
+            "{}{} {} {}",
+            prefix,
+            if last { "└─" } else { "├─" },
+            name.cyan(),
+            note.dimmed()
+        );
+        let nested = format!("{}{}", prefix, if last { "   " } else { "│  " });
+        print_hierarchy(embedded, &nested);
+    }
+}
+
+/// Index `directory` in memory with default discovery, for commands that compare
+/// trees rather than write an index
+fn index_directory(directory: &Path, language: &str) -> Result<CodeGraph> {
+    if !directory.is_dir() {
+        anyhow::bail!("Directory not found: {}", directory.display());
+    }
+    let mut graph = CodeGraph::new(
+        directory.to_string_lossy().to_string(),
+        language.to_string(),
+    );
+    match language {
+        "go" => GoParser::new()?.parse_directory(directory, &mut graph)?,
+        "typescript" | "ts" => {
+            TypeScriptParser::new(Language::TypeScript)?.parse_directory(directory, &mut graph)?
+        }
+        "javascript" | "js" => {
+            TypeScriptParser::new(Language::JavaScript)?.parse_directory(directory, &mut graph)?
+        }
+        "python" | "py" => PythonParser::new()?.parse_directory(directory, &mut graph)?,
+        _ => anyhow::bail!("Unsupported language: {}", language),
+    }
+    Ok(graph)
+}
+
+/// A directory to index, or a graph file written by `index`
+fn load_snapshot(path: &Path, language: &str) -> Result<CodeGraph> {
+    if path.is_dir() {
+        index_directory(path, language)
+    } else {
+        load_graph(path)
+    }
+}
+
+/// Run git in `directory` and return its trimmed output
+fn git(directory: &Path, args: &[&str]) -> Result<String> {
+    let output = Command::new("git")
+        .arg("-C")
+        .arg(directory)
+        .args(args)
+        .output()
+        .context("Failed to run git")?;
+    if !output.status.success() {
+        anyhow::bail!(
+            "git {} failed: {}",
+            args.join(" "),
+            String::from_utf8_lossy(&output.stderr).trim()
+        );
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).trim().to_string())
+}
+
+/// A detached checkout of a git ref in a temporary directory, removed when dropped
+struct Worktree {
+    repo: PathBuf,
+    path: PathBuf,
+}
+
+impl Worktree {
+    /// Check out `rev` of the repository containing `directory`; `name` keeps
+    /// simultaneous checkouts apart
+    fn add(directory: &Path, rev: &str, name: &str) -> Result<Self> {
+        let repo = PathBuf::from(git(directory, &["rev-parse", "--show-toplevel"])?);
+        let path = std::env::temp_dir().join(format!("codenav-{}-{}", std::process::id(), name));
+        let _ = std::fs::remove_dir_all(&path);
+        let target = path.to_string_lossy().to_string();
+        git(
+            &repo,
+            &["worktree", "add", "--detach", "--quiet", &target, rev],
+        )
+        .with_context(|| format!("Failed to check out {}", rev))?;
+        Ok(Self { repo, path })
+    }
+}
+
+impl Drop for Worktree {
+    fn drop(&mut self) {
+        let target = self.path.to_string_lossy().to_string();
+        let _ = git(&self.repo, &["worktree", "remove", "--force", &target]);
+    }
+}
+
+/// Exit status of `path` when the target can't be reached, distinct from errors (1)
+const EXIT_NO_PATH: i32 = 2;
+
+/// Exit status of `rename` when the new name collides with an existing symbol
+const EXIT_RENAME_CONFLICT: i32 = 2;
+
+/// Exit status of `complexity` when a function exceeds the threshold
+const EXIT_TOO_COMPLEX: i32 = 2;
+
+/// Exit status of `diff` when the snapshots differ
+const EXIT_CHANGED: i32 = 2;
+
 fn main() -> Result<()> {
-    let cli = Cli::parse();
+    let mut cli = Cli::parse();
+    // JSON goes to stdout alone; progress messages would corrupt it
+    if cli.json {
+        cli.quiet = true;
+    }
 
     match &cli.command {
         Commands::Index {
+            action: Some(IndexAction::Inspect { file, output }),
+            ..
+        } => {
+            let output = output_format(&cli, output);
+            let header = format::inspect(file)?;
+            match output {
+                "text" => {
+                    let kind = match header.kind {
+                        IndexKind::Graph => "graph index",
+                        IndexKind::FileCache => "per-file cache",
+                    };
+                    let supported = header.supported_version();
+                    let status = match header.format_version.cmp(&supported) {
+                        std::cmp::Ordering::Equal => "current".green(),
+                        std::cmp::Ordering::Less if header.kind == IndexKind::Graph => {
+                            "older, loads as is".yellow()
+                        }
+                        std::cmp::Ordering::Less => "older, rebuilt by the next index".yellow(),
+                        std::cmp::Ordering::Greater => {
+                            format!("newer than format {} this build reads", supported).red()
+                        }
+                    };
+                    println!(
+                        "{}: {}, format {} ({})",
+                        file.display().to_string().bold(),
+                        kind,
+                        header.format_version,
+                        status
+                    );
+                    let unknown = || "unknown".dimmed().to_string();
+                    println!(
+                        "  Written by: {}",
+                        header
+                            .tool_version
+                            .as_ref()
+                            .map_or_else(unknown, |v| format!("code-navigator {}", v))
+                    );
+                    println!(
+                        "  Created:    {}",
+                        header.created_at.clone().unwrap_or_else(unknown)
+                    );
+                    println!("  Language:   {}", header.language);
+                    if let Some(root) = &header.root_path {
+                        println!("  Root:       {}", root);
+                    }
+                    println!("  Files:      {}", header.files.to_string().cyan());
+                    if let (Some(symbols), Some(calls)) = (header.symbols, header.calls) {
+                        println!("  Symbols:    {}", symbols.to_string().cyan());
+                        println!("  Calls:      {}", calls.to_string().cyan());
+                    }
+                }
+                "json" => schema::print_json(&schema::Versioned::new(&header))?,
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Index {
+            action: _,
             directory,
+            root,
             output,
             language,
-            exclude: _,
-            include_tests: _,
+            exclude,
+            no_gitignore,
+            include_hidden,
+            include_generated,
+            include_tests,
             incremental,
             force,
+            no_cache,
+            include_vendor,
+            goos,
+            goarch,
+            tags,
+            go_version,
+            all_platforms,
+            jobs,
+            low_memory,
             benchmark,
             benchmark_json,
         } => {
-            let lang = language.as_deref().unwrap_or("go");
-
-            // Determine file extension for the language
-            let file_ext = match lang {
-                "go" => "go",
-                "typescript" | "ts" => "ts",
-                "javascript" | "js" => "js",
-                "python" | "py" => "py",
-                _ => anyhow::bail!("Unsupported language: {}", lang),
+            // Clap requires the directory unless a subcommand or --root is given
+            let directory = match directory {
+                Some(directory) => directory.clone(),
+                None if !root.is_empty() => PathBuf::from("."),
+                None => anyhow::bail!("A directory to index is required"),
+            };
+            let directory = &directory;
+            let roots = index_roots(directory, root)?;
+            let root_dirs: Vec<&Path> = match roots.is_empty() {
+                true => vec![directory.as_path()],
+                false => roots.iter().map(|(_, dir)| dir.as_path()).collect(),
+            };
+            let index_start = std::time::Instant::now();
+            let discovery = Discovery::new()
+                .with_excludes(exclude)
+                .with_gitignore(!no_gitignore)
+                .with_hidden(*include_hidden)
+                .with_generated(*include_generated);
+            let mut build = BuildContext::host();
+            if let Some(goos) = goos {
+                build.goos = goos.clone();
+            }
+            if let Some(goarch) = goarch {
+                build.goarch = goarch.clone();
+            }
+            build.tags.extend(tags.iter().cloned());
+            if let Some(version) = go_version {
+                build.go_version = Some(parser::parse_go_version(version)?);
+            }
+
+            // Without --language, every language found in the tree goes into one graph
+            let languages = match language {
+                Some(language) => vec![language.as_str()],
+                None => match parser::languages_in(&discovery.files(directory)) {
+                    found if found.is_empty() => vec!["go"],
+                    found => found,
+                },
             };
+            let lang = languages.join(",");
+            let mut extensions = Vec::new();
+            for language in &languages {
+                extensions.extend_from_slice(parser::extensions_of(language)?);
+            }
 
             // Initialize benchmark timer if requested
             let mut bench_timer = if *benchmark {
@@ -242,7 +698,7 @@ fn main() -> Result<()> {
                     println!("{}", "Counting lines of code...".dimmed());
                 }
                 let discovery_start = std::time::Instant::now();
-                let loc = count_total_loc(directory, file_ext)?;
+                let loc = count_total_loc(directory, &extensions)?;
                 if let Some(ref mut timer) = bench_timer {
                     timer.discovery_duration = Some(discovery_start.elapsed());
                 }
@@ -251,17 +707,35 @@ fn main() -> Result<()> {
                 0
             };
 
-            // Check if incremental mode is requested
-            let should_use_incremental = *incremental && !force && output.exists();
+            // Files left out by one set of build settings can't be added by an update
+            // with another, so the graph records them
+            let build_settings = languages.contains(&"go").then(|| BuildSettings {
+                goos: build.goos.clone(),
+                goarch: build.goarch.clone(),
+                tags: build.tags.iter().cloned().collect(),
+                go_version: build.go_version,
+                cgo: build.cgo,
+                all_platforms: *all_platforms,
+                include_vendor: *include_vendor,
+                include_tests: *include_tests,
+            });
 
-            let graph = if should_use_incremental {
-                // INCREMENTAL MODE
+            // Check if incremental mode is requested
+            let existing = if *incremental && !force && output.exists() {
                 if !cli.quiet {
                     println!("{}", "Incremental update mode...".green().bold());
                 }
-
-                // Load existing graph
-                let mut existing_graph = match load_graph(output) {
+                match load_graph(output) {
+                    Ok(g) if g.metadata.build != build_settings => {
+                        if !cli.quiet {
+                            println!(
+                                "{} Existing graph was indexed with other build settings",
+                                "⚠".yellow()
+                            );
+                            println!("{} Performing full generation...", "→".blue());
+                        }
+                        None
+                    }
                     Ok(g) => {
                         if !cli.quiet {
                             println!(
@@ -270,20 +744,26 @@ fn main() -> Result<()> {
                                 g.nodes.len().to_string().cyan()
                             );
                         }
-                        g
+                        Some(g)
                     }
                     Err(e) => {
                         if !cli.quiet {
                             println!("{} Failed to load existing graph: {}", "⚠".yellow(), e);
                             println!("{} Performing full generation...", "→".blue());
                         }
-                        CodeGraph::new(directory.to_string_lossy().to_string(), lang.to_string())
+                        None
                     }
-                };
+                }
+            } else {
+                None
+            };
+
+            let mut graph = if let Some(mut existing_graph) = existing {
+                // INCREMENTAL MODE
 
                 // Try git first, fallback to timestamps
                 let (changed_files, detection_method) =
-                    match detect_changed_files_git(directory, file_ext) {
+                    match detect_changed_files_git(directory, &extensions) {
                         Ok(files) => (files, "git"),
                         Err(_) => {
                             if !cli.quiet {
@@ -296,13 +776,24 @@ fn main() -> Result<()> {
                                 detect_changed_files_timestamp(
                                     directory,
                                     &existing_graph,
-                                    file_ext,
+                                    &extensions,
                                 )?,
                                 "timestamps",
                             )
                         }
                     };
 
+                // Changes to ignored or excluded files, or files outside the roots, don't
+                // touch the index
+                let discovered: HashSet<PathBuf> = root_dirs
+                    .iter()
+                    .flat_map(|dir| discovery.files(dir))
+                    .collect();
+                let changed_files: Vec<PathBuf> = changed_files
+                    .into_iter()
+                    .filter(|path| discovered.contains(path))
+                    .collect();
+
                 // Detect deleted files
                 let deleted_files = detect_deleted_files(directory, &existing_graph);
 
@@ -340,110 +831,78 @@ fn main() -> Result<()> {
                 let files_to_parse: HashSet<_> = changed_files.iter().collect();
                 let mut files_parsed = 0;
 
-                // Create temporary parser based on language
-                match lang {
-                    "go" => {
-                        let mut parser = GoParser::new()?;
-                        for file_path in &files_to_parse {
-                            if let Err(e) = parser.parse_file(file_path, &mut existing_graph) {
-                                if !cli.quiet {
-                                    println!(
-                                        "{} Failed to parse {}: {}",
-                                        "⚠".yellow(),
-                                        file_path.display(),
-                                        e
-                                    );
-                                }
-                            } else {
-                                files_parsed += 1;
-                                // Track file metadata
-                                if let Ok(metadata) = fs::metadata(file_path) {
-                                    if let Ok(modified) = metadata.modified() {
-                                        existing_graph.track_file_metadata(
-                                            file_path,
-                                            format!("{:?}", modified),
-                                        );
-                                    }
-                                }
-                            }
-                        }
-                    }
-                    "typescript" | "ts" => {
-                        let mut parser = TypeScriptParser::new(Language::TypeScript)?;
-                        for file_path in &files_to_parse {
-                            if let Err(e) = parser.parse_file(file_path, &mut existing_graph) {
-                                if !cli.quiet {
-                                    println!(
-                                        "{} Failed to parse {}: {}",
-                                        "⚠".yellow(),
-                                        file_path.display(),
-                                        e
-                                    );
-                                }
-                            } else {
-                                files_parsed += 1;
-                                if let Ok(metadata) = fs::metadata(file_path) {
-                                    if let Ok(modified) = metadata.modified() {
-                                        existing_graph.track_file_metadata(
-                                            file_path,
-                                            format!("{:?}", modified),
-                                        );
-                                    }
-                                }
+                // One parser per language, picked by each file's extension. Go files are
+                // chosen by the same build context and filters as a full run.
+                let mut go_parser = GoParser::new()?
+                    .with_discovery(discovery.clone())
+                    .with_build_context(build.clone())
+                    .with_all_platforms(*all_platforms)
+                    .with_vendor(*include_vendor)
+                    .with_tests(*include_tests);
+                let mut parsers: BTreeMap<&str, Box<dyn LanguageParser>> = BTreeMap::new();
+                for file_path in &files_to_parse {
+                    let Some(file_language) = parser::language_of(file_path) else {
+                        continue;
+                    };
+                    let parsed = if file_language == "go" {
+                        let root_dir = root_dirs
+                            .iter()
+                            .filter(|dir| file_path.starts_with(dir))
+                            .max_by_key(|dir| dir.components().count())
+                            .copied()
+                            .unwrap_or(directory.as_path());
+                        go_parser.parse_changed_file(root_dir, file_path, &mut existing_graph)
+                    } else {
+                        let parser = match parsers.entry(file_language) {
+                            std::collections::btree_map::Entry::Occupied(entry) => entry.into_mut(),
+                            std::collections::btree_map::Entry::Vacant(entry) => {
+                                entry.insert(parser::for_language(file_language)?)
                             }
-                        }
-                    }
-                    "javascript" | "js" => {
-                        let mut parser = TypeScriptParser::new(Language::JavaScript)?;
-                        for file_path in &files_to_parse {
-                            if let Err(e) = parser.parse_file(file_path, &mut existing_graph) {
-                                if !cli.quiet {
-                                    println!(
-                                        "{} Failed to parse {}: {}",
-                                        "⚠".yellow(),
-                                        file_path.display(),
-                                        e
-                                    );
-                                }
-                            } else {
-                                files_parsed += 1;
-                                if let Ok(metadata) = fs::metadata(file_path) {
-                                    if let Ok(modified) = metadata.modified() {
-                                        existing_graph.track_file_metadata(
-                                            file_path,
-                                            format!("{:?}", modified),
-                                        );
-                                    }
-                                }
+                        };
+                        parser
+                            .parse_file(file_path, &mut existing_graph)
+                            .map(|()| true)
+                    };
+                    match parsed {
+                        Err(e) => {
+                            if !cli.quiet {
+                                println!(
+                                    "{} Failed to parse {}: {}",
+                                    "⚠".yellow(),
+                                    file_path.display(),
+                                    e
+                                );
                             }
+                            continue;
                         }
+                        // Left out like a full run leaves it out, but still tracked
+                        Ok(false) => {}
+                        Ok(true) => files_parsed += 1,
                     }
-                    "python" | "py" => {
-                        let mut parser = PythonParser::new()?;
-                        for file_path in &files_to_parse {
-                            if let Err(e) = parser.parse_file(file_path, &mut existing_graph) {
-                                if !cli.quiet {
-                                    println!(
-                                        "{} Failed to parse {}: {}",
-                                        "⚠".yellow(),
-                                        file_path.display(),
-                                        e
-                                    );
-                                }
-                            } else {
-                                files_parsed += 1;
-                                if let Ok(metadata) = fs::metadata(file_path) {
-                                    if let Ok(modified) = metadata.modified() {
-                                        existing_graph.track_file_metadata(
-                                            file_path,
-                                            format!("{:?}", modified),
-                                        );
-                                    }
-                                }
-                            }
+                    // Track file metadata
+                    if let Ok(metadata) = fs::metadata(file_path) {
+                        if let Ok(modified) = metadata.modified() {
+                            existing_graph
+                                .track_file_metadata(file_path, format!("{:?}", modified));
                         }
                     }
-                    _ => unreachable!(),
+                }
+                // Re-parsed files take their root again, before calls bind across roots
+                if !roots.is_empty() {
+                    existing_graph.metadata.roots =
+                        roots.iter().map(|(label, _)| label.clone()).collect();
+                }
+                existing_graph.assign_roots();
+                // Re-link receiver method calls against the updated method set
+                if languages.contains(&"go") {
+                    GoParser::resolve_calls(&mut existing_graph);
+                }
+                // Imports into changed or deleted modules are bound again
+                if languages
+                    .iter()
+                    .any(|language| matches!(*language, "typescript" | "ts" | "javascript" | "js"))
+                {
+                    TypeScriptParser::resolve_imports(&mut existing_graph);
                 }
 
                 // Update metadata
@@ -451,6 +910,8 @@ fn main() -> Result<()> {
                 existing_graph.metadata.stats.files_parsed = files_parsed;
                 existing_graph.metadata.stats.total_nodes = existing_graph.nodes.len();
                 existing_graph.metadata.stats.total_edges = existing_graph.edges.len();
+                // The per-file cache is only consulted by full runs
+                existing_graph.metadata.stats.cache = None;
                 existing_graph.metadata.git_commit_hash = get_git_commit_hash(directory);
 
                 let files_cached =
@@ -487,7 +948,7 @@ fn main() -> Result<()> {
                 }
 
                 let mut new_graph =
-                    CodeGraph::new(directory.to_string_lossy().to_string(), lang.to_string());
+                    CodeGraph::new(directory.to_string_lossy().to_string(), lang.clone());
 
                 // Start timing parse phase
                 let parse_start = if bench_timer.is_some() {
@@ -496,25 +957,109 @@ fn main() -> Result<()> {
                     None
                 };
 
-                match lang {
-                    "go" => {
-                        let mut parser = GoParser::new()?;
-                        parser.parse_directory(directory, &mut new_graph)?;
+                // Every language found is parsed into the graph of one root, with the root's
+                // own per-file cache
+                let parse_root = |directory: &Path, new_graph: &mut CodeGraph| -> Result<_> {
+                    let mut cache_stats = None;
+                    let mut files_parsed = 0;
+                    for language in &languages {
+                        match *language {
+                            "go" if !*no_cache => {
+                                let cache_path = FileCache::default_path(directory);
+                                let mut cache = if cache_path.exists() {
+                                    FileCache::load(&cache_path, "go").unwrap_or_else(|e| {
+                                        if !cli.quiet {
+                                            println!(
+                                                "{} Discarding index cache: {}",
+                                                "⚠".yellow(),
+                                                e
+                                            );
+                                        }
+                                        FileCache::new("go")
+                                    })
+                                } else {
+                                    FileCache::new("go")
+                                };
+                                let mut parser = GoParser::new()?
+                                    .with_discovery(discovery.clone())
+                                    .with_build_context(build.clone())
+                                    .with_all_platforms(*all_platforms)
+                                    .with_vendor(*include_vendor)
+                                    .with_tests(*include_tests)
+                                    .with_low_memory(*low_memory);
+                                cache_stats = Some(parser.parse_directory_cached(
+                                    directory,
+                                    new_graph,
+                                    Some(&mut cache),
+                                )?);
+                                if let Err(e) = cache.save(&cache_path) {
+                                    if !cli.quiet {
+                                        println!(
+                                            "{} Failed to write index cache: {}",
+                                            "⚠".yellow(),
+                                            e
+                                        );
+                                    }
+                                }
+                            }
+                            "go" => {
+                                let mut parser = GoParser::new()?
+                                    .with_discovery(discovery.clone())
+                                    .with_build_context(build.clone())
+                                    .with_all_platforms(*all_platforms)
+                                    .with_vendor(*include_vendor)
+                                    .with_tests(*include_tests)
+                                    .with_low_memory(*low_memory);
+                                parser.parse_directory(directory, new_graph)?;
+                            }
+                            "typescript" | "ts" => {
+                                let mut parser = TypeScriptParser::new(Language::TypeScript)?
+                                    .with_discovery(discovery.clone());
+                                parser.parse_directory(directory, new_graph)?;
+                            }
+                            "javascript" | "js" => {
+                                let mut parser = TypeScriptParser::new(Language::JavaScript)?
+                                    .with_discovery(discovery.clone());
+                                parser.parse_directory(directory, new_graph)?;
+                            }
+                            "python" | "py" => {
+                                let mut parser =
+                                    PythonParser::new()?.with_discovery(discovery.clone());
+                                parser.parse_directory(directory, new_graph)?;
+                            }
+                            _ => unreachable!(),
+                        }
+                        // Each backend counts only its own files
+                        files_parsed += new_graph.metadata.stats.files_parsed;
+                    }
+                    new_graph.metadata.stats.files_parsed = files_parsed;
+                    Ok(cache_stats)
+                };
+
+                // Files parse on a pool of --jobs threads; resolution runs after the merge
+                let cache_stats = parser::with_jobs(*jobs, || -> Result<_> {
+                    if roots.is_empty() {
+                        return parse_root(directory, &mut new_graph);
                     }
-                    "typescript" | "ts" => {
-                        let mut parser = TypeScriptParser::new(Language::TypeScript)?;
-                        parser.parse_directory(directory, &mut new_graph)?;
+                    let mut root_graphs = Vec::new();
+                    for (label, dir) in &roots {
+                        let mut root_graph =
+                            CodeGraph::new(dir.to_string_lossy().to_string(), lang.clone());
+                        parse_root(dir, &mut root_graph)?;
+                        root_graphs.push((label.clone(), root_graph));
                     }
-                    "javascript" | "js" => {
-                        let mut parser = TypeScriptParser::new(Language::JavaScript)?;
-                        parser.parse_directory(directory, &mut new_graph)?;
+                    new_graph.merge_roots(root_graphs);
+                    // Calls from one root into another bind once every root is merged
+                    if languages.contains(&"go") {
+                        GoParser::resolve_calls(&mut new_graph);
                     }
-                    "python" | "py" => {
-                        let mut parser = PythonParser::new()?;
-                        parser.parse_directory(directory, &mut new_graph)?;
+                    if languages.iter().any(|language| {
+                        matches!(*language, "typescript" | "ts" | "javascript" | "js")
+                    }) {
+                        TypeScriptParser::resolve_imports(&mut new_graph);
                     }
-                    _ => unreachable!(),
-                }
+                    Ok(new_graph.metadata.stats.cache)
+                })??;
 
                 // Record parse duration
                 if let (Some(ref mut timer), Some(start)) = (&mut bench_timer, parse_start) {
@@ -523,24 +1068,20 @@ fn main() -> Result<()> {
 
                 // Track all files in metadata
                 use std::fs;
-                use walkdir::WalkDir;
-                for entry in WalkDir::new(directory)
-                    .into_iter()
-                    .filter_map(|e| e.ok())
-                    .filter(|e| e.path().extension().and_then(|s| s.to_str()) == Some(file_ext))
+                for path in root_dirs
+                    .iter()
+                    .flat_map(|dir| discovery.files(dir))
+                    .filter(|path| has_extension(path, &extensions))
                 {
-                    let path = entry.path();
-                    if let Ok(metadata) = fs::metadata(path) {
+                    if let Ok(metadata) = fs::metadata(&path) {
                         if let Ok(modified) = metadata.modified() {
-                            new_graph.track_file_metadata(
-                                &path.to_path_buf(),
-                                format!("{:?}", modified),
-                            );
+                            new_graph.track_file_metadata(&path, format!("{:?}", modified));
                         }
                     }
                 }
 
                 new_graph.metadata.git_commit_hash = get_git_commit_hash(directory);
+                new_graph.metadata.build = build_settings.clone();
 
                 if !cli.quiet {
                     println!(
@@ -554,11 +1095,27 @@ fn main() -> Result<()> {
                         "→".blue(),
                         new_graph.metadata.stats.files_parsed.to_string().cyan()
                     );
+                    if !new_graph.metadata.roots.is_empty() {
+                        println!(
+                            "  {} Roots: {}",
+                            "→".blue(),
+                            new_graph.metadata.roots.join(", ").cyan()
+                        );
+                    }
+                    if let Some(stats) = cache_stats {
+                        println!(
+                            "  {} Files cached: {}",
+                            "→".blue(),
+                            stats.hits.to_string().green()
+                        );
+                    }
                 }
 
                 new_graph
             };
 
+            graph.metadata.stats.index_duration_ms = Some(index_start.elapsed().as_millis() as u64);
+
             // Save in binary format (compressed)
             let serialization_start = if bench_timer.is_some() {
                 Some(std::time::Instant::now())
@@ -566,7 +1123,16 @@ fn main() -> Result<()> {
                 None
             };
 
+            // References are saved beside the graph, so commands that don't use them
+            // load less
+            graph.metadata.references_spilled = *low_memory;
+            if *low_memory {
+                fast_compressed::save_references(&std::mem::take(&mut graph.references), output)?;
+            }
             fast_compressed::save_to_file(&graph, &output.to_string_lossy())?;
+            if !*low_memory {
+                let _ = std::fs::remove_file(fast_compressed::references_path(output));
+            }
 
             // Record serialization duration
             if let (Some(ref mut timer), Some(start)) = (&mut bench_timer, serialization_start) {
@@ -581,6 +1147,14 @@ fn main() -> Result<()> {
                 );
             }
 
+            if cli.json {
+                schema::print_json(&schema::IndexSummary::new(&graph, output))?;
+            } else {
+                for diagnostic in &graph.metadata.diagnostics {
+                    eprintln!("{} {}", "⚠".yellow(), diagnostic);
+                }
+            }
+
             // Display benchmark results if enabled
             if *benchmark {
                 if let Some(timer) = bench_timer {
@@ -591,7 +1165,7 @@ fn main() -> Result<()> {
                         &timer,
                         total_loc,
                         graph.metadata.stats.files_parsed,
-                        lang.to_string(),
+                        lang.clone(),
                         graph.nodes.len(),
                         graph.edges.len(),
                         output_size,
@@ -630,33 +1204,233 @@ fn main() -> Result<()> {
             }
         }
 
-        Commands::Query {
+        Commands::Lsp => {
+            let stdin = std::io::stdin();
+            code_navigator::lsp::serve(stdin.lock(), std::io::stdout().lock())?;
+        }
+
+        Commands::Serve {
             graph: graph_file,
-            output,
-            count,
-            limit,
-            name,
-            r#type,
-            package,
-            file,
-            tag: _,
+            addr,
         } => {
-            use std::time::Instant;
-
-            let load_start = Instant::now();
-            let graph = load_graph(graph_file)?;
-            let load_time = load_start.elapsed();
-
-            let query_start = Instant::now();
+            use std::sync::atomic::AtomicBool;
+            use std::sync::Arc;
 
-            // Phase 1 Optimization: Use index-based queries instead of linear scans
-            // Apply filters in optimal order (most selective first)
+            let graph = open_graph(&cli, graph_file)?;
+            let listener = std::net::TcpListener::bind(addr)
+                .with_context(|| format!("Failed to listen on {}", addr))?;
 
-            let mut nodes: Vec<&code_navigator::core::Node> = Vec::new();
-            let mut using_index = false;
+            // SIGTERM and Ctrl-C stop accepting; the request in flight still gets its answer
+            let shutdown = Arc::new(AtomicBool::new(false));
+            for signal in [signal_hook::consts::SIGTERM, signal_hook::consts::SIGINT] {
+                signal_hook::flag::register(signal, Arc::clone(&shutdown))?;
+            }
 
-            // Priority 1: Exact name match (O(1) hash lookup)
-            if let Some(name_filter) = name {
+            if !cli.quiet {
+                eprintln!(
+                    "{} Serving {} ({} symbols) on http://{}",
+                    "✓".green().bold(),
+                    graph_file.display(),
+                    graph.nodes.len(),
+                    listener.local_addr()?
+                );
+            }
+            code_navigator::http::serve(&graph, &listener, &shutdown)?;
+            if !cli.quiet {
+                eprintln!("{} Shut down", "→".blue());
+            }
+        }
+
+        Commands::Mcp {
+            directory,
+            include_tests,
+            max_snippet_bytes,
+        } => {
+            let options = code_navigator::mcp::McpOptions {
+                include_tests: *include_tests,
+                max_snippet_bytes: *max_snippet_bytes,
+            };
+            let stdin = std::io::stdin();
+            code_navigator::mcp::serve(
+                directory,
+                &options,
+                stdin.lock(),
+                std::io::stdout().lock(),
+            )?;
+        }
+
+        Commands::Watch {
+            directory,
+            root: roots,
+            output,
+            debounce_ms,
+        } => {
+            use notify::Watcher;
+
+            // Watcher events carry absolute paths; index with the same spelling
+            let root = directory
+                .canonicalize()
+                .with_context(|| format!("Directory not found: {}", directory.display()))?;
+            let mut roots = index_roots(&root, roots)?;
+            for (_, dir) in &mut roots {
+                *dir = dir.canonicalize()?;
+            }
+            let mut graph = CodeGraph::new(root.to_string_lossy().to_string(), "go".to_string());
+            let mut parser = GoParser::new()?;
+            if roots.is_empty() {
+                parser.parse_directory(&root, &mut graph)?;
+            } else {
+                let mut root_graphs = Vec::new();
+                for (label, dir) in &roots {
+                    let mut root_graph =
+                        CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+                    parser.parse_directory(dir, &mut root_graph)?;
+                    root_graphs.push((label.clone(), root_graph));
+                }
+                graph.merge_roots(root_graphs);
+                GoParser::resolve_calls(&mut graph);
+            }
+
+            let (tx, rx) = std::sync::mpsc::channel();
+            let watch_root = root.clone();
+            let root_dirs: Vec<PathBuf> = roots.iter().map(|(_, dir)| dir.clone()).collect();
+            let mut watcher =
+                notify::recommended_watcher(move |res: notify::Result<notify::Event>| {
+                    let Ok(event) = res else {
+                        return;
+                    };
+                    if event.kind.is_access() {
+                        return;
+                    }
+                    for path in event.paths {
+                        // Files outside every root of a multi-root index are not indexed
+                        if watch::is_go_source(&path)
+                            && !go_module::in_ignored_dir(&watch_root, &path, false)
+                            && (root_dirs.is_empty()
+                                || root_dirs.iter().any(|dir| path.starts_with(dir)))
+                        {
+                            let _ = tx.send(path);
+                        }
+                    }
+                })?;
+            watcher.watch(&root, notify::RecursiveMode::Recursive)?;
+
+            if !cli.quiet {
+                println!(
+                    "{} Watching {} ({} nodes, {} edges); press Ctrl-C to stop",
+                    "✓".green().bold(),
+                    root.display().to_string().cyan(),
+                    graph.nodes.len().to_string().cyan(),
+                    graph.edges.len().to_string().cyan()
+                );
+            }
+
+            let quiet = std::time::Duration::from_millis(*debounce_ms);
+            while let Some(paths) = watch::debounce(&rx, quiet) {
+                for path in paths {
+                    let update = match watch::update_go_file(&mut graph, &mut parser, &path) {
+                        Ok(update) => update,
+                        Err(e) => {
+                            eprintln!(
+                                "{} Failed to re-index {}: {}",
+                                "⚠".yellow(),
+                                path.display(),
+                                e
+                            );
+                            continue;
+                        }
+                    };
+                    if update.is_empty() {
+                        continue;
+                    }
+                    if cli.json {
+                        schema::write_json_line(
+                            &mut std::io::stdout().lock(),
+                            &schema::Versioned::new(&update),
+                        )?;
+                        continue;
+                    }
+                    println!(
+                        "{} {}: {} added, {} removed, {} changed",
+                        "↻".blue().bold(),
+                        update.file,
+                        update.added.len().to_string().green(),
+                        update.removed.len().to_string().red(),
+                        update.changed.len().to_string().yellow()
+                    );
+                    for name in &update.added {
+                        println!("  {} {}", "+".green(), name);
+                    }
+                    for name in &update.removed {
+                        println!("  {} {}", "-".red(), name);
+                    }
+                    for name in &update.changed {
+                        println!("  {} {}", "~".yellow(), name);
+                    }
+                }
+
+                if let Some(output) = output {
+                    if let Err(e) = fast_compressed::save_to_file(&graph, &output.to_string_lossy())
+                    {
+                        eprintln!(
+                            "{} Failed to write {}: {}",
+                            "⚠".yellow(),
+                            output.display(),
+                            e
+                        );
+                    }
+                }
+            }
+        }
+
+        Commands::Query {
+            filter,
+            graph: graph_file,
+            output,
+            count,
+            limit,
+            offset,
+            name,
+            r#type,
+            package,
+            file,
+            root,
+            tag: _,
+            only_goroutines,
+            only_deferred,
+            with_complexity,
+            source,
+        } => {
+            let output = output_format(&cli, output);
+            // Reject a bad query before loading the graph
+            let filter = filter
+                .as_deref()
+                .map(str::parse::<SymbolFilter>)
+                .transpose()?;
+            let filter = match root {
+                Some(root) => Some(filter.unwrap_or_default().with_root(root)),
+                None => filter,
+            };
+            use std::time::Instant;
+
+            let load_start = Instant::now();
+            let graph = match source {
+                Some(source) => open_standalone(&cli, source)?,
+                None => open_graph(&cli, graph_file)?,
+            };
+            check_root(&graph, root.as_deref())?;
+            let load_time = load_start.elapsed();
+
+            let query_start = Instant::now();
+
+            // Phase 1 Optimization: Use index-based queries instead of linear scans
+            // Apply filters in optimal order (most selective first)
+
+            let mut nodes: Vec<&code_navigator::core::Node> = Vec::new();
+            let mut using_index = false;
+
+            // Priority 1: Exact name match (O(1) hash lookup)
+            if let Some(name_filter) = name {
                 if !name_filter.contains('*') {
                     // Exact match - use by_name index
                     nodes = graph.get_nodes_by_name(name_filter);
@@ -674,13 +1448,7 @@ fn main() -> Result<()> {
 
             // Priority 2: Type filter (O(1) hash lookup)
             if let Some(type_filter) = r#type {
-                let node_type = match type_filter.as_str() {
-                    "function" => NodeType::Function,
-                    "method" => NodeType::Method,
-                    "handler" => NodeType::HttpHandler,
-                    "middleware" => NodeType::Middleware,
-                    _ => anyhow::bail!("Unknown node type: {}", type_filter),
-                };
+                let node_type: NodeType = type_filter.parse()?;
 
                 if !using_index {
                     // No previous filter - use type index directly
@@ -701,7 +1469,10 @@ fn main() -> Result<()> {
 
             // Priority 3: Package filter (O(n) scan on filtered results)
             if let Some(package_filter) = package {
-                nodes.retain(|n| n.package == *package_filter);
+                nodes.retain(|n| {
+                    n.package == *package_filter
+                        || n.metadata.get("import_path") == Some(package_filter)
+                });
             }
 
             // Priority 4: File filter (O(n) scan on filtered results)
@@ -709,10 +1480,34 @@ fn main() -> Result<()> {
                 nodes.retain(|n| n.file_path.to_string_lossy().contains(file_filter));
             }
 
-            if let Some(limit_count) = limit {
-                nodes.truncate(*limit_count);
+            // Priority 5: the filter query
+            if let Some(filter) = &filter {
+                nodes.retain(|n| filter.matches(n));
+            }
+
+            // Priority 6: functions some `go` or `defer` statement calls
+            for (wanted, kind) in [
+                (*only_goroutines, EdgeKind::Go),
+                (*only_deferred, EdgeKind::Defer),
+            ] {
+                if wanted {
+                    let called: HashSet<&str> = graph
+                        .called_as(kind)
+                        .iter()
+                        .map(|n| n.id.as_str())
+                        .collect();
+                    nodes.retain(|n| called.contains(n.id.as_str()));
+                }
             }
 
+            // Sort before paging so --limit and --offset return the same nodes every run
+            nodes.sort_by(|a, b| {
+                (&a.file_path, a.line, &a.name).cmp(&(&b.file_path, b.line, &b.name))
+            });
+            let page = PageRequest::new(offset.unwrap_or(0), *limit).apply(nodes);
+            let paged = offset.is_some() || limit.is_some();
+            let nodes = &page.items;
+
             let query_time = query_start.elapsed();
 
             // Print timing info in verbose mode or as a comment
@@ -729,205 +1524,1878 @@ fn main() -> Result<()> {
                 return Ok(());
             }
 
-            match output.as_str() {
+            match output {
                 "table" => {
                     if nodes.is_empty() {
                         println!("{}", "No nodes found".yellow());
                         return Ok(());
                     }
 
-                    println!(
+                    print!(
                         "{:<40} {:<15} {:<30} {:<10}",
                         "Name".bold(),
                         "Type".bold(),
                         "Package".bold(),
                         "Line".bold()
                     );
-                    println!("{}", "-".repeat(95));
+                    if *with_complexity {
+                        print!(" {:<10}", "Complexity".bold());
+                    }
+                    println!();
+                    println!("{}", "-".repeat(if *with_complexity { 106 } else { 95 }));
 
-                    for node in &nodes {
+                    for node in nodes {
                         let type_str = match node.node_type {
                             NodeType::Function => "Function".green(),
                             NodeType::Method => "Method".blue(),
                             NodeType::HttpHandler => "HTTP Handler".yellow(),
                             NodeType::Middleware => "Middleware".magenta(),
+                            NodeType::Benchmark => "Benchmark".green(),
+                            NodeType::Fuzz => "Fuzz".green(),
+                            NodeType::Struct => "Struct".cyan(),
+                            NodeType::Class => "Class".cyan(),
+                            NodeType::Interface => "Interface".cyan(),
+                            NodeType::Type => "Type".cyan(),
+                            NodeType::Const => "Const".white(),
+                            NodeType::Var => "Var".white(),
+                            NodeType::Field => "Field".white(),
                         };
 
-                        println!(
+                        print!(
                             "{:<40} {:<15} {:<30} {:<10}",
                             node.name,
                             format!("{}", type_str),
                             node.package,
                             node.line
                         );
+                        if *with_complexity {
+                            print!(
+                                " {:<10}",
+                                node.complexity()
+                                    .map_or_else(|| "-".to_string(), |c| c.to_string())
+                            );
+                        }
+                        println!();
                     }
 
                     println!();
-                    println!(
-                        "{} {} nodes found",
-                        "→".blue(),
-                        nodes.len().to_string().cyan()
-                    );
+                    if paged {
+                        print_page_summary(&page, true, "nodes");
+                    } else {
+                        println!(
+                            "{} {} nodes found",
+                            "→".blue(),
+                            nodes.len().to_string().cyan()
+                        );
+                    }
                 }
                 "json" => {
-                    let json = serde_json::to_string_pretty(&nodes)?;
-                    println!("{}", json);
+                    print_page_json(
+                        &page,
+                        *limit,
+                        *offset,
+                        schema::symbols(nodes.iter().copied()),
+                    )?;
                 }
                 "tree" => {
-                    for node in &nodes {
+                    for node in nodes {
                         println!("├─ {}", node.name.cyan().bold());
                         println!("│  └─ Type: {:?}", node.node_type);
                         println!("│  └─ Package: {}", node.package);
                         println!("│  └─ File: {}", node.file_path.display());
                         println!("│  └─ Line: {}", node.line);
+                        if let Some(complexity) = node.complexity().filter(|_| *with_complexity) {
+                            println!("│  └─ Complexity: {}", complexity);
+                        }
+                        println!();
+                    }
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Search {
+            query,
+            graph: graph_file,
+            kind,
+            limit,
+            offset,
+            filter,
+            root,
+            count,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let mut filter: SymbolFilter = filter.as_deref().unwrap_or("").parse()?;
+            if let Some(root) = root {
+                filter = filter.with_root(root);
+            }
+            let options = SearchOptions {
+                kinds: kind
+                    .iter()
+                    .map(|k| k.parse())
+                    .collect::<Result<Vec<NodeType>>>()?,
+                filter,
+                ..Default::default()
+            };
+            let graph = open_graph(&cli, graph_file)?;
+            check_root(&graph, root.as_deref())?;
+            let page = graph.search_page(
+                query,
+                &options,
+                &PageRequest::new(offset.unwrap_or(0), Some(limit.unwrap_or(20))),
+            );
+            let paged = offset.is_some() || limit.is_some();
+            // Counting reports every match
+            if *count {
+                println!("{}", page.total);
+                return Ok(());
+            }
+            let matches = &page.items;
+
+            match output {
+                "table" => {
+                    if matches.is_empty() {
+                        println!("{} No symbols match '{}'", "✗".red(), query);
+                        return Ok(());
+                    }
+
+                    println!(
+                        "{:<40} {:<12} {:<10} {}",
+                        "Name".bold(),
+                        "Kind".bold(),
+                        "Match".bold(),
+                        "Location".bold()
+                    );
+                    println!("{}", "-".repeat(95));
+                    for m in matches {
+                        println!(
+                            "{:<40} {:<12} {:<10} {}:{}",
+                            m.node.name,
+                            format!("{:?}", m.node.node_type),
+                            format!("{:?}", m.match_kind).to_lowercase(),
+                            m.node.file_path.display(),
+                            m.node.line
+                        );
+                        println!("  {}", m.node.signature.dimmed());
+                    }
+                    if paged || page.has_more() {
                         println!();
+                        print_page_summary(&page, true, "matches");
+                    }
+                }
+                "json" => {
+                    let results: Vec<schema::SearchResult> =
+                        matches.iter().map(schema::SearchResult::from).collect();
+                    print_page_json(&page, *limit, *offset, results)?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Doc {
+            symbol,
+            graph: graph_file,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let node = graph.resolve_symbol(symbol)?;
+
+            match output {
+                "text" => {
+                    println!("{}", node.declaration().bold());
+                    // Indented like `go doc`, with paragraph breaks left blank
+                    for line in node.documentation.as_deref().unwrap_or("").lines() {
+                        match line.is_empty() {
+                            true => println!(),
+                            false => println!("    {}", line),
+                        }
+                    }
+                }
+                "json" => {
+                    schema::print_json(&schema::Versioned::new(schema::Symbol::from(node)))?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Source {
+            symbol,
+            graph: graph_file,
+            context,
+            with_line_numbers,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let snippet = graph.source(symbol, *context)?;
+
+            match output {
+                "text" => {
+                    let text = snippet.with_context();
+                    if *with_line_numbers {
+                        // `split` keeps a trailing empty context line that `lines` would drop
+                        let last = snippet.start_line + text.split('\n').count() - 1;
+                        let width = last.to_string().len();
+                        for (i, line) in text.split('\n').enumerate() {
+                            let number = snippet.start_line + i;
+                            // Context lines are dimmed so the declaration stands out
+                            let inside = (snippet.range.start.line..=snippet.range.end.line)
+                                .contains(&number);
+                            let number = format!("{:>width$}", number, width = width);
+                            match inside {
+                                true => println!("{}  {}", number.dimmed(), line),
+                                false => println!("{}  {}", number.dimmed(), line.dimmed()),
+                            }
+                        }
+                    } else {
+                        println!("{}", text);
+                    }
+                }
+                "json" => {
+                    schema::print_json(&schema::Versioned::new(schema::Source::from(&snippet)))?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Blame {
+            symbols,
+            graph: graph_file,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let nodes = symbols
+                .iter()
+                .map(|symbol| graph.resolve_symbol(symbol))
+                .collect::<Result<Vec<_>>>()?;
+            // Symbols of one file share its blame
+            let mut blamer = Blamer::new();
+            let blames: Vec<_> = nodes.iter().map(|node| blamer.annotate(node)).collect();
+
+            match output {
+                "text" => {
+                    for (node, blame) in nodes.iter().zip(&blames) {
+                        let commit = match blame {
+                            Ok(blame) => blame,
+                            Err(reason) => {
+                                println!(
+                                    "{}  {}",
+                                    node.name.bold(),
+                                    format!("blame unavailable: {}", reason).dimmed()
+                                );
+                                continue;
+                            }
+                        };
+                        let last = &commit.last_modified;
+                        let hash = match last.hash {
+                            Some(_) => last.short_hash().yellow(),
+                            None => last.short_hash().red(),
+                        };
+                        println!(
+                            "{}  {}  {}  {}  {}",
+                            node.name.bold(),
+                            hash,
+                            last.author,
+                            last.date.format("%Y-%m-%d"),
+                            last.summary.dimmed()
+                        );
+                        if commit.uncommitted > 0 {
+                            println!(
+                                "    {} of lines {}-{} uncommitted",
+                                commit.uncommitted, commit.start_line, commit.end_line
+                            );
+                        }
+                    }
+                }
+                "json" => {
+                    let blames: Vec<_> = nodes
+                        .iter()
+                        .zip(&blames)
+                        .map(|(node, blame)| schema::Blame::new(node, blame))
+                        .collect();
+                    schema::print_json(&blames)?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Outline {
+            file,
+            graph: graph_file,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let stdin = file.as_os_str() == "-";
+            let standalone = stdin || (!graph_file.exists() && file.is_file());
+            let (graph, file) = match standalone {
+                true if stdin => (
+                    open_standalone(&cli, file)?,
+                    PathBuf::from(parser::STDIN_PATH),
+                ),
+                true => (open_standalone(&cli, file)?, file.clone()),
+                false => (open_graph(&cli, graph_file)?, file.clone()),
+            };
+            let path = [
+                file.clone(),
+                Path::new(&graph.metadata.root_path).join(&file),
+            ]
+            .into_iter()
+            .chain(file.canonicalize().ok())
+            .find(|path| graph.nodes.iter().any(|node| &node.file_path == path))
+            // A file parsed on its own may well declare nothing
+            .or_else(|| standalone.then(|| file.clone()))
+            .with_context(|| format!("No symbols indexed for file {}", file.display()))?;
+            let outline = graph.outline(&path);
+
+            match output {
+                "text" => {
+                    println!("{}", file.display().to_string().bold());
+                    print_outline(&outline, "");
+                }
+                "json" => {
+                    let items: Vec<schema::OutlineItem> =
+                        outline.iter().map(schema::OutlineItem::from).collect();
+                    schema::print_json(&items)?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Trace {
+            graph: graph_file,
+            from,
+            depth,
+            output,
+            show_lines,
+            filter: _,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+
+            // Find the starting node
+            let start_node = graph.resolve_symbol(from)?;
+            let traces = graph.trace_dependencies(&start_node.id, *depth);
+
+            if traces.is_empty() && output != "json" {
+                if !cli.quiet {
+                    println!("{}", "No dependencies found".yellow());
+                }
+                return Ok(());
+            }
+
+            match output {
+                "tree" => {
+                    println!("{}", format!("Dependencies of {}", from).bold());
+                    println!();
+
+                    let mut current_depth = 0;
+                    for trace in &traces {
+                        if trace.depth > current_depth {
+                            current_depth = trace.depth;
+                        }
+
+                        let indent = "  ".repeat(trace.depth);
+                        let line_info = if *show_lines {
+                            format!(" ({}:{})", trace.file_path.display(), trace.line)
+                        } else {
+                            String::new()
+                        };
+
+                        let mut tags = call_tags(trace.call_kind, trace.indirect, trace.dynamic);
+                        if !trace.promoted_via.is_empty() {
+                            tags.push_str(&format!(" (via {})", trace.promoted_via.join(".")));
+                        }
+                        tags.push_str(&hidden_tag(&trace.hidden_via));
+
+                        println!(
+                            "{}├─ {}{}{}",
+                            indent,
+                            trace.to_name.cyan(),
+                            tags.dimmed(),
+                            line_info.dimmed()
+                        );
                     }
+
+                    println!();
+                    println!("{} {} dependencies found", "→".blue(), traces.len());
+                }
+                "json" => {
+                    schema::print_json(&schema::call_edges(&traces))?;
+                }
+                "dot" => {
+                    let subgraph = graph.extract_subgraph(&start_node.name, *depth);
+                    print!("{}", dot::render(&subgraph, &dot::DotOptions::default()));
+                }
+                "mermaid" => {
+                    let subgraph = graph.extract_subgraph(&start_node.name, *depth);
+                    print!(
+                        "{}",
+                        mermaid::render(&subgraph, &mermaid::MermaidOptions::default())
+                    );
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Callers {
+            graph: graph_file,
+            function,
+            count,
+            output,
+            show_lines,
+            transitive,
+            depth,
+            limit,
+            offset,
+            root,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let from = root_filter(&graph, root.as_deref())?;
+
+            let max_depth = if *transitive { *depth } else { 1 };
+            let page = graph.callers_page(
+                function,
+                max_depth,
+                &from,
+                &PageRequest::new(offset.unwrap_or(0), *limit),
+            );
+            let paged = offset.is_some() || limit.is_some();
+            let callers = &page.items;
+
+            if *count {
+                println!("{}", page.total);
+                return Ok(());
+            }
+
+            if page.total == 0 && output != "json" {
+                if !cli.quiet {
+                    println!("{}", format!("No callers found for {}", function).yellow());
+                }
+                return Ok(());
+            }
+
+            match output {
+                "tree" => {
+                    println!("{}", format!("Callers of {}", function).bold());
+                    println!();
+
+                    for caller in callers {
+                        let line_info = if *show_lines {
+                            format!(" ({}:{})", caller.file_path.display(), caller.line)
+                        } else {
+                            String::new()
+                        };
+                        let indent = "│  ".repeat(caller.depth - 1);
+                        let via = if caller.depth > 1 {
+                            format!(" → {}", caller.callee)
+                        } else {
+                            String::new()
+                        };
+                        let tags = call_tags(caller.call_kind, caller.indirect, caller.dynamic);
+
+                        println!(
+                            "{}├─ {}{}{}{}",
+                            indent,
+                            caller.caller.cyan(),
+                            via.dimmed(),
+                            tags.dimmed(),
+                            line_info.dimmed()
+                        );
+                    }
+
+                    println!();
+                    print_page_summary(&page, paged, "callers");
+                }
+                "json" => {
+                    print_page_json(&page, *limit, *offset, schema::references(callers))?;
+                }
+                "table" => {
+                    println!(
+                        "{:<40} {:<30} {:<10} {:<6}",
+                        "Caller".bold(),
+                        "File".bold(),
+                        "Line".bold(),
+                        "Depth".bold()
+                    );
+                    println!("{}", "-".repeat(87));
+
+                    for caller in callers {
+                        println!(
+                            "{:<40} {:<30} {:<10} {:<6}",
+                            caller.caller,
+                            caller
+                                .file_path
+                                .file_name()
+                                .and_then(|n| n.to_str())
+                                .unwrap_or(""),
+                            caller.line,
+                            caller.depth
+                        );
+                    }
+
+                    println!();
+                    print_page_summary(&page, paged, "callers");
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Callees {
+            graph: graph_file,
+            function,
+            transitive,
+            depth,
+            prune,
+            with_edges,
+            root,
+            count,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let options = CalleeOptions {
+                max_depth: if *transitive { *depth } else { 1 },
+                prune: prune.iter().map(|p| p.parse()).collect::<Result<_>>()?,
+            };
+            let graph = open_graph(&cli, graph_file)?;
+            let callees_in = root_filter(&graph, root.as_deref())?;
+            let mut closure = graph.callees(function, &options)?;
+            if !callees_in.is_empty() {
+                // Calls outside the index lie in no root
+                let in_root = |call: &code_navigator::core::CalleeCall| {
+                    call.hop
+                        .callee
+                        .is_some_and(|callee| callees_in.matches(callee))
+                };
+                closure.reached.retain(in_root);
+                closure.calls.retain(in_root);
+            }
+
+            if *count {
+                println!("{}", closure.reached.len());
+                return Ok(());
+            }
+
+            let location = |call: &code_navigator::core::CalleeCall| match call.hop.callee {
+                Some(callee) => format!("{}:{}", callee.file_path.display(), callee.line),
+                None => "external".to_string(),
+            };
+            match output {
+                "tree" | "table" if closure.reached.is_empty() => {
+                    if !cli.quiet {
+                        println!("{}", format!("No callees found for {}", function).yellow());
+                    }
+                    return Ok(());
+                }
+                "tree" => {
+                    println!("{}", format!("Callees of {}", closure.root.name).bold());
+                    println!();
+                    for call in &closure.reached {
+                        let indent = "│  ".repeat(call.depth - 1);
+                        let via = match call.depth {
+                            1 => String::new(),
+                            _ => format!(" ← {}", call.caller.name),
+                        };
+                        let call_edge = call.hop.call;
+                        let mut tags = call_tags(
+                            call_edge.kind(),
+                            call_edge.is_indirect(),
+                            call_edge.is_dynamic(),
+                        );
+                        tags.push_str(&hidden_tag(&call_edge.hidden_via()));
+                        println!(
+                            "{}├─ {}{}{} {}",
+                            indent,
+                            call.hop.callee_name().cyan(),
+                            via.dimmed(),
+                            tags.dimmed(),
+                            format!("({})", location(call)).dimmed()
+                        );
+                    }
+                    println!();
+                    println!("{} {} callees found", "→".blue(), closure.reached.len());
+                }
+                "table" => {
+                    println!(
+                        "{:<40} {:<6} {:<30} {}",
+                        "Callee".bold(),
+                        "Depth".bold(),
+                        "Via".bold(),
+                        "Location".bold()
+                    );
+                    println!("{}", "-".repeat(100));
+                    for call in &closure.reached {
+                        println!(
+                            "{:<40} {:<6} {:<30} {}",
+                            call.hop.callee_name(),
+                            call.depth,
+                            call.caller.name,
+                            location(call)
+                        );
+                    }
+                    println!();
+                    println!("{} {} callees found", "→".blue(), closure.reached.len());
+                }
+                "json" => schema::print_json(&schema::Versioned::new(schema::Callees::new(
+                    &closure,
+                    *with_edges,
+                )))?,
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+            if *with_edges && output != "json" {
+                println!();
+                println!("{}", "Calls".bold());
+                for call in &closure.calls {
+                    println!(
+                        "  {} → {} {}",
+                        call.caller.name,
+                        call.hop.callee_name(),
+                        format!(
+                            "({}:{})",
+                            call.hop.call.file_path.display(),
+                            call.hop.call.line
+                        )
+                        .dimmed()
+                    );
+                }
+            }
+        }
+
+        Commands::References {
+            symbol,
+            graph: graph_file,
+            kind,
+            limit,
+            offset,
+            root,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let from = root_filter(&graph, root.as_deref())?;
+
+            let kinds = kind
+                .iter()
+                .map(|k| k.parse())
+                .collect::<Result<Vec<ReferenceKind>>>()?;
+            let page = graph.references_page(
+                symbol,
+                &kinds,
+                &from,
+                &PageRequest::new(offset.unwrap_or(0), *limit),
+            );
+            let paged = offset.is_some() || limit.is_some();
+            let references: &Vec<CallSite> = &page.items;
+
+            if page.total == 0 && output != "json" {
+                if !cli.quiet {
+                    println!("{}", format!("No references found for {}", symbol).yellow());
+                }
+                return Ok(());
+            }
+
+            match output {
+                "tree" => {
+                    println!("{}", format!("References to {}", symbol).bold());
+                    println!();
+
+                    for site in references {
+                        println!(
+                            "├─ {} {} {}",
+                            site.caller.cyan(),
+                            format!("[{}]", site.kind.as_str()).yellow(),
+                            format!("({}:{})", site.file_path.display(), site.line).dimmed()
+                        );
+                        println!(
+                            "│    {}",
+                            site.call_site.lines().next().unwrap_or("").dimmed()
+                        );
+                    }
+
+                    println!();
+                    print_page_summary(&page, paged, "references");
+                }
+                "json" => {
+                    print_page_json(&page, *limit, *offset, schema::references(references))?;
+                }
+                "table" => {
+                    println!(
+                        "{:<40} {:<14} {:<30} {:<6}",
+                        "From".bold(),
+                        "Kind".bold(),
+                        "File".bold(),
+                        "Line".bold()
+                    );
+                    println!("{}", "-".repeat(93));
+
+                    for site in references {
+                        println!(
+                            "{:<40} {:<14} {:<30} {:<6}",
+                            site.caller,
+                            site.kind.as_str(),
+                            site.file_path
+                                .file_name()
+                                .and_then(|n| n.to_str())
+                                .unwrap_or(""),
+                            site.line
+                        );
+                    }
+
+                    println!();
+                    print_page_summary(&page, paged, "references");
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::FieldUsage {
+            field,
+            graph: graph_file,
+            kind,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+
+            let kinds = kind
+                .iter()
+                .map(|k| k.parse())
+                .collect::<Result<Vec<FieldAccessKind>>>()?;
+            let accesses: Vec<FieldAccess> = graph
+                .field_accesses(field)?
+                .into_iter()
+                .filter(|access| kinds.is_empty() || kinds.contains(&access.kind))
+                .collect();
+
+            if accesses.is_empty() && output != "json" {
+                if !cli.quiet {
+                    println!("{}", format!("No accesses found for {}", field).yellow());
+                }
+                return Ok(());
+            }
+
+            let function_name = |access: &FieldAccess| {
+                access
+                    .function
+                    .map(|function| function.name.clone())
+                    .unwrap_or_else(|| access.reference.from.clone())
+            };
+            match output {
+                "tree" => {
+                    println!(
+                        "{}",
+                        format!("Accesses to {}", accesses[0].field.name).bold()
+                    );
+                    println!();
+
+                    for access in &accesses {
+                        println!(
+                            "├─ {} {} {}",
+                            function_name(access).cyan(),
+                            format!("[{}]", access.kind.as_str()).yellow(),
+                            format!(
+                                "({}:{})",
+                                access.reference.file_path.display(),
+                                access.reference.line
+                            )
+                            .dimmed()
+                        );
+                        println!(
+                            "│    {}",
+                            access
+                                .reference
+                                .call_site
+                                .lines()
+                                .next()
+                                .unwrap_or("")
+                                .dimmed()
+                        );
+                    }
+
+                    println!();
+                    println!("{} {} accesses found", "→".blue(), accesses.len());
+                }
+                "json" => {
+                    let accesses: Vec<schema::FieldAccess> =
+                        accesses.iter().map(schema::FieldAccess::from).collect();
+                    schema::print_json(&accesses)?;
+                }
+                "table" => {
+                    println!(
+                        "{:<40} {:<8} {:<30} {:<6}",
+                        "Function".bold(),
+                        "Kind".bold(),
+                        "File".bold(),
+                        "Line".bold()
+                    );
+                    println!("{}", "-".repeat(87));
+
+                    for access in &accesses {
+                        println!(
+                            "{:<40} {:<8} {:<30} {:<6}",
+                            function_name(access),
+                            access.kind.as_str(),
+                            access
+                                .reference
+                                .file_path
+                                .file_name()
+                                .and_then(|n| n.to_str())
+                                .unwrap_or(""),
+                            access.reference.line
+                        );
+                    }
+
+                    println!();
+                    println!("{} {} accesses found", "→".blue(), accesses.len());
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::CoverageMap {
+            symbol,
+            graph: graph_file,
+            max_depth,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let tests = graph.tests_reaching(symbol, *max_depth)?;
+
+            if tests.is_empty() && output != "json" {
+                if !cli.quiet {
+                    let hint = if graph.has_tests() {
+                        ""
+                    } else {
+                        "; the index has no test files, re-run `codenav index --include-tests`"
+                    };
+                    println!("{}", format!("No tests reach {}{}", symbol, hint).yellow());
+                }
+                return Ok(());
+            }
+
+            match output {
+                "tree" => {
+                    println!("{}", format!("Tests reaching {}", symbol).bold());
+                    println!();
+
+                    for coverage in &tests {
+                        let reach = if coverage.is_direct() {
+                            "direct".to_string()
+                        } else {
+                            format!("{} calls", coverage.path.hops.len())
+                        };
+                        println!(
+                            "├─ {} {} {}",
+                            coverage.test.name.cyan(),
+                            format!("[{}]", reach).yellow(),
+                            format!(
+                                "({}:{})",
+                                coverage.test.file_path.display(),
+                                coverage.test.line
+                            )
+                            .dimmed()
+                        );
+                        println!("│    {}", coverage.names().join(" → ").dimmed());
+                    }
+
+                    println!();
+                    println!("{} {} tests found", "→".blue(), tests.len());
+                }
+                "json" => {
+                    let tests: Vec<schema::TestCoverage> =
+                        tests.iter().map(schema::TestCoverage::from).collect();
+                    schema::print_json(&tests)?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Benchmarks {
+            symbol,
+            graph: graph_file,
+            max_depth,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let benchmarks = graph.benchmarks_reaching(symbol, *max_depth)?;
+
+            if benchmarks.is_empty() && output != "json" {
+                if !cli.quiet {
+                    let hint = if graph.has_tests() {
+                        ""
+                    } else {
+                        "; the index has no test files, re-run `codenav index --include-tests`"
+                    };
+                    println!(
+                        "{}",
+                        format!("No benchmarks reach {}{}", symbol, hint).yellow()
+                    );
+                }
+                return Ok(());
+            }
+
+            match output {
+                "tree" => {
+                    println!("{}", format!("Benchmarks reaching {}", symbol).bold());
+                    println!();
+
+                    for coverage in &benchmarks {
+                        let reach = if coverage.is_direct() {
+                            "direct".to_string()
+                        } else {
+                            format!("{} calls", coverage.path.hops.len())
+                        };
+                        println!(
+                            "├─ {} {} {} {}",
+                            coverage.test.name.cyan(),
+                            coverage.test.node_type.as_str().dimmed(),
+                            format!("[{}]", reach).yellow(),
+                            format!(
+                                "({}:{})",
+                                coverage.test.file_path.display(),
+                                coverage.test.line
+                            )
+                            .dimmed()
+                        );
+                        println!("│    {}", coverage.names().join(" → ").dimmed());
+                    }
+
+                    println!();
+                    println!("{} {} benchmarks found", "→".blue(), benchmarks.len());
+                }
+                "json" => {
+                    let benchmarks: Vec<schema::BenchmarkCoverage> = benchmarks
+                        .iter()
+                        .map(schema::BenchmarkCoverage::from)
+                        .collect();
+                    schema::print_json(&benchmarks)?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Rename {
+            old,
+            new,
+            graph: graph_file,
+            dry_run,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            if !dry_run {
+                anyhow::bail!("Applying renames is not implemented yet; pass --dry-run");
+            }
+            let graph = open_graph(&cli, graph_file)?;
+            let plan = graph.plan_rename(old, new)?;
+
+            match output {
+                "text" => {
+                    println!(
+                        "{}",
+                        format!("Renaming {} to {} (dry run)", plan.symbol.name, new).bold()
+                    );
+                    println!();
+
+                    for (file, edits) in plan.files() {
+                        println!("{}", file.display().to_string().cyan());
+                        for edit in edits {
+                            println!(
+                                "  {}:{}  {} → {}",
+                                edit.line,
+                                edit.column,
+                                edit.old_text,
+                                edit.new_text.green()
+                            );
+                        }
+                    }
+                    println!();
+                    println!("{} {} edits", "→".blue(), plan.edits.len());
+
+                    if !plan.conflicts.is_empty() {
+                        println!();
+                        for conflict in &plan.conflicts {
+                            let existing = conflict.existing;
+                            println!(
+                                "{} {} already exists in {} {}",
+                                "✗".red(),
+                                existing.name.yellow(),
+                                conflict.scope,
+                                format!("({}:{})", existing.file_path.display(), existing.line)
+                                    .dimmed()
+                            );
+                        }
+                    }
+                }
+                "json" => {
+                    schema::print_json(&schema::Versioned::new(schema::RenameReport::from(&plan)))?
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+
+            if !plan.conflicts.is_empty() {
+                std::process::exit(EXIT_RENAME_CONFLICT);
+            }
+        }
+
+        Commands::Cycles {
+            graph: graph_file,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let cycles = graph.cycles();
+
+            match output {
+                "text" => {
+                    if cycles.is_empty() {
+                        println!("{} No call cycles found", "✓".green());
+                        return Ok(());
+                    }
+
+                    for cycle in &cycles {
+                        let names: Vec<&str> =
+                            cycle.nodes.iter().map(|node| node.name.as_str()).collect();
+                        let heading = if cycle.self_recursive {
+                            format!("{} (self-recursive)", names[0])
+                        } else {
+                            format!("{} → {}", names.join(" → "), names[0])
+                        };
+                        println!("{}", heading.bold());
+                        for call in &cycle.calls {
+                            let caller = graph
+                                .get_node_by_id(&call.from)
+                                .map(|node| node.name.as_str())
+                                .unwrap_or(call.from.as_str());
+                            println!(
+                                "  {} → {} {}",
+                                caller.cyan(),
+                                call.to.cyan(),
+                                format!("({}:{})", call.file_path.display(), call.line).dimmed()
+                            );
+                        }
+                        println!();
+                    }
+                    println!("{} {} cycles found", "→".blue(), cycles.len());
+                }
+                "json" => {
+                    let cycles: Vec<schema::Cycle> =
+                        cycles.iter().map(schema::Cycle::from).collect();
+                    schema::print_json(&cycles)?;
+                }
+                "sarif" => {
+                    let findings: Vec<Finding> =
+                        cycles.iter().map(|cycle| cycle.finding()).collect();
+                    println!(
+                        "{}",
+                        sarif::render(
+                            &[findings::CALL_CYCLE],
+                            &findings,
+                            Path::new(&graph.metadata.root_path)
+                        )?
+                    );
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Imports {
+            package,
+            graph: graph_file,
+            external,
+            reverse,
+            cycles,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+
+            if *cycles {
+                let cycles = graph.import_cycles();
+                match output {
+                    "text" => {
+                        if cycles.is_empty() {
+                            println!("{} No import cycles found", "✓".green());
+                            return Ok(());
+                        }
+                        for cycle in &cycles {
+                            let heading = match &cycle.packages[..] {
+                                [package] => format!("{} (imports itself)", package),
+                                packages => format!("{} → {}", packages.join(" → "), packages[0]),
+                            };
+                            println!("{}", heading.bold());
+                            for import in &cycle.imports {
+                                println!(
+                                    "  {} → {} {}",
+                                    graph.importing_package(import).cyan(),
+                                    import.path.cyan(),
+                                    format!("({}:{})", import.file_path.display(), import.line)
+                                        .dimmed()
+                                );
+                            }
+                            println!();
+                        }
+                        println!("{} {} import cycles found", "→".blue(), cycles.len());
+                    }
+                    "json" => {
+                        let cycles: Vec<schema::ImportCycle> = cycles
+                            .iter()
+                            .map(|cycle| schema::ImportCycle::new(&graph, cycle))
+                            .collect();
+                        schema::print_json(&cycles)?;
+                    }
+                    "dot" => {
+                        let imports: Vec<&Import> = cycles
+                            .iter()
+                            .flat_map(|cycle| cycle.imports.iter().copied())
+                            .collect();
+                        print!("{}", dot::render_imports(&graph, &imports));
+                    }
+                    _ => anyhow::bail!("Unknown output format: {}", output),
+                }
+                return Ok(());
+            }
+
+            let imports: Vec<&Import> = match package {
+                Some(package) => {
+                    let known = graph.imports.iter().any(|import| {
+                        import.path == *package || graph.importing_package(import) == *package
+                    }) || graph
+                        .nodes
+                        .iter()
+                        .any(|node| node.metadata.get("import_path") == Some(package));
+                    if !known {
+                        anyhow::bail!("Unknown package: {}", package);
+                    }
+                    if *reverse {
+                        graph.importers(package)
+                    } else {
+                        graph
+                            .package_imports(*external)
+                            .into_iter()
+                            .filter(|import| graph.importing_package(import) == *package)
+                            .collect()
+                    }
+                }
+                None => graph.package_imports(*external),
+            };
+
+            match output {
+                "text" => {
+                    if imports.is_empty() {
+                        println!("{}", "No imports found".yellow());
+                        return Ok(());
+                    }
+
+                    // Specs repeated across a package's files are listed once
+                    let mut grouped: BTreeMap<(String, &str, Option<&str>), Vec<&Import>> =
+                        BTreeMap::new();
+                    for &import in &imports {
+                        let key = (
+                            graph.importing_package(import),
+                            import.path.as_str(),
+                            import.alias.as_deref(),
+                        );
+                        grouped.entry(key).or_default().push(import);
+                    }
+
+                    if *reverse {
+                        if let Some(package) = package {
+                            println!("{}", format!("Packages importing {}", package).bold());
+                        }
+                    }
+                    let mut current = None;
+                    for ((importer, path, _), specs) in &grouped {
+                        let first = specs[0];
+                        let kind = match first.kind() {
+                            ImportKind::Normal => String::new(),
+                            ImportKind::Alias => {
+                                format!(" as {}", first.alias.as_deref().unwrap_or(""))
+                            }
+                            ImportKind::Dot => " (dot import)".to_string(),
+                            ImportKind::Blank => " (blank import)".to_string(),
+                        };
+                        let mut location = format!("({}:{}", first.file_path.display(), first.line);
+                        if specs.len() > 1 {
+                            location.push_str(&format!(" and {} more", specs.len() - 1));
+                        }
+                        location.push(')');
+
+                        if *reverse {
+                            println!(
+                                "  {} {}{} {}",
+                                "←".blue(),
+                                importer.cyan(),
+                                kind,
+                                location.dimmed()
+                            );
+                            continue;
+                        }
+                        if current != Some(importer) {
+                            if current.is_some() {
+                                println!();
+                            }
+                            println!("{}", importer.bold());
+                            current = Some(importer);
+                        }
+                        let path = if first.internal {
+                            path.cyan()
+                        } else {
+                            path.normal()
+                        };
+                        println!("  {} {}{} {}", "→".blue(), path, kind, location.dimmed());
+                    }
+                }
+                "json" => {
+                    let imports: Vec<schema::ImportEdge> = imports
+                        .iter()
+                        .map(|&import| schema::ImportEdge::new(&graph, import))
+                        .collect();
+                    schema::print_json(&imports)?;
+                }
+                "dot" => print!("{}", dot::render_imports(&graph, &imports)),
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Deadcode {
+            graph: graph_file,
+            strict,
+            roots,
+            in_root,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let filter = root_filter(&graph, in_root.as_deref())?;
+
+            let options = DeadCodeOptions {
+                strict: *strict,
+                roots: roots.clone(),
+            };
+            let mut report = graph.dead_code(&options)?;
+            report.dead.retain(|node| filter.matches(node));
+
+            match output {
+                "text" => {
+                    if report.dead.is_empty() {
+                        println!(
+                            "{} Every function is reachable from {} entry points",
+                            "✓".green(),
+                            report.roots.len()
+                        );
+                        return Ok(());
+                    }
+
+                    println!(
+                        "{}",
+                        format!("Unreachable from {} entry points:", report.roots.len()).bold()
+                    );
+                    println!();
+                    for node in &report.dead {
+                        println!(
+                            "  {} {}",
+                            node.name.cyan(),
+                            format!("({}:{})", node.file_path.display(), node.line).dimmed()
+                        );
+                    }
+                    println!();
+                    println!("{} {} dead functions found", "→".blue(), report.dead.len());
+                }
+                "json" => {
+                    schema::print_json(&schema::symbols(report.dead.iter().copied()))?;
+                }
+                "sarif" => println!(
+                    "{}",
+                    sarif::render(
+                        &[findings::DEAD_CODE],
+                        &report.findings(),
+                        Path::new(&graph.metadata.root_path)
+                    )?
+                ),
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Entrypoints {
+            graph: graph_file,
+            strict,
+            root: in_root,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let filter = root_filter(&graph, in_root.as_deref())?;
+            let mut packages = graph.entry_points(*strict);
+            for package in &mut packages {
+                package
+                    .entry_points
+                    .retain(|entry| filter.matches(entry.node));
+            }
+            packages.retain(|package| !package.entry_points.is_empty());
+
+            match output {
+                "text" => {
+                    if packages.is_empty() {
+                        println!("{}", "No entry points found".yellow());
+                        return Ok(());
+                    }
+                    let root = graph.metadata.root_path.as_str();
+                    for package in &packages {
+                        let dir = package.dir.strip_prefix(root).unwrap_or(package.dir);
+                        let dir = match dir.as_os_str().is_empty() {
+                            true => ".".to_string(),
+                            false => dir.display().to_string(),
+                        };
+                        println!(
+                            "{} {}",
+                            package.package.bold(),
+                            format!("({})", dir).dimmed()
+                        );
+                        for entry in &package.entry_points {
+                            let file = entry
+                                .node
+                                .file_path
+                                .strip_prefix(root)
+                                .unwrap_or(&entry.node.file_path);
+                            println!(
+                                "  {:<9} {:<40} {}",
+                                entry.kind.as_str(),
+                                entry.node.name.cyan(),
+                                format!("{}:{}", file.display(), entry.node.line).dimmed()
+                            );
+                        }
+                        println!();
+                    }
+                    println!(
+                        "{} {} entry points in {} packages",
+                        "→".blue(),
+                        packages.iter().map(|p| p.entry_points.len()).sum::<usize>(),
+                        packages.len()
+                    );
+                }
+                "json" => {
+                    let packages: Vec<schema::PackageEntryPoints> = packages
+                        .iter()
+                        .map(schema::PackageEntryPoints::from)
+                        .collect();
+                    schema::print_json(&packages)?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Stats {
+            graph: graph_file,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            // Counted off the index as stored, before any global filter narrows it
+            let graph = load_graph(graph_file)?;
+            let stats = graph.index_stats();
+
+            match output {
+                "table" => {
+                    let row = |label: &str, value: String| println!("  {:<20} {}", label, value);
+                    println!("{}", graph.metadata.root_path.bold());
+                    row("Files", stats.files.to_string());
+                    row("Packages", stats.packages.to_string());
+                    row("Functions", stats.functions.to_string());
+                    row("Methods", stats.methods.to_string());
+                    row("Types", stats.types.to_string());
+                    let calls = &stats.calls;
+                    row(
+                        "Call edges",
+                        format!(
+                            "{} ({} static, {} indirect, {} dynamic; {} go, {} defer)",
+                            calls.total,
+                            calls.direct,
+                            calls.indirect,
+                            calls.dynamic,
+                            calls.go,
+                            calls.defer
+                        ),
+                    );
+                    row("Unresolved calls", stats.unresolved_calls.to_string());
+                    let diagnostics: usize = stats.diagnostics.values().sum();
+                    row(
+                        "Diagnostics",
+                        match diagnostics {
+                            0 => "0".to_string(),
+                            _ => format!(
+                                "{} ({})",
+                                diagnostics,
+                                stats
+                                    .diagnostics
+                                    .iter()
+                                    .map(|(code, count)| format!("{} {}", code, count))
+                                    .collect::<Vec<_>>()
+                                    .join(", ")
+                            ),
+                        },
+                    );
+                    row(
+                        "Index time",
+                        stats
+                            .index_duration_ms
+                            .map_or("unknown".to_string(), |ms| format!("{} ms", ms)),
+                    );
+                    row(
+                        "Cache",
+                        match (stats.cache, stats.cache_hit_rate()) {
+                            (Some(cache), Some(rate)) => format!(
+                                "{} hits, {} misses ({:.1}% hit rate)",
+                                cache.hits,
+                                cache.misses,
+                                rate * 100.0
+                            ),
+                            (Some(_), None) => "empty".to_string(),
+                            (None, _) => "disabled".to_string(),
+                        },
+                    );
+                    println!(
+                        "{}",
+                        format!("Indexed {}", graph.metadata.generated_at).dimmed()
+                    );
+                }
+                "json" => schema::print_json(&schema::Versioned::new(schema::IndexStats::new(
+                    &graph, &stats,
+                )))?,
+                _ => anyhow::bail!("Unknown output format: {}. Use: table, json", output),
+            }
+        }
+
+        Commands::Metrics {
+            graph: graph_file,
+            sort,
+            top,
+            root,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let sort: MetricsSort = sort.parse()?;
+            let graph = open_graph(&cli, graph_file)?;
+            let filter = root_filter(&graph, root.as_deref())?;
+
+            let mut metrics = graph.function_metrics(sort);
+            metrics.retain(|row| filter.matches(row.node));
+            if let Some(top) = top {
+                metrics.truncate(*top);
+            }
+
+            match output {
+                "table" => {
+                    if metrics.is_empty() {
+                        println!("{}", "No functions indexed".yellow());
+                        return Ok(());
+                    }
+
+                    println!(
+                        "{:<40} {:>8} {:>8} {:>10} {:>6}  {}",
+                        "Function".bold(),
+                        "Fan-In".bold(),
+                        "Fan-Out".bold(),
+                        "Reachable".bold(),
+                        "Lines".bold(),
+                        "Location".bold()
+                    );
+                    println!("{}", "-".repeat(100));
+                    for row in &metrics {
+                        println!(
+                            "{:<40} {:>8} {:>8} {:>10} {:>6}  {}",
+                            row.node.name,
+                            row.fan_in,
+                            row.fan_out,
+                            row.reachable,
+                            row.lines,
+                            format!("{}:{}", row.node.file_path.display(), row.node.line).dimmed()
+                        );
+                    }
+                    println!();
+                    println!("{} {} functions", "→".blue(), metrics.len());
+                }
+                "json" => {
+                    let rows: Vec<schema::FunctionMetrics> =
+                        metrics.iter().map(schema::FunctionMetrics::from).collect();
+                    schema::print_json(&rows)?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}. Use: table, json", output),
+            }
+        }
+
+        Commands::Complexity {
+            graph: graph_file,
+            threshold,
+            source,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = match source {
+                Some(source) => open_standalone(&cli, source)?,
+                None => open_graph(&cli, graph_file)?,
+            };
+            let complex = graph.complex_functions(*threshold);
+
+            match output {
+                "text" => {
+                    if complex.is_empty() {
+                        if !cli.quiet {
+                            println!(
+                                "{} No function is more complex than {}",
+                                "✓".green(),
+                                threshold
+                            );
+                        }
+                        return Ok(());
+                    }
+
+                    println!("{}", format!("More complex than {}:", threshold).bold());
+                    println!();
+                    for node in &complex {
+                        println!(
+                            "  {:>4}  {} {}",
+                            node.complexity().unwrap_or_default().to_string().red(),
+                            node.name.cyan(),
+                            format!("({}:{})", node.file_path.display(), node.line).dimmed()
+                        );
+                    }
+                    println!();
+                    println!(
+                        "{} {} functions over the threshold",
+                        "→".blue(),
+                        complex.len()
+                    );
+                }
+                "json" => {
+                    schema::print_json(&schema::symbols(complex.iter().copied()))?;
+                }
+                "sarif" => println!(
+                    "{}",
+                    sarif::render(
+                        &[findings::HIGH_COMPLEXITY],
+                        &findings::complexity_findings(&complex, *threshold),
+                        Path::new(&graph.metadata.root_path)
+                    )?
+                ),
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+            if !complex.is_empty() {
+                std::process::exit(EXIT_TOO_COMPLEX);
+            }
+        }
+
+        Commands::Hierarchy {
+            r#type,
+            graph: graph_file,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let type_node = graph.resolve_type(r#type)?;
+            let hierarchy = graph.hierarchy(type_node);
+
+            match output {
+                "tree" => {
+                    println!(
+                        "{} {}",
+                        type_node.name.bold(),
+                        format!("({}:{})", type_node.file_path.display(), type_node.line).dimmed()
+                    );
+                    print_hierarchy(&hierarchy.root, "");
+
+                    let of_interface = type_node.node_type == NodeType::Interface;
+                    if !hierarchy.implementations.is_empty() {
+                        println!();
+                        println!(
+                            "{}",
+                            if of_interface {
+                                "Implemented by"
+                            } else {
+                                "Satisfies"
+                            }
+                            .bold()
+                        );
+                    }
+                    let count = hierarchy.implementations.len();
+                    for (i, implementation) in hierarchy.implementations.iter().enumerate() {
+                        let related = match of_interface {
+                            true => implementation.implementer,
+                            false => implementation.interface,
+                        };
+                        let pointer = match (of_interface, implementation.pointer) {
+                            (true, true) => "*",
+                            _ => "",
+                        };
+                        let as_pointer = match (of_interface, implementation.pointer) {
+                            (false, true) => format!(" as *{}", type_node.name),
+                            _ => String::new(),
+                        };
+                        println!(
+                            "{} {}{} {}",
+                            if i + 1 == count { "└─" } else { "├─" },
+                            format!("{}{}", pointer, related.name).cyan(),
+                            as_pointer,
+                            format!("({}:{})", related.file_path.display(), related.line).dimmed()
+                        );
+                    }
+                }
+                "json" => {
+                    schema::print_json(&schema::Versioned::new(schema::Hierarchy::from(
+                        &hierarchy,
+                    )))?;
+                }
+                _ => anyhow::bail!("Unknown output format: {}", output),
+            }
+        }
+
+        Commands::Unresolved {
+            graph: graph_file,
+            reason,
+            examples,
+            output,
+        } => {
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let reason = reason
+                .as_deref()
+                .map(str::parse::<UnresolvedReason>)
+                .transpose()?;
+            let mut buckets = graph.unresolved();
+            buckets.retain(|bucket| reason.is_none_or(|reason| bucket.reason == reason));
+
+            match output {
+                "text" => {
+                    if buckets.is_empty() {
+                        if !cli.quiet {
+                            println!("{}", "Every call and reference resolved".green());
+                        }
+                        return Ok(());
+                    }
+                    for bucket in &buckets {
+                        println!(
+                            "{} {}",
+                            bucket.reason.label().bold(),
+                            format!("({})", bucket.count()).dimmed()
+                        );
+                        let width = bucket.names.iter().map(|n| n.name.len()).max().unwrap_or(0);
+                        for name in &bucket.names {
+                            let locations: Vec<String> = name
+                                .uses
+                                .iter()
+                                .take(*examples)
+                                .map(|edge| format!("{}:{}", edge.file_path.display(), edge.line))
+                                .collect();
+                            let more = match name.uses.len().saturating_sub(*examples) {
+                                0 => String::new(),
+                                hidden => format!(" and {} more", hidden),
+                            };
+                            println!(
+                                "  {:<width$}  {:>4}  {}{}",
+                                name.name.cyan(),
+                                name.uses.len(),
+                                locations.join(", ").dimmed(),
+                                more.dimmed(),
+                                width = width
+                            );
+                        }
+                        println!();
+                    }
+                    let total: usize = buckets.iter().map(|bucket| bucket.count()).sum();
+                    println!("{} {} unresolved", "→".blue(), total);
+                }
+                "json" => {
+                    let buckets: Vec<_> = buckets
+                        .iter()
+                        .map(|bucket| schema::UnresolvedBucket::new(bucket, *examples))
+                        .collect();
+                    schema::print_json(&buckets)?;
                 }
                 _ => anyhow::bail!("Unknown output format: {}", output),
             }
         }
 
-        Commands::Trace {
+        Commands::Implementations {
+            r#type,
             graph: graph_file,
-            from,
-            depth,
             output,
-            show_lines,
-            filter: _,
         } => {
-            let graph = load_graph(graph_file)?;
-
-            // Find the starting node
-            let nodes = graph.get_nodes_by_name(from);
-            if nodes.is_empty() {
-                anyhow::bail!("Function not found: {}", from);
-            }
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let type_node = graph.resolve_type(r#type)?;
+            let implementations = graph.implementations(type_node);
 
-            let start_node = nodes[0];
-            let traces = graph.trace_dependencies(&start_node.id, *depth);
-
-            if traces.is_empty() {
+            if implementations.is_empty() && output != "json" {
                 if !cli.quiet {
-                    println!("{}", "No dependencies found".yellow());
+                    println!(
+                        "{}",
+                        format!("No implementations found for {}", r#type).yellow()
+                    );
                 }
                 return Ok(());
             }
 
-            match output.as_str() {
+            match output {
                 "tree" => {
-                    println!("{}", format!("Dependencies of {}", from).bold());
+                    let of_interface = type_node.node_type == NodeType::Interface;
+                    let heading = if of_interface {
+                        format!("Implementations of {}", r#type)
+                    } else {
+                        format!("Interfaces implemented by {}", r#type)
+                    };
+                    println!("{}", heading.bold());
                     println!();
 
-                    let mut current_depth = 0;
-                    for trace in &traces {
-                        if trace.depth > current_depth {
-                            current_depth = trace.depth;
-                        }
-
-                        let indent = "  ".repeat(trace.depth);
-                        let line_info = if *show_lines {
-                            format!(" ({}:{})", trace.file_path.display(), trace.line)
+                    for implementation in &implementations {
+                        let implementer = if implementation.pointer {
+                            format!("*{}", implementation.implementer.name)
+                        } else {
+                            implementation.implementer.name.clone()
+                        };
+                        let (name, location) = if of_interface {
+                            (implementer.clone(), implementation.implementer)
+                        } else {
+                            (
+                                implementation.interface.name.clone(),
+                                implementation.interface,
+                            )
+                        };
+                        let as_pointer = if !of_interface && implementation.pointer {
+                            format!(" as {}", implementer)
                         } else {
                             String::new()
                         };
-
                         println!(
-                            "{}├─ {}{}",
-                            indent,
-                            trace.to_name.cyan(),
-                            line_info.dimmed()
+                            "├─ {}{} {}",
+                            name.cyan(),
+                            as_pointer,
+                            format!("({}:{})", location.file_path.display(), location.line)
+                                .dimmed()
                         );
+
+                        for (i, m) in implementation.methods.iter().enumerate() {
+                            let branch = if i + 1 == implementation.methods.len() {
+                                "└─"
+                            } else {
+                                "├─"
+                            };
+                            let via = if m.via.is_empty() {
+                                String::new()
+                            } else {
+                                format!(" via {}", m.via.join("."))
+                            };
+                            println!(
+                                "│  {} {} → {}{} {}",
+                                branch,
+                                m.requirement
+                                    .metadata
+                                    .get("method")
+                                    .unwrap_or(&m.requirement.name),
+                                m.method.name,
+                                via.dimmed(),
+                                format!("({}:{})", m.method.file_path.display(), m.method.line)
+                                    .dimmed()
+                            );
+                        }
                     }
 
                     println!();
-                    println!("{} {} dependencies found", "→".blue(), traces.len());
+                    println!(
+                        "{} {} implementations found",
+                        "→".blue(),
+                        implementations.len()
+                    );
                 }
                 "json" => {
-                    let json = serde_json::to_string_pretty(&traces)?;
-                    println!("{}", json);
+                    let results: Vec<schema::Implementation> = implementations
+                        .iter()
+                        .map(schema::Implementation::from)
+                        .collect();
+                    schema::print_json(&results)?;
                 }
                 _ => anyhow::bail!("Unknown output format: {}", output),
             }
         }
 
-        Commands::Callers {
+        Commands::MethodImplementations {
+            method,
             graph: graph_file,
-            function,
-            count,
             output,
-            show_lines,
         } => {
-            let graph = load_graph(graph_file)?;
-            let callers = graph.find_callers(function);
-
-            if *count {
-                println!("{}", callers.len());
-                return Ok(());
-            }
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
+            let method_node = graph.resolve_method(method)?;
+            let implementations = graph.method_implementations(method_node);
 
-            if callers.is_empty() {
+            if implementations.is_empty() && output != "json" {
                 if !cli.quiet {
-                    println!("{}", format!("No callers found for {}", function).yellow());
+                    println!(
+                        "{}",
+                        format!("No implementations found for {}", method).yellow()
+                    );
                 }
                 return Ok(());
             }
 
-            match output.as_str() {
+            match output {
                 "tree" => {
-                    println!("{}", format!("Callers of {}", function).bold());
+                    let of_interface = method_node.metadata.contains_key("abstract");
+                    let heading = if of_interface {
+                        format!("Implementations of {}", method)
+                    } else {
+                        format!("Interface methods satisfied by {}", method)
+                    };
+                    println!("{}", heading.bold());
                     println!();
 
-                    for caller in &callers {
-                        let line_info = if *show_lines {
-                            format!(" ({}:{})", caller.file_path.display(), caller.line)
+                    for (i, implementation) in implementations.iter().enumerate() {
+                        let last = i + 1 == implementations.len();
+                        let found = if of_interface {
+                            implementation.method
                         } else {
-                            String::new()
+                            implementation.requirement
                         };
-
-                        // Try to get the calling function name from the node
-                        if let Some(node) = graph.get_node_by_id(&caller.from) {
-                            println!("├─ {}{}", node.name.cyan(), line_info.dimmed());
+                        println!(
+                            "{} {} {}",
+                            if last { "└─" } else { "├─" },
+                            found.name.cyan(),
+                            format!("({}:{})", found.file_path.display(), found.line).dimmed()
+                        );
+                        let implementer = if implementation.pointer {
+                            format!("*{}", implementation.implementer.name)
                         } else {
-                            println!("├─ {}{}", caller.from.cyan(), line_info.dimmed());
-                        }
-                    }
-
-                    println!();
-                    println!("{} {} callers found", "→".blue(), callers.len());
-                }
-                "json" => {
-                    let json = serde_json::to_string_pretty(&callers)?;
-                    println!("{}", json);
-                }
-                "table" => {
-                    println!(
-                        "{:<40} {:<30} {:<10}",
-                        "Caller".bold(),
-                        "File".bold(),
-                        "Line".bold()
-                    );
-                    println!("{}", "-".repeat(80));
-
-                    for caller in &callers {
-                        let caller_name = graph
-                            .get_node_by_id(&caller.from)
-                            .map(|n| n.name.as_str())
-                            .unwrap_or(&caller.from);
-
+                            implementation.implementer.name.clone()
+                        };
+                        let via = if implementation.via.is_empty() {
+                            String::new()
+                        } else {
+                            format!(" via {}", implementation.via.join("."))
+                        };
                         println!(
-                            "{:<40} {:<30} {:<10}",
-                            caller_name,
-                            caller
-                                .file_path
-                                .file_name()
-                                .and_then(|n| n.to_str())
-                                .unwrap_or(""),
-                            caller.line
+                            "{}  └─ {} implements {}{}",
+                            if last { " " } else { "│" },
+                            implementer,
+                            implementation.interface.name,
+                            via.dimmed()
                         );
                     }
 
                     println!();
-                    println!("{} {} callers found", "→".blue(), callers.len());
+                    println!(
+                        "{} {} implementations found",
+                        "→".blue(),
+                        implementations.len()
+                    );
+                }
+                "json" => {
+                    let results: Vec<schema::MethodImplementation> = implementations
+                        .iter()
+                        .map(schema::MethodImplementation::from)
+                        .collect();
+                    schema::print_json(&results)?;
                 }
                 _ => anyhow::bail!("Unknown output format: {}", output),
             }
@@ -936,67 +3404,71 @@ fn main() -> Result<()> {
         Commands::Path {
             graph: graph_file,
             from,
+            roots,
             to,
             limit,
             all,
             max_depth,
             output,
         } => {
-            let graph = load_graph(graph_file)?;
+            let output = output_format(&cli, output);
+            if let Some(roots) = roots.as_deref().filter(|roots| *roots != ENTRY_POINTS) {
+                anyhow::bail!("Unknown root set: {} (expected {})", roots, ENTRY_POINTS);
+            }
+            let graph = open_graph(&cli, graph_file)?;
 
-            // Find the starting node
-            let from_nodes = graph.get_nodes_by_name(from);
-            if from_nodes.is_empty() {
-                anyhow::bail!("Starting function not found: {}", from);
-            }
-
-            let from_node = from_nodes[0];
-
-            let paths = if let Some(n) = limit {
-                // Find N paths using DFS with early termination
-                let mut found_paths = graph.find_paths_limited(&from_node.id, to, *max_depth, *n);
-                found_paths.sort_by_key(|p| p.len());
-                found_paths
-            } else if *all {
-                // Find all paths (warning: may be very slow)
-                let mut found_paths =
-                    graph.find_paths_limited(&from_node.id, to, *max_depth, usize::MAX);
-                found_paths.sort_by_key(|p| p.len());
-                found_paths
-            } else {
-                // Default: Find shortest path using BFS (fastest)
-                if let Some(shortest_path) = graph.find_shortest_path(&from_node.id, to, *max_depth)
-                {
-                    vec![shortest_path]
-                } else {
-                    Vec::new()
-                }
+            let options = PathOptions {
+                max_depth: *max_depth,
+                all: *all || limit.is_some(),
+                limit: *limit,
+            };
+            let (paths, from) = match from {
+                Some(from) => (graph.call_paths(from, to, &options)?, from.as_str()),
+                None => (graph.entry_point_paths(to, &options)?, "entry points"),
             };
 
             if paths.is_empty() {
-                if !cli.quiet {
+                if output == "json" {
+                    schema::print_json(&Vec::<schema::CallPath>::new())?;
+                } else if !cli.quiet {
                     println!(
                         "{}",
-                        format!("No path found from {} to {}", from, to).yellow()
+                        format!(
+                            "No path found from {} to {} within {} calls",
+                            from, to, max_depth
+                        )
+                        .yellow()
                     );
                 }
-                return Ok(());
+                std::process::exit(EXIT_NO_PATH);
             }
 
-            match output.as_str() {
+            match output {
                 "tree" => {
                     println!("{}", format!("Paths from {} to {}", from, to).bold());
                     println!();
 
                     for (idx, path) in paths.iter().enumerate() {
-                        println!("{} Path {} (length: {})", "→".blue(), idx + 1, path.len());
-                        for (i, step) in path.iter().enumerate() {
-                            let prefix = if i == path.len() - 1 {
+                        println!(
+                            "{} Path {}: {}",
+                            "→".blue(),
+                            idx + 1,
+                            path.names().join(" → ")
+                        );
+                        println!("  {}", path.start.name.cyan());
+                        for (i, hop) in path.hops.iter().enumerate() {
+                            let prefix = if i == path.hops.len() - 1 {
                                 "└─"
                             } else {
                                 "├─"
                             };
-                            println!("  {} {}", prefix, step.cyan());
+                            println!(
+                                "  {} {} {}",
+                                prefix,
+                                hop.callee_name().cyan(),
+                                format!("({}:{})", hop.call.file_path.display(), hop.call.line)
+                                    .dimmed()
+                            );
                         }
                         println!();
                     }
@@ -1004,8 +3476,9 @@ fn main() -> Result<()> {
                     println!("{} {} paths found", "→".blue(), paths.len());
                 }
                 "json" => {
-                    let json = serde_json::to_string_pretty(&paths)?;
-                    println!("{}", json);
+                    let paths: Vec<schema::CallPath> =
+                        paths.iter().map(schema::CallPath::from).collect();
+                    schema::print_json(&paths)?;
                 }
                 _ => anyhow::bail!("Unknown output format: {}", output),
             }
@@ -1018,7 +3491,8 @@ fn main() -> Result<()> {
             limit,
             output,
         } => {
-            let graph = load_graph(graph_file)?;
+            let output = output_format(&cli, output);
+            let graph = open_graph(&cli, graph_file)?;
 
             match analysis_type.as_str() {
                 "complexity" => {
@@ -1040,7 +3514,7 @@ fn main() -> Result<()> {
                         results.truncate(*limit_count);
                     }
 
-                    match output.as_str() {
+                    match output {
                         "table" => {
                             println!(
                                 "{:<40} {:<10} {:<10} {:<10}",
@@ -1073,8 +3547,7 @@ fn main() -> Result<()> {
                                     })
                                 })
                                 .collect();
-                            let json = serde_json::to_string_pretty(&json_results)?;
-                            println!("{}", json);
+                            schema::print_json(&json_results)?;
                         }
                         _ => anyhow::bail!("Unknown output format: {}", output),
                     }
@@ -1084,12 +3557,12 @@ fn main() -> Result<()> {
                     let limit_count = limit.unwrap_or(20);
                     let hotspots = graph.find_hotspots(limit_count);
 
-                    if hotspots.is_empty() {
+                    if hotspots.is_empty() && output != "json" {
                         println!("{}", "No hotspots found".yellow());
                         return Ok(());
                     }
 
-                    match output.as_str() {
+                    match output {
                         "table" => {
                             println!("{:<50} {:<15}", "Function".bold(), "Call Count".bold());
                             println!("{}", "-".repeat(65));
@@ -1102,8 +3575,7 @@ fn main() -> Result<()> {
                             println!("{} {} hotspots found", "→".blue(), hotspots.len());
                         }
                         "json" => {
-                            let json = serde_json::to_string_pretty(&hotspots)?;
-                            println!("{}", json);
+                            schema::print_json(&hotspots)?;
                         }
                         _ => anyhow::bail!("Unknown output format: {}", output),
                     }
@@ -1133,6 +3605,20 @@ fn main() -> Result<()> {
                         results.truncate(*limit_count);
                     }
 
+                    if output == "json" {
+                        let json_results: Vec<_> = results
+                            .iter()
+                            .map(|(package, count)| {
+                                serde_json::json!({
+                                    "package": package,
+                                    "dependencies": count
+                                })
+                            })
+                            .collect();
+                        schema::print_json(&json_results)?;
+                        return Ok(());
+                    }
+
                     println!("{:<40} {:<15}", "Package".bold(), "Dependencies".bold());
                     println!("{}", "-".repeat(55));
 
@@ -1145,11 +3631,29 @@ fn main() -> Result<()> {
                 }
 
                 "circular" => {
-                    println!(
-                        "{}",
-                        "Circular dependency detection not yet implemented".yellow()
-                    );
-                    println!("Coming soon!");
+                    let mut cycles = graph.cycles();
+                    if let Some(limit_count) = limit {
+                        cycles.truncate(*limit_count);
+                    }
+
+                    if output == "json" {
+                        let cycles: Vec<schema::Cycle> =
+                            cycles.iter().map(schema::Cycle::from).collect();
+                        schema::print_json(&cycles)?;
+                        return Ok(());
+                    }
+
+                    println!("{:<60} {:<10}", "Cycle".bold(), "Functions".bold());
+                    println!("{}", "-".repeat(70));
+
+                    for cycle in &cycles {
+                        let names: Vec<&str> =
+                            cycle.nodes.iter().map(|node| node.name.as_str()).collect();
+                        println!("{:<60} {:<10}", names.join(" → "), names.len());
+                    }
+
+                    println!();
+                    println!("{} {} cycles found", "→".blue(), cycles.len());
                 }
 
                 _ => anyhow::bail!(
@@ -1165,8 +3669,19 @@ fn main() -> Result<()> {
             format,
             filter,
             exclude_tests,
+            cluster_by_file,
+            no_external,
+            group_by,
+            collapse,
+            keep_internal,
+            root,
+            depth,
         } => {
-            let mut graph = load_graph(graph_file)?;
+            let group_by: Option<GroupBy> = group_by.as_deref().map(str::parse).transpose()?;
+            if group_by.is_some() && matches!(format.as_str(), "graphml" | "csv") {
+                anyhow::bail!("--group-by works with dot, mermaid and json exports");
+            }
+            let mut graph = open_graph(&cli, graph_file)?;
 
             // Apply filters if specified
             if filter.is_some() || *exclude_tests {
@@ -1184,6 +3699,8 @@ fn main() -> Result<()> {
                                     "method" => Some(NodeType::Method),
                                     "handler" => Some(NodeType::HttpHandler),
                                     "middleware" => Some(NodeType::Middleware),
+                                    "benchmark" => Some(NodeType::Benchmark),
+                                    "fuzz" => Some(NodeType::Fuzz),
                                     _ => anyhow::bail!("Unknown node type: {}", parts[1]),
                                 };
                             }
@@ -1209,6 +3726,21 @@ fn main() -> Result<()> {
                 }
             }
 
+            if let Some(root) = root {
+                let root_name = graph.resolve_symbol(root)?.name.clone();
+                graph = graph.extract_subgraph(&root_name, *depth);
+
+                if !cli.quiet {
+                    println!(
+                        "{} Limited to {} nodes within depth {} of {}",
+                        "→".blue(),
+                        graph.nodes.len().to_string().cyan(),
+                        depth,
+                        root.cyan()
+                    );
+                }
+            }
+
             if !cli.quiet {
                 println!(
                     "{}",
@@ -1228,7 +3760,14 @@ fn main() -> Result<()> {
                     }
                 }
                 "dot" => {
-                    dot::save_to_file(&graph, output)?;
+                    let options = dot::DotOptions {
+                        cluster_by_file: *cluster_by_file,
+                        include_external: !*no_external,
+                        group_by,
+                        collapse: *collapse,
+                        keep_internal: *keep_internal,
+                    };
+                    dot::save_to_file(&graph, output, &options)?;
                     if !cli.quiet {
                         println!(
                             "{} Exported to DOT: {}",
@@ -1237,13 +3776,87 @@ fn main() -> Result<()> {
                         );
                     }
                 }
+                "mermaid" => {
+                    let options = mermaid::MermaidOptions {
+                        include_external: !*no_external,
+                        group_by,
+                        collapse: *collapse,
+                        keep_internal: *keep_internal,
+                    };
+                    mermaid::save_to_file(&graph, output, &options)?;
+                    if !cli.quiet {
+                        println!(
+                            "{} Exported to Mermaid: {}",
+                            "✓".green().bold(),
+                            output.display()
+                        );
+                    }
+                }
                 "csv" => {
                     csv::save_to_files(&graph, output)?;
                     if !cli.quiet {
                         println!("{} Exported to CSV files", "✓".green().bold());
                     }
                 }
-                _ => anyhow::bail!("Unknown export format: {}. Use: graphml, dot, csv", format),
+                "json" => {
+                    match group_by {
+                        Some(by) => {
+                            let groups = schema::CallGraphGroups::new(
+                                &graph.group_calls(by),
+                                *collapse,
+                                *keep_internal,
+                                !*no_external,
+                            );
+                            let mut file = std::fs::File::create(output)?;
+                            schema::write_json(&mut file, &schema::Versioned::new(groups))?;
+                        }
+                        None => json::save_to_file(&graph, output)?,
+                    }
+                    if !cli.quiet {
+                        println!(
+                            "{} Exported to JSON: {}",
+                            "✓".green().bold(),
+                            output.display()
+                        );
+                    }
+                }
+                _ => anyhow::bail!(
+                    "Unknown export format: {}. Use: graphml, dot, mermaid, csv, json",
+                    format
+                ),
+            }
+        }
+
+        Commands::Report {
+            graph: graph_file,
+            out,
+            package,
+            no_symbols,
+            no_call_graph,
+            no_entry_points,
+            no_dead_code,
+        } => {
+            let graph = open_graph(&cli, graph_file)?;
+            let options = markdown::ReportOptions {
+                package: package.clone(),
+                symbols: !no_symbols,
+                call_graph: !no_call_graph,
+                entry_points: !no_entry_points,
+                dead_code: !no_dead_code,
+            };
+
+            match out {
+                Some(path) => {
+                    markdown::save_to_file(&graph, path, &options)?;
+                    if !cli.quiet {
+                        println!(
+                            "{} Report written to {}",
+                            "✓".green().bold(),
+                            path.display().to_string().cyan()
+                        );
+                    }
+                }
+                None => print!("{}", markdown::render(&graph, &options)?),
             }
         }
 
@@ -1253,7 +3866,7 @@ fn main() -> Result<()> {
             depth,
             output,
         } => {
-            let graph = load_graph(graph_file)?;
+            let graph = open_graph(&cli, graph_file)?;
 
             if !cli.quiet {
                 println!(
@@ -1293,124 +3906,166 @@ fn main() -> Result<()> {
         }
 
         Commands::Diff {
-            old_graph,
-            new_graph,
+            old,
+            new,
+            refs,
+            language,
             show_added,
             show_removed,
             show_changed,
             complexity_threshold,
             output,
         } => {
-            let old = load_graph(old_graph)?;
-            let new = load_graph(new_graph)?;
-
-            if !cli.quiet {
-                println!("{}", "Comparing graphs...".green().bold());
-            }
-
-            let diff = old.diff(&new);
-
-            match output.as_str() {
-                "json" => {
-                    let json = serde_json::to_string_pretty(&diff)?;
-                    println!("{}", json);
-                }
-                "table" => {
-                    // Summary
-                    println!("\n{}", "=== GRAPH DIFF SUMMARY ===".bold());
-                    println!(
-                        "Added nodes:   {}",
-                        diff.added_nodes.len().to_string().green()
-                    );
-                    println!(
-                        "Removed nodes: {}",
-                        diff.removed_nodes.len().to_string().red()
-                    );
-                    println!(
-                        "Changed nodes: {}",
-                        diff.changed_nodes.len().to_string().yellow()
-                    );
-                    println!(
-                        "Edge changes:  {} added, {} removed",
-                        diff.added_edges_count.to_string().green(),
-                        diff.removed_edges_count.to_string().red()
-                    );
-
-                    // Show added nodes if requested or if no specific flags
-                    if (*show_added || (!show_added && !show_removed && !show_changed))
-                        && !diff.added_nodes.is_empty()
-                    {
-                        println!("\n{}", "=== ADDED NODES ===".green().bold());
-                        for node_id in &diff.added_nodes {
-                            println!("  {} {}", "+".green(), node_id);
+            let output = output_format(&cli, output);
+            // Checkouts live until the comparison has read their sources
+            let mut worktrees = Vec::new();
+            let (old_graph, new_graph) = match (refs, old, new) {
+                (Some(refs), directory, None) => {
+                    let directory = directory.as_deref().unwrap_or(Path::new("."));
+                    let prefix = git(directory, &["rev-parse", "--show-prefix"])?;
+                    let mut graphs = Vec::new();
+                    for (rev, name) in refs.iter().zip(["old", "new"]) {
+                        if !cli.quiet && output != "json" {
+                            println!("{} {}", "Indexing".green().bold(), rev);
                         }
+                        let worktree = Worktree::add(directory, rev, name)?;
+                        graphs.push(index_directory(&worktree.path.join(&prefix), language)?);
+                        worktrees.push(worktree);
                     }
-
-                    // Show removed nodes
-                    if (*show_removed || (!show_added && !show_removed && !show_changed))
-                        && !diff.removed_nodes.is_empty()
-                    {
-                        println!("\n{}", "=== REMOVED NODES ===".red().bold());
-                        for node_id in &diff.removed_nodes {
-                            println!("  {} {}", "-".red(), node_id);
-                        }
+                    let new_graph = graphs.pop().unwrap();
+                    (graphs.pop().unwrap(), new_graph)
+                }
+                (Some(_), _, Some(_)) => {
+                    anyhow::bail!("--ref compares one directory at two refs; pass a single path")
+                }
+                (None, Some(old), Some(new)) => {
+                    if !cli.quiet && output != "json" {
+                        println!("{}", "Comparing snapshots...".green().bold());
                     }
+                    (load_snapshot(old, language)?, load_snapshot(new, language)?)
+                }
+                (None, _, _) => anyhow::bail!("Pass two snapshots to compare, or --ref OLD NEW"),
+            };
 
-                    // Show changed nodes
-                    if (*show_changed || (!show_added && !show_removed && !show_changed))
-                        && !diff.changed_nodes.is_empty()
-                    {
-                        println!("\n{}", "=== CHANGED NODES ===".yellow().bold());
-                        for change in &diff.changed_nodes {
-                            println!(
-                                "  {} {} (line {} → {})",
-                                "~".yellow(),
-                                change.node_name,
-                                change.old_line,
-                                change.new_line
-                            );
-                            if change.old_signature != change.new_signature {
-                                println!("    Old: {}", change.old_signature.dimmed());
-                                println!("    New: {}", change.new_signature);
+            let changes = old_graph.changes(&new_graph, *complexity_threshold);
+            let diff = schema::GraphDiff::new(&changes, &old_graph.metadata.root_path);
+            drop(worktrees);
+
+            match output {
+                "json" => schema::print_json(&schema::Versioned::new(&diff))?,
+                "text" | "table" => {
+                    let all = !show_added && !show_removed && !show_changed;
+                    let (added, removed, changed) = (
+                        *show_added || all,
+                        *show_removed || all,
+                        *show_changed || all,
+                    );
+                    let count = |f: fn(&schema::FileDiff) -> usize| -> usize {
+                        diff.files.iter().map(f).sum()
+                    };
+
+                    for file in &diff.files {
+                        println!("\n{}", file.file.bold());
+                        if added {
+                            for symbol in &file.added {
+                                println!(
+                                    "  {} {}  (line {})",
+                                    "+".green(),
+                                    symbol.signature,
+                                    symbol.line
+                                );
                             }
                         }
-                    }
-
-                    // Show complexity changes if threshold specified
-                    if let Some(threshold) = complexity_threshold {
-                        let significant_changes: Vec<_> = diff
-                            .complexity_changes
-                            .iter()
-                            .filter(|c| c.change.abs() >= *threshold as i32)
-                            .collect();
-
-                        if !significant_changes.is_empty() {
-                            println!(
-                                "\n{}",
-                                format!("=== COMPLEXITY CHANGES (≥{}) ===", threshold).bold()
-                            );
-                            for change in significant_changes {
-                                let arrow = if change.change > 0 {
-                                    "↑".red()
+                        if removed {
+                            for symbol in &file.removed {
+                                println!(
+                                    "  {} {}  (line {})",
+                                    "-".red(),
+                                    symbol.signature,
+                                    symbol.line
+                                );
+                            }
+                        }
+                        if changed {
+                            for rename in &file.renamed {
+                                let from = if rename.old_file == file.file {
+                                    String::new()
                                 } else {
-                                    "↓".green()
+                                    format!(" from {}", rename.old_file)
                                 };
-                                println!("  {} {} ({:+})", arrow, change.node_name, change.change);
                                 println!(
-                                    "    Fan-in:  {} → {}",
-                                    change.old_fan_in, change.new_fan_in
+                                    "  {} {} renamed to {}{}  (line {})",
+                                    "→".cyan(),
+                                    rename.old_name,
+                                    rename.new_name,
+                                    from,
+                                    rename.line
+                                );
+                            }
+                            for change in &file.changed {
+                                println!(
+                                    "  {} {}  (line {})",
+                                    "~".yellow(),
+                                    change.name,
+                                    change.line
+                                );
+                                println!("      {}", change.old_signature.dimmed());
+                                println!("      {}", change.new_signature);
+                            }
+                            for change in &file.complexity_changes {
+                                println!(
+                                    "  {} {} complexity {} → {}  (line {})",
+                                    "~".yellow(),
+                                    change.name,
+                                    change.old_complexity,
+                                    change.new_complexity,
+                                    change.line
+                                );
+                            }
+                        }
+                        if added {
+                            for call in &file.added_calls {
+                                println!(
+                                    "  {} call {} → {}  (line {})",
+                                    "+".green(),
+                                    call.caller,
+                                    call.callee,
+                                    call.line
                                 );
+                            }
+                        }
+                        if removed {
+                            for call in &file.removed_calls {
                                 println!(
-                                    "    Fan-out: {} → {}",
-                                    change.old_fan_out, change.new_fan_out
+                                    "  {} call {} → {}  (line {})",
+                                    "-".red(),
+                                    call.caller,
+                                    call.callee,
+                                    call.line
                                 );
                             }
                         }
                     }
 
-                    println!();
+                    if diff.files.is_empty() {
+                        println!("{}", "No changes".green());
+                    } else {
+                        println!(
+                            "\n{} added, {} removed, {} changed, {} renamed; calls: {} added, {} removed",
+                            count(|f| f.added.len()).to_string().green(),
+                            count(|f| f.removed.len()).to_string().red(),
+                            count(|f| f.changed.len()).to_string().yellow(),
+                            count(|f| f.renamed.len()).to_string().cyan(),
+                            count(|f| f.added_calls.len()).to_string().green(),
+                            count(|f| f.removed_calls.len()).to_string().red()
+                        );
+                    }
                 }
-                _ => anyhow::bail!("Unknown output format: {}. Use: table, json", output),
+                _ => anyhow::bail!("Unknown output format: {}. Use: text, json", output),
+            }
+
+            if !diff.files.is_empty() {
+                std::process::exit(EXIT_CHANGED);
             }
         }
     }
@@ -0,0 +1,495 @@
+//! A Model Context Protocol server for `codenav mcp`.
+//!
+//! [`serve`] speaks JSON-RPC over the stdio transport, one message per line, and offers
+//! the graph as tools: `find_symbol`, `get_call_graph`, `find_references`, `get_source`
+//! and `reindex`. The directory is indexed on the first tool call rather than at
+//! startup, so clients that only list tools never pay for a parse. Results are compact
+//! JSON built from the [`schema`](crate::schema) structures the CLI's `--json` emits,
+//! with source text clipped to [`McpOptions::max_snippet_bytes`].
+
+use crate::core::{
+    CodeGraph, NodeType, Page, PageRequest, ReferenceKind, SearchOptions, SymbolFilter,
+};
+use crate::parser::GoParser;
+use crate::schema::{self, Reference, SearchResult, Symbol};
+use anyhow::{bail, Context, Result};
+use serde::Serialize;
+use serde_json::{json, Value};
+use std::fs;
+use std::io::{BufRead, Write};
+use std::path::{Path, PathBuf};
+
+/// Protocol revisions the server understands, newest last
+const PROTOCOL_VERSIONS: &[&str] = &["2024-11-05", "2025-03-26", "2025-06-18"];
+
+/// JSON-RPC error codes used in responses
+const PARSE_ERROR: i64 = -32700;
+const METHOD_NOT_FOUND: i64 = -32601;
+const INVALID_PARAMS: i64 = -32602;
+
+/// Deepest `get_call_graph` walks, however much a client asks for
+const MAX_DEPTH: usize = 10;
+
+#[derive(Debug, Clone)]
+pub struct McpOptions {
+    /// Index `_test.go` files too
+    pub include_tests: bool,
+    /// Longest source text a tool returns; longer snippets are cut at a line break
+    pub max_snippet_bytes: usize,
+}
+
+impl Default for McpOptions {
+    fn default() -> Self {
+        Self {
+            include_tests: false,
+            max_snippet_bytes: 4000,
+        }
+    }
+}
+
+/// Answer MCP requests for the Go code under `root` until `input` closes
+pub fn serve(
+    root: &Path,
+    options: &McpOptions,
+    input: impl BufRead,
+    mut output: impl Write,
+) -> Result<()> {
+    let mut server = Server {
+        root: root.to_path_buf(),
+        options: options.clone(),
+        graph: None,
+    };
+    for line in input.lines() {
+        let line = line?;
+        if line.trim().is_empty() {
+            continue;
+        }
+        let message: Value = match serde_json::from_str(&line) {
+            Ok(message) => message,
+            Err(err) => {
+                let response = error_response(Value::Null, PARSE_ERROR, &err.to_string());
+                schema::write_json_line(&mut output, &response)?;
+                continue;
+            }
+        };
+        // Notifications and responses to requests the server never sends need no reply
+        let (Some(method), Some(id)) = (message["method"].as_str(), message.get("id")) else {
+            continue;
+        };
+        let params = message.get("params").cloned().unwrap_or(Value::Null);
+
+        let response = match server.request(method, &params) {
+            Ok(result) => json!({ "jsonrpc": "2.0", "id": id, "result": result }),
+            Err((code, message)) => error_response(id.clone(), code, &message),
+        };
+        schema::write_json_line(&mut output, &response)?;
+    }
+    Ok(())
+}
+
+fn error_response(id: Value, code: i64, message: &str) -> Value {
+    json!({
+        "jsonrpc": "2.0",
+        "id": id,
+        "error": { "code": code, "message": message },
+    })
+}
+
+struct Server {
+    root: PathBuf,
+    options: McpOptions,
+    /// Built on the first tool call
+    graph: Option<CodeGraph>,
+}
+
+impl Server {
+    fn request(&mut self, method: &str, params: &Value) -> Result<Value, (i64, String)> {
+        match method {
+            "initialize" => {
+                // Agree to the client's revision when it is one we know, else offer ours
+                let requested = params["protocolVersion"].as_str().unwrap_or_default();
+                let version = PROTOCOL_VERSIONS
+                    .iter()
+                    .find(|&&version| version == requested)
+                    .or(PROTOCOL_VERSIONS.last())
+                    .copied();
+                Ok(json!({
+                    "protocolVersion": version,
+                    "capabilities": { "tools": { "listChanged": false } },
+                    "serverInfo": { "name": "codenav", "version": env!("CARGO_PKG_VERSION") },
+                }))
+            }
+            "ping" => Ok(json!({})),
+            "tools/list" => Ok(json!({ "tools": tools() })),
+            "tools/call" => {
+                let Some(name) = params["name"].as_str() else {
+                    return Err((INVALID_PARAMS, "Missing tool name".to_string()));
+                };
+                if !TOOL_NAMES.contains(&name) {
+                    return Err((INVALID_PARAMS, format!("Unknown tool: {}", name)));
+                }
+                let arguments = params.get("arguments").cloned().unwrap_or(json!({}));
+                // Failures inside a tool are results the model can read and act on
+                Ok(match self.call_tool(name, &arguments) {
+                    Ok(result) => json!({
+                        "content": [{ "type": "text", "text": versioned(result).to_string() }],
+                    }),
+                    Err(err) => json!({
+                        "content": [{ "type": "text", "text": format!("{:#}", err) }],
+                        "isError": true,
+                    }),
+                })
+            }
+            _ => Err((METHOD_NOT_FOUND, format!("Unsupported method: {}", method))),
+        }
+    }
+
+    fn call_tool(&mut self, name: &str, arguments: &Value) -> Result<Value> {
+        if name == "reindex" {
+            self.graph = None;
+        }
+        let max_snippet_bytes = self.options.max_snippet_bytes;
+        let graph = self.graph()?;
+
+        match name {
+            "find_symbol" => {
+                let query = string_arg(arguments, "name")?;
+                let kinds = match arguments["kind"].as_str() {
+                    Some(kind) => vec![kind.parse::<NodeType>()?],
+                    None => Vec::new(),
+                };
+                let request = page_args(arguments, Some(20))?;
+                let under = arguments["path"]
+                    .as_str()
+                    .map(|path| Path::new(&graph.metadata.root_path).join(path));
+
+                let options = SearchOptions {
+                    kinds,
+                    ..Default::default()
+                };
+                let matches = graph
+                    .search(query, &options)
+                    .into_iter()
+                    .filter(|m| {
+                        under
+                            .as_ref()
+                            .is_none_or(|dir| m.node.file_path.starts_with(dir))
+                    })
+                    .collect();
+                let page = request.apply(matches);
+                let results: Vec<SearchResult> =
+                    page.items.iter().map(SearchResult::from).collect();
+                let offset = arguments["offset"].as_u64().map(|offset| offset as usize);
+                let limit = usize_arg(arguments, "limit")?;
+                let listing = schema::Listing::new(&page, limit, offset, results);
+                Ok(serde_json::to_value(listing)?)
+            }
+            "get_call_graph" => {
+                let node = graph.resolve_symbol(string_arg(arguments, "symbol")?)?;
+                let depth = usize_arg(arguments, "depth")?
+                    .unwrap_or(1)
+                    .clamp(1, MAX_DEPTH);
+                let request = page_args(arguments, None)?;
+                match arguments["direction"].as_str().unwrap_or("callees") {
+                    "callees" => {
+                        let page = request.apply(graph.trace_dependencies(&node.id, depth));
+                        let calls = schema::call_edges(&page.items);
+                        let result = json!({ "symbol": Symbol::from(node), "calls": calls });
+                        Ok(with_page(arguments, result, &page))
+                    }
+                    "callers" => {
+                        let page = graph.callers_page(
+                            &node.name,
+                            depth,
+                            &SymbolFilter::default(),
+                            &request,
+                        );
+                        let callers = schema::references(&page.items);
+                        let result = json!({ "symbol": Symbol::from(node), "callers": callers });
+                        Ok(with_page(arguments, result, &page))
+                    }
+                    other => bail!("Unknown direction: {} (expected callees or callers)", other),
+                }
+            }
+            "find_references" => {
+                let node = graph.resolve_symbol(string_arg(arguments, "symbol")?)?;
+                let kind = arguments["kind"]
+                    .as_str()
+                    .map(str::parse::<ReferenceKind>)
+                    .transpose()?;
+                let mut references: Vec<ReferenceWithCode> = graph
+                    .references(&node.name)
+                    .iter()
+                    .filter(|site| kind.is_none_or(|kind| site.kind == kind))
+                    .map(|site| ReferenceWithCode {
+                        reference: Reference::from(site),
+                        code: clip(
+                            site.call_site.lines().next().unwrap_or("").trim(),
+                            max_snippet_bytes,
+                        )
+                        .0,
+                    })
+                    .collect();
+                // The order schema::references gives
+                references.sort_by(|a, b| {
+                    (a.reference.depth, &a.reference.location)
+                        .cmp(&(b.reference.depth, &b.reference.location))
+                });
+                let page = page_args(arguments, None)?.apply(references);
+                let result = json!({ "symbol": Symbol::from(node), "references": &page.items });
+                Ok(with_page(arguments, result, &page))
+            }
+            "get_source" => {
+                let node = graph.resolve_symbol(string_arg(arguments, "symbol")?)?;
+                let max_bytes = usize_arg(arguments, "max_bytes")?
+                    .map_or(max_snippet_bytes, |max| max.min(max_snippet_bytes));
+                let source = fs::read_to_string(&node.file_path)
+                    .with_context(|| format!("Failed to read {}", node.file_path.display()))?;
+                let declaration: Vec<&str> = source
+                    .lines()
+                    .skip(node.line.saturating_sub(1))
+                    .take(node.end_line.max(node.line) + 1 - node.line)
+                    .collect();
+                let (code, truncated) = clip(&declaration.join("\n"), max_bytes);
+                Ok(json!({
+                    "symbol": Symbol::from(node),
+                    "code": code,
+                    "truncated": truncated,
+                }))
+            }
+            "reindex" => Ok(json!({
+                "root": graph.metadata.root_path,
+                "files_parsed": graph.metadata.stats.files_parsed,
+                "symbols": graph.nodes.len(),
+                "calls": graph.edges.len(),
+            })),
+            _ => unreachable!("tools/call checks the name"),
+        }
+    }
+
+    /// The graph, indexing the directory the first time it is needed
+    fn graph(&mut self) -> Result<&CodeGraph> {
+        if self.graph.is_none() {
+            let root = self
+                .root
+                .canonicalize()
+                .with_context(|| format!("Directory not found: {}", self.root.display()))?;
+            let mut graph = CodeGraph::new(root.to_string_lossy().to_string(), "go".to_string());
+            GoParser::new()?
+                .with_tests(self.options.include_tests)
+                .parse_directory(&root, &mut graph)
+                .with_context(|| format!("Failed to index {}", root.display()))?;
+            self.graph = Some(graph);
+        }
+        Ok(self.graph.as_ref().expect("indexed above"))
+    }
+}
+
+/// A reference with its source text, e.g. `Add(5, 3)`; only the first line of a call
+/// spanning several
+#[derive(Serialize)]
+struct ReferenceWithCode {
+    #[serde(flatten)]
+    reference: Reference,
+    code: String,
+}
+
+const TOOL_NAMES: &[&str] = &[
+    "find_symbol",
+    "get_call_graph",
+    "find_references",
+    "get_source",
+    "reindex",
+];
+
+/// Tool descriptions with the JSON Schema of their arguments, as `tools/list` returns
+fn tools() -> Value {
+    let symbol = json!({
+        "type": "string",
+        "description": "Function, method or type name, e.g. Add or (*Calculator).Add",
+    });
+    json!([
+        {
+            "name": "find_symbol",
+            "description": "Search indexed symbols by name, best matches first: exact, prefix, substring, then fuzzy.",
+            "inputSchema": {
+                "type": "object",
+                "properties": {
+                    "name": { "type": "string", "description": "Name or part of a name" },
+                    "kind": {
+                        "type": "string",
+                        "enum": ["function", "method", "struct", "interface", "type", "const", "var", "field"],
+                        "description": "Only symbols of this kind",
+                    },
+                    "path": {
+                        "type": "string",
+                        "description": "Only symbols in files under this path, relative to the indexed directory",
+                    },
+                    "limit": { "type": "integer", "minimum": 1, "default": 20, "description": "Return at most this many; given, the result is a page with the total" },
+                    "offset": { "type": "integer", "minimum": 0, "description": "Skip this many; the result is then a page with the total" },
+                },
+                "required": ["name"],
+                "additionalProperties": false,
+            },
+        },
+        {
+            "name": "get_call_graph",
+            "description": "Calls a function makes, or the functions calling it, up to a depth.",
+            "inputSchema": {
+                "type": "object",
+                "properties": {
+                    "symbol": symbol,
+                    "direction": { "type": "string", "enum": ["callees", "callers"], "default": "callees" },
+                    "depth": { "type": "integer", "minimum": 1, "maximum": MAX_DEPTH, "default": 1 },
+                    "limit": { "type": "integer", "minimum": 1, "description": "Return at most this many; the result then carries a page with the total" },
+                    "offset": { "type": "integer", "minimum": 0, "description": "Skip this many; the result then carries a page with the total" },
+                },
+                "required": ["symbol"],
+                "additionalProperties": false,
+            },
+        },
+        {
+            "name": "find_references",
+            "description": "Every call and other use of a symbol, with the source text of each.",
+            "inputSchema": {
+                "type": "object",
+                "properties": {
+                    "symbol": symbol,
+                    "kind": {
+                        "type": "string",
+                        "enum": ["call", "assignment", "argument", "method_value", "value", "write", "closure"],
+                        "description": "Only references of this kind",
+                    },
+                    "limit": { "type": "integer", "minimum": 1, "description": "Return at most this many; the result then carries a page with the total" },
+                    "offset": { "type": "integer", "minimum": 0, "description": "Skip this many; the result then carries a page with the total" },
+                },
+                "required": ["symbol"],
+                "additionalProperties": false,
+            },
+        },
+        {
+            "name": "get_source",
+            "description": "Source text of a symbol's declaration, clipped at a line break when long.",
+            "inputSchema": {
+                "type": "object",
+                "properties": {
+                    "symbol": symbol,
+                    "max_bytes": {
+                        "type": "integer",
+                        "minimum": 1,
+                        "description": "Clip to this many bytes; never more than the server's limit",
+                    },
+                },
+                "required": ["symbol"],
+                "additionalProperties": false,
+            },
+        },
+        {
+            "name": "reindex",
+            "description": "Parse the directory again after files changed on disk.",
+            "inputSchema": { "type": "object", "properties": {}, "additionalProperties": false },
+        },
+    ])
+}
+
+fn string_arg<'a>(arguments: &'a Value, name: &str) -> Result<&'a str> {
+    match arguments.get(name) {
+        Some(Value::String(value)) => Ok(value),
+        Some(_) => bail!("Argument {} must be a string", name),
+        None => bail!("Missing required argument: {}", name),
+    }
+}
+
+/// `result` with the `schema_version` its structures follow, as `--json` output has it
+fn versioned(mut result: Value) -> Value {
+    if let Value::Object(fields) = &mut result {
+        fields
+            .entry("schema_version")
+            .or_insert(json!(schema::SCHEMA_VERSION));
+    }
+    result
+}
+
+/// Whether the client asked for a page, by giving `limit` or `offset`
+fn is_paged(arguments: &Value) -> bool {
+    ["limit", "offset"]
+        .iter()
+        .any(|name| arguments.get(name).is_some_and(|value| !value.is_null()))
+}
+
+/// The page the `limit` and `offset` arguments ask for
+fn page_args(arguments: &Value, default_limit: Option<usize>) -> Result<PageRequest> {
+    let offset = match arguments.get("offset") {
+        None | Some(Value::Null) => 0,
+        Some(value) => match value.as_u64() {
+            Some(n) => n as usize,
+            None => bail!("Argument offset must be a non-negative integer"),
+        },
+    };
+    let limit = usize_arg(arguments, "limit")?.or(default_limit);
+    Ok(PageRequest::new(offset, limit))
+}
+
+/// `result` with a `page` object saying where its list sits in the whole: `total`,
+/// `offset`, `has_more` and `next_offset`, as in `schema::Page`. Only added when the
+/// client asked for a page.
+fn with_page<T>(arguments: &Value, mut result: Value, page: &Page<T>) -> Value {
+    if is_paged(arguments) {
+        result["page"] = json!({
+            "schema_version": schema::SCHEMA_VERSION,
+            "total": page.total,
+            "offset": page.offset,
+            "has_more": page.has_more(),
+            "next_offset": page.next_offset(),
+        });
+    }
+    result
+}
+
+fn usize_arg(arguments: &Value, name: &str) -> Result<Option<usize>> {
+    match arguments.get(name) {
+        None | Some(Value::Null) => Ok(None),
+        Some(value) => match value.as_u64() {
+            Some(n) if n > 0 => Ok(Some(n as usize)),
+            _ => bail!("Argument {} must be a positive integer", name),
+        },
+    }
+}
+
+/// `text` cut to at most `max_bytes`, at the last line break that fits when there is
+/// one, and whether anything was cut
+fn clip(text: &str, max_bytes: usize) -> (String, bool) {
+    if text.len() <= max_bytes {
+        return (text.to_string(), false);
+    }
+    let mut end = max_bytes;
+    while !text.is_char_boundary(end) {
+        end -= 1;
+    }
+    if !text[end..].starts_with('\n') {
+        end = text[..end]
+            .rfind('\n')
+            .filter(|&newline| newline > 0)
+            .unwrap_or(end);
+    }
+    (text[..end].to_string(), true)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_clip_prefers_line_breaks() {
+        let text = "func Add(a int, b int) int {\n\treturn a + b\n}";
+        assert_eq!(clip(text, 100), (text.to_string(), false));
+        assert_eq!(
+            clip(text, 30),
+            ("func Add(a int, b int) int {".to_string(), true)
+        );
+        // The first line alone is too long, so it is cut mid-line
+        assert_eq!(clip(text, 10), ("func Add(a".to_string(), true));
+        // Never inside a character: é is two bytes
+        assert_eq!(clip("aé", 2), ("a".to_string(), true));
+    }
+}
@@ -0,0 +1,386 @@
+//! Which files under an indexed directory are source files of the project.
+//!
+//! Discovery honours `.gitignore` files at every level below the root, skips hidden
+//! directories and applies `--exclude` patterns, all with gitignore glob syntax.
+
+use std::collections::HashSet;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// File selection for one indexing run
+#[derive(Debug, Clone)]
+pub struct Discovery {
+    gitignore: bool,
+    hidden: bool,
+    generated: bool,
+    excludes: Vec<Rule>,
+}
+
+impl Default for Discovery {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl Discovery {
+    /// Honour `.gitignore`, skip hidden directories, include generated files
+    pub fn new() -> Self {
+        Self {
+            gitignore: true,
+            hidden: false,
+            generated: true,
+            excludes: Vec::new(),
+        }
+    }
+
+    /// Also skip paths matching these gitignore-style patterns, relative to the root:
+    /// `*.pb.go` anywhere, `gen/` for a directory, `/tools/*.go` only at the top
+    pub fn with_excludes(mut self, patterns: &[String]) -> Self {
+        self.excludes
+            .extend(patterns.iter().filter_map(|pattern| Rule::parse(pattern)));
+        self
+    }
+
+    /// Whether `.gitignore` files are read
+    pub fn with_gitignore(mut self, gitignore: bool) -> Self {
+        self.gitignore = gitignore;
+        self
+    }
+
+    /// Also descend into directories whose name starts with `.`
+    pub fn with_hidden(mut self, hidden: bool) -> Self {
+        self.hidden = hidden;
+        self
+    }
+
+    /// Whether files marked `// Code generated ... DO NOT EDIT.` are indexed
+    pub fn with_generated(mut self, generated: bool) -> Self {
+        self.generated = generated;
+        self
+    }
+
+    /// False for the contents of a generated file when generated files are left out
+    pub fn accepts_source(&self, source: &str) -> bool {
+        self.generated || !is_generated(source)
+    }
+
+    /// Every file under `root` that isn't ignored, excluded or inside a hidden
+    /// directory, sorted. Symlinked directories are followed, each real directory once.
+    pub fn files(&self, root: &Path) -> Vec<PathBuf> {
+        let mut files = Vec::new();
+        let mut ignores = Vec::new();
+        let mut seen = HashSet::new();
+        self.walk(root, root, &mut ignores, &mut seen, &mut files);
+        files.sort();
+        files
+    }
+
+    fn walk(
+        &self,
+        root: &Path,
+        dir: &Path,
+        ignores: &mut Vec<Gitignore>,
+        seen: &mut HashSet<PathBuf>,
+        files: &mut Vec<PathBuf>,
+    ) {
+        // A symlink back up the tree would otherwise be walked forever
+        let Ok(real) = fs::canonicalize(dir) else {
+            return;
+        };
+        if !seen.insert(real) {
+            return;
+        }
+        let Ok(entries) = fs::read_dir(dir) else {
+            return;
+        };
+
+        let pushed = self.gitignore
+            && match fs::read_to_string(dir.join(".gitignore")) {
+                Ok(text) => {
+                    ignores.push(Gitignore::parse(dir, &text));
+                    true
+                }
+                Err(_) => false,
+            };
+
+        let mut paths: Vec<PathBuf> = entries.filter_map(|e| e.ok()).map(|e| e.path()).collect();
+        paths.sort();
+        for path in paths {
+            // fs::metadata follows symlinks, so a link to a directory is walked as one
+            let Ok(metadata) = fs::metadata(&path) else {
+                continue;
+            };
+            let is_dir = metadata.is_dir();
+            let hidden = path
+                .file_name()
+                .is_some_and(|name| name.to_string_lossy().starts_with('.'));
+            if (is_dir && hidden && !self.hidden) || self.skips(root, &path, is_dir, ignores) {
+                continue;
+            }
+            if is_dir {
+                self.walk(root, &path, ignores, seen, files);
+            } else {
+                files.push(path);
+            }
+        }
+
+        if pushed {
+            ignores.pop();
+        }
+    }
+
+    fn skips(&self, root: &Path, path: &Path, is_dir: bool, ignores: &[Gitignore]) -> bool {
+        let excluded = relative(root, path)
+            .is_some_and(|relative| last_match(&self.excludes, &relative, is_dir) == Some(true));
+        // The innermost .gitignore with an opinion decides
+        excluded
+            || ignores.iter().rev().find_map(|gitignore| {
+                let relative = relative(&gitignore.dir, path)?;
+                last_match(&gitignore.rules, &relative, is_dir)
+            }) == Some(true)
+    }
+}
+
+/// Whether `source` carries Go's generated-code marker, a line
+/// `// Code generated <anything> DO NOT EDIT.` before the first line of code
+pub fn is_generated(source: &str) -> bool {
+    for line in source.lines() {
+        let line = line.trim_end();
+        if line.starts_with("// Code generated ") && line.ends_with(" DO NOT EDIT.") {
+            return true;
+        }
+        if !line.is_empty() && !line.starts_with("//") {
+            return false;
+        }
+    }
+    false
+}
+
+/// The rules of one `.gitignore` file, which apply below its directory
+#[derive(Debug, Clone)]
+struct Gitignore {
+    dir: PathBuf,
+    rules: Vec<Rule>,
+}
+
+impl Gitignore {
+    fn parse(dir: &Path, text: &str) -> Self {
+        Self {
+            dir: dir.to_path_buf(),
+            rules: text.lines().filter_map(Rule::parse).collect(),
+        }
+    }
+}
+
+/// One gitignore pattern
+#[derive(Debug, Clone)]
+struct Rule {
+    /// Pattern segments between slashes, `**` included
+    segments: Vec<String>,
+    /// `!pattern`: re-include what an earlier rule ignored
+    negated: bool,
+    /// `pattern/`: only matches directories
+    dir_only: bool,
+    /// A pattern with a slash before its end matches from the `.gitignore`'s directory;
+    /// one without matches a name at any depth
+    anchored: bool,
+}
+
+impl Rule {
+    fn parse(line: &str) -> Option<Self> {
+        let line = line.trim_end();
+        if line.is_empty() || line.starts_with('#') {
+            return None;
+        }
+        let (negated, pattern) = match line.strip_prefix('!') {
+            Some(rest) => (true, rest),
+            None => (false, line.strip_prefix('\\').unwrap_or(line)),
+        };
+        let (dir_only, pattern) = match pattern.strip_suffix('/') {
+            Some(rest) => (true, rest),
+            None => (false, pattern),
+        };
+        let anchored = pattern.contains('/');
+        let pattern = pattern.strip_prefix('/').unwrap_or(pattern);
+        if pattern.is_empty() {
+            return None;
+        }
+        Some(Self {
+            segments: pattern.split('/').map(str::to_string).collect(),
+            negated,
+            dir_only,
+            anchored,
+        })
+    }
+
+    fn matches(&self, path: &[&str], is_dir: bool) -> bool {
+        if self.dir_only && !is_dir {
+            return false;
+        }
+        let segments: Vec<&str> = self.segments.iter().map(String::as_str).collect();
+        if self.anchored {
+            match_segments(&segments, path)
+        } else {
+            path.last()
+                .is_some_and(|name| segments.len() == 1 && match_name(segments[0], name))
+        }
+    }
+}
+
+/// Whether the last rule matching `path` ignores it (`Some(true)`) or re-includes it
+fn last_match(rules: &[Rule], path: &[String], is_dir: bool) -> Option<bool> {
+    let path: Vec<&str> = path.iter().map(String::as_str).collect();
+    rules
+        .iter()
+        .rev()
+        .find(|rule| rule.matches(&path, is_dir))
+        .map(|rule| !rule.negated)
+}
+
+/// `path` below `base` as its components, if it is below it
+fn relative(base: &Path, path: &Path) -> Option<Vec<String>> {
+    let relative = path.strip_prefix(base).ok()?;
+    let components: Vec<String> = relative
+        .components()
+        .map(|c| c.as_os_str().to_string_lossy().to_string())
+        .collect();
+    (!components.is_empty()).then_some(components)
+}
+
+/// Match path components against pattern segments, where `**` spans any number of them
+fn match_segments(pattern: &[&str], path: &[&str]) -> bool {
+    match pattern.split_first() {
+        None => path.is_empty(),
+        Some((&"**", rest)) => (0..=path.len()).any(|skip| match_segments(rest, &path[skip..])),
+        Some((segment, rest)) => path
+            .split_first()
+            .is_some_and(|(name, tail)| match_name(segment, name) && match_segments(rest, tail)),
+    }
+}
+
+/// Match one path component against a glob with `*`, `?` and `[...]` classes
+fn match_name(pattern: &str, name: &str) -> bool {
+    let pattern: Vec<char> = pattern.chars().collect();
+    let name: Vec<char> = name.chars().collect();
+    match_chars(&pattern, &name)
+}
+
+fn match_chars(pattern: &[char], name: &[char]) -> bool {
+    match pattern.split_first() {
+        None => name.is_empty(),
+        Some(('*', rest)) => (0..=name.len()).any(|skip| match_chars(rest, &name[skip..])),
+        Some(('?', rest)) => !name.is_empty() && match_chars(rest, &name[1..]),
+        Some(('[', rest)) => match (name.split_first(), class_end(rest)) {
+            (Some((c, tail)), Some(end)) => {
+                in_class(&rest[..end], *c) && match_chars(&rest[end + 1..], tail)
+            }
+            // An unclosed bracket is a literal `[`
+            (Some(('[', tail)), None) => match_chars(rest, tail),
+            _ => false,
+        },
+        Some(('\\', rest)) if !rest.is_empty() => {
+            name.first() == Some(&rest[0]) && match_chars(&rest[1..], &name[1..])
+        }
+        Some((c, rest)) => name.first() == Some(c) && match_chars(rest, &name[1..]),
+    }
+}
+
+/// Index of the `]` closing a class that starts right after `[`
+fn class_end(class: &[char]) -> Option<usize> {
+    let skip = match class.first() {
+        Some('!') | Some('^') => 2,
+        _ => 1,
+    };
+    class
+        .iter()
+        .skip(skip)
+        .position(|&c| c == ']')
+        .map(|position| position + skip)
+}
+
+fn in_class(class: &[char], c: char) -> bool {
+    let (negated, class) = match class.first() {
+        Some('!') | Some('^') => (true, &class[1..]),
+        _ => (false, class),
+    };
+    let mut found = false;
+    let mut i = 0;
+    while i < class.len() {
+        if i + 2 < class.len() && class[i + 1] == '-' {
+            found |= class[i] <= c && c <= class[i + 2];
+            i += 3;
+        } else {
+            found |= class[i] == c;
+            i += 1;
+        }
+    }
+    found != negated
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn ignored(patterns: &str, path: &str, is_dir: bool) -> bool {
+        let rules: Vec<Rule> = patterns.lines().filter_map(Rule::parse).collect();
+        let path: Vec<String> = path.split('/').map(str::to_string).collect();
+        last_match(&rules, &path, is_dir) == Some(true)
+    }
+
+    #[test]
+    fn test_gitignore_patterns() {
+        assert!(ignored("*.pb.go", "api/v1/service.pb.go", false));
+        assert!(!ignored("*.pb.go", "api/v1/service.go", false));
+        assert!(ignored("build/", "build", true));
+        assert!(!ignored("build/", "build", false));
+        assert!(ignored("/tools/*.go", "tools/gen.go", false));
+        assert!(!ignored("/tools/*.go", "cmd/tools/gen.go", false));
+        assert!(ignored("docs/**/*.go", "docs/a/b/example.go", false));
+        assert!(ignored("docs/**/*.go", "docs/example.go", false));
+        assert!(ignored("file[0-9].go", "file7.go", false));
+        assert!(!ignored("file[!0-9].go", "file7.go", false));
+        assert!(ignored("?.go", "a.go", false));
+        assert!(!ignored("# comment\n\n", "# comment", false));
+    }
+
+    #[test]
+    fn test_negation_reincludes() {
+        let rules = "*.go\n!keep.go\n";
+        assert!(ignored(rules, "drop.go", false));
+        assert!(!ignored(rules, "keep.go", false));
+    }
+
+    #[test]
+    fn test_hidden_directories_are_opt_in() {
+        let dir = tempfile::TempDir::new().unwrap();
+        fs::create_dir(dir.path().join(".cache")).unwrap();
+        fs::write(dir.path().join(".cache").join("a.py"), "").unwrap();
+        fs::write(dir.path().join("b.py"), "").unwrap();
+
+        assert_eq!(
+            Discovery::new().files(dir.path()),
+            [dir.path().join("b.py")]
+        );
+        assert_eq!(
+            Discovery::new().with_hidden(true).files(dir.path()),
+            [
+                dir.path().join(".cache").join("a.py"),
+                dir.path().join("b.py")
+            ]
+        );
+    }
+
+    #[test]
+    fn test_generated_marker() {
+        assert!(is_generated(
+            "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api\n"
+        ));
+        assert!(is_generated(
+            "// Copyright 2024\n\n// Code generated by stringer; DO NOT EDIT.\npackage x\n"
+        ));
+        assert!(!is_generated(
+            "package x\n\n// Code generated by hand. DO NOT EDIT.\n"
+        ));
+        assert!(!is_generated("// Code generated by hand.\npackage x\n"));
+    }
+}
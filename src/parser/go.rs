@@ -1,11 +1,38 @@
-use crate::core::{CodeGraph, Edge, EdgeType, Node, NodeType, Parameter};
+use super::discovery::Discovery;
+use super::go_build::{self, BuildContext};
+use super::go_module::{self, GoModule};
+use super::span;
+use crate::core::{
+    CodeGraph, Diagnostic, Edge, EdgeKind, EdgeType, FieldAccessKind, Import, MethodSets, Node,
+    NodeType, Parameter, ReferenceKind, Selection,
+};
+use crate::serializer::file_cache::{self, CacheStats, CachedFile, FileCache};
 use anyhow::{Context, Result};
+use std::collections::{BTreeMap, BTreeSet, HashMap, HashSet};
 use std::fs;
-use std::path::Path;
+use std::path::{Path, PathBuf};
 use tree_sitter::Parser;
 
 pub struct GoParser {
     parser: Parser,
+    build: BuildContext,
+    all_platforms: bool,
+    discovery: Discovery,
+    include_vendor: bool,
+    include_tests: bool,
+    low_memory: bool,
+    /// Imports of the file being parsed
+    imports: Vec<GoImport>,
+}
+
+/// One import spec: `import alias "path"`, where the alias may be `.` or `_`
+#[derive(Debug, Clone)]
+struct GoImport {
+    path: String,
+    alias: Option<String>,
+    /// 1-based position of the spec
+    line: usize,
+    column: usize,
 }
 
 impl GoParser {
@@ -14,78 +41,408 @@ impl GoParser {
         parser
             .set_language(&tree_sitter_go::LANGUAGE.into())
             .context("Failed to set Go language")?;
-        Ok(Self { parser })
+        Ok(Self {
+            parser,
+            build: BuildContext::host(),
+            all_platforms: false,
+            discovery: Discovery::new(),
+            include_vendor: false,
+            include_tests: false,
+            low_memory: false,
+            imports: Vec::new(),
+        })
+    }
+
+    /// Select files by build constraints for `build` instead of the host platform
+    pub fn with_build_context(mut self, build: BuildContext) -> Self {
+        self.build = build;
+        self
+    }
+
+    /// Index files for every platform instead of only those `build` selects. Their
+    /// symbols carry the file's constraint in the `build` metadata key, and a symbol
+    /// defined once per platform isn't reported as a duplicate.
+    pub fn with_all_platforms(mut self, all_platforms: bool) -> Self {
+        self.all_platforms = all_platforms;
+        self
+    }
+
+    /// Choose files by `discovery`'s ignore rules instead of the defaults
+    pub fn with_discovery(mut self, discovery: Discovery) -> Self {
+        self.discovery = discovery;
+        self
+    }
+
+    /// Also index packages under `vendor/` directories
+    pub fn with_vendor(mut self, include_vendor: bool) -> Self {
+        self.include_vendor = include_vendor;
+        self
+    }
+
+    /// Also index `_test.go` files; their symbols are marked with the `test` metadata key
+    pub fn with_tests(mut self, include_tests: bool) -> Self {
+        self.include_tests = include_tests;
+        self
+    }
+
+    /// Parse and merge files [`LOW_MEMORY_BATCH`] at a time, so only one batch's
+    /// per-file graphs are held at once
+    pub fn with_low_memory(mut self, low_memory: bool) -> Self {
+        self.low_memory = low_memory;
+        self
+    }
+
+    pub fn build_context(&self) -> &BuildContext {
+        &self.build
     }
 
     pub fn parse_directory(&mut self, dir: &Path, graph: &mut CodeGraph) -> Result<()> {
+        self.parse_directory_cached(dir, graph, None)?;
+        Ok(())
+    }
+
+    /// Parse every Go file under `dir`, reusing results from `cache` for files whose
+    /// content hash is unchanged. The cache is refreshed with this run's results and
+    /// pruned of deleted files; cached files are still re-resolved against the rest.
+    pub fn parse_directory_cached(
+        &mut self,
+        dir: &Path,
+        graph: &mut CodeGraph,
+        mut cache: Option<&mut FileCache>,
+    ) -> Result<CacheStats> {
         use rayon::prelude::*;
 
-        // Phase 3: Parallel file discovery with jwalk
-        let file_paths: Vec<_> = jwalk::WalkDir::new(dir)
-            .into_iter()
-            .filter_map(|e| e.ok())
-            .filter(|e| {
-                e.path().extension().and_then(|s| s.to_str()) == Some("go")
-                    && !e.path().to_string_lossy().contains("_test.go")
+        let entries = self.discovery.files(dir);
+
+        // Directories with their own go.mod are separate modules, as with `go build ./...`
+        let nested_modules: Vec<&Path> = entries
+            .iter()
+            .filter(|path| path.file_name().is_some_and(|name| name == "go.mod"))
+            .filter_map(|path| path.parent())
+            .filter(|module_dir| *module_dir != dir)
+            .collect();
+
+        // Discovery returns paths sorted, so results merge in path order whatever the
+        // thread scheduling
+        let file_paths: Vec<PathBuf> = entries
+            .iter()
+            .filter(|path| {
+                path.extension().and_then(|s| s.to_str()) == Some("go")
+                    && (self.include_tests || !is_test_file(path))
+                    && !go_module::in_ignored_dir(dir, path, self.include_vendor)
+                    && !nested_modules
+                        .iter()
+                        .any(|module_dir| path.starts_with(module_dir))
             })
-            .map(|e| e.path())
+            .cloned()
             .collect();
 
         let dir_str = dir.to_string_lossy().to_string();
+        let build = (!self.all_platforms).then_some(&self.build);
+        let discovery = &self.discovery;
 
-        // Phase 3: Batched parallel processing for better CPU utilization
-        let chunk_size = 100.min(file_paths.len().max(1));
-        let results: Vec<CodeGraph> = file_paths
-            .par_chunks(chunk_size)
-            .map(|chunk| {
-                let mut chunk_graph = CodeGraph::new_with_capacity(
-                    dir_str.clone(),
-                    "go".to_string(),
-                    chunk.len() * 20, // Estimate ~20 nodes per file
-                    chunk.len() * 80, // Estimate ~80 edges per file
-                );
-
-                for path in chunk {
-                    let mut parser = match Self::new() {
-                        Ok(p) => p,
-                        Err(_) => continue,
+        let mut stats = CacheStats::default();
+        let mut present = HashSet::new();
+        // By default every file is parsed before any is merged
+        let batch_size = match self.low_memory {
+            true => LOW_MEMORY_BATCH,
+            false => file_paths.len().max(1),
+        };
+        for batch in file_paths.chunks(batch_size) {
+            let cached = cache.as_deref();
+            // Each file parses on its own; failures come back as diagnostics, in path order
+            let results: Vec<Result<FileResult, Diagnostic>> = batch
+                .par_iter()
+                .filter_map(|path| {
+                    let content = match fs::read(path).context("Failed to read file") {
+                        Ok(content) => content,
+                        Err(e) => return Some(Err(super::file_error(super::READ_ERROR, path, &e))),
                     };
+                    let source = String::from_utf8_lossy(&content);
+                    if !discovery.accepts_source(&source) {
+                        return None;
+                    }
+                    let key = path.to_string_lossy().to_string();
+                    let content_hash = file_cache::content_hash(&content);
+                    let mut file_graph = CodeGraph::new(dir_str.clone(), "go".to_string());
 
-                    if let Err(e) = parser.parse_file(path, &mut chunk_graph) {
-                        eprintln!("Warning: Failed to parse {}: {}", path.display(), e);
+                    // Parsed only to learn what it declares, so calls to it can be reported
+                    // as excluded rather than undefined
+                    let excluded = build.is_some_and(|build| !build.matches_file(path, &source));
+                    if excluded {
+                        let _ = Self::new().and_then(|mut parser| {
+                            parser.parse_source(path, &source, &mut file_graph)
+                        });
+                        return Some(Ok(FileResult {
+                            key,
+                            content_hash,
+                            graph: file_graph,
+                            cache_hit: false,
+                            excluded,
+                        }));
                     }
-                }
 
-                chunk_graph
-            })
-            .collect();
+                    if let Some(entry) = cached.and_then(|c| c.get(&key, &content_hash)) {
+                        for node in &entry.nodes {
+                            file_graph.add_node(node.clone());
+                        }
+                        for edge in &entry.edges {
+                            file_graph.add_edge(edge.clone());
+                        }
+                        for reference in &entry.references {
+                            file_graph.add_reference(reference.clone());
+                        }
+                        file_graph.imports = entry.imports.clone();
+                        file_graph.metadata.diagnostics = entry.diagnostics.clone();
+                        return Some(Ok(FileResult {
+                            key,
+                            content_hash,
+                            graph: file_graph,
+                            cache_hit: true,
+                            excluded,
+                        }));
+                    }
+
+                    let parsed = Self::new()
+                        .and_then(|mut parser| parser.parse_source(path, &source, &mut file_graph));
+                    if let Err(e) = parsed {
+                        file_graph.metadata.diagnostics.push(super::file_error(
+                            super::PARSE_ERROR,
+                            path,
+                            &e,
+                        ));
+                    }
+                    Some(Ok(FileResult {
+                        key,
+                        content_hash,
+                        graph: file_graph,
+                        cache_hit: false,
+                        excluded,
+                    }))
+                })
+                .collect();
+
+            for result in results {
+                let result = match result {
+                    Ok(result) => result,
+                    Err(diagnostic) => {
+                        graph.metadata.diagnostics.push(diagnostic);
+                        continue;
+                    }
+                };
+                if result.excluded {
+                    let symbols = symbol_keys(&result.graph.nodes);
+                    if !symbols.is_empty() {
+                        graph.metadata.build_excluded.insert(result.key, symbols);
+                    }
+                    continue;
+                }
+                if result.cache_hit {
+                    stats.hits += 1;
+                } else {
+                    stats.misses += 1;
+                    if let Some(cache) = cache.as_deref_mut() {
+                        cache.insert(
+                            result.key.clone(),
+                            CachedFile {
+                                content_hash: result.content_hash.clone(),
+                                nodes: result.graph.nodes.clone(),
+                                edges: result.graph.edges.clone(),
+                                references: result.graph.references.clone(),
+                                imports: result.graph.imports.clone(),
+                                diagnostics: result.graph.metadata.diagnostics.clone(),
+                            },
+                        );
+                    }
+                }
+                graph
+                    .metadata
+                    .file_hashes
+                    .insert(result.key.clone(), result.content_hash);
+                present.insert(result.key);
+                graph.merge(result.graph);
+            }
+        }
+        let cached = cache.is_some();
+        if let Some(cache) = cache {
+            cache.retain_files(&present);
+        }
 
-        // Merge all chunk results - uses incremental index updates
-        let files_parsed = file_paths.len();
-        for chunk_graph in results {
-            graph.merge(chunk_graph);
+        // Import paths are derived from go.mod rather than file contents, so they are
+        // assigned after merging and never stored in the cache
+        if let Some(module) = GoModule::find(dir) {
+            assign_import_paths(&mut graph.nodes, &module);
+            assign_importers(&mut graph.imports, &module);
         }
+        // IDs take in the import path, and calls resolve to them
+        graph.assign_stable_ids();
+
+        // Calls can only be resolved once every file's definitions are known
+        Self::resolve_calls(graph);
 
-        graph.metadata.stats.files_parsed = files_parsed;
+        graph.metadata.stats.files_parsed = present.len();
         graph.metadata.stats.total_nodes = graph.nodes.len();
         graph.metadata.stats.total_edges = graph.edges.len();
-        Ok(())
+        graph.metadata.stats.cache = cached.then_some(stats);
+        Ok(stats)
     }
 
     pub fn parse_file(&mut self, file_path: &Path, graph: &mut CodeGraph) -> Result<()> {
         let source = fs::read_to_string(file_path)
             .context(format!("Failed to read file: {}", file_path.display()))?;
+        self.parse_file_source(file_path, &source, graph)
+    }
+
+    /// Parse `file_path` under `dir` if [`parse_directory`](Self::parse_directory) would,
+    /// for an incremental update. Returns false for a file its filters leave out; one the
+    /// build constraints exclude has its symbols recorded in `build_excluded` instead.
+    pub fn parse_changed_file(
+        &mut self,
+        dir: &Path,
+        file_path: &Path,
+        graph: &mut CodeGraph,
+    ) -> Result<bool> {
+        let in_nested_module = file_path
+            .ancestors()
+            .skip(1)
+            .take_while(|ancestor| *ancestor != dir && ancestor.starts_with(dir))
+            .any(|ancestor| ancestor.join("go.mod").exists());
+        if (!self.include_tests && is_test_file(file_path))
+            || go_module::in_ignored_dir(dir, file_path, self.include_vendor)
+            || in_nested_module
+        {
+            return Ok(false);
+        }
+        let source = fs::read_to_string(file_path)
+            .context(format!("Failed to read file: {}", file_path.display()))?;
+        if !self.discovery.accepts_source(&source) {
+            return Ok(false);
+        }
+        if !self.all_platforms && !self.build.matches_file(file_path, &source) {
+            let mut file_graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".into());
+            let _ = self.parse_source(file_path, &source, &mut file_graph);
+            let symbols = symbol_keys(&file_graph.nodes);
+            if !symbols.is_empty() {
+                let key = file_path.to_string_lossy().to_string();
+                graph.metadata.build_excluded.insert(key, symbols);
+            }
+            return Ok(false);
+        }
+        self.parse_file_source(file_path, &source, graph)?;
+        Ok(true)
+    }
+
+    /// Parse `source` as the contents of `file_path`, e.g. an editor buffer not yet saved
+    pub fn parse_file_source(
+        &mut self,
+        file_path: &Path,
+        source: &str,
+        graph: &mut CodeGraph,
+    ) -> Result<()> {
+        let first_new = graph.nodes.len();
+        let first_import = graph.imports.len();
+        self.parse_source(file_path, source, graph)?;
+        graph.metadata.file_hashes.insert(
+            file_path.to_string_lossy().to_string(),
+            file_cache::content_hash(source.as_bytes()),
+        );
+
+        let module = file_path.parent().and_then(GoModule::find);
+        if let Some(module) = module {
+            assign_import_paths(&mut graph.nodes[first_new..], &module);
+            assign_importers(&mut graph.imports[first_import..], &module);
+        }
+        graph.assign_package_stable_ids(file_path.parent().unwrap_or(Path::new("")));
+        Ok(())
+    }
+
+    /// Index `source` on its own as the contents of `file_path`, which need not exist,
+    /// e.g. [`STDIN_PATH`](super::STDIN_PATH) for a snippet piped in. There is no package
+    /// or module around it, so calls into the package's other files are left
+    /// unresolved, as calls into unindexed packages are.
+    pub fn index_standalone(&mut self, file_path: &Path, source: &str) -> CodeGraph {
+        let root = file_path.parent().unwrap_or(Path::new(""));
+        let mut graph = CodeGraph::new(root.to_string_lossy().to_string(), "go".to_string());
+        if let Err(e) = self.parse_source(file_path, source, &mut graph) {
+            graph
+                .metadata
+                .diagnostics
+                .push(super::file_error(super::PARSE_ERROR, file_path, &e));
+        }
+        graph.assign_stable_ids();
+        Self::resolve_calls(&mut graph);
+
+        graph.metadata.stats.files_parsed = 1;
+        graph.metadata.stats.total_nodes = graph.nodes.len();
+        graph.metadata.stats.total_edges = graph.edges.len();
+        graph
+    }
 
+    fn parse_source(
+        &mut self,
+        file_path: &Path,
+        source: &str,
+        graph: &mut CodeGraph,
+    ) -> Result<()> {
         let tree = self
             .parser
-            .parse(&source, None)
+            .parse(source, None)
             .context("Failed to parse Go file")?;
 
         let root = tree.root_node();
-        let package_name = self.extract_package(root, &source);
+        let package_name = self.extract_package(root, source);
+        self.imports = self.extract_imports(root, source);
+        graph
+            .imports
+            .extend(self.imports.iter().map(|import| Import {
+                path: import.path.clone(),
+                alias: import.alias.clone(),
+                file_path: file_path.to_path_buf(),
+                line: import.line,
+                column: import.column,
+                importer: None,
+                package: package_name.clone(),
+                internal: false,
+            }));
+
+        // Walk the tree to extract functions and methods. Tree-sitter recovers from syntax
+        // errors, so declarations around a broken one are still found; the errors are
+        // reported rather than failing the file.
+        let first_new = graph.nodes.len();
+        self.walk_tree(root, source, file_path, &package_name, graph)?;
+        graph
+            .metadata
+            .diagnostics
+            .extend(super::syntax_errors(root, source, file_path));
 
-        // Walk the tree to extract functions and methods
-        self.walk_tree(root, &source, file_path, &package_name, graph)?;
+        // Function literals sit inside bodies, where a comment above one isn't its doc
+        let comments = own_line_comments(root, source);
+        for node in &mut graph.nodes[first_new..] {
+            if node.metadata.contains_key("enclosing") {
+                continue;
+            }
+            let group = comment_group(&comments, node.line.saturating_sub(1));
+            let doc = doc_comment(&group, source);
+            if !doc.is_empty() {
+                node.documentation = Some(doc);
+            }
+            // The declaration's span takes in its comments, directives included
+            if let (Some(first), Some(declaration)) = (group.first(), node.span.as_mut()) {
+                declaration.start = span(*first).start;
+            }
+        }
+        if is_test_file(file_path) {
+            for node in &mut graph.nodes[first_new..] {
+                node.metadata.insert("test".to_string(), "true".to_string());
+            }
+        }
+        // Queries can pick a platform out of an index of all of them
+        if let Some(constraint) = go_build::file_constraint(file_path, source) {
+            for node in &mut graph.nodes[first_new..] {
+                node.metadata
+                    .insert("build".to_string(), constraint.clone());
+            }
+        }
 
         Ok(())
     }
@@ -105,6 +462,56 @@ impl GoParser {
         "unknown".to_string()
     }
 
+    fn extract_imports(&self, root: tree_sitter::Node, source: &str) -> Vec<GoImport> {
+        let mut specs = Vec::new();
+        let mut cursor = root.walk();
+        for decl in root.children(&mut cursor) {
+            if decl.kind() != "import_declaration" {
+                continue;
+            }
+            let mut decl_cursor = decl.walk();
+            for child in decl.named_children(&mut decl_cursor) {
+                match child.kind() {
+                    "import_spec" => specs.push(child),
+                    "import_spec_list" => {
+                        let mut list_cursor = child.walk();
+                        specs.extend(
+                            child
+                                .named_children(&mut list_cursor)
+                                .filter(|n| n.kind() == "import_spec"),
+                        );
+                    }
+                    _ => {}
+                }
+            }
+        }
+
+        specs
+            .into_iter()
+            .filter_map(|spec| {
+                let path = spec.child_by_field_name("path")?;
+                Some(GoImport {
+                    path: source[path.byte_range()]
+                        .trim_matches(|c| c == '"' || c == '`')
+                        .to_string(),
+                    alias: spec
+                        .child_by_field_name("name")
+                        .map(|name| source[name.byte_range()].to_string()),
+                    line: spec.start_position().row + 1,
+                    column: spec.start_position().column + 1,
+                })
+            })
+            .collect()
+    }
+
+    /// The import a `qualifier.Name` selector refers to in the current file
+    fn import_for(&self, qualifier: &str) -> Option<&GoImport> {
+        self.imports.iter().find(|import| match &import.alias {
+            Some(alias) => alias == qualifier,
+            None => default_package_name(&import.path) == qualifier,
+        })
+    }
+
     fn walk_tree(
         &self,
         node: tree_sitter::Node,
@@ -117,6 +524,16 @@ impl GoParser {
             self.extract_function(node, source, file_path, package_name, graph)?;
         } else if node.kind() == "method_declaration" {
             self.extract_method(node, source, file_path, package_name, graph)?;
+        } else if node.parent().is_some_and(is_top_level) {
+            match node.kind() {
+                "type_declaration" => {
+                    self.extract_types(node, source, file_path, package_name, graph)
+                }
+                "const_declaration" | "var_declaration" => {
+                    self.extract_values(node, source, file_path, package_name, graph)
+                }
+                _ => {}
+            }
         }
 
         // Recurse into children
@@ -136,21 +553,15 @@ impl GoParser {
         package_name: &str,
         graph: &mut CodeGraph,
     ) -> Result<()> {
-        let mut func_name = String::new();
-        let mut parameters = Vec::new();
-
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            match child.kind() {
-                "identifier" if func_name.is_empty() => {
-                    func_name = source[child.byte_range()].to_string();
-                }
-                "parameter_list" => {
-                    parameters = self.extract_parameters(child, source);
-                }
-                _ => {}
-            }
-        }
+        let func_name = node
+            .child_by_field_name("name")
+            .map(|n| source[n.byte_range()].to_string())
+            .unwrap_or_default();
+        // A parenthesized result list is a parameter_list too
+        let parameters = node
+            .child_by_field_name("parameters")
+            .map(|n| self.extract_parameters(n, source))
+            .unwrap_or_default();
 
         if !func_name.is_empty() {
             let line = node.start_position().row + 1;
@@ -165,18 +576,39 @@ impl GoParser {
             let mut node_obj = Node::new(
                 id,
                 func_name.clone(),
-                NodeType::Function,
+                test_function_kind(file_path, &func_name, &parameters),
                 file_path.to_path_buf(),
                 line,
                 end_line,
                 package_name.to_string(),
                 signature,
             );
+            let mut scope = self.local_scope(node, source, &parameters);
+            scope.bind_parameters(&parameters, &node_obj.id);
             node_obj.parameters = parameters;
+            node_obj.returns = self.extract_results(node, source);
+            node_obj.column = node
+                .child_by_field_name("name")
+                .unwrap_or(node)
+                .start_position()
+                .column
+                + 1;
+            node_obj.name_span = node.child_by_field_name("name").map(span);
+            node_obj.span = Some(span(node));
+            node_obj.metadata.insert(
+                "complexity".to_string(),
+                cyclomatic_complexity(node).to_string(),
+            );
+            node_obj.metadata.insert(
+                "body_lines".to_string(),
+                body_lines(node, source).to_string(),
+            );
             graph.add_node(node_obj);
 
             // Extract calls within this function
-            self.extract_calls_in_node(node, source, file_path, &func_name, line, graph)?;
+            self.extract_calls_in_node(
+                node, source, file_path, &func_name, line, &mut scope, graph,
+            )?;
         }
 
         Ok(())
@@ -190,22 +622,17 @@ impl GoParser {
         package_name: &str,
         graph: &mut CodeGraph,
     ) -> Result<()> {
-        let mut method_name = String::new();
-        let mut parameters = Vec::new();
-
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            match child.kind() {
-                "field_identifier" if method_name.is_empty() => {
-                    method_name = source[child.byte_range()].to_string();
-                }
-                "parameter_list" if !parameters.is_empty() => {
-                    // Second parameter_list is the method parameters (first is receiver)
-                    parameters = self.extract_parameters(child, source);
-                }
-                _ => {}
-            }
-        }
+        let method_name = node
+            .child_by_field_name("name")
+            .map(|n| source[n.byte_range()].to_string())
+            .unwrap_or_default();
+        let parameters = node
+            .child_by_field_name("parameters")
+            .map(|n| self.extract_parameters(n, source))
+            .unwrap_or_default();
+        let receiver = node
+            .child_by_field_name("receiver")
+            .and_then(|n| self.extract_receiver(n, source));
 
         if !method_name.is_empty() {
             let line = node.start_position().row + 1;
@@ -215,11 +642,19 @@ impl GoParser {
                 .next()
                 .unwrap_or("")
                 .to_string();
-            let id = format!("{}:{}:{}", file_path.display(), method_name, line);
+
+            // Methods are named after their receiver, go tool style:
+            // (*Calculator).Add for pointer receivers, Calculator.Add for value receivers
+            let qualified_name = match &receiver {
+                Some(recv) if recv.pointer => format!("(*{}).{}", recv.type_name, method_name),
+                Some(recv) => format!("{}.{}", recv.type_name, method_name),
+                None => method_name.clone(),
+            };
+            let id = format!("{}:{}:{}", file_path.display(), qualified_name, line);
 
             let mut node_obj = Node::new(
                 id,
-                method_name.clone(),
+                qualified_name.clone(),
                 NodeType::Method,
                 file_path.to_path_buf(),
                 line,
@@ -227,122 +662,2430 @@ impl GoParser {
                 package_name.to_string(),
                 signature,
             );
-            node_obj.parameters = parameters;
+            node_obj.parameters = parameters.clone();
+            node_obj.returns = self.extract_results(node, source);
+            node_obj
+                .metadata
+                .insert("method".to_string(), method_name.clone());
+
+            // Local variable types visible in the body, used to resolve c.Method() calls
+            let mut scope = self.local_scope(node, source, &parameters);
+            scope.bind_parameters(&parameters, &node_obj.id);
+            if let Some(recv) = &receiver {
+                node_obj.metadata.insert(
+                    "receiver".to_string(),
+                    if recv.pointer {
+                        format!("*{}", recv.type_name)
+                    } else {
+                        recv.type_name.clone()
+                    },
+                );
+                node_obj
+                    .metadata
+                    .insert("receiver_type".to_string(), recv.type_name.clone());
+                if let Some(var) = &recv.var_name {
+                    scope.types.insert(var.clone(), recv.type_name.clone());
+                }
+            }
+            node_obj.column = node
+                .child_by_field_name("name")
+                .unwrap_or(node)
+                .start_position()
+                .column
+                + 1;
+            node_obj.name_span = node.child_by_field_name("name").map(span);
+            node_obj.span = Some(span(node));
+            node_obj.metadata.insert(
+                "complexity".to_string(),
+                cyclomatic_complexity(node).to_string(),
+            );
+            node_obj.metadata.insert(
+                "body_lines".to_string(),
+                body_lines(node, source).to_string(),
+            );
             graph.add_node(node_obj);
 
             // Extract calls within this method
-            self.extract_calls_in_node(node, source, file_path, &method_name, line, graph)?;
+            self.extract_calls_in_node(
+                node,
+                source,
+                file_path,
+                &qualified_name,
+                line,
+                &mut scope,
+                graph,
+            )?;
         }
 
         Ok(())
     }
 
-    fn extract_parameters(&self, node: tree_sitter::Node, source: &str) -> Vec<Parameter> {
-        let mut parameters = Vec::new();
+    /// Index the specs of a top-level `type` declaration. Structs and interfaces record
+    /// what they embed under `embeds`, each named struct field becomes a field node
+    /// named `Struct.field`, and each method an interface declares becomes an abstract
+    /// method node named `Interface.Method`. Aliases (`type A = B`) are marked `alias`.
+    fn extract_types(
+        &self,
+        node: tree_sitter::Node,
+        source: &str,
+        file_path: &Path,
+        package_name: &str,
+        graph: &mut CodeGraph,
+    ) {
         let mut cursor = node.walk();
+        for spec in node
+            .named_children(&mut cursor)
+            .filter(|n| matches!(n.kind(), "type_spec" | "type_alias"))
+        {
+            let (Some(name_node), Some(type_node)) = (
+                spec.child_by_field_name("name"),
+                spec.child_by_field_name("type"),
+            ) else {
+                continue;
+            };
+            let type_name = source[name_node.byte_range()].to_string();
+            let line = spec.start_position().row + 1;
+            let signature = format!(
+                "type {}",
+                source[spec.byte_range()]
+                    .lines()
+                    .next()
+                    .unwrap_or("")
+                    .trim_end()
+            );
+            let node_type = match type_node.kind() {
+                "struct_type" => NodeType::Struct,
+                "interface_type" => NodeType::Interface,
+                _ => NodeType::Type,
+            };
 
-        for child in node.children(&mut cursor) {
-            if child.kind() == "parameter_declaration" {
-                let mut name = String::new();
-                let mut param_type = String::new();
-
-                let mut param_cursor = child.walk();
-                for param_child in child.children(&mut param_cursor) {
-                    match param_child.kind() {
-                        "identifier" if name.is_empty() => {
-                            name = source[param_child.byte_range()].to_string();
-                        }
-                        "type_identifier" | "pointer_type" | "slice_type" | "array_type"
-                        | "map_type" | "interface_type" | "qualified_type" => {
-                            param_type = source[param_child.byte_range()].to_string();
+            let mut type_obj = Node::new(
+                format!("{}:{}:{}", file_path.display(), type_name, line),
+                type_name.clone(),
+                node_type,
+                file_path.to_path_buf(),
+                line,
+                spec.end_position().row + 1,
+                package_name.to_string(),
+                signature,
+            );
+            type_obj.column = name_node.start_position().column + 1;
+            type_obj.name_span = Some(span(name_node));
+            type_obj.span = Some(span(declaration_of(spec)));
+            if spec.kind() == "type_alias" {
+                type_obj
+                    .metadata
+                    .insert("alias".to_string(), "true".to_string());
+            }
+
+            let mut embeds = Vec::new();
+            let mut methods = Vec::new();
+            match type_node.kind() {
+                "struct_type" => embeds = self.struct_embeds(type_node, source),
+                "interface_type" => {
+                    let mut elem_cursor = type_node.walk();
+                    for elem in type_node.named_children(&mut elem_cursor) {
+                        match elem.kind() {
+                            "method_elem" => methods.push(elem),
+                            "type_elem" => {
+                                let mut type_cursor = elem.walk();
+                                let types: Vec<tree_sitter::Node> =
+                                    elem.named_children(&mut type_cursor).collect();
+                                match types.as_slice() {
+                                    [embedded]
+                                        if matches!(
+                                            embedded.kind(),
+                                            "type_identifier" | "qualified_type"
+                                        ) =>
+                                    {
+                                        embeds.push(self.type_reference(*embedded, source))
+                                    }
+                                    // Unions and ~T only appear in constraints, which no
+                                    // value can have as its type
+                                    _ => {
+                                        type_obj
+                                            .metadata
+                                            .insert("constraint".to_string(), "true".to_string());
+                                    }
+                                }
+                            }
+                            _ => {}
                         }
-                        _ => {}
                     }
                 }
+                _ => {}
+            }
+            if !embeds.is_empty() {
+                type_obj
+                    .metadata
+                    .insert("embeds".to_string(), embeds.join(","));
+            }
+            graph.add_node(type_obj);
 
-                if !name.is_empty() || !param_type.is_empty() {
-                    parameters.push(Parameter {
-                        name: if name.is_empty() {
-                            "_".to_string()
-                        } else {
-                            name
-                        },
-                        param_type,
-                    });
-                }
+            if type_node.kind() == "struct_type" {
+                self.extract_fields(
+                    type_node,
+                    source,
+                    file_path,
+                    package_name,
+                    &type_name,
+                    graph,
+                );
+            }
+            for elem in methods {
+                self.extract_interface_method(
+                    elem,
+                    source,
+                    file_path,
+                    package_name,
+                    &type_name,
+                    graph,
+                );
             }
         }
-
-        parameters
     }
 
-    fn extract_calls_in_node(
+    /// One field node per name a struct declares, so `a, b int` gives `T.a` and `T.b`.
+    /// Embedded fields are recorded on the struct under `embeds` instead.
+    fn extract_fields(
         &self,
         node: tree_sitter::Node,
         source: &str,
         file_path: &Path,
-        func_name: &str,
-        func_line: usize,
+        package_name: &str,
+        struct_name: &str,
         graph: &mut CodeGraph,
-    ) -> Result<()> {
-        self.find_calls(node, source, file_path, func_name, func_line, graph);
-        Ok(())
+    ) {
+        let mut cursor = node.walk();
+        for list in node
+            .named_children(&mut cursor)
+            .filter(|n| n.kind() == "field_declaration_list")
+        {
+            let mut field_cursor = list.walk();
+            for field in list
+                .named_children(&mut field_cursor)
+                .filter(|n| n.kind() == "field_declaration")
+            {
+                let field_type = field
+                    .child_by_field_name("type")
+                    .map(|t| source[t.byte_range()].to_string());
+                let signature = source[field.byte_range()]
+                    .lines()
+                    .next()
+                    .unwrap_or("")
+                    .trim_end()
+                    .to_string();
+
+                let mut name_cursor = field.walk();
+                for name_node in field.children_by_field_name("name", &mut name_cursor) {
+                    let field_name = source[name_node.byte_range()].to_string();
+                    let name = format!("{}.{}", struct_name, field_name);
+                    let line = name_node.start_position().row + 1;
+
+                    let mut field_obj = Node::new(
+                        format!("{}:{}:{}", file_path.display(), name, line),
+                        name,
+                        NodeType::Field,
+                        file_path.to_path_buf(),
+                        line,
+                        field.end_position().row + 1,
+                        package_name.to_string(),
+                        signature.clone(),
+                    );
+                    field_obj.column = name_node.start_position().column + 1;
+                    field_obj.name_span = Some(span(name_node));
+                    field_obj.span = Some(span(field));
+                    field_obj
+                        .metadata
+                        .insert("struct".to_string(), struct_name.to_string());
+                    field_obj.metadata.insert("field".to_string(), field_name);
+                    if let Some(field_type) = &field_type {
+                        field_obj
+                            .metadata
+                            .insert("declared_type".to_string(), field_type.clone());
+                    }
+                    graph.add_node(field_obj);
+                }
+            }
+        }
     }
 
-    fn find_calls(
+    /// Index a top-level `const` or `var` declaration with one node per declared name,
+    /// so `const ( A = 1; B = 2 )` gives A and B at their own positions. Blank names
+    /// (`var _ Logger = ...`) declare nothing and are skipped.
+    fn extract_values(
         &self,
         node: tree_sitter::Node,
         source: &str,
         file_path: &Path,
-        func_name: &str,
-        func_line: usize,
+        package_name: &str,
         graph: &mut CodeGraph,
     ) {
-        if node.kind() == "call_expression" {
-            let mut called_func = String::new();
-            let mut cursor = node.walk();
+        let (keyword, node_type, spec_kind) = match node.kind() {
+            "const_declaration" => ("const", NodeType::Const, "const_spec"),
+            _ => ("var", NodeType::Var, "var_spec"),
+        };
 
-            for child in node.children(&mut cursor) {
+        // Grouped var specs may be wrapped in a var_spec_list
+        let mut specs = Vec::new();
+        let mut pending = vec![node];
+        while let Some(current) = pending.pop() {
+            let mut cursor = current.walk();
+            for child in current.named_children(&mut cursor) {
                 match child.kind() {
-                    "identifier" => {
-                        called_func = source[child.byte_range()].to_string();
-                    }
-                    "selector_expression" => {
-                        // For method calls like obj.Method()
-                        let mut sel_cursor = child.walk();
-                        for sel_child in child.children(&mut sel_cursor) {
-                            if sel_child.kind() == "field_identifier" {
-                                called_func = source[sel_child.byte_range()].to_string();
-                            }
-                        }
-                    }
+                    kind if kind == spec_kind => specs.push(child),
+                    "var_spec_list" => pending.push(child),
                     _ => {}
                 }
             }
+        }
+        specs.sort_by_key(|spec| spec.start_byte());
 
-            if !called_func.is_empty() {
-                let line = node.start_position().row + 1;
-                let call_site = source[node.byte_range()].to_string();
-                let from_id = format!("{}:{}:{}", file_path.display(), func_name, func_line);
+        for spec in specs {
+            let signature = format!(
+                "{} {}",
+                keyword,
+                source[spec.byte_range()]
+                    .lines()
+                    .next()
+                    .unwrap_or("")
+                    .trim_end()
+            );
+            let declared_type = spec
+                .child_by_field_name("type")
+                .map(|t| source[t.byte_range()].to_string());
 
-                if graph.get_node_by_id(&from_id).is_some() {
-                    let edge = Edge::new(
-                        from_id,
-                        called_func,
-                        EdgeType::Calls,
-                        call_site,
-                        file_path.to_path_buf(),
-                        line,
-                    );
-                    graph.add_edge(edge);
+            let mut cursor = spec.walk();
+            for name_node in spec.children_by_field_name("name", &mut cursor) {
+                let name = source[name_node.byte_range()].to_string();
+                if name == "_" {
+                    continue;
+                }
+                let line = name_node.start_position().row + 1;
+
+                let mut value = Node::new(
+                    format!("{}:{}:{}", file_path.display(), name, line),
+                    name,
+                    node_type.clone(),
+                    file_path.to_path_buf(),
+                    line,
+                    spec.end_position().row + 1,
+                    package_name.to_string(),
+                    signature.clone(),
+                );
+                value.column = name_node.start_position().column + 1;
+                value.name_span = Some(span(name_node));
+                value.span = Some(span(declaration_of(spec)));
+                if let Some(declared_type) = &declared_type {
+                    value
+                        .metadata
+                        .insert("declared_type".to_string(), declared_type.clone());
+                }
+                graph.add_node(value);
+            }
+        }
+    }
+
+    /// Embedded fields of a struct type, written `T` or `*T`
+    fn struct_embeds(&self, node: tree_sitter::Node, source: &str) -> Vec<String> {
+        let mut embeds = Vec::new();
+        let mut cursor = node.walk();
+        for list in node
+            .named_children(&mut cursor)
+            .filter(|n| n.kind() == "field_declaration_list")
+        {
+            let mut field_cursor = list.walk();
+            for field in list
+                .named_children(&mut field_cursor)
+                .filter(|n| n.kind() == "field_declaration")
+            {
+                if field.child_by_field_name("name").is_some() {
+                    continue;
                 }
+                let Some(type_node) = field.child_by_field_name("type") else {
+                    continue;
+                };
+                let mut token_cursor = field.walk();
+                let pointer = field.children(&mut token_cursor).any(|c| c.kind() == "*");
+                let embedded = self.type_reference(type_node, source);
+                embeds.push(if pointer {
+                    format!("*{}", embedded)
+                } else {
+                    embedded
+                });
             }
         }
+        embeds
+    }
 
-        // Recurse into children
+    /// A named type as it can be looked up later: `T` for the current package, or the
+    /// import path and name (`example.com/mymod/calc.T`) for `pkg.T`. Type arguments
+    /// are dropped.
+    fn type_reference(&self, node: tree_sitter::Node, source: &str) -> String {
+        match node.kind() {
+            "generic_type" => node
+                .child_by_field_name("type")
+                .map(|base| self.type_reference(base, source))
+                .unwrap_or_default(),
+            "qualified_type" => {
+                let package = node
+                    .child_by_field_name("package")
+                    .map(|n| &source[n.byte_range()]);
+                let name = node
+                    .child_by_field_name("name")
+                    .map(|n| &source[n.byte_range()]);
+                match (package.and_then(|p| self.import_for(p)), name) {
+                    (Some(import), Some(name)) => format!("{}.{}", import.path, name),
+                    _ => source[node.byte_range()].to_string(),
+                }
+            }
+            _ => source[node.byte_range()].to_string(),
+        }
+    }
+
+    fn extract_interface_method(
+        &self,
+        node: tree_sitter::Node,
+        source: &str,
+        file_path: &Path,
+        package_name: &str,
+        interface_name: &str,
+        graph: &mut CodeGraph,
+    ) {
+        let Some(name_node) = node.child_by_field_name("name") else {
+            return;
+        };
+        let method_name = source[name_node.byte_range()].to_string();
+        let name = format!("{}.{}", interface_name, method_name);
+        let line = node.start_position().row + 1;
+
+        let mut method = Node::new(
+            format!("{}:{}:{}", file_path.display(), name, line),
+            name,
+            NodeType::Method,
+            file_path.to_path_buf(),
+            line,
+            node.end_position().row + 1,
+            package_name.to_string(),
+            source[node.byte_range()]
+                .lines()
+                .next()
+                .unwrap_or("")
+                .trim_end()
+                .to_string(),
+        );
+        method.parameters = node
+            .child_by_field_name("parameters")
+            .map(|n| self.extract_parameters(n, source))
+            .unwrap_or_default();
+        method.returns = self.extract_results(node, source);
+        method.column = name_node.start_position().column + 1;
+        method.name_span = Some(span(name_node));
+        method.span = Some(span(node));
+        method.metadata.insert("method".to_string(), method_name);
+        for key in ["receiver", "receiver_type"] {
+            method
+                .metadata
+                .insert(key.to_string(), interface_name.to_string());
+        }
+        method
+            .metadata
+            .insert("abstract".to_string(), "true".to_string());
+        graph.add_node(method);
+    }
+
+    /// Result types of a function, method or interface method, one per value returned
+    fn extract_results(&self, node: tree_sitter::Node, source: &str) -> Vec<String> {
+        match node.child_by_field_name("result") {
+            Some(result) if result.kind() == "parameter_list" => self
+                .extract_parameters(result, source)
+                .into_iter()
+                .map(|p| p.param_type)
+                .collect(),
+            Some(result) => vec![source[result.byte_range()].to_string()],
+            None => Vec::new(),
+        }
+    }
+
+    /// Extract the receiver variable and base type from a method receiver list
+    fn extract_receiver(&self, node: tree_sitter::Node, source: &str) -> Option<Receiver> {
+        let mut cursor = node.walk();
+        let param = node
+            .children(&mut cursor)
+            .find(|c| c.kind() == "parameter_declaration")?;
+
+        let var_name = param
+            .child_by_field_name("name")
+            .map(|n| source[n.byte_range()].to_string());
+        let type_node = param.child_by_field_name("type")?;
+        let (type_name, pointer) = base_type_name(type_node, source);
+
+        if type_name.is_empty() {
+            return None;
+        }
+
+        Some(Receiver {
+            var_name,
+            type_name,
+            pointer,
+        })
+    }
+
+    /// Build the scope of a function: every local it declares, with parameter types
+    /// as the initial variable -> type map
+    fn local_scope(
+        &self,
+        node: tree_sitter::Node,
+        source: &str,
+        parameters: &[Parameter],
+    ) -> LocalScope {
+        let type_parameters = type_parameters(node, source);
+        let mut scope = LocalScope {
+            names: local_names(node, source),
+            ..Default::default()
+        };
+        for param in parameters {
+            let type_name = param.param_type.trim_start_matches('*');
+            // Stack[int] is a Stack; a map is no named type at all
+            let type_name = match type_name.split_once('[') {
+                Some((base, _)) if type_name.ends_with(']') && base != "map" => base,
+                _ => type_name,
+            };
+            if !type_name.is_empty()
+                && !type_parameters.contains(type_name)
+                && type_name
+                    .chars()
+                    .all(|c| c.is_alphanumeric() || c == '_' || c == '.')
+            {
+                scope
+                    .types
+                    .insert(param.name.clone(), type_name.to_string());
+            }
+        }
+        // `T(x)` converts to a type parameter rather than calling a package-level T
+        scope.names.extend(type_parameters);
+        scope
+    }
+
+    fn extract_parameters(&self, node: tree_sitter::Node, source: &str) -> Vec<Parameter> {
+        let mut parameters = Vec::new();
         let mut cursor = node.walk();
+
         for child in node.children(&mut cursor) {
-            self.find_calls(child, source, file_path, func_name, func_line, graph);
+            if child.kind() == "parameter_declaration"
+                || child.kind() == "variadic_parameter_declaration"
+            {
+                let param_type = child
+                    .child_by_field_name("type")
+                    .map(|t| source[t.byte_range()].to_string())
+                    .unwrap_or_default();
+
+                // `a, b int` declares one parameter per name
+                let mut name_cursor = child.walk();
+                let names: Vec<String> = child
+                    .children_by_field_name("name", &mut name_cursor)
+                    .map(|n| source[n.byte_range()].to_string())
+                    .collect();
+
+                if names.is_empty() {
+                    if !param_type.is_empty() {
+                        parameters.push(Parameter {
+                            name: "_".to_string(),
+                            param_type,
+                        });
+                    }
+                } else {
+                    for name in names {
+                        parameters.push(Parameter {
+                            name,
+                            param_type: param_type.clone(),
+                        });
+                    }
+                }
+            }
+        }
+
+        parameters
+    }
+
+    #[allow(clippy::too_many_arguments)]
+    fn extract_calls_in_node(
+        &self,
+        node: tree_sitter::Node,
+        source: &str,
+        file_path: &Path,
+        func_name: &str,
+        func_line: usize,
+        scope: &mut LocalScope,
+        graph: &mut CodeGraph,
+    ) -> Result<()> {
+        self.find_calls(node, source, file_path, func_name, func_line, scope, graph);
+        Ok(())
+    }
+
+    #[allow(clippy::too_many_arguments)]
+    fn find_calls(
+        &self,
+        node: tree_sitter::Node,
+        source: &str,
+        file_path: &Path,
+        func_name: &str,
+        func_line: usize,
+        scope: &mut LocalScope,
+        graph: &mut CodeGraph,
+    ) {
+        match node.kind() {
+            "short_var_declaration" | "var_spec" => {
+                self.track_local_types(node, source, &mut scope.types);
+                self.track_function_values(node, source, scope);
+            }
+            "assignment_statement" => self.track_function_values(node, source, scope),
+            "identifier" | "selector_expression" => {
+                if let Some((target, kind, mut metadata)) =
+                    self.value_reference(node, source, scope)
+                {
+                    metadata.extend(stored_in(node, source, scope));
+                    let from_id = format!("{}:{}:{}", file_path.display(), func_name, func_line);
+                    add_reference(
+                        node, source, file_path, from_id, target, kind, metadata, graph,
+                    );
+                }
+            }
+            "composite_literal" => {
+                // Calculator{name: n} sets the field Calculator.name
+                let from_id = format!("{}:{}:{}", file_path.display(), func_name, func_line);
+                for (key, field, metadata) in field_initializers(node, source) {
+                    add_reference(
+                        key,
+                        source,
+                        file_path,
+                        from_id.clone(),
+                        field,
+                        ReferenceKind::Write,
+                        metadata,
+                        graph,
+                    );
+                }
+            }
+            "func_literal" => {
+                self.extract_closure(node, source, file_path, func_name, func_line, scope, graph);
+                return;
+            }
+            "call_expression" => {
+                let mut called_func = String::new();
+                let mut receiver_type = None;
+                let mut qualifier = None;
+                let mut via = None;
+                let mut parameter = None;
+                let mut local = None;
+                let mut binding = None;
+
+                if let Some(function) = node
+                    .child_by_field_name("function")
+                    .map(|function| generic_callee(function, source, scope))
+                {
+                    match function.kind() {
+                        "identifier" | "type_identifier" => {
+                            called_func = source[function.byte_range()].to_string();
+                            // op() where op holds a named function calls that function,
+                            // and f() where f holds c.Add calls the method
+                            if let Some(bound) = scope.functions.get(&called_func) {
+                                let name = std::mem::take(&mut called_func);
+                                local = Some(name.clone());
+                                match bound.clone() {
+                                    FunctionValue::Function(function) => called_func = function,
+                                    FunctionValue::Method {
+                                        receiver_type: bound_type,
+                                        method,
+                                        kind,
+                                    } => {
+                                        called_func = method;
+                                        receiver_type = Some(bound_type);
+                                        binding = Some(kind);
+                                    }
+                                }
+                                via = Some(name);
+                            } else if let Some(bound) = scope.parameters.get(&called_func) {
+                                // f() on a parameter calls whatever callers pass in
+                                parameter = Some(bound.clone());
+                            } else if scope.names.contains(&called_func) {
+                                // f() on a local holding a literal, or nothing known here
+                                local = Some(called_func.clone());
+                            }
+                        }
+                        "selector_expression" | "qualified_type" => {
+                            // For method calls like obj.Method(), and pkg.Map[int]()
+                            let (operand, field) = match function.kind() {
+                                "qualified_type" => ("package", "name"),
+                                _ => ("operand", "field"),
+                            };
+                            if let Some(field) = function.child_by_field_name(field) {
+                                called_func = source[field.byte_range()].to_string();
+                            }
+                            if let Some(operand) = function.child_by_field_name(operand) {
+                                let operand_text = source[operand.byte_range()].to_string();
+                                if operand.kind() == "identifier" {
+                                    receiver_type = scope.types.get(&operand_text).cloned();
+                                }
+                                if receiver_type.is_none() {
+                                    qualifier = Some(operand_text);
+                                }
+                            }
+                        }
+                        _ => {}
+                    }
+                }
+
+                if !called_func.is_empty() {
+                    let line = node.start_position().row + 1;
+                    let call_site = source[node.byte_range()].to_string();
+                    let from_id = format!("{}:{}:{}", file_path.display(), func_name, func_line);
+
+                    if graph.get_node_by_id(&from_id).is_some() {
+                        let mut edge = Edge::new(
+                            from_id,
+                            called_func.clone(),
+                            EdgeType::Calls,
+                            call_site,
+                            file_path.to_path_buf(),
+                            line,
+                        );
+                        // Receiver calls are resolved to the concrete method once all files are parsed
+                        if let Some(receiver_type) = receiver_type {
+                            edge.metadata
+                                .insert("receiver_type".to_string(), receiver_type);
+                            edge.metadata.insert("method".to_string(), called_func);
+                        } else if let Some(qualifier) = qualifier {
+                            // pkg.Func() or a call on a value of unknown type
+                            if let Some(import) = self.import_for(&qualifier) {
+                                edge.metadata
+                                    .insert("import_path".to_string(), import.path.clone());
+                            }
+                            edge.metadata.insert("qualifier".to_string(), qualifier);
+                        } else if let Some((index, function_id)) = parameter {
+                            edge.metadata
+                                .insert("parameter".to_string(), index.to_string());
+                            edge.metadata
+                                .insert("parameter_of".to_string(), function_id);
+                        } else {
+                            // Bare calls may name a function from a dot-imported package
+                            let dot_imports: Vec<&str> = self
+                                .imports
+                                .iter()
+                                .filter(|import| import.alias.as_deref() == Some("."))
+                                .map(|import| import.path.as_str())
+                                .collect();
+                            if !dot_imports.is_empty() {
+                                edge.metadata
+                                    .insert("dot_imports".to_string(), dot_imports.join(","));
+                            }
+                        }
+                        if let Some(via) = via {
+                            edge.metadata.insert("via".to_string(), via);
+                        }
+                        // Whatever else the local is assigned gets an indirect edge
+                        if let Some(local) = local {
+                            edge.metadata.insert("local".to_string(), local);
+                        }
+                        if let Some(binding) = binding {
+                            edge.metadata
+                                .insert("indirect".to_string(), "true".to_string());
+                            edge.metadata
+                                .insert("binding".to_string(), binding.to_string());
+                        }
+                        set_call_kind(&mut edge, node);
+                        edge.column = node.start_position().column + 1;
+                        graph.add_edge(edge);
+                    }
+                }
+            }
+            _ => {}
+        }
+
+        // Recurse into children
+        let mut cursor = node.walk();
+        for child in node.children(&mut cursor) {
+            self.find_calls(child, source, file_path, func_name, func_line, scope, graph);
+        }
+    }
+
+    /// Index a function literal as a function of its own, named go tool style:
+    /// `Greet.func1` for the first literal in Greet and `Greet.func1.1` for one nested
+    /// inside it. The enclosing function references the literal, and calls it when it is
+    /// invoked where it is written (`defer func() { ... }()`); calls in its body belong
+    /// to the literal. The body sees the enclosing function's locals.
+    #[allow(clippy::too_many_arguments)]
+    fn extract_closure(
+        &self,
+        node: tree_sitter::Node,
+        source: &str,
+        file_path: &Path,
+        func_name: &str,
+        func_line: usize,
+        scope: &mut LocalScope,
+        graph: &mut CodeGraph,
+    ) {
+        let from_id = format!("{}:{}:{}", file_path.display(), func_name, func_line);
+        let Some(package_name) = graph.get_node_by_id(&from_id).map(|n| n.package.clone()) else {
+            return;
+        };
+
+        scope.closures += 1;
+        let name = if scope.in_closure {
+            format!("{}.{}", func_name, scope.closures)
+        } else {
+            format!("{}.func{}", func_name, scope.closures)
+        };
+        let line = node.start_position().row + 1;
+        let signature = source[node.byte_range()]
+            .lines()
+            .next()
+            .unwrap_or("")
+            .to_string();
+        let parameters = node
+            .child_by_field_name("parameters")
+            .map(|n| self.extract_parameters(n, source))
+            .unwrap_or_default();
+
+        let mut closure = Node::new(
+            format!("{}:{}:{}", file_path.display(), name, line),
+            name.clone(),
+            NodeType::Function,
+            file_path.to_path_buf(),
+            line,
+            node.end_position().row + 1,
+            package_name,
+            signature,
+        );
+        closure.column = node.start_position().column + 1;
+        // Literals have no name; the `func` keyword stands in for one
+        closure.name_span = node.child(0).map(span);
+        closure.span = Some(span(node));
+        closure.returns = self.extract_results(node, source);
+        closure
+            .metadata
+            .insert("enclosing".to_string(), func_name.to_string());
+        closure.metadata.insert(
+            "complexity".to_string(),
+            cyclomatic_complexity(node).to_string(),
+        );
+        let mut own = self.local_scope(node, source, &parameters);
+        own.bind_parameters(&parameters, &closure.id);
+        closure.parameters = parameters;
+        graph.add_node(closure);
+
+        add_reference(
+            node,
+            source,
+            file_path,
+            from_id.clone(),
+            name.clone(),
+            ReferenceKind::Closure,
+            stored_in(node, source, scope).into_iter().collect(),
+            graph,
+        );
+        if let Some(call) = node.parent().filter(|parent| {
+            parent.kind() == "call_expression"
+                && parent.child_by_field_name("function") == Some(node)
+        }) {
+            let mut edge = Edge::new(
+                from_id,
+                name.clone(),
+                EdgeType::Calls,
+                source[call.byte_range()].to_string(),
+                file_path.to_path_buf(),
+                call.start_position().row + 1,
+            );
+            set_call_kind(&mut edge, call);
+            edge.column = call.start_position().column + 1;
+            graph.add_edge(edge);
+        }
+
+        let mut inner = LocalScope {
+            closures: 0,
+            in_closure: true,
+            ..scope.clone()
+        };
+        inner.types.extend(own.types);
+        inner.parameters.extend(own.parameters);
+        if let Some(body) = node.child_by_field_name("body") {
+            self.find_calls(body, source, file_path, &name, line, &mut inner, graph);
+        }
+    }
+
+    /// Record the static type of locals declared as `x := &T{}`, `x := T{}` or `var x *T`
+    fn track_local_types(
+        &self,
+        node: tree_sitter::Node,
+        source: &str,
+        scope: &mut HashMap<String, String>,
+    ) {
+        let names: Vec<String> = match node.kind() {
+            "short_var_declaration" => node
+                .child_by_field_name("left")
+                .map(|left| {
+                    let mut cursor = left.walk();
+                    left.named_children(&mut cursor)
+                        .filter(|n| n.kind() == "identifier")
+                        .map(|n| source[n.byte_range()].to_string())
+                        .collect()
+                })
+                .unwrap_or_default(),
+            _ => {
+                let mut cursor = node.walk();
+                node.children_by_field_name("name", &mut cursor)
+                    .map(|n| source[n.byte_range()].to_string())
+                    .collect()
+            }
+        };
+
+        // An explicit type applies to every declared name
+        if let Some(type_node) = node.child_by_field_name("type") {
+            let (type_name, _) = base_type_name(type_node, source);
+            if !type_name.is_empty() {
+                for name in &names {
+                    scope.insert(name.clone(), type_name.clone());
+                }
+            }
+            return;
+        }
+
+        let value_field = if node.kind() == "short_var_declaration" {
+            "right"
+        } else {
+            "value"
+        };
+        let values: Vec<tree_sitter::Node> = node
+            .child_by_field_name(value_field)
+            .map(|right| {
+                let mut cursor = right.walk();
+                right.named_children(&mut cursor).collect()
+            })
+            .unwrap_or_default();
+
+        for (name, value) in names.iter().zip(values) {
+            if let Some(type_name) = composite_literal_type(value, source) {
+                scope.insert(name.clone(), type_name);
+            }
+        }
+    }
+
+    /// Follow `op := Add` and `op = Add` so a later `op()` is known to call Add, and
+    /// likewise `f := c.Add` and `g := (*Calculator).Subtract` for methods.
+    /// Assigning anything else to the name forgets the function it held.
+    fn track_function_values(&self, node: tree_sitter::Node, source: &str, scope: &mut LocalScope) {
+        let (left, right) = match node.kind() {
+            "var_spec" => ("name", "value"),
+            _ => ("left", "right"),
+        };
+        let mut cursor = node.walk();
+        let names: Vec<tree_sitter::Node> = if left == "name" {
+            node.children_by_field_name("name", &mut cursor).collect()
+        } else {
+            node.child_by_field_name(left)
+                .map(|list| list.named_children(&mut cursor).collect())
+                .unwrap_or_default()
+        };
+        let mut cursor = node.walk();
+        let values: Vec<tree_sitter::Node> = node
+            .child_by_field_name(right)
+            .map(|list| list.named_children(&mut cursor).collect())
+            .unwrap_or_default();
+
+        for (i, name) in names.iter().enumerate() {
+            if name.kind() != "identifier" {
+                continue;
+            }
+            let name = source[name.byte_range()].to_string();
+            let function = values
+                .get(i)
+                .and_then(|&value| self.function_value(value, source, scope));
+            match function {
+                Some(function) => {
+                    scope.functions.insert(name, function);
+                }
+                None => {
+                    scope.functions.remove(&name);
+                }
+            }
+        }
+    }
+
+    /// The function an expression stored in a local names: a package-level function
+    /// (`Add`), a method value on a local of known type (`c.Add`) or a method expression
+    /// (`(*Calculator).Subtract`)
+    fn function_value(
+        &self,
+        value: tree_sitter::Node,
+        source: &str,
+        scope: &LocalScope,
+    ) -> Option<FunctionValue> {
+        if value.kind() == "identifier" {
+            let name = &source[value.byte_range()];
+            return self
+                .is_package_symbol(name, scope)
+                .then(|| FunctionValue::Function(name.to_string()));
+        }
+        if value.kind() != "selector_expression" {
+            return None;
+        }
+
+        let operand = value.child_by_field_name("operand")?;
+        let method = source[value.child_by_field_name("field")?.byte_range()].to_string();
+        let operand_text = match operand.kind() {
+            "identifier" => source[operand.byte_range()].to_string(),
+            "parenthesized_expression" => method_expression_type(operand, source)?,
+            _ => return None,
+        };
+        let (receiver_type, kind) = if scope.names.contains(&operand_text) {
+            (scope.types.get(&operand_text)?.clone(), METHOD_VALUE)
+        } else if self.import_for(&operand_text).is_none() {
+            (operand_text, METHOD_EXPRESSION)
+        } else {
+            return None;
+        };
+        Some(FunctionValue::Method {
+            receiver_type,
+            method,
+            kind,
+        })
+    }
+
+    /// Whether a bare identifier inside a function names something declared at package
+    /// level rather than a local, an import or a predeclared identifier
+    fn is_package_symbol(&self, name: &str, scope: &LocalScope) -> bool {
+        !scope.names.contains(name)
+            && !PREDECLARED.contains(&name)
+            && self.import_for(name).is_none()
+    }
+
+    /// A use of a package-level symbol other than calling it: `f := PrintMessage`,
+    /// `run(Add)`, `return handler`, or a method value such as `c.Add` or `(*Calculator).Add`.
+    /// Returns the referenced name, how it is used and the metadata resolution needs.
+    fn value_reference(
+        &self,
+        node: tree_sitter::Node,
+        source: &str,
+        scope: &LocalScope,
+    ) -> Option<(String, ReferenceKind, HashMap<String, String>)> {
+        let mut metadata = HashMap::new();
+
+        if node.kind() == "identifier" {
+            let kind = value_use(node)?;
+            let name = &source[node.byte_range()];
+            return self
+                .is_package_symbol(name, scope)
+                .then(|| (name.to_string(), kind, metadata));
+        }
+
+        let operand = node.child_by_field_name("operand")?;
+        let field = source[node.child_by_field_name("field")?.byte_range()].to_string();
+        let operand_text = &source[operand.byte_range()];
+        let local = scope.names.contains(operand_text);
+        if operand.kind() == "identifier" && !local {
+            if let Some(import) = self.import_for(operand_text) {
+                let kind = value_use(node)?;
+                metadata.insert("import_path".to_string(), import.path.clone());
+                metadata.insert("qualifier".to_string(), operand_text.to_string());
+                return Some((field, kind, metadata));
+            }
+        }
+
+        // c.Add on a local of known type is a method value; Calculator.Name and
+        // (*Calculator).Add are method expressions. (*c).name dereferences a local.
+        let receiver_type = match operand.kind() {
+            "identifier" if local => scope.types.get(operand_text).cloned(),
+            "identifier" => Some(operand_text.to_string()),
+            "parenthesized_expression" => {
+                let inner = method_expression_type(operand, source)?;
+                match scope.names.contains(&inner) {
+                    true => scope.types.get(&inner).cloned(),
+                    false => Some(inner),
+                }
+            }
+            _ => None,
+        }?;
+        let (kind, access) = selector_access(node)?;
+        metadata.insert("receiver_type".to_string(), receiver_type);
+        metadata.insert("method".to_string(), field.clone());
+        // c.name could also access a struct field; resolution picks the kind once it
+        // knows which of the two the name is
+        metadata.insert("use".to_string(), kind.as_str().to_string());
+        metadata.insert("access".to_string(), access.as_str().to_string());
+        Some((field, ReferenceKind::MethodValue, metadata))
+    }
+
+    /// Narrow a graph indexed for all platforms down to the files `build` compiles, and
+    /// resolve the calls that were ambiguous between per-platform definitions
+    pub fn select_platform(graph: &mut CodeGraph, build: &BuildContext) {
+        let excluded: BTreeSet<String> = graph
+            .nodes
+            .iter()
+            .filter(|node| {
+                node.metadata
+                    .get("build")
+                    .is_some_and(|constraint| !build.eval(constraint))
+            })
+            .map(|node| node.file_path.to_string_lossy().to_string())
+            .collect();
+        if excluded.is_empty() {
+            return;
+        }
+        for file in &excluded {
+            let nodes: Vec<Node> = graph
+                .nodes
+                .iter()
+                .filter(|node| node.file_path.to_string_lossy() == file.as_str())
+                .cloned()
+                .collect();
+            graph.remove_nodes_from_file(file);
+            let symbols = symbol_keys(&nodes);
+            if !symbols.is_empty() {
+                graph.metadata.build_excluded.insert(file.clone(), symbols);
+            }
+        }
+        Self::resolve_calls(graph);
+        graph.metadata.stats.files_parsed = graph
+            .metadata
+            .stats
+            .files_parsed
+            .saturating_sub(excluded.len());
+        graph.metadata.stats.total_nodes = graph.nodes.len();
+        graph.metadata.stats.total_edges = graph.edges.len();
+    }
+
+    /// Resolve calls against a package-level symbol table built from every parsed file,
+    /// so a call in one file links to the definition in another file of the same package.
+    /// Receiver calls (c.LogOperation()) are pointed at the concrete method on the
+    /// receiver's type. Symbols defined more than once in a package are reported as
+    /// diagnostics and calls to them are left unresolved.
+    pub fn resolve_calls(graph: &mut CodeGraph) {
+        Self::resolve_calls_in(graph, None);
+    }
+
+    /// Re-resolve the calls made from files in `package_dir`, plus every call made through
+    /// an import. Other calls resolve within their own package, so a change to one file
+    /// can't affect them.
+    pub fn resolve_package_calls(graph: &mut CodeGraph, package_dir: &Path) {
+        Self::resolve_calls_in(graph, Some(package_dir));
+    }
+
+    fn resolve_calls_in(graph: &mut CodeGraph, scope: Option<&Path>) {
+        let table = SymbolTable::build(&graph.nodes);
+
+        graph.metadata.diagnostics.retain(|d| {
+            d.code != DUPLICATE_SYMBOL
+                && !(d.code == AMBIGUOUS_SELECTOR
+                    && scope.is_none_or(|scope| d.file_path.parent() == Some(scope)))
+        });
+        graph
+            .metadata
+            .diagnostics
+            .extend(table.duplicates(&graph.nodes));
+
+        let packages = PackageDirs::new(&graph.nodes);
+        let method_sets = MethodSets::new(&graph.nodes);
+        let mut ambiguous = Vec::new();
+        // References to functions and methods resolve exactly like calls to them
+        for edge in graph.edges.iter_mut().chain(graph.references.iter_mut()) {
+            let Some(package_dir) = edge.file_path.parent() else {
+                continue;
+            };
+            // Other languages resolve their own calls
+            if !is_go_file(&edge.file_path) {
+                continue;
+            }
+            // Calls through an import depend on another package, so they are always revisited
+            let via_import = edge.metadata.contains_key("import_path")
+                || edge.metadata.contains_key("dot_imports");
+            if scope.is_some_and(|scope| scope != package_dir) && !via_import {
+                continue;
+            }
+            // Incremental updates re-resolve edges; drop bindings to stale definitions
+            edge.metadata.remove("target_id");
+            edge.metadata.remove("promoted_via");
+            if !matches!(edge.edge_type, EdgeType::Calls | EdgeType::References) {
+                continue;
+            }
+
+            let mut promoted_via = None;
+            let package = table.package_of(&edge.file_path);
+            let target = if let Some(import_path) = edge.metadata.get("import_path") {
+                // pkg.Func() reaches only exported names, and only in indexed packages
+                packages
+                    .get(import_path, package_dir)
+                    .filter(|_| is_exported(&edge.to))
+                    .and_then(|dir| table.imported(dir))
+                    .and_then(|package| table.unique(package, &edge.to))
+                    .map(|&idx| &graph.nodes[idx])
+            } else if edge.metadata.contains_key("qualifier")
+                || (edge.edge_type == EdgeType::Calls && edge.calls_through_value())
+            {
+                // A call on a value of unknown type, or through a parameter or local; the
+                // functions passed or assigned to it get indirect edges of their own
+                None
+            } else {
+                let selector = match (
+                    edge.metadata.get("receiver_type"),
+                    edge.metadata.get("method"),
+                ) {
+                    (Some(receiver_type), Some(method)) => Some((receiver_type, method)),
+                    _ => None,
+                };
+                let key = match selector {
+                    Some((receiver_type, method)) => format!("{}.{}", receiver_type, method),
+                    None => edge.to.clone(),
+                };
+                let declared = package
+                    .and_then(|package| table.unique(package, &key))
+                    .or_else(|| {
+                        let dot_imports = edge.metadata.get("dot_imports")?;
+                        dot_imports
+                            .split(',')
+                            .filter_map(|import_path| packages.get(import_path, package_dir))
+                            .filter(|_| is_exported(&key))
+                            .filter_map(|dir| table.imported(dir))
+                            .find_map(|package| table.unique(package, &key))
+                    })
+                    .map(|&idx| &graph.nodes[idx]);
+
+                // A method the receiver's type doesn't declare may be promoted from a
+                // field it embeds
+                match selector {
+                    Some((receiver_type, method))
+                        if declared.is_none()
+                            && !package.is_some_and(|package| table.contains(package, &key)) =>
+                    {
+                        match method_sets.select(package_dir, receiver_type, method) {
+                            Some(Selection::Method(entry)) => {
+                                promoted_via = Some(entry.via.join(","));
+                                Some(entry.method)
+                            }
+                            Some(Selection::Ambiguous(entries)) => {
+                                let fields: Vec<String> =
+                                    entries.iter().map(|entry| entry.via.join(".")).collect();
+                                ambiguous.push(Diagnostic::warning(
+                                    AMBIGUOUS_SELECTOR,
+                                    format!(
+                                        "ambiguous selector {}: promoted from both {}",
+                                        key,
+                                        fields.join(" and ")
+                                    ),
+                                    edge.file_path.clone(),
+                                    edge.line,
+                                ));
+                                None
+                            }
+                            None => None,
+                        }
+                    }
+                    _ => declared,
+                }
+            };
+
+            if let Some(via) = promoted_via.filter(|via| !via.is_empty()) {
+                edge.metadata.insert("promoted_via".to_string(), via);
+            }
+            if let Some(target) = target {
+                edge.to = target.name.clone();
+                edge.metadata
+                    .insert("target_id".to_string(), target.id.clone());
+                if let Some(use_kind) = edge.metadata.get("use").cloned() {
+                    let kind = match target.node_type {
+                        NodeType::Field => use_kind,
+                        _ => ReferenceKind::MethodValue.as_str().to_string(),
+                    };
+                    edge.metadata.insert("reference".to_string(), kind);
+                }
+            }
+        }
+
+        graph.metadata.diagnostics.extend(ambiguous);
+        link_indirect_calls(graph);
+        link_dynamic_calls(graph);
+        graph.build_indexes();
+    }
+}
+
+impl super::LanguageParser for GoParser {
+    fn language(&self) -> &'static str {
+        "go"
+    }
+
+    fn parse_directory(&mut self, dir: &Path, graph: &mut CodeGraph) -> Result<()> {
+        GoParser::parse_directory(self, dir, graph)
+    }
+
+    fn parse_source(
+        &mut self,
+        file_path: &Path,
+        source: &str,
+        graph: &mut CodeGraph,
+    ) -> Result<()> {
+        self.parse_file_source(file_path, source, graph)
+    }
+
+    fn index_standalone(&mut self, file_path: &Path, source: &str) -> CodeGraph {
+        GoParser::index_standalone(self, file_path, source)
+    }
+}
+
+/// Give every call through a parameter an indirect edge to each function passed for
+/// it: with `apply(Add, 1, 2)` and `apply(Sub, 1, 2)`, `f(a, b)` in apply may call Add
+/// or Sub. Named functions, method values and literals passed straight to a resolved
+/// call are followed; a parameter handed on to another function is not. Calls through
+/// a local (`op()`) or a struct field (`t.fn()`) get an edge to each function the
+/// function assigns to that local, or anything in the package stores in that field,
+/// beside the one the parser followed.
+fn link_indirect_calls(graph: &mut CodeGraph) {
+    // Calls through a local holding a method are indirect too, but come from the parser
+    graph
+        .edges
+        .retain(|edge| !edge.is_indirect() || edge.metadata.contains_key("binding"));
+
+    let nodes: HashMap<&str, &Node> = graph
+        .nodes
+        .iter()
+        .map(|node| (node.id.as_str(), node))
+        .collect();
+    // The definition each call expression was resolved to, by position
+    let callees: HashMap<(&Path, usize, usize), &str> = graph
+        .edges
+        .iter()
+        .filter(|edge| edge.edge_type == EdgeType::Calls && !edge.is_dynamic())
+        .filter_map(|edge| {
+            let target_id = edge.metadata.get("target_id")?;
+            Some((
+                (edge.file_path.as_path(), edge.line, edge.column),
+                target_id.as_str(),
+            ))
+        })
+        .collect();
+
+    // (function ID, parameter position) -> IDs of the functions passed there
+    let mut passed: HashMap<(&str, &str), Vec<&str>> = HashMap::new();
+    // (function ID, local) and (package directory, `T.field`) -> functions stored there
+    let mut locals: HashMap<(&str, &str), Vec<&str>> = HashMap::new();
+    let mut fields: HashMap<(&Path, String), Vec<&str>> = HashMap::new();
+    fn add<'a>(targets: &mut Vec<&'a str>, target_id: &'a str) {
+        if !targets.contains(&target_id) {
+            targets.push(target_id);
+        }
+    }
+    for reference in &graph.references {
+        let Some(target_id) = reference.metadata.get("target_id") else {
+            continue;
+        };
+        if let Some(local) = reference.metadata.get("stored_in_local") {
+            add(
+                locals.entry((&reference.from, local)).or_default(),
+                target_id,
+            );
+        }
+        if let (Some(field), Some(dir)) = (
+            reference.metadata.get("stored_in_field"),
+            reference.file_path.parent(),
+        ) {
+            add(fields.entry((dir, field.clone())).or_default(), target_id);
+        }
+        let (Some(index), Some(call)) = (
+            reference.metadata.get("argument"),
+            reference.metadata.get("call"),
+        ) else {
+            continue;
+        };
+        let Some((line, column)) = call
+            .split_once(':')
+            .and_then(|(line, column)| Some((line.parse().ok()?, column.parse().ok()?)))
+        else {
+            continue;
+        };
+        if let Some(&callee) = callees.get(&(reference.file_path.as_path(), line, column)) {
+            add(
+                passed.entry((callee, index.as_str())).or_default(),
+                target_id,
+            );
+        }
+    }
+
+    let mut indirect = Vec::new();
+    for edge in &graph.edges {
+        if edge.edge_type != EdgeType::Calls || edge.is_dynamic() {
+            continue;
+        }
+        let (candidates, via) = if let (Some(index), Some(function_id)) = (
+            edge.metadata.get("parameter"),
+            edge.metadata.get("parameter_of"),
+        ) {
+            (
+                passed.get(&(function_id.as_str(), index.as_str())),
+                edge.to.clone(),
+            )
+        } else if let Some(local) = edge.metadata.get("local") {
+            (
+                locals.get(&(edge.from.as_str(), local.as_str())),
+                local.clone(),
+            )
+        } else if let (Some(receiver_type), Some(method), Some(dir)) = (
+            edge.metadata.get("receiver_type"),
+            edge.metadata.get("method"),
+            edge.file_path.parent(),
+        ) {
+            let field = format!("{}.{}", receiver_type, method);
+            (fields.get(&(dir, field.clone())), field)
+        } else {
+            continue;
+        };
+        // The function the parser already followed keeps its own edge
+        let followed = edge.metadata.get("target_id");
+        for target in candidates
+            .into_iter()
+            .flatten()
+            .filter(|&&id| followed.map(String::as_str) != Some(id))
+            .filter_map(|id| nodes.get(id))
+        {
+            let mut call = Edge::new(
+                edge.from.clone(),
+                target.name.clone(),
+                EdgeType::Calls,
+                edge.call_site.clone(),
+                edge.file_path.clone(),
+                edge.line,
+            );
+            call.column = edge.column;
+            call.metadata
+                .insert("target_id".to_string(), target.id.clone());
+            call.metadata
+                .insert("indirect".to_string(), "true".to_string());
+            call.metadata.insert("via".to_string(), via.clone());
+            if let Some(kind) = edge.metadata.get("kind") {
+                call.metadata.insert("kind".to_string(), kind.clone());
+            }
+            indirect.push(call);
+        }
+    }
+    graph.edges.extend(indirect);
+}
+
+/// Give every call of an interface method a dynamic edge to the method satisfying it
+/// on each indexed type implementing the interface: with `func report(s Shape)` calling
+/// `s.Area()`, report may call Square.Area and Circle.Area. The call itself keeps
+/// pointing at Shape.Area, and types outside the index are never candidates.
+fn link_dynamic_calls(graph: &mut CodeGraph) {
+    graph.edges.retain(|edge| !edge.is_dynamic());
+
+    let nodes: HashMap<&str, &Node> = graph
+        .nodes
+        .iter()
+        .map(|node| (node.id.as_str(), node))
+        .collect();
+    let method_sets = MethodSets::new(&graph.nodes);
+    let mut targets: HashMap<&str, Vec<&Node>> = HashMap::new();
+
+    let mut dynamic = Vec::new();
+    for edge in &graph.edges {
+        if edge.edge_type != EdgeType::Calls || edge.is_indirect() {
+            continue;
+        }
+        let Some(&method) = edge
+            .metadata
+            .get("target_id")
+            .and_then(|id| nodes.get(id.as_str()))
+        else {
+            continue;
+        };
+        if !method.metadata.contains_key("abstract") {
+            continue;
+        }
+
+        let candidates = targets
+            .entry(method.id.as_str())
+            .or_insert_with(|| method_sets.dispatch_targets(method));
+        for target in candidates.iter() {
+            let mut call = Edge::new(
+                edge.from.clone(),
+                target.name.clone(),
+                EdgeType::Calls,
+                edge.call_site.clone(),
+                edge.file_path.clone(),
+                edge.line,
+            );
+            call.column = edge.column;
+            call.metadata
+                .insert("target_id".to_string(), target.id.clone());
+            call.metadata
+                .insert("dynamic".to_string(), "true".to_string());
+            call.metadata.insert("via".to_string(), edge.to.clone());
+            if let Some(kind) = edge.metadata.get("kind") {
+                call.metadata.insert("kind".to_string(), kind.clone());
+            }
+            dynamic.push(call);
+        }
+    }
+    graph.edges.extend(dynamic);
+}
+
+const DUPLICATE_SYMBOL: &str = "duplicate-symbol";
+const AMBIGUOUS_SELECTOR: &str = "ambiguous-selector";
+
+/// The name a Go symbol is looked up by when resolving calls: `Name`, or `Type.Method`
+/// for a method. `None` for symbols calls never resolve to by name.
+fn symbol_key(node: &Node) -> Option<String> {
+    if !is_go_file(&node.file_path) {
+        return None;
+    }
+    match node.node_type {
+        NodeType::Method => match (
+            node.metadata.get("receiver_type"),
+            node.metadata.get("method"),
+        ) {
+            (Some(receiver_type), Some(method)) => Some(format!("{}.{}", receiver_type, method)),
+            _ => None,
+        },
+        // init and blank functions may legally appear any number of times, and
+        // so may the literals inside init functions (`init.func1`)
+        _ if node.name == "init" || node.name == "_" => None,
+        _ if node
+            .metadata
+            .get("enclosing")
+            .is_some_and(|e| e == "init" || e.starts_with("init.")) =>
+        {
+            None
+        }
+        _ => Some(node.name.clone()),
+    }
+}
+
+/// The distinct [`symbol_key`]s of `nodes`, sorted
+fn symbol_keys(nodes: &[Node]) -> Vec<String> {
+    let keys: BTreeSet<String> = nodes.iter().filter_map(symbol_key).collect();
+    keys.into_iter().collect()
+}
+
+/// A Go package: the files of one directory with the same package clause, so an
+/// external `foo_test` package stays apart from the `foo` package it tests
+type Package<'a> = (&'a Path, &'a str);
+
+/// Top-level symbols of each package, keyed by package directory, package name and
+/// symbol name. Methods are keyed as `Type.Method` regardless of pointer or value
+/// receiver, and fields as `Struct.field`, since a type can't have a field and a method
+/// of the same name.
+struct SymbolTable {
+    symbols: HashMap<(PathBuf, String, String), Vec<usize>>,
+    /// Package clause of each file
+    files: HashMap<PathBuf, String>,
+    /// The package an import of each directory names, which is never its external test
+    /// package
+    imported: BTreeMap<PathBuf, String>,
+}
+
+impl SymbolTable {
+    fn build(nodes: &[Node]) -> Self {
+        let mut symbols: HashMap<(PathBuf, String, String), Vec<usize>> = HashMap::new();
+        let mut files = HashMap::new();
+        let mut imported: BTreeMap<PathBuf, String> = BTreeMap::new();
+        for (idx, node) in nodes.iter().enumerate() {
+            if !is_go_file(&node.file_path) {
+                continue;
+            }
+            let package_dir = node
+                .file_path
+                .parent()
+                .map(Path::to_path_buf)
+                .unwrap_or_default();
+            if !files.contains_key(&node.file_path) {
+                files.insert(node.file_path.clone(), node.package.clone());
+                if !is_external_test(&node.file_path, &node.package) {
+                    // Several packages in one directory don't build; pick one stably
+                    match imported.get(&package_dir) {
+                        Some(name) if name <= &node.package => {}
+                        _ => {
+                            imported.insert(package_dir.clone(), node.package.clone());
+                        }
+                    }
+                }
+            }
+            let Some(key) = symbol_key(node) else {
+                continue;
+            };
+            symbols
+                .entry((package_dir, node.package.clone(), key))
+                .or_default()
+                .push(idx);
+        }
+
+        // Keep definitions in source order so diagnostics are stable
+        for indices in symbols.values_mut() {
+            indices.sort_by(|&a, &b| {
+                (&nodes[a].file_path, nodes[a].line).cmp(&(&nodes[b].file_path, nodes[b].line))
+            });
+        }
+
+        Self {
+            symbols,
+            files,
+            imported,
+        }
+    }
+
+    /// The package `file` belongs to; a file declaring nothing is taken to be in the
+    /// package its directory's imports name
+    fn package_of<'a>(&'a self, file: &'a Path) -> Option<Package<'a>> {
+        let dir = file.parent()?;
+        match self.files.get(file) {
+            Some(name) => Some((dir, name)),
+            None => self.imported(dir),
+        }
+    }
+
+    /// The package an import resolving to `dir` names
+    fn imported<'a>(&'a self, dir: &'a Path) -> Option<Package<'a>> {
+        self.imported.get(dir).map(|name| (dir, name.as_str()))
+    }
+
+    fn key((dir, package): Package, name: &str) -> (PathBuf, String, String) {
+        (dir.to_path_buf(), package.to_string(), name.to_string())
+    }
+
+    /// The definition of `name` in `package`, if there is exactly one
+    fn unique(&self, package: Package, name: &str) -> Option<&usize> {
+        match self.symbols.get(&Self::key(package, name)) {
+            Some(indices) if indices.len() == 1 => indices.first(),
+            _ => None,
+        }
+    }
+
+    /// Whether `package` defines `name` at all, even more than once
+    fn contains(&self, package: Package, name: &str) -> bool {
+        self.symbols.contains_key(&Self::key(package, name))
+    }
+
+    /// One diagnostic per redefinition, pointing back at the first definition
+    fn duplicates(&self, nodes: &[Node]) -> Vec<Diagnostic> {
+        let mut diagnostics: Vec<Diagnostic> = self
+            .symbols
+            .iter()
+            .filter(|(_, indices)| indices.len() > 1)
+            .flat_map(|((_, _, name), indices)| {
+                let first = &nodes[indices[0]];
+                // Definitions for different platforms, in an index of all of them
+                let per_platform = |node: &Node| {
+                    matches!(
+                        (first.metadata.get("build"), node.metadata.get("build")),
+                        (Some(a), Some(b)) if a != b
+                    )
+                };
+                indices[1..]
+                    .iter()
+                    .filter(move |&&idx| !per_platform(&nodes[idx]))
+                    .map(move |&idx| {
+                        let node = &nodes[idx];
+                        Diagnostic::warning(
+                            DUPLICATE_SYMBOL,
+                            format!(
+                                "{} redeclared in package {} (first declared at {}:{})",
+                                name,
+                                node.package,
+                                first.file_path.display(),
+                                first.line
+                            ),
+                            node.file_path.clone(),
+                            node.line,
+                        )
+                    })
+            })
+            .collect();
+        diagnostics.sort_by(|a, b| (&a.file_path, a.line).cmp(&(&b.file_path, b.line)));
+        diagnostics
+    }
+}
+
+/// Package directory of every import path seen on a node, per root of a multi-root
+/// index, where two roots may declare the same import path
+struct PackageDirs {
+    dirs: HashMap<String, HashMap<Option<String>, PathBuf>>,
+    /// The root of each package directory
+    roots: HashMap<PathBuf, String>,
+}
+
+impl PackageDirs {
+    fn new(nodes: &[Node]) -> Self {
+        let mut packages = Self {
+            dirs: HashMap::new(),
+            roots: HashMap::new(),
+        };
+        for node in nodes {
+            let (Some(import_path), Some(dir)) =
+                (node.metadata.get("import_path"), node.file_path.parent())
+            else {
+                continue;
+            };
+            let root = node.root().map(str::to_string);
+            if let Some(root) = &root {
+                packages.roots.insert(dir.to_path_buf(), root.clone());
+            }
+            packages
+                .dirs
+                .entry(import_path.clone())
+                .or_default()
+                .insert(root, dir.to_path_buf());
+        }
+        packages
+    }
+
+    /// The directory `import_path` names in a file of `importer_dir`: the only package
+    /// with the path or, when several roots declare it, the importer's own root's
+    fn get(&self, import_path: &str, importer_dir: &Path) -> Option<&PathBuf> {
+        let dirs = self.dirs.get(import_path)?;
+        if dirs.len() == 1 {
+            return dirs.values().next();
+        }
+        let root = self.roots.get(importer_dir)?;
+        dirs.get(&Some(root.clone()))
+    }
+}
+
+/// Go source files, as opposed to files of the other languages a graph may hold
+fn is_go_file(path: &Path) -> bool {
+    path.extension().is_some_and(|ext| ext == "go")
+}
+
+/// Go test files, which `go build` leaves out
+fn is_test_file(path: &Path) -> bool {
+    path.file_name()
+        .and_then(|name| name.to_str())
+        .is_some_and(|name| name.ends_with("_test.go"))
+}
+
+/// Files of an external test package, `package foo_test` in a `_test.go` file, which
+/// sees the package it tests only through an import
+fn is_external_test(path: &Path, package: &str) -> bool {
+    package.ends_with("_test") && is_test_file(path)
+}
+
+/// `Benchmark` for `BenchmarkX(b *testing.B)` and `Fuzz` for `FuzzX(f *testing.F)` in a
+/// test file, where X doesn't start with a lowercase letter; otherwise `Function`
+fn test_function_kind(path: &Path, name: &str, parameters: &[Parameter]) -> NodeType {
+    let takes = |param_type: &str| match parameters {
+        [parameter] => parameter.param_type == param_type,
+        _ => false,
+    };
+    let named = |prefix: &str| {
+        name.strip_prefix(prefix)
+            .is_some_and(|rest| !rest.starts_with(char::is_lowercase))
+    };
+    if !is_test_file(path) {
+        NodeType::Function
+    } else if named("Benchmark") && takes("*testing.B") {
+        NodeType::Benchmark
+    } else if named("Fuzz") && takes("*testing.F") {
+        NodeType::Fuzz
+    } else {
+        NodeType::Function
+    }
+}
+
+fn is_exported(name: &str) -> bool {
+    name.chars().next().is_some_and(char::is_uppercase)
+}
+
+/// Conventional package name for an import path when the import has no alias:
+/// the last element, skipping a major version (`/v2`) and a `go-` prefix or
+/// `-go`/`.v2` suffix, e.g. `github.com/mattn/go-sqlite3` -> `sqlite3`
+fn default_package_name(import_path: &str) -> String {
+    let mut elements = import_path.rsplit('/');
+    let mut last = elements.next().unwrap_or(import_path);
+    let is_major_version =
+        last.len() > 1 && last.starts_with('v') && last[1..].chars().all(|c| c.is_ascii_digit());
+    if is_major_version {
+        last = elements.next().unwrap_or(last);
+    }
+    let last = last.split('.').next().unwrap_or(last);
+    let last = last.strip_prefix("go-").unwrap_or(last);
+    let last = last.strip_suffix("-go").unwrap_or(last);
+    last.replace('-', "_")
+}
+
+/// Record each node's package import path under the `import_path` metadata key
+fn assign_import_paths(nodes: &mut [Node], module: &GoModule) {
+    let mut by_dir: HashMap<PathBuf, Option<String>> = HashMap::new();
+    for node in nodes {
+        let Some(package_dir) = node.file_path.parent() else {
+            continue;
+        };
+        let import_path = by_dir
+            .entry(package_dir.to_path_buf())
+            .or_insert_with(|| module.import_path(package_dir));
+        if let Some(import_path) = import_path {
+            node.metadata
+                .insert("import_path".to_string(), import_path.clone());
+        }
+    }
+}
+
+/// Record the importing package of each import and whether it imports from `module`
+fn assign_importers(imports: &mut [Import], module: &GoModule) {
+    let mut by_dir: HashMap<PathBuf, Option<String>> = HashMap::new();
+    for import in imports {
+        if let Some(package_dir) = import.file_path.parent() {
+            import.importer = by_dir
+                .entry(package_dir.to_path_buf())
+                .or_insert_with(|| module.import_path(package_dir))
+                .clone();
+        }
+        import.internal = import
+            .path
+            .strip_prefix(module.path.as_str())
+            .is_some_and(|rest| rest.is_empty() || rest.starts_with('/'));
+    }
+}
+
+/// Whether declarations directly inside `node` are package-level: the file itself, or a
+/// stretch of it the parser couldn't fit into the grammar
+fn is_top_level(node: tree_sitter::Node) -> bool {
+    node.kind() == "source_file"
+        || (node.is_error() && node.parent().is_some_and(|p| p.kind() == "source_file"))
+}
+
+/// Cyclomatic complexity of a function or literal: one plus each `if`, `for` (ranges
+/// included), `case` of a switch or select, `&&` and `||`. A `default` is not a branch of
+/// its own, and function literals inside are left to their own count.
+fn cyclomatic_complexity(function: tree_sitter::Node) -> usize {
+    fn branches(node: tree_sitter::Node) -> usize {
+        let own = match node.kind() {
+            "if_statement" | "for_statement" | "expression_case" | "type_case"
+            | "communication_case" => 1,
+            "binary_expression" => node
+                .child_by_field_name("operator")
+                .filter(|op| matches!(op.kind(), "&&" | "||"))
+                .map_or(0, |_| 1),
+            _ => 0,
+        };
+        let mut cursor = node.walk();
+        let nested: usize = node
+            .children(&mut cursor)
+            .filter(|child| child.kind() != "func_literal")
+            .map(branches)
+            .sum();
+        own + nested
+    }
+    function
+        .child_by_field_name("body")
+        .map_or(1, |body| 1 + branches(body))
+}
+
+/// Non-blank lines between the braces of a function's body; 0 for `{}` or no body
+fn body_lines(function: tree_sitter::Node, source: &str) -> usize {
+    let Some(body) = function.child_by_field_name("body") else {
+        return 0;
+    };
+    let text = &source[body.byte_range()];
+    let inner = text
+        .strip_prefix('{')
+        .and_then(|text| text.strip_suffix('}'))
+        .unwrap_or(text);
+    inner.lines().filter(|line| !line.trim().is_empty()).count()
+}
+
+/// Files parsed between merges with [`GoParser::with_low_memory`]
+pub const LOW_MEMORY_BATCH: usize = 256;
+
+/// Parse output of one file, before cross-file resolution
+struct FileResult {
+    key: String,
+    content_hash: String,
+    graph: CodeGraph,
+    cache_hit: bool,
+    /// Left out by the build constraints; only the names it declares are kept
+    excluded: bool,
+}
+
+/// What is known about a function's locals while its body is walked
+#[derive(Default, Clone)]
+struct LocalScope {
+    /// Static types of parameters and locals, used to resolve c.Method() calls
+    types: HashMap<String, String>,
+    /// Every name the function declares; these shadow package-level symbols
+    names: HashSet<String>,
+    /// Locals currently holding a named function, e.g. `op := Add` or `f := c.Add`
+    functions: HashMap<String, FunctionValue>,
+    /// Function literals seen so far directly in this function, for numbering them
+    closures: usize,
+    /// The function being walked is itself a function literal
+    in_closure: bool,
+    /// Parameters by name, with their position and the ID of the function declaring
+    /// them; a literal sees the parameters of the functions around it
+    parameters: HashMap<String, (usize, String)>,
+}
+
+impl LocalScope {
+    fn bind_parameters(&mut self, parameters: &[Parameter], function_id: &str) {
+        for (index, param) in parameters.iter().enumerate() {
+            if param.name != "_" {
+                self.parameters
+                    .insert(param.name.clone(), (index, function_id.to_string()));
+            }
+        }
+    }
+}
+
+/// A named function held by a local
+#[derive(Clone)]
+enum FunctionValue {
+    /// `op := Add`
+    Function(String),
+    /// `f := c.Add`, with the receiver bound, or `g := (*Calculator).Subtract`, taking
+    /// it as the first argument; `kind` is [`METHOD_VALUE`] or [`METHOD_EXPRESSION`]
+    Method {
+        receiver_type: String,
+        method: String,
+        kind: &'static str,
+    },
+}
+
+/// `binding` of a call through a local holding a method value
+const METHOD_VALUE: &str = "method_value";
+/// `binding` of a call through a local holding a method expression
+const METHOD_EXPRESSION: &str = "method_expression";
+
+/// Identifiers that are never package-level symbols when used as values
+const PREDECLARED: &[&str] = &["_", "nil", "true", "false", "iota"];
+
+struct Receiver {
+    var_name: Option<String>,
+    type_name: String,
+    pointer: bool,
+}
+
+/// Reduce a type expression to its named base type, e.g. *Calculator -> (Calculator, true)
+fn base_type_name(node: tree_sitter::Node, source: &str) -> (String, bool) {
+    match node.kind() {
+        "pointer_type" => {
+            let mut cursor = node.walk();
+            let inner = node.named_children(&mut cursor).next();
+            match inner {
+                Some(inner) => (base_type_name(inner, source).0, true),
+                None => (String::new(), true),
+            }
+        }
+        "parenthesized_type" => {
+            let mut cursor = node.walk();
+            let inner = node.named_children(&mut cursor).next();
+            match inner {
+                Some(inner) => base_type_name(inner, source),
+                None => (String::new(), false),
+            }
+        }
+        "generic_type" => {
+            let name = node
+                .child_by_field_name("type")
+                .map(|n| source[n.byte_range()].to_string())
+                .unwrap_or_default();
+            (name, false)
+        }
+        "type_identifier" | "qualified_type" => (source[node.byte_range()].to_string(), false),
+        _ => (String::new(), false),
+    }
+}
+
+/// The generic function a call instantiates with explicit type arguments: `Map` in
+/// `Map[int](xs)` and `calc.Map` in `calc.Map[int, string](xs)`. Any other callee is
+/// returned as it is, including an element of a local such as `handlers[i]()`.
+fn generic_callee<'t>(
+    function: tree_sitter::Node<'t>,
+    source: &str,
+    scope: &LocalScope,
+) -> tree_sitter::Node<'t> {
+    let base = match function.kind() {
+        "index_expression" => function.child_by_field_name("operand"),
+        "type_instantiation_expression" => function.child_by_field_name("type"),
+        _ => None,
+    };
+    let Some(base) = base else {
+        return function;
+    };
+    let first = match base.kind() {
+        "identifier" | "type_identifier" => Some(base),
+        "selector_expression" => base.child_by_field_name("operand"),
+        "qualified_type" => base.child_by_field_name("package"),
+        _ => None,
+    };
+    match first {
+        Some(first) if !scope.names.contains(&source[first.byte_range()]) => base,
+        _ => function,
+    }
+}
+
+/// Type parameters in scope in a function or method: its own, as in `func Map[T, U any]`,
+/// and those a method's receiver names, as in `func (s *Stack[T]) Push(v T)`
+fn type_parameters(node: tree_sitter::Node, source: &str) -> HashSet<String> {
+    let mut names = HashSet::new();
+    if let Some(list) = node.child_by_field_name("type_parameters") {
+        let mut cursor = list.walk();
+        for declaration in list.named_children(&mut cursor) {
+            let mut name_cursor = declaration.walk();
+            names.extend(
+                declaration
+                    .children_by_field_name("name", &mut name_cursor)
+                    .map(|n| source[n.byte_range()].to_string()),
+            );
+        }
+    }
+
+    let mut receiver_type = node.child_by_field_name("receiver").and_then(|list| {
+        let mut cursor = list.walk();
+        let param = list
+            .named_children(&mut cursor)
+            .find(|c| c.kind() == "parameter_declaration");
+        param.and_then(|param| param.child_by_field_name("type"))
+    });
+    while let Some(type_node) = receiver_type {
+        match type_node.kind() {
+            "pointer_type" | "parenthesized_type" => receiver_type = type_node.named_child(0),
+            "generic_type" => {
+                if let Some(arguments) = type_node.child_by_field_name("type_arguments") {
+                    let mut cursor = arguments.walk();
+                    names.extend(
+                        arguments
+                            .named_children(&mut cursor)
+                            .map(|n| source[n.byte_range()].trim().to_string()),
+                    );
+                }
+                break;
+            }
+            _ => break,
+        }
+    }
+    names
+}
+
+/// Names declared anywhere inside a function: receiver, parameters, results and locals.
+/// Block scoping is ignored, so a name declared in one block shadows a package-level
+/// symbol of the same name throughout the function.
+fn local_names(node: tree_sitter::Node, source: &str) -> HashSet<String> {
+    let mut names = HashSet::new();
+    let mut pending = vec![node];
+    while let Some(current) = pending.pop() {
+        let declared = match current.kind() {
+            "parameter_declaration"
+            | "variadic_parameter_declaration"
+            | "var_spec"
+            | "const_spec" => {
+                let mut cursor = current.walk();
+                current
+                    .children_by_field_name("name", &mut cursor)
+                    .collect::<Vec<_>>()
+            }
+            "short_var_declaration" | "range_clause" | "receive_statement" => current
+                .child_by_field_name("left")
+                .map(expression_list)
+                .unwrap_or_default(),
+            "type_switch_statement" => current
+                .child_by_field_name("alias")
+                .map(expression_list)
+                .unwrap_or_default(),
+            _ => Vec::new(),
+        };
+        names.extend(
+            declared
+                .into_iter()
+                .filter(|n| n.kind() == "identifier")
+                .map(|n| source[n.byte_range()].to_string()),
+        );
+
+        let mut cursor = current.walk();
+        pending.extend(current.named_children(&mut cursor));
+    }
+    names
+}
+
+/// The expressions of an `expression_list`, or the node itself if it is a single expression
+fn expression_list(node: tree_sitter::Node) -> Vec<tree_sitter::Node> {
+    if node.kind() != "expression_list" {
+        return vec![node];
+    }
+    let mut cursor = node.walk();
+    node.named_children(&mut cursor).collect()
+}
+
+/// How an expression is used by its parent, or `None` where the position is not a use
+/// worth recording: call targets, selector operands, declared names and the like
+fn value_use(node: tree_sitter::Node) -> Option<ReferenceKind> {
+    let parent = node.parent()?;
+    match parent.kind() {
+        "argument_list" => Some(ReferenceKind::Argument),
+        "expression_list" => {
+            let owner = parent.parent()?;
+            let value_field = match owner.kind() {
+                "short_var_declaration" | "assignment_statement" => "right",
+                "var_spec" | "const_spec" => "value",
+                "return_statement" => return Some(ReferenceKind::Value),
+                _ => return None,
+            };
+            (owner.child_by_field_name(value_field) == Some(parent))
+                .then_some(ReferenceKind::Assignment)
+        }
+        "literal_element" => {
+            // In `{Key: value}` the key names a struct field or map key, not a symbol
+            let owner = parent.parent()?;
+            let is_key = owner.kind() == "keyed_element" && owner.named_child(0) == Some(parent);
+            (!is_key).then_some(ReferenceKind::Value)
+        }
+        "binary_expression" | "send_statement" => Some(ReferenceKind::Value),
+        _ => None,
+    }
+}
+
+/// Record a reference from the function `from_id` to `target` at `node`
+#[allow(clippy::too_many_arguments)]
+fn add_reference(
+    node: tree_sitter::Node,
+    source: &str,
+    file_path: &Path,
+    from_id: String,
+    target: String,
+    kind: ReferenceKind,
+    metadata: HashMap<String, String>,
+    graph: &mut CodeGraph,
+) {
+    if graph.get_node_by_id(&from_id).is_none() {
+        return;
+    }
+    let row = node.start_position().row;
+    // The whole line gives more context than the bare name
+    let context = source.lines().nth(row).unwrap_or("").trim().to_string();
+    let mut edge = Edge::new(
+        from_id,
+        target,
+        EdgeType::References,
+        context,
+        file_path.to_path_buf(),
+        row + 1,
+    );
+    edge.metadata.extend(metadata);
+    edge.metadata
+        .insert("reference".to_string(), kind.as_str().to_string());
+    // A function passed straight to a call may be called through that parameter
+    if let Some((call, index)) = argument_position(node) {
+        let position = call.start_position();
+        edge.metadata
+            .insert("argument".to_string(), index.to_string());
+        edge.metadata.insert(
+            "call".to_string(),
+            format!("{}:{}", position.row + 1, position.column + 1),
+        );
+    }
+    edge.column = node.start_position().column + 1;
+    graph.add_reference(edge);
+}
+
+/// Mark a call made by a `go` or `defer` statement
+fn set_call_kind(edge: &mut Edge, call: tree_sitter::Node) {
+    let kind = match call.parent().map(|parent| parent.kind()) {
+        Some("go_statement") => EdgeKind::Go,
+        Some("defer_statement") => EdgeKind::Defer,
+        _ => return,
+    };
+    edge.metadata
+        .insert("kind".to_string(), kind.as_str().to_string());
+}
+
+/// The declaration a lone spec is written in, so `type T int` spans from `type`;
+/// a spec in a parenthesized group spans only itself
+fn declaration_of(spec: tree_sitter::Node) -> tree_sitter::Node {
+    match spec.parent() {
+        Some(parent)
+            if parent.kind().ends_with("_declaration")
+                && parent.child(1).is_some_and(|child| child.kind() != "(") =>
+        {
+            parent
+        }
+        _ => spec,
+    }
+}
+
+/// Comments alone on their lines, keyed by the 0-based row each ends on. Comments
+/// trailing code are left out: they document that line.
+fn own_line_comments<'t>(
+    root: tree_sitter::Node<'t>,
+    source: &str,
+) -> BTreeMap<usize, tree_sitter::Node<'t>> {
+    let mut comments = BTreeMap::new();
+    let mut pending = vec![root];
+    while let Some(node) = pending.pop() {
+        if node.kind() == "comment" {
+            let start = node.start_byte();
+            let line_start = source[..start].rfind('\n').map_or(0, |i| i + 1);
+            if source[line_start..start].trim().is_empty() {
+                comments.insert(node.end_position().row, node);
+            }
+            continue;
+        }
+        let mut cursor = node.walk();
+        pending.extend(node.children(&mut cursor));
+    }
+    comments
+}
+
+/// The comment group of a declaration starting on 0-based `row`: the comments directly
+/// above it, with no blank line between, top first
+fn comment_group<'t>(
+    comments: &BTreeMap<usize, tree_sitter::Node<'t>>,
+    row: usize,
+) -> Vec<tree_sitter::Node<'t>> {
+    let mut group = Vec::new();
+    let mut row = row;
+    while let Some(&comment) = row.checked_sub(1).and_then(|above| comments.get(&above)) {
+        group.push(comment);
+        row = comment.start_position().row;
+    }
+    group.reverse();
+    group
+}
+
+/// The doc comment a comment group spells, as `go doc` reads it. Comment markers and
+/// trailing whitespace are stripped, blank lines between paragraphs kept, and
+/// directives such as `//go:generate` left out.
+fn doc_comment(group: &[tree_sitter::Node], source: &str) -> String {
+    let mut lines: Vec<String> = Vec::new();
+    for comment in group {
+        let text = &source[comment.byte_range()];
+        if let Some(line) = text.strip_prefix("//") {
+            if !is_directive(line) {
+                let line = line.strip_prefix(' ').unwrap_or(line);
+                lines.push(line.trim_end().to_string());
+            }
+        } else {
+            let body = text
+                .strip_prefix("/*")
+                .and_then(|body| body.strip_suffix("*/"))
+                .unwrap_or(text);
+            let body: Vec<&str> = body.lines().map(str::trim_end).collect();
+            // Continuation lines lose the indentation they share
+            let indent = body
+                .iter()
+                .skip(1)
+                .filter(|line| !line.trim().is_empty())
+                .map(|line| line.len() - line.trim_start().len())
+                .min()
+                .unwrap_or(0);
+            for (i, line) in body.iter().enumerate() {
+                let line = match i {
+                    0 => line.trim_start(),
+                    _ => line.get(indent..).unwrap_or(line.trim_start()),
+                };
+                lines.push(line.to_string());
+            }
+        }
+    }
+
+    // Paragraph breaks survive; runs of blank lines and blank edges don't
+    lines.dedup_by(|line, previous| line.is_empty() && previous.is_empty());
+    lines.join("\n").trim_matches('\n').to_string()
+}
+
+/// `//go:noinline`, `//nolint:errcheck`, `//line a.go:1` and the like: text after `//`
+/// with no space that tools read and readers don't
+fn is_directive(line: &str) -> bool {
+    if ["line ", "extern ", "export "]
+        .iter()
+        .any(|prefix| line.starts_with(prefix))
+    {
+        return true;
+    }
+    let Some((tool, _)) = line.split_once(':') else {
+        return false;
+    };
+    !tool.is_empty()
+        && tool
+            .chars()
+            .all(|c| c.is_ascii_lowercase() || c.is_ascii_digit())
+        && line[tool.len() + 1..]
+            .chars()
+            .next()
+            .is_some_and(|c| c.is_ascii_lowercase() || c.is_ascii_digit())
+}
+
+/// Where a function value is stored when `node` is assigned to a local or a struct field
+/// of known type: `stored_in_local` for `op := Add`, and `stored_in_field` with `T.fn`
+/// for `T{fn: Add}` or `t.fn = Add`, so a later `op()` or `t.fn()` may call it
+fn stored_in(
+    node: tree_sitter::Node,
+    source: &str,
+    scope: &LocalScope,
+) -> Option<(String, String)> {
+    let parent = node.parent()?;
+    if parent.kind() == "literal_element" {
+        let element = parent.parent().filter(|e| e.kind() == "keyed_element")?;
+        if element.named_child(1) != Some(parent) {
+            return None;
+        }
+        let key = element.named_child(0)?;
+        let key = match key.kind() {
+            "literal_element" => key.named_child(0)?,
+            _ => key,
+        };
+        let literal = element.parent()?.parent()?;
+        let type_name = match literal.kind() {
+            "composite_literal" => composite_literal_type(literal, source)?,
+            _ => return None,
+        };
+        if type_name.contains('.') || !matches!(key.kind(), "identifier" | "field_identifier") {
+            return None;
+        }
+        let field = format!("{}.{}", type_name, &source[key.byte_range()]);
+        return Some(("stored_in_field".to_string(), field));
+    }
+
+    let owner = parent
+        .parent()
+        .filter(|_| parent.kind() == "expression_list")?;
+    let names: Vec<tree_sitter::Node> = match owner.kind() {
+        "short_var_declaration" | "assignment_statement"
+            if owner.child_by_field_name("right") == Some(parent) =>
+        {
+            expression_list(owner.child_by_field_name("left")?)
+        }
+        "var_spec" if owner.child_by_field_name("value") == Some(parent) => {
+            let mut cursor = owner.walk();
+            owner.children_by_field_name("name", &mut cursor).collect()
+        }
+        _ => return None,
+    };
+    let mut cursor = parent.walk();
+    let index = parent
+        .named_children(&mut cursor)
+        .filter(|child| child.kind() != "comment")
+        .position(|child| child == node)?;
+    let name = *names.get(index)?;
+    match name.kind() {
+        "identifier" => {
+            let local = &source[name.byte_range()];
+            scope
+                .names
+                .contains(local)
+                .then(|| ("stored_in_local".to_string(), local.to_string()))
+        }
+        "selector_expression" => {
+            let operand = name.child_by_field_name("operand")?;
+            let type_name = scope.types.get(&source[operand.byte_range()])?;
+            let field = &source[name.child_by_field_name("field")?.byte_range()];
+            Some((
+                "stored_in_field".to_string(),
+                format!("{}.{}", type_name, field),
+            ))
+        }
+        _ => None,
+    }
+}
+
+/// The call `node` is an argument of, and its position in the argument list
+fn argument_position(node: tree_sitter::Node) -> Option<(tree_sitter::Node, usize)> {
+    let arguments = node.parent().filter(|p| p.kind() == "argument_list")?;
+    let call = arguments
+        .parent()
+        .filter(|p| p.kind() == "call_expression")?;
+    let mut cursor = arguments.walk();
+    let index = arguments
+        .named_children(&mut cursor)
+        .filter(|child| child.kind() != "comment")
+        .position(|child| child == node)?;
+    Some((call, index))
+}
+
+/// The keys of a `T{field: value}` literal of a struct type declared in this package,
+/// each with the field name and the metadata resolving it to `T.field`
+fn field_initializers<'a>(
+    node: tree_sitter::Node<'a>,
+    source: &str,
+) -> Vec<(tree_sitter::Node<'a>, String, HashMap<String, String>)> {
+    let Some(type_node) = node.child_by_field_name("type") else {
+        return Vec::new();
+    };
+    let (type_name, _) = base_type_name(type_node, source);
+    // Types from other packages carry their qualifier and can't be resolved here
+    if type_name.is_empty() || type_name.contains('.') {
+        return Vec::new();
+    }
+    let Some(body) = node.child_by_field_name("body") else {
+        return Vec::new();
+    };
+
+    let mut initializers = Vec::new();
+    let mut cursor = body.walk();
+    for element in body
+        .named_children(&mut cursor)
+        .filter(|n| n.kind() == "keyed_element")
+    {
+        let key = element.named_child(0).map(|key| match key.kind() {
+            "literal_element" => key.named_child(0).unwrap_or(key),
+            _ => key,
+        });
+        let Some(key) = key.filter(|k| matches!(k.kind(), "identifier" | "field_identifier"))
+        else {
+            continue;
+        };
+        let field = source[key.byte_range()].to_string();
+        let metadata = HashMap::from([
+            ("receiver_type".to_string(), type_name.clone()),
+            ("method".to_string(), field.clone()),
+            ("use".to_string(), ReferenceKind::Write.as_str().to_string()),
+            (
+                "access".to_string(),
+                FieldAccessKind::Init.as_str().to_string(),
+            ),
+        ]);
+        initializers.push((key, field, metadata));
+    }
+    initializers
+}
+
+/// How a `x.name` selector on a value of known type uses the name: its reference kind
+/// were it a field, and whether it reads or writes it. `None` when it is called.
+fn selector_access(node: tree_sitter::Node) -> Option<(ReferenceKind, FieldAccessKind)> {
+    let parent = node.parent()?;
+    if parent.kind() == "call_expression" && parent.child_by_field_name("function") == Some(node) {
+        return None;
+    }
+    let written = match parent.kind() {
+        "inc_statement" | "dec_statement" => true,
+        "expression_list" => parent.parent().is_some_and(|owner| {
+            owner.kind() == "assignment_statement"
+                && owner.child_by_field_name("left") == Some(parent)
+        }),
+        _ => false,
+    };
+    match written {
+        true => Some((ReferenceKind::Write, FieldAccessKind::Write)),
+        // Selectors are read in many more places than functions are used as values,
+        // e.g. `if c.enabled` or `c.items[i]`
+        false => Some((
+            value_use(node).unwrap_or(ReferenceKind::Value),
+            FieldAccessKind::Read,
+        )),
+    }
+}
+
+/// Receiver type of a method expression operand: `(*T)` or `(T)`
+fn method_expression_type(node: tree_sitter::Node, source: &str) -> Option<String> {
+    let inner = node.named_child(0)?;
+    let inner = match inner.kind() {
+        "unary_expression" => inner.child_by_field_name("operand")?,
+        _ => inner,
+    };
+    (inner.kind() == "identifier").then(|| source[inner.byte_range()].to_string())
+}
+
+/// Type of a `T{...}` or `&T{...}` expression
+fn composite_literal_type(node: tree_sitter::Node, source: &str) -> Option<String> {
+    match node.kind() {
+        "unary_expression" => node
+            .child_by_field_name("operand")
+            .and_then(|operand| composite_literal_type(operand, source)),
+        "composite_literal" => {
+            let (type_name, _) = base_type_name(node.child_by_field_name("type")?, source);
+            (!type_name.is_empty()).then_some(type_name)
         }
+        _ => None,
     }
 }
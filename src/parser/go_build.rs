@@ -0,0 +1,391 @@
+//! Go build constraints: `//go:build` lines and `_GOOS`/`_GOARCH` file name suffixes.
+
+use std::collections::BTreeSet;
+use std::path::Path;
+use std::str::FromStr;
+
+const KNOWN_OS: &[&str] = &[
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "js",
+    "linux",
+    "nacl",
+    "netbsd",
+    "openbsd",
+    "plan9",
+    "solaris",
+    "wasip1",
+    "windows",
+    "zos",
+];
+
+const KNOWN_ARCH: &[&str] = &[
+    "386",
+    "amd64",
+    "amd64p32",
+    "arm",
+    "armbe",
+    "arm64",
+    "arm64be",
+    "loong64",
+    "mips",
+    "mipsle",
+    "mips64",
+    "mips64le",
+    "mips64p32",
+    "mips64p32le",
+    "ppc",
+    "ppc64",
+    "ppc64le",
+    "riscv",
+    "riscv64",
+    "s390",
+    "s390x",
+    "sparc",
+    "sparc64",
+    "wasm",
+];
+
+const UNIX_OS: &[&str] = &[
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "linux",
+    "netbsd",
+    "openbsd",
+    "solaris",
+];
+
+/// The target a set of files is selected for, like `GOOS`, `GOARCH` and `-tags`
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct BuildContext {
+    pub goos: String,
+    pub goarch: String,
+    /// Extra tags that count as satisfied, e.g. `integration`
+    pub tags: BTreeSet<String>,
+    /// Minor version of the Go release to build with, e.g. 21 for Go 1.21: `go1.N` holds
+    /// for every N up to it. `None` satisfies every release tag.
+    pub go_version: Option<u32>,
+    /// Whether `cgo` holds, like `CGO_ENABLED=1`
+    pub cgo: bool,
+}
+
+impl BuildContext {
+    /// A target with cgo enabled and any Go release
+    pub fn new(goos: &str, goarch: &str) -> Self {
+        Self {
+            goos: goos.to_string(),
+            goarch: goarch.to_string(),
+            tags: BTreeSet::new(),
+            go_version: None,
+            cgo: true,
+        }
+    }
+
+    /// The platform this binary runs on, using Go's names for it, with cgo disabled
+    /// when `CGO_ENABLED=0` is set
+    pub fn host() -> Self {
+        let goos = match std::env::consts::OS {
+            "macos" => "darwin",
+            os => os,
+        };
+        let goarch = match std::env::consts::ARCH {
+            "x86_64" => "amd64",
+            "x86" => "386",
+            "aarch64" => "arm64",
+            "loongarch64" => "loong64",
+            "powerpc64" if cfg!(target_endian = "little") => "ppc64le",
+            "powerpc64" => "ppc64",
+            "wasm32" => "wasm",
+            arch => arch,
+        };
+        let mut host = Self::new(goos, goarch);
+        host.cgo = std::env::var("CGO_ENABLED").map_or(true, |enabled| enabled != "0");
+        host
+    }
+
+    /// Whether the go tool would compile `path` for this target, judging by its
+    /// file name and any `//go:build` line in `source`
+    pub fn matches_file(&self, path: &Path, source: &str) -> bool {
+        self.matches_file_name(path) && build_constraint(source).is_none_or(|expr| self.eval(expr))
+    }
+
+    fn matches_file_name(&self, path: &Path) -> bool {
+        file_name_constraint(path).is_none_or(|expr| self.eval(&expr))
+    }
+
+    fn has_tag(&self, tag: &str) -> bool {
+        tag == self.goos
+            || tag == self.goarch
+            || self.tags.contains(tag)
+            || (tag == "unix" && UNIX_OS.contains(&self.goos.as_str()))
+            // GOOS values that imply another one
+            || (tag == "linux" && self.goos == "android")
+            || (tag == "solaris" && self.goos == "illumos")
+            || (tag == "darwin" && self.goos == "ios")
+            || tag == "gc"
+            || (tag == "cgo" && self.cgo)
+            // Every release tag up to the target release is satisfied
+            || tag
+                .strip_prefix("go1.")
+                .and_then(|minor| minor.parse::<u32>().ok())
+                .is_some_and(|minor| self.go_version.is_none_or(|version| minor <= version))
+    }
+
+    /// Evaluate a constraint expression. Malformed expressions are treated as satisfied;
+    /// indexing a file the compiler would reject is better than silently dropping it.
+    pub fn eval(&self, expr: &str) -> bool {
+        let tokens = tokenize(expr);
+        let mut parser = ExprParser {
+            tokens: &tokens,
+            pos: 0,
+            context: self,
+        };
+        match parser.or() {
+            Some(value) if parser.pos == tokens.len() => value,
+            _ => true,
+        }
+    }
+}
+
+impl FromStr for BuildContext {
+    type Err = anyhow::Error;
+
+    /// Parse a target as written on the command line, e.g. `--platform linux/arm64`
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.split_once('/') {
+            Some((goos, goarch)) if !goos.is_empty() && !goarch.is_empty() => {
+                Ok(Self::new(goos, goarch))
+            }
+            _ => anyhow::bail!("Expected GOOS/GOARCH, e.g. linux/amd64, not '{}'", s),
+        }
+    }
+}
+
+/// The minor version of a Go release as written for `--go-version`: `1.21`, `1.21.3`
+/// or `go1.21` all give 21
+pub fn parse_go_version(version: &str) -> anyhow::Result<u32> {
+    let numbers = version.strip_prefix("go").unwrap_or(version);
+    numbers
+        .strip_prefix("1.")
+        .and_then(|rest| rest.split('.').next())
+        .and_then(|minor| minor.parse().ok())
+        .ok_or_else(|| anyhow::anyhow!("Expected a Go version like 1.21, not '{}'", version))
+}
+
+/// Everything restricting the platforms a file builds for, as one constraint expression:
+/// its `_GOOS`/`_GOARCH` name suffix and its `//go:build` line. `None` for a file every
+/// platform compiles.
+pub fn file_constraint(path: &Path, source: &str) -> Option<String> {
+    match (file_name_constraint(path), build_constraint(source)) {
+        (Some(name), Some(line)) => Some(format!("{} && ({})", name, line)),
+        (Some(name), None) => Some(name),
+        (None, line) => line.map(str::to_string),
+    }
+}
+
+/// The constraint a `_GOOS`, `_GOARCH` or `_GOOS_GOARCH` file name suffix implies
+fn file_name_constraint(path: &Path) -> Option<String> {
+    let stem = path.file_stem().and_then(|s| s.to_str())?;
+    let stem = stem.strip_suffix("_test").unwrap_or(stem);
+    // The first element is the file's own name, never a constraint
+    let parts: Vec<&str> = stem.split('_').skip(1).collect();
+    match parts.as_slice() {
+        [.., os, arch] if KNOWN_OS.contains(os) && KNOWN_ARCH.contains(arch) => {
+            Some(format!("{} && {}", os, arch))
+        }
+        [.., last] if KNOWN_OS.contains(last) || KNOWN_ARCH.contains(last) => {
+            Some(last.to_string())
+        }
+        _ => None,
+    }
+}
+
+/// The expression of the `//go:build` line in a file's header, if any. Only comments
+/// and blank lines may precede it, as with the go tool.
+pub fn build_constraint(source: &str) -> Option<&str> {
+    for line in source.lines() {
+        let line = line.trim();
+        if let Some(expr) = line.strip_prefix("//go:build") {
+            if expr.is_empty() || expr.starts_with(char::is_whitespace) {
+                return Some(expr.trim());
+            }
+        }
+        if !line.is_empty() && !line.starts_with("//") {
+            return None;
+        }
+    }
+    None
+}
+
+fn tokenize(expr: &str) -> Vec<String> {
+    let mut tokens = Vec::new();
+    let mut chars = expr.chars().peekable();
+    while let Some(c) = chars.next() {
+        match c {
+            c if c.is_whitespace() => {}
+            '(' | ')' | '!' => tokens.push(c.to_string()),
+            '&' | '|' => {
+                if chars.peek() == Some(&c) {
+                    chars.next();
+                }
+                tokens.push(format!("{}{}", c, c));
+            }
+            _ => {
+                let mut ident = c.to_string();
+                while let Some(&next) = chars.peek() {
+                    if next.is_alphanumeric() || next == '_' || next == '.' {
+                        ident.push(next);
+                        chars.next();
+                    } else {
+                        break;
+                    }
+                }
+                tokens.push(ident);
+            }
+        }
+    }
+    tokens
+}
+
+/// Recursive descent over `||`, `&&`, `!` and parentheses, in Go's precedence order
+struct ExprParser<'a> {
+    tokens: &'a [String],
+    pos: usize,
+    context: &'a BuildContext,
+}
+
+impl ExprParser<'_> {
+    fn peek(&self) -> Option<&str> {
+        self.tokens.get(self.pos).map(String::as_str)
+    }
+
+    fn or(&mut self) -> Option<bool> {
+        let mut value = self.and()?;
+        while self.peek() == Some("||") {
+            self.pos += 1;
+            value |= self.and()?;
+        }
+        Some(value)
+    }
+
+    fn and(&mut self) -> Option<bool> {
+        let mut value = self.unary()?;
+        while self.peek() == Some("&&") {
+            self.pos += 1;
+            value &= self.unary()?;
+        }
+        Some(value)
+    }
+
+    fn unary(&mut self) -> Option<bool> {
+        let token = self.peek()?.to_string();
+        self.pos += 1;
+        match token.as_str() {
+            "!" => self.unary().map(|value| !value),
+            "(" => {
+                let value = self.or()?;
+                if self.peek() != Some(")") {
+                    return None;
+                }
+                self.pos += 1;
+                Some(value)
+            }
+            ")" | "&&" | "||" => None,
+            tag => Some(self.context.has_tag(tag)),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_eval_constraint_expressions() {
+        let linux = BuildContext::new("linux", "amd64");
+        assert!(linux.eval("linux"));
+        assert!(linux.eval("unix && !windows"));
+        assert!(linux.eval("(darwin || linux) && amd64"));
+        assert!(linux.eval("go1.21"));
+        assert!(!linux.eval("ignore"));
+        assert!(!linux.eval("windows || (darwin && arm64)"));
+        // Malformed expressions don't hide the file
+        assert!(linux.eval("linux &&"));
+
+        let mut tagged = BuildContext::new("windows", "arm64");
+        tagged.tags.insert("integration".to_string());
+        assert!(tagged.eval("integration && windows"));
+        assert!(!tagged.eval("unix"));
+    }
+
+    #[test]
+    fn test_release_and_cgo_tags_follow_the_context() {
+        let mut old = BuildContext::new("linux", "amd64");
+        assert!(old.eval("cgo && go1.99"));
+
+        old.go_version = Some(20);
+        old.cgo = false;
+        assert!(old.eval("go1.20 && go1.1"));
+        assert!(!old.eval("go1.21"));
+        assert!(old.eval("!cgo"));
+        assert!(!old.eval("cgo || go1.22"));
+
+        assert_eq!(parse_go_version("1.21").unwrap(), 21);
+        assert_eq!(parse_go_version("go1.22.3").unwrap(), 22);
+        assert!(parse_go_version("2.0").is_err());
+    }
+
+    #[test]
+    fn test_file_name_suffixes() {
+        let linux = BuildContext::new("linux", "amd64");
+        assert!(linux.matches_file_name(Path::new("foo_linux.go")));
+        assert!(linux.matches_file_name(Path::new("foo_linux_amd64_test.go")));
+        assert!(!linux.matches_file_name(Path::new("foo_windows.go")));
+        assert!(!linux.matches_file_name(Path::new("foo_linux_arm64.go")));
+        assert!(!linux.matches_file_name(Path::new("foo_386.go")));
+        // A bare platform name is a file name, not a constraint
+        assert!(linux.matches_file_name(Path::new("windows.go")));
+    }
+
+    #[test]
+    fn test_file_constraint_combines_name_and_build_line() {
+        assert_eq!(
+            file_constraint(Path::new("open_linux_arm64.go"), "package foo\n"),
+            Some("linux && arm64".to_string())
+        );
+        assert_eq!(
+            file_constraint(
+                Path::new("open_linux.go"),
+                "//go:build cgo || go1.20\n\npackage foo\n"
+            ),
+            Some("linux && (cgo || go1.20)".to_string())
+        );
+        assert_eq!(
+            file_constraint(Path::new("open.go"), "//go:build !windows\n\npackage foo\n"),
+            Some("!windows".to_string())
+        );
+        assert_eq!(file_constraint(Path::new("open.go"), "package foo\n"), None);
+    }
+
+    #[test]
+    fn test_build_constraint_must_precede_package_clause() {
+        assert_eq!(
+            build_constraint("// Copyright\n\n//go:build linux\n\npackage foo\n"),
+            Some("linux")
+        );
+        assert_eq!(build_constraint("package foo\n//go:build linux\n"), None);
+        assert_eq!(build_constraint("//go:builder linux\npackage foo\n"), None);
+    }
+}
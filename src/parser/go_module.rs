@@ -0,0 +1,174 @@
+//! Go module layout: finding go.mod, import paths, the modules a go.work uses and the
+//! directories `./...` skips.
+
+use std::fs;
+use std::path::{Component, Path, PathBuf};
+
+/// A Go module: the directory holding go.mod and the module path it declares
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GoModule {
+    /// Canonical path of the directory containing go.mod
+    pub root: PathBuf,
+    /// Module path from the `module` directive, e.g. `example.com/mymod`
+    pub path: String,
+}
+
+impl GoModule {
+    /// The module containing `dir`: the nearest go.mod in `dir` or one of its ancestors
+    pub fn find(dir: &Path) -> Option<Self> {
+        let dir = dir.canonicalize().ok()?;
+        dir.ancestors().find_map(|candidate| {
+            let go_mod = fs::read_to_string(candidate.join("go.mod")).ok()?;
+            Some(Self {
+                root: candidate.to_path_buf(),
+                path: parse_module_path(&go_mod)?,
+            })
+        })
+    }
+
+    /// Import path of the package in `package_dir`, or `None` if it lies outside the module.
+    /// Vendored packages keep the import path they are vendored under.
+    pub fn import_path(&self, package_dir: &Path) -> Option<String> {
+        let package_dir = package_dir.canonicalize().ok()?;
+        let relative = package_dir.strip_prefix(&self.root).ok()?;
+        let components: Vec<String> = relative
+            .components()
+            .map(|c| c.as_os_str().to_string_lossy().to_string())
+            .collect();
+
+        if let Some(vendor) = components.iter().rposition(|c| c == "vendor") {
+            let vendored = &components[vendor + 1..];
+            return (!vendored.is_empty()).then(|| vendored.join("/"));
+        }
+        let mut import_path = self.path.clone();
+        for component in &components {
+            import_path.push('/');
+            import_path.push_str(component);
+        }
+        Some(import_path)
+    }
+}
+
+/// The path in a go.mod `module` directive, with quotes and trailing comments removed
+pub fn parse_module_path(go_mod: &str) -> Option<String> {
+    go_mod.lines().find_map(|line| {
+        let line = line.split("//").next()?.trim();
+        let path = line.strip_prefix("module")?;
+        if !path.starts_with(char::is_whitespace) {
+            return None;
+        }
+        let path = path.trim().trim_matches(|c| c == '"' || c == '`');
+        (!path.is_empty()).then(|| path.to_string())
+    })
+}
+
+/// The module directories a go.work file's `use` directives list, as written, e.g.
+/// `./svc/a`; both `use ./a` and a `use ( ... )` block are read
+pub fn parse_go_work(go_work: &str) -> Vec<String> {
+    let mut dirs = Vec::new();
+    let mut in_block = false;
+    for line in go_work.lines() {
+        let line = line.split("//").next().unwrap_or_default().trim();
+        let dir = match (in_block, line.strip_prefix("use")) {
+            (true, _) if line == ")" => {
+                in_block = false;
+                continue;
+            }
+            (true, _) => line,
+            (false, Some(rest)) if rest.trim() == "(" => {
+                in_block = true;
+                continue;
+            }
+            (false, Some(rest)) if rest.starts_with(char::is_whitespace) => rest.trim(),
+            _ => continue,
+        };
+        let dir = dir.trim_matches(|c| c == '"' || c == '`');
+        if !dir.is_empty() {
+            dirs.push(dir.to_string());
+        }
+    }
+    dirs
+}
+
+/// The module directories of the go.work in `dir`, joined to `dir`; `None` without one
+pub fn workspace_roots(dir: &Path) -> Option<Vec<PathBuf>> {
+    let go_work = fs::read_to_string(dir.join("go.work")).ok()?;
+    Some(
+        parse_go_work(&go_work)
+            .iter()
+            .map(|used| join_relative(dir, Path::new(used)))
+            .collect(),
+    )
+}
+
+/// `path` under `dir` without the `./` parts, so `./svc/a` in `.` is `./svc/a` rather
+/// than `././svc/a`; an absolute `path` stands alone
+pub fn join_relative(dir: &Path, path: &Path) -> PathBuf {
+    let path: PathBuf = path
+        .components()
+        .filter(|part| !matches!(part, Component::CurDir))
+        .collect();
+    dir.join(path)
+}
+
+/// Whether `go build ./...` skips a directory of this name: `testdata`, `vendor`
+/// (unless vendored code is wanted) and anything starting with `.` or `_`
+pub fn is_ignored_dir(name: &str, include_vendor: bool) -> bool {
+    name == "testdata"
+        || (name == "vendor" && !include_vendor)
+        || name.starts_with('.')
+        || name.starts_with('_')
+}
+
+/// Whether any directory between `root` and the file at `path` is skipped by `./...`
+pub fn in_ignored_dir(root: &Path, path: &Path, include_vendor: bool) -> bool {
+    let relative = path.strip_prefix(root).unwrap_or(path);
+    let Some(parent) = relative.parent() else {
+        return false;
+    };
+    parent.components().any(|component| match component {
+        Component::Normal(name) => is_ignored_dir(&name.to_string_lossy(), include_vendor),
+        _ => false,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_module_path() {
+        assert_eq!(
+            parse_module_path("// comment\nmodule example.com/mymod // trailing\n\ngo 1.21\n"),
+            Some("example.com/mymod".to_string())
+        );
+        assert_eq!(
+            parse_module_path("module \"example.com/quoted\"\n"),
+            Some("example.com/quoted".to_string())
+        );
+        assert_eq!(parse_module_path("go 1.21\n"), None);
+        assert_eq!(parse_module_path("modules x\n"), None);
+    }
+
+    #[test]
+    fn test_parse_go_work() {
+        let go_work = "go 1.22\n\nuse ./tools // linters\n\nuse (\n\t./svc/a\n\t\"./svc/b\"\n)\n";
+        assert_eq!(
+            parse_go_work(go_work),
+            vec!["./tools", "./svc/a", "./svc/b"]
+        );
+        assert!(parse_go_work("go 1.22\nuser ./x\n").is_empty());
+    }
+
+    #[test]
+    fn test_ignored_dirs() {
+        let root = Path::new("/m");
+        assert!(in_ignored_dir(root, Path::new("/m/vendor/x/x.go"), false));
+        assert!(!in_ignored_dir(root, Path::new("/m/vendor/x/x.go"), true));
+        assert!(in_ignored_dir(root, Path::new("/m/a/testdata/t.go"), true));
+        assert!(in_ignored_dir(root, Path::new("/m/_old/x.go"), false));
+        assert!(!in_ignored_dir(root, Path::new("/m/calc/calc.go"), false));
+        // Only directories count, not the file name itself
+        assert!(!in_ignored_dir(root, Path::new("/m/_gen.go"), false));
+    }
+}
@@ -1,7 +1,197 @@
+pub mod discovery;
 pub mod go;
+pub mod go_build;
+pub mod go_module;
 pub mod python;
 pub mod typescript;
 
-pub use go::GoParser;
+pub use discovery::Discovery;
+pub use go::{GoParser, LOW_MEMORY_BATCH};
+pub use go_build::{parse_go_version, BuildContext};
+pub use go_module::GoModule;
 pub use python::PythonParser;
 pub use typescript::{Language, TypeScriptParser};
+
+use crate::core::{CodeGraph, Diagnostic, Position, Span};
+use anyhow::{bail, Context, Result};
+use std::collections::HashSet;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// Diagnostic code for a source file that couldn't be read
+pub const READ_ERROR: &str = "read-error";
+/// Diagnostic code for a source file the parser gave up on
+pub const PARSE_ERROR: &str = "parse-error";
+
+/// Diagnostic code for source the grammar couldn't make sense of
+pub const SYNTAX_ERROR: &str = "syntax-error";
+
+/// Path given to source read from stdin, wherever a file path is reported
+pub const STDIN_PATH: &str = "<stdin>";
+
+/// A language backend. Every backend records symbols as nodes and calls as edges in the
+/// same shapes, so queries and output work alike whatever language a file is in. Calls
+/// are only resolved within a language.
+pub trait LanguageParser {
+    /// The language as `index --language` names it, e.g. `python`
+    fn language(&self) -> &'static str;
+
+    /// Index every file of the language under `dir` and resolve the calls between them
+    fn parse_directory(&mut self, dir: &Path, graph: &mut CodeGraph) -> Result<()>;
+
+    /// Add `source`, as the contents of `file_path`, to `graph`
+    fn parse_source(&mut self, file_path: &Path, source: &str, graph: &mut CodeGraph)
+        -> Result<()>;
+
+    /// Add the file at `file_path` to `graph`
+    fn parse_file(&mut self, file_path: &Path, graph: &mut CodeGraph) -> Result<()> {
+        let source = fs::read_to_string(file_path)
+            .context(format!("Failed to read file: {}", file_path.display()))?;
+        self.parse_source(file_path, &source, graph)
+    }
+
+    /// Index `source` on its own as the contents of `file_path`, which need not exist;
+    /// calls into other files are left unresolved
+    fn index_standalone(&mut self, file_path: &Path, source: &str) -> CodeGraph {
+        let root = file_path.parent().unwrap_or(Path::new(""));
+        let mut graph = CodeGraph::new(
+            root.to_string_lossy().to_string(),
+            self.language().to_string(),
+        );
+        if let Err(e) = self.parse_source(file_path, source, &mut graph) {
+            graph
+                .metadata
+                .diagnostics
+                .push(file_error(PARSE_ERROR, file_path, &e));
+        }
+        graph.metadata.stats.files_parsed = 1;
+        graph.metadata.stats.total_nodes = graph.nodes.len();
+        graph.metadata.stats.total_edges = graph.edges.len();
+        graph
+    }
+}
+
+/// The language of files with `path`'s extension, as `index --language` names it
+pub fn language_of(path: &Path) -> Option<&'static str> {
+    match path.extension()?.to_str()? {
+        "go" => Some("go"),
+        "py" => Some("python"),
+        "ts" | "tsx" => Some("typescript"),
+        "js" | "jsx" => Some("javascript"),
+        _ => None,
+    }
+}
+
+/// The extensions of `language`'s files, by name or file extension as for
+/// [`for_language`]
+pub fn extensions_of(language: &str) -> Result<&'static [&'static str]> {
+    Ok(match language {
+        "go" => &["go"],
+        "python" | "py" => &["py"],
+        "typescript" | "ts" => &["ts", "tsx"],
+        "javascript" | "js" => &["js", "jsx"],
+        _ => bail!("Unsupported language: {}", language),
+    })
+}
+
+/// The languages `paths` are written in, Go first, then Python, TypeScript and JavaScript
+pub fn languages_in(paths: &[PathBuf]) -> Vec<&'static str> {
+    let found: HashSet<&str> = paths.iter().filter_map(|path| language_of(path)).collect();
+    ["go", "python", "typescript", "javascript"]
+        .into_iter()
+        .filter(|language| found.contains(language))
+        .collect()
+}
+
+/// The backend for `language`, by name or file extension: `go`, `python` or `py`,
+/// `typescript` or `ts`, `javascript` or `js`
+pub fn for_language(language: &str) -> Result<Box<dyn LanguageParser>> {
+    Ok(match language {
+        "go" => Box::new(GoParser::new()?),
+        "python" | "py" => Box::new(PythonParser::new()?),
+        "typescript" | "ts" => Box::new(TypeScriptParser::new(Language::TypeScript)?),
+        "javascript" | "js" => Box::new(TypeScriptParser::new(Language::JavaScript)?),
+        _ => bail!("Unsupported language: {}", language),
+    })
+}
+
+/// The backend for the file at `path`, chosen by its extension
+pub fn for_path(path: &Path) -> Result<Box<dyn LanguageParser>> {
+    match language_of(path) {
+        Some(language) => for_language(language),
+        None => bail!(
+            "No parser for {}: expected a .go, .py, .ts or .js file",
+            path.display()
+        ),
+    }
+}
+
+/// Run `op` on a pool of `jobs` threads, which every `parse_directory` inside it parses
+/// files on. `None` or 0 uses one thread per CPU core. Parsers merge per-file results in
+/// path order, so the graph is the same whatever the thread count.
+pub fn with_jobs<R: Send>(jobs: Option<usize>, op: impl FnOnce() -> R + Send) -> Result<R> {
+    let pool = rayon::ThreadPoolBuilder::new()
+        .num_threads(jobs.unwrap_or(0))
+        .build()
+        .context("Failed to start parser threads")?;
+    Ok(pool.install(op))
+}
+
+/// An error diagnostic reporting why the file at `path` couldn't be indexed
+pub(crate) fn file_error(code: &str, path: &Path, error: &anyhow::Error) -> Diagnostic {
+    Diagnostic::error(code, format!("{:#}", error), path.to_path_buf(), 1)
+}
+
+/// The stretch of source a syntax node covers. Tree-sitter counts columns and offsets
+/// in bytes, so they stay exact in files with multi-byte UTF-8.
+pub(crate) fn span(node: tree_sitter::Node) -> Span {
+    Span {
+        start: position(node.start_position(), node.start_byte()),
+        end: position(node.end_position(), node.end_byte()),
+    }
+}
+
+fn position(point: tree_sitter::Point, offset: usize) -> Position {
+    Position {
+        line: point.row + 1,
+        column: point.column + 1,
+        offset,
+    }
+}
+
+/// One diagnostic per syntax error in a parsed tree: a token the parser had to invent
+/// (`missing }`) or a stretch of source it skipped. Skipped stretches are reported once,
+/// at their outermost extent; everything outside them is indexed as usual.
+pub(crate) fn syntax_errors(root: tree_sitter::Node, source: &str, path: &Path) -> Vec<Diagnostic> {
+    let mut diagnostics = Vec::new();
+    let mut pending = vec![root];
+    while let Some(node) = pending.pop() {
+        let message = if node.is_missing() {
+            format!("missing `{}`", node.kind())
+        } else if node.is_error() {
+            let text = source[node.byte_range()].trim();
+            let first_line = text.lines().next().unwrap_or("");
+            match first_line.chars().count() {
+                0 => "unexpected end of input".to_string(),
+                n if n > 40 => format!(
+                    "syntax error near `{}…`",
+                    first_line.chars().take(40).collect::<String>()
+                ),
+                _ => format!("syntax error near `{}`", first_line),
+            }
+        } else {
+            if node.has_error() {
+                let mut cursor = node.walk();
+                let children: Vec<_> = node.children(&mut cursor).collect();
+                pending.extend(children.into_iter().rev());
+            }
+            continue;
+        };
+        let start = node.start_position();
+        diagnostics.push(
+            Diagnostic::error(SYNTAX_ERROR, message, path.to_path_buf(), start.row + 1)
+                .at_column(start.column + 1),
+        );
+    }
+    diagnostics
+}
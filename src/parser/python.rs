@@ -1,11 +1,36 @@
+use super::discovery::Discovery;
+use super::{span, LanguageParser};
 use crate::core::{CodeGraph, Edge, EdgeType, Node, NodeType, Parameter};
 use anyhow::{Context, Result};
-use std::fs;
+use std::collections::{HashMap, HashSet};
 use std::path::Path;
 use tree_sitter::Parser;
 
 pub struct PythonParser {
     parser: Parser,
+    discovery: Discovery,
+}
+
+/// Where a definition sits while walking a file
+#[derive(Debug, Clone, Copy, Default)]
+struct Scope<'a> {
+    /// Qualified name of the class whose body this is, e.g. `Outer.Inner`
+    class: Option<&'a str>,
+    /// Name of the function this is nested in, e.g. `decorator.wrapper`
+    function: Option<&'a str>,
+}
+
+/// A call found in a function, resolved once the whole file's definitions are known
+struct PendingCall {
+    edge: Edge,
+    /// The calling function and the functions it is nested in, innermost first
+    scopes: Vec<String>,
+}
+
+/// Whether `path` is a test module by pytest's naming, `test_*.py` or `*_test.py`
+fn is_test_file(path: &Path) -> bool {
+    let name = path.file_stem().and_then(|s| s.to_str()).unwrap_or("");
+    name.starts_with("test_") || name.ends_with("_test")
 }
 
 impl PythonParser {
@@ -14,22 +39,29 @@ impl PythonParser {
         parser
             .set_language(&tree_sitter_python::LANGUAGE.into())
             .context("Failed to set Python language")?;
-        Ok(Self { parser })
+        Ok(Self {
+            parser,
+            discovery: Discovery::new(),
+        })
+    }
+
+    /// Choose files by `discovery`'s ignore rules instead of the defaults
+    pub fn with_discovery(mut self, discovery: Discovery) -> Self {
+        self.discovery = discovery;
+        self
     }
 
     pub fn parse_directory(&mut self, dir: &Path, graph: &mut CodeGraph) -> Result<()> {
         use rayon::prelude::*;
 
-        // Phase 3: Parallel file discovery with jwalk
-        let file_paths: Vec<_> = jwalk::WalkDir::new(dir)
+        // Discovery returns paths sorted, so results merge in path order
+        let file_paths: Vec<_> = self
+            .discovery
+            .files(dir)
             .into_iter()
-            .filter_map(|e| e.ok())
-            .filter(|e| {
-                e.path().extension().and_then(|s| s.to_str()) == Some("py")
-                    && !e.path().to_string_lossy().contains("_test.py")
-                    && !e.path().to_string_lossy().contains("test_")
+            .filter(|path| {
+                path.extension().and_then(|s| s.to_str()) == Some("py") && !is_test_file(path)
             })
-            .map(|e| e.path())
             .collect();
 
         let dir_str = dir.to_string_lossy().to_string();
@@ -53,7 +85,11 @@ impl PythonParser {
                     };
 
                     if let Err(e) = parser.parse_file(path, &mut chunk_graph) {
-                        eprintln!("Warning: Failed to parse {}: {}", path.display(), e);
+                        chunk_graph.metadata.diagnostics.push(super::file_error(
+                            super::PARSE_ERROR,
+                            path,
+                            &e,
+                        ));
                     }
                 }
 
@@ -73,13 +109,20 @@ impl PythonParser {
         Ok(())
     }
 
-    pub fn parse_file(&mut self, file_path: &Path, graph: &mut CodeGraph) -> Result<()> {
-        let source = fs::read_to_string(file_path)
-            .context(format!("Failed to read file: {}", file_path.display()))?;
-
+    /// Parse `source` as the contents of `file_path`. Calls are resolved within the file:
+    /// a bare name to the function nested in the caller or defined in the module, or the
+    /// class it constructs; `self.method()` to the method of the enclosing class or a
+    /// base class defined in the same file. Calls on modules it imports are recorded
+    /// with the module as `import_path` and left unresolved, as are all other calls.
+    pub fn parse_source(
+        &mut self,
+        file_path: &Path,
+        source: &str,
+        graph: &mut CodeGraph,
+    ) -> Result<()> {
         let tree = self
             .parser
-            .parse(&source, None)
+            .parse(source, None)
             .context("Failed to parse Python file")?;
 
         let root = tree.root_node();
@@ -92,276 +135,523 @@ impl PythonParser {
             .unwrap_or("default")
             .to_string();
 
-        // Walk the tree to extract functions and methods
-        self.walk_tree(root, &source, file_path, &package_name, graph)?;
+        let first_new = graph.nodes.len();
+        let modules = self.imported_modules(root, source);
+        let mut calls = Vec::new();
+        self.walk_tree(
+            root,
+            source,
+            file_path,
+            &package_name,
+            Scope::default(),
+            &modules,
+            graph,
+            &mut calls,
+        );
+
+        let defined: HashMap<&str, &Node> = graph.nodes[first_new..]
+            .iter()
+            .map(|node| (node.name.as_str(), node))
+            .collect();
+        let edges: Vec<Edge> = calls
+            .into_iter()
+            .map(|call| resolve_call(call, &defined))
+            .collect();
+        for edge in edges {
+            graph.add_edge(edge);
+        }
+        graph
+            .metadata
+            .diagnostics
+            .extend(super::syntax_errors(root, source, file_path));
 
         Ok(())
     }
 
+    /// Module names bound by `import` statements, mapped to the module:
+    /// `os.path` for `import os.path as path`, `os` for `import os.path`
+    fn imported_modules(&self, root: tree_sitter::Node, source: &str) -> HashMap<String, String> {
+        let mut modules = HashMap::new();
+        let mut cursor = root.walk();
+        for statement in root.named_children(&mut cursor) {
+            if statement.kind() != "import_statement" {
+                continue;
+            }
+            let mut name_cursor = statement.walk();
+            for name in statement.children_by_field_name("name", &mut name_cursor) {
+                match name.kind() {
+                    "aliased_import" => {
+                        let module = name.child_by_field_name("name");
+                        let alias = name.child_by_field_name("alias");
+                        if let (Some(module), Some(alias)) = (module, alias) {
+                            modules.insert(
+                                source[alias.byte_range()].to_string(),
+                                source[module.byte_range()].to_string(),
+                            );
+                        }
+                    }
+                    _ => {
+                        let module = &source[name.byte_range()];
+                        let first = module.split('.').next().unwrap_or(module);
+                        modules.insert(first.to_string(), first.to_string());
+                    }
+                }
+            }
+        }
+        modules
+    }
+
+    #[allow(clippy::too_many_arguments)]
     fn walk_tree(
         &self,
         node: tree_sitter::Node,
         source: &str,
         file_path: &Path,
         package_name: &str,
+        scope: Scope<'_>,
+        modules: &HashMap<String, String>,
         graph: &mut CodeGraph,
-    ) -> Result<()> {
-        if node.kind() == "function_definition" {
-            // Check if it's inside a class (method) or standalone (function)
-            if self.is_inside_class(node) {
-                self.extract_method(node, source, file_path, package_name, graph)?;
-            } else {
-                self.extract_function(node, source, file_path, package_name, graph)?;
+        calls: &mut Vec<PendingCall>,
+    ) {
+        match node.kind() {
+            "function_definition" => {
+                let Some((from_id, name, scopes)) =
+                    self.extract_function(node, source, file_path, package_name, scope, graph)
+                else {
+                    return;
+                };
+                if let Some(body) = node.child_by_field_name("body") {
+                    self.find_calls(
+                        body, source, file_path, &from_id, scope, &scopes, modules, calls,
+                    );
+                    let inner = Scope {
+                        class: None,
+                        function: Some(&name),
+                    };
+                    self.walk_tree(
+                        body,
+                        source,
+                        file_path,
+                        package_name,
+                        inner,
+                        modules,
+                        graph,
+                        calls,
+                    );
+                }
             }
-        }
-
-        // Recurse into children
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            self.walk_tree(child, source, file_path, package_name, graph)?;
-        }
-
-        Ok(())
-    }
-
-    fn is_inside_class(&self, node: tree_sitter::Node) -> bool {
-        let mut current = node.parent();
-        while let Some(parent) = current {
-            if parent.kind() == "class_definition" {
-                return true;
+            "class_definition" => {
+                let Some(name) =
+                    self.extract_class(node, source, file_path, package_name, scope, graph)
+                else {
+                    return;
+                };
+                if let Some(body) = node.child_by_field_name("body") {
+                    let inner = Scope {
+                        class: Some(&name),
+                        function: scope.function,
+                    };
+                    self.walk_tree(
+                        body,
+                        source,
+                        file_path,
+                        package_name,
+                        inner,
+                        modules,
+                        graph,
+                        calls,
+                    );
+                }
+            }
+            _ => {
+                let mut cursor = node.walk();
+                for child in node.children(&mut cursor) {
+                    self.walk_tree(
+                        child,
+                        source,
+                        file_path,
+                        package_name,
+                        scope,
+                        modules,
+                        graph,
+                        calls,
+                    );
+                }
             }
-            current = parent.parent();
         }
-        false
     }
 
+    /// Add a function or, in a class body, a method; returns its ID, its name and the
+    /// scopes a bare call in it is looked up in
     fn extract_function(
         &self,
         node: tree_sitter::Node,
         source: &str,
         file_path: &Path,
         package_name: &str,
+        scope: Scope<'_>,
         graph: &mut CodeGraph,
-    ) -> Result<()> {
-        let mut func_name = String::new();
-        let mut parameters = Vec::new();
-
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            match child.kind() {
-                "identifier" if func_name.is_empty() => {
-                    func_name = source[child.byte_range()].to_string();
-                }
-                "parameters" => {
-                    parameters = self.extract_parameters(child, source);
-                }
-                _ => {}
+    ) -> Option<(String, String, Vec<String>)> {
+        let name_node = node.child_by_field_name("name")?;
+        let identifier = &source[name_node.byte_range()];
+        let (name, node_type) = match (scope.class, scope.function) {
+            (Some(class), _) => (format!("{}.{}", class, identifier), NodeType::Method),
+            (None, Some(function)) => (format!("{}.{}", function, identifier), NodeType::Function),
+            (None, None) => (identifier.to_string(), NodeType::Function),
+        };
+
+        let line = node.start_position().row + 1;
+        let mut func = Node::new(
+            format!("{}:{}:{}", file_path.display(), name, line),
+            name.clone(),
+            node_type,
+            file_path.to_path_buf(),
+            line,
+            node.end_position().row + 1,
+            package_name.to_string(),
+            header(node, source),
+        );
+        func.parameters = node
+            .child_by_field_name("parameters")
+            .map(|n| self.extract_parameters(n, source))
+            .unwrap_or_default();
+        func.returns = node
+            .child_by_field_name("return_type")
+            .map(|n| vec![source[n.byte_range()].to_string()])
+            .unwrap_or_default();
+        func.documentation = docstring(node, source);
+        func.column = name_node.start_position().column + 1;
+        func.name_span = Some(span(name_node));
+        func.span = Some(span(with_decorators(node)));
+        if let Some(class) = scope.class {
+            func.metadata
+                .insert("method".to_string(), identifier.to_string());
+            for key in ["receiver", "receiver_type"] {
+                func.metadata.insert(key.to_string(), class.to_string());
             }
         }
-
-        if !func_name.is_empty() {
-            let line = node.start_position().row + 1;
-            let end_line = node.end_position().row + 1;
-            let signature = source[node.byte_range()]
-                .lines()
-                .next()
-                .unwrap_or("")
-                .to_string();
-            let id = format!("{}:{}:{}", file_path.display(), func_name, line);
-
-            let mut node_obj = Node::new(
-                id,
-                func_name.clone(),
-                NodeType::Function,
-                file_path.to_path_buf(),
-                line,
-                end_line,
-                package_name.to_string(),
-                signature,
-            );
-            node_obj.parameters = parameters;
-            graph.add_node(node_obj);
-
-            // Extract calls within this function
-            self.extract_calls_in_node(node, source, file_path, &func_name, line, graph)?;
+        if let Some(function) = scope.function.filter(|_| scope.class.is_none()) {
+            func.metadata
+                .insert("enclosing".to_string(), function.to_string());
         }
-
-        Ok(())
+        let id = func.id.clone();
+        graph.add_node(func);
+
+        // A bare call resolves in the caller first, then the functions around it
+        let mut scopes = vec![name.clone()];
+        let mut outer = scope.function.filter(|_| scope.class.is_none());
+        while let Some(function) = outer {
+            scopes.push(function.to_string());
+            outer = function.rsplit_once('.').map(|(outer, _)| outer);
+        }
+        Some((id, name, scopes))
     }
 
-    fn extract_method(
+    /// Add a class; returns its qualified name, `Outer.Inner` for a nested class
+    fn extract_class(
         &self,
         node: tree_sitter::Node,
         source: &str,
         file_path: &Path,
         package_name: &str,
+        scope: Scope<'_>,
         graph: &mut CodeGraph,
-    ) -> Result<()> {
-        let mut method_name = String::new();
-        let mut parameters = Vec::new();
-
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            match child.kind() {
-                "identifier" if method_name.is_empty() => {
-                    method_name = source[child.byte_range()].to_string();
-                }
-                "parameters" => {
-                    parameters = self.extract_parameters(child, source);
-                }
-                _ => {}
-            }
+    ) -> Option<String> {
+        let name_node = node.child_by_field_name("name")?;
+        let identifier = &source[name_node.byte_range()];
+        let name = match (scope.class, scope.function) {
+            (Some(outer), _) | (None, Some(outer)) => format!("{}.{}", outer, identifier),
+            (None, None) => identifier.to_string(),
+        };
+
+        let line = node.start_position().row + 1;
+        let mut class = Node::new(
+            format!("{}:{}:{}", file_path.display(), name, line),
+            name.clone(),
+            NodeType::Class,
+            file_path.to_path_buf(),
+            line,
+            node.end_position().row + 1,
+            package_name.to_string(),
+            header(node, source),
+        );
+        class.documentation = docstring(node, source);
+        class.column = name_node.start_position().column + 1;
+        class.name_span = Some(span(name_node));
+        class.span = Some(span(with_decorators(node)));
+
+        // Keyword arguments such as metaclass=ABCMeta are not bases
+        let bases: Vec<&str> = node
+            .child_by_field_name("superclasses")
+            .map(|list| {
+                let mut cursor = list.walk();
+                list.named_children(&mut cursor)
+                    .filter(|base| matches!(base.kind(), "identifier" | "attribute"))
+                    .map(|base| &source[base.byte_range()])
+                    .collect()
+            })
+            .unwrap_or_default();
+        if !bases.is_empty() {
+            class.metadata.insert("bases".to_string(), bases.join(","));
         }
-
-        if !method_name.is_empty() {
-            let line = node.start_position().row + 1;
-            let end_line = node.end_position().row + 1;
-            let signature = source[node.byte_range()]
-                .lines()
-                .next()
-                .unwrap_or("")
-                .to_string();
-            let id = format!("{}:{}:{}", file_path.display(), method_name, line);
-
-            let mut node_obj = Node::new(
-                id,
-                method_name.clone(),
-                NodeType::Method,
-                file_path.to_path_buf(),
-                line,
-                end_line,
-                package_name.to_string(),
-                signature,
-            );
-            node_obj.parameters = parameters;
-            graph.add_node(node_obj);
-
-            // Extract calls within this method
-            self.extract_calls_in_node(node, source, file_path, &method_name, line, graph)?;
+        if let Some(function) = scope.function.filter(|_| scope.class.is_none()) {
+            class
+                .metadata
+                .insert("enclosing".to_string(), function.to_string());
         }
-
-        Ok(())
+        graph.add_node(class);
+        Some(name)
     }
 
     fn extract_parameters(&self, node: tree_sitter::Node, source: &str) -> Vec<Parameter> {
         let mut parameters = Vec::new();
         let mut cursor = node.walk();
 
-        for child in node.children(&mut cursor) {
-            match child.kind() {
-                "identifier" => {
-                    let name = source[child.byte_range()].to_string();
-                    // Skip 'self' parameter
-                    if name != "self" && name != "cls" {
-                        parameters.push(Parameter {
-                            name,
-                            param_type: "Any".to_string(),
-                        });
-                    }
+        for child in node.named_children(&mut cursor) {
+            let (name, param_type) = match child.kind() {
+                "identifier" | "list_splat_pattern" | "dictionary_splat_pattern" => {
+                    (source[child.byte_range()].to_string(), None)
                 }
-                "typed_parameter" | "default_parameter" => {
-                    let mut param_name = String::new();
-                    let mut param_type = String::new();
-
-                    let mut param_cursor = child.walk();
-                    for param_child in child.children(&mut param_cursor) {
-                        match param_child.kind() {
-                            "identifier" if param_name.is_empty() => {
-                                param_name = source[param_child.byte_range()].to_string();
-                            }
-                            "type" => {
-                                param_type = source[param_child.byte_range()].to_string();
-                            }
-                            _ => {}
-                        }
-                    }
-
-                    if !param_name.is_empty() && param_name != "self" && param_name != "cls" {
-                        parameters.push(Parameter {
-                            name: param_name,
-                            param_type: if param_type.is_empty() {
-                                "Any".to_string()
-                            } else {
-                                param_type
-                            },
-                        });
-                    }
+                "typed_parameter" => {
+                    let mut name_cursor = child.walk();
+                    let name = child
+                        .named_children(&mut name_cursor)
+                        .find(|n| {
+                            matches!(
+                                n.kind(),
+                                "identifier" | "list_splat_pattern" | "dictionary_splat_pattern"
+                            )
+                        })
+                        .map(|n| source[n.byte_range()].to_string())
+                        .unwrap_or_default();
+                    (name, child.child_by_field_name("type"))
                 }
-                _ => {}
+                "default_parameter" | "typed_default_parameter" => (
+                    child
+                        .child_by_field_name("name")
+                        .map(|n| source[n.byte_range()].to_string())
+                        .unwrap_or_default(),
+                    child.child_by_field_name("type"),
+                ),
+                _ => continue,
+            };
+            // The receiver isn't an argument callers pass
+            if name.is_empty() || name == "self" || name == "cls" {
+                continue;
             }
+            parameters.push(Parameter {
+                name,
+                param_type: param_type
+                    .map(|t| source[t.byte_range()].to_string())
+                    .unwrap_or_else(|| "Any".to_string()),
+            });
         }
 
         parameters
     }
 
-    fn extract_calls_in_node(
-        &self,
-        node: tree_sitter::Node,
-        source: &str,
-        file_path: &Path,
-        func_name: &str,
-        func_line: usize,
-        graph: &mut CodeGraph,
-    ) -> Result<()> {
-        self.find_calls(node, source, file_path, func_name, func_line, graph);
-        Ok(())
-    }
-
+    /// Collect the calls made in `node`, leaving out nested functions and classes, which
+    /// make their own
+    #[allow(clippy::too_many_arguments)]
     fn find_calls(
         &self,
         node: tree_sitter::Node,
         source: &str,
         file_path: &Path,
-        func_name: &str,
-        func_line: usize,
-        graph: &mut CodeGraph,
+        from_id: &str,
+        scope: Scope<'_>,
+        scopes: &[String],
+        modules: &HashMap<String, String>,
+        calls: &mut Vec<PendingCall>,
     ) {
+        if matches!(node.kind(), "function_definition" | "class_definition") {
+            return;
+        }
         if node.kind() == "call" {
-            let mut called_func = String::new();
-            let mut cursor = node.walk();
-
-            for child in node.children(&mut cursor) {
-                match child.kind() {
-                    "identifier" => {
-                        called_func = source[child.byte_range()].to_string();
-                    }
-                    "attribute" => {
-                        // For method calls like obj.method()
-                        let mut attr_cursor = child.walk();
-                        for attr_child in child.children(&mut attr_cursor) {
-                            if attr_child.kind() == "identifier" {
-                                // Get the last identifier (the method name)
-                                called_func = source[attr_child.byte_range()].to_string();
+            if let Some(function) = node.child_by_field_name("function") {
+                let target = &source[function.byte_range()];
+                let mut edge = Edge::new(
+                    from_id.to_string(),
+                    target.to_string(),
+                    EdgeType::Calls,
+                    source[node.byte_range()].to_string(),
+                    file_path.to_path_buf(),
+                    node.start_position().row + 1,
+                );
+                edge.column = node.start_position().column + 1;
+
+                if function.kind() == "attribute" {
+                    let object = function.child_by_field_name("object");
+                    let attribute = function.child_by_field_name("attribute");
+                    if let (Some(object), Some(attribute)) = (object, attribute) {
+                        let object = &source[object.byte_range()];
+                        let attribute = &source[attribute.byte_range()];
+                        match (object, scope.class) {
+                            // The enclosing function is a method of `class`
+                            ("self" | "cls", Some(class)) => {
+                                edge.to = attribute.to_string();
+                                edge.metadata
+                                    .insert("receiver_type".to_string(), class.to_string());
+                                edge.metadata
+                                    .insert("method".to_string(), attribute.to_string());
+                            }
+                            _ => {
+                                if let Some(module) = modules.get(object) {
+                                    edge.to = attribute.to_string();
+                                    edge.metadata
+                                        .insert("qualifier".to_string(), object.to_string());
+                                    edge.metadata
+                                        .insert("import_path".to_string(), module.clone());
+                                }
                             }
                         }
                     }
-                    _ => {}
-                }
-            }
-
-            if !called_func.is_empty() {
-                let line = node.start_position().row + 1;
-                let call_site = source[node.byte_range()].to_string();
-                let from_id = format!("{}:{}:{}", file_path.display(), func_name, func_line);
-
-                if graph.get_node_by_id(&from_id).is_some() {
-                    let edge = Edge::new(
-                        from_id,
-                        called_func,
-                        EdgeType::Calls,
-                        call_site,
-                        file_path.to_path_buf(),
-                        line,
-                    );
-                    graph.add_edge(edge);
                 }
+                calls.push(PendingCall {
+                    edge,
+                    scopes: scopes.to_vec(),
+                });
             }
         }
 
-        // Recurse into children
         let mut cursor = node.walk();
         for child in node.children(&mut cursor) {
-            self.find_calls(child, source, file_path, func_name, func_line, graph);
+            self.find_calls(
+                child, source, file_path, from_id, scope, scopes, modules, calls,
+            );
         }
     }
 }
+
+impl LanguageParser for PythonParser {
+    fn language(&self) -> &'static str {
+        "python"
+    }
+
+    fn parse_directory(&mut self, dir: &Path, graph: &mut CodeGraph) -> Result<()> {
+        PythonParser::parse_directory(self, dir, graph)
+    }
+
+    fn parse_source(
+        &mut self,
+        file_path: &Path,
+        source: &str,
+        graph: &mut CodeGraph,
+    ) -> Result<()> {
+        PythonParser::parse_source(self, file_path, source, graph)
+    }
+}
+
+/// Bind `call` to a definition in the file, if it names one
+fn resolve_call(call: PendingCall, defined: &HashMap<&str, &Node>) -> Edge {
+    let PendingCall { mut edge, scopes } = call;
+    let target = match (
+        edge.metadata.get("receiver_type"),
+        edge.metadata.get("method"),
+    ) {
+        (Some(class), Some(method)) => resolve_method(class, method, defined),
+        _ if edge.metadata.contains_key("import_path") || edge.to.contains('.') => None,
+        _ => scopes
+            .iter()
+            .find_map(|scope| {
+                defined
+                    .get(format!("{}.{}", scope, edge.to).as_str())
+                    .filter(|node| node.metadata.get("enclosing") == Some(scope))
+            })
+            .or_else(|| {
+                defined.get(edge.to.as_str()).filter(|node| {
+                    !node.metadata.contains_key("enclosing")
+                        && matches!(node.node_type, NodeType::Function | NodeType::Class)
+                })
+            })
+            .copied(),
+    };
+    if let Some(target) = target {
+        edge.to = target.name.clone();
+        edge.metadata
+            .insert("target_id".to_string(), target.id.clone());
+    }
+    edge
+}
+
+/// The method `class.method` or, failing that, one its bases defined in the file
+/// declare, searched left to right and depth first as Python does for simple hierarchies
+fn resolve_method<'a>(
+    class: &str,
+    method: &str,
+    defined: &HashMap<&str, &'a Node>,
+) -> Option<&'a Node> {
+    let mut pending = vec![class.to_string()];
+    let mut seen = HashSet::new();
+    while let Some(class) = pending.pop() {
+        if !seen.insert(class.clone()) {
+            continue;
+        }
+        let key = format!("{}.{}", class, method);
+        if let Some(&node) = defined
+            .get(key.as_str())
+            .filter(|node| node.node_type == NodeType::Method)
+        {
+            return Some(node);
+        }
+        if let Some(bases) = defined
+            .get(class.as_str())
+            .and_then(|node| node.metadata.get("bases"))
+        {
+            pending.extend(bases.split(',').rev().map(str::to_string));
+        }
+    }
+    None
+}
+
+/// A decorated definition's source starts at its first decorator
+fn with_decorators(node: tree_sitter::Node) -> tree_sitter::Node {
+    node.parent()
+        .filter(|parent| parent.kind() == "decorated_definition")
+        .unwrap_or(node)
+}
+
+/// The declaration up to its body, e.g. `async def fetch(url: str) -> dict`
+fn header(node: tree_sitter::Node, source: &str) -> String {
+    let end = node
+        .child_by_field_name("body")
+        .map_or(node.end_byte(), |body| body.start_byte());
+    let head = source[node.start_byte()..end].trim_end();
+    let head = head.strip_suffix(':').unwrap_or(head);
+    head.split_whitespace().collect::<Vec<_>>().join(" ")
+}
+
+/// The string literal opening a function or class body, without its quotes and with the
+/// indentation its continuation lines share removed
+fn docstring(node: tree_sitter::Node, source: &str) -> Option<String> {
+    let first = node.child_by_field_name("body")?.named_child(0)?;
+    let string = first.named_child(0)?;
+    if first.kind() != "expression_statement" || string.kind() != "string" {
+        return None;
+    }
+    let text = &source[string.byte_range()];
+    let text = text.trim_start_matches(|c: char| c.is_ascii_alphabetic());
+    let quote = ["\"\"\"", "'''", "\"", "'"]
+        .into_iter()
+        .find(|quote| text.starts_with(quote) && text.len() >= 2 * quote.len())?;
+    let body = &text[quote.len()..text.len() - quote.len()];
+
+    let lines: Vec<&str> = body.lines().map(str::trim_end).collect();
+    let indent = lines
+        .iter()
+        .skip(1)
+        .filter(|line| !line.trim().is_empty())
+        .map(|line| line.len() - line.trim_start().len())
+        .min()
+        .unwrap_or(0);
+    let doc: Vec<&str> = lines
+        .iter()
+        .enumerate()
+        .map(|(i, line)| match i {
+            0 => line.trim_start(),
+            _ => line.get(indent..).unwrap_or(line.trim_start()),
+        })
+        .collect();
+    let doc = doc.join("\n").trim().to_string();
+    (!doc.is_empty()).then_some(doc)
+}
@@ -1,12 +1,18 @@
-use crate::core::{CodeGraph, Edge, EdgeType, Node, NodeType, Parameter};
+use super::discovery::Discovery;
+use super::{span, LanguageParser};
+use crate::core::{
+    BindingKind, CodeGraph, Edge, EdgeType, ModuleBinding, Node, NodeType, Parameter,
+};
 use anyhow::{Context, Result};
-use std::fs;
-use std::path::Path;
+use std::collections::{HashMap, HashSet};
+use std::ffi::OsString;
+use std::path::{Component, Path, PathBuf};
 use tree_sitter::Parser;
 
 pub struct TypeScriptParser {
     parser: Parser,
     language: Language,
+    discovery: Discovery,
 }
 
 #[derive(Clone, Copy)]
@@ -15,6 +21,25 @@ pub enum Language {
     JavaScript,
 }
 
+/// Where a definition sits while walking a file
+#[derive(Debug, Clone, Copy, Default)]
+struct Scope<'a> {
+    /// Name of the class whose body this is
+    class: Option<&'a str>,
+    /// Name of the function this is nested in, e.g. `outer.inner`
+    function: Option<&'a str>,
+}
+
+/// A call found in a function, resolved once the whole file's definitions are known
+struct PendingCall {
+    edge: Edge,
+    /// The calling function and the functions it is nested in, innermost first
+    scopes: Vec<String>,
+}
+
+/// Extensions a relative import may leave out, in the order they are tried
+const SCRIPT_EXTENSIONS: [&str; 4] = ["ts", "tsx", "js", "jsx"];
+
 impl TypeScriptParser {
     pub fn new(language: Language) -> Result<Self> {
         let mut parser = Parser::new();
@@ -26,7 +51,17 @@ impl TypeScriptParser {
         parser
             .set_language(&ts_language.into())
             .context("Failed to set TypeScript language")?;
-        Ok(Self { parser, language })
+        Ok(Self {
+            parser,
+            language,
+            discovery: Discovery::new(),
+        })
+    }
+
+    /// Choose files by `discovery`'s ignore rules instead of the defaults
+    pub fn with_discovery(mut self, discovery: Discovery) -> Self {
+        self.discovery = discovery;
+        self
     }
 
     pub fn parse_directory(&mut self, dir: &Path, graph: &mut CodeGraph) -> Result<()> {
@@ -37,20 +72,20 @@ impl TypeScriptParser {
             Language::JavaScript => vec!["js", "jsx"],
         };
 
-        // Phase 3: Parallel file discovery with jwalk
-        let file_paths: Vec<_> = jwalk::WalkDir::new(dir)
+        // Discovery returns paths sorted, so results merge in path order
+        let file_paths: Vec<_> = self
+            .discovery
+            .files(dir)
             .into_iter()
-            .filter_map(|e| e.ok())
-            .filter(|e| {
-                if let Some(ext) = e.path().extension().and_then(|s| s.to_str()) {
+            .filter(|path| {
+                if let Some(ext) = path.extension().and_then(|s| s.to_str()) {
                     extensions.contains(&ext)
-                        && !e.path().to_string_lossy().contains(".test.")
-                        && !e.path().to_string_lossy().contains(".spec.")
+                        && !path.to_string_lossy().contains(".test.")
+                        && !path.to_string_lossy().contains(".spec.")
                 } else {
                     false
                 }
             })
-            .map(|e| e.path())
             .collect();
 
         let language = self.language;
@@ -80,7 +115,11 @@ impl TypeScriptParser {
                     };
 
                     if let Err(e) = parser.parse_file(path, &mut chunk_graph) {
-                        eprintln!("Warning: Failed to parse {}: {}", path.display(), e);
+                        chunk_graph.metadata.diagnostics.push(super::file_error(
+                            super::PARSE_ERROR,
+                            path,
+                            &e,
+                        ));
                     }
                 }
 
@@ -93,6 +132,7 @@ impl TypeScriptParser {
         for chunk_graph in results {
             graph.merge(chunk_graph);
         }
+        Self::resolve_imports(graph);
 
         graph.metadata.stats.files_parsed = files_parsed;
         graph.metadata.stats.total_nodes = graph.nodes.len();
@@ -100,18 +140,26 @@ impl TypeScriptParser {
         Ok(())
     }
 
-    pub fn parse_file(&mut self, file_path: &Path, graph: &mut CodeGraph) -> Result<()> {
-        let source = fs::read_to_string(file_path)
-            .context(format!("Failed to read file: {}", file_path.display()))?;
-
+    /// Parse `source` as the contents of `file_path`. Calls are resolved within the file:
+    /// a bare name to the function nested in the caller or declared in the module, or
+    /// the class `new` constructs; `this.method()` to the method of the enclosing class
+    /// or a base class declared in the same file. Calls of imported names are recorded
+    /// with the module specifier as `import_path`, for [`resolve_imports`](Self::resolve_imports)
+    /// to bind once every file is parsed.
+    pub fn parse_source(
+        &mut self,
+        file_path: &Path,
+        source: &str,
+        graph: &mut CodeGraph,
+    ) -> Result<()> {
         let tree = self
             .parser
-            .parse(&source, None)
+            .parse(source, None)
             .context("Failed to parse TypeScript file")?;
 
         let root = tree.root_node();
 
-        // For TypeScript/JavaScript, we'll use "module" as the default package
+        // For TypeScript/JavaScript, the directory name stands in for the package
         let package_name = file_path
             .parent()
             .and_then(|p| p.file_name())
@@ -119,334 +167,998 @@ impl TypeScriptParser {
             .unwrap_or("default")
             .to_string();
 
-        // Walk the tree to extract functions and methods
-        self.walk_tree(root, &source, file_path, &package_name, graph)?;
+        let first_new = graph.nodes.len();
+        let bindings = module_bindings(root, source, file_path);
+        let imports: HashMap<&str, &ModuleBinding> = bindings
+            .iter()
+            .filter(|binding| binding.kind == BindingKind::Import)
+            .map(|binding| (binding.alias.as_str(), binding))
+            .collect();
+        let mut calls = Vec::new();
+        self.walk_tree(
+            root,
+            source,
+            file_path,
+            &package_name,
+            Scope::default(),
+            &imports,
+            graph,
+            &mut calls,
+        );
+
+        let defined: HashMap<&str, &Node> = graph.nodes[first_new..]
+            .iter()
+            .map(|node| (node.name.as_str(), node))
+            .collect();
+        let edges: Vec<Edge> = calls
+            .into_iter()
+            .map(|call| resolve_call(call, &defined, &imports))
+            .collect();
+        for edge in edges {
+            graph.add_edge(edge);
+        }
+        graph.modules.extend(bindings);
+        graph
+            .metadata
+            .diagnostics
+            .extend(super::syntax_errors(root, source, file_path));
 
         Ok(())
     }
 
+    /// Bind calls of imported names to the exported declarations they name in other
+    /// indexed files, following `export { ... } from` and `export * from` re-exports.
+    /// Only relative specifiers such as `./calculator` are followed, with the extension
+    /// and `/index` optional; calls into packages stay external.
+    pub fn resolve_imports(graph: &mut CodeGraph) {
+        let modules = Modules::new(graph);
+        let resolved: Vec<(usize, Option<(String, String)>)> = graph
+            .edges
+            .iter()
+            .enumerate()
+            .filter(|(_, edge)| is_script(&edge.file_path))
+            .filter_map(|(idx, edge)| {
+                let source = edge.metadata.get("import_path")?;
+                let imported = edge.metadata.get("imported")?;
+                let target = modules
+                    .resolve(&edge.file_path, source)
+                    .and_then(|file| modules.export(file, imported, &mut HashSet::new()))
+                    .map(|node| (node.name.clone(), node.id.clone()));
+                Some((idx, target))
+            })
+            .collect();
+
+        for (idx, target) in resolved {
+            let edge = &mut graph.edges[idx];
+            edge.metadata.remove("target_id");
+            if let Some((name, id)) = target {
+                edge.to = name;
+                edge.metadata.insert("target_id".to_string(), id);
+            }
+        }
+        graph.build_indexes();
+    }
+
+    #[allow(clippy::too_many_arguments)]
     fn walk_tree(
         &self,
         node: tree_sitter::Node,
         source: &str,
         file_path: &Path,
         package_name: &str,
+        scope: Scope<'_>,
+        imports: &HashMap<&str, &ModuleBinding>,
         graph: &mut CodeGraph,
-    ) -> Result<()> {
-        match node.kind() {
-            "function_declaration" | "function" => {
-                self.extract_function(node, source, file_path, package_name, graph)?;
-            }
-            "method_definition" => {
-                self.extract_method(node, source, file_path, package_name, graph)?;
+        calls: &mut Vec<PendingCall>,
+    ) {
+        let walk_children = |node: tree_sitter::Node,
+                             scope: Scope<'_>,
+                             graph: &mut CodeGraph,
+                             calls: &mut Vec<PendingCall>| {
+            let mut cursor = node.walk();
+            for child in node.children(&mut cursor) {
+                self.walk_tree(
+                    child,
+                    source,
+                    file_path,
+                    package_name,
+                    scope,
+                    imports,
+                    graph,
+                    calls,
+                );
             }
-            "arrow_function" => {
-                self.extract_arrow_function(node, source, file_path, package_name, graph)?;
+        };
+
+        match definition(node, source) {
+            Some(Definition::Function {
+                name_node,
+                name,
+                function,
+            }) => {
+                let Some((from_id, name, scopes)) = self.extract_function(
+                    node,
+                    name_node,
+                    name,
+                    function,
+                    source,
+                    file_path,
+                    package_name,
+                    scope,
+                    graph,
+                ) else {
+                    return;
+                };
+                if let Some(body) = function.child_by_field_name("body") {
+                    self.find_calls(
+                        body, source, file_path, &from_id, scope, &scopes, imports, calls,
+                    );
+                    let inner = Scope {
+                        class: None,
+                        function: Some(&name),
+                    };
+                    walk_children(body, inner, graph, calls);
+                }
             }
-            "class_declaration" => {
-                // For classes, we still want to traverse to find methods
+            Some(Definition::Class {
+                name_node,
+                name,
+                class,
+            }) => {
+                let name = self.extract_class(
+                    node,
+                    name_node,
+                    name,
+                    class,
+                    source,
+                    file_path,
+                    package_name,
+                    scope,
+                    graph,
+                );
+                if let Some(body) = class.child_by_field_name("body") {
+                    let inner = Scope {
+                        class: Some(&name),
+                        function: scope.function,
+                    };
+                    walk_children(body, inner, graph, calls);
+                }
             }
-            _ => {}
+            None => walk_children(node, scope, graph, calls),
         }
-
-        // Recurse into children
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            self.walk_tree(child, source, file_path, package_name, graph)?;
-        }
-
-        Ok(())
     }
 
+    /// Add a function or, in a class body, a method; returns its ID, its name and the
+    /// scopes a bare call in it is looked up in
+    #[allow(clippy::too_many_arguments)]
     fn extract_function(
         &self,
         node: tree_sitter::Node,
+        name_node: Option<tree_sitter::Node>,
+        identifier: &str,
+        function: tree_sitter::Node,
         source: &str,
         file_path: &Path,
         package_name: &str,
+        scope: Scope<'_>,
         graph: &mut CodeGraph,
-    ) -> Result<()> {
-        let mut func_name = String::new();
-        let mut parameters = Vec::new();
-
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            match child.kind() {
-                "identifier" if func_name.is_empty() => {
-                    func_name = source[child.byte_range()].to_string();
-                }
-                "formal_parameters" => {
-                    parameters = self.extract_parameters(child, source);
-                }
-                _ => {}
-            }
-        }
-
-        if func_name.is_empty() {
-            func_name = "anonymous".to_string();
-        }
+    ) -> Option<(String, String, Vec<String>)> {
+        let (name, node_type) = match (scope.class, scope.function) {
+            (Some(class), _) => (format!("{}.{}", class, identifier), NodeType::Method),
+            (None, Some(function)) => (format!("{}.{}", function, identifier), NodeType::Function),
+            (None, None) => (identifier.to_string(), NodeType::Function),
+        };
 
         let line = node.start_position().row + 1;
-        let end_line = node.end_position().row + 1;
-        let signature = source[node.byte_range()]
-            .lines()
-            .next()
-            .unwrap_or("")
-            .to_string();
-        let id = format!("{}:{}:{}", file_path.display(), func_name, line);
-
-        let mut node_obj = Node::new(
-            id,
-            func_name.clone(),
-            NodeType::Function,
+        let mut func = Node::new(
+            format!("{}:{}:{}", file_path.display(), name, line),
+            name.clone(),
+            node_type,
             file_path.to_path_buf(),
             line,
-            end_line,
+            node.end_position().row + 1,
             package_name.to_string(),
-            signature,
+            header(declaration(node), function, source),
         );
-        node_obj.parameters = parameters;
-        graph.add_node(node_obj);
-
-        // Extract calls within this function
-        self.extract_calls_in_node(node, source, file_path, &func_name, line, graph)?;
+        func.parameters = function
+            .child_by_field_name("parameters")
+            .map(|n| self.extract_parameters(n, source))
+            .or_else(|| {
+                // `x => x + 1` has a bare identifier for its single parameter
+                function.child_by_field_name("parameter").map(|n| {
+                    vec![Parameter {
+                        name: source[n.byte_range()].to_string(),
+                        param_type: "any".to_string(),
+                    }]
+                })
+            })
+            .unwrap_or_default();
+        func.returns = function
+            .child_by_field_name("return_type")
+            .map(|n| vec![type_text(n, source)])
+            .unwrap_or_default();
+        func.documentation = jsdoc(declaration(node), source);
+        func.column = name_node.unwrap_or(node).start_position().column + 1;
+        func.name_span = name_node.map(span);
+        func.span = Some(span(node));
+        if let Some(class) = scope.class {
+            func.metadata
+                .insert("method".to_string(), identifier.to_string());
+            for key in ["receiver", "receiver_type"] {
+                func.metadata.insert(key.to_string(), class.to_string());
+            }
+        }
+        if let Some(function) = scope.function.filter(|_| scope.class.is_none()) {
+            func.metadata
+                .insert("enclosing".to_string(), function.to_string());
+        }
+        let id = func.id.clone();
+        graph.add_node(func);
 
-        Ok(())
+        // A bare call resolves in the caller first, then the functions around it
+        let mut scopes = vec![name.clone()];
+        let mut outer = scope.function.filter(|_| scope.class.is_none());
+        while let Some(function) = outer {
+            scopes.push(function.to_string());
+            outer = function.rsplit_once('.').map(|(outer, _)| outer);
+        }
+        Some((id, name, scopes))
     }
 
-    fn extract_method(
+    /// Add a class; returns its name, `outer.Inner` for a class declared in a function
+    #[allow(clippy::too_many_arguments)]
+    fn extract_class(
         &self,
         node: tree_sitter::Node,
+        name_node: Option<tree_sitter::Node>,
+        identifier: &str,
+        class: tree_sitter::Node,
         source: &str,
         file_path: &Path,
         package_name: &str,
+        scope: Scope<'_>,
         graph: &mut CodeGraph,
-    ) -> Result<()> {
-        let mut method_name = String::new();
-        let mut parameters = Vec::new();
+    ) -> String {
+        let name = match scope.function {
+            Some(outer) => format!("{}.{}", outer, identifier),
+            None => identifier.to_string(),
+        };
 
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            match child.kind() {
-                "property_identifier" if method_name.is_empty() => {
-                    method_name = source[child.byte_range()].to_string();
-                }
-                "formal_parameters" => {
-                    parameters = self.extract_parameters(child, source);
-                }
-                _ => {}
-            }
+        let line = node.start_position().row + 1;
+        let mut class_node = Node::new(
+            format!("{}:{}:{}", file_path.display(), name, line),
+            name.clone(),
+            NodeType::Class,
+            file_path.to_path_buf(),
+            line,
+            node.end_position().row + 1,
+            package_name.to_string(),
+            header(declaration(node), class, source),
+        );
+        class_node.documentation = jsdoc(declaration(node), source);
+        class_node.column = name_node.unwrap_or(node).start_position().column + 1;
+        class_node.name_span = name_node.map(span);
+        class_node.span = Some(span(node));
+
+        let bases = superclasses(class, source);
+        if !bases.is_empty() {
+            class_node
+                .metadata
+                .insert("bases".to_string(), bases.join(","));
+        }
+        if let Some(function) = scope.function {
+            class_node
+                .metadata
+                .insert("enclosing".to_string(), function.to_string());
         }
+        graph.add_node(class_node);
+        name
+    }
 
-        if !method_name.is_empty() {
-            let line = node.start_position().row + 1;
-            let end_line = node.end_position().row + 1;
-            let signature = source[node.byte_range()]
-                .lines()
-                .next()
-                .unwrap_or("")
-                .to_string();
-            let id = format!("{}:{}:{}", file_path.display(), method_name, line);
-
-            let mut node_obj = Node::new(
-                id,
-                method_name.clone(),
-                NodeType::Method,
-                file_path.to_path_buf(),
-                line,
-                end_line,
-                package_name.to_string(),
-                signature,
-            );
-            node_obj.parameters = parameters;
-            graph.add_node(node_obj);
+    fn extract_parameters(&self, node: tree_sitter::Node, source: &str) -> Vec<Parameter> {
+        let mut parameters = Vec::new();
+        let mut cursor = node.walk();
 
-            // Extract calls within this method
-            self.extract_calls_in_node(node, source, file_path, &method_name, line, graph)?;
+        for child in node.named_children(&mut cursor) {
+            let (pattern, param_type) = match child.kind() {
+                "required_parameter" | "optional_parameter" => (
+                    child.child_by_field_name("pattern"),
+                    child.child_by_field_name("type"),
+                ),
+                // JavaScript parameters are the bare patterns
+                "identifier" | "rest_pattern" | "assignment_pattern" => (Some(child), None),
+                _ => continue,
+            };
+            let Some(pattern) = pattern else {
+                continue;
+            };
+            let pattern = match pattern.kind() {
+                "assignment_pattern" => pattern.child_by_field_name("left").unwrap_or(pattern),
+                _ => pattern,
+            };
+            let name = source[pattern.byte_range()].to_string();
+            // `this: Window` declares the receiver's type, not an argument
+            if name.is_empty() || name == "this" {
+                continue;
+            }
+            parameters.push(Parameter {
+                name,
+                param_type: param_type
+                    .map(|t| type_text(t, source))
+                    .unwrap_or_else(|| "any".to_string()),
+            });
         }
 
-        Ok(())
+        parameters
     }
 
-    fn extract_arrow_function(
+    /// Collect the calls made in `node`, leaving out nested functions and classes, which
+    /// make their own. Calls in anonymous callbacks count as the enclosing function's.
+    #[allow(clippy::too_many_arguments)]
+    fn find_calls(
         &self,
         node: tree_sitter::Node,
         source: &str,
         file_path: &Path,
-        package_name: &str,
-        graph: &mut CodeGraph,
-    ) -> Result<()> {
-        // Try to find if this arrow function is assigned to a variable
-        let parent = node.parent();
-        let mut func_name = String::new();
-
-        if let Some(parent_node) = parent {
-            if parent_node.kind() == "variable_declarator" {
-                let mut cursor = parent_node.walk();
-                for child in parent_node.children(&mut cursor) {
-                    if child.kind() == "identifier" {
-                        func_name = source[child.byte_range()].to_string();
-                        break;
+        from_id: &str,
+        scope: Scope<'_>,
+        scopes: &[String],
+        imports: &HashMap<&str, &ModuleBinding>,
+        calls: &mut Vec<PendingCall>,
+    ) {
+        if definition(node, source).is_some() {
+            return;
+        }
+        let callee = match node.kind() {
+            "call_expression" => node.child_by_field_name("function"),
+            "new_expression" => node.child_by_field_name("constructor"),
+            _ => None,
+        };
+        // `import('./x')` and `super(...)` call no indexed function
+        if let Some(function) = callee.filter(|f| !matches!(f.kind(), "import" | "super")) {
+            let mut edge = Edge::new(
+                from_id.to_string(),
+                source[function.byte_range()].to_string(),
+                EdgeType::Calls,
+                source[node.byte_range()].to_string(),
+                file_path.to_path_buf(),
+                node.start_position().row + 1,
+            );
+            edge.column = node.start_position().column + 1;
+
+            if function.kind() == "member_expression" {
+                let object = function.child_by_field_name("object");
+                let property = function.child_by_field_name("property");
+                if let (Some(object), Some(property)) = (object, property) {
+                    let property = &source[property.byte_range()];
+                    let namespace = imports
+                        .get(&source[object.byte_range()])
+                        .filter(|binding| binding.name == "*");
+                    match (object.kind(), scope.class, namespace) {
+                        // The enclosing function is a method of `class`
+                        ("this", Some(class), _) => {
+                            edge.to = property.to_string();
+                            edge.metadata
+                                .insert("receiver_type".to_string(), class.to_string());
+                            edge.metadata
+                                .insert("method".to_string(), property.to_string());
+                        }
+                        (_, _, Some(binding)) => {
+                            edge.to = property.to_string();
+                            edge.metadata
+                                .insert("qualifier".to_string(), binding.alias.clone());
+                            if let Some(module) = &binding.source {
+                                edge.metadata
+                                    .insert("import_path".to_string(), module.clone());
+                            }
+                            edge.metadata
+                                .insert("imported".to_string(), property.to_string());
+                        }
+                        _ => {}
                     }
                 }
             }
+            calls.push(PendingCall {
+                edge,
+                scopes: scopes.to_vec(),
+            });
         }
 
-        if func_name.is_empty() {
-            // Skip anonymous arrow functions that aren't assigned
-            return Ok(());
-        }
-
-        let mut parameters = Vec::new();
         let mut cursor = node.walk();
         for child in node.children(&mut cursor) {
-            if child.kind() == "formal_parameters" {
-                parameters = self.extract_parameters(child, source);
-            }
+            self.find_calls(
+                child, source, file_path, from_id, scope, scopes, imports, calls,
+            );
         }
+    }
+}
 
-        let line = node.start_position().row + 1;
-        let end_line = node.end_position().row + 1;
-        let signature = source[node.byte_range()]
-            .lines()
-            .next()
-            .unwrap_or("")
-            .to_string();
-        let id = format!("{}:{}:{}", file_path.display(), func_name, line);
+impl LanguageParser for TypeScriptParser {
+    fn language(&self) -> &'static str {
+        match self.language {
+            Language::TypeScript => "typescript",
+            Language::JavaScript => "javascript",
+        }
+    }
 
-        let mut node_obj = Node::new(
-            id,
-            func_name.clone(),
-            NodeType::Function,
-            file_path.to_path_buf(),
-            line,
-            end_line,
-            package_name.to_string(),
-            signature,
-        );
-        node_obj.parameters = parameters;
-        graph.add_node(node_obj);
+    fn parse_directory(&mut self, dir: &Path, graph: &mut CodeGraph) -> Result<()> {
+        TypeScriptParser::parse_directory(self, dir, graph)
+    }
 
-        // Extract calls within this arrow function
-        self.extract_calls_in_node(node, source, file_path, &func_name, line, graph)?;
+    fn parse_source(
+        &mut self,
+        file_path: &Path,
+        source: &str,
+        graph: &mut CodeGraph,
+    ) -> Result<()> {
+        TypeScriptParser::parse_source(self, file_path, source, graph)
+    }
+}
 
-        Ok(())
+/// A declaration that becomes a node, and the function or class node holding its
+/// parameters and body
+enum Definition<'t, 's> {
+    Function {
+        name_node: Option<tree_sitter::Node<'t>>,
+        name: &'s str,
+        function: tree_sitter::Node<'t>,
+    },
+    Class {
+        name_node: Option<tree_sitter::Node<'t>>,
+        name: &'s str,
+        class: tree_sitter::Node<'t>,
+    },
+}
+
+/// What `node` declares, if anything: a function declaration, a method, a class, a
+/// `const` or class field bound to a function or class, or the anonymous function or
+/// class of `export default`, named `default`
+fn definition<'t, 's>(node: tree_sitter::Node<'t>, source: &'s str) -> Option<Definition<'t, 's>> {
+    let name_of = |name: tree_sitter::Node| &source[name.byte_range()];
+    match node.kind() {
+        "function_declaration" | "generator_function_declaration" | "method_definition" => {
+            let name = node.child_by_field_name("name")?;
+            Some(Definition::Function {
+                name_node: Some(name),
+                name: name_of(name),
+                function: node,
+            })
+        }
+        "class_declaration" | "abstract_class_declaration" => {
+            let name = node.child_by_field_name("name")?;
+            Some(Definition::Class {
+                name_node: Some(name),
+                name: name_of(name),
+                class: node,
+            })
+        }
+        "variable_declarator" | "public_field_definition" | "field_definition" => {
+            let name = node
+                .child_by_field_name("name")
+                .or_else(|| node.child_by_field_name("property"))
+                .filter(|name| {
+                    matches!(
+                        name.kind(),
+                        "identifier" | "property_identifier" | "private_property_identifier"
+                    )
+                })?;
+            let value = node.child_by_field_name("value")?;
+            bound_definition(value, Some(name), name_of(name))
+        }
+        "export_statement" => {
+            let value = node.child_by_field_name("value")?;
+            bound_definition(value, None, "default")
+        }
+        _ => None,
     }
+}
 
-    fn extract_parameters(&self, node: tree_sitter::Node, source: &str) -> Vec<Parameter> {
-        let mut parameters = Vec::new();
-        let mut cursor = node.walk();
+/// `value` as a definition named `name`, when it is a function or class expression
+fn bound_definition<'t, 's>(
+    value: tree_sitter::Node<'t>,
+    name_node: Option<tree_sitter::Node<'t>>,
+    name: &'s str,
+) -> Option<Definition<'t, 's>> {
+    match value.kind() {
+        "arrow_function" | "function_expression" | "function" | "generator_function" => {
+            Some(Definition::Function {
+                name_node,
+                name,
+                function: value,
+            })
+        }
+        "class" => Some(Definition::Class {
+            name_node,
+            name,
+            class: value,
+        }),
+        _ => None,
+    }
+}
 
-        for child in node.children(&mut cursor) {
-            match child.kind() {
-                "required_parameter" | "optional_parameter" => {
-                    let mut name = String::new();
-                    let mut param_type = String::new();
-
-                    let mut param_cursor = child.walk();
-                    for param_child in child.children(&mut param_cursor) {
-                        match param_child.kind() {
-                            "identifier" if name.is_empty() => {
-                                name = source[param_child.byte_range()].to_string();
+/// Bind `call` to a definition in the file, or mark it as a call of an imported name
+fn resolve_call(
+    call: PendingCall,
+    defined: &HashMap<&str, &Node>,
+    imports: &HashMap<&str, &ModuleBinding>,
+) -> Edge {
+    let PendingCall { mut edge, scopes } = call;
+    let target = match (
+        edge.metadata.get("receiver_type"),
+        edge.metadata.get("method"),
+    ) {
+        (Some(class), Some(method)) => resolve_method(class, method, defined),
+        _ if edge.metadata.contains_key("import_path") || edge.to.contains('.') => None,
+        _ => scopes
+            .iter()
+            .find_map(|scope| {
+                defined
+                    .get(format!("{}.{}", scope, edge.to).as_str())
+                    .filter(|node| node.metadata.get("enclosing") == Some(scope))
+            })
+            .or_else(|| {
+                defined.get(edge.to.as_str()).filter(|node| {
+                    !node.metadata.contains_key("enclosing")
+                        && matches!(node.node_type, NodeType::Function | NodeType::Class)
+                })
+            })
+            .copied(),
+    };
+    if let Some(target) = target {
+        edge.to = target.name.clone();
+        edge.metadata
+            .insert("target_id".to_string(), target.id.clone());
+    } else if let Some(binding) = imports
+        .get(edge.to.as_str())
+        .filter(|binding| binding.name != "*" && !edge.metadata.contains_key("method"))
+    {
+        if let Some(module) = &binding.source {
+            edge.metadata
+                .insert("import_path".to_string(), module.clone());
+        }
+        edge.metadata
+            .insert("imported".to_string(), binding.name.clone());
+    }
+    edge
+}
+
+/// The method `class.method` or, failing that, one a base class declared in the file
+/// declares, nearest first
+fn resolve_method<'a>(
+    class: &str,
+    method: &str,
+    defined: &HashMap<&str, &'a Node>,
+) -> Option<&'a Node> {
+    let mut class = class.to_string();
+    let mut seen = HashSet::new();
+    while seen.insert(class.clone()) {
+        let key = format!("{}.{}", class, method);
+        if let Some(&node) = defined
+            .get(key.as_str())
+            .filter(|node| node.node_type == NodeType::Method)
+        {
+            return Some(node);
+        }
+        class = defined
+            .get(class.as_str())
+            .and_then(|node| node.metadata.get("bases"))?
+            .clone();
+    }
+    None
+}
+
+/// Every name the file's top-level `import` and `export` statements bind
+fn module_bindings(root: tree_sitter::Node, source: &str, file_path: &Path) -> Vec<ModuleBinding> {
+    let text = |node: tree_sitter::Node| source[node.byte_range()].to_string();
+    let mut bindings = Vec::new();
+    let mut bind = |kind, node: tree_sitter::Node, module: Option<String>, name, alias| {
+        bindings.push(ModuleBinding {
+            kind,
+            source: module,
+            name,
+            alias,
+            file_path: file_path.to_path_buf(),
+            line: node.start_position().row + 1,
+            column: node.start_position().column + 1,
+        });
+    };
+
+    let mut cursor = root.walk();
+    for statement in root.named_children(&mut cursor) {
+        let module = statement
+            .child_by_field_name("source")
+            .map(|s| text(s).trim_matches(|c| c == '"' || c == '\'').to_string());
+        match statement.kind() {
+            "import_statement" => {
+                let mut clause_cursor = statement.walk();
+                let Some(clause) = statement
+                    .named_children(&mut clause_cursor)
+                    .find(|child| child.kind() == "import_clause")
+                else {
+                    // `import './polyfill'` binds nothing
+                    continue;
+                };
+                let mut cursor = clause.walk();
+                for part in clause.named_children(&mut cursor) {
+                    match part.kind() {
+                        "identifier" => bind(
+                            BindingKind::Import,
+                            part,
+                            module.clone(),
+                            "default".into(),
+                            text(part),
+                        ),
+                        "namespace_import" => {
+                            if let Some(local) = part.named_child(0) {
+                                bind(
+                                    BindingKind::Import,
+                                    part,
+                                    module.clone(),
+                                    "*".into(),
+                                    text(local),
+                                );
                             }
-                            "type_annotation" => {
-                                // Extract type from type annotation
-                                let mut type_cursor = param_child.walk();
-                                for type_child in param_child.children(&mut type_cursor) {
-                                    if type_child.kind() != ":" {
-                                        param_type = source[type_child.byte_range()].to_string();
-                                    }
-                                }
+                        }
+                        "named_imports" => {
+                            for (spec, name, alias) in specifiers(part, source) {
+                                bind(BindingKind::Import, spec, module.clone(), name, alias);
                             }
-                            _ => {}
                         }
+                        _ => {}
                     }
-
-                    if !name.is_empty() {
-                        parameters.push(Parameter {
-                            name,
-                            param_type: if param_type.is_empty() {
-                                "any".to_string()
-                            } else {
-                                param_type
-                            },
-                        });
+                }
+            }
+            "export_statement" => {
+                let default = (0..statement.child_count())
+                    .filter_map(|i| statement.child(i))
+                    .any(|child| child.kind() == "default");
+                let mut clause_cursor = statement.walk();
+                let clause = statement
+                    .named_children(&mut clause_cursor)
+                    .find(|child| matches!(child.kind(), "export_clause" | "namespace_export"));
+                match (module.is_some(), clause) {
+                    // `export * as ns from './x'`
+                    (true, Some(ns)) if ns.kind() == "namespace_export" => {
+                        if let Some(alias) = ns.named_child(0) {
+                            let alias = text(alias)
+                                .trim_matches(|c| c == '"' || c == '\'')
+                                .to_string();
+                            bind(
+                                BindingKind::ReExport,
+                                statement,
+                                module.clone(),
+                                "*".into(),
+                                alias,
+                            );
+                        }
+                    }
+                    (is_reexport, Some(clause)) => {
+                        let kind = match is_reexport {
+                            true => BindingKind::ReExport,
+                            false => BindingKind::Export,
+                        };
+                        for (spec, name, alias) in specifiers(clause, source) {
+                            bind(kind, spec, module.clone(), name, alias);
+                        }
+                    }
+                    (true, None) => bind(
+                        BindingKind::ReExport,
+                        statement,
+                        module.clone(),
+                        "*".into(),
+                        "*".into(),
+                    ),
+                    (false, None) => {
+                        for name in exported_names(statement, source) {
+                            let alias = match default {
+                                true => "default".to_string(),
+                                false => name.clone(),
+                            };
+                            bind(BindingKind::Export, statement, None, name, alias);
+                        }
                     }
                 }
-                _ => {}
             }
+            _ => {}
         }
-
-        parameters
     }
+    bindings
+}
 
-    fn extract_calls_in_node(
-        &self,
-        node: tree_sitter::Node,
-        source: &str,
-        file_path: &Path,
-        func_name: &str,
-        func_line: usize,
-        graph: &mut CodeGraph,
-    ) -> Result<()> {
-        self.find_calls(node, source, file_path, func_name, func_line, graph);
-        Ok(())
+/// `(specifier, name, alias)` for each `name as alias` in `{ ... }`, the alias being the
+/// name itself when there is none
+fn specifiers<'t>(
+    clause: tree_sitter::Node<'t>,
+    source: &str,
+) -> Vec<(tree_sitter::Node<'t>, String, String)> {
+    let unquote = |node: tree_sitter::Node| {
+        source[node.byte_range()]
+            .trim_matches(|c| c == '"' || c == '\'')
+            .to_string()
+    };
+    let mut cursor = clause.walk();
+    clause
+        .named_children(&mut cursor)
+        .filter(|spec| matches!(spec.kind(), "import_specifier" | "export_specifier"))
+        .filter_map(|spec| {
+            let name = unquote(spec.child_by_field_name("name")?);
+            let alias = spec
+                .child_by_field_name("alias")
+                .map(unquote)
+                .unwrap_or_else(|| name.clone());
+            Some((spec, name, alias))
+        })
+        .collect()
+}
+
+/// The local names an `export` of a declaration or `export default` binds:
+/// `add` for `export function add`, each variable of `export const a = 1, b = 2`,
+/// `add` for `export default add`, and `default` for an anonymous default export
+fn exported_names(statement: tree_sitter::Node, source: &str) -> Vec<String> {
+    if let Some(declaration) = statement.child_by_field_name("declaration") {
+        if let Some(name) = declaration.child_by_field_name("name") {
+            return vec![source[name.byte_range()].to_string()];
+        }
+        let mut cursor = declaration.walk();
+        return declaration
+            .named_children(&mut cursor)
+            .filter(|child| child.kind() == "variable_declarator")
+            .filter_map(|declarator| declarator.child_by_field_name("name"))
+            .filter(|name| name.kind() == "identifier")
+            .map(|name| source[name.byte_range()].to_string())
+            .collect();
+    }
+    match statement.child_by_field_name("value") {
+        Some(value) if value.kind() == "identifier" => vec![source[value.byte_range()].to_string()],
+        Some(value) => match value.child_by_field_name("name") {
+            // `export default function add() {}` parsed as an expression
+            Some(name) => vec![source[name.byte_range()].to_string()],
+            None => vec!["default".to_string()],
+        },
+        None => Vec::new(),
     }
+}
 
-    fn find_calls(
-        &self,
-        node: tree_sitter::Node,
-        source: &str,
-        file_path: &Path,
-        func_name: &str,
-        func_line: usize,
-        graph: &mut CodeGraph,
-    ) {
-        if node.kind() == "call_expression" {
-            let mut called_func = String::new();
-            let mut cursor = node.walk();
+/// The module graph of an index's TypeScript and JavaScript files
+struct Modules<'g> {
+    /// Indexed files by their path with `.` and `..` worked out
+    files: HashMap<PathBuf, &'g Path>,
+    bindings: HashMap<&'g Path, Vec<&'g ModuleBinding>>,
+    /// Top-level functions and classes by file and name
+    declarations: HashMap<(&'g Path, &'g str), &'g Node>,
+}
 
-            for child in node.children(&mut cursor) {
-                match child.kind() {
-                    "identifier" => {
-                        called_func = source[child.byte_range()].to_string();
-                    }
-                    "member_expression" => {
-                        // For method calls like obj.method()
-                        let mut member_cursor = child.walk();
-                        for member_child in child.children(&mut member_cursor) {
-                            if member_child.kind() == "property_identifier" {
-                                called_func = source[member_child.byte_range()].to_string();
-                            }
-                        }
-                    }
-                    _ => {}
-                }
+impl<'g> Modules<'g> {
+    fn new(graph: &'g CodeGraph) -> Self {
+        let mut modules = Modules {
+            files: HashMap::new(),
+            bindings: HashMap::new(),
+            declarations: HashMap::new(),
+        };
+        for node in graph.nodes.iter().filter(|node| is_script(&node.file_path)) {
+            modules
+                .files
+                .insert(normalize(&node.file_path), &node.file_path);
+            if !node.metadata.contains_key("enclosing")
+                && matches!(node.node_type, NodeType::Function | NodeType::Class)
+            {
+                modules
+                    .declarations
+                    .insert((&node.file_path, &node.name), node);
             }
+        }
+        // A file of re-exports declares nothing
+        for binding in &graph.modules {
+            modules
+                .files
+                .insert(normalize(&binding.file_path), &binding.file_path);
+            modules
+                .bindings
+                .entry(&binding.file_path)
+                .or_default()
+                .push(binding);
+        }
+        modules
+    }
 
-            if !called_func.is_empty() {
-                let line = node.start_position().row + 1;
-                let call_site = source[node.byte_range()].to_string();
-                let from_id = format!("{}:{}:{}", file_path.display(), func_name, func_line);
-
-                if graph.get_node_by_id(&from_id).is_some() {
-                    let edge = Edge::new(
-                        from_id,
-                        called_func,
-                        EdgeType::Calls,
-                        call_site,
-                        file_path.to_path_buf(),
-                        line,
-                    );
-                    graph.add_edge(edge);
+    /// The indexed file `specifier` names from `from`, without package imports
+    fn resolve(&self, from: &Path, specifier: &str) -> Option<&'g Path> {
+        if !(specifier.starts_with("./") || specifier.starts_with("../")) {
+            return None;
+        }
+        let base = normalize(&from.parent()?.join(specifier));
+        let with_extension = |path: &Path, ext: &str| {
+            let mut path = OsString::from(path);
+            path.push(".");
+            path.push(ext);
+            PathBuf::from(path)
+        };
+
+        let mut candidates = vec![base.clone()];
+        // TypeScript sources import each other by the `.js` names they compile to
+        if base
+            .extension()
+            .is_some_and(|ext| ext == "js" || ext == "jsx")
+        {
+            candidates.extend(["ts", "tsx"].map(|ext| base.with_extension(ext)));
+        }
+        candidates.extend(SCRIPT_EXTENSIONS.map(|ext| with_extension(&base, ext)));
+        candidates.extend(SCRIPT_EXTENSIONS.map(|ext| with_extension(&base.join("index"), ext)));
+        candidates
+            .iter()
+            .find_map(|candidate| self.files.get(candidate).copied())
+    }
+
+    /// The declaration `file` exports as `name`, through any imports and re-exports
+    fn export(
+        &self,
+        file: &'g Path,
+        name: &str,
+        seen: &mut HashSet<(&'g Path, String)>,
+    ) -> Option<&'g Node> {
+        if !seen.insert((file, name.to_string())) {
+            return None;
+        }
+        let bindings = self
+            .bindings
+            .get(file)
+            .map(Vec::as_slice)
+            .unwrap_or_default();
+        for binding in bindings.iter().filter(|binding| binding.alias == name) {
+            match binding.kind {
+                BindingKind::Export => return self.local(file, &binding.name, seen),
+                BindingKind::ReExport if binding.name != "*" => {
+                    let module = self.resolve(file, binding.source.as_deref()?)?;
+                    return self.export(module, &binding.name, seen);
                 }
+                _ => {}
             }
         }
+        // `export *` passes on every export but the default
+        if name == "default" {
+            return None;
+        }
+        bindings
+            .iter()
+            .filter(|binding| {
+                binding.kind == BindingKind::ReExport && binding.name == "*" && binding.alias == "*"
+            })
+            .filter_map(|binding| self.resolve(file, binding.source.as_deref()?))
+            .find_map(|module| self.export(module, name, seen))
+    }
 
-        // Recurse into children
-        let mut cursor = node.walk();
-        for child in node.children(&mut cursor) {
-            self.find_calls(child, source, file_path, func_name, func_line, graph);
+    /// The declaration `name` is in `file`: its own, or the one it imports
+    fn local(
+        &self,
+        file: &'g Path,
+        name: &str,
+        seen: &mut HashSet<(&'g Path, String)>,
+    ) -> Option<&'g Node> {
+        if let Some(&node) = self.declarations.get(&(file, name)) {
+            return Some(node);
+        }
+        let import = self.bindings.get(file)?.iter().find(|binding| {
+            binding.kind == BindingKind::Import && binding.alias == name && binding.name != "*"
+        })?;
+        let module = self.resolve(file, import.source.as_deref()?)?;
+        self.export(module, &import.name, seen)
+    }
+}
+
+/// Whether the file at `path` is TypeScript or JavaScript
+fn is_script(path: &Path) -> bool {
+    matches!(super::language_of(path), Some("typescript" | "javascript"))
+}
+
+/// `path` with `.` components dropped and `..` taking off the component before it
+fn normalize(path: &Path) -> PathBuf {
+    let mut normalized = PathBuf::new();
+    for component in path.components() {
+        match component {
+            Component::CurDir => {}
+            Component::ParentDir
+                if matches!(
+                    normalized.components().next_back(),
+                    Some(Component::Normal(_))
+                ) =>
+            {
+                normalized.pop();
+            }
+            other => normalized.push(other),
         }
     }
+    normalized
+}
+
+/// The statement a declaration is written in: the `export` around it, or for a
+/// variable the `const` declaration, which its doc comment precedes
+fn declaration(node: tree_sitter::Node) -> tree_sitter::Node {
+    let mut outer = node;
+    if outer.kind() == "variable_declarator" {
+        outer = outer
+            .parent()
+            .filter(|parent| {
+                matches!(
+                    parent.kind(),
+                    "lexical_declaration" | "variable_declaration"
+                )
+            })
+            .unwrap_or(outer);
+    }
+    outer
+        .parent()
+        .filter(|parent| parent.kind() == "export_statement")
+        .unwrap_or(outer)
+}
+
+/// The declaration up to the body of `function`, e.g.
+/// `export function add(a: number, b: number): number`
+fn header(start: tree_sitter::Node, function: tree_sitter::Node, source: &str) -> String {
+    let end = function
+        .child_by_field_name("body")
+        .map_or(function.end_byte(), |body| body.start_byte());
+    let head = source[start.start_byte()..end.max(start.start_byte())].trim_end();
+    let head = head.strip_suffix("=>").unwrap_or(head).trim_end();
+    head.split_whitespace().collect::<Vec<_>>().join(" ")
+}
+
+/// A type annotation without its leading `:`
+fn type_text(node: tree_sitter::Node, source: &str) -> String {
+    source[node.byte_range()]
+        .trim_start_matches(':')
+        .trim()
+        .to_string()
+}
+
+/// The classes `class` extends, as written
+fn superclasses(class: tree_sitter::Node, source: &str) -> Vec<String> {
+    let mut cursor = class.walk();
+    let Some(heritage) = class
+        .named_children(&mut cursor)
+        .find(|child| child.kind() == "class_heritage")
+    else {
+        return Vec::new();
+    };
+    let mut heritage_cursor = heritage.walk();
+    let children: Vec<_> = heritage.named_children(&mut heritage_cursor).collect();
+    children
+        .into_iter()
+        .flat_map(|clause| match clause.kind() {
+            "extends_clause" => {
+                let mut cursor = clause.walk();
+                clause
+                    .children_by_field_name("value", &mut cursor)
+                    .map(|value| source[value.byte_range()].to_string())
+                    .collect()
+            }
+            // JavaScript's heritage holds the expression directly
+            "implements_clause" => Vec::new(),
+            _ => vec![source[clause.byte_range()].to_string()],
+        })
+        .collect()
+}
+
+/// The `/** ... */` comment right before `node`, without its markers
+fn jsdoc(node: tree_sitter::Node, source: &str) -> Option<String> {
+    let comment = node
+        .prev_sibling()
+        .filter(|prev| prev.kind() == "comment")?;
+    // Only a comment ending on the line before the declaration documents it
+    if comment.end_position().row + 1 < node.start_position().row {
+        return None;
+    }
+    let text = source[comment.byte_range()]
+        .strip_prefix("/**")?
+        .strip_suffix("*/")?;
+    let lines: Vec<&str> = text
+        .lines()
+        .map(|line| {
+            let line = line.trim();
+            line.strip_prefix("* ")
+                .or_else(|| line.strip_prefix('*'))
+                .unwrap_or(line)
+        })
+        .collect();
+    let doc = lines.join("\n").trim().to_string();
+    (!doc.is_empty()).then_some(doc)
 }
@@ -0,0 +1,1378 @@
+//! Stable JSON structures emitted by `--json`.
+//!
+//! Field names here are part of the command-line interface: editors and scripts parse
+//! them, so fields may be added but existing ones are not renamed or removed. Every
+//! command serializes through [`print_json`] so the encoding stays uniform.
+
+use crate::core::{
+    CallSite, CodeGraph, Diagnostic, Edge, GroupTarget, Import, MatchKind, Node, NodeType,
+    SearchMatch, TraceResult,
+};
+use anyhow::Result;
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::io::Write;
+use std::path::Path;
+
+pub use crate::core::{
+    EdgeKind, EntryKind, FieldAccessKind, GroupBy, ImportKind, Position, ReferenceKind, Span,
+    UnresolvedReason,
+};
+
+/// Version of these structures, carried as `schema_version` by every object a command
+/// prints, first with [`Versioned`], and by the envelopes that wrap a list: [`Page`] and
+/// [`IndexSummary`]. A bare list, as 0.4.0 printed them, has none. Bumped when a field is
+/// renamed or removed, which the rules above allow only across versions.
+pub const SCHEMA_VERSION: u32 = 1;
+
+/// A position in a source file. `line` and `column` are 1-based; `column` is 0 when
+/// the index was built before columns were recorded.
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+pub struct Location {
+    /// Path of the file as it was indexed
+    pub file: String,
+    pub line: usize,
+    pub column: usize,
+}
+
+impl Location {
+    pub fn new(file: &Path, line: usize, column: usize) -> Self {
+        Self {
+            file: file.display().to_string(),
+            line,
+            column,
+        }
+    }
+}
+
+/// A function or method definition
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Symbol {
+    /// Graph node ID, stable for a given file, name and line
+    pub id: String,
+    /// Display name; Go methods carry their receiver, e.g. `(*Calculator).Add`
+    pub name: String,
+    /// `function`, `method`, `http_handler`, `middleware`, `benchmark`, `fuzz`, `struct`,
+    /// `interface`, `type`, `const`, `var` or `field`
+    pub kind: NodeType,
+    pub package: String,
+    /// Go import path of the package, e.g. `example.com/mymod/calc`, when indexed from a module
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub import_path: Option<String>,
+    /// Root of a multi-root index the symbol lies in, e.g. `svc/a`; it tells apart
+    /// packages two roots declare under one import path
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub root: Option<String>,
+    /// First line of the declaration
+    pub signature: String,
+    /// Doc comment without comment markers, paragraphs separated by a blank line;
+    /// empty when the symbol has none
+    #[serde(default)]
+    pub doc: String,
+    pub location: Location,
+    pub end_line: usize,
+    /// Where the symbol's name is written
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub name_range: Option<Span>,
+    /// The whole declaration, from its doc comment through the closing brace
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub range: Option<Span>,
+    /// Receiver type for methods, e.g. `*Calculator`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub receiver: Option<String>,
+    /// Go build constraint of the declaring file, e.g. `linux && amd64`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub build: Option<String>,
+    /// Cyclomatic complexity of a function or method body
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub complexity: Option<usize>,
+}
+
+impl From<&Node> for Symbol {
+    fn from(node: &Node) -> Self {
+        Self {
+            id: node.id.clone(),
+            name: node.name.clone(),
+            kind: node.node_type.clone(),
+            package: node.package.clone(),
+            import_path: node.metadata.get("import_path").cloned(),
+            root: node.root().map(str::to_string),
+            signature: node.signature.clone(),
+            doc: node.documentation.clone().unwrap_or_default(),
+            location: Location::new(&node.file_path, node.line, node.column),
+            end_line: node.end_line,
+            name_range: node.name_span,
+            range: node.span,
+            receiver: node.metadata.get("receiver").cloned(),
+            build: node.metadata.get("build").cloned(),
+            complexity: node.complexity(),
+        }
+    }
+}
+
+/// A symbol found by `search`, in ranked order
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SearchResult {
+    #[serde(flatten)]
+    pub symbol: Symbol,
+    /// `exact`, `prefix`, `substring` or `fuzzy`
+    pub match_kind: MatchKind,
+    /// Relevance from 1000 (exact) down to 250
+    pub score: u32,
+}
+
+impl From<&SearchMatch<'_>> for SearchResult {
+    fn from(m: &SearchMatch<'_>) -> Self {
+        Self {
+            symbol: Symbol::from(m.node),
+            match_kind: m.match_kind,
+            score: m.score,
+        }
+    }
+}
+
+/// A type implementing an interface, as reported by `implementations`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Implementation {
+    pub interface: Symbol,
+    /// The struct or named type whose method set covers the interface
+    pub implementer: Symbol,
+    /// Only the pointer type (`*Calculator`) implements the interface
+    pub pointer: bool,
+    /// One entry per interface method, sorted by name
+    pub methods: Vec<MethodMatch>,
+}
+
+/// An interface method and the concrete method that satisfies it
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MethodMatch {
+    /// The method as the interface declares it
+    pub requirement: Symbol,
+    pub method: Symbol,
+    /// Embedded fields the method is promoted through, outermost first
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub via: Vec<String>,
+}
+
+impl From<&crate::core::Implementation<'_>> for Implementation {
+    fn from(implementation: &crate::core::Implementation<'_>) -> Self {
+        Self {
+            interface: Symbol::from(implementation.interface),
+            implementer: Symbol::from(implementation.implementer),
+            pointer: implementation.pointer,
+            methods: implementation
+                .methods
+                .iter()
+                .map(|m| MethodMatch {
+                    requirement: Symbol::from(m.requirement),
+                    method: Symbol::from(m.method),
+                    via: m.via.clone(),
+                })
+                .collect(),
+        }
+    }
+}
+
+/// A concrete method and the interface method it satisfies, as reported by
+/// `method-implementations`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MethodImplementation {
+    /// The method as the interface declares it
+    pub requirement: Symbol,
+    pub method: Symbol,
+    /// The interface holding the requirement, possibly through an embedded interface
+    pub interface: Symbol,
+    /// The type implementing the interface with the method
+    pub implementer: Symbol,
+    /// Only the pointer type (`*Calculator`) implements the interface
+    pub pointer: bool,
+    /// Embedded fields the method is promoted through, outermost first
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub via: Vec<String>,
+}
+
+impl From<&crate::core::MethodImplementation<'_>> for MethodImplementation {
+    fn from(implementation: &crate::core::MethodImplementation<'_>) -> Self {
+        Self {
+            requirement: Symbol::from(implementation.requirement),
+            method: Symbol::from(implementation.method),
+            interface: Symbol::from(implementation.interface),
+            implementer: Symbol::from(implementation.implementer),
+            pointer: implementation.pointer,
+            via: implementation.via.clone(),
+        }
+    }
+}
+
+/// A type and the types it embeds, as reported by `hierarchy`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct HierarchyType {
+    /// The type as its embedder writes it, without the `*`
+    pub name: String,
+    /// The type's declaration; absent for a type outside the index
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub symbol: Option<Symbol>,
+    /// Embedded through a pointer
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub pointer: bool,
+    /// Embedded from outside the index, so what it embeds is unknown
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub external: bool,
+    /// Already on the branch above; its embeds are not repeated
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub cycle: bool,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub embeds: Vec<HierarchyType>,
+}
+
+impl From<&crate::core::HierarchyEntry<'_>> for HierarchyType {
+    fn from(entry: &crate::core::HierarchyEntry<'_>) -> Self {
+        Self {
+            name: entry.name.clone(),
+            symbol: entry.node.map(Symbol::from),
+            pointer: entry.pointer,
+            external: entry.is_external(),
+            cycle: entry.cycle,
+            embeds: entry.embeds.iter().map(HierarchyType::from).collect(),
+        }
+    }
+}
+
+/// What a type is built from and the interfaces around it, as emitted by `hierarchy`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Hierarchy {
+    #[serde(rename = "type")]
+    pub root: HierarchyType,
+    /// The interfaces a type satisfies, or the types implementing an interface
+    pub implementations: Vec<Implementation>,
+}
+
+impl From<&crate::core::TypeHierarchy<'_>> for Hierarchy {
+    fn from(hierarchy: &crate::core::TypeHierarchy<'_>) -> Self {
+        Self {
+            root: HierarchyType::from(&hierarchy.root),
+            implementations: hierarchy
+                .implementations
+                .iter()
+                .map(Implementation::from)
+                .collect(),
+        }
+    }
+}
+
+/// One call from `caller` to `callee`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CallEdge {
+    /// ID of the calling function or method
+    pub caller: String,
+    /// Name of the called function as resolved, e.g. `PrintMessage` or `(*Calculator).Add`
+    pub callee: String,
+    /// ID of the called definition; absent for external or unresolved calls
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub callee_id: Option<String>,
+    /// Position of the call expression
+    pub location: Location,
+    /// Distance from the traced function; 1 for its direct calls
+    pub depth: usize,
+    /// A possible call through a function-typed parameter, not written in the source
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub indirect: bool,
+    /// A possible call of an implementation through an interface method
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub dynamic: bool,
+    /// `normal`, or `go` / `defer` for calls made by those statements
+    #[serde(default)]
+    pub kind: EdgeKind,
+    /// Embedded fields a promoted method is reached through, outermost first
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub promoted_via: Vec<String>,
+    /// Unexported functions left out between caller and callee with `--exported-only`,
+    /// in call order
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub hidden_via: Vec<String>,
+}
+
+impl From<&TraceResult> for CallEdge {
+    fn from(trace: &TraceResult) -> Self {
+        Self {
+            caller: trace.from_id.clone(),
+            callee: trace.to_name.clone(),
+            callee_id: trace.to_id.clone(),
+            location: Location::new(&trace.file_path, trace.line, trace.column),
+            // Trace depths start at 0 for the starting function's own calls
+            depth: trace.depth + 1,
+            indirect: trace.indirect,
+            dynamic: trace.dynamic,
+            kind: trace.call_kind,
+            promoted_via: trace.promoted_via.clone(),
+            hidden_via: trace.hidden_via.clone(),
+        }
+    }
+}
+
+impl From<&Edge> for CallEdge {
+    fn from(edge: &Edge) -> Self {
+        Self {
+            caller: edge.from.clone(),
+            callee: edge.to.clone(),
+            callee_id: edge.metadata.get("target_id").cloned(),
+            location: Location::new(&edge.file_path, edge.line, edge.column),
+            depth: 1,
+            indirect: edge.is_indirect(),
+            dynamic: edge.is_dynamic(),
+            kind: edge.kind(),
+            promoted_via: edge.promoted_via(),
+            hidden_via: edge.hidden_via(),
+        }
+    }
+}
+
+/// Everything a function may end up calling, as reported by `callees`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Callees {
+    pub root: Symbol,
+    /// Nearest first
+    pub callees: Vec<Callee>,
+    /// Every call found on the way, with `--with-edges`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub calls: Option<Vec<CallEdge>>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Callee {
+    /// As resolved, e.g. `(*Calculator).Add`, or as written for code outside the index,
+    /// e.g. `fmt.Println`
+    pub name: String,
+    /// Absent for code outside the index
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub symbol: Option<Symbol>,
+    /// Fewest calls from the root to it; 1 for the root's own calls
+    pub depth: usize,
+    /// Name of the function whose call first reached it
+    pub via: String,
+    /// Position of that call
+    pub location: Location,
+}
+
+impl Callees {
+    pub fn new(closure: &crate::core::CalleeClosure<'_>, with_edges: bool) -> Self {
+        let call_edge = |call: &crate::core::CalleeCall<'_>| CallEdge {
+            depth: call.depth,
+            ..CallEdge::from(call.hop.call)
+        };
+        Self {
+            root: Symbol::from(closure.root),
+            callees: closure
+                .reached
+                .iter()
+                .map(|call| Callee {
+                    name: call.hop.callee_name(),
+                    symbol: call.hop.callee.map(Symbol::from),
+                    depth: call.depth,
+                    via: call.caller.name.clone(),
+                    location: Location::new(
+                        &call.hop.call.file_path,
+                        call.hop.call.line,
+                        call.hop.call.column,
+                    ),
+                })
+                .collect(),
+            calls: with_edges.then(|| closure.calls.iter().map(call_edge).collect()),
+        }
+    }
+}
+
+/// A chain of calls, as reported by `path`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CallPath {
+    /// Names from the starting function to the target, e.g. `["main", "Greet", "PrintMessage"]`
+    pub symbols: Vec<String>,
+    /// One call per hop, with the position of the call expression
+    pub calls: Vec<CallEdge>,
+}
+
+impl From<&crate::core::CallPath<'_>> for CallPath {
+    fn from(path: &crate::core::CallPath<'_>) -> Self {
+        Self {
+            symbols: path.names(),
+            calls: path
+                .hops
+                .iter()
+                .enumerate()
+                .map(|(i, hop)| CallEdge {
+                    callee: hop.callee_name(),
+                    callee_id: hop.callee.map(|node| node.id.clone()),
+                    depth: i + 1,
+                    ..CallEdge::from(hop.call)
+                })
+                .collect(),
+        }
+    }
+}
+
+/// Functions calling each other in a loop, as reported by `cycles`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Cycle {
+    /// Members in call order, starting from the first in source order
+    pub symbols: Vec<Symbol>,
+    /// The calls between members
+    pub calls: Vec<CallEdge>,
+    /// A single function calling itself
+    pub self_recursive: bool,
+}
+
+impl From<&crate::core::Cycle<'_>> for Cycle {
+    fn from(cycle: &crate::core::Cycle<'_>) -> Self {
+        Self {
+            symbols: cycle.nodes.iter().map(|&node| Symbol::from(node)).collect(),
+            calls: cycle
+                .calls
+                .iter()
+                .map(|&edge| CallEdge::from(edge))
+                .collect(),
+            self_recursive: cycle.self_recursive,
+        }
+    }
+}
+
+/// One import spec in a package, as reported by `imports`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ImportEdge {
+    /// Importing package: its import path, or its directory when outside a module
+    pub package: String,
+    /// Imported path as written, e.g. `example.com/mymod/calc`
+    pub path: String,
+    /// Name the spec gives the package, including `.` and `_`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub alias: Option<String>,
+    /// `normal`, `alias`, `dot` or `blank`
+    pub kind: ImportKind,
+    /// Whether the imported package is in the importer's module
+    pub internal: bool,
+    pub location: Location,
+}
+
+impl ImportEdge {
+    pub fn new(graph: &CodeGraph, import: &Import) -> Self {
+        Self {
+            package: graph.importing_package(import),
+            path: import.path.clone(),
+            alias: import.alias.clone(),
+            kind: import.kind(),
+            internal: import.internal,
+            location: Location::new(&import.file_path, import.line, import.column),
+        }
+    }
+}
+
+/// Packages importing each other in a loop, as reported by `imports --cycles`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ImportCycle {
+    /// Members in import order, starting from the first by name
+    pub packages: Vec<String>,
+    /// The imports between members
+    pub imports: Vec<ImportEdge>,
+}
+
+impl ImportCycle {
+    pub fn new(graph: &CodeGraph, cycle: &crate::core::ImportCycle<'_>) -> Self {
+        Self {
+            packages: cycle.packages.clone(),
+            imports: cycle
+                .imports
+                .iter()
+                .map(|&import| ImportEdge::new(graph, import))
+                .collect(),
+        }
+    }
+}
+
+/// A declaration in a file outline, as reported by `outline`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct OutlineItem {
+    #[serde(flatten)]
+    pub symbol: Symbol,
+    /// Fields and methods of a type, in source order
+    pub children: Vec<OutlineItem>,
+}
+
+impl From<&crate::core::OutlineEntry<'_>> for OutlineItem {
+    fn from(entry: &crate::core::OutlineEntry<'_>) -> Self {
+        Self {
+            symbol: Symbol::from(entry.node),
+            children: entry.children.iter().map(OutlineItem::from).collect(),
+        }
+    }
+}
+
+/// A use of a symbol inside another function
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Reference {
+    /// Name of the referenced symbol
+    pub symbol: String,
+    /// ID of the referenced definition; absent when it could not be resolved
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub symbol_id: Option<String>,
+    pub kind: ReferenceKind,
+    /// ID of the function or method containing the reference
+    pub from: String,
+    /// Name of the function or method containing the reference
+    pub from_name: String,
+    pub location: Location,
+    /// 1 for direct references, n for transitive callers n calls away
+    pub depth: usize,
+    /// A possible call through a function-typed parameter, not written in the source
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub indirect: bool,
+    /// A possible call of an implementation through an interface method
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub dynamic: bool,
+    /// `go` or `defer` for calls made by those statements; omitted for other references
+    #[serde(default, skip_serializing_if = "EdgeKind::is_normal")]
+    pub call_kind: EdgeKind,
+}
+
+impl From<&CallSite> for Reference {
+    fn from(site: &CallSite) -> Self {
+        Self {
+            symbol: site.callee.clone(),
+            symbol_id: site.callee_id.clone(),
+            kind: site.kind,
+            from: site.caller_id.clone(),
+            from_name: site.caller.clone(),
+            location: Location::new(&site.file_path, site.line, site.column),
+            depth: site.depth,
+            indirect: site.indirect,
+            dynamic: site.dynamic,
+            call_kind: site.call_kind,
+        }
+    }
+}
+
+/// A read, write or composite-literal initialization of a struct field
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FieldAccess {
+    /// Field name, e.g. `Calculator.name`
+    pub field: String,
+    pub field_id: String,
+    pub kind: FieldAccessKind,
+    /// Name of the function or method containing the access
+    pub function: String,
+    pub function_id: String,
+    pub location: Location,
+}
+
+impl From<&crate::core::FieldAccess<'_>> for FieldAccess {
+    fn from(access: &crate::core::FieldAccess<'_>) -> Self {
+        let reference = access.reference;
+        Self {
+            field: access.field.name.clone(),
+            field_id: access.field.id.clone(),
+            kind: access.kind,
+            function: access
+                .function
+                .map(|function| function.name.clone())
+                .unwrap_or_else(|| reference.from.clone()),
+            function_id: reference.from.clone(),
+            location: Location::new(&reference.file_path, reference.line, reference.column),
+        }
+    }
+}
+
+/// A test reaching a function, as reported by `coverage-map`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TestCoverage {
+    pub test: Symbol,
+    /// The function literal in the test the calls leave from, e.g. a `b.Run` callback
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub closure: Option<Symbol>,
+    /// Calls between the test and the function; 1 when the test calls it directly
+    pub depth: usize,
+    /// The shortest chain of calls from the test to the function
+    pub path: CallPath,
+}
+
+impl From<&crate::core::TestCoverage<'_>> for TestCoverage {
+    fn from(coverage: &crate::core::TestCoverage<'_>) -> Self {
+        Self {
+            test: Symbol::from(coverage.test),
+            closure: coverage.closure.map(Symbol::from),
+            depth: coverage.path.hops.len(),
+            path: CallPath::from(&coverage.path),
+        }
+    }
+}
+
+/// A benchmark or fuzz target reaching a function, as reported by `benchmarks`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BenchmarkCoverage {
+    pub benchmark: Symbol,
+    /// The `b.Run` or `f.Fuzz` callback the calls leave from
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub closure: Option<Symbol>,
+    /// Calls between the benchmark and the function; 1 when it calls it directly
+    pub depth: usize,
+    /// The shortest chain of calls from the benchmark to the function
+    pub path: CallPath,
+}
+
+impl From<&crate::core::TestCoverage<'_>> for BenchmarkCoverage {
+    fn from(coverage: &crate::core::TestCoverage<'_>) -> Self {
+        Self {
+            benchmark: Symbol::from(coverage.test),
+            closure: coverage.closure.map(Symbol::from),
+            depth: coverage.path.hops.len(),
+            path: CallPath::from(&coverage.path),
+        }
+    }
+}
+
+/// Call metrics of a function, as reported by `metrics`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FunctionMetrics {
+    pub symbol: Symbol,
+    /// Distinct functions calling it directly
+    pub fan_in: usize,
+    /// Distinct functions it calls directly, those outside the index included
+    pub fan_out: usize,
+    /// Distinct indexed functions reachable through its calls, itself excluded
+    pub reachable: usize,
+    /// Lines from the declaration through its closing brace
+    pub lines: usize,
+}
+
+impl From<&crate::core::FunctionMetrics<'_>> for FunctionMetrics {
+    fn from(metrics: &crate::core::FunctionMetrics<'_>) -> Self {
+        Self {
+            symbol: Symbol::from(metrics.node),
+            fan_in: metrics.fan_in,
+            fan_out: metrics.fan_out,
+            reachable: metrics.reachable,
+            lines: metrics.lines,
+        }
+    }
+}
+
+/// What an index holds, as reported by `stats`
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct IndexStats {
+    pub root: String,
+    pub generated_at: String,
+    pub files: usize,
+    pub packages: usize,
+    pub functions: usize,
+    pub methods: usize,
+    pub types: usize,
+    pub calls: CallCounts,
+    /// Calls as written that are bound to no indexed symbol
+    pub unresolved_calls: usize,
+    /// Diagnostics by code, e.g. `{"parse-error": 1}`
+    pub diagnostics: BTreeMap<String, usize>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub index_duration_ms: Option<u64>,
+    /// Present when the index was built with the per-file cache
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub cache: Option<CacheUse>,
+}
+
+/// Call edges by how they were found (`static`, `indirect`, `dynamic`, adding up to
+/// `total`) and how they run (`go`, `defer`)
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CallCounts {
+    pub total: usize,
+    #[serde(rename = "static")]
+    pub direct: usize,
+    pub indirect: usize,
+    pub dynamic: usize,
+    pub go: usize,
+    pub defer: usize,
+}
+
+/// Files the per-file cache served and parsed, and the share served
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct CacheUse {
+    pub hits: usize,
+    pub misses: usize,
+    pub hit_rate: f64,
+}
+
+impl IndexStats {
+    pub fn new(graph: &CodeGraph, stats: &crate::core::IndexStats) -> Self {
+        let calls = &stats.calls;
+        Self {
+            root: graph.metadata.root_path.clone(),
+            generated_at: graph.metadata.generated_at.clone(),
+            files: stats.files,
+            packages: stats.packages,
+            functions: stats.functions,
+            methods: stats.methods,
+            types: stats.types,
+            calls: CallCounts {
+                total: calls.total,
+                direct: calls.direct,
+                indirect: calls.indirect,
+                dynamic: calls.dynamic,
+                go: calls.go,
+                defer: calls.defer,
+            },
+            unresolved_calls: stats.unresolved_calls,
+            diagnostics: stats.diagnostics.clone(),
+            index_duration_ms: stats.index_duration_ms,
+            cache: stats.cache.map(|cache| CacheUse {
+                hits: cache.hits,
+                misses: cache.misses,
+                hit_rate: stats.cache_hit_rate().unwrap_or(0.0),
+            }),
+        }
+    }
+}
+
+/// A symbol's declaration as printed by `source`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Source {
+    pub symbol: Symbol,
+    /// The file's bytes in `range`, from the doc comment through the closing brace
+    pub text: String,
+    pub range: Span,
+    /// `--context` lines before `text`, from the start of their first line
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub before: String,
+    /// `--context` lines after `text`, through the end of their last line
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub after: String,
+    /// Line `before`, or `text` without context, starts on
+    pub start_line: usize,
+}
+
+impl From<&crate::core::SourceSnippet<'_>> for Source {
+    fn from(snippet: &crate::core::SourceSnippet<'_>) -> Self {
+        Self {
+            symbol: Symbol::from(snippet.node),
+            text: snippet.text.clone(),
+            range: snippet.range,
+            before: snippet.before.clone(),
+            after: snippet.after.clone(),
+            start_line: snippet.start_line,
+        }
+    }
+}
+
+/// The commit a symbol's lines were last changed in, as reported by `blame`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BlameCommit {
+    /// Full hash, or `uncommitted` for a line edited in the work tree
+    pub commit: String,
+    pub author: String,
+    /// Author date in RFC 3339, in the author's time zone
+    pub date: String,
+    pub summary: String,
+}
+
+impl From<&crate::blame::Commit> for BlameCommit {
+    fn from(commit: &crate::blame::Commit) -> Self {
+        Self {
+            commit: commit.hash.as_deref().unwrap_or("uncommitted").to_string(),
+            author: commit.author.clone(),
+            date: commit.date.to_rfc3339(),
+            summary: commit.summary.clone(),
+        }
+    }
+}
+
+/// `git blame` over one symbol's declaration, as emitted by `blame`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Blame {
+    pub symbol: Symbol,
+    /// The newest commit among the declaration's lines; absent with `unavailable`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub last_modified: Option<BlameCommit>,
+    /// 1-based lines blamed, inclusive
+    #[serde(default)]
+    pub start_line: usize,
+    #[serde(default)]
+    pub end_line: usize,
+    /// Lines edited in the work tree and not committed
+    #[serde(default)]
+    pub uncommitted_lines: usize,
+    /// Why git could not blame the file, e.g. it is outside a git work tree
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub unavailable: Option<String>,
+}
+
+impl Blame {
+    pub fn new(
+        node: &Node,
+        blame: &std::result::Result<crate::blame::SymbolBlame<'_>, String>,
+    ) -> Self {
+        match blame {
+            Ok(blame) => Self {
+                symbol: Symbol::from(node),
+                last_modified: Some(BlameCommit::from(&blame.last_modified)),
+                start_line: blame.start_line,
+                end_line: blame.end_line,
+                uncommitted_lines: blame.uncommitted,
+                unavailable: None,
+            },
+            Err(reason) => Self {
+                symbol: Symbol::from(node),
+                last_modified: None,
+                start_line: 0,
+                end_line: 0,
+                uncommitted_lines: 0,
+                unavailable: Some(reason.clone()),
+            },
+        }
+    }
+}
+
+/// The unresolved uses of one name, as reported by `unresolved`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct UnresolvedName {
+    /// The name as written, qualified, e.g. `fmt.Println`
+    pub name: String,
+    pub count: usize,
+    /// The first uses by file and position, up to `--examples`
+    pub examples: Vec<Location>,
+}
+
+/// Unresolved calls and references sharing a reason, as emitted by `unresolved`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct UnresolvedBucket {
+    pub reason: UnresolvedReason,
+    pub count: usize,
+    /// Most used first, ties by name
+    pub names: Vec<UnresolvedName>,
+}
+
+impl UnresolvedBucket {
+    /// `bucket` with at most `examples` locations per name
+    pub fn new(bucket: &crate::core::UnresolvedBucket<'_>, examples: usize) -> Self {
+        Self {
+            reason: bucket.reason,
+            count: bucket.count(),
+            names: bucket
+                .names
+                .iter()
+                .map(|name| UnresolvedName {
+                    name: name.name.clone(),
+                    count: name.uses.len(),
+                    examples: name
+                        .uses
+                        .iter()
+                        .take(examples)
+                        .map(|edge| Location::new(&edge.file_path, edge.line, edge.column))
+                        .collect(),
+                })
+                .collect(),
+        }
+    }
+}
+
+/// The entry points of one package, as listed by `entrypoints`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PackageEntryPoints {
+    /// Import path, or the package name outside a module
+    pub package: String,
+    /// Directory of the package as indexed
+    pub dir: String,
+    /// In declaration order
+    pub entry_points: Vec<EntryPoint>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct EntryPoint {
+    /// `main`, `init` or `exported`
+    pub kind: EntryKind,
+    pub symbol: Symbol,
+}
+
+impl From<&crate::core::PackageEntryPoints<'_>> for PackageEntryPoints {
+    fn from(package: &crate::core::PackageEntryPoints<'_>) -> Self {
+        Self {
+            package: package.package.clone(),
+            dir: package.dir.display().to_string(),
+            entry_points: package
+                .entry_points
+                .iter()
+                .map(|entry| EntryPoint {
+                    kind: entry.kind,
+                    symbol: Symbol::from(entry.node),
+                })
+                .collect(),
+        }
+    }
+}
+
+/// Edits and collisions for renaming a symbol, as reported by `rename --dry-run`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct RenameReport {
+    pub symbol: String,
+    pub symbol_id: String,
+    pub new_name: String,
+    /// Edits by file, in file order; nothing has been written
+    pub files: Vec<FileEdits>,
+    /// Symbols already named `new_name` in the same scope; the rename is unsafe unless empty
+    pub conflicts: Vec<RenameConflict>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FileEdits {
+    pub file: String,
+    pub edits: Vec<TextEdit>,
+}
+
+/// Replace `old_text` with `new_text` starting at a 1-based line and column
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TextEdit {
+    pub line: usize,
+    pub column: usize,
+    pub old_text: String,
+    pub new_text: String,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct RenameConflict {
+    /// The existing symbol, e.g. `Multiply` or `(*Calculator).Add`
+    pub symbol: String,
+    pub symbol_id: String,
+    /// `package <name>` or `type <name>`
+    pub scope: String,
+    pub location: Location,
+}
+
+impl From<&crate::core::RenamePlan<'_>> for RenameReport {
+    fn from(plan: &crate::core::RenamePlan<'_>) -> Self {
+        Self {
+            symbol: plan.symbol.name.clone(),
+            symbol_id: plan.symbol.id.clone(),
+            new_name: plan.new_name.clone(),
+            files: plan
+                .files()
+                .into_iter()
+                .map(|(file, edits)| FileEdits {
+                    file: file.display().to_string(),
+                    edits: edits
+                        .into_iter()
+                        .map(|edit| TextEdit {
+                            line: edit.line,
+                            column: edit.column,
+                            old_text: edit.old_text.clone(),
+                            new_text: edit.new_text.clone(),
+                        })
+                        .collect(),
+                })
+                .collect(),
+            conflicts: plan
+                .conflicts
+                .iter()
+                .map(|conflict| {
+                    let existing = conflict.existing;
+                    RenameConflict {
+                        symbol: existing.name.clone(),
+                        symbol_id: existing.id.clone(),
+                        scope: conflict.scope.clone(),
+                        location: Location::new(
+                            &existing.file_path,
+                            existing.line,
+                            existing.column,
+                        ),
+                    }
+                })
+                .collect(),
+        }
+    }
+}
+
+/// What changed between two snapshots, as reported by `diff`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GraphDiff {
+    /// Files with at least one change, in path order
+    pub files: Vec<FileDiff>,
+}
+
+/// Changes in one file. Lines are in the old snapshot for removed symbols and calls
+/// and in the new one otherwise.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FileDiff {
+    /// Path relative to the indexed directory
+    pub file: String,
+    pub added: Vec<DiffSymbol>,
+    pub removed: Vec<DiffSymbol>,
+    pub changed: Vec<SignatureChange>,
+    pub renamed: Vec<SymbolRename>,
+    pub added_calls: Vec<DiffCall>,
+    pub removed_calls: Vec<DiffCall>,
+    /// Only with `--complexity-threshold`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub complexity_changes: Vec<ComplexityChange>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DiffSymbol {
+    pub id: String,
+    pub name: String,
+    pub kind: NodeType,
+    /// The declaration without its body
+    pub signature: String,
+    pub line: usize,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SignatureChange {
+    pub name: String,
+    pub kind: NodeType,
+    /// The ID changes with the signature
+    pub old_id: String,
+    pub new_id: String,
+    pub old_signature: String,
+    pub new_signature: String,
+    pub line: usize,
+}
+
+/// A symbol whose body reappeared under another name
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SymbolRename {
+    pub old_name: String,
+    pub new_name: String,
+    pub old_id: String,
+    pub new_id: String,
+    pub kind: NodeType,
+    /// Where the symbol was declared before, relative to the indexed directory
+    pub old_file: String,
+    pub line: usize,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DiffCall {
+    pub caller: String,
+    /// Resolved name, e.g. `(*Calculator).Add`, or as written for external calls
+    pub callee: String,
+    /// Line of the call expression
+    pub line: usize,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ComplexityChange {
+    pub name: String,
+    pub old_complexity: usize,
+    pub new_complexity: usize,
+    pub line: usize,
+}
+
+impl GraphDiff {
+    /// `old_root` is the old snapshot's indexed directory, for naming renamed symbols' files
+    pub fn new(changes: &crate::core::GraphChanges<'_>, old_root: &str) -> Self {
+        let symbol = |node: &Node| DiffSymbol {
+            id: node.id.clone(),
+            name: node.name.clone(),
+            kind: node.node_type.clone(),
+            signature: node.declaration().to_string(),
+            line: node.line,
+        };
+        let call = |change: &crate::core::CallChange<'_>| DiffCall {
+            caller: change.caller.name.clone(),
+            callee: change.callee.clone(),
+            line: change.edge.line,
+        };
+        let files = changes
+            .files
+            .iter()
+            .map(|file| FileDiff {
+                file: file.file.clone(),
+                added: file.added.iter().map(|node| symbol(node)).collect(),
+                removed: file.removed.iter().map(|node| symbol(node)).collect(),
+                changed: file
+                    .changed
+                    .iter()
+                    .map(|change| SignatureChange {
+                        name: change.new.name.clone(),
+                        kind: change.new.node_type.clone(),
+                        old_id: change.old.id.clone(),
+                        new_id: change.new.id.clone(),
+                        old_signature: change.old.declaration().to_string(),
+                        new_signature: change.new.declaration().to_string(),
+                        line: change.new.line,
+                    })
+                    .collect(),
+                renamed: file
+                    .renamed
+                    .iter()
+                    .map(|change| SymbolRename {
+                        old_name: change.old.name.clone(),
+                        new_name: change.new.name.clone(),
+                        old_id: change.old.id.clone(),
+                        new_id: change.new.id.clone(),
+                        kind: change.new.node_type.clone(),
+                        old_file: change
+                            .old
+                            .file_path
+                            .strip_prefix(old_root)
+                            .unwrap_or(&change.old.file_path)
+                            .display()
+                            .to_string(),
+                        line: change.new.line,
+                    })
+                    .collect(),
+                added_calls: file.added_calls.iter().map(call).collect(),
+                removed_calls: file.removed_calls.iter().map(call).collect(),
+                complexity_changes: file
+                    .complexity
+                    .iter()
+                    .map(|change| ComplexityChange {
+                        name: change.new.name.clone(),
+                        old_complexity: change.old.complexity().unwrap_or_default(),
+                        new_complexity: change.new.complexity().unwrap_or_default(),
+                        line: change.new.line,
+                    })
+                    .collect(),
+            })
+            .collect();
+        Self { files }
+    }
+}
+
+/// Result of `index`
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct IndexSummary {
+    #[serde(default)]
+    pub schema_version: u32,
+    pub root: String,
+    /// The roots of a multi-root index, relative to `root`, e.g. `svc/a`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub roots: Vec<String>,
+    pub language: String,
+    pub files_parsed: usize,
+    pub symbols: usize,
+    pub calls: usize,
+    pub output: String,
+    pub diagnostics: Vec<Diagnostic>,
+}
+
+impl IndexSummary {
+    pub fn new(graph: &CodeGraph, output: &Path) -> Self {
+        Self {
+            schema_version: SCHEMA_VERSION,
+            root: graph.metadata.root_path.clone(),
+            roots: graph.metadata.roots.clone(),
+            language: graph.metadata.language.clone(),
+            files_parsed: graph.metadata.stats.files_parsed,
+            symbols: graph.nodes.len(),
+            calls: graph.edges.len(),
+            output: output.display().to_string(),
+            diagnostics: graph.metadata.diagnostics.clone(),
+        }
+    }
+}
+
+/// Symbols one re-indexed file gained, lost or changed, as reported by `watch`
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct IndexUpdate {
+    pub file: String,
+    /// Names of symbols the file now defines that it did not before
+    pub added: Vec<String>,
+    /// Names of symbols no longer defined, including all of them for a deleted file
+    pub removed: Vec<String>,
+    /// Names of symbols whose signature or line range changed
+    pub changed: Vec<String>,
+}
+
+impl IndexUpdate {
+    pub fn is_empty(&self) -> bool {
+        self.added.is_empty() && self.removed.is_empty() && self.changed.is_empty()
+    }
+}
+
+/// Symbols sorted by position, then name
+pub fn symbols<'a>(nodes: impl IntoIterator<Item = &'a Node>) -> Vec<Symbol> {
+    let mut symbols: Vec<Symbol> = nodes.into_iter().map(Symbol::from).collect();
+    symbols.sort_by(|a, b| (&a.location, &a.name).cmp(&(&b.location, &b.name)));
+    symbols
+}
+
+/// Call edges sorted by depth, then position
+pub fn call_edges(traces: &[TraceResult]) -> Vec<CallEdge> {
+    let mut edges: Vec<CallEdge> = traces.iter().map(CallEdge::from).collect();
+    edges.sort_by(|a, b| (a.depth, &a.location, &a.callee).cmp(&(b.depth, &b.location, &b.callee)));
+    edges
+}
+
+/// References sorted by depth, then position
+pub fn references(sites: &[CallSite]) -> Vec<Reference> {
+    let mut references: Vec<Reference> = sites.iter().map(Reference::from).collect();
+    references.sort_by(|a, b| (a.depth, &a.location).cmp(&(b.depth, &b.location)));
+    references
+}
+
+/// One page of a longer result list, printed instead of the bare list when a limit or
+/// an offset is given
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Page<T> {
+    #[serde(default)]
+    pub schema_version: u32,
+    /// Results in the whole list
+    pub total: usize,
+    /// Position of the first result in the whole list
+    pub offset: usize,
+    /// Whether results follow this page
+    pub has_more: bool,
+    /// The offset to ask for the next page with
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub next_offset: Option<usize>,
+    pub results: Vec<T>,
+}
+
+impl<T> Page<T> {
+    /// `results`, converted from the items of `page`, with its position
+    pub fn new<U>(page: &crate::core::Page<U>, results: Vec<T>) -> Self {
+        Self {
+            schema_version: SCHEMA_VERSION,
+            total: page.total,
+            offset: page.offset,
+            has_more: page.has_more(),
+            next_offset: page.next_offset(),
+            results,
+        }
+    }
+}
+
+/// A command's object, printed with the [`SCHEMA_VERSION`] it follows as its first field
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Versioned<T> {
+    pub schema_version: u32,
+    #[serde(flatten)]
+    pub value: T,
+}
+
+impl<T> Versioned<T> {
+    pub fn new(value: T) -> Self {
+        Self {
+            schema_version: SCHEMA_VERSION,
+            value,
+        }
+    }
+}
+
+/// A result list as printed: the bare list, or a [`Page`] when the request gave a limit
+/// or an offset
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(untagged)]
+pub enum Listing<T> {
+    Page(Page<T>),
+    List(Vec<T>),
+}
+
+impl<T> Listing<T> {
+    /// `results`, converted from the items of `page`, cut by `limit` and `offset`
+    pub fn new<U>(
+        page: &crate::core::Page<U>,
+        limit: Option<usize>,
+        offset: Option<usize>,
+        results: Vec<T>,
+    ) -> Self {
+        match limit.or(offset) {
+            Some(_) => Listing::Page(Page::new(page, results)),
+            None => Listing::List(results),
+        }
+    }
+}
+
+/// The call graph gathered by file or package, as written by
+/// `export --format json --group-by`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CallGraphGroups {
+    pub group_by: GroupBy,
+    pub groups: Vec<CallGroup>,
+    /// One per pair of groups, or group and function outside the index, the calls
+    /// between them merged
+    pub calls: Vec<GroupCall>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CallGroup {
+    /// The file relative to the indexed root, or the package's import path
+    pub name: String,
+    /// Symbols in the group
+    pub size: usize,
+    /// The symbols, in source order; left out when collapsed
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub symbols: Vec<Symbol>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GroupCall {
+    pub from: String,
+    /// A group's name, or the function as written when `external`, e.g. `fmt.Println`
+    pub to: String,
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub external: bool,
+    /// Calls merged
+    pub calls: usize,
+}
+
+impl CallGraphGroups {
+    /// `grouped`, collapsed to one entry per group without its symbols when `collapse`.
+    /// Calls within a collapsed group are only kept with `keep_internal`.
+    pub fn new(
+        grouped: &crate::core::GroupedCalls<'_>,
+        collapse: bool,
+        keep_internal: bool,
+        include_external: bool,
+    ) -> Self {
+        let name = |i: usize| grouped.groups[i].name.clone();
+        Self {
+            group_by: grouped.by,
+            groups: grouped
+                .groups
+                .iter()
+                .map(|group| CallGroup {
+                    name: group.name.clone(),
+                    size: group.nodes.len(),
+                    symbols: match collapse {
+                        true => Vec::new(),
+                        false => symbols(group.nodes.iter().copied()),
+                    },
+                })
+                .collect(),
+            calls: grouped
+                .calls
+                .iter()
+                .filter(|call| !(collapse && !keep_internal && call.is_internal()))
+                .filter_map(|call| {
+                    let (to, external) = match &call.to {
+                        GroupTarget::Group(to) => (name(*to), false),
+                        GroupTarget::External(_) if !include_external => return None,
+                        GroupTarget::External(function) => (function.clone(), true),
+                    };
+                    Some(GroupCall {
+                        from: name(call.from),
+                        to,
+                        external,
+                        calls: call.calls,
+                    })
+                })
+                .collect(),
+        }
+    }
+}
+
+/// Serialize `value` as pretty-printed JSON followed by a newline
+pub fn write_json<W: Write, T: Serialize + ?Sized>(writer: &mut W, value: &T) -> Result<()> {
+    serde_json::to_writer_pretty(&mut *writer, value)?;
+    writeln!(writer)?;
+    Ok(())
+}
+
+/// Serialize `value` as compact JSON on a single line, for streamed output
+pub fn write_json_line<W: Write, T: Serialize + ?Sized>(writer: &mut W, value: &T) -> Result<()> {
+    serde_json::to_writer(&mut *writer, value)?;
+    writeln!(writer)?;
+    writer.flush()?;
+    Ok(())
+}
+
+/// Shared encoder for every command's `--json` output
+pub fn print_json<T: Serialize + ?Sized>(value: &T) -> Result<()> {
+    let stdout = std::io::stdout();
+    let mut lock = stdout.lock();
+    write_json(&mut lock, value)
+}
@@ -47,6 +47,7 @@ mod tests {
             package: "test".to_string(),
             file_path: std::path::PathBuf::from("/test/file.ts"),
             line: 10,
+            column: 1,
             end_line: 15,
             signature: "testFunc()".to_string(),
             parameters: vec![],
@@ -54,6 +55,8 @@ mod tests {
             documentation: None,
             tags: vec![],
             metadata: Default::default(),
+            name_span: None,
+            span: None,
         };
         graph.add_node(node);
 
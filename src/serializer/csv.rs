@@ -1,4 +1,4 @@
-use crate::core::CodeGraph;
+use crate::core::{CodeGraph, Span};
 use anyhow::Result;
 use std::fs::File;
 use std::io::Write;
@@ -19,14 +19,14 @@ pub fn save_to_files(graph: &CodeGraph, output_prefix: &Path) -> Result<()> {
     let mut nodes_file = File::create(&nodes_path)?;
     writeln!(
         nodes_file,
-        "id,name,type,file_path,line,end_line,package,signature"
+        "id,name,type,file_path,line,end_line,package,signature,name_start_offset,name_end_offset,start_offset,end_offset"
     )?;
 
     for node in &graph.nodes {
         let node_type = format!("{:?}", node.node_type);
         writeln!(
             nodes_file,
-            "\"{}\",\"{}\",\"{}\",\"{}\",{},{},\"{}\",\"{}\"",
+            "\"{}\",\"{}\",\"{}\",\"{}\",{},{},\"{}\",\"{}\",{},{}",
             escape_csv(&node.id),
             escape_csv(&node.name),
             node_type,
@@ -34,7 +34,9 @@ pub fn save_to_files(graph: &CodeGraph, output_prefix: &Path) -> Result<()> {
             node.line,
             node.end_line,
             escape_csv(&node.package),
-            escape_csv(&node.signature)
+            escape_csv(&node.signature),
+            offsets(node.name_span.as_ref()),
+            offsets(node.span.as_ref())
         )?;
     }
 
@@ -65,3 +67,11 @@ pub fn save_to_files(graph: &CodeGraph, output_prefix: &Path) -> Result<()> {
 fn escape_csv(s: &str) -> String {
     s.replace('"', "\"\"")
 }
+
+/// Start and end byte offsets of a span as two CSV fields, both empty when unknown
+fn offsets(span: Option<&Span>) -> String {
+    match span {
+        Some(span) => format!("{},{}", span.start.offset, span.end.offset),
+        None => ",".to_string(),
+    }
+}
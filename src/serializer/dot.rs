@@ -1,70 +1,388 @@
-use crate::core::CodeGraph;
+use crate::core::{CodeGraph, EdgeKind, EdgeType, GroupBy, GroupTarget, Import, Node, NodeType};
 use anyhow::Result;
-use std::fs::File;
-use std::io::Write;
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+use std::fmt::Write as _;
+use std::fs;
 use std::path::Path;
 
-pub fn save_to_file(graph: &CodeGraph, output_path: &Path) -> Result<()> {
-    let mut file = File::create(output_path)?;
-
-    // Write DOT header
-    writeln!(file, "digraph CodeGraph {{")?;
-    writeln!(file, "  rankdir=LR;")?;
-    writeln!(file, "  node [shape=box];")?;
-    writeln!(file)?;
-
-    // Write nodes
-    for node in &graph.nodes {
-        let node_type = format!("{:?}", node.node_type);
-        let label = format!(
-            "{}\\n{}\\n{}:{}",
-            node.name, node_type, node.package, node.line
-        );
+/// Rendering options for DOT output
+#[derive(Debug, Clone)]
+pub struct DotOptions {
+    /// Group nodes into one `cluster_N` subgraph per source file
+    pub cluster_by_file: bool,
+    /// Draw calls to functions outside the indexed code (e.g. fmt.Println) as dashed ellipses
+    pub include_external: bool,
+    /// Group nodes into one cluster per file or package and merge the calls between
+    /// groups into one edge labelled with their count; supersedes `cluster_by_file`
+    pub group_by: Option<GroupBy>,
+    /// With `group_by`, draw each group as a single node
+    pub collapse: bool,
+    /// With `collapse`, keep the calls within a group as a self-edge
+    pub keep_internal: bool,
+}
 
-        // Color nodes by type
-        let color = match node.node_type {
-            crate::core::NodeType::Function => "lightblue",
-            crate::core::NodeType::Method => "lightgreen",
-            crate::core::NodeType::HttpHandler => "yellow",
-            crate::core::NodeType::Middleware => "pink",
-        };
+impl Default for DotOptions {
+    fn default() -> Self {
+        Self {
+            cluster_by_file: false,
+            include_external: true,
+            group_by: None,
+            collapse: false,
+            keep_internal: false,
+        }
+    }
+}
+
+/// An edge between two DOT IDs: label, color and whether it is inferred
+type DotEdge = (String, String, Option<String>, Option<&'static str>, bool);
+
+pub fn save_to_file(graph: &CodeGraph, output_path: &Path, options: &DotOptions) -> Result<()> {
+    fs::write(output_path, render(graph, options))?;
+    Ok(())
+}
+
+/// Render the graph as a DOT digraph. Nodes and edges are sorted and node IDs use
+/// paths relative to the indexed root, so the output is stable across runs and machines.
+/// Repeated calls between the same two functions are drawn as a single edge.
+pub fn render(graph: &CodeGraph, options: &DotOptions) -> String {
+    if let Some(by) = options.group_by {
+        return render_grouped(graph, options, by);
+    }
+    let root = graph.metadata.root_path.as_str();
 
-        writeln!(
-            file,
-            "  \"{}\" [label=\"{}\", fillcolor={}, style=filled];",
-            escape_dot(&node.id),
-            escape_dot(&label),
-            color
-        )?;
+    let mut nodes: Vec<&Node> = graph.nodes.iter().collect();
+    nodes.sort_by(|a, b| (&a.file_path, a.line, &a.name).cmp(&(&b.file_path, b.line, &b.name)));
+    let (externals, edges) = call_edges(graph, options.include_external);
+
+    let mut out = String::new();
+    out.push_str("digraph CodeGraph {\n");
+    out.push_str("  rankdir=LR;\n");
+    out.push_str("  node [shape=box];\n");
+    out.push('\n');
+
+    if options.cluster_by_file {
+        let mut by_file: BTreeMap<String, Vec<&Node>> = BTreeMap::new();
+        for &node in &nodes {
+            by_file
+                .entry(relative_path(&node.file_path, root))
+                .or_default()
+                .push(node);
+        }
+        for (i, (file, file_nodes)) in by_file.iter().enumerate() {
+            let _ = writeln!(out, "  subgraph \"cluster_{}\" {{", i);
+            let _ = writeln!(out, "    label=\"{}\";", escape_dot(file));
+            for node in file_nodes {
+                let _ = writeln!(out, "    {}", node_statement(node, root));
+            }
+            out.push_str("  }\n");
+        }
+    } else {
+        for node in &nodes {
+            let _ = writeln!(out, "  {}", node_statement(node, root));
+        }
+    }
+
+    for name in &externals {
+        out.push_str(&external_statement(name));
     }
 
-    writeln!(file)?;
+    out.push('\n');
+
+    for edge in &edges {
+        out.push_str(&edge_statement(edge));
+    }
 
-    // Write edges
+    out.push_str("}\n");
+    out
+}
+
+/// The calls of the graph between DOT IDs, and the names of the functions outside the
+/// index they reach
+fn call_edges(graph: &CodeGraph, include_external: bool) -> (BTreeSet<String>, BTreeSet<DotEdge>) {
+    let root = graph.metadata.root_path.as_str();
+
+    let mut externals = BTreeSet::new();
+    let mut edges = BTreeSet::new();
     for edge in &graph.edges {
-        let edge_type = format!("{:?}", edge.edge_type);
+        let Some(from) = graph.get_node_by_id(&edge.from) else {
+            continue;
+        };
+        let from_id = dot_id(from, root);
+        // Goroutine launches and deferred calls are labelled and colored
+        let (label, color) = match (&edge.edge_type, edge.kind()) {
+            (EdgeType::Calls, EdgeKind::Normal) => (None, None),
+            (EdgeType::Calls, EdgeKind::Go) => (Some("go".to_string()), Some("blue")),
+            (EdgeType::Calls, EdgeKind::Defer) => (Some("defer".to_string()), Some("darkorange")),
+            (edge_type, _) => (Some(format!("{:?}", edge_type)), None),
+        };
 
-        // Try to find the target node to link to its ID
-        // If not found, just use the function name
-        let target = if let Some(_target_node) = graph.get_nodes_by_name(&edge.to).first() {
-            format!("{}:{}", edge.to, edge.line)
+        let targets = graph.edge_targets(edge);
+        if targets.is_empty() {
+            if !include_external {
+                continue;
+            }
+            let name = match edge.metadata.get("qualifier") {
+                Some(qualifier) => format!("{}.{}", qualifier, edge.to),
+                None => edge.to.clone(),
+            };
+            edges.insert((
+                from_id.clone(),
+                format!("external:{}", name),
+                label,
+                color,
+                false,
+            ));
+            externals.insert(name);
         } else {
-            edge.to.clone()
+            for target in targets {
+                edges.insert((
+                    from_id.clone(),
+                    dot_id(target, root),
+                    label.clone(),
+                    color,
+                    edge.is_indirect(),
+                ));
+            }
+        }
+    }
+    // A call written in the source supersedes the same call inferred through a parameter
+    let direct: BTreeSet<(String, String, Option<String>)> = edges
+        .iter()
+        .filter(|(_, _, _, _, indirect)| !indirect)
+        .map(|(from, to, label, _, _)| (from.clone(), to.clone(), label.clone()))
+        .collect();
+    edges.retain(|(from, to, label, _, indirect)| {
+        !indirect || !direct.contains(&(from.clone(), to.clone(), label.clone()))
+    });
+    (externals, edges)
+}
+
+/// Render the graph with its nodes grouped by `by`: one cluster per group, or one node
+/// per group when collapsed. Calls between groups become one edge per pair of groups,
+/// labelled with the number of calls it stands for; Graphviz draws it between the
+/// clusters' borders.
+fn render_grouped(graph: &CodeGraph, options: &DotOptions, by: GroupBy) -> String {
+    let root = graph.metadata.root_path.as_str();
+    let grouped = graph.group_calls(by);
+
+    let mut out = String::new();
+    out.push_str("digraph CodeGraph {\n");
+    out.push_str("  rankdir=LR;\n");
+    if !options.collapse {
+        out.push_str("  compound=true;\n");
+    }
+    out.push_str("  node [shape=box];\n");
+    out.push('\n');
+
+    // Where an edge leaves or enters each group: the group's node, or its first symbol
+    let mut anchors = Vec::new();
+    for (i, group) in grouped.groups.iter().enumerate() {
+        if options.collapse {
+            let id = format!("group:{}", group.name);
+            let _ = writeln!(
+                out,
+                "  \"{}\" [label=\"{}\\n{} symbols\"];",
+                escape_dot(&id),
+                escape_dot(&group.name),
+                group.nodes.len()
+            );
+            anchors.push(id);
+        } else {
+            let _ = writeln!(out, "  subgraph \"cluster_{}\" {{", i);
+            let _ = writeln!(out, "    label=\"{}\";", escape_dot(&group.name));
+            for node in &group.nodes {
+                let _ = writeln!(out, "    {}", node_statement(node, root));
+            }
+            out.push_str("  }\n");
+            anchors.push(dot_id(group.nodes[0], root));
+        }
+    }
+
+    let calls: Vec<_> = grouped
+        .calls
+        .iter()
+        .filter(|call| match &call.to {
+            GroupTarget::External(_) => options.include_external,
+            // Drawn symbol to symbol inside a cluster
+            GroupTarget::Group(_) if call.is_internal() => {
+                options.collapse && options.keep_internal
+            }
+            GroupTarget::Group(_) => true,
+        })
+        .collect();
+    let externals: BTreeSet<&str> = calls
+        .iter()
+        .filter_map(|call| match &call.to {
+            GroupTarget::External(name) => Some(name.as_str()),
+            GroupTarget::Group(_) => None,
+        })
+        .collect();
+    for name in externals {
+        out.push_str(&external_statement(name));
+    }
+
+    out.push('\n');
+
+    if !options.collapse {
+        let membership = grouped.membership();
+        let group_of: HashMap<String, usize> = graph
+            .nodes
+            .iter()
+            .filter_map(|node| Some((dot_id(node, root), *membership.get(node.id.as_str())?)))
+            .collect();
+        let (_, edges) = call_edges(graph, false);
+        for edge in &edges {
+            let (from, to) = (group_of.get(&edge.0), group_of.get(&edge.1));
+            if from.is_some() && from == to {
+                out.push_str(&edge_statement(edge));
+            }
+        }
+    }
+    for call in calls {
+        let to = match &call.to {
+            GroupTarget::Group(to) => anchors[*to].clone(),
+            GroupTarget::External(name) => format!("external:{}", name),
         };
+        let mut attributes = vec![format!(
+            "label=\"{} call{}\"",
+            call.calls,
+            if call.calls == 1 { "" } else { "s" }
+        )];
+        if !options.collapse {
+            attributes.push(format!("ltail=\"cluster_{}\"", call.from));
+            if let GroupTarget::Group(to) = call.to {
+                attributes.push(format!("lhead=\"cluster_{}\"", to));
+            }
+        }
+        let _ = writeln!(
+            out,
+            "  \"{}\" -> \"{}\" [{}];",
+            escape_dot(&anchors[call.from]),
+            escape_dot(&to),
+            attributes.join(", ")
+        );
+    }
+
+    out.push_str("}\n");
+    out
+}
+
+fn external_statement(name: &str) -> String {
+    format!(
+        "  \"external:{}\" [label=\"{}\", shape=ellipse, style=dashed];\n",
+        escape_dot(name),
+        escape_dot(name)
+    )
+}
 
-        writeln!(
-            file,
-            "  \"{}\" -> \"{}\" [label=\"{}\"];",
-            escape_dot(&edge.from),
-            escape_dot(&target),
-            escape_dot(&edge_type)
-        )?;
+fn edge_statement((from, to, label, color, indirect): &DotEdge) -> String {
+    let mut attributes = Vec::new();
+    if let Some(label) = label {
+        attributes.push(format!("label=\"{}\"", escape_dot(label)));
+    }
+    if let Some(color) = color {
+        attributes.push(format!("color={}", color));
+    }
+    if *indirect {
+        attributes.push("style=dashed".to_string());
+    }
+    let mut out = format!("  \"{}\" -> \"{}\"", escape_dot(from), escape_dot(to));
+    if !attributes.is_empty() {
+        let _ = write!(out, " [{}]", attributes.join(", "));
+    }
+    out.push_str(";\n");
+    out
+}
+
+/// Render package imports as a DOT digraph with one node per package. Packages outside
+/// the module are dashed ellipses; aliased and dot imports are labelled with their name
+/// and blank imports are dotted, so each kind of spec stays distinct.
+pub fn render_imports(graph: &CodeGraph, imports: &[&Import]) -> String {
+    let mut packages = BTreeMap::new();
+    let mut edges = BTreeSet::new();
+    for import in imports {
+        let from = graph.importing_package(import);
+        packages.insert(from.clone(), true);
+        packages
+            .entry(import.path.clone())
+            .or_insert(import.internal);
+        edges.insert((from, import.path.clone(), import.alias.clone()));
     }
 
-    // Close digraph
-    writeln!(file, "}}")?;
+    let mut out = String::new();
+    out.push_str("digraph Imports {\n");
+    out.push_str("  rankdir=LR;\n");
+    out.push_str("  node [shape=box];\n");
+    out.push('\n');
 
-    Ok(())
+    for (package, internal) in &packages {
+        let _ = write!(out, "  \"{}\"", escape_dot(package));
+        if !internal {
+            out.push_str(" [shape=ellipse, style=dashed]");
+        }
+        out.push_str(";\n");
+    }
+
+    out.push('\n');
+
+    for (from, to, alias) in &edges {
+        let _ = write!(out, "  \"{}\" -> \"{}\"", escape_dot(from), escape_dot(to));
+        match alias.as_deref() {
+            None => {}
+            Some("_") => out.push_str(" [label=\"_\", style=dotted]"),
+            Some(alias) => {
+                let _ = write!(out, " [label=\"{}\"]", escape_dot(alias));
+            }
+        }
+        out.push_str(";\n");
+    }
+
+    out.push_str("}\n");
+    out
+}
+
+fn node_statement(node: &Node, root: &str) -> String {
+    let node_type = format!("{:?}", node.node_type);
+    let label = format!(
+        "{}\\n{}\\n{}:{}",
+        node.name, node_type, node.package, node.line
+    );
+
+    // Color nodes by type
+    let color = match node.node_type {
+        NodeType::Function => "lightblue",
+        NodeType::Method => "lightgreen",
+        NodeType::HttpHandler => "yellow",
+        NodeType::Middleware => "pink",
+        NodeType::Benchmark | NodeType::Fuzz => "lightsalmon",
+        NodeType::Struct | NodeType::Class | NodeType::Type => "wheat",
+        NodeType::Interface => "plum",
+        NodeType::Const | NodeType::Var | NodeType::Field => "lightgrey",
+    };
+
+    format!(
+        "\"{}\" [label=\"{}\", fillcolor={}, style=filled];",
+        escape_dot(&dot_id(node, root)),
+        escape_dot(&label),
+        color
+    )
+}
+
+/// Node ID with the file path made relative to the indexed root
+fn dot_id(node: &Node, root: &str) -> String {
+    format!(
+        "{}:{}:{}",
+        relative_path(&node.file_path, root),
+        node.name,
+        node.line
+    )
+}
+
+pub(super) fn relative_path(path: &Path, root: &str) -> String {
+    path.strip_prefix(root)
+        .unwrap_or(path)
+        .display()
+        .to_string()
 }
 
 fn escape_dot(s: &str) -> String {
@@ -72,3 +390,152 @@ fn escape_dot(s: &str) -> String {
         .replace('\n', "\\n")
         .replace('\r', "\\r")
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::core::Edge;
+    use std::path::PathBuf;
+
+    fn sample_graph() -> CodeGraph {
+        let mut graph = CodeGraph::new("/src".to_string(), "go".to_string());
+        graph.add_node(Node::new(
+            "/src/b.go:Run:3".to_string(),
+            "Run".to_string(),
+            NodeType::Function,
+            PathBuf::from("/src/b.go"),
+            3,
+            6,
+            "app".to_string(),
+            "func Run() {".to_string(),
+        ));
+        graph.add_node(Node::new(
+            "/src/a.go:(*Server).Start:5".to_string(),
+            "(*Server).Start".to_string(),
+            NodeType::Method,
+            PathBuf::from("/src/a.go"),
+            5,
+            8,
+            "app".to_string(),
+            "func (s *Server) Start() {".to_string(),
+        ));
+
+        let mut println = Edge::new(
+            "/src/b.go:Run:3".to_string(),
+            "Println".to_string(),
+            EdgeType::Calls,
+            "fmt.Println()".to_string(),
+            PathBuf::from("/src/b.go"),
+            5,
+        );
+        println
+            .metadata
+            .insert("qualifier".to_string(), "fmt".to_string());
+        graph.add_edge(println);
+        for line in [4, 5] {
+            graph.add_edge(Edge::new(
+                "/src/b.go:Run:3".to_string(),
+                "(*Server).Start".to_string(),
+                EdgeType::Calls,
+                "s.Start()".to_string(),
+                PathBuf::from("/src/b.go"),
+                line,
+            ));
+        }
+        graph
+    }
+
+    #[test]
+    fn test_render_is_sorted_and_relative() {
+        let expected = r#"digraph CodeGraph {
+  rankdir=LR;
+  node [shape=box];
+
+  "a.go:(*Server).Start:5" [label="(*Server).Start\nMethod\napp:5", fillcolor=lightgreen, style=filled];
+  "b.go:Run:3" [label="Run\nFunction\napp:3", fillcolor=lightblue, style=filled];
+  "external:fmt.Println" [label="fmt.Println", shape=ellipse, style=dashed];
+
+  "b.go:Run:3" -> "a.go:(*Server).Start:5";
+  "b.go:Run:3" -> "external:fmt.Println";
+}
+"#;
+        assert_eq!(render(&sample_graph(), &DotOptions::default()), expected);
+    }
+
+    #[test]
+    fn test_render_clusters_and_omits_external() {
+        let options = DotOptions {
+            cluster_by_file: true,
+            include_external: false,
+            ..Default::default()
+        };
+        let expected = r#"digraph CodeGraph {
+  rankdir=LR;
+  node [shape=box];
+
+  subgraph "cluster_0" {
+    label="a.go";
+    "a.go:(*Server).Start:5" [label="(*Server).Start\nMethod\napp:5", fillcolor=lightgreen, style=filled];
+  }
+  subgraph "cluster_1" {
+    label="b.go";
+    "b.go:Run:3" [label="Run\nFunction\napp:3", fillcolor=lightblue, style=filled];
+  }
+
+  "b.go:Run:3" -> "a.go:(*Server).Start:5";
+}
+"#;
+        assert_eq!(render(&sample_graph(), &options), expected);
+    }
+
+    #[test]
+    fn test_render_dashes_indirect_calls() {
+        let mut graph = sample_graph();
+        let mut possible = Edge::new(
+            "/src/a.go:(*Server).Start:5".to_string(),
+            "Run".to_string(),
+            EdgeType::Calls,
+            "f()".to_string(),
+            PathBuf::from("/src/a.go"),
+            6,
+        );
+        possible
+            .metadata
+            .insert("target_id".to_string(), "/src/b.go:Run:3".to_string());
+        possible
+            .metadata
+            .insert("indirect".to_string(), "true".to_string());
+        graph.add_edge(possible);
+
+        let dot = render(&graph, &DotOptions::default());
+        assert!(dot.contains("  \"a.go:(*Server).Start:5\" -> \"b.go:Run:3\" [style=dashed];\n"));
+        assert!(dot.contains("  \"b.go:Run:3\" -> \"a.go:(*Server).Start:5\";\n"));
+    }
+
+    #[test]
+    fn test_render_labels_goroutines_and_defers() {
+        let mut graph = sample_graph();
+        for (line, kind) in [(6, "go"), (7, "defer")] {
+            let mut call = Edge::new(
+                "/src/a.go:(*Server).Start:5".to_string(),
+                "Run".to_string(),
+                EdgeType::Calls,
+                "Run()".to_string(),
+                PathBuf::from("/src/a.go"),
+                line,
+            );
+            call.metadata
+                .insert("target_id".to_string(), "/src/b.go:Run:3".to_string());
+            call.metadata.insert("kind".to_string(), kind.to_string());
+            graph.add_edge(call);
+        }
+
+        let dot = render(&graph, &DotOptions::default());
+        assert!(dot.contains(
+            "  \"a.go:(*Server).Start:5\" -> \"b.go:Run:3\" [label=\"go\", color=blue];\n"
+        ));
+        assert!(dot.contains(
+            "  \"a.go:(*Server).Start:5\" -> \"b.go:Run:3\" [label=\"defer\", color=darkorange];\n"
+        ));
+    }
+}
@@ -1,7 +1,9 @@
-use crate::core::CodeGraph;
-use anyhow::Result;
+use super::format::{self, IndexHeader};
+use crate::core::{CodeGraph, Edge};
+use anyhow::{Context, Result};
+use std::path::{Path, PathBuf};
 
-/// Save graph to JSON format with LZ4 compression
+/// Save graph to JSON format with LZ4 compression, behind a versioned header
 /// LZ4 is 3-4x faster to decompress than zstd, with slightly larger files
 pub fn save_to_file(graph: &CodeGraph, path: &str) -> Result<()> {
     // Serialize to JSON (respects serde attributes)
@@ -11,7 +13,7 @@ pub fn save_to_file(graph: &CodeGraph, path: &str) -> Result<()> {
     let compressed = lz4_flex::compress_prepend_size(&json);
 
     // Write directly to file
-    std::fs::write(path, compressed)?;
+    std::fs::write(path, format::encode(&IndexHeader::of(graph), &compressed)?)?;
 
     Ok(())
 }
@@ -19,10 +21,16 @@ pub fn save_to_file(graph: &CodeGraph, path: &str) -> Result<()> {
 /// Load graph from JSON+LZ4 format
 pub fn load_from_file(path: &str) -> Result<CodeGraph> {
     // Read compressed data from file
-    let compressed = std::fs::read(path)?;
+    let bytes = std::fs::read(path)?;
+
+    // Headerless files are format 1, the same graph layout without the header
+    let compressed = match format::decode(&bytes, Path::new(path))? {
+        Some((_, body)) => body,
+        None => &bytes[..],
+    };
 
     // Decompress with LZ4 (very fast)
-    let decompressed = lz4_flex::decompress_size_prepended(&compressed)
+    let decompressed = lz4_flex::decompress_size_prepended(compressed)
         .map_err(|e| anyhow::anyhow!("Failed to decompress: {}", e))?;
 
     // Deserialize from JSON
@@ -31,6 +39,31 @@ pub fn load_from_file(path: &str) -> Result<CodeGraph> {
     Ok(graph)
 }
 
+/// Where `index --low-memory` writes the reference table of the graph at `graph_path`
+pub fn references_path(graph_path: &Path) -> PathBuf {
+    graph_path.with_extension("refs")
+}
+
+/// Save `references` beside the graph at `graph_path`, in the graph's JSON+LZ4 format
+pub fn save_references(references: &[Edge], graph_path: &Path) -> Result<()> {
+    let json = serde_json::to_vec(references)?;
+    std::fs::write(
+        references_path(graph_path),
+        lz4_flex::compress_prepend_size(&json),
+    )?;
+    Ok(())
+}
+
+/// Load the reference table saved beside the graph at `graph_path`
+pub fn load_references(graph_path: &Path) -> Result<Vec<Edge>> {
+    let path = references_path(graph_path);
+    let compressed = std::fs::read(&path)
+        .with_context(|| format!("Failed to read references from {}", path.display()))?;
+    let decompressed = lz4_flex::decompress_size_prepended(&compressed)
+        .map_err(|e| anyhow::anyhow!("Failed to decompress: {}", e))?;
+    Ok(serde_json::from_slice(&decompressed)?)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -48,6 +81,7 @@ mod tests {
             package: "test".to_string(),
             file_path: std::path::PathBuf::from("/test/file.ts"),
             line: 10,
+            column: 1,
             end_line: 15,
             signature: "testFunc()".to_string(),
             parameters: vec![],
@@ -55,6 +89,8 @@ mod tests {
             documentation: None,
             tags: vec![],
             metadata: Default::default(),
+            name_span: None,
+            span: None,
         };
         graph.add_node(node);
 
@@ -68,4 +104,110 @@ mod tests {
         assert_eq!(loaded.nodes.len(), 1);
         assert_eq!(loaded.nodes[0].name, "testFunc");
     }
+
+    fn graph_with_one_node() -> CodeGraph {
+        let mut graph = CodeGraph::new("/test".to_string(), "go".to_string());
+        graph.add_node(crate::core::Node::new(
+            "main.go:main:3".to_string(),
+            "main".to_string(),
+            crate::core::NodeType::Function,
+            std::path::PathBuf::from("main.go"),
+            3,
+            5,
+            "main".to_string(),
+            "func main() {".to_string(),
+        ));
+        graph.metadata.stats.files_parsed = 1;
+        graph
+    }
+
+    #[test]
+    fn test_header_is_written_and_inspected() {
+        let temp_file = NamedTempFile::new().unwrap();
+        save_to_file(&graph_with_one_node(), temp_file.path().to_str().unwrap()).unwrap();
+
+        let header = format::inspect(temp_file.path()).unwrap();
+        assert_eq!(header.kind, format::IndexKind::Graph);
+        assert_eq!(header.format_version, format::INDEX_FORMAT_VERSION);
+        assert_eq!(
+            header.tool_version.as_deref(),
+            Some(env!("CARGO_PKG_VERSION"))
+        );
+        assert_eq!((header.files, header.symbols), (1, Some(1)));
+
+        // An incremental run that parsed nothing still writes the whole index's count
+        let mut graph = graph_with_one_node();
+        graph.metadata.stats.files_parsed = 0;
+        save_to_file(&graph, temp_file.path().to_str().unwrap()).unwrap();
+        assert_eq!(format::inspect(temp_file.path()).unwrap().files, 1);
+    }
+
+    #[test]
+    fn test_format_1_graph_loads_and_is_rewritten_with_a_header() {
+        // Format 1 is the compressed graph with no header in front
+        let temp_file = NamedTempFile::new().unwrap();
+        let path = temp_file.path().to_str().unwrap();
+        let json = serde_json::to_vec(&graph_with_one_node()).unwrap();
+        std::fs::write(path, lz4_flex::compress_prepend_size(&json)).unwrap();
+
+        let header = format::inspect(temp_file.path()).unwrap();
+        assert_eq!((header.format_version, header.tool_version), (1, None));
+        assert_eq!(header.symbols, Some(1));
+
+        let loaded = load_from_file(path).unwrap();
+        assert_eq!(loaded.nodes[0].name, "main");
+        save_to_file(&loaded, path).unwrap();
+        assert_eq!(
+            format::inspect(temp_file.path()).unwrap().format_version,
+            format::INDEX_FORMAT_VERSION
+        );
+    }
+
+    #[test]
+    fn test_newer_format_is_refused() {
+        let temp_file = NamedTempFile::new().unwrap();
+        let path = temp_file.path().to_str().unwrap();
+        let graph = graph_with_one_node();
+        let mut header = IndexHeader::of(&graph);
+        header.format_version = format::INDEX_FORMAT_VERSION + 1;
+        header.tool_version = Some("99.0.0".to_string());
+        let body = lz4_flex::compress_prepend_size(&serde_json::to_vec(&graph).unwrap());
+        std::fs::write(path, format::encode(&header, &body).unwrap()).unwrap();
+
+        let err = load_from_file(path).unwrap_err().to_string();
+        assert!(err.contains(&format!(
+            "was written by code-navigator 99.0.0 in index format {}, newer than format {}",
+            format::INDEX_FORMAT_VERSION + 1,
+            format::INDEX_FORMAT_VERSION
+        )));
+        assert!(err.contains("upgrade code-navigator or re-index"));
+
+        // Still described, so the version can be seen
+        assert_eq!(
+            format::inspect(temp_file.path()).unwrap().format_version,
+            format::INDEX_FORMAT_VERSION + 1
+        );
+    }
+
+    #[test]
+    fn test_references_roundtrip() {
+        let dir = tempfile::tempdir().unwrap();
+        let graph_path = dir.path().join("codenav.bin");
+        let reference = crate::core::Edge::new(
+            "main".to_string(),
+            "Add".to_string(),
+            crate::core::EdgeType::References,
+            "apply(Add)".to_string(),
+            std::path::PathBuf::from("/test/main.go"),
+            12,
+        );
+
+        save_references(std::slice::from_ref(&reference), &graph_path).unwrap();
+        assert!(dir.path().join("codenav.refs").exists());
+        let loaded = load_references(&graph_path).unwrap();
+
+        assert_eq!(loaded.len(), 1);
+        assert_eq!(loaded[0].to, "Add");
+        assert_eq!(loaded[0].call_site, "apply(Add)");
+    }
 }
@@ -0,0 +1,295 @@
+use super::format::{self, IndexHeader, IndexKind};
+use crate::core::{Diagnostic, Edge, Import, Node};
+use anyhow::{bail, Context, Result};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashSet};
+use std::path::{Path, PathBuf};
+
+/// Bump whenever the cached node/edge layout or the parser output changes meaning,
+/// so caches written by older builds are discarded instead of misread
+pub const FILE_CACHE_VERSION: u32 = 17;
+
+/// Directory created inside the indexed root to hold the cache
+pub const CACHE_DIR: &str = ".code-navigator";
+
+/// Per-file parse results keyed by path and content hash.
+/// Entries hold the nodes and edges a file produced before cross-file resolution,
+/// so a cached file is re-resolved against whatever the other files now define.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct FileCache {
+    pub format_version: u32,
+    pub language: String,
+    /// Version of code-navigator that last loaded or created the cache
+    #[serde(default)]
+    pub tool_version: Option<String>,
+    /// When the cache was first written, RFC 3339
+    #[serde(default)]
+    pub created_at: Option<String>,
+    pub files: BTreeMap<String, CachedFile>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CachedFile {
+    pub content_hash: String,
+    pub nodes: Vec<Node>,
+    pub edges: Vec<Edge>,
+    #[serde(default)]
+    pub references: Vec<Edge>,
+    #[serde(default)]
+    pub imports: Vec<Import>,
+    /// Syntax errors found in the file, reported again whenever it is served from cache
+    #[serde(default)]
+    pub diagnostics: Vec<Diagnostic>,
+}
+
+impl FileCache {
+    pub fn new(language: &str) -> Self {
+        Self {
+            format_version: FILE_CACHE_VERSION,
+            language: language.to_string(),
+            tool_version: Some(env!("CARGO_PKG_VERSION").to_string()),
+            created_at: Some(chrono::Utc::now().to_rfc3339()),
+            files: BTreeMap::new(),
+        }
+    }
+
+    /// Default cache location for an indexed directory: `<dir>/.code-navigator/index.cache`
+    pub fn default_path(dir: &Path) -> PathBuf {
+        dir.join(CACHE_DIR).join("index.cache")
+    }
+
+    /// Load a cache written by `save`. A cache from an older format version, or from
+    /// before caches had a header, comes back empty, so every file is parsed again and
+    /// the cache rebuilt. Fails on unreadable files, a newer format version or a
+    /// different language; callers are expected to fall back to an empty cache.
+    pub fn load(path: &Path, language: &str) -> Result<Self> {
+        let bytes = std::fs::read(path)
+            .with_context(|| format!("Failed to read cache: {}", path.display()))?;
+
+        // Check the version before trusting the rest of the layout
+        let Some((version, _, compressed)) = format::split(&bytes, path)? else {
+            return Ok(Self::new(language));
+        };
+        if version > FILE_CACHE_VERSION {
+            bail!(
+                "Cache format version {} is not supported: it was written by a newer code-navigator (this one reads {})",
+                version,
+                FILE_CACHE_VERSION
+            );
+        }
+        // What an older build cached may mean something else now
+        if version < FILE_CACHE_VERSION {
+            return Ok(Self::new(language));
+        }
+
+        let json = lz4_flex::decompress_size_prepended(compressed)
+            .map_err(|e| anyhow::anyhow!("Failed to decompress cache: {}", e))?;
+        let mut cache: FileCache = serde_json::from_slice(&json).context("Corrupt cache")?;
+        if cache.language != language {
+            bail!("Cache was built for {}, not {}", cache.language, language);
+        }
+        cache.tool_version = Some(env!("CARGO_PKG_VERSION").to_string());
+        Ok(cache)
+    }
+
+    pub fn save(&self, path: &Path) -> Result<()> {
+        if let Some(parent) = path.parent() {
+            std::fs::create_dir_all(parent)?;
+        }
+        let json = serde_json::to_vec(self)?;
+        let compressed = lz4_flex::compress_prepend_size(&json);
+        std::fs::write(path, format::encode(&self.header(), &compressed)?)?;
+        Ok(())
+    }
+
+    /// The header `save` writes in front of the cache, for `index inspect`
+    fn header(&self) -> IndexHeader {
+        IndexHeader {
+            kind: IndexKind::FileCache,
+            format_version: self.format_version,
+            tool_version: self.tool_version.clone(),
+            created_at: self.created_at.clone(),
+            language: self.language.clone(),
+            root_path: None,
+            files: self.files.len(),
+            symbols: Some(self.files.values().map(|entry| entry.nodes.len()).sum()),
+            calls: Some(self.files.values().map(|entry| entry.edges.len()).sum()),
+        }
+    }
+
+    /// Cached results for `path` if its content still hashes to `content_hash`
+    pub fn get(&self, path: &str, content_hash: &str) -> Option<&CachedFile> {
+        self.files
+            .get(path)
+            .filter(|entry| entry.content_hash == content_hash)
+    }
+
+    pub fn insert(&mut self, path: String, entry: CachedFile) {
+        self.files.insert(path, entry);
+    }
+
+    /// Drop entries for files that no longer exist
+    pub fn retain_files(&mut self, present: &HashSet<String>) {
+        self.files.retain(|path, _| present.contains(path));
+    }
+}
+
+/// Hit/miss counts for one indexing run
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CacheStats {
+    pub hits: usize,
+    pub misses: usize,
+}
+
+/// Stable 64-bit FNV-1a hash of file contents, hex encoded.
+/// std's DefaultHasher is not guaranteed stable across Rust releases, so it can't key a disk cache.
+pub fn content_hash(content: &[u8]) -> String {
+    let mut hash: u64 = 0xcbf29ce484222325;
+    for byte in content {
+        hash ^= u64::from(*byte);
+        hash = hash.wrapping_mul(0x100000001b3);
+    }
+    format!("{:016x}", hash)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::core::NodeType;
+    use tempfile::TempDir;
+
+    #[test]
+    fn test_content_hash_is_stable() {
+        assert_eq!(content_hash(b""), "cbf29ce484222325");
+        assert_eq!(content_hash(b"a"), "af63dc4c8601ec8c");
+        assert_ne!(content_hash(b"package main"), content_hash(b"package util"));
+    }
+
+    #[test]
+    fn test_file_cache_roundtrip() {
+        let dir = TempDir::new().unwrap();
+        let path = FileCache::default_path(dir.path());
+
+        let mut cache = FileCache::new("go");
+        cache.insert(
+            "main.go".to_string(),
+            CachedFile {
+                content_hash: content_hash(b"package main"),
+                nodes: vec![Node::new(
+                    "main.go:main:3".to_string(),
+                    "main".to_string(),
+                    NodeType::Function,
+                    PathBuf::from("main.go"),
+                    3,
+                    5,
+                    "main".to_string(),
+                    "func main() {".to_string(),
+                )],
+                edges: vec![],
+                references: vec![],
+                imports: vec![],
+                diagnostics: vec![],
+            },
+        );
+        cache.save(&path).unwrap();
+
+        let loaded = FileCache::load(&path, "go").unwrap();
+        assert!(loaded
+            .get("main.go", &content_hash(b"package main"))
+            .is_some());
+        assert!(loaded.get("main.go", &content_hash(b"changed")).is_none());
+        assert!(FileCache::load(&path, "python").is_err());
+    }
+
+    #[test]
+    fn test_header_is_inspected_without_the_body() {
+        let dir = TempDir::new().unwrap();
+        let path = FileCache::default_path(dir.path());
+        let mut cache = FileCache::new("go");
+        cache.insert(
+            "main.go".to_string(),
+            CachedFile {
+                content_hash: content_hash(b"package main"),
+                nodes: vec![],
+                edges: vec![],
+                references: vec![],
+                imports: vec![],
+                diagnostics: vec![],
+            },
+        );
+        cache.save(&path).unwrap();
+
+        // A body that no longer decompresses doesn't matter to the header
+        let mut bytes = std::fs::read(&path).unwrap();
+        let len = bytes.len();
+        bytes.truncate(len - 4);
+        std::fs::write(&path, bytes).unwrap();
+        let header = format::inspect(&path).unwrap();
+        assert_eq!(header.kind, IndexKind::FileCache);
+        assert_eq!(header.format_version, FILE_CACHE_VERSION);
+        assert_eq!((header.files, header.symbols), (1, Some(0)));
+    }
+
+    #[test]
+    fn test_headerless_cache_is_rebuilt() {
+        let dir = TempDir::new().unwrap();
+        let path = FileCache::default_path(dir.path());
+        std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+        let mut cache = FileCache::new("go");
+        cache.insert(
+            "main.go".to_string(),
+            CachedFile {
+                content_hash: content_hash(b"package main"),
+                nodes: vec![],
+                edges: vec![],
+                references: vec![],
+                imports: vec![],
+                diagnostics: vec![],
+            },
+        );
+        let json = serde_json::to_vec(&cache).unwrap();
+        std::fs::write(&path, lz4_flex::compress_prepend_size(&json)).unwrap();
+
+        let loaded = FileCache::load(&path, "go").unwrap();
+        assert!(loaded.files.is_empty());
+    }
+
+    #[test]
+    fn test_incompatible_version_is_rejected() {
+        let dir = TempDir::new().unwrap();
+        let path = FileCache::default_path(dir.path());
+
+        let mut cache = FileCache::new("go");
+        cache.format_version = FILE_CACHE_VERSION + 1;
+        cache.save(&path).unwrap();
+
+        let err = FileCache::load(&path, "go").unwrap_err();
+        assert!(err.to_string().contains("not supported"));
+    }
+
+    #[test]
+    fn test_older_version_is_rebuilt() {
+        let dir = TempDir::new().unwrap();
+        let path = FileCache::default_path(dir.path());
+
+        let mut cache = FileCache::new("go");
+        cache.format_version = FILE_CACHE_VERSION - 1;
+        cache.insert(
+            "main.go".to_string(),
+            CachedFile {
+                content_hash: content_hash(b"package main"),
+                nodes: vec![],
+                edges: vec![],
+                references: vec![],
+                imports: vec![],
+                diagnostics: vec![],
+            },
+        );
+        cache.save(&path).unwrap();
+
+        // Loaded empty, so the next index run parses every file and writes it afresh
+        let loaded = FileCache::load(&path, "go").unwrap();
+        assert_eq!(loaded.format_version, FILE_CACHE_VERSION);
+        assert!(loaded.files.is_empty());
+    }
+}
@@ -0,0 +1,233 @@
+//! Versioning of the files `index` writes.
+//!
+//! A graph file or per-file cache starts with [`MAGIC`], the format version and a small
+//! JSON [`IndexHeader`], so its version and provenance can be read without decompressing
+//! the rest. Graphs written before versioning have no header and are format 1, which
+//! differs from format 2 only by the header, so they load as they are. A graph from a
+//! newer format is refused.
+
+use super::file_cache::FILE_CACHE_VERSION;
+use crate::core::CodeGraph;
+use anyhow::{bail, Context, Result};
+use serde::de::IgnoredAny;
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::fs::File;
+use std::io::Read;
+use std::path::Path;
+
+/// Opens every graph file and per-file cache written with a header
+pub const MAGIC: &[u8; 8] = b"CODENAV\0";
+
+/// Bump when the graph layout changes in a way serde defaults can't absorb, and teach
+/// [`fast_compressed::load_from_file`](super::fast_compressed::load_from_file) to
+/// convert graphs of the old version
+pub const INDEX_FORMAT_VERSION: u32 = 2;
+
+/// What kind of file [`inspect`] found
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum IndexKind {
+    /// A graph written by `index`, e.g. `codenav.bin`
+    Graph,
+    /// The per-file parse cache in `.code-navigator/index.cache`
+    FileCache,
+}
+
+/// Where a file came from, as stored at the start of a graph file or read by [`inspect`]
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct IndexHeader {
+    pub kind: IndexKind,
+    pub format_version: u32,
+    /// Version of code-navigator that wrote the file; unknown for files from before
+    /// versioning
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub tool_version: Option<String>,
+    /// When the file was written, RFC 3339
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub created_at: Option<String>,
+    pub language: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub root_path: Option<String>,
+    /// Source files the graph holds symbols from, as `stats` counts them, or files cached
+    pub files: usize,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub symbols: Option<usize>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub calls: Option<usize>,
+}
+
+impl IndexHeader {
+    /// The header of a graph about to be written by this build
+    pub fn of(graph: &CodeGraph) -> Self {
+        Self {
+            kind: IndexKind::Graph,
+            format_version: INDEX_FORMAT_VERSION,
+            tool_version: Some(env!("CARGO_PKG_VERSION").to_string()),
+            created_at: Some(graph.metadata.generated_at.clone()),
+            language: graph.metadata.language.clone(),
+            root_path: Some(graph.metadata.root_path.clone()),
+            files: graph.indexed_files(),
+            symbols: Some(graph.nodes.len()),
+            calls: Some(graph.edges.len()),
+        }
+    }
+
+    /// The version this build reads for the file's kind
+    pub fn supported_version(&self) -> u32 {
+        match self.kind {
+            IndexKind::Graph => INDEX_FORMAT_VERSION,
+            IndexKind::FileCache => FILE_CACHE_VERSION,
+        }
+    }
+}
+
+/// `header` and `body` laid out as a graph file or per-file cache
+pub fn encode(header: &IndexHeader, body: &[u8]) -> Result<Vec<u8>> {
+    let header_json = serde_json::to_vec(header)?;
+    let mut bytes = Vec::with_capacity(16 + header_json.len() + body.len());
+    bytes.extend_from_slice(MAGIC);
+    bytes.extend_from_slice(&header.format_version.to_le_bytes());
+    bytes.extend_from_slice(&(header_json.len() as u32).to_le_bytes());
+    bytes.extend_from_slice(&header_json);
+    bytes.extend_from_slice(body);
+    Ok(bytes)
+}
+
+/// A file's format version, its header if that parses, and the body after it
+pub type Parts<'a> = (u32, Option<IndexHeader>, &'a [u8]);
+
+/// Split a file written by [`encode`] into its [`Parts`]; `None` for a file from before
+/// headers. The header is `None` when it doesn't parse, as a newer format's may not.
+pub fn split<'a>(bytes: &'a [u8], path: &Path) -> Result<Option<Parts<'a>>> {
+    let Some(rest) = bytes.strip_prefix(MAGIC.as_slice()) else {
+        return Ok(None);
+    };
+    let (version, header_len) = match rest.get(..8) {
+        Some(fixed) => (
+            u32::from_le_bytes(fixed[..4].try_into().unwrap()),
+            u32::from_le_bytes(fixed[4..].try_into().unwrap()) as usize,
+        ),
+        None => bail!("{} is truncated", path.display()),
+    };
+    let header_bytes = rest
+        .get(8..8 + header_len)
+        .with_context(|| format!("{} is truncated", path.display()))?;
+    let header = serde_json::from_slice(header_bytes).ok();
+    Ok(Some((version, header, &rest[8 + header_len..])))
+}
+
+/// Split a graph file into its header and body; `None` for a file from before
+/// versioning. Fails on a newer format, naming the version and the tool that wrote it.
+pub fn decode<'a>(bytes: &'a [u8], path: &Path) -> Result<Option<(IndexHeader, &'a [u8])>> {
+    let Some((version, header, body)) = split(bytes, path)? else {
+        return Ok(None);
+    };
+    if header
+        .as_ref()
+        .is_some_and(|header| header.kind == IndexKind::FileCache)
+    {
+        bail!("{} is a per-file cache, not a graph", path.display());
+    }
+    // Only the version is read from a newer header, whose layout may have changed
+    if version > INDEX_FORMAT_VERSION {
+        let writer = header
+            .and_then(|header| header.tool_version)
+            .map(|tool| format!(" by code-navigator {}", tool))
+            .unwrap_or_default();
+        bail!(
+            "{} was written{} in index format {}, newer than format {} that code-navigator {} reads; upgrade code-navigator or re-index",
+            path.display(),
+            writer,
+            version,
+            INDEX_FORMAT_VERSION,
+            env!("CARGO_PKG_VERSION")
+        );
+    }
+    let header = header.with_context(|| format!("Corrupt index header in {}", path.display()))?;
+    Ok(Some((header, body)))
+}
+
+/// Read the version and provenance of a graph file or per-file cache. The header is
+/// read from the start of the file alone; graphs and caches from before headers are
+/// decompressed, but their symbols are skipped rather than loaded.
+pub fn inspect(path: &Path) -> Result<IndexHeader> {
+    let mut file =
+        File::open(path).with_context(|| format!("Failed to open {}", path.display()))?;
+    let mut fixed = [0u8; 16];
+    if file.read_exact(&mut fixed).is_ok() && fixed.starts_with(MAGIC) {
+        let header_len = u32::from_le_bytes(fixed[12..].try_into().unwrap()) as usize;
+        let mut header = vec![0u8; header_len];
+        file.read_exact(&mut header)
+            .with_context(|| format!("{} is truncated", path.display()))?;
+        // Described even when newer, as long as the header kept its layout
+        let version = u32::from_le_bytes(fixed[8..12].try_into().unwrap());
+        return serde_json::from_slice(&header).with_context(|| {
+            format!(
+                "{} has a format {} header this code-navigator can't read",
+                path.display(),
+                version
+            )
+        });
+    }
+
+    let compressed = std::fs::read(path)?;
+    let json = lz4_flex::decompress_size_prepended(&compressed)
+        .map_err(|e| anyhow::anyhow!("{} is not a code-navigator index: {}", path.display(), e))?;
+    let probe: Probe = serde_json::from_slice(&json)
+        .with_context(|| format!("{} is not a code-navigator index", path.display()))?;
+    match (probe.metadata, probe.format_version) {
+        (Some(metadata), _) => Ok(IndexHeader {
+            kind: IndexKind::Graph,
+            format_version: 1,
+            tool_version: None,
+            created_at: Some(metadata.generated_at),
+            language: metadata.language,
+            root_path: Some(metadata.root_path),
+            files: metadata.stats.files_parsed,
+            symbols: Some(probe.nodes.len()),
+            calls: Some(probe.edges.len()),
+        }),
+        (None, Some(format_version)) => Ok(IndexHeader {
+            kind: IndexKind::FileCache,
+            format_version,
+            tool_version: probe.tool_version,
+            created_at: probe.created_at,
+            language: probe.language.unwrap_or_default(),
+            root_path: None,
+            files: probe.files.len(),
+            symbols: None,
+            calls: None,
+        }),
+        (None, None) => bail!("{} is not a code-navigator index", path.display()),
+    }
+}
+
+/// The parts of a graph or per-file cache [`inspect`] reports; the rest is skipped
+#[derive(Deserialize)]
+struct Probe {
+    metadata: Option<ProbeMetadata>,
+    #[serde(default)]
+    nodes: Vec<IgnoredAny>,
+    #[serde(default)]
+    edges: Vec<IgnoredAny>,
+    format_version: Option<u32>,
+    tool_version: Option<String>,
+    created_at: Option<String>,
+    language: Option<String>,
+    #[serde(default)]
+    files: BTreeMap<String, IgnoredAny>,
+}
+
+#[derive(Deserialize)]
+struct ProbeMetadata {
+    generated_at: String,
+    language: String,
+    root_path: String,
+    stats: ProbeStats,
+}
+
+#[derive(Deserialize)]
+struct ProbeStats {
+    files_parsed: usize,
+}
@@ -56,6 +56,14 @@ pub fn save_to_file(graph: &CodeGraph, output_path: &Path) -> Result<()> {
         file,
         "  <key id=\"d6\" for=\"edge\" attr.name=\"call_site\" attr.type=\"string\"/>"
     )?;
+    writeln!(
+        file,
+        "  <key id=\"d7\" for=\"node\" attr.name=\"start_offset\" attr.type=\"int\"/>"
+    )?;
+    writeln!(
+        file,
+        "  <key id=\"d8\" for=\"node\" attr.name=\"end_offset\" attr.type=\"int\"/>"
+    )?;
     writeln!(file)?;
 
     // Start graph
@@ -88,6 +96,11 @@ pub fn save_to_file(graph: &CodeGraph, output_path: &Path) -> Result<()> {
             "      <data key=\"d4\">{}</data>",
             escape_xml(&node.package)
         )?;
+        // Byte range of the whole declaration, when the parser recorded one
+        if let Some(span) = &node.span {
+            writeln!(file, "      <data key=\"d7\">{}</data>", span.start.offset)?;
+            writeln!(file, "      <data key=\"d8\">{}</data>", span.end.offset)?;
+        }
         writeln!(file, "    </node>")?;
     }
 
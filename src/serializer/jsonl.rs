@@ -1,7 +1,7 @@
 use crate::core::CodeGraph;
 use anyhow::Result;
 use serde_json;
-use std::collections::HashMap;
+use std::collections::BTreeMap;
 use std::fs::File;
 use std::io::{BufWriter, Write};
 
@@ -36,6 +36,7 @@ pub fn export_jsonl(graph: &CodeGraph, output_path: &str) -> Result<()> {
             "node_type": format!("{:?}", node.node_type),
             "file_path": node.file_path.display().to_string(),
             "line": node.line,
+            "column": node.column,
             "end_line": node.end_line,
             "package": node.package,
             "signature": node.signature,
@@ -44,20 +45,28 @@ pub fn export_jsonl(graph: &CodeGraph, output_path: &str) -> Result<()> {
             "documentation": node.documentation,
             "tags": node.tags,
             "metadata": node.metadata,
+            "name_span": node.name_span,
+            "span": node.span,
         });
         writeln!(writer, "{}", serde_json::to_string(&node_line)?)?;
     }
 
-    // Write each edge as a line
-    for edge in &graph.edges {
+    // Write each edge as a line, then each non-call reference
+    let lines = graph
+        .edges
+        .iter()
+        .map(|edge| ("edge", edge))
+        .chain(graph.references.iter().map(|edge| ("reference", edge)));
+    for (line_type, edge) in lines {
         let edge_line = serde_json::json!({
-            "type": "edge",
+            "type": line_type,
             "from": edge.from,
             "to": edge.to,
             "edge_type": format!("{:?}", edge.edge_type),
             "call_site": edge.call_site,
             "file_path": edge.file_path.display().to_string(),
             "line": edge.line,
+            "column": edge.column,
             "metadata": edge.metadata,
         });
         writeln!(writer, "{}", serde_json::to_string(&edge_line)?)?;
@@ -79,6 +88,7 @@ pub fn load_from_jsonl(input_path: &str) -> Result<CodeGraph> {
     let mut metadata: Option<GraphMetadata> = None;
     let mut nodes = Vec::new();
     let mut edges = Vec::new();
+    let mut references = Vec::new();
 
     for line in reader.lines() {
         let line = line?;
@@ -99,9 +109,17 @@ pub fn load_from_jsonl(input_path: &str) -> Result<CodeGraph> {
                         total_nodes: value["stats"]["total_nodes"].as_u64().unwrap_or(0) as usize,
                         total_edges: value["stats"]["total_edges"].as_u64().unwrap_or(0) as usize,
                         files_parsed: value["stats"]["files_parsed"].as_u64().unwrap_or(0) as usize,
+                        index_duration_ms: None,
+                        cache: None,
                     },
-                    file_metadata: HashMap::new(),
+                    file_metadata: BTreeMap::new(),
                     git_commit_hash: None,
+                    diagnostics: Vec::new(),
+                    build_excluded: BTreeMap::new(),
+                    file_hashes: BTreeMap::new(),
+                    references_spilled: false,
+                    roots: Vec::new(),
+                    build: None,
                 });
             }
             Some("node") => {
@@ -110,6 +128,14 @@ pub fn load_from_jsonl(input_path: &str) -> Result<CodeGraph> {
                     "Method" => NodeType::Method,
                     "HttpHandler" => NodeType::HttpHandler,
                     "Middleware" => NodeType::Middleware,
+                    "Benchmark" => NodeType::Benchmark,
+                    "Fuzz" => NodeType::Fuzz,
+                    "Struct" => NodeType::Struct,
+                    "Interface" => NodeType::Interface,
+                    "Type" => NodeType::Type,
+                    "Const" => NodeType::Const,
+                    "Var" => NodeType::Var,
+                    "Field" => NodeType::Field,
                     _ => NodeType::Function,
                 };
 
@@ -146,14 +172,14 @@ pub fn load_from_jsonl(input_path: &str) -> Result<CodeGraph> {
                     Vec::new()
                 };
 
-                let metadata_map: std::collections::HashMap<String, String> =
+                let metadata_map: BTreeMap<String, String> =
                     if let Some(meta_obj) = value["metadata"].as_object() {
                         meta_obj
                             .iter()
                             .filter_map(|(k, v)| Some((k.clone(), v.as_str()?.to_string())))
                             .collect()
                     } else {
-                        std::collections::HashMap::new()
+                        BTreeMap::new()
                     };
 
                 let node = Node {
@@ -162,6 +188,7 @@ pub fn load_from_jsonl(input_path: &str) -> Result<CodeGraph> {
                     node_type,
                     file_path: PathBuf::from(value["file_path"].as_str().unwrap_or("")),
                     line: value["line"].as_u64().unwrap_or(0) as usize,
+                    column: value["column"].as_u64().unwrap_or(0) as usize,
                     end_line: value["end_line"].as_u64().unwrap_or(0) as usize,
                     package: value["package"].as_str().unwrap_or("").to_string(),
                     signature: value["signature"].as_str().unwrap_or("").to_string(),
@@ -170,25 +197,28 @@ pub fn load_from_jsonl(input_path: &str) -> Result<CodeGraph> {
                     documentation: value["documentation"].as_str().map(|s| s.to_string()),
                     tags,
                     metadata: metadata_map,
+                    name_span: serde_json::from_value(value["name_span"].clone()).unwrap_or(None),
+                    span: serde_json::from_value(value["span"].clone()).unwrap_or(None),
                 };
                 nodes.push(node);
             }
-            Some("edge") => {
+            Some(line_type @ ("edge" | "reference")) => {
                 let edge_type = match value["edge_type"].as_str().unwrap_or("Calls") {
                     "Calls" => EdgeType::Calls,
                     "Imports" => EdgeType::Imports,
                     "Implements" => EdgeType::Implements,
+                    "References" => EdgeType::References,
                     _ => EdgeType::Calls,
                 };
 
-                let metadata_map: std::collections::HashMap<String, String> =
+                let metadata_map: BTreeMap<String, String> =
                     if let Some(meta_obj) = value["metadata"].as_object() {
                         meta_obj
                             .iter()
                             .filter_map(|(k, v)| Some((k.clone(), v.as_str()?.to_string())))
                             .collect()
                     } else {
-                        std::collections::HashMap::new()
+                        BTreeMap::new()
                     };
 
                 let edge = Edge {
@@ -198,9 +228,14 @@ pub fn load_from_jsonl(input_path: &str) -> Result<CodeGraph> {
                     call_site: value["call_site"].as_str().unwrap_or("").to_string(),
                     file_path: PathBuf::from(value["file_path"].as_str().unwrap_or("")),
                     line: value["line"].as_u64().unwrap_or(0) as usize,
+                    column: value["column"].as_u64().unwrap_or(0) as usize,
                     metadata: metadata_map,
                 };
-                edges.push(edge);
+                if line_type == "reference" {
+                    references.push(edge);
+                } else {
+                    edges.push(edge);
+                }
             }
             _ => {
                 // Unknown type, skip
@@ -218,15 +253,26 @@ pub fn load_from_jsonl(input_path: &str) -> Result<CodeGraph> {
             total_nodes: nodes.len(),
             total_edges: edges.len(),
             files_parsed: 0,
+            index_duration_ms: None,
+            cache: None,
         },
-        file_metadata: HashMap::new(),
+        file_metadata: BTreeMap::new(),
         git_commit_hash: None,
+        diagnostics: Vec::new(),
+        build_excluded: BTreeMap::new(),
+        file_hashes: BTreeMap::new(),
+        references_spilled: false,
+        roots: Vec::new(),
+        build: None,
     });
 
     let mut graph = CodeGraph {
         metadata,
         nodes,
         edges,
+        references,
+        imports: Vec::new(),
+        modules: Vec::new(),
         node_by_id: Default::default(),
         outgoing: Default::default(),
         incoming: Default::default(),
@@ -258,9 +304,17 @@ mod tests {
                     total_nodes: 1,
                     total_edges: 1,
                     files_parsed: 1,
+                    index_duration_ms: None,
+                    cache: None,
                 },
-                file_metadata: HashMap::new(),
+                file_metadata: BTreeMap::new(),
                 git_commit_hash: None,
+                diagnostics: Vec::new(),
+                build_excluded: BTreeMap::new(),
+                file_hashes: BTreeMap::new(),
+                references_spilled: false,
+                roots: Vec::new(),
+                build: None,
             },
             nodes: vec![Node {
                 id: "test:func1:10".to_string(),
@@ -268,6 +322,7 @@ mod tests {
                 node_type: NodeType::Function,
                 file_path: PathBuf::from("test.go"),
                 line: 10,
+                column: 6,
                 end_line: 20,
                 package: "main".to_string(),
                 signature: "func func1()".to_string(),
@@ -276,6 +331,8 @@ mod tests {
                 documentation: None,
                 tags: vec![],
                 metadata: Default::default(),
+                name_span: None,
+                span: None,
             }],
             edges: vec![Edge {
                 from: "test:func1:10".to_string(),
@@ -284,8 +341,12 @@ mod tests {
                 call_site: "func2()".to_string(),
                 file_path: PathBuf::from("test.go"),
                 line: 15,
+                column: 5,
                 metadata: Default::default(),
             }],
+            references: vec![],
+            imports: vec![],
+            modules: vec![],
             node_by_id: Default::default(),
             outgoing: Default::default(),
             incoming: Default::default(),
@@ -308,5 +369,7 @@ mod tests {
         assert_eq!(loaded_graph.edges.len(), 1);
         assert_eq!(loaded_graph.nodes[0].name, "func1");
         assert_eq!(loaded_graph.edges[0].to, "func2");
+        assert_eq!(loaded_graph.nodes[0].column, 6);
+        assert_eq!(loaded_graph.edges[0].column, 5);
     }
 }
@@ -0,0 +1,210 @@
+use super::dot::relative_path;
+use super::mermaid::{self, MermaidOptions};
+use crate::core::{CodeGraph, DeadCodeOptions, Node, NodeType};
+use anyhow::{bail, Result};
+use std::collections::{BTreeMap, HashSet};
+use std::fmt::Write as _;
+use std::fs;
+use std::path::Path;
+
+/// What `report` covers and which sections it includes
+#[derive(Debug, Clone)]
+pub struct ReportOptions {
+    /// Only this package, by name or import path; the whole index when `None`
+    pub package: Option<String>,
+    /// A table of symbols per file
+    pub symbols: bool,
+    /// The calls between functions as a Mermaid flowchart
+    pub call_graph: bool,
+    /// The functions reachability starts from
+    pub entry_points: bool,
+    /// Functions and methods no entry point reaches
+    pub dead_code: bool,
+}
+
+impl Default for ReportOptions {
+    fn default() -> Self {
+        Self {
+            package: None,
+            symbols: true,
+            call_graph: true,
+            entry_points: true,
+            dead_code: true,
+        }
+    }
+}
+
+pub fn save_to_file(graph: &CodeGraph, output_path: &Path, options: &ReportOptions) -> Result<()> {
+    fs::write(output_path, render(graph, options)?)?;
+    Ok(())
+}
+
+/// Render a Markdown architecture report. Paths are relative to the indexed root and
+/// every list is sorted, with nothing taken from the clock or the machine, so the same
+/// code always gives the same report and it can be committed and diffed.
+pub fn render(graph: &CodeGraph, options: &ReportOptions) -> Result<String> {
+    let root = graph.metadata.root_path.as_str();
+    let in_scope = |node: &Node| match &options.package {
+        Some(package) => {
+            node.package == *package || node.metadata.get("import_path") == Some(package)
+        }
+        None => true,
+    };
+
+    let mut nodes: Vec<&Node> = graph.nodes.iter().filter(|node| in_scope(node)).collect();
+    if nodes.is_empty() {
+        match &options.package {
+            Some(package) => bail!("No symbols found in package {}", package),
+            None => bail!("The index has no symbols"),
+        }
+    }
+    nodes.sort_by(|a, b| (&a.file_path, a.line, &a.name).cmp(&(&b.file_path, b.line, &b.name)));
+
+    let title = match &options.package {
+        Some(package) => package.clone(),
+        None => Path::new(root)
+            .file_name()
+            .map(|name| name.to_string_lossy().to_string())
+            .unwrap_or_else(|| root.to_string()),
+    };
+    let mut out = format!("# Architecture report: {}\n", title);
+
+    if options.symbols {
+        out.push_str("\n## Symbols\n");
+        // Fields show in their struct's declaration and literals in their function's body
+        let mut by_file: BTreeMap<String, Vec<&Node>> = BTreeMap::new();
+        for node in nodes
+            .iter()
+            .filter(|node| node.node_type != NodeType::Field)
+            .filter(|node| !node.metadata.contains_key("enclosing"))
+        {
+            by_file
+                .entry(relative_path(&node.file_path, root))
+                .or_default()
+                .push(node);
+        }
+        for (file, symbols) in &by_file {
+            let _ = writeln!(out, "\n### {}\n", file);
+            out.push_str("| Name | Kind | Signature | Summary |\n");
+            out.push_str("|------|------|-----------|---------|\n");
+            for node in symbols {
+                let _ = writeln!(
+                    out,
+                    "| `{}` | {} | `{}` | {} |",
+                    escape_cell(&node.name),
+                    node.node_type.as_str(),
+                    escape_cell(node.declaration()),
+                    escape_cell(&summary(node.documentation.as_deref().unwrap_or("")))
+                );
+            }
+        }
+    }
+
+    if options.call_graph {
+        let functions: Vec<&Node> = nodes
+            .iter()
+            .copied()
+            .filter(|node| {
+                matches!(
+                    node.node_type,
+                    NodeType::Function
+                        | NodeType::Method
+                        | NodeType::HttpHandler
+                        | NodeType::Middleware
+                        | NodeType::Benchmark
+                        | NodeType::Fuzz
+                )
+            })
+            .collect();
+        let mut calls = CodeGraph::new(root.to_string(), graph.metadata.language.clone());
+        let ids: HashSet<&str> = functions.iter().map(|node| node.id.as_str()).collect();
+        for node in functions {
+            calls.add_node(node.clone());
+        }
+        for edge in graph
+            .edges
+            .iter()
+            .filter(|edge| ids.contains(edge.from.as_str()))
+        {
+            calls.add_edge(edge.clone());
+        }
+
+        out.push_str("\n## Call graph\n\n```mermaid\n");
+        out.push_str(&mermaid::render(&calls, &MermaidOptions::default()));
+        out.push_str("```\n");
+    }
+
+    if options.entry_points || options.dead_code {
+        let report = graph.dead_code(&DeadCodeOptions::default())?;
+        let list = |out: &mut String, nodes: &[&Node]| {
+            for node in nodes.iter().filter(|node| in_scope(node)) {
+                let _ = writeln!(
+                    out,
+                    "- `{}` ({}:{})",
+                    node.name,
+                    relative_path(&node.file_path, root),
+                    node.line
+                );
+            }
+        };
+
+        if options.entry_points {
+            out.push_str("\n## Entry points\n\n");
+            if report.roots.iter().any(|node| in_scope(node)) {
+                list(&mut out, &report.roots);
+            } else {
+                out.push_str("None.\n");
+            }
+        }
+        if options.dead_code {
+            out.push_str("\n## Dead code\n\n");
+            if report.dead.iter().any(|node| in_scope(node)) {
+                out.push_str("Functions and methods no entry point reaches:\n\n");
+                list(&mut out, &report.dead);
+            } else {
+                out.push_str("Every function is reachable from an entry point.\n");
+            }
+        }
+    }
+
+    Ok(out)
+}
+
+/// The first sentence of a doc comment, on one line
+fn summary(doc: &str) -> String {
+    let paragraph = doc.split("\n\n").next().unwrap_or("");
+    let text = paragraph.split_whitespace().collect::<Vec<_>>().join(" ");
+    match text.find(". ") {
+        Some(end) => text[..=end].to_string(),
+        None => text,
+    }
+}
+
+/// A pipe would end the table cell early
+fn escape_cell(text: &str) -> String {
+    text.replace('|', "\\|")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_summary_is_the_first_sentence() {
+        assert_eq!(summary("Add adds two numbers"), "Add adds two numbers");
+        assert_eq!(
+            summary("Parse reads a file.\nIt returns an error\nwhen the file is missing."),
+            "Parse reads a file."
+        );
+        assert_eq!(
+            summary("First paragraph\n\nSecond paragraph"),
+            "First paragraph"
+        );
+        assert_eq!(summary(""), "");
+    }
+
+    #[test]
+    fn test_escape_cell() {
+        assert_eq!(escape_cell("a || b"), "a \\|\\| b");
+    }
+}
@@ -0,0 +1,366 @@
+use crate::core::{CodeGraph, EdgeKind, GroupBy, GroupTarget, Node};
+use anyhow::Result;
+use std::collections::{BTreeSet, HashMap, HashSet};
+use std::fmt::Write as _;
+use std::fs;
+use std::path::Path;
+
+/// Rendering options for Mermaid output
+#[derive(Debug, Clone)]
+pub struct MermaidOptions {
+    /// Draw calls to functions outside the indexed code (e.g. fmt.Println) as dashed stadiums
+    pub include_external: bool,
+    /// Group nodes into one subgraph per file or package and merge the calls between
+    /// groups into one link labelled with their count
+    pub group_by: Option<GroupBy>,
+    /// With `group_by`, draw each group as a single node
+    pub collapse: bool,
+    /// With `collapse`, keep the calls within a group as a self-link
+    pub keep_internal: bool,
+}
+
+impl Default for MermaidOptions {
+    fn default() -> Self {
+        Self {
+            include_external: true,
+            group_by: None,
+            collapse: false,
+            keep_internal: false,
+        }
+    }
+}
+
+/// A link between two Mermaid IDs, with its kind and whether it is inferred
+type MermaidEdge = (String, String, EdgeKind, bool);
+
+pub fn save_to_file(graph: &CodeGraph, output_path: &Path, options: &MermaidOptions) -> Result<()> {
+    fs::write(output_path, render(graph, options))?;
+    Ok(())
+}
+
+/// Render the graph as a Mermaid `graph TD` flowchart. Mermaid IDs only allow a small
+/// character set, so each node gets a sanitized ID (`(*Calculator).Add` -> `Calculator_Add`)
+/// and keeps its original name as the label. Output is sorted for stable diffs.
+pub fn render(graph: &CodeGraph, options: &MermaidOptions) -> String {
+    if let Some(by) = options.group_by {
+        return render_grouped(graph, options, by);
+    }
+    let mut nodes: Vec<&Node> = graph.nodes.iter().collect();
+    nodes.sort_by(|a, b| (&a.file_path, a.line, &a.name).cmp(&(&b.file_path, b.line, &b.name)));
+    let mut taken = HashSet::new();
+    let ids = node_ids(&nodes, &mut taken);
+    let (externals, edges) = call_edges(graph, &ids, options.include_external);
+
+    let mut out = String::from("graph TD\n");
+    for node in &nodes {
+        let _ = writeln!(
+            out,
+            "    {}[\"{}\"]",
+            ids[node.id.as_str()],
+            escape_label(&node.name)
+        );
+    }
+    for (id, name) in &externals {
+        let _ = writeln!(out, "    {}([\"{}\"])", id, escape_label(name));
+    }
+    for edge in &edges {
+        out.push_str(&edge_statement(edge));
+    }
+    push_external_class(&mut out, &externals);
+    out
+}
+
+/// Assign IDs in sorted order so duplicates are numbered consistently
+fn node_ids<'a>(nodes: &[&'a Node], taken: &mut HashSet<String>) -> HashMap<&'a str, String> {
+    let mut ids = HashMap::new();
+    for node in nodes {
+        ids.insert(node.id.as_str(), unique_id(sanitize_id(&node.name), taken));
+    }
+    ids
+}
+
+/// `base`, numbered from 2 when it is already taken
+fn unique_id(base: String, taken: &mut HashSet<String>) -> String {
+    let mut id = base.clone();
+    let mut n = 2;
+    while !taken.insert(id.clone()) {
+        id = format!("{}_{}", base, n);
+        n += 1;
+    }
+    id
+}
+
+/// The calls of the graph between Mermaid IDs, and the IDs and names of the functions
+/// outside the index they reach
+fn call_edges(
+    graph: &CodeGraph,
+    ids: &HashMap<&str, String>,
+    include_external: bool,
+) -> (BTreeSet<(String, String)>, BTreeSet<MermaidEdge>) {
+    let mut externals = BTreeSet::new();
+    let mut edges = BTreeSet::new();
+    for edge in &graph.edges {
+        let Some(from) = ids.get(edge.from.as_str()) else {
+            continue;
+        };
+
+        let targets = graph.edge_targets(edge);
+        if targets.is_empty() {
+            if !include_external {
+                continue;
+            }
+            let name = match edge.metadata.get("qualifier") {
+                Some(qualifier) => format!("{}.{}", qualifier, edge.to),
+                None => edge.to.clone(),
+            };
+            let id = format!("ext_{}", sanitize_id(&name));
+            edges.insert((from.clone(), id.clone(), edge.kind(), false));
+            externals.insert((id, name));
+        } else {
+            for target in targets {
+                if let Some(to) = ids.get(target.id.as_str()) {
+                    edges.insert((from.clone(), to.clone(), edge.kind(), edge.is_indirect()));
+                }
+            }
+        }
+    }
+    // A call written in the source supersedes the same call inferred through a parameter
+    let direct: BTreeSet<(String, String, EdgeKind)> = edges
+        .iter()
+        .filter(|(_, _, _, indirect)| !indirect)
+        .map(|(from, to, kind, _)| (from.clone(), to.clone(), *kind))
+        .collect();
+    edges.retain(|(from, to, kind, indirect)| {
+        !indirect || !direct.contains(&(from.clone(), to.clone(), *kind))
+    });
+    (externals, edges)
+}
+
+/// Render the graph with its nodes grouped by `by`: one subgraph per group, or one node
+/// per group when collapsed. Calls between groups become one link per pair of groups,
+/// labelled with the number of calls it stands for.
+fn render_grouped(graph: &CodeGraph, options: &MermaidOptions, by: GroupBy) -> String {
+    let grouped = graph.group_calls(by);
+    let mut taken = HashSet::new();
+    let nodes: Vec<&Node> = grouped
+        .groups
+        .iter()
+        .flat_map(|group| group.nodes.iter().copied())
+        .collect();
+    let ids = match options.collapse {
+        true => HashMap::new(),
+        false => node_ids(&nodes, &mut taken),
+    };
+    let group_ids: Vec<String> = grouped
+        .groups
+        .iter()
+        .map(|group| unique_id(format!("group_{}", sanitize_id(&group.name)), &mut taken))
+        .collect();
+
+    let mut out = String::from("graph TD\n");
+    for (group, id) in grouped.groups.iter().zip(&group_ids) {
+        if options.collapse {
+            let _ = writeln!(
+                out,
+                "    {}[\"{}<br/>{} symbols\"]",
+                id,
+                escape_label(&group.name),
+                group.nodes.len()
+            );
+            continue;
+        }
+        let _ = writeln!(
+            out,
+            "    subgraph {}[\"{}\"]",
+            id,
+            escape_label(&group.name)
+        );
+        for node in &group.nodes {
+            let _ = writeln!(
+                out,
+                "        {}[\"{}\"]",
+                ids[node.id.as_str()],
+                escape_label(&node.name)
+            );
+        }
+        out.push_str("    end\n");
+    }
+
+    let calls: Vec<_> = grouped
+        .calls
+        .iter()
+        .filter(|call| match &call.to {
+            GroupTarget::External(_) => options.include_external,
+            // Linked symbol to symbol inside a subgraph
+            GroupTarget::Group(_) if call.is_internal() => {
+                options.collapse && options.keep_internal
+            }
+            GroupTarget::Group(_) => true,
+        })
+        .collect();
+    let externals: BTreeSet<(String, String)> = calls
+        .iter()
+        .filter_map(|call| match &call.to {
+            GroupTarget::External(name) => {
+                Some((format!("ext_{}", sanitize_id(name)), name.clone()))
+            }
+            GroupTarget::Group(_) => None,
+        })
+        .collect();
+    for (id, name) in &externals {
+        let _ = writeln!(out, "    {}([\"{}\"])", id, escape_label(name));
+    }
+
+    if !options.collapse {
+        let membership = grouped.membership();
+        let group_of: HashMap<&str, usize> = ids
+            .iter()
+            .filter_map(|(node, id)| Some((id.as_str(), *membership.get(node)?)))
+            .collect();
+        let (_, edges) = call_edges(graph, &ids, false);
+        for edge in &edges {
+            let from = group_of.get(edge.0.as_str());
+            if from.is_some() && from == group_of.get(edge.1.as_str()) {
+                out.push_str(&edge_statement(edge));
+            }
+        }
+    }
+    for call in calls {
+        let to = match &call.to {
+            GroupTarget::Group(to) => group_ids[*to].clone(),
+            GroupTarget::External(name) => format!("ext_{}", sanitize_id(name)),
+        };
+        let _ = writeln!(
+            out,
+            "    {} -->|{} call{}| {}",
+            group_ids[call.from],
+            call.calls,
+            if call.calls == 1 { "" } else { "s" },
+            to
+        );
+    }
+    push_external_class(&mut out, &externals);
+    out
+}
+
+fn edge_statement((from, to, kind, indirect): &MermaidEdge) -> String {
+    let arrow = if *indirect { "-.->" } else { "-->" };
+    match kind {
+        EdgeKind::Normal => format!("    {} {} {}\n", from, arrow, to),
+        _ => format!("    {} {}|{}| {}\n", from, arrow, kind.as_str(), to),
+    }
+}
+
+/// Dash the outline of the external functions
+fn push_external_class(out: &mut String, externals: &BTreeSet<(String, String)>) {
+    if !externals.is_empty() {
+        let external_ids: Vec<&str> = externals.iter().map(|(id, _)| id.as_str()).collect();
+        out.push_str("    classDef external stroke-dasharray: 5 5\n");
+        let _ = writeln!(out, "    class {} external", external_ids.join(","));
+    }
+}
+
+/// Reduce a symbol name to `[A-Za-z0-9_]`, collapsing runs of other characters
+fn sanitize_id(name: &str) -> String {
+    let mut id = String::new();
+    for c in name.chars() {
+        if c.is_ascii_alphanumeric() {
+            id.push(c);
+        } else if !id.is_empty() && !id.ends_with('_') {
+            id.push('_');
+        }
+    }
+    let id = id.trim_end_matches('_').to_string();
+
+    // `end` is a Mermaid keyword and breaks the flowchart
+    match id.as_str() {
+        "" => "node".to_string(),
+        "end" => "end_".to_string(),
+        _ => id,
+    }
+}
+
+fn escape_label(s: &str) -> String {
+    s.replace('"', "#quot;")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::core::{Edge, EdgeType, NodeType};
+    use std::path::PathBuf;
+
+    fn node(file: &str, name: &str, line: usize, node_type: NodeType) -> Node {
+        Node::new(
+            format!("{}:{}:{}", file, name, line),
+            name.to_string(),
+            node_type,
+            PathBuf::from(file),
+            line,
+            line + 2,
+            "app".to_string(),
+            String::new(),
+        )
+    }
+
+    #[test]
+    fn test_sanitize_id() {
+        assert_eq!(sanitize_id("(*Calculator).Add"), "Calculator_Add");
+        assert_eq!(sanitize_id("Point.Norm"), "Point_Norm");
+        assert_eq!(sanitize_id("main"), "main");
+        assert_eq!(sanitize_id("end"), "end_");
+        assert_eq!(sanitize_id("$"), "node");
+    }
+
+    #[test]
+    fn test_render_sanitizes_and_dedupes_ids() {
+        let mut graph = CodeGraph::new("/src".to_string(), "go".to_string());
+        graph.add_node(node("/src/a.go", "(*Server).Start", 3, NodeType::Method));
+        graph.add_node(node("/src/b/b.go", "Run", 1, NodeType::Function));
+        graph.add_node(node("/src/c/c.go", "Run", 1, NodeType::Function));
+
+        let mut to_start = Edge::new(
+            "/src/b/b.go:Run:1".to_string(),
+            "(*Server).Start".to_string(),
+            EdgeType::Calls,
+            "s.Start()".to_string(),
+            PathBuf::from("/src/b/b.go"),
+            2,
+        );
+        to_start.metadata.insert(
+            "target_id".to_string(),
+            "/src/a.go:(*Server).Start:3".to_string(),
+        );
+        graph.add_edge(to_start);
+        let mut println = Edge::new(
+            "/src/c/c.go:Run:1".to_string(),
+            "Println".to_string(),
+            EdgeType::Calls,
+            "fmt.Println()".to_string(),
+            PathBuf::from("/src/c/c.go"),
+            2,
+        );
+        println
+            .metadata
+            .insert("qualifier".to_string(), "fmt".to_string());
+        graph.add_edge(println);
+
+        let expected = r#"graph TD
+    Server_Start["(*Server).Start"]
+    Run["Run"]
+    Run_2["Run"]
+    ext_fmt_Println(["fmt.Println"])
+    Run --> Server_Start
+    Run_2 --> ext_fmt_Println
+    classDef external stroke-dasharray: 5 5
+    class ext_fmt_Println external
+"#;
+        assert_eq!(render(&graph, &MermaidOptions::default()), expected);
+
+        let options = MermaidOptions {
+            include_external: false,
+            ..Default::default()
+        };
+        assert!(!render(&graph, &options).contains("fmt.Println"));
+    }
+}
@@ -3,8 +3,13 @@ pub mod compressed;
 pub mod csv;
 pub mod dot;
 pub mod fast_compressed;
+pub mod file_cache;
+pub mod format;
 pub mod graphml;
 pub mod index_cache;
 pub mod json;
 pub mod jsonl;
+pub mod markdown;
+pub mod mermaid;
 pub mod optimized_binary;
+pub mod sarif;
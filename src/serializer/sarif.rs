@@ -0,0 +1,151 @@
+use crate::core::{Finding, FindingLocation, Rule};
+use anyhow::Result;
+use serde_json::{json, Map, Value};
+use std::path::{Component, Path, PathBuf};
+
+/// Base ID artifact URIs are relative to; code scanning resolves it to the checkout
+pub const SRCROOT: &str = "%SRCROOT%";
+
+/// Render `findings` as a SARIF 2.1.0 log with one run, describing every rule in
+/// `rules`. File URIs are relative to the repository containing `root`, the indexed
+/// directory, so GitHub code scanning can place results in the checkout; files outside
+/// it get absolute `file://` URIs.
+pub fn render(rules: &[Rule], findings: &[Finding], root: &Path) -> Result<String> {
+    let base = repo_root(root);
+    let descriptors: Vec<Value> = rules
+        .iter()
+        .map(|rule| {
+            json!({
+                "id": rule.id,
+                "name": rule.name,
+                "shortDescription": { "text": rule.description },
+                "defaultConfiguration": { "level": rule.level.as_str() },
+            })
+        })
+        .collect();
+
+    let results: Vec<Value> = findings
+        .iter()
+        .map(|finding| {
+            let mut result = json!({
+                "ruleId": finding.rule.id,
+                "level": finding.level.as_str(),
+                "message": { "text": finding.message },
+                "locations": [{ "physicalLocation": physical_location(&finding.location, &base) }],
+            });
+            if let Some(index) = rules.iter().position(|rule| rule.id == finding.rule.id) {
+                result["ruleIndex"] = json!(index);
+            }
+            if !finding.related.is_empty() {
+                let related: Vec<Value> = finding
+                    .related
+                    .iter()
+                    .enumerate()
+                    .map(|(id, location)| {
+                        let mut related = json!({
+                            "id": id + 1,
+                            "physicalLocation": physical_location(location, &base),
+                        });
+                        if let Some(message) = &location.message {
+                            related["message"] = json!({ "text": message });
+                        }
+                        related
+                    })
+                    .collect();
+                result["relatedLocations"] = json!(related);
+            }
+            result
+        })
+        .collect();
+
+    let log = json!({
+        "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+        "version": "2.1.0",
+        "runs": [{
+            "tool": {
+                "driver": {
+                    "name": "codenav",
+                    "version": env!("CARGO_PKG_VERSION"),
+                    "informationUri": "https://github.com/shaharia-lab/code-navigator",
+                    "rules": descriptors,
+                }
+            },
+            "results": results,
+        }],
+    });
+    Ok(serde_json::to_string_pretty(&log)?)
+}
+
+fn physical_location(location: &FindingLocation, base: &Path) -> Value {
+    let mut region = Map::new();
+    region.insert("startLine".to_string(), json!(location.line));
+    if location.column > 0 {
+        region.insert("startColumn".to_string(), json!(location.column));
+    }
+    if location.end_line > 0 {
+        region.insert("endLine".to_string(), json!(location.end_line));
+    }
+    if location.end_column > 0 {
+        region.insert("endColumn".to_string(), json!(location.end_column));
+    }
+    json!({
+        "artifactLocation": artifact_location(&location.file_path, base),
+        "region": region,
+    })
+}
+
+fn artifact_location(path: &Path, base: &Path) -> Value {
+    let path = path.canonicalize().unwrap_or_else(|_| path.to_path_buf());
+    match path.strip_prefix(base) {
+        Ok(relative) => json!({ "uri": encode_path(relative), "uriBaseId": SRCROOT }),
+        Err(_) => json!({ "uri": format!("file://{}", encode_path(&path)) }),
+    }
+}
+
+/// The repository around `root`: the nearest directory at or above it holding `.git`,
+/// or `root` itself outside a repository
+pub fn repo_root(root: &Path) -> PathBuf {
+    let root = root.canonicalize().unwrap_or_else(|_| root.to_path_buf());
+    root.ancestors()
+        .find(|dir| dir.join(".git").exists())
+        .unwrap_or(&root)
+        .to_path_buf()
+}
+
+/// A path as a URI path: components joined with `/`, everything but RFC 3986
+/// unreserved characters percent-encoded
+fn encode_path(path: &Path) -> String {
+    let mut uri = String::new();
+    for component in path.components() {
+        match component {
+            Component::RootDir => {}
+            Component::Normal(part) => {
+                if !uri.is_empty() || path.has_root() {
+                    uri.push('/');
+                }
+                for byte in part.to_string_lossy().bytes() {
+                    match byte {
+                        b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'-' | b'.' | b'_' | b'~' => {
+                            uri.push(byte as char)
+                        }
+                        _ => uri.push_str(&format!("%{:02X}", byte)),
+                    }
+                }
+            }
+            _ => {}
+        }
+    }
+    uri
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_encode_path() {
+        assert_eq!(encode_path(Path::new("pkg/calc.go")), "pkg/calc.go");
+        assert_eq!(encode_path(Path::new("my dir/a#b.go")), "my%20dir/a%23b.go");
+        assert_eq!(encode_path(Path::new("/src/main.go")), "/src/main.go");
+    }
+}
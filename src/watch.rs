@@ -0,0 +1,134 @@
+//! Incremental re-indexing for `codenav watch`.
+//!
+//! The watcher feeds changed paths through [`debounce`], and each path in a batch is
+//! patched into the in-memory graph with [`update_go_file`].
+
+use crate::core::CodeGraph;
+use crate::parser::GoParser;
+use crate::schema::IndexUpdate;
+use anyhow::Result;
+use std::collections::{BTreeMap, BTreeSet};
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::sync::mpsc::Receiver;
+use std::time::Duration;
+
+/// Whether `path` is a file `watch` re-indexes; test files are skipped like in `index`
+pub fn is_go_source(path: &Path) -> bool {
+    path.extension().and_then(|s| s.to_str()) == Some("go")
+        && !path.to_string_lossy().ends_with("_test.go")
+}
+
+/// Block until a path arrives, then keep collecting until no new path has arrived
+/// for `quiet`. An editor saving a file several times in a row yields one batch.
+/// Returns `None` once the sending side is gone.
+pub fn debounce(rx: &Receiver<PathBuf>, quiet: Duration) -> Option<BTreeSet<PathBuf>> {
+    let first = rx.recv().ok()?;
+    let mut batch = BTreeSet::from([first]);
+    while let Ok(path) = rx.recv_timeout(quiet) {
+        batch.insert(path);
+    }
+    Some(batch)
+}
+
+/// Re-index one Go file in place: drop everything it defined, parse it again if it still
+/// exists and matches the parser's build context, and re-resolve the calls of its
+/// package against the patched symbol table.
+pub fn update_go_file(
+    graph: &mut CodeGraph,
+    parser: &mut GoParser,
+    path: &Path,
+) -> Result<IndexUpdate> {
+    let source = fs::read_to_string(path).ok();
+    update_go_source(graph, parser, path, source.as_deref())
+}
+
+/// [`update_go_file`] with the file's contents supplied by the caller, such as an
+/// unsaved editor buffer; `None` treats the file as deleted
+pub fn update_go_source(
+    graph: &mut CodeGraph,
+    parser: &mut GoParser,
+    path: &Path,
+    source: Option<&str>,
+) -> Result<IndexUpdate> {
+    let file = path.to_string_lossy().to_string();
+    let before = file_symbols(graph, path);
+
+    graph.remove_nodes_from_file(&file);
+    graph.metadata.file_metadata.remove(&file);
+    graph.metadata.diagnostics.retain(|d| d.file_path != path);
+    // A file whose build constraints now exclude it counts as deleted
+    if let Some(source) = source.filter(|source| parser.build_context().matches_file(path, source))
+    {
+        parser.parse_file_source(path, source, graph)?;
+        graph.assign_roots();
+        if let Ok(modified) = fs::metadata(path).and_then(|m| m.modified()) {
+            graph.track_file_metadata(&path.to_path_buf(), format!("{:?}", modified));
+        }
+    }
+    if let Some(package_dir) = path.parent() {
+        GoParser::resolve_package_calls(graph, package_dir);
+    }
+
+    graph.metadata.generated_at = chrono::Utc::now().to_rfc3339();
+    graph.metadata.stats.total_nodes = graph.nodes.len();
+    graph.metadata.stats.total_edges = graph.edges.len();
+
+    let after = file_symbols(graph, path);
+    let mut update = IndexUpdate {
+        file,
+        ..Default::default()
+    };
+    for (name, old) in &before {
+        match after.get(name) {
+            None => update.removed.push(name.clone()),
+            Some(new) if new != old => update.changed.push(name.clone()),
+            Some(_) => {}
+        }
+    }
+    update.added = after
+        .keys()
+        .filter(|name| !before.contains_key(*name))
+        .cloned()
+        .collect();
+    Ok(update)
+}
+
+/// Signature and line range of every symbol defined in `path`, by name
+fn file_symbols(graph: &CodeGraph, path: &Path) -> BTreeMap<String, (String, usize, usize)> {
+    graph
+        .nodes
+        .iter()
+        .filter(|n| n.file_path == path)
+        .map(|n| (n.name.clone(), (n.signature.clone(), n.line, n.end_line)))
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::mpsc;
+
+    #[test]
+    fn test_is_go_source() {
+        assert!(is_go_source(Path::new("/src/main.go")));
+        assert!(!is_go_source(Path::new("/src/main_test.go")));
+        assert!(!is_go_source(Path::new("/src/main.go.swp")));
+    }
+
+    #[test]
+    fn test_debounce_collapses_rapid_saves() {
+        let (tx, rx) = mpsc::channel();
+        for path in ["a.go", "b.go", "a.go", "a.go"] {
+            tx.send(PathBuf::from(path)).unwrap();
+        }
+        drop(tx);
+
+        let batch = debounce(&rx, Duration::from_millis(10)).unwrap();
+        assert_eq!(
+            batch.into_iter().collect::<Vec<_>>(),
+            vec![PathBuf::from("a.go"), PathBuf::from("b.go")]
+        );
+        assert!(debounce(&rx, Duration::from_millis(10)).is_none());
+    }
+}
@@ -0,0 +1,110 @@
+use code_navigator::blame::Blamer;
+use code_navigator::core::CodeGraph;
+use code_navigator::parser::GoParser;
+use std::fs;
+use std::path::Path;
+use std::process::Command;
+
+const CALC: &str = "package calc
+
+// Add adds two numbers
+func Add(a, b int) int {
+	return a + b
+}
+
+func Sub(a, b int) int {
+	return a - b
+}
+";
+
+/// Run git in `repo` as a fixed author, so commits don't depend on the user's config
+fn git(repo: &Path, args: &[&str]) {
+    let status = Command::new("git")
+        .arg("-C")
+        .arg(repo)
+        .args(["-c", "commit.gpgsign=false"])
+        .args(args)
+        .env("GIT_AUTHOR_NAME", "Ada Lovelace")
+        .env("GIT_AUTHOR_EMAIL", "ada@example.com")
+        .env("GIT_AUTHOR_DATE", "2023-11-14T23:13:20+01:00")
+        .env("GIT_COMMITTER_NAME", "Ada Lovelace")
+        .env("GIT_COMMITTER_EMAIL", "ada@example.com")
+        .env("GIT_COMMITTER_DATE", "2023-11-14T23:13:20+01:00")
+        .status()
+        .unwrap();
+    assert!(status.success(), "git {:?} failed", args);
+}
+
+/// A repository with calc.go committed, then Sub's body edited in the work tree
+fn edited_repo() -> (tempfile::TempDir, CodeGraph) {
+    let repo = tempfile::tempdir().unwrap();
+    let calc = repo.path().join("calc.go");
+    fs::write(&calc, CALC).unwrap();
+    git(repo.path(), &["init", "-q"]);
+    git(repo.path(), &["add", "calc.go"]);
+    git(repo.path(), &["commit", "-q", "-m", "Add the calculator"]);
+    fs::write(&calc, CALC.replace("a - b", "a - b + 0")).unwrap();
+
+    let mut graph = CodeGraph::new(repo.path().to_string_lossy().to_string(), "go".to_string());
+    GoParser::new()
+        .unwrap()
+        .parse_directory(repo.path(), &mut graph)
+        .unwrap();
+    (repo, graph)
+}
+
+#[test]
+fn test_symbols_are_blamed_line_by_line() {
+    let (_repo, graph) = edited_repo();
+    let mut blamer = Blamer::new();
+
+    // Doc comment included
+    let add = blamer.annotate(graph.get_nodes_by_name("Add")[0]).unwrap();
+    assert_eq!((add.start_line, add.end_line), (3, 6));
+    assert_eq!(add.uncommitted, 0);
+    assert_eq!(add.last_modified.author, "Ada Lovelace");
+    assert_eq!(add.last_modified.summary, "Add the calculator");
+    assert_eq!(
+        add.last_modified.date.to_rfc3339(),
+        "2023-11-14T23:13:20+01:00"
+    );
+    assert_eq!(add.last_modified.short_hash().len(), 7);
+
+    // The edited line is newer than any commit, so it is the one reported
+    let sub = blamer.annotate(graph.get_nodes_by_name("Sub")[0]).unwrap();
+    assert_eq!(sub.uncommitted, 1);
+    assert_eq!(sub.last_modified.hash, None);
+    assert_eq!(sub.last_modified.short_hash(), "uncommitted");
+}
+
+#[test]
+fn test_each_file_is_blamed_once() {
+    let (repo, graph) = edited_repo();
+    let mut blamer = Blamer::new();
+    let add = graph.get_nodes_by_name("Add")[0];
+    let before = blamer.annotate(add).unwrap().last_modified;
+
+    // Without the repository a second run of git would fail, so the answers come
+    // from the first
+    fs::remove_dir_all(repo.path().join(".git")).unwrap();
+    assert_eq!(blamer.annotate(add).unwrap().last_modified, before);
+    let sub = blamer.annotate(graph.get_nodes_by_name("Sub")[0]).unwrap();
+    assert_eq!(sub.uncommitted, 1);
+
+    let reason = Blamer::new().annotate(add).unwrap_err();
+    assert!(reason.contains("not in a git work tree"), "{}", reason);
+}
+
+#[test]
+fn test_untracked_files_are_reported_with_the_reason() {
+    let (repo, _graph) = edited_repo();
+    let untracked = repo.path().join("new.go");
+    fs::write(&untracked, "package calc\n").unwrap();
+
+    let mut blamer = Blamer::new();
+    let reason = blamer.file(&untracked).unwrap_err().to_string();
+    assert!(reason.starts_with("git blame"), "{}", reason);
+    assert!(reason.contains("new.go"), "{}", reason);
+    // Files of the repository are blamed all the same
+    assert!(blamer.file(&repo.path().join("calc.go")).is_ok());
+}
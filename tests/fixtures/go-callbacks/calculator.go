@@ -0,0 +1,33 @@
+package main
+
+// Calculator keeps a running total
+type Calculator struct {
+	total int
+}
+
+func (c *Calculator) Add(a, b int) int {
+	c.total += a + b
+	return c.total
+}
+
+func (c *Calculator) Subtract(a, b int) int {
+	c.total -= a - b
+	return c.total
+}
+
+// Bound calls Add through a method value
+func Bound(c *Calculator) int {
+	f := c.Add
+	return f(1, 2)
+}
+
+// Unbound calls Subtract through a method expression
+func Unbound(c *Calculator) int {
+	g := (*Calculator).Subtract
+	return g(c, 1, 2)
+}
+
+// Passed hands a method value straight to apply
+func Passed(c *Calculator) int {
+	return apply(c.Add, 1, 2)
+}
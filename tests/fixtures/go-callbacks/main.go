@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func Subtract(a, b int) int {
+	return a - b
+}
+
+// apply calls whichever operation it is given
+func apply(f func(int, int) int, a, b int) int {
+	return f(a, b)
+}
+
+func main() {
+	sum := apply(Add, 2, 3)
+	diff := apply(Subtract, 5, 1)
+	product := apply(func(a, b int) int { return a * b }, 2, 4)
+	fmt.Println(sum, diff, product)
+}
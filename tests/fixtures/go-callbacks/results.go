@@ -0,0 +1,13 @@
+package main
+
+// checked logs through its Logger and calls f, with a parenthesized result list
+func checked(f func(int, int) int, l *Logger, a, b int) (int, error) {
+	l.LogOperation("checked", 0)
+	return f(a, b), nil
+}
+
+// Checked hands Subtract to checked, with named results
+func Checked(l *Logger) (n int, err error) {
+	n, err = checked(Subtract, l, 3, 1)
+	return
+}
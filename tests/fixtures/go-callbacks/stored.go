@@ -0,0 +1,37 @@
+package main
+
+func Inc(n int) int { return n + 1 }
+
+func Dec(n int) int { return n - 1 }
+
+// Step applies the function stored in its field
+type Step struct {
+	fn func(int) int
+}
+
+func (s *Step) Do(n int) int {
+	return s.fn(n)
+}
+
+// NewSteps stores Inc through a literal and Dec through an assignment
+func NewSteps() []*Step {
+	up := &Step{fn: Inc}
+	down := &Step{}
+	down.fn = Dec
+	return []*Step{up, down}
+}
+
+// Pick calls whichever function its local ends up holding
+func Pick(down bool, n int) int {
+	step := Inc
+	if down {
+		step = Dec
+	}
+	return step(n)
+}
+
+// Square calls a literal held by a local
+func Square(n int) int {
+	sq := func(x int) int { return x * x }
+	return sq(n)
+}
@@ -0,0 +1,23 @@
+package main
+
+// Logger counts finished operations
+type Logger struct {
+	count int
+}
+
+// LogOperation records one operation
+func (l *Logger) LogOperation(op string, result int) {
+	l.count++
+}
+
+// Run logs once it returns and reports from two goroutines
+func Run(l *Logger, done chan bool) {
+	defer l.LogOperation("run", 0)
+	go report(done)
+	go func() { done <- true }()
+	report(done)
+}
+
+func report(done chan bool) {
+	done <- true
+}
@@ -0,0 +1,61 @@
+package complexity
+
+// Simple has no branches
+func Simple() int {
+	return 1
+}
+
+// Classify switches over many cases; default adds no branch
+func Classify(n int) string {
+	switch {
+	case n < 0:
+		return "negative"
+	case n == 0:
+		return "zero"
+	case n < 10:
+		return "small"
+	case n < 100 && n%2 == 0:
+		return "even"
+	default:
+		return "large"
+	}
+}
+
+// Retry loops around a closure whose branches count toward the closure
+func Retry(attempts int, op func() error) error {
+	try := func() error {
+		if err := op(); err != nil {
+			return err
+		}
+		for i := 0; i < 3; i++ {
+			if i == attempts || i > 5 {
+				return nil
+			}
+		}
+		return nil
+	}
+	for i := 0; i < attempts; i++ {
+		if try() == nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Kind ranges, switches on a type and selects on a channel
+func Kind(v interface{}, done chan struct{}) string {
+	for range []int{1} {
+	}
+	switch v.(type) {
+	case int, int64:
+		return "int"
+	case string:
+		return "string"
+	}
+	select {
+	case <-done:
+		return "done"
+	default:
+	}
+	return "other"
+}
@@ -0,0 +1,46 @@
+package main
+
+import "example.com/dead/shapes"
+
+func init() {
+	register()
+}
+
+func register() {}
+
+func main() {
+	var s Shape = Square{Side: 2}
+	report(s)
+	total := shapes.Sum(1, 2)
+	println(total, apply(double))
+}
+
+// Shape is implemented by Square; Area is only ever called through it
+type Shape interface {
+	Area() int
+}
+
+type Square struct {
+	Side int
+}
+
+func (s Square) Area() int {
+	return s.Side * s.Side
+}
+
+func report(s Shape) {
+	println(s.Area())
+}
+
+func apply(f func(int) int) int {
+	return f(1)
+}
+
+func double(n int) int {
+	return n * 2
+}
+
+// Divide is never called
+func Divide(a, b int) int {
+	return a / b
+}
@@ -0,0 +1,15 @@
+package shapes
+
+// Sum is called from main
+func Sum(a, b int) int {
+	return add(a, b)
+}
+
+func add(a, b int) int {
+	return a + b
+}
+
+// Scale is exported but unused, so only --strict reports it
+func Scale(n, factor int) int {
+	return n * factor
+}
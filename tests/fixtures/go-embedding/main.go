@@ -0,0 +1,64 @@
+package main
+
+import "fmt"
+
+// logger writes messages with a prefix.
+type logger struct {
+	prefix string
+}
+
+func (l *logger) Warn(msg string) {
+	fmt.Println(l.prefix, "WARN", msg)
+}
+
+func (l logger) Info(msg string) {
+	fmt.Println(l.prefix, "INFO", msg)
+}
+
+// auditor records operations for later review.
+type auditor struct{}
+
+func (a *auditor) Record(op string) {}
+
+func (a *auditor) Flush() {}
+
+// store keeps results; it can be flushed too.
+type store struct{}
+
+func (s *store) Flush() {}
+
+// Calculator gets Warn and Info from logger and Record from auditor. Flush is
+// promoted from both auditor and store, so c.Flush() doesn't compile.
+type Calculator struct {
+	logger
+	*auditor
+	store
+	total int
+}
+
+func (c *Calculator) Add(n int) {
+	c.total += n
+	c.Warn("adding")
+	c.Record("add")
+	c.Flush()
+}
+
+// journal is flushed instead of the calculator's stores.
+type journal struct{}
+
+func (j journal) Flush() {}
+
+// Scientific reaches logger two levels down, through Calculator. Its own journal's
+// Flush, one level down, settles the calculator's ambiguity.
+type Scientific struct {
+	*Calculator
+	journal
+}
+
+func main() {
+	c := &Calculator{}
+	c.Add(1)
+	s := &Scientific{}
+	s.Info("ready")
+	s.Flush()
+}
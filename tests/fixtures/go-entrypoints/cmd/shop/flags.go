@@ -0,0 +1,7 @@
+package main
+
+var verbose bool
+
+func init() {
+	verbose = false
+}
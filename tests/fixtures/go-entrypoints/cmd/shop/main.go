@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/shop/inventory"
+)
+
+func init() {
+	fmt.Println("first")
+}
+
+func init() {
+	fmt.Println("second")
+}
+
+func main() {
+	store := inventory.NewStore()
+	fmt.Println(store.Count())
+}
+
+func usage() string {
+	return "shop"
+}
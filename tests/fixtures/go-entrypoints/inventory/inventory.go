@@ -0,0 +1,26 @@
+package inventory
+
+// Store holds items by name
+type Store struct {
+	items map[string]int
+}
+
+func init() {
+	defaultSize = 16
+}
+
+var defaultSize int
+
+// NewStore returns an empty store
+func NewStore() *Store {
+	return &Store{items: make(map[string]int, defaultSize)}
+}
+
+// Count returns the number of items
+func (s *Store) Count() int {
+	return len(s.items)
+}
+
+func (s *Store) grow() {
+	s.items = make(map[string]int, 2*len(s.items))
+}
@@ -0,0 +1,24 @@
+package main
+
+// Number is any type Sum can add up.
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+// Map applies f to every element of xs.
+func Map[T, U any](xs []T, f func(T) U) []U {
+	out := make([]U, 0, len(xs))
+	for _, x := range xs {
+		out = append(out, f(x))
+	}
+	return out
+}
+
+// Sum adds up xs.
+func Sum[N Number](xs []N) N {
+	var total N
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
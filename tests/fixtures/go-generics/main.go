@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func double(n int) int {
+	return n * 2
+}
+
+func main() {
+	labels := Map[int, string]([]int{1, 2, 3}, strconv.Itoa)
+	doubled := Map([]int{1, 2, 3}, double)
+	total := Sum[int](doubled)
+	average := Sum([]float64{1.5, 2.5}) / 2
+
+	s := &Stack[string]{}
+	s.Push("a")
+	s.Pop()
+
+	var t Stack[int]
+	t.Push(total)
+	fmt.Println(labels, average, t.Len(), NewStack[int]())
+}
@@ -0,0 +1,40 @@
+package main
+
+// Stack is a last-in, first-out collection.
+type Stack[T any] struct {
+	items []T
+}
+
+// NewStack returns an empty stack.
+func NewStack[T any]() *Stack[T] {
+	return &Stack[T]{}
+}
+
+// Push adds v to the top of the stack.
+func (s *Stack[T]) Push(v T) {
+	s.items = append(s.items, v)
+}
+
+// Pop removes and returns the top of the stack.
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+// Len reports how many values the stack holds.
+func (s Stack[T]) Len() int {
+	return len(s.items)
+}
+
+// Drain pops every value, handing each to visit.
+func Drain[T any](s *Stack[T], visit func(T)) {
+	for s.Len() > 0 {
+		v, _ := s.Pop()
+		visit(v)
+	}
+}
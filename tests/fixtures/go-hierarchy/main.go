@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Namer has a name.
+type Namer interface {
+	Name() string
+}
+
+// Describer names and describes itself.
+type Describer interface {
+	Namer
+	Describe() string
+}
+
+// Printer's methods come from outside the module.
+type Printer interface {
+	fmt.Stringer
+}
+
+// Base is the named root of every shape.
+type Base struct {
+	name string
+}
+
+func (b Base) Name() string { return b.name }
+
+// Shape is a named base guarded by a mutex.
+type Shape struct {
+	Base
+	sync.Mutex
+}
+
+func (s *Shape) Describe() string { return "shape " + s.Name() }
+
+// Square reaches Base two levels down, through Shape.
+type Square struct {
+	*Shape
+	side int
+}
+
+// Vertex and Edge embed each other through pointers.
+type Vertex struct {
+	*Edge
+}
+
+// Edge leads back to the vertex it leaves.
+type Edge struct {
+	*Vertex
+}
+
+func main() {
+	sq := &Square{Shape: &Shape{Base: Base{name: "square"}}}
+	fmt.Println(sq.Describe())
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Logger is anything that can log a message.
+type Logger interface {
+	Log(msg string)
+}
+
+// ConsoleLogger prints to standard output.
+type ConsoleLogger struct{}
+
+func (c ConsoleLogger) Log(msg string) {
+	fmt.Println(msg)
+}
+
+// FileLogger appends to a file.
+type FileLogger struct {
+	file *os.File
+}
+
+func (f *FileLogger) Log(msg string) {
+	fmt.Fprintln(f.file, msg)
+}
+
+// Silent has a Log method of another shape, so it is no Logger.
+type Silent struct{}
+
+func (s Silent) Log() {}
+
+// Process logs through whichever Logger it is given.
+func Process(l Logger, items []string) {
+	for _, item := range items {
+		l.Log(item)
+	}
+}
+
+func main() {
+	Process(ConsoleLogger{}, []string{"a"})
+	Process(&FileLogger{file: os.Stderr}, []string{"b"})
+}
@@ -0,0 +1,11 @@
+package calc
+
+// Add returns the sum of a and b
+func Add(a, b int) int {
+	return a + b
+}
+
+// Twice doubles a
+func Twice(a int) int {
+	return Add(a, a)
+}
@@ -0,0 +1,8 @@
+//go:build ignore
+
+package calc
+
+// Add is excluded from every build and must not clash with calc.go
+func Add(a, b int) int {
+	return b + a
+}
@@ -0,0 +1,4 @@
+package calc
+
+// Plan9Only is only built for GOOS=plan9
+func Plan9Only() {}
@@ -0,0 +1,12 @@
+package main
+
+import "fmt"
+
+// Add adds two numbers; calc defines its own Add
+func Add(a int, b int) int {
+	return a + b
+}
+
+func main() {
+	fmt.Println(Add(1, 2))
+}
@@ -0,0 +1,4 @@
+package sample
+
+// FromTestdata lives under testdata/ and is never indexed
+func FromTestdata() {}
@@ -0,0 +1,3 @@
+module example.com/mymod/tools
+
+go 1.21
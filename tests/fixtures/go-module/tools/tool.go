@@ -0,0 +1,4 @@
+package tools
+
+// Tool belongs to the nested tools module, not example.com/mymod
+func Tool() {}
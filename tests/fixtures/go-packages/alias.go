@@ -0,0 +1,8 @@
+package main
+
+import h "example.com/app/helper"
+
+// UseAlias calls the helper package through an import alias
+func UseAlias() string {
+	return h.Shout("alias")
+}
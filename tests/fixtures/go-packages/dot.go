@@ -0,0 +1,8 @@
+package main
+
+import . "example.com/app/helper"
+
+// UseDot calls the helper package through a dot-import
+func UseDot() string {
+	return Greeting("dot")
+}
@@ -0,0 +1,18 @@
+package helper
+
+import "strings"
+
+// Greeting builds a greeting for name
+func Greeting(name string) string {
+	return "Hello, " + name
+}
+
+// Shout is Greeting in upper case
+func Shout(name string) string {
+	return strings.ToUpper(Greeting(name))
+}
+
+// ToUpper shares its name with strings.ToUpper, which must not resolve here
+func ToUpper(s string) string {
+	return s
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/app/helper"
+)
+
+func main() {
+	fmt.Println(helper.Greeting("world"))
+	fmt.Println(UseAlias(), UseDot())
+}
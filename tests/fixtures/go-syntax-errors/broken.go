@@ -0,0 +1,11 @@
+package main
+
+// Broken is missing the right operand of its addition
+func Broken(x int) int {
+	return x +
+}
+
+// After follows the broken function
+func After() int {
+	return helper()
+}
@@ -0,0 +1,15 @@
+package main
+
+func main() {
+	Healthy()
+	Broken(1)
+	After()
+}
+
+func Healthy() int {
+	return helper()
+}
+
+func helper() int {
+	return 1
+}
@@ -0,0 +1,43 @@
+package api
+
+import "fmt"
+
+// Greet reaches PrintMessage through two unexported helpers
+func Greet(name string) {
+	helper("Hello, " + name)
+}
+
+func helper(msg string) {
+	format(msg)
+}
+
+func format(msg string) {
+	PrintMessage(msg)
+	fmt.Println(msg)
+}
+
+// PrintMessage prints a message
+func PrintMessage(msg string) {
+	fmt.Println(msg)
+}
+
+// counter is unexported, so its methods and fields are too
+type counter struct {
+	Total int
+}
+
+func (c *counter) Add(n int) {
+	c.Total += n
+}
+
+// Counter is exported
+type Counter struct{}
+
+// Inc calls an unexported method
+func (c *Counter) Inc() {
+	c.bump()
+}
+
+func (c *Counter) bump() {
+	PrintMessage("bump")
+}
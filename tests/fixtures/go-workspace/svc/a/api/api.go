@@ -0,0 +1,12 @@
+package api
+
+import (
+	"example.com/a/internal/config"
+	"example.com/b/greet"
+)
+
+// Start loads the settings and greets
+func Start() string {
+	config.Load()
+	return greet.Hello()
+}
@@ -0,0 +1,4 @@
+package config
+
+// Load reads the service's settings
+func Load() {}
@@ -0,0 +1,4 @@
+// A fork of svc/a kept beside it, under the same module path
+module example.com/a
+
+go 1.22
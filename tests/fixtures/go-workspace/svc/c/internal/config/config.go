@@ -0,0 +1,4 @@
+package config
+
+// Load reads the fork's settings
+func Load() {}
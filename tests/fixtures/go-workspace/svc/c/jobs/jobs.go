@@ -0,0 +1,8 @@
+package jobs
+
+import "example.com/a/internal/config"
+
+// Run loads the fork's settings
+func Run() {
+	config.Load()
+}
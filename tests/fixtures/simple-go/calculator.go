@@ -4,6 +4,7 @@ import "fmt"
 
 // Calculator is a simple calculator struct
 type Calculator struct {
+	logger
 	name string
 }
 
@@ -31,3 +32,32 @@ func (c *Calculator) LogOperation(op string, result int) {
 	msg := fmt.Sprintf("%s: %s = %d", c.name, op, result)
 	PrintMessage(msg)
 }
+
+// Name returns the calculator's name
+func (c Calculator) Name() string {
+	return c.name
+}
+
+// Logger is satisfied by *Calculator through LogOperation and Name
+type Logger interface {
+	LogOperation(op string, result int)
+	Name() string
+}
+
+// Recorder logs operations but has no Name, so it is not a Logger
+type Recorder struct{}
+
+// LogOperation discards the operation
+func (r *Recorder) LogOperation(op string, result int) {}
+
+// SetName renames the calculator
+func (c *Calculator) SetName(name string) {
+	c.name = name
+	c.Warn("renamed to " + name)
+}
+
+// logger is embedded in Calculator, which gets its Warn method
+type logger struct{}
+
+// Warn discards the warning
+func (l *logger) Warn(msg string) {}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCalculatorAdd(t *testing.T) {
+	calc := &Calculator{name: "test"}
+	if got := calc.Add(2, 3); got != 5 {
+		t.Errorf("Add(2, 3) = %d, want 5", got)
+	}
+}
+
+func BenchmarkGreet(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Greet("bench")
+	}
+}
+
+// newTestCalculator is a helper, not a test
+func newTestCalculator() *Calculator {
+	return NewCalculator("helper")
+}
@@ -19,7 +19,7 @@ func Multiply(x int, y int) int {
 // Greet prints a greeting
 func Greet(name string) {
 	message := fmt.Sprintf("Hello, %s!", name)
-	PrintMessage(message)
+	defer func() { PrintMessage(message) }()
 }
 
 // PrintMessage prints a message
@@ -33,3 +33,32 @@ func main() {
 	fmt.Printf("Sum: %d, Product: %d\n", sum, product)
 	Greet("World")
 }
+
+// Apply calls Add through a function value
+func Apply() int {
+	op := Add
+	return op(2, 3)
+}
+
+// Even and Odd call each other
+func Even(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return Odd(n - 1)
+}
+
+func Odd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return Even(n - 1)
+}
+
+// Factorial calls itself
+func Factorial(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * Factorial(n-1)
+}
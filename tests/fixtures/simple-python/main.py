@@ -0,0 +1,53 @@
+"""Entry point, mirroring the Go fixture's main.go."""
+
+import math
+
+from calculator import Calculator, add, multiply
+
+
+def greet(name: str) -> None:
+    """Print a greeting."""
+    message = f"Hello, {name}!"
+    print_message(message)
+
+
+def print_message(msg: str) -> None:
+    """Print a message."""
+    print(msg)
+
+
+def even(n: int) -> bool:
+    """Even and odd call each other."""
+    if n == 0:
+        return True
+    return odd(n - 1)
+
+
+def odd(n: int) -> bool:
+    if n == 0:
+        return False
+    return even(n - 1)
+
+
+def factorial(n: int) -> int:
+    """Factorial calls itself."""
+    if n <= 1:
+        return 1
+    return n * factorial(n - 1)
+
+
+def hypotenuse(a: float, b: float) -> float:
+    """Call into an imported module."""
+    return math.sqrt(add(a * a, b * b))
+
+
+def main():
+    total = add(5, 3)
+    product = multiply(4, 5)
+    print(f"Sum: {total}, Product: {product}")
+    greet("World")
+    Calculator().add(1, 2)
+
+
+if __name__ == "__main__":
+    main()
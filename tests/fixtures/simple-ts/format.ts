@@ -0,0 +1,6 @@
+/**
+ * Formats a result for display
+ */
+export default function formatResult(label: string, value: number): string {
+  return `${label}: ${value}`;
+}
@@ -0,0 +1,3 @@
+// Public surface of the calculator
+export * from "./calculator";
+export { default as describe } from "./format";
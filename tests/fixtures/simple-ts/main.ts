@@ -0,0 +1,19 @@
+import { add, multiply as times, Calculator } from "./calculator";
+import * as calc from "./index";
+import formatResult from "./format";
+import { describe } from "./index";
+import { readFileSync } from "fs";
+
+/**
+ * Calls into the other files through each kind of import
+ */
+export function run(): void {
+  const sum = add(5, 3);
+  const product = times(4, 5);
+  console.log(formatResult("Sum", sum));
+  console.log(describe("Product", product));
+  const calculator = new Calculator();
+  calculator.add(1, 2);
+  calc.power(2, 8);
+  readFileSync("config.json");
+}
@@ -0,0 +1,3313 @@
+use code_navigator::core::{
+    CallCounts, CallSite, CalleeOptions, CodeGraph, DeadCodeOptions, Edge, EdgeKind, EdgeType,
+    FieldAccessKind, HierarchyEntry, MethodImplementation, MetricsSort, NodeType, Page,
+    PageRequest, PathOptions, Prune, ReferenceKind, RenamePlan, SearchOptions, SymbolFilter,
+    UnresolvedReason,
+};
+use code_navigator::parser::{GoParser, STDIN_PATH};
+use std::fs;
+use std::path::{Path, PathBuf};
+
+fn fixture_dir(name: &str) -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join(name)
+}
+
+fn index_dir(dir: &Path) -> CodeGraph {
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    let mut parser = GoParser::new().unwrap();
+    parser.parse_directory(dir, &mut graph).unwrap();
+    graph
+}
+
+/// ID of the symbol declared at `file:line`
+fn node_id(graph: &CodeGraph, file: &Path, name: &str, line: usize) -> String {
+    graph
+        .nodes
+        .iter()
+        .find(|n| n.file_path == file && n.name == name && n.line == line)
+        .unwrap_or_else(|| panic!("no {} at {}:{}", name, file.display(), line))
+        .id
+        .clone()
+}
+
+fn callees_of(graph: &CodeGraph, name: &str) -> Vec<String> {
+    let node = graph
+        .get_nodes_by_name(name)
+        .into_iter()
+        .next()
+        .unwrap_or_else(|| panic!("node {} not found", name));
+    graph
+        .get_outgoing_edges(&node.id)
+        .iter()
+        .map(|e| e.to.clone())
+        .collect()
+}
+
+#[test]
+fn test_methods_are_named_with_receiver() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let add = graph.get_nodes_by_name("(*Calculator).Add");
+    assert_eq!(add.len(), 1);
+    assert_eq!(add[0].node_type, NodeType::Method);
+    assert_eq!(add[0].metadata.get("receiver").unwrap(), "*Calculator");
+    assert_eq!(add[0].metadata.get("method").unwrap(), "Add");
+
+    // The free function Add stays distinct from the method
+    let func = graph.get_nodes_by_name("Add");
+    assert_eq!(func.len(), 1);
+    assert_eq!(func[0].node_type, NodeType::Function);
+}
+
+#[test]
+fn test_receiver_call_chain() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    assert!(
+        callees_of(&graph, "(*Calculator).Add").contains(&"(*Calculator).LogOperation".to_string())
+    );
+    assert!(callees_of(&graph, "(*Calculator).Subtract")
+        .contains(&"(*Calculator).LogOperation".to_string()));
+    assert!(callees_of(&graph, "(*Calculator).LogOperation").contains(&"PrintMessage".to_string()));
+
+    // Calculator.Add -> LogOperation -> PrintMessage is reachable end to end
+    let add = graph.get_nodes_by_name("(*Calculator).Add")[0];
+    let path = graph
+        .find_shortest_path(&add.id, "PrintMessage", 5)
+        .unwrap();
+    assert_eq!(path, vec!["(*Calculator).LogOperation", "PrintMessage"]);
+
+    // Warn is promoted from the logger Calculator embeds
+    let set_name = graph.resolve_symbol("(*Calculator).SetName").unwrap();
+    let warn = graph
+        .get_outgoing_edges(&set_name.id)
+        .into_iter()
+        .find(|edge| edge.to == "(*logger).Warn")
+        .unwrap();
+    assert_eq!(warn.promoted_via(), vec!["logger".to_string()]);
+}
+
+#[test]
+fn test_value_and_pointer_receivers() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("shapes.go"),
+        r#"package shapes
+
+type Point struct {
+	x, y int
+}
+
+func (p Point) Norm() int {
+	return p.square()
+}
+
+func (p Point) square() int {
+	return p.x*p.x + p.y*p.y
+}
+
+func (p *Point) Scale(k int) int {
+	p.x *= k
+	return p.Norm()
+}
+"#,
+    )
+    .unwrap();
+
+    let graph = index_dir(dir.path());
+
+    assert!(callees_of(&graph, "Point.Norm").contains(&"Point.square".to_string()));
+    assert!(callees_of(&graph, "(*Point).Scale").contains(&"Point.Norm".to_string()));
+    assert_eq!(graph.find_nodes_by_symbol("Norm").len(), 1);
+    assert_eq!(graph.find_nodes_by_symbol("Point.Scale").len(), 1);
+}
+
+#[test]
+fn test_cross_file_call_resolves_to_definition() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    // LogOperation lives in calculator.go, PrintMessage in main.go
+    let log = graph.get_nodes_by_name("(*Calculator).LogOperation")[0];
+    let edge = graph
+        .get_outgoing_edges(&log.id)
+        .into_iter()
+        .find(|e| e.to == "PrintMessage")
+        .unwrap();
+
+    let targets = graph.edge_targets(edge);
+    assert_eq!(targets.len(), 1);
+    assert!(targets[0].file_path.ends_with("main.go"));
+    assert_eq!(targets[0].line, 26);
+    assert_eq!(edge.metadata.get("target_id"), Some(&targets[0].id));
+
+    // Package-qualified calls are not resolved against the local package
+    let println = graph
+        .get_outgoing_edges(&graph.get_nodes_by_name("PrintMessage")[0].id)
+        .into_iter()
+        .find(|e| e.to == "Println")
+        .unwrap();
+    assert_eq!(println.metadata.get("qualifier").unwrap(), "fmt");
+    assert!(!println.metadata.contains_key("target_id"));
+
+    assert!(graph.metadata.diagnostics.is_empty());
+}
+
+#[test]
+fn test_file_from_stdin_leaves_other_files_unresolved() {
+    let source = fs::read_to_string(fixture_dir("simple-go").join("calculator.go")).unwrap();
+    let graph = GoParser::new()
+        .unwrap()
+        .index_standalone(Path::new(STDIN_PATH), &source);
+    assert!(graph.metadata.diagnostics.is_empty());
+    assert!(graph
+        .nodes
+        .iter()
+        .all(|n| n.file_path == Path::new(STDIN_PATH)));
+
+    // PrintMessage is declared in main.go, which was not given
+    let log = graph.resolve_symbol("(*Calculator).LogOperation").unwrap();
+    let edge = graph
+        .get_outgoing_edges(&log.id)
+        .into_iter()
+        .find(|e| e.to == "PrintMessage")
+        .unwrap();
+    assert_eq!(edge.line, 33);
+    assert!(graph.edge_targets(edge).is_empty());
+    assert!(!edge.metadata.contains_key("target_id"));
+
+    let callees = graph
+        .callees("(*Calculator).LogOperation", &CalleeOptions::default())
+        .unwrap();
+    let names: Vec<String> = callees
+        .reached
+        .iter()
+        .map(|call| call.hop.callee_name())
+        .collect();
+    assert_eq!(names, vec!["fmt.Sprintf", "PrintMessage"]);
+
+    // Calls within the file still resolve; the unknown PrintMessage is matched by name
+    assert_eq!(graph.callers("(*Calculator).LogOperation").len(), 2);
+    assert_eq!(graph.callers("PrintMessage").len(), 1);
+    graph.dead_code(&DeadCodeOptions::default()).unwrap();
+}
+
+#[test]
+fn test_index_stats_count_the_fixture() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let stats = graph.index_stats();
+
+    // calculator_test.go is left out, as tests are by default
+    assert_eq!((stats.files, stats.packages), (2, 1));
+    // Greet's deferred literal is not counted among the functions
+    assert_eq!((stats.functions, stats.methods, stats.types), (10, 9, 4));
+    assert_eq!(
+        stats.calls,
+        CallCounts {
+            total: 19,
+            // op(2, 3) in Apply follows op to Add, so it is direct
+            direct: 19,
+            indirect: 0,
+            dynamic: 0,
+            go: 0,
+            defer: 1,
+        }
+    );
+    // fmt.Sprintf twice, fmt.Println and fmt.Printf
+    assert_eq!(stats.unresolved_calls, 4);
+    assert!(stats.diagnostics.is_empty());
+    // Only `index` records these
+    assert_eq!((stats.index_duration_ms, stats.cache), (None, None));
+
+    // An incremental update that re-parses one file still holds both
+    let mut graph = graph;
+    let main = fixture_dir("simple-go").join("main.go");
+    graph.remove_nodes_from_file(&main.to_string_lossy());
+    GoParser::new()
+        .unwrap()
+        .parse_file(&main, &mut graph)
+        .unwrap();
+    graph.metadata.stats.files_parsed = 1;
+    assert_eq!(graph.index_stats().files, 2);
+}
+
+#[test]
+fn test_duplicate_symbol_diagnostic() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("a.go"),
+        "package util\n\nfunc Helper() {}\n\nfunc Run() {\n\tHelper()\n}\n",
+    )
+    .unwrap();
+    fs::write(
+        dir.path().join("b.go"),
+        "package util\n\nfunc init() {}\n\nfunc Helper() {}\n",
+    )
+    .unwrap();
+    fs::write(dir.path().join("c.go"), "package util\n\nfunc init() {}\n").unwrap();
+
+    let graph = index_dir(dir.path());
+
+    let diagnostics = &graph.metadata.diagnostics;
+    assert_eq!(diagnostics.len(), 1);
+    assert_eq!(diagnostics[0].code, "duplicate-symbol");
+    assert!(diagnostics[0].file_path.ends_with("b.go"));
+    assert_eq!(diagnostics[0].line, 5);
+    assert!(diagnostics[0].message.contains("Helper"));
+
+    // The ambiguous call is left unresolved rather than bound to either definition
+    let run = graph.get_nodes_by_name("Run")[0];
+    let edge = graph.get_outgoing_edges(&run.id)[0];
+    assert!(!edge.metadata.contains_key("target_id"));
+}
+
+#[test]
+fn test_symbols_are_scoped_per_package_directory() {
+    let dir = tempfile::tempdir().unwrap();
+    for pkg in ["alpha", "beta"] {
+        fs::create_dir(dir.path().join(pkg)).unwrap();
+        fs::write(
+            dir.path().join(pkg).join("helper.go"),
+            format!("package {}\n\nfunc Helper() {{}}\n", pkg),
+        )
+        .unwrap();
+        fs::write(
+            dir.path().join(pkg).join("run.go"),
+            format!("package {}\n\nfunc Run() {{\n\tHelper()\n}}\n", pkg),
+        )
+        .unwrap();
+    }
+
+    let graph = index_dir(dir.path());
+    assert!(graph.metadata.diagnostics.is_empty());
+
+    for run in graph.get_nodes_by_name("Run") {
+        let edge = graph.get_outgoing_edges(&run.id)[0];
+        let targets = graph.edge_targets(edge);
+        assert_eq!(targets.len(), 1);
+        assert_eq!(targets[0].package, run.package);
+    }
+}
+
+#[test]
+fn test_callers_report_call_sites() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let sites: Vec<(String, usize)> = graph
+        .callers("Add")
+        .into_iter()
+        .map(|c| (c.caller, c.line))
+        .collect();
+    assert_eq!(
+        sites,
+        vec![
+            ("Multiply".to_string(), 12),
+            ("Multiply".to_string(), 14),
+            ("main".to_string(), 31),
+            ("Apply".to_string(), 40),
+        ]
+    );
+}
+
+#[test]
+fn test_transitive_callers_of_print_message() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let callers = graph.transitive_callers("PrintMessage", 5);
+    let at_depth = |depth: usize| -> Vec<String> {
+        let mut names: Vec<String> = callers
+            .iter()
+            .filter(|c| c.depth == depth)
+            .map(|c| c.caller.clone())
+            .collect();
+        names.sort();
+        names
+    };
+
+    assert_eq!(
+        at_depth(1),
+        vec!["(*Calculator).LogOperation", "Greet.func1"]
+    );
+    assert_eq!(
+        at_depth(2),
+        vec!["(*Calculator).Add", "(*Calculator).Subtract", "Greet"]
+    );
+    assert_eq!(at_depth(3), vec!["main"]);
+    assert!(at_depth(4).is_empty());
+}
+
+#[test]
+fn test_dot_export_matches_golden() {
+    use code_navigator::serializer::dot::{render, DotOptions};
+
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let expected = include_str!("golden/simple-go.dot");
+    assert_eq!(render(&graph, &DotOptions::default()), expected);
+
+    // Parallel parsing must not change the output
+    let again = index_dir(&fixture_dir("simple-go"));
+    assert_eq!(render(&again, &DotOptions::default()), expected);
+}
+
+#[test]
+fn test_grouped_exports_merge_calls_between_files() {
+    use code_navigator::core::{GroupBy, GroupTarget};
+    use code_navigator::serializer::{dot, mermaid};
+
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let grouped = graph.group_calls(GroupBy::File);
+    let names: Vec<&str> = grouped.groups.iter().map(|g| g.name.as_str()).collect();
+    assert_eq!(names, vec!["calculator.go", "main.go"]);
+    // LogOperation calling PrintMessage is the only call between the files
+    let between: Vec<(usize, &GroupTarget, usize)> = grouped
+        .calls
+        .iter()
+        .filter(|call| matches!(call.to, GroupTarget::Group(_)) && !call.is_internal())
+        .map(|call| (call.from, &call.to, call.calls))
+        .collect();
+    assert_eq!(between, vec![(0, &GroupTarget::Group(1), 1)]);
+
+    let collapsed = dot::DotOptions {
+        include_external: false,
+        group_by: Some(GroupBy::File),
+        collapse: true,
+        ..Default::default()
+    };
+    assert_eq!(
+        dot::render(&graph, &collapsed),
+        r#"digraph CodeGraph {
+  rankdir=LR;
+  node [shape=box];
+
+  "group:calculator.go" [label="calculator.go\n15 symbols"];
+  "group:main.go" [label="main.go\n10 symbols"];
+
+  "group:calculator.go" -> "group:main.go" [label="1 call"];
+}
+"#
+    );
+    let internal = dot::render(
+        &graph,
+        &dot::DotOptions {
+            keep_internal: true,
+            ..collapsed.clone()
+        },
+    );
+    assert!(internal.contains("  \"group:main.go\" -> \"group:main.go\" [label=\"11 calls\"];\n"));
+    assert!(internal
+        .contains("  \"group:calculator.go\" -> \"group:calculator.go\" [label=\"3 calls\"];\n"));
+
+    // Clustered, calls inside a file stay between symbols and the rest join the clusters
+    let clustered = dot::render(
+        &graph,
+        &dot::DotOptions {
+            group_by: Some(GroupBy::File),
+            ..Default::default()
+        },
+    );
+    assert!(clustered.contains("  compound=true;\n"));
+    assert!(clustered.contains("    label=\"main.go\";\n"));
+    assert!(clustered.contains(
+        "  \"calculator.go:(*Calculator).Add:17\" -> \"calculator.go:(*Calculator).LogOperation:31\";\n"
+    ));
+    assert!(clustered.contains(
+        "  \"calculator.go:Calculator:6\" -> \"main.go:Add:6\" [label=\"1 call\", ltail=\"cluster_0\", lhead=\"cluster_1\"];\n"
+    ));
+    assert!(clustered.contains(
+        "  \"main.go:Add:6\" -> \"external:fmt.Sprintf\" [label=\"1 call\", ltail=\"cluster_1\"];\n"
+    ));
+    assert!(!clustered.contains("LogOperation:31\" -> \"main.go:PrintMessage:26\""));
+
+    let subgraphs = mermaid::render(
+        &graph,
+        &mermaid::MermaidOptions {
+            include_external: false,
+            group_by: Some(GroupBy::File),
+            ..Default::default()
+        },
+    );
+    assert!(subgraphs.contains("    subgraph group_calculator_go[\"calculator.go\"]\n"));
+    assert!(subgraphs.contains("    Calculator_Add --> Calculator_LogOperation\n"));
+    assert!(subgraphs.contains("    group_calculator_go -->|1 call| group_main_go\n"));
+    assert!(!subgraphs.contains("Calculator_LogOperation --> PrintMessage"));
+
+    // Without a module there is one package, whose calls are all internal
+    let package = mermaid::render(
+        &graph,
+        &mermaid::MermaidOptions {
+            include_external: false,
+            group_by: Some(GroupBy::Package),
+            collapse: true,
+            ..Default::default()
+        },
+    );
+    assert_eq!(
+        package,
+        "graph TD\n    group_main[\"main<br/>25 symbols\"]\n"
+    );
+}
+
+#[test]
+fn test_markdown_report_matches_golden() {
+    use code_navigator::serializer::markdown::{render, ReportOptions};
+
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let expected = include_str!("golden/simple-go.md");
+    assert_eq!(render(&graph, &ReportOptions::default()).unwrap(), expected);
+
+    // Sections can be left out, and a package that isn't indexed is an error
+    let entry_points = ReportOptions {
+        symbols: false,
+        call_graph: false,
+        dead_code: false,
+        ..Default::default()
+    };
+    assert_eq!(
+        render(&graph, &entry_points).unwrap(),
+        "# Architecture report: simple-go\n\n## Entry points\n\n- `main` (main.go:30)\n"
+    );
+    let missing = ReportOptions {
+        package: Some("calc".to_string()),
+        ..Default::default()
+    };
+    assert!(render(&graph, &missing).is_err());
+}
+
+#[test]
+fn test_mermaid_export_from_root() {
+    use code_navigator::serializer::mermaid::{render, MermaidOptions};
+
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let subgraph = graph.extract_subgraph("main", 2);
+
+    let expected = r#"graph TD
+    Add["Add"]
+    Multiply["Multiply"]
+    Greet["Greet"]
+    Greet_func1["Greet.func1"]
+    main["main"]
+    ext_fmt_Printf(["fmt.Printf"])
+    ext_fmt_Sprintf(["fmt.Sprintf"])
+    Greet -->|defer| Greet_func1
+    Greet --> ext_fmt_Sprintf
+    Multiply --> Add
+    main --> Add
+    main --> Greet
+    main --> Multiply
+    main --> ext_fmt_Printf
+    classDef external stroke-dasharray: 5 5
+    class ext_fmt_Printf,ext_fmt_Sprintf external
+"#;
+    assert_eq!(render(&subgraph, &MermaidOptions::default()), expected);
+
+    // Calls out of the index count as a step too: at depth 1 Greet is drawn but not
+    // its call of fmt.Sprintf, as `trace --depth 1` leaves it out
+    let shallow = render(
+        &graph.extract_subgraph("main", 1),
+        &MermaidOptions::default(),
+    );
+    assert!(
+        shallow.contains("    main --> ext_fmt_Printf\n"),
+        "{}",
+        shallow
+    );
+    assert!(!shallow.contains("fmt.Sprintf"), "{}", shallow);
+
+    // Receiver-qualified names keep their label but get a safe ID
+    let full = render(&graph, &MermaidOptions::default());
+    assert!(full.contains("    Calculator_LogOperation[\"(*Calculator).LogOperation\"]\n"));
+    assert!(full.contains("    Calculator_Add --> Calculator_LogOperation\n"));
+}
+
+#[test]
+fn test_search_finds_symbols_by_approximate_name() {
+    use code_navigator::core::{MatchKind, SearchOptions};
+
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let top = |query: &str| {
+        let results = graph.search(query, &SearchOptions::default());
+        let first = results
+            .first()
+            .unwrap_or_else(|| panic!("nothing matches {}", query));
+        (first.node.name.clone(), first.match_kind)
+    };
+
+    assert_eq!(
+        top("calc add"),
+        ("(*Calculator).Add".to_string(), MatchKind::Fuzzy)
+    );
+    assert_eq!(top("prmsg"), ("PrintMessage".to_string(), MatchKind::Fuzzy));
+    assert_eq!(top("greet"), ("Greet".to_string(), MatchKind::Exact));
+
+    // Methods match on `Type.Method`, and shorter names rank first within a kind
+    let names: Vec<String> = graph
+        .search("calc", &SearchOptions::default())
+        .iter()
+        .map(|m| m.node.name.clone())
+        .collect();
+    assert_eq!(
+        names,
+        vec![
+            "Calculator",
+            "(*Calculator).Add",
+            "Calculator.Name",
+            "Calculator.name",
+            "(*Calculator).SetName",
+            "(*Calculator).Subtract",
+            "(*Calculator).LogOperation",
+            "NewCalculator",
+        ]
+    );
+
+    let options = SearchOptions {
+        kinds: vec![NodeType::Function],
+        limit: Some(1),
+        ..Default::default()
+    };
+    let functions = graph.search("calc", &options);
+    assert_eq!(functions.len(), 1);
+    assert_eq!(functions[0].node.name, "NewCalculator");
+    assert_eq!(functions[0].match_kind, MatchKind::Substring);
+}
+
+#[test]
+fn test_interface_implementations() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let logger = graph.resolve_type("Logger").unwrap();
+    let implementations = graph.implementations(logger);
+    assert_eq!(implementations.len(), 1);
+    let calculator = &implementations[0];
+    assert_eq!(calculator.implementer.name, "Calculator");
+    // LogOperation has a pointer receiver, so only *Calculator is a Logger
+    assert!(calculator.pointer);
+
+    let methods: Vec<(&str, &str, usize)> = calculator
+        .methods
+        .iter()
+        .map(|m| {
+            (
+                m.requirement.name.as_str(),
+                m.method.name.as_str(),
+                m.method.line,
+            )
+        })
+        .collect();
+    assert_eq!(
+        methods,
+        vec![
+            ("Logger.LogOperation", "(*Calculator).LogOperation", 31),
+            ("Logger.Name", "Calculator.Name", 37),
+        ]
+    );
+
+    // The reverse direction, from the concrete type
+    let calculator = graph.resolve_type("Calculator").unwrap();
+    let interfaces: Vec<&str> = graph
+        .implementations(calculator)
+        .iter()
+        .map(|i| i.interface.name.as_str())
+        .collect();
+    assert_eq!(interfaces, vec!["Logger"]);
+
+    // Recorder has LogOperation but no Name
+    let recorder = graph.resolve_type("Recorder").unwrap();
+    assert!(graph.implementations(recorder).is_empty());
+}
+
+#[test]
+fn test_method_sets_follow_embedding() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("store.go"),
+        r#"package store
+
+type Namer interface {
+	Name() string
+}
+
+type Store interface {
+	Namer
+	Save(key string, value []byte) error
+}
+
+type base struct{}
+
+func (b base) Name() string { return "base" }
+
+type other struct{}
+
+func (o other) Name() string { return "other" }
+
+type diskWriter struct{}
+
+func (d *diskWriter) Save(key string, value []byte) error { return nil }
+
+type Disk struct {
+	base
+	*diskWriter
+}
+
+type Memory struct {
+	base
+}
+
+func (m Memory) Save(k string, v []byte) (err error) { return nil }
+
+type Mismatched struct{}
+
+func (m Mismatched) Name() int { return 0 }
+
+type Ambiguous struct {
+	base
+	other
+}
+"#,
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+
+    let store = graph.resolve_type("Store").unwrap();
+    let implementations = graph.implementations(store);
+    let names: Vec<(&str, bool)> = implementations
+        .iter()
+        .map(|i| (i.implementer.name.as_str(), i.pointer))
+        .collect();
+    assert_eq!(names, vec![("Disk", false), ("Memory", false)]);
+
+    // Embedding *diskWriter promotes its pointer method into Disk's value method set
+    let disk: Vec<(&str, Vec<String>)> = implementations[0]
+        .methods
+        .iter()
+        .map(|m| (m.method.name.as_str(), m.via.clone()))
+        .collect();
+    assert_eq!(
+        disk,
+        vec![
+            ("base.Name", vec!["base".to_string()]),
+            ("(*diskWriter).Save", vec!["*diskWriter".to_string()]),
+        ]
+    );
+
+    // Result types must match, and Name promoted from two fields at once is ambiguous
+    let namer = graph.resolve_type("Namer").unwrap();
+    let namers: Vec<&str> = graph
+        .implementations(namer)
+        .iter()
+        .map(|i| i.implementer.name.as_str())
+        .collect();
+    assert_eq!(namers, vec!["base", "other", "Disk", "Memory"]);
+}
+
+fn references_of(graph: &CodeGraph, symbol: &str) -> Vec<(String, usize, ReferenceKind)> {
+    graph
+        .references(symbol)
+        .into_iter()
+        .map(|site| (site.caller, site.line, site.kind))
+        .collect()
+}
+
+#[test]
+fn test_references_include_assignments_and_indirect_calls() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    assert_eq!(
+        references_of(&graph, "Add"),
+        vec![
+            ("Multiply".to_string(), 12, ReferenceKind::Call),
+            ("Multiply".to_string(), 14, ReferenceKind::Call),
+            ("main".to_string(), 31, ReferenceKind::Call),
+            ("Apply".to_string(), 39, ReferenceKind::Assignment),
+            ("Apply".to_string(), 40, ReferenceKind::Call),
+        ]
+    );
+
+    // op(2, 3) is a call to Add made through the local op
+    let apply = graph.resolve_symbol("Apply").unwrap();
+    let indirect = &graph.get_outgoing_edges(&apply.id)[0];
+    assert_eq!(indirect.to, "Add");
+    assert_eq!(indirect.call_site, "op(2, 3)");
+    assert_eq!(indirect.metadata.get("via").map(String::as_str), Some("op"));
+}
+
+#[test]
+fn test_references_are_classified() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("refs.go"),
+        r#"package refs
+
+import "strings"
+
+type Calculator struct{}
+
+func (c *Calculator) Add(a, b int) int { return a + b }
+
+func PrintMessage(msg string) {}
+
+func run(f func(string), msg string) { f(msg) }
+
+func Use(c *Calculator) {
+	f := PrintMessage
+	run(PrintMessage, "hi")
+	add := (*Calculator).Add
+	bound := c.Add
+	handlers := []func(string){PrintMessage}
+	upper := strings.ToUpper
+	_, _, _, _, _ = f, add, bound, handlers, upper
+}
+
+func Shadow() string {
+	PrintMessage := "local"
+	return PrintMessage
+}
+"#,
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+
+    // Locals that shadow PrintMessage are not references to it
+    assert_eq!(
+        references_of(&graph, "PrintMessage"),
+        vec![
+            ("Use".to_string(), 14, ReferenceKind::Assignment),
+            ("Use".to_string(), 15, ReferenceKind::Argument),
+            ("Use".to_string(), 18, ReferenceKind::Value),
+        ]
+    );
+    // The only call is a possible one, through run's parameter
+    let callers = graph.callers("PrintMessage");
+    assert_eq!(callers.len(), 1);
+    assert_eq!(callers[0].caller, "run");
+    assert!(callers[0].indirect);
+
+    // Method expressions and method values both resolve to the method
+    assert_eq!(
+        references_of(&graph, "(*Calculator).Add"),
+        vec![
+            ("Use".to_string(), 16, ReferenceKind::MethodValue),
+            ("Use".to_string(), 17, ReferenceKind::MethodValue),
+        ]
+    );
+
+    // Symbols outside the index are matched by name, as with callers
+    assert_eq!(
+        references_of(&graph, "ToUpper"),
+        vec![("Use".to_string(), 19, ReferenceKind::Assignment)]
+    );
+}
+
+#[test]
+fn test_dead_code_is_unreachable_from_entry_points() {
+    let graph = index_dir(&fixture_dir("go-deadcode"));
+    let dead = |options: DeadCodeOptions| -> Vec<String> {
+        graph
+            .dead_code(&options)
+            .unwrap()
+            .dead
+            .iter()
+            .map(|node| node.name.clone())
+            .collect()
+    };
+
+    // Square.Area is only called through Shape, double only passed as a value, and
+    // shapes.Scale is exported from a library package
+    assert_eq!(dead(DeadCodeOptions::default()), vec!["Divide"]);
+
+    let strict = DeadCodeOptions {
+        strict: true,
+        ..Default::default()
+    };
+    assert_eq!(dead(strict), vec!["Divide", "Scale"]);
+
+    let rooted = DeadCodeOptions {
+        roots: vec!["Divide".to_string()],
+        ..Default::default()
+    };
+    assert!(dead(rooted).is_empty());
+
+    let roots: Vec<String> = graph
+        .dead_code(&DeadCodeOptions::default())
+        .unwrap()
+        .roots
+        .iter()
+        .map(|node| node.name.clone())
+        .collect();
+    assert_eq!(roots, vec!["init", "main", "Sum", "Scale"]);
+}
+
+#[test]
+fn test_cycles_report_mutual_and_self_recursion() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let cycles = graph.cycles();
+
+    let summary: Vec<(Vec<String>, Vec<usize>, bool)> = cycles
+        .iter()
+        .map(|cycle| {
+            (
+                cycle.nodes.iter().map(|n| n.name.clone()).collect(),
+                cycle.calls.iter().map(|e| e.line).collect(),
+                cycle.self_recursive,
+            )
+        })
+        .collect();
+    assert_eq!(
+        summary,
+        vec![
+            (
+                vec!["Even".to_string(), "Odd".to_string()],
+                vec![48, 55],
+                false
+            ),
+            (vec!["Factorial".to_string()], vec![63], true),
+        ]
+    );
+    assert_eq!(cycles[0].calls[0].call_site, "Odd(n - 1)");
+}
+
+fn path_summary(paths: &[code_navigator::core::CallPath]) -> Vec<(Vec<String>, Vec<usize>)> {
+    paths
+        .iter()
+        .map(|path| {
+            (
+                path.names(),
+                path.hops.iter().map(|hop| hop.call.line).collect(),
+            )
+        })
+        .collect()
+}
+
+#[test]
+fn test_call_paths_shortest_all_and_unreachable() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let strings = |names: &[&str]| names.iter().map(|n| n.to_string()).collect::<Vec<_>>();
+
+    let shortest = graph
+        .call_paths("main", "PrintMessage", &PathOptions::default())
+        .unwrap();
+    assert_eq!(
+        path_summary(&shortest),
+        vec![(
+            strings(&["main", "Greet", "Greet.func1", "PrintMessage"]),
+            vec![34, 22, 22]
+        )]
+    );
+    assert!(shortest[0].hops[0]
+        .call
+        .file_path
+        .ends_with("simple-go/main.go"));
+
+    // Multiply calls Add twice, so it contributes two paths
+    let all = PathOptions {
+        all: true,
+        ..PathOptions::default()
+    };
+    assert_eq!(
+        path_summary(&graph.call_paths("main", "Add", &all).unwrap()),
+        vec![
+            (strings(&["main", "Add"]), vec![31]),
+            (strings(&["main", "Multiply", "Add"]), vec![12]),
+            (strings(&["main", "Multiply", "Add"]), vec![14]),
+        ]
+    );
+    let limited = PathOptions {
+        limit: Some(1),
+        ..all.clone()
+    };
+    assert_eq!(graph.call_paths("main", "Add", &limited).unwrap().len(), 1);
+
+    // Targets outside the index match by name
+    let external = graph
+        .call_paths("main", "fmt.Println", &PathOptions::default())
+        .unwrap();
+    assert_eq!(
+        external[0].names(),
+        strings(&[
+            "main",
+            "Greet",
+            "Greet.func1",
+            "PrintMessage",
+            "fmt.Println"
+        ])
+    );
+    let shallow = PathOptions {
+        max_depth: 2,
+        ..PathOptions::default()
+    };
+    assert!(graph
+        .call_paths("main", "Println", &shallow)
+        .unwrap()
+        .is_empty());
+
+    assert!(graph
+        .call_paths("PrintMessage", "main", &PathOptions::default())
+        .unwrap()
+        .is_empty());
+}
+
+#[test]
+fn test_call_paths_need_qualified_names_when_ambiguous() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let err = graph
+        .call_paths("main", "LogOperation", &PathOptions::default())
+        .unwrap_err()
+        .to_string();
+    assert!(err.contains("Ambiguous symbol LogOperation"), "{}", err);
+    assert!(err.contains("(*Calculator).LogOperation"), "{}", err);
+
+    let paths = graph
+        .call_paths("(*Calculator).Add", "PrintMessage", &PathOptions::default())
+        .unwrap();
+    assert_eq!(
+        path_summary(&paths),
+        vec![(
+            vec![
+                "(*Calculator).Add".to_string(),
+                "(*Calculator).LogOperation".to_string(),
+                "PrintMessage".to_string()
+            ],
+            vec![19, 33]
+        )]
+    );
+}
+
+#[test]
+fn test_limited_call_paths_are_the_shortest() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("main.go"),
+        r#"package main
+
+func main() {
+	a()
+	b()
+	target()
+}
+
+func a() {
+	b()
+	target()
+}
+
+func b() {
+	target()
+}
+
+func target() {}
+"#,
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+    let strings = |names: &[&str]| names.iter().map(|n| n.to_string()).collect::<Vec<_>>();
+
+    // Depth first, main → a → b → target is found before the direct call
+    let limited = PathOptions {
+        all: true,
+        limit: Some(2),
+        ..PathOptions::default()
+    };
+    assert_eq!(
+        path_summary(&graph.call_paths("main", "target", &limited).unwrap()),
+        vec![
+            (strings(&["main", "target"]), vec![6]),
+            (strings(&["main", "a", "target"]), vec![4, 11]),
+        ]
+    );
+
+    let all = PathOptions {
+        all: true,
+        ..PathOptions::default()
+    };
+    assert_eq!(graph.call_paths("main", "target", &all).unwrap().len(), 4);
+}
+
+#[test]
+fn test_struct_fields_are_symbols_with_references() {
+    use code_navigator::core::SearchOptions;
+
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let field = graph.resolve_symbol("name").unwrap();
+    assert_eq!(field.name, "Calculator.name");
+    assert_eq!(field.node_type, NodeType::Field);
+    assert_eq!((field.line, field.column), (8, 2));
+    assert_eq!(
+        field.metadata.get("declared_type").map(String::as_str),
+        Some("string")
+    );
+    assert_eq!(
+        graph.resolve_symbol("Calculator.name").unwrap().id,
+        field.id
+    );
+
+    // The literal key in NewCalculator and SetName set it; the other methods read it
+    // through their receiver
+    assert_eq!(
+        references_of(&graph, "name"),
+        vec![
+            ("NewCalculator".to_string(), 13, ReferenceKind::Write),
+            (
+                "(*Calculator).LogOperation".to_string(),
+                32,
+                ReferenceKind::Argument
+            ),
+            ("Calculator.Name".to_string(), 38, ReferenceKind::Value),
+            (
+                "(*Calculator).SetName".to_string(),
+                55,
+                ReferenceKind::Write
+            ),
+        ]
+    );
+    assert_eq!(graph.references("name")[0].column, 21);
+
+    let options = SearchOptions {
+        kinds: vec![NodeType::Field],
+        ..Default::default()
+    };
+    let fields: Vec<String> = graph
+        .search("name", &options)
+        .iter()
+        .map(|m| m.node.name.clone())
+        .collect();
+    assert_eq!(fields, vec!["Calculator.name"]);
+}
+
+#[test]
+fn test_grouped_const_var_and_type_declarations() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("decls.go"),
+        r#"package decls
+
+const (
+	A = 1; B = 2
+)
+
+var (
+	Default Celsius = 20
+	x, y = A, B
+)
+
+var _ = x
+
+type Celsius float64
+
+type Temp = Celsius
+
+type Point struct {
+	X, Y int
+	Label string `json:"label"`
+}
+
+func Origin() Point {
+	return Point{X: A, Y: B}
+}
+
+func Describe(p *Point) string {
+	return p.Label
+}
+"#,
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+
+    let mut nodes: Vec<_> = graph.nodes.iter().collect();
+    nodes.sort_by_key(|n| (n.line, n.column));
+    let summary: Vec<(String, NodeType, usize, usize)> = nodes
+        .iter()
+        .map(|n| (n.name.clone(), n.node_type.clone(), n.line, n.column))
+        .collect();
+    let expected = [
+        ("A", NodeType::Const, 4, 2),
+        ("B", NodeType::Const, 4, 9),
+        ("Default", NodeType::Var, 8, 2),
+        ("x", NodeType::Var, 9, 2),
+        ("y", NodeType::Var, 9, 5),
+        ("Celsius", NodeType::Type, 14, 6),
+        ("Temp", NodeType::Type, 16, 6),
+        ("Point", NodeType::Struct, 18, 6),
+        ("Point.X", NodeType::Field, 19, 2),
+        ("Point.Y", NodeType::Field, 19, 5),
+        ("Point.Label", NodeType::Field, 20, 2),
+        ("Origin", NodeType::Function, 23, 6),
+        ("Describe", NodeType::Function, 27, 6),
+    ];
+    assert_eq!(
+        summary,
+        expected
+            .iter()
+            .map(|(name, kind, line, column)| (name.to_string(), kind.clone(), *line, *column))
+            .collect::<Vec<_>>()
+    );
+    assert!(graph.metadata.diagnostics.is_empty());
+
+    let a = graph.resolve_symbol("A").unwrap();
+    assert_eq!(a.signature, "const A = 1");
+    let default = graph.resolve_symbol("Default").unwrap();
+    assert_eq!(default.signature, "var Default Celsius = 20");
+    assert_eq!(
+        default.metadata.get("declared_type").map(String::as_str),
+        Some("Celsius")
+    );
+    let temp = graph.resolve_symbol("Temp").unwrap();
+    assert_eq!(temp.metadata.get("alias").map(String::as_str), Some("true"));
+
+    assert_eq!(
+        references_of(&graph, "A"),
+        vec![("Origin".to_string(), 24, ReferenceKind::Value)]
+    );
+    assert_eq!(
+        references_of(&graph, "X"),
+        vec![("Origin".to_string(), 24, ReferenceKind::Write)]
+    );
+    assert_eq!(
+        references_of(&graph, "Point.Label"),
+        vec![("Describe".to_string(), 28, ReferenceKind::Value)]
+    );
+}
+
+fn field_accesses_of(graph: &CodeGraph, field: &str) -> Vec<(String, FieldAccessKind, usize)> {
+    graph
+        .field_accesses(field)
+        .unwrap()
+        .iter()
+        .map(|access| {
+            (
+                access.function.map(|f| f.name.clone()).unwrap_or_default(),
+                access.kind,
+                access.reference.line,
+            )
+        })
+        .collect()
+}
+
+#[test]
+fn test_field_accesses_classify_reads_writes_and_inits() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    assert_eq!(
+        field_accesses_of(&graph, "Calculator.name"),
+        vec![
+            ("NewCalculator".to_string(), FieldAccessKind::Init, 13),
+            (
+                "(*Calculator).LogOperation".to_string(),
+                FieldAccessKind::Read,
+                32
+            ),
+            ("Calculator.Name".to_string(), FieldAccessKind::Read, 38),
+            (
+                "(*Calculator).SetName".to_string(),
+                FieldAccessKind::Write,
+                55
+            ),
+        ]
+    );
+    let write = &graph.field_accesses("name").unwrap()[3];
+    assert_eq!((write.reference.line, write.reference.column), (55, 2));
+    assert_eq!(write.field.name, "Calculator.name");
+
+    let err = graph.field_accesses("Add").unwrap_err().to_string();
+    assert!(err.contains("Field not found: Add"), "{}", err);
+}
+
+#[test]
+fn test_field_accesses_through_pointers() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("counter.go"),
+        r#"package counter
+
+type Counter struct {
+	count int
+	step  int
+}
+
+func Tick(c *Counter) {
+	c.count += c.step
+	(*c).count++
+}
+
+func New() *Counter {
+	c := &Counter{}
+	c.step = 1
+	return c
+}
+"#,
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+
+    assert_eq!(
+        field_accesses_of(&graph, "count"),
+        vec![
+            ("Tick".to_string(), FieldAccessKind::Write, 9),
+            ("Tick".to_string(), FieldAccessKind::Write, 10),
+        ]
+    );
+    assert_eq!(
+        field_accesses_of(&graph, "Counter.step"),
+        vec![
+            ("Tick".to_string(), FieldAccessKind::Read, 9),
+            ("New".to_string(), FieldAccessKind::Write, 15),
+        ]
+    );
+}
+
+/// Each file's name with the (line, column) of every edit in it
+fn rename_sites(plan: &RenamePlan) -> Vec<(String, Vec<(usize, usize)>)> {
+    plan.files()
+        .into_iter()
+        .map(|(file, edits)| {
+            (
+                file.file_name().unwrap().to_string_lossy().to_string(),
+                edits.iter().map(|edit| (edit.line, edit.column)).collect(),
+            )
+        })
+        .collect()
+}
+
+#[test]
+fn test_plan_rename_lists_definition_and_references() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let plan = graph.plan_rename("PrintMessage", "Show").unwrap();
+    assert!(plan.conflicts.is_empty());
+    assert_eq!(
+        rename_sites(&plan),
+        vec![
+            ("calculator.go".to_string(), vec![(33, 2)]),
+            ("main.go".to_string(), vec![(22, 17), (26, 6)]),
+        ]
+    );
+    assert!(plan
+        .edits
+        .iter()
+        .all(|edit| edit.old_text == "PrintMessage" && edit.new_text == "Show"));
+
+    // The call through `op` doesn't spell the name, so only `op := Add` is edited
+    let plan = graph.plan_rename("Add", "Multiply").unwrap();
+    assert_eq!(
+        rename_sites(&plan),
+        vec![(
+            "main.go".to_string(),
+            vec![(6, 6), (12, 12), (14, 12), (31, 9), (39, 8)]
+        )]
+    );
+    let conflicts: Vec<(&str, usize, &str)> = plan
+        .conflicts
+        .iter()
+        .map(|c| (c.existing.name.as_str(), c.existing.line, c.scope.as_str()))
+        .collect();
+    assert_eq!(conflicts, vec![("Multiply", 11, "package main")]);
+}
+
+#[test]
+fn test_plan_rename_of_field_checks_methods_of_the_type() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let plan = graph.plan_rename("Calculator.name", "Name").unwrap();
+    assert_eq!(
+        rename_sites(&plan),
+        vec![(
+            "calculator.go".to_string(),
+            vec![(8, 2), (13, 21), (32, 38), (38, 11), (55, 4)]
+        )]
+    );
+    let conflicts: Vec<(&str, &str)> = plan
+        .conflicts
+        .iter()
+        .map(|c| (c.existing.name.as_str(), c.scope.as_str()))
+        .collect();
+    assert_eq!(conflicts, vec![("Calculator.Name", "type Calculator")]);
+
+    let err = graph.plan_rename("Add", "2x").unwrap_err().to_string();
+    assert!(err.contains("Invalid name: 2x"), "{}", err);
+    let err = graph
+        .plan_rename("Calculator", "Calc")
+        .unwrap_err()
+        .to_string();
+    assert!(err.contains("not supported"), "{}", err);
+}
+
+fn index_with_tests(dir: &Path) -> CodeGraph {
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    let mut parser = GoParser::new().unwrap().with_tests(true);
+    parser.parse_directory(dir, &mut graph).unwrap();
+    graph
+}
+
+fn tests_reaching(graph: &CodeGraph, symbol: &str) -> Vec<Vec<String>> {
+    graph
+        .tests_reaching(symbol, 10)
+        .unwrap()
+        .iter()
+        .map(|coverage| coverage.path.names())
+        .collect()
+}
+
+#[test]
+fn test_tests_reaching_direct_and_transitive() {
+    let dir = fixture_dir("simple-go");
+    assert!(!index_dir(&dir).has_tests());
+
+    let graph = index_with_tests(&dir);
+    assert!(graph.has_tests());
+    assert_eq!(
+        tests_reaching(&graph, "Calculator.Add"),
+        vec![vec!["TestCalculatorAdd", "(*Calculator).Add"]]
+    );
+    assert_eq!(
+        tests_reaching(&graph, "PrintMessage"),
+        vec![
+            vec!["BenchmarkGreet", "Greet", "PrintMessage"],
+            vec![
+                "TestCalculatorAdd",
+                "(*Calculator).Add",
+                "(*Calculator).LogOperation",
+                "PrintMessage"
+            ],
+        ]
+    );
+    // Helpers in test files aren't tests
+    assert!(tests_reaching(&graph, "NewCalculator").is_empty());
+    assert!(graph
+        .tests_reaching("PrintMessage", 2)
+        .unwrap()
+        .iter()
+        .all(|coverage| coverage.test.name == "BenchmarkGreet"));
+}
+
+#[test]
+fn test_test_files_stay_out_of_dead_code() {
+    let dir = fixture_dir("simple-go");
+    let dead = |graph: &CodeGraph| -> (Vec<String>, Vec<String>) {
+        let report = graph.dead_code(&DeadCodeOptions::default()).unwrap();
+        let names = |nodes: &[&code_navigator::core::Node]| {
+            nodes.iter().map(|node| node.name.clone()).collect()
+        };
+        (names(&report.roots), names(&report.dead))
+    };
+
+    let with_tests = index_with_tests(&dir);
+    let helper = &with_tests.get_nodes_by_name("newTestCalculator")[0];
+    assert_eq!(
+        helper.metadata.get("test").map(String::as_str),
+        Some("true")
+    );
+    // Tests neither keep code alive nor show up as unused themselves
+    assert_eq!(dead(&with_tests), dead(&index_dir(&dir)));
+}
+
+#[test]
+fn test_external_test_package_reaches_code_through_its_import() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(dir.path().join("go.mod"), "module example.com/shop\n").unwrap();
+    fs::create_dir(dir.path().join("cart")).unwrap();
+    fs::write(
+        dir.path().join("cart").join("cart.go"),
+        r#"package cart
+
+func Total(prices []int) int {
+	return sum(prices)
+}
+
+func sum(prices []int) int {
+	n := 0
+	for _, p := range prices {
+		n += p
+	}
+	return n
+}
+"#,
+    )
+    .unwrap();
+    fs::write(
+        dir.path().join("cart").join("cart_test.go"),
+        r#"package cart_test
+
+import (
+	"testing"
+
+	"example.com/shop/cart"
+)
+
+func TestTotal(t *testing.T) {
+	if cart.Total([]int{1, 2}) != 3 {
+		t.Fatal("wrong total")
+	}
+}
+"#,
+    )
+    .unwrap();
+    let graph = index_with_tests(dir.path());
+
+    let test = &graph.get_nodes_by_name("TestTotal")[0];
+    assert_eq!(test.package, "cart_test");
+    assert_eq!(
+        tests_reaching(&graph, "sum"),
+        vec![vec!["TestTotal", "Total", "sum"]]
+    );
+}
+
+#[test]
+fn test_benchmarks_reaching_through_run_and_fuzz_callbacks() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("parse.go"),
+        r#"package parse
+
+func Parse(s string) int {
+	return count(s)
+}
+
+func count(s string) int {
+	return len(s)
+}
+"#,
+    )
+    .unwrap();
+    fs::write(
+        dir.path().join("parse_test.go"),
+        r#"package parse
+
+import "testing"
+
+func BenchmarkParse(b *testing.B) {
+	for _, input := range []string{"short", "a longer input"} {
+		b.Run(input, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Parse(input)
+			}
+		})
+	}
+}
+
+func BenchmarkCount(b *testing.B) {
+	count("x")
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("seed")
+	f.Fuzz(func(t *testing.T, s string) {
+		Parse(s)
+	})
+}
+
+func TestParse(t *testing.T) {
+	Parse("x")
+}
+
+func BenchmarkOf(name string) {}
+"#,
+    )
+    .unwrap();
+    let graph = index_with_tests(dir.path());
+
+    let kind = |name: &str| graph.get_nodes_by_name(name)[0].node_type.clone();
+    assert_eq!(kind("BenchmarkParse"), NodeType::Benchmark);
+    assert_eq!(kind("FuzzParse"), NodeType::Fuzz);
+    assert_eq!(kind("TestParse"), NodeType::Function);
+    // Not a benchmark without a *testing.B
+    assert_eq!(kind("BenchmarkOf"), NodeType::Function);
+
+    let benchmarks = graph.benchmarks_reaching("count", 10).unwrap();
+    let names: Vec<Vec<String>> = benchmarks.iter().map(|b| b.names()).collect();
+    assert_eq!(
+        names,
+        vec![
+            vec!["BenchmarkCount", "count"],
+            vec!["BenchmarkParse", "BenchmarkParse.func1", "Parse", "count"],
+            vec!["FuzzParse", "FuzzParse.func1", "Parse", "count"],
+        ]
+    );
+    assert!(benchmarks[0].is_direct() && benchmarks[0].closure.is_none());
+    // The callback's call is the first hop, counted from the benchmark
+    assert_eq!(benchmarks[1].path.start.name, "BenchmarkParse");
+    assert_eq!(benchmarks[1].path.hops.len(), 2);
+    assert_eq!(benchmarks[1].path.hops[0].call.line, 9);
+    // JSON names the benchmark as such, not as a test
+    let json = serde_json::to_value(code_navigator::schema::BenchmarkCoverage::from(
+        &benchmarks[1],
+    ))
+    .unwrap();
+    assert_eq!(json["benchmark"]["name"], "BenchmarkParse");
+    assert_eq!(json["closure"]["name"], "BenchmarkParse.func1");
+    assert!(json.get("test").is_none());
+
+    // Tests reach through subtest callbacks the same way
+    assert_eq!(
+        tests_reaching(&graph, "Parse"),
+        vec![
+            vec!["BenchmarkParse", "Parse"],
+            vec!["FuzzParse", "Parse"],
+            vec!["TestParse", "Parse"],
+        ]
+    );
+}
+
+#[test]
+fn test_closures_are_nodes_named_after_their_function() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let closure = &graph.get_nodes_by_name("Greet.func1")[0];
+    assert_eq!((closure.line, closure.column), (22, 8));
+    assert_eq!(closure.node_type, NodeType::Function);
+    assert_eq!(closure.metadata.get("enclosing").unwrap(), "Greet");
+
+    // The deferred literal is invoked in place, so Greet calls it
+    assert_eq!(callees_of(&graph, "Greet"), vec!["Sprintf", "Greet.func1"]);
+    assert_eq!(callees_of(&graph, "Greet.func1"), vec!["PrintMessage"]);
+
+    let paths = graph
+        .call_paths("Greet", "PrintMessage", &PathOptions::default())
+        .unwrap();
+    assert_eq!(
+        paths[0].names(),
+        vec!["Greet", "Greet.func1", "PrintMessage"]
+    );
+    assert!(graph
+        .transitive_callers("PrintMessage", 2)
+        .iter()
+        .any(|site| site.caller == "Greet" && site.depth == 2));
+}
+
+#[test]
+fn test_closures_passed_as_values_are_only_referenced() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("main.go"),
+        r#"package main
+
+func Walk() {
+	run(func() {
+		PrintMessage("nested")
+		go func() { PrintMessage("inner") }()
+	})
+}
+
+func PrintMessage(msg string) {}
+
+func run(f func()) { f() }
+
+type Counter struct{}
+
+func (c *Counter) Each() {
+	double := func(n int) int { return n * 2 }
+	_ = double
+}
+"#,
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+
+    assert_eq!(callees_of(&graph, "Walk"), vec!["run"]);
+    assert_eq!(
+        callees_of(&graph, "Walk.func1"),
+        vec!["PrintMessage", "Walk.func1.1"]
+    );
+    assert_eq!(
+        references_of(&graph, "Walk.func1"),
+        vec![("Walk".to_string(), 4, ReferenceKind::Closure)]
+    );
+    assert_eq!(
+        graph.get_nodes_by_name("(*Counter).Each.func1")[0].parameters[0].param_type,
+        "int"
+    );
+}
+
+#[test]
+fn test_calls_through_parameters_reach_every_function_passed() {
+    let mut graph = index_dir(&fixture_dir("go-callbacks"));
+
+    let apply = graph.resolve_symbol("apply").unwrap();
+    let edges = graph.get_outgoing_edges(&apply.id);
+    let (indirect, direct): (Vec<&Edge>, Vec<&Edge>) =
+        edges.into_iter().partition(|e| e.is_indirect());
+
+    // f(a, b) itself resolves to nothing; each function passed for f gets an edge
+    assert_eq!(direct.len(), 1);
+    assert_eq!(direct[0].to, "f");
+    assert!(graph.edge_targets(direct[0]).is_empty());
+    let mut candidates: Vec<&str> = indirect.iter().map(|e| e.to.as_str()).collect();
+    candidates.sort();
+    assert_eq!(
+        candidates,
+        vec!["(*Calculator).Add", "Add", "Subtract", "main.func1"]
+    );
+    for edge in &indirect {
+        assert_eq!((edge.line, edge.column), (15, 9));
+        assert_eq!(edge.call_site, "f(a, b)");
+        assert_eq!(edge.metadata.get("via").map(String::as_str), Some("f"));
+    }
+
+    let paths = graph
+        .call_paths("main", "Subtract", &PathOptions::default())
+        .unwrap();
+    assert_eq!(paths[0].names(), vec!["main", "apply", "Subtract"]);
+    let callers = graph.callers("Add");
+    assert_eq!(callers.len(), 1);
+    assert!(callers[0].indirect);
+
+    graph.remove_indirect_calls();
+    assert!(graph.callers("Add").is_empty());
+    assert!(graph
+        .call_paths("main", "Subtract", &PathOptions::default())
+        .unwrap()
+        .is_empty());
+}
+
+#[test]
+fn test_calls_through_locals_and_fields_reach_every_function_stored() {
+    let graph = index_dir(&fixture_dir("go-callbacks"));
+    let indirect = |function: &str| -> Vec<(String, usize, String)> {
+        let function = graph.resolve_symbol(function).unwrap();
+        let mut calls: Vec<_> = graph
+            .get_outgoing_edges(&function.id)
+            .into_iter()
+            .filter(|edge| edge.is_indirect())
+            .map(|edge| {
+                let via = edge.metadata.get("via").cloned().unwrap_or_default();
+                (edge.to.clone(), edge.line, via)
+            })
+            .collect();
+        calls.sort();
+        calls
+    };
+
+    // step holds Dec where it is called, and Inc on the other path
+    let pick = graph.resolve_symbol("Pick").unwrap();
+    let followed = graph
+        .get_outgoing_edges(&pick.id)
+        .into_iter()
+        .find(|edge| !edge.is_indirect())
+        .unwrap();
+    assert_eq!(graph.edge_targets(followed)[0].name, "Dec");
+    assert_eq!(
+        indirect("Pick"),
+        vec![("Inc".to_string(), 30, "step".to_string())]
+    );
+
+    // A literal held by a local
+    assert_eq!(
+        indirect("Square"),
+        vec![("Square.func1".to_string(), 36, "sq".to_string())]
+    );
+
+    // s.fn(n) may call what a literal or an assignment stored in Step.fn
+    assert_eq!(
+        indirect("(*Step).Do"),
+        vec![
+            ("Dec".to_string(), 13, "Step.fn".to_string()),
+            ("Inc".to_string(), 13, "Step.fn".to_string()),
+        ]
+    );
+    let mut callers: Vec<String> = graph
+        .callers("Inc")
+        .iter()
+        .map(|site| site.caller.clone())
+        .collect();
+    callers.sort();
+    assert_eq!(callers, vec!["(*Step).Do", "Pick"]);
+}
+
+#[test]
+fn test_parameters_survive_a_parenthesized_result_list() {
+    let graph = index_dir(&fixture_dir("go-callbacks"));
+
+    let checked = graph.resolve_symbol("checked").unwrap();
+    let params: Vec<&str> = checked.parameters.iter().map(|p| p.name.as_str()).collect();
+    assert_eq!(params, vec!["f", "l", "a", "b"]);
+
+    // f(a, b) reaches the function Checked passes, l's method call its declaration
+    let edges = graph.get_outgoing_edges(&checked.id);
+    assert!(edges
+        .iter()
+        .any(|edge| edge.is_indirect() && edge.to == "Subtract" && edge.line == 6));
+    let logged = edges
+        .iter()
+        .find(|edge| edge.line == 5)
+        .expect("l.LogOperation is a call");
+    let targets = graph.edge_targets(logged);
+    assert_eq!(targets.len(), 1);
+    assert_eq!(targets[0].name, "(*Logger).LogOperation");
+}
+
+#[test]
+fn test_calls_through_method_values_and_expressions() {
+    let mut graph = index_dir(&fixture_dir("go-callbacks"));
+    let calls = |graph: &CodeGraph, function: &str| -> Vec<(String, usize, bool)> {
+        let function = graph.resolve_symbol(function).unwrap();
+        graph
+            .get_outgoing_edges(&function.id)
+            .iter()
+            .map(|edge| (edge.to.clone(), edge.line, edge.is_indirect()))
+            .collect()
+    };
+
+    // f := c.Add; f(1, 2) binds the receiver, g := (*Calculator).Subtract takes it first
+    assert_eq!(
+        calls(&graph, "Bound"),
+        vec![("(*Calculator).Add".to_string(), 21, true)]
+    );
+    assert_eq!(
+        calls(&graph, "Unbound"),
+        vec![("(*Calculator).Subtract".to_string(), 27, true)]
+    );
+    let bound = graph.resolve_symbol("Bound").unwrap();
+    let edge = graph.get_outgoing_edges(&bound.id)[0];
+    assert_eq!(edge.metadata.get("via").map(String::as_str), Some("f"));
+    assert_eq!(
+        edge.metadata.get("binding").map(String::as_str),
+        Some("method_value")
+    );
+    let add = graph.resolve_symbol("(*Calculator).Add").unwrap();
+    let targets: Vec<&str> = graph
+        .edge_targets(edge)
+        .iter()
+        .map(|node| node.id.as_str())
+        .collect();
+    assert_eq!(targets, vec![add.id.as_str()]);
+
+    // apply(c.Add, 1, 2): apply's f(a, b) may call the method
+    let apply = graph.resolve_symbol("apply").unwrap();
+    assert!(graph
+        .get_outgoing_edges(&apply.id)
+        .iter()
+        .any(|edge| edge.is_indirect() && edge.to == "(*Calculator).Add"));
+    let mut callers: Vec<(String, bool)> = graph
+        .callers("(*Calculator).Add")
+        .iter()
+        .map(|site| (site.caller.clone(), site.indirect))
+        .collect();
+    callers.sort();
+    assert_eq!(
+        callers,
+        vec![("Bound".to_string(), true), ("apply".to_string(), true)]
+    );
+
+    // Re-resolving keeps them, --no-indirect drops them
+    GoParser::resolve_calls(&mut graph);
+    assert_eq!(calls(&graph, "Bound").len(), 1);
+    graph.remove_indirect_calls();
+    assert!(calls(&graph, "Bound").is_empty());
+}
+
+#[test]
+fn test_go_and_defer_calls_are_marked() {
+    let graph = index_dir(&fixture_dir("go-callbacks"));
+
+    let run = graph.resolve_symbol("Run").unwrap();
+    let mut calls: Vec<(String, usize, EdgeKind)> = graph
+        .get_outgoing_edges(&run.id)
+        .iter()
+        .map(|edge| (edge.to.clone(), edge.line, edge.kind()))
+        .collect();
+    calls.sort_by_key(|(_, line, _)| *line);
+    assert_eq!(
+        calls,
+        vec![
+            ("(*Logger).LogOperation".to_string(), 15, EdgeKind::Defer),
+            ("report".to_string(), 16, EdgeKind::Go),
+            ("Run.func1".to_string(), 17, EdgeKind::Go),
+            ("report".to_string(), 18, EdgeKind::Normal),
+        ]
+    );
+
+    // The deferred receiver call resolves to the method of l's type
+    let deferred = graph
+        .get_outgoing_edges(&run.id)
+        .into_iter()
+        .find(|edge| edge.kind() == EdgeKind::Defer)
+        .unwrap();
+    let targets = graph.edge_targets(deferred);
+    assert_eq!(targets.len(), 1);
+    assert_eq!(targets[0].metadata.get("receiver_type").unwrap(), "Logger");
+
+    let names = |kind: EdgeKind| -> Vec<String> {
+        graph
+            .called_as(kind)
+            .iter()
+            .map(|node| node.name.clone())
+            .collect()
+    };
+    assert_eq!(names(EdgeKind::Go), vec!["Run.func1", "report"]);
+    assert_eq!(names(EdgeKind::Defer), vec!["(*Logger).LogOperation"]);
+}
+
+#[test]
+fn test_doc_comments_are_attached() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let doc = |name: &str| {
+        graph
+            .resolve_symbol(name)
+            .unwrap()
+            .documentation
+            .clone()
+            .unwrap_or_default()
+    };
+    assert_eq!(doc("Add"), "Add adds two numbers");
+    assert_eq!(doc("(*Calculator).Add"), "Add adds two numbers (method)");
+
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("docs.go"),
+        r#"package docs
+
+// Sum adds its arguments.
+//
+// It never overflows.   
+//
+//
+//go:noinline
+func Sum(a, b int) int { return a + b }
+
+// Point is a position.
+type Point struct {
+	// X is the horizontal offset.
+	X int
+	Y int // trailing, documents nothing above
+}
+
+type (
+	// Celsius is a temperature.
+	Celsius float64
+)
+
+/*
+Describe renders p.
+
+	It keeps indentation.
+*/
+func Describe(p Point) string { return "" }
+
+// Detached from what follows.
+
+func Bare() {}
+"#,
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+    let doc = |name: &str| graph.resolve_symbol(name).unwrap().documentation.clone();
+
+    assert_eq!(
+        doc("Sum").as_deref(),
+        Some("Sum adds its arguments.\n\nIt never overflows.")
+    );
+    assert_eq!(doc("Point").as_deref(), Some("Point is a position."));
+    assert_eq!(
+        doc("Point.X").as_deref(),
+        Some("X is the horizontal offset.")
+    );
+    assert_eq!(doc("Point.Y"), None);
+    assert_eq!(doc("Celsius").as_deref(), Some("Celsius is a temperature."));
+    assert_eq!(
+        doc("Describe").as_deref(),
+        Some("Describe renders p.\n\n\tIt keeps indentation.")
+    );
+    assert_eq!(doc("Bare"), None);
+
+    let bare = code_navigator::schema::Symbol::from(graph.resolve_symbol("Bare").unwrap());
+    assert_eq!(bare.doc, "");
+}
+
+#[test]
+fn test_outline_groups_members_by_type() {
+    let dir = fixture_dir("simple-go");
+    let graph = index_dir(&dir);
+    let outline = graph.outline(&dir.join("calculator.go"));
+
+    let tree: Vec<(&str, usize, Vec<(&str, NodeType, usize)>)> = outline
+        .iter()
+        .map(|entry| {
+            let children = entry
+                .children
+                .iter()
+                .map(|member| {
+                    (
+                        member.node.identifier(),
+                        member.node.node_type.clone(),
+                        member.node.line,
+                    )
+                })
+                .collect();
+            (entry.node.name.as_str(), entry.node.line, children)
+        })
+        .collect();
+    assert_eq!(
+        tree,
+        vec![
+            (
+                "Calculator",
+                6,
+                vec![
+                    ("name", NodeType::Field, 8),
+                    ("Add", NodeType::Method, 17),
+                    ("Subtract", NodeType::Method, 24),
+                    ("LogOperation", NodeType::Method, 31),
+                    ("Name", NodeType::Method, 37),
+                    ("SetName", NodeType::Method, 54),
+                ]
+            ),
+            ("NewCalculator", 12, vec![]),
+            (
+                "Logger",
+                42,
+                vec![
+                    ("LogOperation", NodeType::Method, 43),
+                    ("Name", NodeType::Method, 44),
+                ]
+            ),
+            ("Recorder", 48, vec![("LogOperation", NodeType::Method, 51)]),
+            ("logger", 60, vec![("Warn", NodeType::Method, 63)]),
+        ]
+    );
+    // The whole declaration, not just the line naming it
+    assert_eq!(outline[0].node.end_line, 9);
+    assert_eq!(outline[0].children[1].node.end_line, 21);
+
+    // Methods on a type from another file stay at the top level
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("types.go"),
+        "package p\n\ntype T struct{}\n",
+    )
+    .unwrap();
+    fs::write(
+        dir.path().join("methods.go"),
+        "package p\n\nfunc (T) M() {}\n\nfunc F() {}\n",
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+    let names: Vec<(&str, usize)> = graph
+        .outline(&dir.path().join("methods.go"))
+        .iter()
+        .map(|entry| (entry.node.name.as_str(), entry.children.len()))
+        .collect();
+    assert_eq!(names, vec![("T.M", 0), ("F", 0)]);
+}
+
+#[test]
+fn test_spans_slice_back_to_the_declaration() {
+    let dir = fixture_dir("simple-go");
+    let graph = index_dir(&dir);
+    let source = fs::read_to_string(dir.join("calculator.go")).unwrap();
+
+    let add = graph.resolve_symbol("(*Calculator).Add").unwrap();
+    let span = add.span.unwrap();
+    assert_eq!(
+        &source[span.start.offset..span.end.offset],
+        "// Add adds two numbers (method)\nfunc (c *Calculator) Add(a, b int) int {\n\tresult := a + b\n\tc.LogOperation(\"Add\", result)\n\treturn result\n}"
+    );
+    assert_eq!((span.start.line, span.end.line), (16, add.end_line));
+    let name = add.name_span.unwrap();
+    assert_eq!(&source[name.start.offset..name.end.offset], "Add");
+    assert_eq!((name.start.line, name.start.column), (add.line, add.column));
+
+    // Offsets count bytes, so multi-byte text ahead of a symbol doesn't throw them off
+    let dir = tempfile::tempdir().unwrap();
+    let source = "package main\n\n// Grüße says hello 👋.\nfunc Grüße() string {\n\treturn \"日本語\"\n}\n\ntype Größe struct {\n\t// Höhe in Metern\n\tHöhe float64\n}\n";
+    fs::write(dir.path().join("main.go"), source).unwrap();
+    let graph = index_dir(dir.path());
+    let text = |name: &str| {
+        let node = graph.nodes.iter().find(|n| n.name == name).unwrap();
+        let (span, name_span) = (node.span.unwrap(), node.name_span.unwrap());
+        (
+            &source[span.start.offset..span.end.offset],
+            &source[name_span.start.offset..name_span.end.offset],
+        )
+    };
+
+    assert_eq!(
+        text("Grüße"),
+        (
+            "// Grüße says hello 👋.\nfunc Grüße() string {\n\treturn \"日本語\"\n}",
+            "Grüße"
+        )
+    );
+    assert_eq!(
+        text("Größe"),
+        (
+            "type Größe struct {\n\t// Höhe in Metern\n\tHöhe float64\n}",
+            "Größe"
+        )
+    );
+    assert_eq!(
+        text("Größe.Höhe"),
+        ("// Höhe in Metern\n\tHöhe float64", "Höhe")
+    );
+
+    // Columns are bytes too: `Höhe` starts after a tab, then comes `float64`
+    let field = graph.nodes.iter().find(|n| n.name == "Größe.Höhe").unwrap();
+    let end = field.span.unwrap().end;
+    assert_eq!((end.line, end.column), (10, 15));
+}
+
+#[test]
+fn test_generic_calls_resolve_to_the_generic_declaration() {
+    let dir = fixture_dir("go-generics");
+    let graph = index_dir(&dir);
+    let id = |file: &str, name: &str, line: usize| node_id(&graph, &dir.join(file), name, line);
+
+    // Type parameter lists are part of the signature
+    let map = graph.resolve_symbol("Map").unwrap();
+    assert_eq!(
+        map.signature,
+        "func Map[T, U any](xs []T, f func(T) U) []U {"
+    );
+    let stack = graph.resolve_symbol("Stack").unwrap();
+    assert_eq!(stack.node_type, NodeType::Struct);
+    assert_eq!(stack.signature, "type Stack[T any] struct {");
+
+    // Methods on Stack[T] are grouped under Stack
+    let push = graph.resolve_symbol("(*Stack).Push").unwrap();
+    assert_eq!(push.metadata.get("receiver").unwrap(), "*Stack");
+    assert_eq!(push.metadata.get("receiver_type").unwrap(), "Stack");
+    let outline = graph.outline(&dir.join("stack.go"));
+    let members: Vec<&str> = outline[0]
+        .children
+        .iter()
+        .map(|entry| entry.node.name.as_str())
+        .collect();
+    assert_eq!(outline[0].node.name, "Stack");
+    assert_eq!(
+        members,
+        vec!["Stack.items", "(*Stack).Push", "(*Stack).Pop", "Stack.Len"]
+    );
+
+    // Explicit and inferred type arguments alike call the generic definition
+    let main = graph.resolve_symbol("main").unwrap();
+    let calls: Vec<(&str, &str)> = graph
+        .get_outgoing_edges(&main.id)
+        .into_iter()
+        .filter(|edge| edge.edge_type == EdgeType::Calls)
+        .filter_map(|edge| Some((edge.to.as_str(), edge.metadata.get("target_id")?.as_str())))
+        .collect();
+    let (map_id, sum_id) = (id("generics.go", "Map", 9), id("generics.go", "Sum", 18));
+    let push_id = id("stack.go", "(*Stack).Push", 14);
+    assert_eq!(
+        calls,
+        vec![
+            ("Map", map_id.as_str()),
+            ("Map", map_id.as_str()),
+            ("Sum", sum_id.as_str()),
+            ("Sum", sum_id.as_str()),
+            ("(*Stack).Push", push_id.as_str()),
+            ("(*Stack).Pop", id("stack.go", "(*Stack).Pop", 19).as_str()),
+            ("(*Stack).Push", push_id.as_str()),
+            ("Stack.Len", id("stack.go", "Stack.Len", 30).as_str()),
+            ("NewStack", id("stack.go", "NewStack", 9).as_str()),
+        ]
+    );
+
+    // A parameter typed *Stack[T] still resolves method calls, and functions passed to
+    // a generic function are reached through its parameter
+    let drain = callees_of(&graph, "Drain");
+    assert!(drain.contains(&"Stack.Len".to_string()), "{:?}", drain);
+    assert!(drain.contains(&"(*Stack).Pop".to_string()), "{:?}", drain);
+    assert!(callees_of(&graph, "Map").contains(&"double".to_string()));
+}
+
+#[test]
+fn test_promoted_methods_resolve_through_embedded_fields() {
+    let dir = fixture_dir("go-embedding");
+    let graph = index_dir(&dir);
+    let calls = |name: &str| -> Vec<(String, bool, Vec<String>)> {
+        let node = graph.resolve_symbol(name).unwrap();
+        graph
+            .get_outgoing_edges(&node.id)
+            .into_iter()
+            .filter(|edge| edge.edge_type == EdgeType::Calls)
+            .map(|edge| {
+                (
+                    edge.to.clone(),
+                    edge.metadata.contains_key("target_id"),
+                    edge.promoted_via(),
+                )
+            })
+            .collect()
+    };
+    let via = |fields: &[&str]| fields.iter().map(|f| f.to_string()).collect::<Vec<_>>();
+
+    // Flush is promoted from auditor and store at the same depth, so it has no target
+    assert_eq!(
+        calls("(*Calculator).Add"),
+        vec![
+            ("(*logger).Warn".to_string(), true, via(&["logger"])),
+            ("(*auditor).Record".to_string(), true, via(&["*auditor"])),
+            ("Flush".to_string(), false, via(&[])),
+        ]
+    );
+    // Two levels down, and a shallower Flush winning over the ambiguous deeper ones
+    assert_eq!(
+        calls("main"),
+        vec![
+            ("(*Calculator).Add".to_string(), true, via(&[])),
+            (
+                "logger.Info".to_string(),
+                true,
+                via(&["*Calculator", "logger"])
+            ),
+            ("journal.Flush".to_string(), true, via(&["journal"])),
+        ]
+    );
+
+    let ambiguous: Vec<String> = graph
+        .metadata
+        .diagnostics
+        .iter()
+        .filter(|d| d.code == "ambiguous-selector")
+        .map(|d| format!("{}:{}", d.line, d.message))
+        .collect();
+    assert_eq!(
+        ambiguous,
+        vec!["43:ambiguous selector Calculator.Flush: promoted from both *auditor and store"]
+    );
+}
+
+#[test]
+fn test_interface_calls_reach_every_implementation() {
+    let dir = fixture_dir("go-interfaces");
+    let mut graph = index_dir(&dir);
+    let calls = |graph: &CodeGraph| -> Vec<(String, Option<String>, bool)> {
+        let process = graph.resolve_symbol("Process").unwrap();
+        graph
+            .get_outgoing_edges(&process.id)
+            .into_iter()
+            .map(|edge| {
+                (
+                    edge.to.clone(),
+                    edge.metadata.get("target_id").cloned(),
+                    edge.is_dynamic(),
+                )
+            })
+            .collect()
+    };
+    let id = |name: &str, line: usize| node_id(&graph, &dir.join("main.go"), name, line);
+    let logger_log = id("Logger.Log", 10);
+
+    // l.Log() still points at the interface, and dynamically at both implementations;
+    // Silent.Log takes no message, so Silent is no Logger
+    assert_eq!(
+        calls(&graph),
+        vec![
+            ("Logger.Log".to_string(), Some(logger_log.clone()), false),
+            (
+                "ConsoleLogger.Log".to_string(),
+                Some(id("ConsoleLogger.Log", 16)),
+                true
+            ),
+            (
+                "(*FileLogger).Log".to_string(),
+                Some(id("(*FileLogger).Log", 25)),
+                true
+            ),
+        ]
+    );
+    let dynamic = graph
+        .get_outgoing_edges(&id("Process", 35))
+        .into_iter()
+        .find(|edge| edge.is_dynamic())
+        .unwrap();
+    assert_eq!(dynamic.metadata.get("via").unwrap(), "Logger.Log");
+    assert_eq!(dynamic.line, 37);
+
+    // Both implementations are reachable from main, the other Log is not
+    let callers: Vec<(String, bool)> = graph
+        .callers("(*FileLogger).Log")
+        .into_iter()
+        .map(|site| (site.caller, site.dynamic))
+        .collect();
+    assert_eq!(callers, vec![("Process".to_string(), true)]);
+    let dead: Vec<String> = graph
+        .dead_code(&DeadCodeOptions::default())
+        .unwrap()
+        .dead
+        .iter()
+        .map(|node| node.name.clone())
+        .collect();
+    assert_eq!(dead, vec!["Silent.Log"]);
+
+    graph.remove_dynamic_calls();
+    assert_eq!(
+        calls(&graph),
+        vec![("Logger.Log".to_string(), Some(logger_log), false)]
+    );
+}
+
+#[test]
+fn test_syntax_errors_are_reported_without_losing_healthy_files() {
+    let dir = fixture_dir("go-syntax-errors");
+    let graph = index_dir(&dir);
+
+    // The broken function is reported where its body goes wrong
+    let errors: Vec<_> = graph
+        .metadata
+        .diagnostics
+        .iter()
+        .filter(|d| d.code == "syntax-error")
+        .collect();
+    assert!(!errors.is_empty());
+    for error in &errors {
+        assert_eq!(error.file_path, dir.join("broken.go"));
+        assert!((4..=6).contains(&error.line), "{}", error);
+        assert!(error.column > 0);
+    }
+
+    // main.go parsed cleanly and resolves as usual
+    for name in ["main", "Healthy", "helper"] {
+        let node = graph.resolve_symbol(name).unwrap();
+        assert_eq!(node.file_path, dir.join("main.go"));
+    }
+    let healthy = graph.resolve_symbol("Healthy").unwrap();
+    let call = graph.get_outgoing_edges(&healthy.id)[0];
+    assert_eq!(
+        call.metadata.get("target_id"),
+        Some(&graph.resolve_symbol("helper").unwrap().id)
+    );
+
+    // Calls into the broken file are kept, resolved only where the declaration survived
+    let main = graph.resolve_symbol("main").unwrap();
+    let mut callees: Vec<&str> = graph
+        .get_outgoing_edges(&main.id)
+        .iter()
+        .map(|e| e.to.as_str())
+        .collect();
+    callees.sort();
+    assert_eq!(callees, ["After", "Broken", "Healthy"]);
+    for edge in graph.get_outgoing_edges(&main.id) {
+        if let Some(target) = edge.metadata.get("target_id") {
+            assert!(graph.get_node_by_id(target).is_some());
+        }
+    }
+    // The declaration after the broken one survives and is linked
+    let after = graph.resolve_symbol("After").unwrap();
+    assert_eq!(after.file_path, dir.join("broken.go"));
+    let call = graph
+        .get_outgoing_edges(&main.id)
+        .into_iter()
+        .find(|e| e.to == "After")
+        .unwrap();
+    assert_eq!(call.metadata.get("target_id"), Some(&after.id));
+}
+
+#[test]
+fn test_reindexed_file_replaces_its_diagnostics() {
+    let dir = tempfile::tempdir().unwrap();
+    let file = dir.path().join("main.go");
+    let broken = "package main\n\nfunc main() int {\n\treturn 1 +\n}\n";
+    fs::write(&file, broken).unwrap();
+    let mut graph = index_dir(dir.path());
+    let syntax_errors = |graph: &CodeGraph| {
+        graph
+            .metadata
+            .diagnostics
+            .iter()
+            .filter(|d| d.code == "syntax-error")
+            .count()
+    };
+    let before = syntax_errors(&graph);
+    assert!(before > 0);
+
+    let reindex = |graph: &mut CodeGraph| {
+        graph.remove_nodes_from_file(&file.to_string_lossy());
+        GoParser::new().unwrap().parse_file(&file, graph).unwrap();
+    };
+
+    // Still broken: reported once, not stacked on the earlier run's
+    reindex(&mut graph);
+    assert_eq!(syntax_errors(&graph), before);
+
+    // Fixed: the error goes away
+    fs::write(&file, "package main\n\nfunc main() int {\n\treturn 1\n}\n").unwrap();
+    reindex(&mut graph);
+    assert!(graph.metadata.diagnostics.is_empty());
+}
+
+#[test]
+fn test_function_metrics() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let metrics = graph.function_metrics(MetricsSort::FanIn);
+    let row = |name: &str| {
+        metrics
+            .iter()
+            .find(|m| m.node.name == name)
+            .unwrap_or_else(|| panic!("no metrics for {}", name))
+    };
+
+    // Multiply calls Add twice but counts once, and Apply's `op(2, 3)` doesn't name it
+    assert_eq!(row("Add").fan_in, 2);
+    assert_eq!(row("PrintMessage").fan_in, 2);
+    assert_eq!(row("(*Calculator).LogOperation").fan_in, 2);
+
+    // fmt.Printf counts towards fan-out but, not being indexed, not towards reachable;
+    // Greet's deferred literal does
+    let main = row("main");
+    assert_eq!(main.fan_in, 0);
+    assert_eq!(main.fan_out, 4);
+    assert_eq!(main.reachable, 5);
+    // The four statements between the braces
+    assert_eq!(main.lines, 4);
+    assert_eq!(row("Add").lines, 1);
+
+    // Recursion doesn't count a function as reaching itself
+    assert_eq!(row("Even").reachable, 1);
+    assert_eq!(row("Factorial").reachable, 0);
+    assert_eq!(row("Factorial").fan_in, 1);
+
+    // Largest first, ties in source order
+    let top: Vec<&str> = metrics
+        .iter()
+        .take(4)
+        .map(|m| m.node.name.as_str())
+        .collect();
+    assert_eq!(
+        top,
+        vec![
+            "(*Calculator).LogOperation",
+            "Add",
+            "PrintMessage",
+            "(*logger).Warn"
+        ]
+    );
+
+    let by_lines = graph.function_metrics(MetricsSort::Lines);
+    assert_eq!(by_lines[0].node.name, "Multiply");
+    assert!(by_lines.windows(2).all(|w| w[0].lines >= w[1].lines));
+
+    assert_eq!(
+        "fan-out".parse::<MetricsSort>().unwrap(),
+        MetricsSort::FanOut
+    );
+    assert!("calls".parse::<MetricsSort>().is_err());
+}
+
+#[test]
+fn test_cyclomatic_complexity() {
+    let graph = index_dir(&fixture_dir("go-complexity"));
+    let complexity = |name: &str| graph.resolve_symbol(name).unwrap().complexity();
+
+    assert_eq!(complexity("Simple"), Some(1));
+    // Four cases and an &&
+    assert_eq!(complexity("Classify"), Some(6));
+    // The closure's if, for, if and || are its own, not Retry's
+    assert_eq!(complexity("Retry"), Some(3));
+    assert_eq!(complexity("Retry.func1"), Some(5));
+    // A range, two type cases and a select case
+    assert_eq!(complexity("Kind"), Some(5));
+
+    let over: Vec<(&str, Option<usize>)> = graph
+        .complex_functions(4)
+        .into_iter()
+        .map(|node| (node.name.as_str(), node.complexity()))
+        .collect();
+    assert_eq!(
+        over,
+        vec![
+            ("Classify", Some(6)),
+            ("Retry.func1", Some(5)),
+            ("Kind", Some(5)),
+        ]
+    );
+    assert!(graph.complex_functions(6).is_empty());
+}
+
+#[test]
+fn test_changes_between_snapshots() {
+    let old_dir = tempfile::tempdir().unwrap();
+    let new_dir = tempfile::tempdir().unwrap();
+    fs::write(
+        old_dir.path().join("calc.go"),
+        r#"package calc
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func Sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total = Add(total, v)
+	}
+	return total
+}
+
+func Twice(n int) int {
+	return Add(n, n)
+}
+
+func Unused() {}
+"#,
+    )
+    .unwrap();
+    // Add takes a slice, Sum is now Total, Twice and Unused are gone and Report is new
+    fs::write(
+        new_dir.path().join("calc.go"),
+        r#"package calc
+
+import "fmt"
+
+func Add(values ...int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+func Total(values []int) int {
+	total := 0
+	for _, v := range values {
+		total = Add(total, v)
+	}
+	return total
+}
+
+func Report(values []int) {
+	fmt.Println(Total(values))
+}
+"#,
+    )
+    .unwrap();
+    let old = index_dir(old_dir.path());
+    let new = index_dir(new_dir.path());
+
+    let changes = old.changes(&new, Some(1));
+    assert_eq!(changes.files.len(), 1);
+    let file = &changes.files[0];
+    assert_eq!(file.file, "calc.go");
+
+    let names = |nodes: &[&code_navigator::core::Node]| -> Vec<String> {
+        nodes.iter().map(|node| node.name.clone()).collect()
+    };
+    assert_eq!(names(&file.added), vec!["Report"]);
+    assert_eq!(names(&file.removed), vec!["Twice", "Unused"]);
+
+    let renamed: Vec<(&str, &str)> = file
+        .renamed
+        .iter()
+        .map(|change| (change.old.name.as_str(), change.new.name.as_str()))
+        .collect();
+    assert_eq!(renamed, vec![("Sum", "Total")]);
+
+    assert_eq!(file.changed.len(), 1);
+    assert_eq!(file.changed[0].old.declaration(), "func Add(a, b int) int");
+    assert_eq!(
+        file.changed[0].new.declaration(),
+        "func Add(values ...int) int"
+    );
+    assert_eq!(file.complexity.len(), 1);
+    assert_eq!(file.complexity[0].new.name, "Add");
+
+    // Total's call of Add is Sum's, renamed; only Twice's call went away
+    let calls = |calls: &[code_navigator::core::CallChange]| -> Vec<(String, String)> {
+        calls
+            .iter()
+            .map(|call| (call.caller.name.clone(), call.callee.clone()))
+            .collect()
+    };
+    assert_eq!(
+        calls(&file.removed_calls),
+        vec![("Twice".to_string(), "Add".to_string())]
+    );
+    assert_eq!(
+        calls(&file.added_calls),
+        vec![
+            ("Report".to_string(), "Total".to_string()),
+            ("Report".to_string(), "fmt.Println".to_string()),
+        ]
+    );
+
+    assert!(old.changes(&index_dir(old_dir.path()), Some(1)).is_empty());
+    // Without a threshold, complexity alone is no change
+    assert!(old.changes(&new, None).files[0].complexity.is_empty());
+}
+
+#[test]
+fn test_source_of_symbol() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+
+    let add = graph.source("(*Calculator).Add", 0).unwrap();
+    assert_eq!(
+        add.text,
+        "// Add adds two numbers (method)\nfunc (c *Calculator) Add(a, b int) int {\n\tresult := a + b\n\tc.LogOperation(\"Add\", result)\n\treturn result\n}"
+    );
+    assert_eq!(add.start_line, 16);
+    assert_eq!((add.range.start.line, add.range.end.line), (16, 21));
+    assert_eq!(add.with_context(), add.text);
+
+    // One blank line on either side
+    let padded = graph.source("(*Calculator).Add", 1).unwrap();
+    assert_eq!(padded.start_line, 15);
+    assert_eq!(padded.before, "\n");
+    assert_eq!(padded.after, "\n");
+    assert_eq!(padded.text, add.text);
+
+    let ambiguous = graph.source("LogOperation", 0).unwrap_err().to_string();
+    assert!(
+        ambiguous.starts_with("Ambiguous symbol LogOperation"),
+        "{}",
+        ambiguous
+    );
+    assert!(
+        ambiguous.contains("(*Recorder).LogOperation"),
+        "{}",
+        ambiguous
+    );
+}
+
+#[test]
+fn test_source_of_edited_file_is_refused() {
+    let dir = tempfile::tempdir().unwrap();
+    let file = dir.path().join("calculator.go");
+    fs::copy(fixture_dir("simple-go").join("calculator.go"), &file).unwrap();
+    let graph = index_dir(dir.path());
+    assert!(graph.source("NewCalculator", 0).is_ok());
+
+    let source = fs::read_to_string(&file).unwrap();
+    fs::write(&file, format!("// Package main\n{}", source)).unwrap();
+    let error = graph.source("NewCalculator", 0).unwrap_err().to_string();
+    assert!(
+        error.contains("has changed since NewCalculator was indexed"),
+        "{}",
+        error
+    );
+
+    // Re-indexing records the new range
+    let graph = index_dir(dir.path());
+    assert_eq!(graph.source("NewCalculator", 0).unwrap().start_line, 12);
+
+    // An edit inside a body moves no name, but is refused all the same
+    let source = fs::read_to_string(&file).unwrap();
+    fs::write(&file, source.replace("result := a + b", "result := b + a")).unwrap();
+    for symbol in ["(*Calculator).Add", "NewCalculator"] {
+        let error = graph.source(symbol, 0).unwrap_err().to_string();
+        assert!(error.contains("has changed since"), "{}", error);
+    }
+}
+
+#[test]
+fn test_symbol_filter_query() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let select = |query: &str| -> Vec<String> {
+        let filter: SymbolFilter = query.parse().unwrap();
+        let mut names: Vec<String> = graph
+            .nodes
+            .iter()
+            .filter(|node| filter.matches(node))
+            .map(|node| node.name.clone())
+            .collect();
+        names.sort();
+        names
+    };
+
+    // Both receiver kinds, and not Logger's or Recorder's methods
+    assert_eq!(
+        select("kind:method receiver:Calculator"),
+        vec![
+            "(*Calculator).Add",
+            "(*Calculator).LogOperation",
+            "(*Calculator).SetName",
+            "(*Calculator).Subtract",
+            "Calculator.Name",
+        ]
+    );
+    assert_eq!(select("receiver:*Calculator").len(), 4);
+    assert_eq!(
+        select("kind:method receiver:Calculator name:~Operation"),
+        vec!["(*Calculator).LogOperation"]
+    );
+    assert_eq!(select("name:Add"), vec!["(*Calculator).Add", "Add"]);
+
+    assert_eq!(
+        select("kind:function exported:false"),
+        vec!["Greet.func1", "main"]
+    );
+    assert_eq!(
+        select("kind:function exported:true file:calculator.go"),
+        vec!["NewCalculator"]
+    );
+    assert_eq!(select("").len(), graph.nodes.len());
+
+    let err = "kind:method owner:Calculator"
+        .parse::<SymbolFilter>()
+        .unwrap_err();
+    assert_eq!(
+        err.to_string(),
+        "Unknown filter key \"owner\" in \"owner:Calculator\"; expected one of kind, name, receiver, exported, file, package, root"
+    );
+
+    // Search applies the same filter
+    let options = SearchOptions {
+        filter: "receiver:Calculator".parse().unwrap(),
+        ..Default::default()
+    };
+    let found: Vec<String> = graph
+        .search("log", &options)
+        .iter()
+        .map(|m| m.node.name.clone())
+        .collect();
+    assert_eq!(found, vec!["(*Calculator).LogOperation"]);
+}
+
+/// Every page of `size`, fetched by following `next_offset` from 0
+fn stitch<T>(size: usize, fetch: impl Fn(&PageRequest) -> Page<T>) -> (Vec<T>, usize) {
+    let mut items = Vec::new();
+    let mut pages = 0;
+    let mut offset = Some(0);
+    while let Some(start) = offset {
+        let page = fetch(&PageRequest::new(start, Some(size)));
+        assert!(page.items.len() <= size);
+        assert_eq!(page.offset, start);
+        offset = page.next_offset();
+        assert_eq!(page.has_more(), offset.is_some());
+        items.extend(page.items);
+        pages += 1;
+    }
+    (items, pages)
+}
+
+#[test]
+fn test_paged_results_stitch_back_together() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let none = SymbolFilter::default();
+    let site = |s: &CallSite| {
+        (
+            s.caller.clone(),
+            s.file_path.clone(),
+            s.line,
+            s.column,
+            s.depth,
+        )
+    };
+
+    let all = graph.references_page("Add", &[], &none, &PageRequest::default());
+    assert!(all.total >= 5, "{}", all.total);
+    assert!(!all.has_more());
+    let (stitched, pages) = stitch(2, |page| graph.references_page("Add", &[], &none, page));
+    assert_eq!(pages, all.total.div_ceil(2));
+    assert_eq!(
+        stitched.iter().map(site).collect::<Vec<_>>(),
+        all.items.iter().map(site).collect::<Vec<_>>()
+    );
+    // Sorted by file, then position
+    let positions: Vec<_> = all
+        .items
+        .iter()
+        .map(|s| (s.file_path.clone(), s.line, s.column))
+        .collect();
+    let mut sorted = positions.clone();
+    sorted.sort();
+    assert_eq!(positions, sorted);
+
+    // Kinds are filtered before paging, so the total counts only them
+    let calls = graph.references_page(
+        "Add",
+        &[ReferenceKind::Call],
+        &none,
+        &PageRequest::new(0, Some(1)),
+    );
+    assert_eq!(calls.items.len(), 1);
+    assert_eq!(
+        calls.total,
+        all.items
+            .iter()
+            .filter(|s| s.kind == ReferenceKind::Call)
+            .count()
+    );
+
+    let all = graph.callers_page("PrintMessage", 5, &none, &PageRequest::default());
+    assert!(all.total > 3);
+    let (stitched, _) = stitch(3, |page| graph.callers_page("PrintMessage", 5, &none, page));
+    assert_eq!(
+        stitched.iter().map(site).collect::<Vec<_>>(),
+        all.items.iter().map(site).collect::<Vec<_>>()
+    );
+    let depths: Vec<usize> = all.items.iter().map(|s| s.depth).collect();
+    assert!(depths.windows(2).all(|pair| pair[0] <= pair[1]));
+
+    let options = SearchOptions::default();
+    let all = graph.search_page("a", &options, &PageRequest::default());
+    assert!(all.total > 4);
+    let (stitched, _) = stitch(4, |page| graph.search_page("a", &options, page));
+    let ids = |matches: &[code_navigator::core::SearchMatch]| -> Vec<String> {
+        matches.iter().map(|m| m.node.id.clone()).collect()
+    };
+    assert_eq!(ids(&stitched), ids(&all.items));
+
+    // Past the end is an empty last page, not an error
+    let past = graph.references_page("Add", &[], &none, &PageRequest::new(100, Some(2)));
+    assert!(past.items.is_empty() && !past.has_more());
+}
+
+#[test]
+fn test_transitive_callees() {
+    let graph = index_dir(&fixture_dir("simple-go"));
+    let callees =
+        |symbol: &str, max_depth: usize, prune: Vec<Prune>| -> Vec<(String, usize, String)> {
+            let options = CalleeOptions { max_depth, prune };
+            graph
+                .callees(symbol, &options)
+                .unwrap()
+                .reached
+                .iter()
+                .map(|call| (call.hop.callee_name(), call.depth, call.caller.name.clone()))
+                .collect()
+        };
+    let entry = |name: &str, depth: usize, via: &str| (name.to_string(), depth, via.to_string());
+
+    assert_eq!(
+        callees("main", 1, vec![Prune::External]),
+        vec![
+            entry("Add", 1, "main"),
+            entry("Multiply", 1, "main"),
+            entry("Greet", 1, "main"),
+        ]
+    );
+    // Add is reached again through Multiply, but keeps its least depth
+    assert_eq!(
+        callees("main", 2, vec![Prune::External]),
+        vec![
+            entry("Add", 1, "main"),
+            entry("Multiply", 1, "main"),
+            entry("Greet", 1, "main"),
+            entry("Greet.func1", 2, "Greet"),
+        ]
+    );
+    assert_eq!(
+        callees("main", 4, vec![]),
+        vec![
+            entry("Add", 1, "main"),
+            entry("Multiply", 1, "main"),
+            entry("fmt.Printf", 1, "main"),
+            entry("Greet", 1, "main"),
+            entry("fmt.Sprintf", 2, "Greet"),
+            entry("Greet.func1", 2, "Greet"),
+            entry("PrintMessage", 3, "Greet.func1"),
+            entry("fmt.Println", 4, "PrintMessage"),
+        ]
+    );
+
+    // Every call on the way is kept for drawing the subgraph
+    let closure = graph
+        .callees(
+            "main",
+            &CalleeOptions {
+                max_depth: 2,
+                prune: vec![Prune::External],
+            },
+        )
+        .unwrap();
+    let calls: Vec<(&str, String, usize)> = closure
+        .calls
+        .iter()
+        .map(|call| {
+            (
+                call.caller.name.as_str(),
+                call.hop.callee_name(),
+                call.depth,
+            )
+        })
+        .collect();
+    assert!(calls.contains(&("Multiply", "Add".to_string(), 2)));
+    assert!(calls.contains(&("Greet", "Greet.func1".to_string(), 2)));
+
+    // Cycles end once every function has been expanded; the root is never reported
+    assert_eq!(callees("Even", 10, vec![]), vec![entry("Odd", 1, "Even")]);
+    assert!(callees("Factorial", 10, vec![]).is_empty());
+    let cycle = graph
+        .callees(
+            "Even",
+            &CalleeOptions {
+                max_depth: 10,
+                prune: vec![],
+            },
+        )
+        .unwrap();
+    assert_eq!(cycle.calls.len(), 2);
+
+    assert!("nearby".parse::<Prune>().is_err());
+}
+
+#[test]
+fn test_exported_only_collapses_hidden_calls() {
+    let mut graph = index_dir(&fixture_dir("go-visibility"));
+    // Capitalized, but on an unexported type
+    for name in ["(*counter).Add", "counter.Total"] {
+        assert!(
+            !graph.resolve_symbol(name).unwrap().is_exported(),
+            "{}",
+            name
+        );
+    }
+
+    graph.hide_unexported();
+    let mut names: Vec<&str> = graph.nodes.iter().map(|n| n.name.as_str()).collect();
+    names.sort();
+    assert_eq!(
+        names,
+        vec!["(*Counter).Inc", "Counter", "Greet", "PrintMessage"]
+    );
+
+    let calls = |symbol: &str| -> Vec<(String, Vec<String>, usize)> {
+        let node = graph.resolve_symbol(symbol).unwrap();
+        let mut calls: Vec<_> = graph
+            .get_outgoing_edges(&node.id)
+            .into_iter()
+            .map(|edge| (edge.to.clone(), edge.hidden_via(), edge.line))
+            .collect();
+        calls.sort();
+        calls
+    };
+    let hidden = |names: &[&str]| names.iter().map(|n| n.to_string()).collect::<Vec<_>>();
+    // Greet → helper → format → PrintMessage keeps Greet → PrintMessage, made where
+    // Greet calls helper
+    assert_eq!(
+        calls("Greet"),
+        vec![
+            ("PrintMessage".to_string(), hidden(&["helper", "format"]), 7),
+            ("Println".to_string(), hidden(&["helper", "format"]), 7),
+        ]
+    );
+    assert_eq!(
+        calls("(*Counter).Inc"),
+        vec![("PrintMessage".to_string(), hidden(&["(*Counter).bump"]), 38)]
+    );
+    assert_eq!(
+        calls("PrintMessage"),
+        vec![("Println".to_string(), vec![], 21)]
+    );
+
+    let greet = graph.resolve_symbol("Greet").unwrap();
+    let print = graph.resolve_symbol("PrintMessage").unwrap();
+    assert!(graph
+        .trace_dependencies(&greet.id, 1)
+        .iter()
+        .any(|t| t.to_id.as_ref() == Some(&print.id) && t.hidden_via.len() == 2));
+    assert!(graph.resolve_symbol("helper").is_err());
+}
+
+#[test]
+fn test_stable_ids_survive_reformatting() {
+    let dir = tempfile::tempdir().unwrap();
+    let file = dir.path().join("calc.go");
+    let index = |source: &str| {
+        fs::write(&file, source).unwrap();
+        index_dir(dir.path())
+    };
+    let ids = |graph: &CodeGraph| -> Vec<(String, String)> {
+        let mut ids: Vec<_> = graph
+            .nodes
+            .iter()
+            .map(|n| (n.name.clone(), n.id.clone()))
+            .collect();
+        ids.sort();
+        ids
+    };
+
+    let before = index(
+        "package calc
+
+// Add adds
+func Add(a, b int) int {
+	return a + b
+}
+
+type Calculator struct{ total int }
+
+func (c *Calculator) Add(n int) {
+	c.total = Add(c.total, n)
+}
+
+func init() {}
+
+func init() {}
+",
+    );
+    let function = before.resolve_symbol("Add").unwrap();
+    let method = before.resolve_symbol("(*Calculator).Add").unwrap();
+    let hash = |id: &str, prefix: &str| {
+        let hash = id.strip_prefix(prefix).unwrap_or_else(|| panic!("{}", id));
+        assert!(
+            hash.len() == 8 && hash.chars().all(|c| c.is_ascii_hexdigit()),
+            "{}",
+            id
+        );
+    };
+    hash(&function.id, "calc.Add#");
+    hash(&method.id, "calc.(*Calculator).Add#");
+    // The second init is told apart by its position
+    let inits: Vec<&str> = before
+        .lookup("init")
+        .iter()
+        .map(|n| n.id.as_str())
+        .collect();
+    assert_eq!(inits.len(), 2);
+    assert_eq!(inits[1], format!("{}~2", inits[0]));
+
+    // Calls point at the stable ID, and IDs work wherever names do
+    let call = before
+        .get_outgoing_edges(&method.id)
+        .into_iter()
+        .find(|edge| edge.to == "Add")
+        .unwrap();
+    assert_eq!(call.metadata.get("target_id"), Some(&function.id));
+    assert_eq!(
+        before.resolve(&method.id).unwrap().name,
+        "(*Calculator).Add"
+    );
+    assert_eq!(before.resolve_symbol(&function.id).unwrap().line, 4);
+    assert_eq!(before.callers(&function.id).len(), 1);
+
+    // Moved down and re-spaced: every ID is unchanged
+    let after = index(
+        "package calc
+
+
+
+// Add adds
+func Add(a,   b int)   int {
+		return a + b
+}
+
+type   Calculator struct {
+	total int
+}
+
+func (c  *Calculator) Add(n int) {
+
+	c.total = Add(c.total, n)
+}
+
+func init() {  }
+func init() {}
+",
+    );
+    assert_eq!(ids(&after), ids(&before));
+    assert!(before.changes(&after, None).is_empty());
+
+    // A new signature is a new ID; the method's stays
+    let changed = index(
+        "package calc
+
+// Add adds
+func Add(a, b, c int) int {
+	return a + b + c
+}
+
+type Calculator struct{ total int }
+
+func (c *Calculator) Add(n int) {
+	c.total = Add(c.total, n, 0)
+}
+
+func init() {}
+
+func init() {}
+",
+    );
+    assert_ne!(changed.resolve_symbol("Add").unwrap().id, function.id);
+    assert_eq!(
+        changed.resolve_symbol("(*Calculator).Add").unwrap().id,
+        method.id
+    );
+}
+
+#[test]
+fn test_adding_a_file_renumbers_only_its_package() {
+    let dir = tempfile::tempdir().unwrap();
+    for (file, source) in [
+        ("a/x.go", "package a\n\nfunc init() {}\n"),
+        ("b/y.go", "package b\n\nfunc init() {}\n\nfunc init() {}\n"),
+        ("a/z.go", "package a\n\nfunc init() {}\n"),
+    ] {
+        fs::create_dir_all(dir.path().join(file).parent().unwrap()).unwrap();
+        fs::write(dir.path().join(file), source).unwrap();
+    }
+
+    let mut graph = CodeGraph::new(dir.path().to_string_lossy().to_string(), "go".to_string());
+    let mut parser = GoParser::new().unwrap();
+    parser
+        .parse_file(&dir.path().join("a/x.go"), &mut graph)
+        .unwrap();
+    parser
+        .parse_file(&dir.path().join("b/y.go"), &mut graph)
+        .unwrap();
+    let b_before: Vec<String> = graph.lookup("init")[1..]
+        .iter()
+        .map(|n| n.id.clone())
+        .collect();
+
+    // The second init of package a takes the next number there, whatever b holds
+    parser
+        .parse_file(&dir.path().join("a/z.go"), &mut graph)
+        .unwrap();
+    let inits: Vec<&str> = graph.lookup("init").iter().map(|n| n.id.as_str()).collect();
+    assert_eq!(inits.len(), 4);
+    assert!(inits[0].starts_with("a.init#"), "{}", inits[0]);
+    assert_eq!(inits[1], format!("{}~2", inits[0]));
+    assert_eq!(inits[2..], b_before);
+    assert_eq!(b_before[1], format!("{}~2", b_before[0]));
+}
+
+#[test]
+fn test_type_hierarchy_follows_embedding() {
+    let dir = fixture_dir("go-hierarchy");
+    let graph = index_dir(&dir);
+    let hierarchy = |name: &str| graph.hierarchy(graph.resolve_type(name).unwrap());
+    // Each entry as (depth, name, pointer, external, cycle), depth first
+    fn flatten(
+        entry: &HierarchyEntry,
+        depth: usize,
+        out: &mut Vec<(usize, String, bool, bool, bool)>,
+    ) {
+        out.push((
+            depth,
+            entry.name.clone(),
+            entry.pointer,
+            entry.is_external(),
+            entry.cycle,
+        ));
+        for embedded in &entry.embeds {
+            flatten(embedded, depth + 1, out);
+        }
+    }
+    let tree = |name: &str| {
+        let mut out = Vec::new();
+        flatten(&hierarchy(name).root, 0, &mut out);
+        out
+    };
+    let entry = |depth, name: &str, pointer, external, cycle| {
+        (depth, name.to_string(), pointer, external, cycle)
+    };
+
+    // Two levels of embedding, with the mutex a leaf from outside the index
+    assert_eq!(
+        tree("Square"),
+        vec![
+            entry(0, "Square", false, false, false),
+            entry(1, "Shape", true, false, false),
+            entry(2, "Base", false, false, false),
+            entry(2, "sync.Mutex", false, true, false),
+        ]
+    );
+    let square = hierarchy("Square");
+    let base = &square.root.embeds[0].embeds[0];
+    assert_eq!(
+        (
+            base.node.unwrap().file_path.clone(),
+            base.node.unwrap().line
+        ),
+        (dir.join("main.go"), 25)
+    );
+    let satisfied: Vec<_> = square
+        .implementations
+        .iter()
+        .map(|i| (i.interface.name.as_str(), i.pointer))
+        .collect();
+    assert_eq!(satisfied, [("Namer", false), ("Describer", false)]);
+
+    // Only *Shape has Describe
+    let satisfied: Vec<_> = hierarchy("Shape")
+        .implementations
+        .iter()
+        .map(|i| (i.interface.name.clone(), i.pointer))
+        .collect();
+    assert_eq!(
+        satisfied,
+        [
+            ("Namer".to_string(), false),
+            ("Describer".to_string(), true)
+        ]
+    );
+
+    // Pointer embedding back to a type on the branch stops there
+    assert_eq!(
+        tree("Vertex"),
+        vec![
+            entry(0, "Vertex", false, false, false),
+            entry(1, "Edge", true, false, false),
+            entry(2, "Vertex", true, false, true),
+        ]
+    );
+
+    // An interface lists the interfaces it embeds and the types implementing it
+    assert_eq!(
+        tree("Describer"),
+        vec![
+            entry(0, "Describer", false, false, false),
+            entry(1, "Namer", false, false, false),
+        ]
+    );
+    let implementers: Vec<_> = hierarchy("Describer")
+        .implementations
+        .iter()
+        .map(|i| (i.implementer.name.clone(), i.pointer))
+        .collect();
+    assert_eq!(
+        implementers,
+        [("Shape".to_string(), true), ("Square".to_string(), false)]
+    );
+    assert_eq!(
+        tree("Printer"),
+        vec![
+            entry(0, "Printer", false, false, false),
+            entry(1, "fmt.Stringer", false, true, false),
+        ]
+    );
+}
+
+#[test]
+fn test_method_implementations_follow_pointer_receivers_and_embedding() {
+    let dir = fixture_dir("go-hierarchy");
+    let graph = index_dir(&dir);
+    // Each match as (found method, implementer, pointer, via)
+    let matches = |symbol: &str, found: fn(&MethodImplementation) -> String| {
+        let method = graph.resolve_method(symbol).unwrap();
+        graph
+            .method_implementations(method)
+            .iter()
+            .map(|m| {
+                (
+                    found(m),
+                    m.implementer.name.clone(),
+                    m.pointer,
+                    m.via.join("."),
+                )
+            })
+            .collect::<Vec<_>>()
+    };
+    let row = |found: &str, implementer: &str, pointer, via: &str| {
+        (
+            found.to_string(),
+            implementer.to_string(),
+            pointer,
+            via.to_string(),
+        )
+    };
+
+    // Only *Shape has Describe, while Square embeds *Shape and has it as a value
+    assert_eq!(
+        matches("Describer.Describe", |m| m.method.name.clone()),
+        vec![
+            row("(*Shape).Describe", "Shape", true, ""),
+            row("(*Shape).Describe", "Square", false, "*Shape"),
+        ]
+    );
+    assert_eq!(
+        matches("Namer.Name", |m| m.method.name.clone()),
+        vec![
+            row("Base.Name", "Base", false, ""),
+            row("Base.Name", "Shape", false, "Base"),
+            row("Base.Name", "Square", false, "*Shape.Base"),
+        ]
+    );
+
+    // From the concrete side, Describer requires Name through the Namer it embeds
+    let satisfied = |symbol: &str| {
+        let method = graph.resolve_method(symbol).unwrap();
+        graph
+            .method_implementations(method)
+            .iter()
+            .map(|m| {
+                (
+                    m.requirement.name.clone(),
+                    m.interface.name.clone(),
+                    m.implementer.name.clone(),
+                )
+            })
+            .collect::<Vec<_>>()
+    };
+    assert_eq!(
+        satisfied("Base.Name"),
+        [
+            ("Namer.Name", "Namer", "Base"),
+            ("Namer.Name", "Namer", "Shape"),
+            ("Namer.Name", "Namer", "Square"),
+            ("Namer.Name", "Describer", "Shape"),
+            ("Namer.Name", "Describer", "Square"),
+        ]
+        .map(|(a, b, c)| (a.to_string(), b.to_string(), c.to_string()))
+    );
+    assert_eq!(
+        satisfied("(*Shape).Describe"),
+        [
+            ("Describer.Describe", "Describer", "Shape"),
+            ("Describer.Describe", "Describer", "Square"),
+        ]
+        .map(|(a, b, c)| (a.to_string(), b.to_string(), c.to_string()))
+    );
+
+    // A promoted method isn't declared on the embedding type, so it is no starting point
+    assert!(graph.resolve_method("Square.Name").is_err());
+}
+
+#[test]
+fn test_unresolved_calls_are_grouped_by_reason() {
+    let dir = fixture_dir("simple-go");
+    let graph = index_dir(&dir);
+    let buckets = graph.unresolved();
+
+    // Only the calls into fmt are left, the ones index_stats counts
+    assert_eq!(buckets.len(), 1);
+    assert_eq!(buckets[0].reason, UnresolvedReason::ExternalPackage);
+    assert_eq!(buckets[0].count(), graph.index_stats().unresolved_calls);
+    let names: Vec<(&str, usize)> = buckets[0]
+        .names
+        .iter()
+        .map(|name| (name.name.as_str(), name.uses.len()))
+        .collect();
+    assert_eq!(
+        names,
+        [("fmt.Sprintf", 2), ("fmt.Printf", 1), ("fmt.Println", 1)]
+    );
+    let sprintf: Vec<_> = buckets[0].names[0]
+        .uses
+        .iter()
+        .map(|edge| (edge.file_path.clone(), edge.line))
+        .collect();
+    assert_eq!(
+        sprintf,
+        [(dir.join("calculator.go"), 32), (dir.join("main.go"), 21)]
+    );
+}
+
+#[test]
+fn test_unresolved_calls_to_build_excluded_files_and_builtins() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("main.go"),
+        "package main\n\nfunc main() {\n\tHelper()\n\t_ = len(\"abc\")\n\tMissing()\n}\n",
+    )
+    .unwrap();
+    fs::write(
+        dir.path().join("helper.go"),
+        "//go:build ignore\n\npackage main\n\nfunc Helper() {}\n",
+    )
+    .unwrap();
+    let graph = index_dir(dir.path());
+
+    assert!(graph.get_nodes_by_name("Helper").is_empty());
+    let unresolved = graph.unresolved();
+    let buckets: Vec<(UnresolvedReason, Vec<&str>)> = unresolved
+        .iter()
+        .map(|bucket| {
+            (
+                bucket.reason,
+                bucket.names.iter().map(|n| n.name.as_str()).collect(),
+            )
+        })
+        .collect();
+    assert_eq!(
+        buckets,
+        [
+            (UnresolvedReason::BuildExcluded, vec!["Helper"]),
+            (UnresolvedReason::Builtin, vec!["len"]),
+            (UnresolvedReason::Undefined, vec!["Missing"]),
+        ]
+    );
+}
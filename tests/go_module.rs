@@ -0,0 +1,772 @@
+use code_navigator::core::{
+    root_label, CalleeOptions, CodeGraph, DeadCodeOptions, Edge, EntryKind, Import, ImportKind,
+    NodeType, PageRequest, PathOptions, Prune, SymbolFilter, ENTRY_POINTS,
+};
+use code_navigator::parser::{go_module, BuildContext, Discovery, GoModule, GoParser};
+use code_navigator::serializer::dot;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+fn fixture_dir(name: &str) -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join(name)
+}
+
+fn index_with(parser: GoParser, dir: &Path) -> CodeGraph {
+    let mut parser = parser;
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    parser.parse_directory(dir, &mut graph).unwrap();
+    graph
+}
+
+fn index_module() -> CodeGraph {
+    index_with(GoParser::new().unwrap(), &fixture_dir("go-module"))
+}
+
+fn import_path(graph: &CodeGraph, symbol: &str) -> String {
+    graph.find_nodes_by_symbol(symbol)[0]
+        .metadata
+        .get("import_path")
+        .cloned()
+        .unwrap_or_default()
+}
+
+#[test]
+fn test_module_path_is_read_from_go_mod() {
+    let module = GoModule::find(&fixture_dir("go-module").join("calc")).unwrap();
+    assert_eq!(module.path, "example.com/mymod");
+    assert_eq!(
+        module.import_path(&fixture_dir("go-module").join("calc")),
+        Some("example.com/mymod/calc".to_string())
+    );
+}
+
+#[test]
+fn test_symbols_record_import_paths() {
+    let graph = index_module();
+
+    let mut adds: Vec<(String, String)> = graph
+        .get_nodes_by_name("Add")
+        .iter()
+        .map(|n| (n.qualified_name(), n.package.clone()))
+        .collect();
+    adds.sort();
+    assert_eq!(
+        adds,
+        vec![
+            ("example.com/mymod.Add".to_string(), "main".to_string()),
+            ("example.com/mymod/calc.Add".to_string(), "calc".to_string()),
+        ]
+    );
+    assert_eq!(import_path(&graph, "Twice"), "example.com/mymod/calc");
+    assert_eq!(import_path(&graph, "main"), "example.com/mymod");
+}
+
+#[test]
+fn test_qualified_names_disambiguate() {
+    let graph = index_module();
+
+    let calc_add = graph.find_nodes_by_symbol("example.com/mymod/calc.Add");
+    assert_eq!(calc_add.len(), 1);
+    assert!(calc_add[0].file_path.ends_with("calc/calc.go"));
+
+    let err = graph.resolve_symbol("Add").unwrap_err().to_string();
+    assert!(err.contains("Ambiguous symbol Add"), "{}", err);
+    assert!(err.contains("example.com/mymod/calc.Add"), "{}", err);
+
+    let main_add = graph.resolve_symbol("example.com/mymod.Add").unwrap();
+    assert!(main_add.file_path.ends_with("go-module/main.go"));
+    assert!(graph.resolve_symbol("example.com/other.Add").is_err());
+
+    // Each package's call to Add binds to its own definition
+    let twice = graph.resolve_symbol("Twice").unwrap();
+    let edge = graph
+        .get_outgoing_edges(&twice.id)
+        .into_iter()
+        .find(|e| e.to == "Add")
+        .unwrap();
+    assert_eq!(edge.metadata.get("target_id"), Some(&calc_add[0].id));
+}
+
+#[test]
+fn test_discovery_skips_what_go_build_skips() {
+    let graph = index_module();
+
+    // The `//go:build ignore` copy of calc.Add is neither indexed nor a duplicate
+    assert_eq!(
+        graph
+            .find_nodes_by_symbol("example.com/mymod/calc.Add")
+            .len(),
+        1
+    );
+    assert!(graph.metadata.diagnostics.is_empty());
+
+    for skipped in ["Vendored", "FromTestdata", "Tool", "Plan9Only"] {
+        assert!(
+            graph.get_nodes_by_name(skipped).is_empty(),
+            "{} should not be indexed",
+            skipped
+        );
+    }
+    assert_eq!(graph.metadata.stats.files_parsed, 2);
+}
+
+#[test]
+fn test_vendor_and_build_context_are_configurable() {
+    let parser = GoParser::new()
+        .unwrap()
+        .with_vendor(true)
+        .with_build_context(BuildContext::new("plan9", "amd64"));
+    let graph = index_with(parser, &fixture_dir("go-module"));
+
+    assert_eq!(graph.get_nodes_by_name("Vendored").len(), 1);
+    assert_eq!(graph.get_nodes_by_name("Plan9Only").len(), 1);
+    assert!(graph.get_nodes_by_name("FromTestdata").is_empty());
+    assert_eq!(import_path(&graph, "Vendored"), "example.org/dep");
+}
+
+#[test]
+fn test_changed_files_are_filtered_like_a_full_index() {
+    let dir = fixture_dir("go-module");
+    let parse_changed = |parser: &mut GoParser, file: &str, graph: &mut CodeGraph| {
+        parser
+            .parse_changed_file(&dir, &dir.join(file), graph)
+            .unwrap()
+    };
+
+    let mut parser = GoParser::new()
+        .unwrap()
+        .with_build_context(BuildContext::new("linux", "amd64"));
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    assert!(parse_changed(&mut parser, "main.go", &mut graph));
+    for skipped in [
+        "calc/calc_plan9.go",
+        "vendor/example.org/dep/dep.go",
+        "testdata/sample.go",
+        "tools/tool.go",
+    ] {
+        assert!(
+            !parse_changed(&mut parser, skipped, &mut graph),
+            "{}",
+            skipped
+        );
+    }
+    assert!(graph.get_nodes_by_name("Plan9Only").is_empty());
+    assert!(graph.get_nodes_by_name("Vendored").is_empty());
+    let plan9_file = dir.join("calc/calc_plan9.go").to_string_lossy().to_string();
+    assert_eq!(
+        graph.metadata.build_excluded.get(&plan9_file),
+        Some(&vec!["Plan9Only".to_string()])
+    );
+
+    let mut parser = GoParser::new()
+        .unwrap()
+        .with_vendor(true)
+        .with_build_context(BuildContext::new("plan9", "amd64"));
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    assert!(parse_changed(&mut parser, "calc/calc_plan9.go", &mut graph));
+    assert!(parse_changed(
+        &mut parser,
+        "vendor/example.org/dep/dep.go",
+        &mut graph
+    ));
+    assert_eq!(graph.get_nodes_by_name("Plan9Only").len(), 1);
+    assert_eq!(import_path(&graph, "Vendored"), "example.org/dep");
+}
+
+#[test]
+fn test_platform_files_are_selected_by_target() {
+    let dir = fixture_dir("go-platforms");
+    let opens = |graph: &CodeGraph| -> Vec<PathBuf> {
+        graph
+            .get_nodes_by_name("Open")
+            .into_iter()
+            .map(|node| node.file_path.clone())
+            .collect()
+    };
+
+    let windows = index_with(
+        GoParser::new()
+            .unwrap()
+            .with_build_context(BuildContext::new("windows", "amd64")),
+        &dir,
+    );
+    assert_eq!(opens(&windows), [dir.join("open_windows.go")]);
+    assert_eq!(
+        call_from(&windows, "main", "Open")
+            .metadata
+            .get("target_id"),
+        Some(&windows.get_nodes_by_name("Open")[0].id)
+    );
+
+    let plan9 = index_with(
+        GoParser::new()
+            .unwrap()
+            .with_build_context(BuildContext::new("plan9", "386")),
+        &dir,
+    );
+    assert_eq!(opens(&plan9), [dir.join("open_other.go")]);
+}
+
+#[test]
+fn test_all_platforms_keeps_tagged_duplicates() {
+    let dir = fixture_dir("go-platforms");
+    let mut graph = index_with(GoParser::new().unwrap().with_all_platforms(true), &dir);
+
+    let mut constraints: Vec<(PathBuf, String)> = graph
+        .get_nodes_by_name("Open")
+        .into_iter()
+        .map(|node| (node.file_path.clone(), node.metadata["build"].clone()))
+        .collect();
+    constraints.sort();
+    assert_eq!(
+        constraints,
+        [
+            (dir.join("open_linux.go"), "linux".to_string()),
+            (dir.join("open_other.go"), "!linux && !windows".to_string()),
+            (dir.join("open_windows.go"), "windows".to_string()),
+        ]
+    );
+    // One definition per platform is no redeclaration, but no single one is the callee
+    assert!(graph.metadata.diagnostics.is_empty());
+    assert!(!call_from(&graph, "main", "Open")
+        .metadata
+        .contains_key("target_id"));
+
+    GoParser::select_platform(&mut graph, &"linux/arm64".parse().unwrap());
+    let open = graph.resolve_symbol("Open").unwrap();
+    assert_eq!(open.file_path, dir.join("open_linux.go"));
+    assert_eq!(
+        call_from(&graph, "main", "Open").metadata.get("target_id"),
+        Some(&open.id)
+    );
+    assert!("linux".parse::<BuildContext>().is_err());
+}
+
+fn call_from<'a>(graph: &'a CodeGraph, caller: &str, callee: &str) -> &'a Edge {
+    let caller = graph.resolve_symbol(caller).unwrap();
+    graph
+        .get_outgoing_edges(&caller.id)
+        .into_iter()
+        .find(|e| e.to == callee)
+        .unwrap_or_else(|| panic!("{} does not call {}", caller.name, callee))
+}
+
+fn caller_names(graph: &CodeGraph, symbol: &str) -> Vec<String> {
+    let mut names: Vec<String> = graph
+        .callers(symbol)
+        .into_iter()
+        .map(|site| site.caller)
+        .collect();
+    names.sort();
+    names
+}
+
+#[test]
+fn test_calls_resolve_across_packages() {
+    let graph = index_with(GoParser::new().unwrap(), &fixture_dir("go-packages"));
+    let greeting = graph
+        .resolve_symbol("example.com/app/helper.Greeting")
+        .unwrap();
+
+    // main -> helper.Greeting is a real edge to the definition...
+    let edge = call_from(&graph, "main", "Greeting");
+    assert_eq!(edge.metadata.get("target_id"), Some(&greeting.id));
+    assert_eq!(
+        edge.metadata.get("import_path").map(String::as_str),
+        Some("example.com/app/helper")
+    );
+    let main = graph.resolve_symbol("main").unwrap();
+    assert!(graph
+        .trace_dependencies(&main.id, 1)
+        .iter()
+        .any(|t| t.to_id.as_ref() == Some(&greeting.id)));
+
+    // ...and main shows up among Greeting's callers
+    assert_eq!(
+        caller_names(&graph, "example.com/app/helper.Greeting"),
+        vec!["Shout", "UseDot", "main"]
+    );
+}
+
+#[test]
+fn test_import_aliases_and_dot_imports() {
+    let graph = index_with(GoParser::new().unwrap(), &fixture_dir("go-packages"));
+    let shout = graph.resolve_symbol("Shout").unwrap();
+    let greeting = graph.resolve_symbol("Greeting").unwrap();
+
+    let aliased = call_from(&graph, "UseAlias", "Shout");
+    assert_eq!(
+        aliased.metadata.get("qualifier").map(String::as_str),
+        Some("h")
+    );
+    assert_eq!(aliased.metadata.get("target_id"), Some(&shout.id));
+
+    let dotted = call_from(&graph, "UseDot", "Greeting");
+    assert_eq!(dotted.metadata.get("target_id"), Some(&greeting.id));
+    assert_eq!(caller_names(&graph, "Shout"), vec!["UseAlias"]);
+}
+
+#[test]
+fn test_calls_outside_the_module_stay_external() {
+    let graph = index_with(GoParser::new().unwrap(), &fixture_dir("go-packages"));
+
+    // strings.ToUpper must not bind to helper.ToUpper despite the shared name
+    let upper = call_from(&graph, "Shout", "ToUpper");
+    assert!(upper.metadata.get("target_id").is_none());
+    assert!(graph.edge_targets(upper).is_empty());
+    assert!(caller_names(&graph, "ToUpper").is_empty());
+
+    let println = call_from(&graph, "main", "Println");
+    assert_eq!(
+        println.metadata.get("import_path").map(String::as_str),
+        Some("fmt")
+    );
+    assert!(graph.edge_targets(println).is_empty());
+}
+
+#[test]
+fn test_package_imports_keep_spec_kinds_apart() {
+    let graph = index_with(GoParser::new().unwrap(), &fixture_dir("go-packages"));
+    let summary = |imports: Vec<&Import>| -> Vec<(String, String, ImportKind, usize, usize)> {
+        imports
+            .iter()
+            .map(|import| {
+                (
+                    graph.importing_package(import),
+                    import.path.clone(),
+                    import.kind(),
+                    import.line,
+                    import.column,
+                )
+            })
+            .collect()
+    };
+
+    let app = "example.com/app".to_string();
+    let helper = "example.com/app/helper".to_string();
+    let within_module = vec![
+        (app.clone(), helper.clone(), ImportKind::Alias, 3, 8),
+        (app.clone(), helper.clone(), ImportKind::Dot, 3, 8),
+        (app.clone(), helper.clone(), ImportKind::Normal, 6, 2),
+    ];
+    assert_eq!(summary(graph.package_imports(false)), within_module);
+    assert_eq!(summary(graph.importers(&helper)), within_module);
+
+    let everything = summary(graph.package_imports(true));
+    assert_eq!(
+        &everything[3..],
+        &[
+            (app, "fmt".to_string(), ImportKind::Normal, 4, 2),
+            (helper, "strings".to_string(), ImportKind::Normal, 3, 8),
+        ]
+    );
+    assert!(graph
+        .imports
+        .iter()
+        .all(|import| import.internal == import.path.starts_with("example.com/app")));
+}
+
+#[test]
+fn test_import_cycles_and_dot_output() {
+    let dir = tempfile::tempdir().unwrap();
+    let write = |path: &str, source: &str| {
+        let path = dir.path().join(path);
+        std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+        std::fs::write(path, source).unwrap();
+    };
+    write("go.mod", "module example.com/cyc\n");
+    write("a/a.go", "package a\n\nimport \"example.com/cyc/b\"\n");
+    write("b/b.go", "package b\n\nimport _ \"example.com/cyc/a\"\n");
+    write(
+        "c/c.go",
+        "package c\n\nimport (\n\t\"fmt\"\n\tx \"example.com/cyc/a\"\n)\n",
+    );
+    // An external test package importing the package it tests is no cycle
+    write(
+        "a/a_test.go",
+        "package a_test\n\nimport \"example.com/cyc/a\"\n",
+    );
+    let graph = index_with(GoParser::new().unwrap().with_tests(true), dir.path());
+
+    let cycles = graph.import_cycles();
+    assert_eq!(cycles.len(), 1);
+    assert_eq!(
+        cycles[0].packages,
+        vec!["example.com/cyc/a", "example.com/cyc/b"]
+    );
+    let edges: Vec<(String, &str)> = cycles[0]
+        .imports
+        .iter()
+        .map(|import| (graph.importing_package(import), import.path.as_str()))
+        .collect();
+    assert_eq!(
+        edges,
+        vec![
+            ("example.com/cyc/a".to_string(), "example.com/cyc/b"),
+            ("example.com/cyc/b".to_string(), "example.com/cyc/a"),
+        ]
+    );
+
+    let importers: Vec<String> = graph
+        .importers("example.com/cyc/a")
+        .iter()
+        .map(|import| graph.importing_package(import))
+        .collect();
+    assert_eq!(importers, vec!["example.com/cyc/b", "example.com/cyc/c"]);
+
+    assert_eq!(
+        dot::render_imports(&graph, &graph.package_imports(true)),
+        r#"digraph Imports {
+  rankdir=LR;
+  node [shape=box];
+
+  "example.com/cyc/a";
+  "example.com/cyc/b";
+  "example.com/cyc/c";
+  "fmt" [shape=ellipse, style=dashed];
+
+  "example.com/cyc/a" -> "example.com/cyc/b";
+  "example.com/cyc/b" -> "example.com/cyc/a" [label="_", style=dotted];
+  "example.com/cyc/c" -> "example.com/cyc/a" [label="x"];
+  "example.com/cyc/c" -> "fmt";
+}
+"#
+    );
+}
+
+#[test]
+fn test_external_test_packages_are_packages_of_their_own() {
+    let dir = tempfile::tempdir().unwrap();
+    let write = |path: &str, source: &str| {
+        let path = dir.path().join(path);
+        std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+        std::fs::write(path, source).unwrap();
+    };
+    write("go.mod", "module example.com/ext\n");
+    write("d/d.go", "package d\n\nfunc Helper() {}\n");
+    write(
+        "e/e.go",
+        "package e\n\nimport \"example.com/ext/d\"\n\nfunc Use() { d.Helper() }\n",
+    );
+    // d_test may import e, which imports d, and may declare its own Helper
+    write(
+        "d/d_test.go",
+        "package d_test\n\nimport (\n\t\"example.com/ext/d\"\n\t\"example.com/ext/e\"\n)\n\nfunc Helper() { d.Helper(); e.Use() }\n\nfunc Run() { Helper() }\n",
+    );
+    let graph = index_with(GoParser::new().unwrap().with_tests(true), dir.path());
+
+    assert!(graph.import_cycles().is_empty());
+    let mut importers: Vec<String> = graph
+        .importers("example.com/ext/e")
+        .iter()
+        .map(|import| graph.importing_package(import))
+        .collect();
+    importers.dedup();
+    assert_eq!(importers, vec!["example.com/ext/d_test"]);
+    assert!(graph
+        .metadata
+        .diagnostics
+        .iter()
+        .all(|d| d.code != "duplicate-symbol"));
+
+    // Helper() in d_test is its own; d.Helper() is the tested package's
+    let helper_called_at = |line: usize| -> PathBuf {
+        let edge = graph
+            .edges
+            .iter()
+            .find(|edge| {
+                edge.file_path.ends_with("d/d_test.go") && edge.line == line && edge.to == "Helper"
+            })
+            .unwrap();
+        let target_id = &edge.metadata["target_id"];
+        let target = graph.get_node_by_id(target_id).unwrap();
+        target.file_path.clone()
+    };
+    assert!(helper_called_at(10).ends_with("d/d_test.go"));
+    assert!(helper_called_at(8).ends_with("d/d.go"));
+}
+
+/// Function names in `graph`, sorted
+fn function_names(graph: &CodeGraph) -> Vec<String> {
+    let mut names: Vec<String> = graph
+        .nodes
+        .iter()
+        .filter(|node| node.node_type == NodeType::Function)
+        .map(|node| node.name.clone())
+        .collect();
+    names.sort();
+    names
+}
+
+#[test]
+fn test_discovery_skips_ignored_hidden_and_generated_files() {
+    let dir = tempfile::tempdir().unwrap();
+    let root = dir.path();
+    let write = |path: &str, contents: &str| {
+        let path = root.join(path);
+        fs::create_dir_all(path.parent().unwrap()).unwrap();
+        fs::write(path, contents).unwrap();
+    };
+    write("main.go", "package main\n\nfunc main() {\n\tKept()\n}\n");
+    write("kept.go", "package main\n\nfunc Kept() {}\n");
+    write(
+        "gen.go",
+        "// Code generated by stringer; DO NOT EDIT.\n\npackage main\n\nfunc Generated() {}\n",
+    );
+    write(".gitignore", "ignored/\n");
+    write("ignored/skip.go", "package ignored\n\nfunc Ignored() {}\n");
+    write("sub/.gitignore", "local.go\n");
+    write("sub/local.go", "package sub\n\nfunc Local() {}\n");
+    write("sub/visible.go", "package sub\n\nfunc Visible() {}\n");
+    write(".hidden/hidden.go", "package hidden\n\nfunc Hidden() {}\n");
+    // A link back to the root must not be walked around forever
+    #[cfg(unix)]
+    std::os::unix::fs::symlink(root, root.join("sub").join("loop")).unwrap();
+
+    let index =
+        |discovery: Discovery| index_with(GoParser::new().unwrap().with_discovery(discovery), root);
+
+    assert_eq!(
+        function_names(&index(Discovery::new())),
+        ["Generated", "Kept", "Visible", "main"]
+    );
+    assert_eq!(
+        function_names(&index(
+            Discovery::new()
+                .with_generated(false)
+                .with_excludes(&["sub/visible.go".to_string()])
+        )),
+        ["Kept", "main"]
+    );
+    // Go skips dot directories whatever the discovery settings, as `./...` does
+    assert_eq!(
+        function_names(&index(Discovery::new().with_gitignore(false))),
+        ["Generated", "Ignored", "Kept", "Local", "Visible", "main"]
+    );
+}
+
+#[test]
+fn test_entry_points_by_package() {
+    let graph = index_with(GoParser::new().unwrap(), &fixture_dir("go-entrypoints"));
+    let packages: Vec<(String, Vec<(EntryKind, String, String, usize)>)> = graph
+        .entry_points(false)
+        .iter()
+        .map(|package| {
+            let entries = package
+                .entry_points
+                .iter()
+                .map(|entry| {
+                    let file = entry.node.file_path.file_name().unwrap();
+                    (
+                        entry.kind,
+                        entry.node.name.clone(),
+                        file.to_string_lossy().to_string(),
+                        entry.node.line,
+                    )
+                })
+                .collect();
+            (package.package.clone(), entries)
+        })
+        .collect();
+    let entry =
+        |kind, name: &str, file: &str, line| (kind, name.to_string(), file.to_string(), line);
+    assert_eq!(
+        packages,
+        vec![
+            (
+                "example.com/shop/cmd/shop".to_string(),
+                vec![
+                    entry(EntryKind::Init, "init", "flags.go", 5),
+                    entry(EntryKind::Init, "init", "main.go", 9),
+                    entry(EntryKind::Init, "init", "main.go", 13),
+                    entry(EntryKind::Main, "main", "main.go", 17),
+                ]
+            ),
+            (
+                "example.com/shop/inventory".to_string(),
+                vec![
+                    entry(EntryKind::Init, "init", "inventory.go", 8),
+                    entry(EntryKind::Exported, "NewStore", "inventory.go", 15),
+                    entry(EntryKind::Exported, "(*Store).Count", "inventory.go", 20),
+                ]
+            ),
+        ]
+    );
+
+    // --strict keeps main and init only
+    let strict: usize = graph
+        .entry_points(true)
+        .iter()
+        .map(|package| package.entry_points.len())
+        .sum();
+    assert_eq!(strict, 5);
+
+    // The whole set as dead code roots, even with --strict
+    let report = graph
+        .dead_code(&DeadCodeOptions {
+            strict: true,
+            roots: vec![ENTRY_POINTS.to_string()],
+        })
+        .unwrap();
+    let dead: Vec<&str> = report.dead.iter().map(|node| node.name.as_str()).collect();
+    assert_eq!(dead, vec!["usage", "(*Store).grow"]);
+
+    // Every entry point reaching fmt.Println, in entry point order
+    let paths = graph
+        .entry_point_paths("fmt.Println", &PathOptions::default())
+        .unwrap();
+    let starts: Vec<(&str, usize)> = paths
+        .iter()
+        .map(|path| (path.start.name.as_str(), path.start.line))
+        .collect();
+    assert_eq!(starts, vec![("init", 9), ("init", 13), ("main", 17)]);
+}
+
+#[test]
+fn test_callees_pruned_to_the_package() {
+    let graph = index_with(GoParser::new().unwrap(), &fixture_dir("go-packages"));
+    let walk = |prune: Vec<Prune>| {
+        graph
+            .callees(
+                "main",
+                &CalleeOptions {
+                    max_depth: 5,
+                    prune,
+                },
+            )
+            .unwrap()
+    };
+
+    // helper's functions are listed where main's package calls them, but not followed
+    let pruned = walk(vec![Prune::External, Prune::Package]);
+    let mut names: Vec<(String, usize)> = pruned
+        .reached
+        .iter()
+        .map(|call| (call.hop.callee_name(), call.depth))
+        .collect();
+    names.sort();
+    assert_eq!(
+        names,
+        vec![
+            ("Greeting".to_string(), 1),
+            ("Shout".to_string(), 2),
+            ("UseAlias".to_string(), 1),
+            ("UseDot".to_string(), 1),
+        ]
+    );
+    assert!(pruned
+        .calls
+        .iter()
+        .all(|call| call.caller.package == "main"));
+
+    let followed = walk(vec![Prune::External]);
+    assert!(followed
+        .calls
+        .iter()
+        .any(|call| call.caller.name == "Shout" && call.hop.callee_name() == "Greeting"));
+}
+
+/// Index the roots go-workspace's go.work uses, each on its own, then merge them
+fn index_workspace() -> CodeGraph {
+    let dir = fixture_dir("go-workspace");
+    let roots = go_module::workspace_roots(&dir)
+        .unwrap()
+        .into_iter()
+        .map(|root| {
+            let graph = index_with(GoParser::new().unwrap(), &root);
+            (root_label(&dir, &root), graph)
+        })
+        .collect();
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    graph.merge_roots(roots);
+    GoParser::resolve_calls(&mut graph);
+    graph
+}
+
+#[test]
+fn test_workspace_roots_resolve_across_modules() {
+    let graph = index_workspace();
+    assert_eq!(graph.metadata.roots, vec!["svc/a", "svc/b", "svc/c"]);
+
+    // svc/a imports svc/b's module through a replace directive
+    let hello = graph.resolve_symbol("example.com/b/greet.Hello").unwrap();
+    assert_eq!(hello.root(), Some("svc/b"));
+    assert_eq!(hello.qualified_name(), "example.com/b/greet.Hello");
+    assert_eq!(
+        call_from(&graph, "Start", "Hello")
+            .metadata
+            .get("target_id"),
+        Some(&hello.id)
+    );
+    assert!(graph
+        .imports
+        .iter()
+        .any(|import| import.path == "example.com/b/greet" && import.internal));
+
+    // svc/a and its fork svc/c both declare example.com/a/internal/config; each
+    // importer binds its own root's, and names and IDs tell the two apart
+    let loads = graph.lookup("Load");
+    let names: Vec<String> = loads.iter().map(|node| node.qualified_name()).collect();
+    assert_eq!(
+        names,
+        vec![
+            "svc/a:example.com/a/internal/config.Load",
+            "svc/c:example.com/a/internal/config.Load",
+        ]
+    );
+    assert!(loads[0]
+        .id
+        .starts_with("svc/a:example.com/a/internal/config.Load#"));
+    assert_eq!(
+        call_from(&graph, "Start", "Load").metadata.get("target_id"),
+        Some(&loads[0].id)
+    );
+    assert_eq!(
+        call_from(&graph, "Run", "Load").metadata.get("target_id"),
+        Some(&loads[1].id)
+    );
+    assert_eq!(
+        graph
+            .resolve_symbol("svc/c:example.com/a/internal/config.Load")
+            .unwrap()
+            .id,
+        loads[1].id
+    );
+
+    let in_fork: SymbolFilter = "root:./svc/c/".parse().unwrap();
+    let mut names: Vec<&str> = graph
+        .nodes
+        .iter()
+        .filter(|node| in_fork.matches(node))
+        .map(|node| node.name.as_str())
+        .collect();
+    names.sort();
+    assert_eq!(names, vec!["Load", "Run"]);
+
+    // As `callers --root` and `references --root`: only sites in the root's functions,
+    // counted before paging
+    let callers = |from: &SymbolFilter| -> Vec<String> {
+        graph
+            .callers_page("Load", 1, from, &PageRequest::default())
+            .items
+            .into_iter()
+            .map(|site| site.caller)
+            .collect()
+    };
+    assert_eq!(callers(&SymbolFilter::default()), vec!["Start", "Run"]);
+    assert_eq!(callers(&in_fork), vec!["Run"]);
+    let references = graph.references_page("Load", &[], &in_fork, &PageRequest::new(0, Some(5)));
+    assert_eq!(references.total, 1);
+    assert_eq!(references.items[0].caller, "Run");
+
+    // Recording the roots again, as after re-parsing a file, changes nothing
+    let mut again = graph.clone();
+    again.assign_roots();
+    let ids = |graph: &CodeGraph| -> Vec<String> {
+        graph.nodes.iter().map(|node| node.id.clone()).collect()
+    };
+    assert_eq!(ids(&again), ids(&graph));
+}
@@ -0,0 +1,243 @@
+use code_navigator::core::CodeGraph;
+use code_navigator::http::serve;
+use code_navigator::parser::GoParser;
+use code_navigator::schema::{
+    CallEdge, Page, Reference, SearchResult, Symbol, Versioned, SCHEMA_VERSION,
+};
+use serde::de::DeserializeOwned;
+use serde_json::Value;
+use std::io::{Read, Write};
+use std::net::{SocketAddr, TcpListener, TcpStream};
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicBool, Ordering};
+
+fn fixture_dir() -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join("simple-go")
+}
+
+fn index_fixture() -> CodeGraph {
+    let dir = fixture_dir();
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    let mut parser = GoParser::new().unwrap();
+    parser.parse_directory(&dir, &mut graph).unwrap();
+    graph
+}
+
+struct Reply {
+    status: u16,
+    content_type: String,
+    body: Value,
+}
+
+impl Reply {
+    fn json<T: DeserializeOwned>(&self) -> T {
+        assert_eq!(self.status, 200, "{}", self.body);
+        assert_eq!(self.content_type, "application/json");
+        serde_json::from_value(self.body.clone()).unwrap()
+    }
+}
+
+fn request(addr: SocketAddr, method: &str, target: &str) -> Reply {
+    let mut stream = TcpStream::connect(addr).unwrap();
+    write!(
+        stream,
+        "{} {} HTTP/1.1\r\nHost: localhost\r\nAccept: application/json\r\n\r\n",
+        method, target
+    )
+    .unwrap();
+    let mut response = String::new();
+    stream.read_to_string(&mut response).unwrap();
+
+    let (head, body) = response.split_once("\r\n\r\n").unwrap();
+    let mut lines = head.lines();
+    let status = lines
+        .next()
+        .unwrap()
+        .split(' ')
+        .nth(1)
+        .unwrap()
+        .parse()
+        .unwrap();
+    let content_type = lines
+        .find_map(|line| line.strip_prefix("Content-Type: "))
+        .unwrap()
+        .to_string();
+    Reply {
+        status,
+        content_type,
+        body: serde_json::from_str(body).unwrap(),
+    }
+}
+
+fn get(addr: SocketAddr, target: &str) -> Reply {
+    request(addr, "GET", target)
+}
+
+/// Every byte outside the unreserved set escaped, as a client building a URL would
+fn escape(text: &str) -> String {
+    text.bytes()
+        .map(|byte| match byte {
+            b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'-' | b'.' | b'_' | b'~' => {
+                (byte as char).to_string()
+            }
+            _ => format!("%{:02X}", byte),
+        })
+        .collect()
+}
+
+/// Stops the server even when an assertion fails, so the scope can end
+struct StopOnDrop<'a>(&'a AtomicBool);
+
+impl Drop for StopOnDrop<'_> {
+    fn drop(&mut self) {
+        self.0.store(true, Ordering::Relaxed);
+    }
+}
+
+fn names(references: &[Reference]) -> Vec<&str> {
+    references.iter().map(|r| r.from_name.as_str()).collect()
+}
+
+#[test]
+fn test_routes_against_the_fixture_index() {
+    let graph = index_fixture();
+    let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+    let addr = listener.local_addr().unwrap();
+    let shutdown = AtomicBool::new(false);
+
+    std::thread::scope(|scope| {
+        let server = scope.spawn(|| serve(&graph, &listener, &shutdown));
+        let stop = StopOnDrop(&shutdown);
+
+        let health = get(addr, "/healthz");
+        assert_eq!(health.status, 200);
+        assert_eq!(health.body["status"], "ok");
+
+        let results: Vec<SearchResult> = get(addr, "/symbols?q=multiply").json();
+        assert_eq!(results[0].symbol.name, "Multiply");
+
+        // A limit or an offset asks for a page that reports the total
+        let first: Page<SearchResult> = get(addr, "/symbols?q=multiply&limit=1").json();
+        assert_eq!(first.results.len(), 1);
+        assert_eq!(first.results[0].symbol.name, "Multiply");
+        assert_eq!(first.offset, 0);
+        let page: Page<SearchResult> = get(addr, "/symbols?q=a&limit=2&offset=0").json();
+        assert_eq!(page.results.len(), 2);
+        assert!(page.total > 2 && page.has_more);
+        assert_eq!(page.next_offset, Some(2));
+        assert_eq!(page.schema_version, SCHEMA_VERSION);
+        let next: Page<SearchResult> = get(addr, "/symbols?q=a&limit=2&offset=2").json();
+        assert_eq!(next.offset, 2);
+        assert_ne!(next.results[0].symbol.id, page.results[0].symbol.id);
+        assert_eq!(get(addr, "/symbols?q=a&offset=-1").status, 400);
+
+        // IDs contain `#` and are escaped like any path segment
+        let add_id = graph.resolve_symbol("Add").unwrap().id.clone();
+        assert!(add_id.starts_with("main.Add#"), "{}", add_id);
+        // Objects carry the version of the schema they follow
+        let add: Versioned<Symbol> = get(addr, &format!("/symbol/{}", escape(&add_id))).json();
+        assert_eq!(add.schema_version, SCHEMA_VERSION);
+        let add = add.value;
+        assert_eq!(add.name, "Add");
+        assert_eq!(add.location.line, 6);
+
+        let references: Vec<Reference> =
+            get(addr, &format!("/symbol/{}/references", escape(&add_id))).json();
+        let from = names(&references);
+        assert!(from.contains(&"main"), "{:?}", from);
+        assert!(from.contains(&"Multiply"), "{:?}", from);
+        let page: Page<Reference> = get(
+            addr,
+            &format!("/symbol/{}/references?limit=1&offset=1", escape(&add_id)),
+        )
+        .json();
+        assert_eq!(page.total, references.len());
+        assert_eq!(page.results, vec![references[1].clone()]);
+
+        // Names work where they are unambiguous
+        let callers: Vec<Reference> = get(addr, "/symbol/Greet/callers").json();
+        assert_eq!(names(&callers), vec!["main"]);
+        // main calls Greet, whose deferred literal calls PrintMessage
+        let callers: Vec<Reference> = get(addr, "/symbol/PrintMessage/callers?depth=3").json();
+        assert!(callers
+            .iter()
+            .any(|c| c.from_name == "main" && c.depth == 3));
+
+        let calls: Vec<CallEdge> = get(addr, "/callgraph?root=main&depth=1").json();
+        let callees: Vec<&str> = calls.iter().map(|c| c.callee.as_str()).collect();
+        for name in ["Add", "Multiply", "Greet"] {
+            assert!(
+                callees.contains(&name),
+                "{} missing from {:?}",
+                name,
+                callees
+            );
+        }
+        assert!(calls.iter().all(|c| c.depth == 1));
+
+        // Paths relative to the indexed root, in source order
+        let symbols: Vec<Symbol> = get(addr, "/file?path=main.go").json();
+        let defined: Vec<&str> = symbols.iter().map(|s| s.name.as_str()).collect();
+        assert_eq!(
+            defined,
+            vec![
+                "Add",
+                "Multiply",
+                "Greet",
+                "Greet.func1",
+                "PrintMessage",
+                "main",
+                "Apply",
+                "Even",
+                "Odd",
+                "Factorial"
+            ]
+        );
+
+        drop(stop);
+        server.join().unwrap().unwrap();
+    });
+}
+
+#[test]
+fn test_errors_are_problem_objects() {
+    let graph = index_fixture();
+    let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+    let addr = listener.local_addr().unwrap();
+    let shutdown = AtomicBool::new(false);
+
+    std::thread::scope(|scope| {
+        let server = scope.spawn(|| serve(&graph, &listener, &shutdown));
+        let stop = StopOnDrop(&shutdown);
+
+        let unknown = get(addr, "/symbol/NoSuchFunction/references");
+        assert_eq!(unknown.status, 404);
+        assert_eq!(unknown.content_type, "application/problem+json");
+        assert_eq!(unknown.body["status"], 404);
+        assert_eq!(unknown.body["title"], "Not Found");
+        assert!(unknown.body["detail"]
+            .as_str()
+            .unwrap()
+            .contains("NoSuchFunction"));
+
+        for target in [
+            "/symbols",
+            "/callgraph",
+            "/callgraph?root=main&depth=zero",
+            "/file",
+        ] {
+            let bad = get(addr, target);
+            assert_eq!(bad.status, 400, "{}", target);
+            assert_eq!(bad.body["status"], 400, "{}", target);
+        }
+        assert_eq!(get(addr, "/file?path=missing.go").status, 404);
+        assert_eq!(get(addr, "/nowhere").status, 404);
+        assert_eq!(request(addr, "POST", "/healthz").status, 405);
+
+        drop(stop);
+        server.join().unwrap().unwrap();
+    });
+}
@@ -0,0 +1,157 @@
+use code_navigator::core::CodeGraph;
+use code_navigator::parser::GoParser;
+use code_navigator::serializer::file_cache::{CacheStats, FileCache, FILE_CACHE_VERSION};
+use std::fs;
+use std::path::Path;
+
+fn copy_fixture(name: &str, dest: &Path) {
+    let src = Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join(name);
+    for entry in fs::read_dir(src).unwrap() {
+        let path = entry.unwrap().path();
+        fs::copy(&path, dest.join(path.file_name().unwrap())).unwrap();
+    }
+}
+
+/// Index `dir` the way `codenav index` does: load the cache, parse, save it back
+fn index_cached(dir: &Path) -> (CodeGraph, CacheStats) {
+    let cache_path = FileCache::default_path(dir);
+    let mut cache = FileCache::load(&cache_path, "go").unwrap_or_else(|_| FileCache::new("go"));
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    let mut parser = GoParser::new().unwrap();
+    let stats = parser
+        .parse_directory_cached(dir, &mut graph, Some(&mut cache))
+        .unwrap();
+    cache.save(&cache_path).unwrap();
+    (graph, stats)
+}
+
+fn call_target_id(graph: &CodeGraph, caller: &str, callee: &str) -> String {
+    let caller = &graph.get_nodes_by_name(caller)[0];
+    let edge = graph
+        .get_outgoing_edges(&caller.id)
+        .into_iter()
+        .find(|e| e.to == callee)
+        .unwrap_or_else(|| panic!("{} does not call {}", caller.name, callee));
+    edge.metadata.get("target_id").unwrap().clone()
+}
+
+#[test]
+fn test_unchanged_files_are_served_from_cache() {
+    let dir = tempfile::tempdir().unwrap();
+    copy_fixture("simple-go", dir.path());
+
+    let (first, stats) = index_cached(dir.path());
+    assert_eq!(stats, CacheStats { hits: 0, misses: 2 });
+
+    let (second, stats) = index_cached(dir.path());
+    assert_eq!(stats, CacheStats { hits: 2, misses: 0 });
+    // The counts are kept with the graph for `stats`
+    assert_eq!(second.metadata.stats.cache, Some(stats));
+    assert_eq!(second.index_stats().cache_hit_rate(), Some(1.0));
+
+    let ids = |graph: &CodeGraph| {
+        let mut ids: Vec<String> = graph.nodes.iter().map(|n| n.id.clone()).collect();
+        ids.sort();
+        ids
+    };
+    assert_eq!(ids(&first), ids(&second));
+    assert_eq!(first.edges.len(), second.edges.len());
+}
+
+#[test]
+fn test_each_root_keeps_its_own_cache() {
+    let dir = tempfile::tempdir().unwrap();
+    let (a, b) = (dir.path().join("svc/a"), dir.path().join("svc/b"));
+    for root in [&a, &b] {
+        fs::create_dir_all(root).unwrap();
+        copy_fixture("simple-go", root);
+    }
+    // As `index --root svc/a --root svc/b`, each root against the cache in its directory
+    let index = || [&a, &b].map(|root| index_cached(root).1);
+    assert_eq!(index(), [CacheStats { hits: 0, misses: 2 }; 2]);
+
+    // Editing svc/a re-parses that file alone, and svc/b is served whole from its cache
+    let main_go = a.join("main.go");
+    let source = fs::read_to_string(&main_go).unwrap();
+    fs::write(&main_go, format!("{}\nfunc Extra() {{}}\n", source)).unwrap();
+    assert_eq!(
+        index(),
+        [
+            CacheStats { hits: 1, misses: 1 },
+            CacheStats { hits: 2, misses: 0 }
+        ]
+    );
+}
+
+#[test]
+fn test_modified_file_invalidates_only_its_entries() {
+    let dir = tempfile::tempdir().unwrap();
+    copy_fixture("simple-go", dir.path());
+    let (before, _) = index_cached(dir.path());
+    let old_target = call_target_id(&before, "(*Calculator).LogOperation", "PrintMessage");
+
+    // Shift every definition in main.go down by two lines
+    let main_go = dir.path().join("main.go");
+    let source = fs::read_to_string(&main_go).unwrap();
+    let source = source.replacen("import \"fmt\"\n", "import \"fmt\"\n\n// moved\n", 1);
+    fs::write(&main_go, source).unwrap();
+
+    let (after, stats) = index_cached(dir.path());
+    assert_eq!(stats, CacheStats { hits: 1, misses: 1 });
+
+    let print_message = &after.get_nodes_by_name("PrintMessage")[0];
+    assert_eq!(print_message.line, 28);
+
+    // calculator.go came from the cache, but its call into main.go follows the move
+    let new_target = call_target_id(&after, "(*Calculator).LogOperation", "PrintMessage");
+    assert_ne!(new_target, old_target);
+    assert_eq!(new_target, print_message.id);
+    assert_eq!(after.get_nodes_by_name("(*Calculator).Add")[0].line, 17);
+}
+
+#[test]
+fn test_deleted_file_is_dropped_from_cache() {
+    let dir = tempfile::tempdir().unwrap();
+    copy_fixture("simple-go", dir.path());
+    index_cached(dir.path());
+
+    fs::remove_file(dir.path().join("calculator.go")).unwrap();
+    let (graph, stats) = index_cached(dir.path());
+    assert_eq!(stats, CacheStats { hits: 1, misses: 0 });
+    assert!(graph.get_nodes_by_name("(*Calculator).Add").is_empty());
+
+    let cache = FileCache::load(&FileCache::default_path(dir.path()), "go").unwrap();
+    assert_eq!(cache.files.len(), 1);
+}
+
+#[test]
+fn test_incompatible_cache_is_discarded() {
+    let dir = tempfile::tempdir().unwrap();
+    copy_fixture("simple-go", dir.path());
+
+    let mut stale = FileCache::new("go");
+    stale.format_version = FILE_CACHE_VERSION + 1;
+    stale.save(&FileCache::default_path(dir.path())).unwrap();
+
+    let (graph, stats) = index_cached(dir.path());
+    assert_eq!(stats, CacheStats { hits: 0, misses: 2 });
+    assert!(!graph.get_nodes_by_name("main").is_empty());
+}
+
+#[test]
+fn test_cached_files_keep_their_syntax_errors() {
+    let dir = tempfile::tempdir().unwrap();
+    copy_fixture("go-syntax-errors", dir.path());
+
+    let (first, _) = index_cached(dir.path());
+    assert!(!first.metadata.diagnostics.is_empty());
+
+    let (second, stats) = index_cached(dir.path());
+    assert_eq!(stats, CacheStats { hits: 2, misses: 0 });
+    assert_eq!(second.metadata.stats.cache, Some(stats));
+    assert_eq!(second.index_stats().cache_hit_rate(), Some(1.0));
+    assert_eq!(first.metadata.diagnostics, second.metadata.diagnostics);
+}
@@ -0,0 +1,525 @@
+use code_navigator::core::{CodeGraph, Node, NodeType, PageRequest};
+use code_navigator::parser::GoParser;
+use code_navigator::schema::{
+    self, CallEdge, EdgeKind, Location, Position, Reference, ReferenceKind, Span, Symbol,
+};
+use serde::de::DeserializeOwned;
+use serde::Serialize;
+use std::path::{Path, PathBuf};
+
+fn fixture_dir() -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join("simple-go")
+}
+
+fn index_fixture() -> CodeGraph {
+    let dir = fixture_dir();
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    let mut parser = GoParser::new().unwrap();
+    parser.parse_directory(&dir, &mut graph).unwrap();
+    graph
+}
+
+/// ID of the symbol declared at `file:line`
+fn id(graph: &CodeGraph, file: &str, name: &str, line: usize) -> String {
+    let path = fixture_dir().join(file);
+    graph
+        .nodes
+        .iter()
+        .find(|n| n.file_path == path && n.name == name && n.line == line)
+        .unwrap_or_else(|| panic!("no {} at {}:{}", name, file, line))
+        .id
+        .clone()
+}
+
+fn location(file: &str, line: usize, column: usize) -> Location {
+    Location::new(&fixture_dir().join(file), line, column)
+}
+
+/// Encode with the shared encoder and decode back into `T`
+fn round_trip<T: Serialize + DeserializeOwned>(value: &T) -> T {
+    let mut buf = Vec::new();
+    schema::write_json(&mut buf, value).unwrap();
+    serde_json::from_slice(&buf).unwrap()
+}
+
+#[test]
+fn test_symbols_round_trip() {
+    let graph = index_fixture();
+    let symbols = round_trip(&schema::symbols(&graph.nodes));
+
+    let summary: Vec<(String, NodeType, Location)> = symbols
+        .iter()
+        .map(|s| (s.name.clone(), s.kind.clone(), s.location.clone()))
+        .collect();
+    assert_eq!(
+        summary,
+        vec![
+            (
+                "Calculator".to_string(),
+                NodeType::Struct,
+                location("calculator.go", 6, 6)
+            ),
+            (
+                "Calculator.name".to_string(),
+                NodeType::Field,
+                location("calculator.go", 8, 2)
+            ),
+            (
+                "NewCalculator".to_string(),
+                NodeType::Function,
+                location("calculator.go", 12, 6)
+            ),
+            (
+                "(*Calculator).Add".to_string(),
+                NodeType::Method,
+                location("calculator.go", 17, 22)
+            ),
+            (
+                "(*Calculator).Subtract".to_string(),
+                NodeType::Method,
+                location("calculator.go", 24, 22)
+            ),
+            (
+                "(*Calculator).LogOperation".to_string(),
+                NodeType::Method,
+                location("calculator.go", 31, 22)
+            ),
+            (
+                "Calculator.Name".to_string(),
+                NodeType::Method,
+                location("calculator.go", 37, 21)
+            ),
+            (
+                "Logger".to_string(),
+                NodeType::Interface,
+                location("calculator.go", 42, 6)
+            ),
+            (
+                "Logger.LogOperation".to_string(),
+                NodeType::Method,
+                location("calculator.go", 43, 2)
+            ),
+            (
+                "Logger.Name".to_string(),
+                NodeType::Method,
+                location("calculator.go", 44, 2)
+            ),
+            (
+                "Recorder".to_string(),
+                NodeType::Struct,
+                location("calculator.go", 48, 6)
+            ),
+            (
+                "(*Recorder).LogOperation".to_string(),
+                NodeType::Method,
+                location("calculator.go", 51, 20)
+            ),
+            (
+                "(*Calculator).SetName".to_string(),
+                NodeType::Method,
+                location("calculator.go", 54, 22)
+            ),
+            (
+                "logger".to_string(),
+                NodeType::Struct,
+                location("calculator.go", 60, 6)
+            ),
+            (
+                "(*logger).Warn".to_string(),
+                NodeType::Method,
+                location("calculator.go", 63, 18)
+            ),
+            (
+                "Add".to_string(),
+                NodeType::Function,
+                location("main.go", 6, 6)
+            ),
+            (
+                "Multiply".to_string(),
+                NodeType::Function,
+                location("main.go", 11, 6)
+            ),
+            (
+                "Greet".to_string(),
+                NodeType::Function,
+                location("main.go", 20, 6)
+            ),
+            (
+                "Greet.func1".to_string(),
+                NodeType::Function,
+                location("main.go", 22, 8)
+            ),
+            (
+                "PrintMessage".to_string(),
+                NodeType::Function,
+                location("main.go", 26, 6)
+            ),
+            (
+                "main".to_string(),
+                NodeType::Function,
+                location("main.go", 30, 6)
+            ),
+            (
+                "Apply".to_string(),
+                NodeType::Function,
+                location("main.go", 38, 6)
+            ),
+            (
+                "Even".to_string(),
+                NodeType::Function,
+                location("main.go", 44, 6)
+            ),
+            (
+                "Odd".to_string(),
+                NodeType::Function,
+                location("main.go", 51, 6)
+            ),
+            (
+                "Factorial".to_string(),
+                NodeType::Function,
+                location("main.go", 59, 6)
+            ),
+        ]
+    );
+
+    let add = &symbols[3];
+    assert_eq!(
+        add,
+        &Symbol {
+            id: id(&graph, "calculator.go", "(*Calculator).Add", 17),
+            name: "(*Calculator).Add".to_string(),
+            kind: NodeType::Method,
+            package: "main".to_string(),
+            import_path: None,
+            root: None,
+            signature: "func (c *Calculator) Add(a, b int) int {".to_string(),
+            doc: "Add adds two numbers (method)".to_string(),
+            location: location("calculator.go", 17, 22),
+            end_line: 21,
+            name_range: Some(Span {
+                start: Position {
+                    line: 17,
+                    column: 22,
+                    offset: 298,
+                },
+                end: Position {
+                    line: 17,
+                    column: 25,
+                    offset: 301,
+                },
+            }),
+            range: Some(Span {
+                start: Position {
+                    line: 16,
+                    column: 1,
+                    offset: 244,
+                },
+                end: Position {
+                    line: 21,
+                    column: 2,
+                    offset: 382,
+                },
+            }),
+            receiver: Some("*Calculator".to_string()),
+            build: None,
+            complexity: Some(1),
+        }
+    );
+}
+
+#[test]
+fn test_call_edges_round_trip() {
+    let graph = index_fixture();
+    let traces = graph.trace_dependencies(&id(&graph, "main.go", "main", 30), 1);
+    let edges = round_trip(&schema::call_edges(&traces));
+
+    let caller = id(&graph, "main.go", "main", 30);
+    assert_eq!(
+        edges,
+        vec![
+            CallEdge {
+                caller: caller.clone(),
+                callee: "Add".to_string(),
+                callee_id: Some(id(&graph, "main.go", "Add", 6)),
+                location: location("main.go", 31, 9),
+                depth: 1,
+                indirect: false,
+                dynamic: false,
+                kind: EdgeKind::Normal,
+                promoted_via: vec![],
+                hidden_via: vec![],
+            },
+            CallEdge {
+                caller: caller.clone(),
+                callee: "Multiply".to_string(),
+                callee_id: Some(id(&graph, "main.go", "Multiply", 11)),
+                location: location("main.go", 32, 13),
+                depth: 1,
+                indirect: false,
+                dynamic: false,
+                kind: EdgeKind::Normal,
+                promoted_via: vec![],
+                hidden_via: vec![],
+            },
+            CallEdge {
+                caller: caller.clone(),
+                callee: "Printf".to_string(),
+                callee_id: None,
+                location: location("main.go", 33, 2),
+                depth: 1,
+                indirect: false,
+                dynamic: false,
+                kind: EdgeKind::Normal,
+                promoted_via: vec![],
+                hidden_via: vec![],
+            },
+            CallEdge {
+                caller,
+                callee: "Greet".to_string(),
+                callee_id: Some(id(&graph, "main.go", "Greet", 20)),
+                location: location("main.go", 34, 2),
+                depth: 1,
+                indirect: false,
+                dynamic: false,
+                kind: EdgeKind::Normal,
+                promoted_via: vec![],
+                hidden_via: vec![],
+            },
+        ]
+    );
+}
+
+#[test]
+fn test_references_round_trip() {
+    let graph = index_fixture();
+    let references = round_trip(&schema::references(&graph.callers("Add")));
+
+    let reference = |from: &str, from_line: usize, line: usize, column: usize| Reference {
+        symbol: "Add".to_string(),
+        symbol_id: Some(id(&graph, "main.go", "Add", 6)),
+        kind: ReferenceKind::Call,
+        from: id(&graph, "main.go", from, from_line),
+        from_name: from.to_string(),
+        location: location("main.go", line, column),
+        depth: 1,
+        indirect: false,
+        dynamic: false,
+        call_kind: EdgeKind::Normal,
+    };
+    assert_eq!(
+        references,
+        vec![
+            reference("Multiply", 11, 12, 12),
+            reference("Multiply", 11, 14, 12),
+            reference("main", 30, 31, 9),
+            reference("Apply", 38, 40, 9),
+        ]
+    );
+}
+
+#[test]
+fn test_json_field_names() {
+    let graph = index_fixture();
+    let mut buf = Vec::new();
+    schema::write_json(
+        &mut buf,
+        &schema::references(&graph.callers("PrintMessage")),
+    )
+    .unwrap();
+    let value: serde_json::Value = serde_json::from_slice(&buf).unwrap();
+
+    let first = &value[0];
+    assert_eq!(first["symbol"], "PrintMessage");
+    assert_eq!(first["kind"], "call");
+    assert_eq!(first["from_name"], "(*Calculator).LogOperation");
+    assert_eq!(first["location"]["line"], 33);
+    assert_eq!(first["location"]["column"], 2);
+    // Only possible calls through a parameter carry the flag
+    assert!(first.get("indirect").is_none());
+    assert!(first["location"]["file"]
+        .as_str()
+        .unwrap()
+        .ends_with("calculator.go"));
+}
+
+#[test]
+fn test_indirect_and_goroutine_calls_are_flagged() {
+    let dir = Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join("go-callbacks");
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    let mut parser = GoParser::new().unwrap();
+    parser.parse_directory(&dir, &mut graph).unwrap();
+
+    let apply = graph.resolve_symbol("apply").unwrap();
+    let traces = graph.trace_dependencies(&apply.id, 1);
+    let value = serde_json::to_value(schema::call_edges(&traces)).unwrap();
+    let add = value
+        .as_array()
+        .unwrap()
+        .iter()
+        .find(|edge| edge["callee"] == "Add")
+        .unwrap();
+    assert_eq!(add["indirect"], true);
+    assert_eq!(add["location"]["line"], 15);
+    assert_eq!(add["kind"], "normal");
+
+    let run = graph.resolve_symbol("Run").unwrap();
+    let traces = graph.trace_dependencies(&run.id, 1);
+    let value = serde_json::to_value(schema::call_edges(&traces)).unwrap();
+    let kinds: Vec<(&str, &str)> = value
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|edge| {
+            (
+                edge["callee"].as_str().unwrap(),
+                edge["kind"].as_str().unwrap(),
+            )
+        })
+        .collect();
+    assert_eq!(
+        kinds,
+        vec![
+            ("(*Logger).LogOperation", "defer"),
+            ("report", "go"),
+            ("Run.func1", "go"),
+            ("report", "normal"),
+        ]
+    );
+}
+
+#[test]
+fn test_unresolved_snapshot() {
+    let graph = index_fixture();
+    let buckets: Vec<_> = graph
+        .unresolved()
+        .iter()
+        .map(|bucket| schema::UnresolvedBucket::new(bucket, 1))
+        .collect();
+    let mut buf = Vec::new();
+    schema::write_json(&mut buf, &buckets).unwrap();
+    let value: serde_json::Value = serde_json::from_slice(&buf).unwrap();
+
+    let example = |file: &str, line: usize, column: usize| {
+        serde_json::to_value(location(file, line, column)).unwrap()
+    };
+    // --examples 1 keeps the first of the two Sprintf calls
+    assert_eq!(
+        value,
+        serde_json::json!([{
+            "reason": "external_package",
+            "count": 4,
+            "names": [
+                { "name": "fmt.Sprintf", "count": 2, "examples": [example("calculator.go", 32, 9)] },
+                { "name": "fmt.Printf", "count": 1, "examples": [example("main.go", 33, 2)] },
+                { "name": "fmt.Println", "count": 1, "examples": [example("main.go", 27, 2)] },
+            ],
+        }])
+    );
+}
+
+#[test]
+fn test_limit_alone_prints_a_page() {
+    let graph = index_fixture();
+    let nodes: Vec<&Node> = graph.nodes.iter().collect();
+
+    // As `query --limit 2 --json`
+    let page = PageRequest::new(0, Some(2)).apply(nodes.clone());
+    let listing = schema::Listing::new(&page, Some(2), None, schema::symbols(page.items.clone()));
+    let mut buf = Vec::new();
+    schema::write_json(&mut buf, &listing).unwrap();
+    let printed: schema::Page<Symbol> = serde_json::from_slice(&buf).unwrap();
+    assert_eq!(printed.schema_version, schema::SCHEMA_VERSION);
+    assert_eq!(printed.results.len(), 2);
+    assert_eq!(printed.total, nodes.len());
+    assert_eq!(
+        (printed.offset, printed.has_more, printed.next_offset),
+        (0, true, Some(2))
+    );
+
+    // Without either flag the list is printed bare
+    let page = PageRequest::default().apply(nodes.clone());
+    let listing = schema::Listing::new(&page, None, None, schema::symbols(page.items.clone()));
+    let mut buf = Vec::new();
+    schema::write_json(&mut buf, &listing).unwrap();
+    let printed: Vec<Symbol> = serde_json::from_slice(&buf).unwrap();
+    assert_eq!(printed.len(), nodes.len());
+}
+
+#[test]
+fn test_objects_carry_the_schema_version() {
+    let graph = index_fixture();
+
+    // As `stats --json`: the version comes first among the object's own fields
+    let stats = schema::Versioned::new(schema::IndexStats::new(&graph, &graph.index_stats()));
+    let mut buf = Vec::new();
+    schema::write_json(&mut buf, &stats).unwrap();
+    let text = String::from_utf8(buf).unwrap();
+    assert!(
+        text.starts_with("{\n  \"schema_version\": 1,\n  \"root\""),
+        "{}",
+        text
+    );
+    let printed: schema::Versioned<schema::IndexStats> = serde_json::from_str(&text).unwrap();
+    assert_eq!(printed, stats);
+    assert_eq!(printed.value.files, 2);
+
+    // As `trace --json`: a list stays bare, as 0.4.0 printed it
+    let traces = graph.trace_dependencies(&id(&graph, "main.go", "main", 30), 1);
+    let mut buf = Vec::new();
+    schema::write_json(&mut buf, &schema::call_edges(&traces)).unwrap();
+    let value: serde_json::Value = serde_json::from_slice(&buf).unwrap();
+    assert!(value.is_array(), "{}", value);
+}
+
+#[test]
+fn test_grouped_call_graph_snapshot() {
+    let graph = index_fixture();
+    let grouped = graph.group_calls(schema::GroupBy::File);
+    let mut buf = Vec::new();
+    schema::write_json(
+        &mut buf,
+        &schema::CallGraphGroups::new(&grouped, true, false, true),
+    )
+    .unwrap();
+    let value: serde_json::Value = serde_json::from_slice(&buf).unwrap();
+
+    // Collapsed groups carry no symbols, and the calls within them are dropped
+    assert_eq!(
+        value,
+        serde_json::json!({
+            "group_by": "file",
+            "groups": [
+                { "name": "calculator.go", "size": 15 },
+                { "name": "main.go", "size": 10 },
+            ],
+            "calls": [
+                { "from": "calculator.go", "to": "main.go", "calls": 1 },
+                { "from": "calculator.go", "to": "fmt.Sprintf", "external": true, "calls": 1 },
+                { "from": "main.go", "to": "fmt.Printf", "external": true, "calls": 1 },
+                { "from": "main.go", "to": "fmt.Println", "external": true, "calls": 1 },
+                { "from": "main.go", "to": "fmt.Sprintf", "external": true, "calls": 1 },
+            ],
+        })
+    );
+
+    let expanded = schema::CallGraphGroups::new(&grouped, false, false, false);
+    assert_eq!(expanded.groups[1].symbols[0].name, "Add");
+    assert_eq!(
+        expanded
+            .calls
+            .iter()
+            .map(|call| (call.from.as_str(), call.to.as_str(), call.calls))
+            .collect::<Vec<_>>(),
+        vec![
+            ("calculator.go", "calculator.go", 3),
+            ("calculator.go", "main.go", 1),
+            ("main.go", "main.go", 11),
+        ]
+    );
+}
@@ -0,0 +1,142 @@
+//! `GoParser::with_low_memory` against the default mode on a generated tree, with
+//! heap use counted by a wrapping allocator
+use code_navigator::core::CodeGraph;
+use code_navigator::parser::{GoParser, LOW_MEMORY_BATCH};
+use std::alloc::{GlobalAlloc, Layout, System};
+use std::fs;
+use std::path::Path;
+use std::sync::atomic::{AtomicUsize, Ordering};
+
+/// The system allocator, counting live bytes and their high-water mark
+struct Counting;
+
+static LIVE: AtomicUsize = AtomicUsize::new(0);
+static PEAK: AtomicUsize = AtomicUsize::new(0);
+
+unsafe impl GlobalAlloc for Counting {
+    unsafe fn alloc(&self, layout: Layout) -> *mut u8 {
+        let ptr = unsafe { System.alloc(layout) };
+        if !ptr.is_null() {
+            let live = LIVE.fetch_add(layout.size(), Ordering::Relaxed) + layout.size();
+            PEAK.fetch_max(live, Ordering::Relaxed);
+        }
+        ptr
+    }
+
+    unsafe fn dealloc(&self, ptr: *mut u8, layout: Layout) {
+        unsafe { System.dealloc(ptr, layout) };
+        LIVE.fetch_sub(layout.size(), Ordering::Relaxed);
+    }
+}
+
+#[global_allocator]
+static ALLOCATOR: Counting = Counting;
+
+/// Write a package of `files` files, each with a method, a function taking another
+/// file's function as a value and a call spread over several lines
+fn write_package(dir: &Path, files: usize) {
+    fs::write(dir.join("go.mod"), "module example.com/large\n\ngo 1.21\n").unwrap();
+    for i in 0..files {
+        let next = (i + 1) % files;
+        let source = format!(
+            "package main\n\n\
+             type T{i} struct{{ n int }}\n\n\
+             func (t *T{i}) Step() int {{ return t.n }}\n\n\
+             func G{i}(a, b int) int {{ return a + b }}\n\n\
+             // F{i} calls into the next file\n\
+             func F{i}() int {{\n\
+             \tt := &T{next}{{}}\n\
+             \tapply := G{next}\n\
+             \treturn t.Step() + apply(\n\
+             \t\tt.n,\n\
+             \t\t2,\n\
+             \t) + F{next}()\n\
+             }}\n"
+        );
+        fs::write(dir.join(format!("file{:05}.go", i)), source).unwrap();
+    }
+}
+
+fn index(dir: &Path, low_memory: bool) -> CodeGraph {
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+    GoParser::new()
+        .unwrap()
+        .with_low_memory(low_memory)
+        .parse_directory(dir, &mut graph)
+        .unwrap();
+    graph
+}
+
+/// The serialized graph, minus the timestamp that differs between any two runs
+fn serialized(mut graph: CodeGraph) -> String {
+    graph.metadata.generated_at = String::new();
+    serde_json::to_string(&graph).unwrap()
+}
+
+/// Run `f`, returning what it kept allocated and the most it had allocated at once,
+/// in bytes
+fn measure<T>(f: impl FnOnce() -> T) -> (T, usize, usize) {
+    let base = LIVE.load(Ordering::Relaxed);
+    PEAK.store(base, Ordering::Relaxed);
+    let result = f();
+    let retained = LIVE.load(Ordering::Relaxed).saturating_sub(base);
+    let peak = PEAK.load(Ordering::Relaxed).saturating_sub(base);
+    (result, retained, peak)
+}
+
+#[test]
+fn test_low_memory_index_matches_default() {
+    let dir = tempfile::tempdir().unwrap();
+    // Enough files for several batches
+    write_package(dir.path(), LOW_MEMORY_BATCH * 2 + 10);
+
+    let default = index(dir.path(), false);
+    let low_memory = index(dir.path(), true);
+
+    let callers = |graph: &CodeGraph| -> Vec<_> {
+        graph
+            .callers("G7")
+            .into_iter()
+            .map(|site| (site.caller, site.file_path, site.line, site.call_site))
+            .collect()
+    };
+    assert_eq!(callers(&low_memory), callers(&default));
+    // A call spanning lines keeps its whole text, as in the exports
+    assert!(low_memory.callers("G7")[0]
+        .call_site
+        .starts_with("apply(\n"));
+    assert_eq!(
+        low_memory.references("G7").len(),
+        default.references("G7").len()
+    );
+
+    assert_eq!(serialized(low_memory), serialized(default));
+}
+
+/// Compare heap use of the two modes:
+/// `cargo test --release --test low_memory -- --ignored --nocapture`
+#[test]
+#[ignore]
+fn bench_low_memory_index() {
+    let dir = tempfile::tempdir().unwrap();
+    write_package(dir.path(), 20_000);
+    // The thread pool and other one-time allocations aren't charged to either run
+    index(dir.path(), false);
+
+    let (default, default_retained, default_peak) = measure(|| index(dir.path(), false));
+    let (low_memory, low_retained, low_peak) = measure(|| index(dir.path(), true));
+    assert_eq!(default.nodes.len(), low_memory.nodes.len());
+    assert_eq!(default.edges.len(), low_memory.edges.len());
+
+    let mib = |bytes: usize| bytes as f64 / (1024.0 * 1024.0);
+    println!(
+        "20000 files: default {:.1} MiB retained, {:.1} MiB peak; \
+         --low-memory {:.1} MiB retained, {:.1} MiB peak",
+        mib(default_retained),
+        mib(default_peak),
+        mib(low_retained),
+        mib(low_peak)
+    );
+    assert!(low_retained < default_retained);
+    assert!(low_peak <= default_peak);
+}
@@ -0,0 +1,302 @@
+use code_navigator::lsp::{path_to_uri, read_message, serve, write_message};
+use serde_json::{json, Value};
+use std::collections::HashMap;
+use std::fs;
+use std::io::Cursor;
+use std::path::{Path, PathBuf};
+
+fn fixture_dir(name: &str) -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join(name)
+        .canonicalize()
+        .unwrap()
+}
+
+/// Run a session of requests and notifications, ending with shutdown and exit, and
+/// return the results by request id
+fn session(root: &Path, messages: Vec<Value>) -> HashMap<u64, Value> {
+    let mut input = Vec::new();
+    let initialize = json!({
+        "jsonrpc": "2.0",
+        "id": 0,
+        "method": "initialize",
+        "params": { "processId": null, "rootUri": path_to_uri(root), "capabilities": {} },
+    });
+    let initialized = json!({ "jsonrpc": "2.0", "method": "initialized", "params": {} });
+    let shutdown = json!({ "jsonrpc": "2.0", "id": 999, "method": "shutdown" });
+    let exit = json!({ "jsonrpc": "2.0", "method": "exit" });
+    for message in [initialize, initialized]
+        .into_iter()
+        .chain(messages)
+        .chain([shutdown, exit])
+    {
+        write_message(&mut input, &message).unwrap();
+    }
+
+    let mut output = Vec::new();
+    serve(Cursor::new(input), &mut output).unwrap();
+
+    let mut responses = HashMap::new();
+    let mut output = Cursor::new(output);
+    while let Some(response) = read_message(&mut output).unwrap() {
+        assert!(response.get("error").is_none(), "{}", response);
+        responses.insert(response["id"].as_u64().unwrap(), response["result"].clone());
+    }
+    responses
+}
+
+fn request(id: u64, method: &str, params: Value) -> Value {
+    json!({ "jsonrpc": "2.0", "id": id, "method": method, "params": params })
+}
+
+fn at(file: &Path, line: u64, character: u64) -> Value {
+    json!({
+        "textDocument": { "uri": path_to_uri(file) },
+        "position": { "line": line, "character": character },
+    })
+}
+
+fn symbol_names(symbols: &Value) -> Vec<&str> {
+    symbols
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|symbol| symbol["name"].as_str().unwrap())
+        .collect()
+}
+
+#[test]
+fn test_document_symbols_after_initialize() {
+    let root = fixture_dir("simple-go");
+    let main_go = root.join("main.go");
+    let responses = session(
+        &root,
+        vec![request(
+            1,
+            "textDocument/documentSymbol",
+            json!({ "textDocument": { "uri": path_to_uri(&main_go) } }),
+        )],
+    );
+
+    let capabilities = &responses[&0]["capabilities"];
+    assert_eq!(capabilities["documentSymbolProvider"], true);
+    assert_eq!(capabilities["callHierarchyProvider"], true);
+
+    let symbols = &responses[&1];
+    let names = symbol_names(symbols);
+    for name in ["Add", "Multiply", "Greet"] {
+        assert!(names.contains(&name), "{} missing from {:?}", name, names);
+    }
+
+    // func Add(a int, b int) int { on line 6 of the file, after its doc comment
+    let add = &symbols[0];
+    assert_eq!(add["name"], "Add");
+    assert_eq!(add["kind"], 12);
+    assert_eq!(
+        add["selectionRange"],
+        json!({ "start": { "line": 5, "character": 5 }, "end": { "line": 5, "character": 8 } })
+    );
+    assert_eq!(add["range"]["start"], json!({ "line": 4, "character": 0 }));
+    assert_eq!(add["range"]["end"], json!({ "line": 7, "character": 1 }));
+}
+
+#[test]
+fn test_definition_and_call_hierarchy() {
+    let root = fixture_dir("simple-go");
+    let main_go = root.join("main.go");
+    let responses = session(
+        &root,
+        vec![
+            // `sum := Add(5, 3)` in main
+            request(1, "textDocument/definition", at(&main_go, 30, 9)),
+            // `func main() {`
+            request(2, "textDocument/prepareCallHierarchy", at(&main_go, 29, 6)),
+        ],
+    );
+
+    assert_eq!(
+        responses[&1],
+        json!([{
+            "uri": path_to_uri(&main_go),
+            "range": { "start": { "line": 5, "character": 5 }, "end": { "line": 5, "character": 8 } },
+        }])
+    );
+
+    let item = responses[&2][0].clone();
+    assert_eq!(item["name"], "main");
+    let responses = session(
+        &root,
+        vec![
+            request(1, "callHierarchy/outgoingCalls", json!({ "item": item })),
+            request(2, "callHierarchy/incomingCalls", json!({ "item": item })),
+        ],
+    );
+
+    // fmt.Printf is outside the index; Greet, Add and Multiply are in main.go
+    let callees: Vec<&str> = responses[&1]
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|call| call["to"]["name"].as_str().unwrap())
+        .collect();
+    assert_eq!(callees, vec!["Add", "Multiply", "Greet"]);
+    assert!(responses[&2].as_array().unwrap().is_empty());
+}
+
+#[test]
+fn test_positions_count_utf16_units() {
+    let dir = tempfile::tempdir().unwrap();
+    let root = dir.path().canonicalize().unwrap();
+    let main_go = root.join("main.go");
+    fs::write(
+        &main_go,
+        "package main\n\nfunc Add(a, b int) int { return a + b }\n\nfunc main() {\n\tprintln(\"日本語\", Add(1, 2))\n}\n",
+    )
+    .unwrap();
+
+    // Add starts at byte 22 of the line but UTF-16 character 16
+    let responses = session(
+        &root,
+        vec![
+            request(1, "textDocument/definition", at(&main_go, 5, 16)),
+            request(
+                2,
+                "textDocument/references",
+                json!({
+                    "textDocument": { "uri": path_to_uri(&main_go) },
+                    "position": { "line": 2, "character": 6 },
+                    "context": { "includeDeclaration": false },
+                }),
+            ),
+        ],
+    );
+
+    assert_eq!(
+        responses[&1][0]["range"],
+        json!({ "start": { "line": 2, "character": 5 }, "end": { "line": 2, "character": 8 } })
+    );
+    assert_eq!(
+        responses[&2],
+        json!([{
+            "uri": path_to_uri(&main_go),
+            "range": { "start": { "line": 5, "character": 16 }, "end": { "line": 5, "character": 19 } },
+        }])
+    );
+}
+
+#[test]
+fn test_reference_ranges_match_whole_identifiers() {
+    let dir = tempfile::tempdir().unwrap();
+    let root = dir.path().canonicalize().unwrap();
+    let main_go = root.join("main.go");
+    fs::write(
+        &main_go,
+        "package main\n\ntype T struct{}\n\nfunc (t T) Add() {}\n\nfunc (Adder T) Run() {\n\tAdder.Add()\n}\n",
+    )
+    .unwrap();
+
+    // The call starts at `Adder`, whose first three letters are not the method
+    let responses = session(
+        &root,
+        vec![request(
+            1,
+            "textDocument/references",
+            json!({
+                "textDocument": { "uri": path_to_uri(&main_go) },
+                "position": { "line": 4, "character": 12 },
+                "context": { "includeDeclaration": false },
+            }),
+        )],
+    );
+
+    assert_eq!(
+        responses[&1],
+        json!([{
+            "uri": path_to_uri(&main_go),
+            "range": { "start": { "line": 7, "character": 7 }, "end": { "line": 7, "character": 10 } },
+        }])
+    );
+}
+
+#[test]
+fn test_did_change_reindexes_the_open_buffer() {
+    let dir = tempfile::tempdir().unwrap();
+    let root = dir.path().canonicalize().unwrap();
+    let main_go = root.join("main.go");
+    fs::write(&main_go, "package main\n\nfunc main() {}\n").unwrap();
+
+    let uri = path_to_uri(&main_go);
+    let edited =
+        "package main\n\nfunc main() { Sub(2, 1) }\n\nfunc Sub(a, b int) int { return a - b }\n";
+    let responses = session(
+        &root,
+        vec![
+            json!({
+                "jsonrpc": "2.0",
+                "method": "textDocument/didOpen",
+                "params": { "textDocument": {
+                    "uri": uri, "languageId": "go", "version": 1,
+                    "text": "package main\n\nfunc main() {}\n",
+                } },
+            }),
+            json!({
+                "jsonrpc": "2.0",
+                "method": "textDocument/didChange",
+                "params": {
+                    "textDocument": { "uri": uri, "version": 2 },
+                    "contentChanges": [{ "text": edited }],
+                },
+            }),
+            request(
+                1,
+                "textDocument/documentSymbol",
+                json!({ "textDocument": { "uri": uri } }),
+            ),
+            request(2, "textDocument/definition", at(&main_go, 2, 15)),
+        ],
+    );
+
+    // The edit is indexed without being saved
+    assert_eq!(symbol_names(&responses[&1]), vec!["main", "Sub"]);
+    assert_eq!(
+        responses[&2][0]["range"]["start"],
+        json!({ "line": 4, "character": 5 })
+    );
+}
+
+#[test]
+fn test_document_symbols_nest_methods_under_their_type() {
+    let root = fixture_dir("simple-go");
+    let calculator_go = root.join("calculator.go");
+    let responses = session(
+        &root,
+        vec![request(
+            1,
+            "textDocument/documentSymbol",
+            json!({ "textDocument": { "uri": path_to_uri(&calculator_go) } }),
+        )],
+    );
+
+    let symbols = &responses[&1];
+    assert_eq!(
+        symbol_names(symbols),
+        vec![
+            "Calculator",
+            "NewCalculator",
+            "Logger",
+            "Recorder",
+            "logger"
+        ]
+    );
+    assert_eq!(
+        symbol_names(&symbols[0]["children"]),
+        vec!["name", "Add", "Subtract", "LogOperation", "Name", "SetName"]
+    );
+    // func (c *Calculator) Add(a, b int) int { on line 17, after its doc comment
+    let add = &symbols[0]["children"][1];
+    assert_eq!(add["kind"], 6);
+    assert_eq!(add["range"]["start"]["line"], 15);
+    assert_eq!(add["range"]["end"]["line"], 20);
+}
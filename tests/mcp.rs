@@ -0,0 +1,280 @@
+use code_navigator::mcp::{serve, McpOptions};
+use code_navigator::schema::SCHEMA_VERSION;
+use serde_json::{json, Value};
+use std::collections::{HashMap, VecDeque};
+use std::fs;
+use std::io::{self, BufRead, BufReader, Cursor, Read};
+use std::path::{Path, PathBuf};
+
+fn fixture_dir(name: &str) -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join(name)
+}
+
+/// Send `initialize` and `messages` in one session and return the responses by id
+fn session(root: &Path, options: &McpOptions, messages: Vec<Value>) -> HashMap<u64, Value> {
+    let initialize = json!({
+        "jsonrpc": "2.0",
+        "id": 0,
+        "method": "initialize",
+        "params": {
+            "protocolVersion": "2024-11-05",
+            "capabilities": {},
+            "clientInfo": { "name": "test", "version": "0" },
+        },
+    });
+    let initialized = json!({ "jsonrpc": "2.0", "method": "notifications/initialized" });
+    let mut input = String::new();
+    for message in [initialize, initialized].into_iter().chain(messages) {
+        input.push_str(&message.to_string());
+        input.push('\n');
+    }
+
+    run(root, options, Cursor::new(input))
+}
+
+fn run(root: &Path, options: &McpOptions, input: impl BufRead) -> HashMap<u64, Value> {
+    let mut output = Vec::new();
+    serve(root, options, input, &mut output).unwrap();
+
+    String::from_utf8(output)
+        .unwrap()
+        .lines()
+        .map(|line| {
+            let response: Value = serde_json::from_str(line).unwrap();
+            (response["id"].as_u64().unwrap(), response)
+        })
+        .collect()
+}
+
+fn call(id: u64, tool: &str, arguments: Value) -> Value {
+    json!({
+        "jsonrpc": "2.0",
+        "id": id,
+        "method": "tools/call",
+        "params": { "name": tool, "arguments": arguments },
+    })
+}
+
+/// The JSON a successful tool call returned as text
+fn tool_result(response: &Value) -> Value {
+    let result = &response["result"];
+    assert_ne!(result["isError"], true, "{}", response);
+    serde_json::from_str(result["content"][0]["text"].as_str().unwrap()).unwrap()
+}
+
+#[test]
+fn test_tools_answer_against_the_fixture() {
+    let responses = session(
+        &fixture_dir("simple-go"),
+        &McpOptions::default(),
+        vec![
+            json!({ "jsonrpc": "2.0", "id": 1, "method": "tools/list" }),
+            call(2, "find_symbol", json!({ "name": "Multiply" })),
+            call(3, "get_call_graph", json!({ "symbol": "main" })),
+            call(
+                4,
+                "get_call_graph",
+                json!({ "symbol": "PrintMessage", "direction": "callers", "depth": 3 }),
+            ),
+            call(
+                5,
+                "find_references",
+                json!({ "symbol": "Add", "kind": "call" }),
+            ),
+            call(6, "get_source", json!({ "symbol": "Add" })),
+            call(7, "get_source", json!({ "symbol": "Add", "max_bytes": 30 })),
+        ],
+    );
+
+    assert_eq!(responses[&0]["result"]["protocolVersion"], "2024-11-05");
+    assert!(responses[&0]["result"]["capabilities"]["tools"].is_object());
+
+    let tools = responses[&1]["result"]["tools"].as_array().unwrap();
+    let names: Vec<&str> = tools.iter().map(|t| t["name"].as_str().unwrap()).collect();
+    assert_eq!(
+        names,
+        vec![
+            "find_symbol",
+            "get_call_graph",
+            "find_references",
+            "get_source",
+            "reindex"
+        ]
+    );
+    for tool in tools {
+        assert_eq!(tool["inputSchema"]["type"], "object", "{}", tool["name"]);
+    }
+
+    let symbols = tool_result(&responses[&2]);
+    assert_eq!(symbols[0]["name"], "Multiply");
+    assert_eq!(symbols[0]["match_kind"], "exact");
+    assert_eq!(symbols[0]["location"]["line"], 11);
+
+    // Objects carry the version of the schema they follow, as `--json` output does
+    let graph = tool_result(&responses[&3]);
+    assert_eq!(graph["schema_version"], SCHEMA_VERSION);
+    assert_eq!(graph["symbol"]["name"], "main");
+    let callees: Vec<&str> = graph["calls"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|call| call["callee"].as_str().unwrap())
+        .collect();
+    for name in ["Add", "Multiply", "Greet"] {
+        assert!(
+            callees.contains(&name),
+            "{} missing from {:?}",
+            name,
+            callees
+        );
+    }
+    assert!(graph["calls"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .all(|c| c["depth"] == 1));
+
+    let callers = tool_result(&responses[&4]);
+    let direct: Vec<&str> = callers["callers"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .filter(|site| site["depth"] == 1)
+        .map(|site| site["from_name"].as_str().unwrap())
+        .collect();
+    assert_eq!(direct, vec!["(*Calculator).LogOperation", "Greet.func1"]);
+    assert!(callers["callers"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .any(|site| site["from_name"] == "main" && site["depth"] == 3));
+
+    let references = tool_result(&responses[&5]);
+    let references = references["references"].as_array().unwrap();
+    assert!(references.iter().all(|r| r["kind"] == "call"));
+    let from_main: Vec<&Value> = references
+        .iter()
+        .filter(|r| r["from_name"] == "main")
+        .collect();
+    assert_eq!(from_main.len(), 1);
+    assert_eq!(from_main[0]["code"], "Add(5, 3)");
+    assert_eq!(from_main[0]["location"]["line"], 31);
+    assert_eq!(
+        references
+            .iter()
+            .filter(|r| r["from_name"] == "Multiply")
+            .count(),
+        2
+    );
+
+    let source = tool_result(&responses[&6]);
+    assert_eq!(
+        source["code"],
+        "func Add(a int, b int) int {\n\treturn a + b\n}"
+    );
+    assert_eq!(source["truncated"], false);
+    assert_eq!(source["symbol"]["end_line"], 8);
+
+    // Clipped at the line break that fits
+    let clipped = tool_result(&responses[&7]);
+    assert_eq!(clipped["code"], "func Add(a int, b int) int {");
+    assert_eq!(clipped["truncated"], true);
+}
+
+#[test]
+fn test_tool_failures_are_results() {
+    let responses = session(
+        &fixture_dir("simple-go"),
+        &McpOptions::default(),
+        vec![
+            call(1, "get_source", json!({ "symbol": "NoSuchFunction" })),
+            call(2, "get_call_graph", json!({})),
+            call(3, "no_such_tool", json!({})),
+            json!({ "jsonrpc": "2.0", "id": 4, "method": "no/such/method" }),
+        ],
+    );
+
+    // The model sees failures inside a tool as text it can act on
+    let unknown = &responses[&1]["result"];
+    assert_eq!(unknown["isError"], true);
+    assert!(unknown["content"][0]["text"]
+        .as_str()
+        .unwrap()
+        .contains("NoSuchFunction"));
+    assert!(responses[&2]["result"]["content"][0]["text"]
+        .as_str()
+        .unwrap()
+        .contains("Missing required argument: symbol"));
+
+    // Unknown tools and methods are protocol errors
+    assert_eq!(responses[&3]["error"]["code"], -32602);
+    assert_eq!(responses[&4]["error"]["code"], -32601);
+}
+
+#[test]
+fn test_indexing_is_lazy_and_reindex_picks_up_changes() {
+    // Listing tools never touches the directory, so a missing one only fails on use
+    let responses = session(
+        Path::new("/nonexistent/codenav-mcp"),
+        &McpOptions::default(),
+        vec![
+            json!({ "jsonrpc": "2.0", "id": 1, "method": "tools/list" }),
+            call(2, "find_symbol", json!({ "name": "Add" })),
+        ],
+    );
+    assert!(responses[&1]["result"]["tools"].is_array());
+    assert_eq!(responses[&2]["result"]["isError"], true);
+
+    let dir = tempfile::tempdir().unwrap();
+    let main_go = dir.path().join("main.go");
+    fs::write(&main_go, "package main\n\nfunc main() {}\n").unwrap();
+
+    let edit = {
+        let main_go = main_go.clone();
+        move || {
+            let source = "package main\n\nfunc main() { Sub(2, 1) }\n\nfunc Sub(a, b int) int { return a - b }\n";
+            fs::write(&main_go, source).unwrap();
+        }
+    };
+    let script = Script(VecDeque::from([
+        step(|| {}, call(1, "find_symbol", json!({ "name": "Sub" }))),
+        // Edited once the first call has been answered
+        step(edit, call(2, "find_symbol", json!({ "name": "Sub" }))),
+        step(|| {}, call(3, "reindex", json!({}))),
+        step(|| {}, call(4, "find_symbol", json!({ "name": "Sub" }))),
+    ]));
+    let responses = run(dir.path(), &McpOptions::default(), BufReader::new(script));
+
+    assert_eq!(tool_result(&responses[&1]), json!([]));
+    // The graph is kept between calls until asked to reindex
+    assert_eq!(tool_result(&responses[&2]), json!([]));
+    let summary = tool_result(&responses[&3]);
+    assert_eq!(summary["files_parsed"], 1);
+    assert_eq!(summary["symbols"], 2);
+    assert_eq!(tool_result(&responses[&4])[0]["name"], "Sub");
+}
+
+type Step = (Box<dyn FnOnce()>, String);
+
+fn step(action: impl FnOnce() + 'static, message: Value) -> Step {
+    (Box::new(action), format!("{}\n", message))
+}
+
+/// Input that runs each step's action just before the server reads its message,
+/// after everything earlier has been answered
+struct Script(VecDeque<Step>);
+
+impl Read for Script {
+    fn read(&mut self, buf: &mut [u8]) -> io::Result<usize> {
+        let Some((action, message)) = self.0.pop_front() else {
+            return Ok(0);
+        };
+        action();
+        let bytes = message.as_bytes();
+        buf[..bytes.len()].copy_from_slice(bytes);
+        Ok(bytes.len())
+    }
+}
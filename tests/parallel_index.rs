@@ -0,0 +1,106 @@
+use code_navigator::core::{CodeGraph, Severity};
+use code_navigator::parser::{self, GoParser, READ_ERROR};
+use std::fs;
+use std::path::Path;
+use std::time::{Duration, Instant};
+
+/// Write a package of `files` files, each with a type, a method and a function calling
+/// into the next file, so resolution has cross-file work to do
+fn write_package(dir: &Path, files: usize) {
+    fs::write(dir.join("go.mod"), "module example.com/bench\n\ngo 1.21\n").unwrap();
+    for i in 0..files {
+        let next = (i + 1) % files;
+        let source = format!(
+            "package main\n\n\
+             type T{i} struct{{ n int }}\n\n\
+             func (t *T{i}) Step() int {{ return t.n + F{next}() }}\n\n\
+             // F{i} calls into the next file\n\
+             func F{i}() int {{\n\
+             \tt := &T{next}{{}}\n\
+             \treturn t.Step()\n\
+             }}\n"
+        );
+        fs::write(dir.join(format!("file{:04}.go", i)), source).unwrap();
+    }
+}
+
+fn index(dir: &Path, jobs: Option<usize>) -> CodeGraph {
+    parser::with_jobs(jobs, || {
+        let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "go".to_string());
+        GoParser::new()
+            .unwrap()
+            .parse_directory(dir, &mut graph)
+            .unwrap();
+        graph
+    })
+    .unwrap()
+}
+
+/// The serialized graph, minus the timestamp that differs between any two runs
+fn serialized(mut graph: CodeGraph) -> String {
+    graph.metadata.generated_at = String::new();
+    serde_json::to_string(&graph).unwrap()
+}
+
+#[test]
+fn test_parallel_index_matches_sequential() {
+    let dir = tempfile::tempdir().unwrap();
+    write_package(dir.path(), 64);
+
+    let sequential = serialized(index(dir.path(), Some(1)));
+    assert_eq!(sequential, serialized(index(dir.path(), Some(4))));
+    assert_eq!(sequential, serialized(index(dir.path(), None)));
+}
+
+#[test]
+fn test_unreadable_files_are_reported_as_diagnostics() {
+    let dir = tempfile::tempdir().unwrap();
+    write_package(dir.path(), 4);
+    // A directory named like a Go file can't be read as one
+    fs::create_dir(dir.path().join("broken.go")).unwrap();
+
+    let graph = index(dir.path(), Some(4));
+    let errors: Vec<_> = graph
+        .metadata
+        .diagnostics
+        .iter()
+        .filter(|d| d.severity == Severity::Error)
+        .collect();
+    assert_eq!(errors.len(), 1);
+    assert_eq!(errors[0].code, READ_ERROR);
+    assert_eq!(errors[0].file_path, dir.path().join("broken.go"));
+
+    // The readable files are indexed as usual
+    assert_eq!(graph.metadata.stats.files_parsed, 4);
+    assert_eq!(graph.find_nodes_by_symbol("F3").len(), 1);
+}
+
+/// Compare indexing times on one thread and on every core:
+/// `cargo test --release --test parallel_index -- --ignored --nocapture`
+#[test]
+#[ignore]
+fn bench_parallel_index_speedup() {
+    let dir = tempfile::tempdir().unwrap();
+    write_package(dir.path(), 5000);
+
+    let time = |jobs| {
+        let start = Instant::now();
+        let graph = index(dir.path(), jobs);
+        (start.elapsed(), graph.nodes.len())
+    };
+    let (sequential, nodes) = time(Some(1));
+    let (parallel, parallel_nodes) = time(None);
+    assert_eq!(nodes, parallel_nodes);
+
+    let cores = std::thread::available_parallelism().map_or(1, |n| n.get());
+    println!(
+        "5000 files: {:?} on 1 thread, {:?} on {} ({:.1}x)",
+        sequential,
+        parallel,
+        cores,
+        sequential.as_secs_f64() / parallel.as_secs_f64().max(f64::EPSILON)
+    );
+    if cores > 1 {
+        assert!(parallel + Duration::from_millis(10) < sequential);
+    }
+}
@@ -0,0 +1,247 @@
+use code_navigator::core::{CodeGraph, NodeType};
+use code_navigator::parser::{self, GoParser, PythonParser};
+use std::fs;
+use std::path::{Path, PathBuf};
+
+fn fixture_dir(name: &str) -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join(name)
+}
+
+fn index_dir(dir: &Path) -> CodeGraph {
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "python".to_string());
+    let mut parser = PythonParser::new().unwrap();
+    parser.parse_directory(dir, &mut graph).unwrap();
+    graph
+}
+
+/// The names each call of `name` leads to, the name written where it is unresolved
+fn callees_of(graph: &CodeGraph, name: &str) -> Vec<String> {
+    let node = graph
+        .get_nodes_by_name(name)
+        .into_iter()
+        .next()
+        .unwrap_or_else(|| panic!("node {} not found", name));
+    graph
+        .get_outgoing_edges(&node.id)
+        .iter()
+        .map(|edge| match graph.edge_targets(edge).first() {
+            Some(target) => target.name.clone(),
+            None => edge.to.clone(),
+        })
+        .collect()
+}
+
+#[test]
+fn test_functions_classes_and_methods() {
+    let dir = fixture_dir("simple-python");
+    let graph = index_dir(&dir);
+
+    let class = graph.get_nodes_by_name("Calculator");
+    assert_eq!(class.len(), 1);
+    assert_eq!(class[0].node_type, NodeType::Class);
+    assert_eq!(class[0].line, 39);
+    assert_eq!(
+        class[0].documentation.as_deref(),
+        Some("Calculator class with instance methods.")
+    );
+
+    // The method stays distinct from the module-level function of the same name
+    let method = graph.get_nodes_by_name("Calculator.add");
+    assert_eq!(method.len(), 1);
+    assert_eq!(method[0].node_type, NodeType::Method);
+    assert_eq!(
+        method[0].metadata.get("receiver_type").unwrap(),
+        "Calculator"
+    );
+    assert_eq!(method[0].metadata.get("method").unwrap(), "add");
+    assert_eq!(method[0].signature, "def add(self, a: int, b: int) -> int");
+    let params: Vec<_> = method[0].parameters.iter().map(|p| &p.name).collect();
+    assert_eq!(params, ["a", "b"]);
+
+    let func = graph.get_nodes_by_name("add");
+    assert_eq!(func.len(), 1);
+    assert_eq!(
+        (func[0].node_type.clone(), func[0].line),
+        (NodeType::Function, 3)
+    );
+
+    let fetch = &graph.get_nodes_by_name("fetch_user")[0];
+    assert_eq!(
+        fetch.signature,
+        "async def fetch_user(user_id: int) -> Dict[str, Any]"
+    );
+    assert_eq!(
+        fetch.documentation.as_deref(),
+        Some("Fetch user data asynchronously.")
+    );
+
+    // A decorated function's span starts at the decorator
+    let greet = graph
+        .get_nodes_by_name("greet")
+        .into_iter()
+        .find(|n| n.file_path == dir.join("async_utils.py"))
+        .unwrap();
+    assert_eq!(greet.line, 47);
+    assert_eq!(greet.span.unwrap().start.line, 46);
+}
+
+#[test]
+fn test_outline_nests_methods_under_their_class() {
+    let dir = fixture_dir("simple-python");
+    let graph = index_dir(&dir);
+
+    let outline = graph.outline(&dir.join("calculator.py"));
+    let top: Vec<_> = outline.iter().map(|e| e.node.name.as_str()).collect();
+    assert_eq!(
+        top,
+        [
+            "add",
+            "subtract",
+            "multiply",
+            "divide",
+            "power",
+            "Calculator"
+        ]
+    );
+    let methods: Vec<_> = outline[5]
+        .children
+        .iter()
+        .map(|e| e.node.name.as_str())
+        .collect();
+    assert_eq!(
+        methods,
+        [
+            "Calculator.__init__",
+            "Calculator.add",
+            "Calculator.subtract",
+            "Calculator.multiply",
+            "Calculator.log_operation",
+            "Calculator.get_history",
+        ]
+    );
+}
+
+#[test]
+fn test_calls_resolve_within_the_file() {
+    let dir = fixture_dir("simple-python");
+    let graph = index_dir(&dir);
+
+    // A bare call inside a method is the module's function; self.m() is the class's method
+    assert_eq!(
+        callees_of(&graph, "Calculator.add"),
+        ["add", "Calculator.log_operation"]
+    );
+    let call = graph.get_outgoing_edges(&graph.get_nodes_by_name("Calculator.add")[0].id)[0];
+    assert_eq!(
+        call.metadata.get("target_id"),
+        Some(&graph.get_nodes_by_name("add")[0].id)
+    );
+    assert_eq!(graph.callers("Calculator.log_operation").len(), 3);
+
+    assert_eq!(callees_of(&graph, "even"), ["odd"]);
+    assert_eq!(callees_of(&graph, "odd"), ["even"]);
+    assert_eq!(callees_of(&graph, "factorial"), ["factorial"]);
+}
+
+#[test]
+fn test_imported_functions_match_by_name_and_modules_stay_external() {
+    let dir = fixture_dir("simple-python");
+    let graph = index_dir(&dir);
+
+    // add comes from calculator.py, which the file doesn't resolve into itself
+    assert_eq!(callees_of(&graph, "hypotenuse"), ["sqrt", "add"]);
+    let hypotenuse = &graph.get_nodes_by_name("hypotenuse")[0];
+    let sqrt = graph.get_outgoing_edges(&hypotenuse.id)[0];
+    assert_eq!(sqrt.metadata.get("qualifier").unwrap(), "math");
+    assert_eq!(sqrt.metadata.get("import_path").unwrap(), "math");
+    assert!(graph.edge_targets(sqrt).is_empty());
+
+    let fetch = &graph.get_nodes_by_name("fetch_data")[0];
+    let sleep = graph.get_outgoing_edges(&fetch.id)[0];
+    assert_eq!(sleep.to, "sleep");
+    assert_eq!(sleep.metadata.get("import_path").unwrap(), "asyncio");
+}
+
+#[test]
+fn test_nested_functions_are_named_by_their_enclosing_function() {
+    let dir = fixture_dir("simple-python");
+    let graph = index_dir(&dir);
+
+    let wrapper = graph.get_nodes_by_name("decorator_example.wrapper");
+    assert_eq!(wrapper.len(), 1);
+    assert_eq!(
+        wrapper[0].metadata.get("enclosing").unwrap(),
+        "decorator_example"
+    );
+    // The decorator's own calls leave out the wrapper's
+    assert!(callees_of(&graph, "decorator_example").is_empty());
+    assert_eq!(
+        callees_of(&graph, "decorator_example.wrapper"),
+        ["print", "func", "print"]
+    );
+
+    let outline = graph.outline(&dir.join("async_utils.py"));
+    assert!(outline
+        .iter()
+        .all(|e| e.node.name != "decorator_example.wrapper"));
+}
+
+#[test]
+fn test_calls_are_not_matched_across_languages() {
+    let dir = tempfile::tempdir().unwrap();
+    fs::write(
+        dir.path().join("util.go"),
+        "package util\n\nfunc helper() {}\n",
+    )
+    .unwrap();
+    fs::write(dir.path().join("run.py"), "def run():\n    helper()\n").unwrap();
+
+    let mut graph = CodeGraph::new(dir.path().to_string_lossy().to_string(), "go,python".into());
+    GoParser::new()
+        .unwrap()
+        .parse_directory(dir.path(), &mut graph)
+        .unwrap();
+    PythonParser::new()
+        .unwrap()
+        .parse_directory(dir.path(), &mut graph)
+        .unwrap();
+
+    assert_eq!(graph.get_nodes_by_name("helper").len(), 1);
+    let run = &graph.get_nodes_by_name("run")[0];
+    let call = graph.get_outgoing_edges(&run.id)[0];
+    assert!(graph.edge_targets(call).is_empty());
+}
+
+#[test]
+fn test_backend_is_chosen_by_extension() {
+    assert_eq!(
+        parser::for_path(Path::new("a.py")).unwrap().language(),
+        "python"
+    );
+    assert_eq!(
+        parser::for_path(Path::new("a.go")).unwrap().language(),
+        "go"
+    );
+    assert_eq!(
+        parser::for_path(Path::new("a.tsx")).unwrap().language(),
+        "typescript"
+    );
+    assert!(parser::for_path(Path::new("a.rb")).is_err());
+
+    let paths = [
+        PathBuf::from("a.py"),
+        PathBuf::from("b.go"),
+        PathBuf::from("c.txt"),
+    ];
+    assert_eq!(parser::languages_in(&paths), ["go", "python"]);
+
+    let source = fs::read_to_string(fixture_dir("simple-python").join("calculator.py")).unwrap();
+    let graph = parser::for_path(Path::new("calculator.py"))
+        .unwrap()
+        .index_standalone(Path::new("calculator.py"), &source);
+    assert_eq!(graph.get_nodes_by_name("Calculator.get_history").len(), 1);
+    assert!(graph.metadata.diagnostics.is_empty());
+}
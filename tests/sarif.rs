@@ -0,0 +1,264 @@
+use code_navigator::core::findings::{self, Finding};
+use code_navigator::core::{CodeGraph, DeadCodeOptions};
+use code_navigator::parser::GoParser;
+use code_navigator::serializer::sarif;
+use serde_json::Value;
+use std::collections::HashSet;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// simple-go copied to `app/` of a fresh repository, so URIs are relative to a known root
+fn index_repo() -> (tempfile::TempDir, CodeGraph) {
+    let repo = tempfile::tempdir().unwrap();
+    fs::create_dir(repo.path().join(".git")).unwrap();
+    let app = repo.path().join("app");
+    fs::create_dir(&app).unwrap();
+    let fixture = Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/fixtures/simple-go");
+    for entry in fs::read_dir(fixture).unwrap() {
+        let path = entry.unwrap().path();
+        fs::copy(&path, app.join(path.file_name().unwrap())).unwrap();
+    }
+
+    let mut graph = CodeGraph::new(app.to_string_lossy().to_string(), "go".to_string());
+    GoParser::new()
+        .unwrap()
+        .parse_directory(&app, &mut graph)
+        .unwrap();
+    (repo, graph)
+}
+
+fn render(rule: findings::Rule, findings: &[Finding], graph: &CodeGraph) -> Value {
+    let log = sarif::render(&[rule], findings, &PathBuf::from(&graph.metadata.root_path)).unwrap();
+    let log: Value = serde_json::from_str(&log).unwrap();
+    validate(&log);
+    log
+}
+
+/// Fail unless every key of `object` is one the SARIF 2.1.0 schema allows there; the
+/// schema forbids additional properties on the objects emitted here
+fn keys(object: &Value, allowed: &[&str], required: &[&str]) {
+    let object = object
+        .as_object()
+        .unwrap_or_else(|| panic!("{} is not an object", object));
+    for key in object.keys() {
+        assert!(
+            allowed.contains(&key.as_str()),
+            "unexpected property {}",
+            key
+        );
+    }
+    for key in required {
+        assert!(
+            object.contains_key(*key),
+            "missing required property {}",
+            key
+        );
+    }
+}
+
+fn positive(value: &Value, name: &str) -> u64 {
+    let n = value[name]
+        .as_u64()
+        .unwrap_or_else(|| panic!("{} is not an integer", name));
+    assert!(n >= 1, "{} must be at least 1", name);
+    n
+}
+
+fn validate_physical_location(location: &Value) {
+    keys(location, &["artifactLocation", "region"], &[]);
+    let artifact = &location["artifactLocation"];
+    keys(artifact, &["uri", "uriBaseId"], &["uri"]);
+    let uri = artifact["uri"].as_str().unwrap();
+    assert!(
+        !uri.contains(' ') && !uri.contains('\\'),
+        "{} is not a URI",
+        uri
+    );
+    if artifact.get("uriBaseId").is_some() {
+        assert!(
+            !uri.starts_with('/'),
+            "{} must be relative to its base",
+            uri
+        );
+    } else {
+        assert!(uri.starts_with("file://"), "{} must be absolute", uri);
+    }
+
+    let region = &location["region"];
+    keys(
+        region,
+        &["startLine", "startColumn", "endLine", "endColumn"],
+        &[],
+    );
+    let start = positive(region, "startLine");
+    if region.get("startColumn").is_some() {
+        positive(region, "startColumn");
+    }
+    if region.get("endLine").is_some() {
+        assert!(positive(region, "endLine") >= start);
+    }
+    if region.get("endColumn").is_some() {
+        positive(region, "endColumn");
+    }
+}
+
+/// Check the log against the constraints the SARIF 2.1.0 schema puts on everything
+/// `sarif::render` writes: required properties, enumerations and minimums
+fn validate(log: &Value) {
+    keys(log, &["$schema", "version", "runs"], &["version", "runs"]);
+    assert_eq!(log["version"], "2.1.0");
+    let levels = ["none", "note", "warning", "error"];
+
+    for run in log["runs"].as_array().unwrap() {
+        keys(run, &["tool", "results"], &["tool"]);
+        keys(&run["tool"], &["driver"], &["driver"]);
+        let driver = &run["tool"]["driver"];
+        keys(
+            driver,
+            &["name", "version", "informationUri", "rules"],
+            &["name"],
+        );
+        let rules = driver["rules"].as_array().unwrap();
+        for rule in rules {
+            keys(
+                rule,
+                &["id", "name", "shortDescription", "defaultConfiguration"],
+                &["id"],
+            );
+            keys(&rule["shortDescription"], &["text"], &["text"]);
+            keys(&rule["defaultConfiguration"], &["level"], &[]);
+            assert!(levels.contains(&rule["defaultConfiguration"]["level"].as_str().unwrap()));
+        }
+
+        for result in run["results"].as_array().unwrap() {
+            keys(
+                result,
+                &[
+                    "ruleId",
+                    "ruleIndex",
+                    "level",
+                    "message",
+                    "locations",
+                    "relatedLocations",
+                ],
+                &["message"],
+            );
+            keys(&result["message"], &["text"], &["text"]);
+            assert!(levels.contains(&result["level"].as_str().unwrap()));
+            let index = result["ruleIndex"].as_u64().unwrap() as usize;
+            assert_eq!(rules[index]["id"], result["ruleId"]);
+
+            for location in result["locations"].as_array().unwrap() {
+                keys(location, &["physicalLocation"], &[]);
+                validate_physical_location(&location["physicalLocation"]);
+            }
+            let mut ids = HashSet::new();
+            for related in result["relatedLocations"]
+                .as_array()
+                .map(Vec::as_slice)
+                .unwrap_or_default()
+            {
+                keys(related, &["id", "message", "physicalLocation"], &[]);
+                assert!(ids.insert(related["id"].as_u64().unwrap()), "duplicate id");
+                validate_physical_location(&related["physicalLocation"]);
+            }
+        }
+    }
+}
+
+fn results(log: &Value) -> &Vec<Value> {
+    log["runs"][0]["results"].as_array().unwrap()
+}
+
+#[test]
+fn test_dead_code_as_sarif() {
+    let (_repo, graph) = index_repo();
+    let report = graph.dead_code(&DeadCodeOptions::default()).unwrap();
+    let log = render(findings::DEAD_CODE, &report.findings(), &graph);
+
+    let rules = &log["runs"][0]["tool"]["driver"]["rules"];
+    assert_eq!(rules[0]["id"], "dead-code");
+    assert_eq!(rules[0]["name"], "DeadCode");
+
+    let results = results(&log);
+    assert_eq!(results.len(), report.dead.len());
+    // The region is the function's name: `func NewCalculator(` on line 12
+    let first = &results[0];
+    assert_eq!(first["ruleId"], "dead-code");
+    assert_eq!(first["level"], "warning");
+    let location = &first["locations"][0]["physicalLocation"];
+    assert_eq!(location["artifactLocation"]["uri"], "app/calculator.go");
+    assert_eq!(location["artifactLocation"]["uriBaseId"], sarif::SRCROOT);
+    assert_eq!(location["region"]["startLine"], 12);
+    assert_eq!(location["region"]["startColumn"], 6);
+    assert_eq!(location["region"]["endColumn"], 19);
+    assert!(first.get("relatedLocations").is_none());
+}
+
+#[test]
+fn test_cycles_map_members_to_related_locations() {
+    let (_repo, graph) = index_repo();
+    let cycles: Vec<Finding> = graph.cycles().iter().map(|cycle| cycle.finding()).collect();
+    let log = render(findings::CALL_CYCLE, &cycles, &graph);
+
+    let results = results(&log);
+    assert_eq!(results.len(), 2);
+
+    // Even and Odd: reported at Even, related to both
+    let mutual = &results[0];
+    assert_eq!(mutual["message"]["text"], "Call cycle: Even → Odd → Even");
+    let related = mutual["relatedLocations"].as_array().unwrap();
+    let members: Vec<(u64, &str, u64)> = related
+        .iter()
+        .map(|location| {
+            (
+                location["id"].as_u64().unwrap(),
+                location["message"]["text"].as_str().unwrap(),
+                location["physicalLocation"]["region"]["startLine"]
+                    .as_u64()
+                    .unwrap(),
+            )
+        })
+        .collect();
+    assert_eq!(members, vec![(1, "Even", 44), (2, "Odd", 51)]);
+    for location in related {
+        assert_eq!(
+            location["physicalLocation"]["artifactLocation"]["uri"],
+            "app/main.go"
+        );
+    }
+
+    // Factorial calls itself: one location, nothing related
+    let recursive = &results[1];
+    assert_eq!(recursive["message"]["text"], "Factorial calls itself");
+    assert!(recursive.get("relatedLocations").is_none());
+}
+
+#[test]
+fn test_complexity_as_sarif() {
+    let (_repo, graph) = index_repo();
+    let complex = graph.complex_functions(1);
+    let log = render(
+        findings::HIGH_COMPLEXITY,
+        &findings::complexity_findings(&complex, 1),
+        &graph,
+    );
+
+    let names: Vec<&str> = results(&log)
+        .iter()
+        .map(|result| result["message"]["text"].as_str().unwrap())
+        .collect();
+    assert_eq!(
+        names,
+        vec![
+            "Multiply has a cyclomatic complexity of 2, above the threshold of 1",
+            "Even has a cyclomatic complexity of 2, above the threshold of 1",
+            "Odd has a cyclomatic complexity of 2, above the threshold of 1",
+            "Factorial has a cyclomatic complexity of 2, above the threshold of 1",
+        ]
+    );
+
+    // No findings is still a valid log describing the rule
+    let empty = render(findings::HIGH_COMPLEXITY, &[], &graph);
+    assert!(results(&empty).is_empty());
+}
@@ -0,0 +1,274 @@
+use code_navigator::core::{BindingKind, CodeGraph, NodeType};
+use code_navigator::parser::{Language, TypeScriptParser};
+use std::fs;
+use std::path::{Path, PathBuf};
+
+fn fixture_dir(name: &str) -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join(name)
+}
+
+fn index_dir(dir: &Path, language: Language) -> CodeGraph {
+    let mut graph = CodeGraph::new(dir.to_string_lossy().to_string(), "typescript".to_string());
+    let mut parser = TypeScriptParser::new(language).unwrap();
+    parser.parse_directory(dir, &mut graph).unwrap();
+    graph
+}
+
+/// The names each call of `name` leads to, the name written where it is unresolved
+fn callees_of(graph: &CodeGraph, name: &str) -> Vec<String> {
+    let node = graph
+        .get_nodes_by_name(name)
+        .into_iter()
+        .next()
+        .unwrap_or_else(|| panic!("node {} not found", name));
+    graph
+        .get_outgoing_edges(&node.id)
+        .iter()
+        .map(|edge| match graph.edge_targets(edge).first() {
+            Some(target) => target.name.clone(),
+            None => edge.to.clone(),
+        })
+        .collect()
+}
+
+#[test]
+fn test_functions_arrow_functions_and_classes() {
+    let dir = fixture_dir("simple-ts");
+    let graph = index_dir(&dir, Language::TypeScript);
+
+    let class = graph.get_nodes_by_name("Calculator");
+    assert_eq!(class.len(), 1);
+    assert_eq!(class[0].node_type, NodeType::Class);
+    assert_eq!(class[0].line, 54);
+    assert_eq!(class[0].signature, "export class Calculator");
+    assert_eq!(class[0].documentation.as_deref(), Some("Calculator class"));
+
+    let method = graph.get_nodes_by_name("Calculator.add");
+    assert_eq!(method.len(), 1);
+    assert_eq!(method[0].node_type, NodeType::Method);
+    assert_eq!(
+        method[0].metadata.get("receiver_type").unwrap(),
+        "Calculator"
+    );
+    assert_eq!(method[0].signature, "add(a: number, b: number): number");
+    assert_eq!(method[0].returns, ["number"]);
+
+    // An arrow function bound to a const is a function named after it
+    let power = &graph.get_nodes_by_name("power")[0];
+    assert_eq!(power.node_type, NodeType::Function);
+    assert_eq!(power.line, 41);
+    assert_eq!(
+        power.signature,
+        "export const power = (base: number, exponent: number): number"
+    );
+    let params: Vec<_> = power
+        .parameters
+        .iter()
+        .map(|p| (p.name.as_str(), p.param_type.as_str()))
+        .collect();
+    assert_eq!(params, [("base", "number"), ("exponent", "number")]);
+    assert_eq!(
+        power.documentation.as_deref(),
+        Some("Calculates power using repeated multiplication")
+    );
+
+    let outline = graph.outline(&dir.join("calculator.ts"));
+    let calculator = outline
+        .iter()
+        .find(|e| e.node.name == "Calculator")
+        .unwrap();
+    let methods: Vec<_> = calculator
+        .children
+        .iter()
+        .map(|e| e.node.name.as_str())
+        .collect();
+    assert_eq!(
+        methods,
+        [
+            "Calculator.constructor",
+            "Calculator.add",
+            "Calculator.subtract",
+            "Calculator.multiply",
+            "Calculator.logOperation",
+            "Calculator.getHistory",
+        ]
+    );
+}
+
+#[test]
+fn test_calls_resolve_within_the_file() {
+    let graph = index_dir(&fixture_dir("simple-ts"), Language::TypeScript);
+
+    // A bare call in a method is the module's function; this.m() is the class's method
+    assert_eq!(
+        callees_of(&graph, "Calculator.add"),
+        ["add", "Calculator.logOperation"]
+    );
+    assert_eq!(graph.callers("Calculator.logOperation").len(), 3);
+    assert_eq!(callees_of(&graph, "power"), ["multiply"]);
+
+    // Calls in anonymous callbacks belong to the function they are written in
+    assert_eq!(
+        callees_of(&graph, "fetchData"),
+        ["Promise", "setTimeout", "resolve"]
+    );
+}
+
+#[test]
+fn test_imports_resolve_across_files() {
+    let dir = fixture_dir("simple-ts");
+    let graph = index_dir(&dir, Language::TypeScript);
+
+    // Named, aliased, default, re-exported default, class and namespace through
+    // `export *`; `fs` is a package and stays external
+    assert_eq!(
+        callees_of(&graph, "run"),
+        [
+            "add",
+            "multiply",
+            "console.log",
+            "formatResult",
+            "console.log",
+            "formatResult",
+            "Calculator",
+            "calculator.add",
+            "power",
+            "readFileSync",
+        ]
+    );
+    let run = &graph.get_nodes_by_name("run")[0];
+    let external = graph.get_outgoing_edges(&run.id)[9];
+    assert_eq!(external.metadata.get("import_path").unwrap(), "fs");
+    assert!(graph.edge_targets(external).is_empty());
+
+    // References to an exported function include the file importing it
+    let add = graph
+        .get_nodes_by_name("add")
+        .into_iter()
+        .find(|n| n.node_type == NodeType::Function)
+        .unwrap();
+    let references = graph.references("add");
+    assert!(references
+        .iter()
+        .all(|site| site.callee_id.as_ref() == Some(&add.id)));
+    let files: Vec<_> = references
+        .iter()
+        .filter_map(|site| site.file_path.file_name()?.to_str())
+        .collect();
+    assert_eq!(files, ["calculator.ts", "calculator.ts", "main.ts"]);
+
+    let format = graph.callers("formatResult");
+    assert_eq!(format.len(), 2);
+    assert!(format.iter().all(|site| site.caller == "run"));
+}
+
+#[test]
+fn test_module_bindings_are_recorded() {
+    let dir = fixture_dir("simple-ts");
+    let graph = index_dir(&dir, Language::TypeScript);
+
+    let barrel: Vec<_> = graph
+        .modules
+        .iter()
+        .filter(|b| b.file_path == dir.join("index.ts"))
+        .map(|b| {
+            (
+                b.kind,
+                b.source.as_deref(),
+                b.name.as_str(),
+                b.alias.as_str(),
+            )
+        })
+        .collect();
+    assert_eq!(
+        barrel,
+        [
+            (BindingKind::ReExport, Some("./calculator"), "*", "*"),
+            (
+                BindingKind::ReExport,
+                Some("./format"),
+                "default",
+                "describe"
+            ),
+        ]
+    );
+
+    let format = graph
+        .modules
+        .iter()
+        .find(|b| b.file_path == dir.join("format.ts"))
+        .unwrap();
+    assert_eq!(
+        (format.kind, format.name.as_str(), format.alias.as_str()),
+        (BindingKind::Export, "formatResult", "default")
+    );
+
+    let aliased = graph
+        .modules
+        .iter()
+        .find(|b| b.kind == BindingKind::Import && b.alias == "times")
+        .unwrap();
+    assert_eq!(
+        (
+            aliased.source.as_deref(),
+            aliased.name.as_str(),
+            aliased.line
+        ),
+        (Some("./calculator"), "multiply", 1)
+    );
+}
+
+#[test]
+fn test_reparsed_file_is_bound_again() {
+    let dir = tempfile::tempdir().unwrap();
+    let lib = dir.path().join("lib.ts");
+    fs::write(&lib, "export function greet() {}\n").unwrap();
+    fs::write(
+        dir.path().join("app.ts"),
+        "import { greet as hello } from './lib.js';\n\nfunction start() {\n  hello();\n}\n",
+    )
+    .unwrap();
+    let mut graph = index_dir(dir.path(), Language::TypeScript);
+    let greet = |graph: &CodeGraph| graph.get_nodes_by_name("greet")[0].id.clone();
+    assert_eq!(graph.callers("greet").len(), 1);
+
+    // The definition moves down a line, so its ID changes
+    fs::write(&lib, "\nexport function greet() {}\n").unwrap();
+    graph.remove_nodes_from_file(&lib.to_string_lossy());
+    TypeScriptParser::new(Language::TypeScript)
+        .unwrap()
+        .parse_source(&lib, &fs::read_to_string(&lib).unwrap(), &mut graph)
+        .unwrap();
+    TypeScriptParser::resolve_imports(&mut graph);
+
+    let start = &graph.get_nodes_by_name("start")[0];
+    let call = graph.get_outgoing_edges(&start.id)[0];
+    assert_eq!(call.metadata.get("target_id"), Some(&greet(&graph)));
+}
+
+#[test]
+fn test_javascript_classes_and_arrow_functions() {
+    let graph = index_dir(&fixture_dir("simple-js"), Language::JavaScript);
+
+    assert_eq!(
+        graph.get_nodes_by_name("Calculator")[0].node_type,
+        NodeType::Class
+    );
+    assert_eq!(
+        callees_of(&graph, "greet"),
+        ["formatMessage", "console.log"]
+    );
+    assert_eq!(
+        callees_of(&graph, "Calculator.add"),
+        ["add", "Calculator.logOperation"]
+    );
+    let params: Vec<_> = graph.get_nodes_by_name("multiply")[0]
+        .parameters
+        .iter()
+        .map(|p| p.name.as_str())
+        .collect();
+    assert_eq!(params, ["x", "y"]);
+}
@@ -0,0 +1,115 @@
+use code_navigator::core::CodeGraph;
+use code_navigator::parser::GoParser;
+use code_navigator::watch::update_go_file;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// Copy a fixture into a temp dir and index it the way `watch` does before listening
+fn indexed_copy(name: &str) -> (tempfile::TempDir, PathBuf, CodeGraph, GoParser) {
+    let src = Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests")
+        .join("fixtures")
+        .join(name);
+    let dir = tempfile::tempdir().unwrap();
+    for entry in fs::read_dir(src).unwrap() {
+        let path = entry.unwrap().path();
+        fs::copy(&path, dir.path().join(path.file_name().unwrap())).unwrap();
+    }
+
+    let root = dir.path().canonicalize().unwrap();
+    let mut graph = CodeGraph::new(root.to_string_lossy().to_string(), "go".to_string());
+    let mut parser = GoParser::new().unwrap();
+    parser.parse_directory(&root, &mut graph).unwrap();
+    (dir, root, graph, parser)
+}
+
+fn caller_names(graph: &CodeGraph, symbol: &str) -> Vec<String> {
+    let mut names: Vec<String> = graph
+        .callers(symbol)
+        .into_iter()
+        .map(|site| site.caller)
+        .collect();
+    names.sort();
+    names
+}
+
+#[test]
+fn test_deleting_a_file_removes_its_symbols() {
+    let (_dir, root, mut graph, mut parser) = indexed_copy("simple-go");
+    assert_eq!(
+        caller_names(&graph, "PrintMessage"),
+        vec!["(*Calculator).LogOperation", "Greet.func1"]
+    );
+
+    let calculator = root.join("calculator.go");
+    fs::remove_file(&calculator).unwrap();
+    let update = update_go_file(&mut graph, &mut parser, &calculator).unwrap();
+
+    assert!(update.added.is_empty());
+    assert!(update.changed.is_empty());
+    assert_eq!(
+        update.removed,
+        vec![
+            "(*Calculator).Add",
+            "(*Calculator).LogOperation",
+            "(*Calculator).SetName",
+            "(*Calculator).Subtract",
+            "(*Recorder).LogOperation",
+            "(*logger).Warn",
+            "Calculator",
+            "Calculator.Name",
+            "Calculator.name",
+            "Logger",
+            "Logger.LogOperation",
+            "Logger.Name",
+            "NewCalculator",
+            "Recorder",
+            "logger",
+        ]
+    );
+    assert!(graph.get_nodes_by_name("(*Calculator).Add").is_empty());
+    assert!(graph
+        .edges
+        .iter()
+        .all(|e| e.file_path != calculator && graph.get_node_by_id(&e.from).is_some()));
+    assert_eq!(caller_names(&graph, "PrintMessage"), vec!["Greet.func1"]);
+}
+
+#[test]
+fn test_modified_file_is_patched_in_place() {
+    let (_dir, root, mut graph, mut parser) = indexed_copy("simple-go");
+
+    let main_go = root.join("main.go");
+    let mut source = fs::read_to_string(&main_go).unwrap();
+    source.push_str("\nfunc Shout() {\n\tPrintMessage(\"HELLO\")\n}\n");
+    fs::write(&main_go, source).unwrap();
+    let update = update_go_file(&mut graph, &mut parser, &main_go).unwrap();
+
+    assert_eq!(update.added, vec!["Shout"]);
+    assert!(update.removed.is_empty());
+    assert!(update.changed.is_empty());
+
+    // Calls from the untouched calculator.go are re-bound to the re-parsed definition
+    assert_eq!(
+        caller_names(&graph, "PrintMessage"),
+        vec!["(*Calculator).LogOperation", "Greet.func1", "Shout"]
+    );
+    let print_message = &graph.get_nodes_by_name("PrintMessage")[0];
+    assert!(graph
+        .edges
+        .iter()
+        .filter(|e| e.to == "PrintMessage")
+        .all(|e| e.metadata.get("target_id") == Some(&print_message.id)));
+}
+
+#[test]
+fn test_unchanged_save_reports_nothing() {
+    let (_dir, root, mut graph, mut parser) = indexed_copy("simple-go");
+    let nodes = graph.nodes.len();
+    let edges = graph.edges.len();
+
+    let update = update_go_file(&mut graph, &mut parser, &root.join("main.go")).unwrap();
+    assert!(update.is_empty());
+    assert_eq!(graph.nodes.len(), nodes);
+    assert_eq!(graph.edges.len(), edges);
+}